@@ -293,6 +293,21 @@ func (s *InstanceService) UpdateInstance(ctx context.Context, request *v1pb.Upda
 				patch.OptionsUpsert = instance.Options
 			}
 			patch.OptionsUpsert.MaximumConnections = request.Instance.Options.GetMaximumConnections()
+		case "options.region":
+			if patch.OptionsUpsert == nil {
+				patch.OptionsUpsert = instance.Options
+			}
+			patch.OptionsUpsert.Region = request.Instance.Options.GetRegion()
+		case "options.backup_blackout_windows":
+			for _, window := range request.Instance.Options.GetBackupBlackoutWindows() {
+				if _, _, err := common.ParsePriorBackupBlackoutWindow(window); err != nil {
+					return nil, status.Errorf(codes.InvalidArgument, err.Error())
+				}
+			}
+			if patch.OptionsUpsert == nil {
+				patch.OptionsUpsert = instance.Options
+			}
+			patch.OptionsUpsert.BackupBlackoutWindows = request.Instance.Options.GetBackupBlackoutWindows()
 		default:
 			return nil, status.Errorf(codes.InvalidArgument, `unsupported update_mask "%s"`, path)
 		}
@@ -1006,6 +1021,11 @@ func (s *InstanceService) convertToInstanceMessage(instanceID string, instance *
 	if err != nil {
 		return nil, err
 	}
+	for _, window := range instance.Options.GetBackupBlackoutWindows() {
+		if _, _, err := common.ParsePriorBackupBlackoutWindow(window); err != nil {
+			return nil, err
+		}
+	}
 
 	return &store.InstanceMessage{
 		ResourceID:    instanceID,
@@ -1376,8 +1396,10 @@ func convertToInstanceOptions(options *storepb.InstanceOptions) *v1pb.InstanceOp
 	}
 
 	return &v1pb.InstanceOptions{
-		SyncInterval:       options.SyncInterval,
-		MaximumConnections: options.MaximumConnections,
+		SyncInterval:          options.SyncInterval,
+		MaximumConnections:    options.MaximumConnections,
+		Region:                options.Region,
+		BackupBlackoutWindows: options.BackupBlackoutWindows,
 	}
 }
 
@@ -1387,7 +1409,9 @@ func convertInstanceOptions(options *v1pb.InstanceOptions) *storepb.InstanceOpti
 	}
 
 	return &storepb.InstanceOptions{
-		SyncInterval:       options.SyncInterval,
-		MaximumConnections: options.MaximumConnections,
+		SyncInterval:          options.SyncInterval,
+		MaximumConnections:    options.MaximumConnections,
+		Region:                options.Region,
+		BackupBlackoutWindows: options.BackupBlackoutWindows,
 	}
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/bytebase/bytebase/backend/common"
@@ -486,23 +487,79 @@ func (s *PlanService) UpdatePlan(ctx context.Context, request *v1pb.UpdatePlanRe
 							return nil
 						}
 
-						var databaseName *string
-						if config.ChangeDatabaseConfig.PreUpdateBackupDetail == nil {
-							if payload.PreUpdateBackupDetail.Database != "" {
-								emptyValue := ""
-								databaseName = &emptyValue
+						preUpdateBackupDetail := convertToTaskPreUpdateBackupDetail(convertPlanPreUpdateBackupDetail(config.ChangeDatabaseConfig.PreUpdateBackupDetail))
+						currentPreUpdateBackupDetail := payload.PreUpdateBackupDetail
+						if currentPreUpdateBackupDetail == nil {
+							currentPreUpdateBackupDetail = &storepb.PreUpdateBackupDetail{}
+						}
+						if proto.Equal(preUpdateBackupDetail, currentPreUpdateBackupDetail) {
+							return nil
+						}
+						if len(preUpdateBackupDetail.ShardDatabases) > 0 {
+							database, err := s.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{UID: task.DatabaseID})
+							if err != nil {
+								return status.Errorf(codes.Internal, "failed to get database: %v", err)
 							}
-						} else {
-							if config.ChangeDatabaseConfig.PreUpdateBackupDetail.Database != payload.PreUpdateBackupDetail.Database {
-								databaseName = &config.ChangeDatabaseConfig.PreUpdateBackupDetail.Database
+							if database == nil {
+								return status.Errorf(codes.NotFound, "database %d not found", *task.DatabaseID)
 							}
-						}
-						if databaseName != nil {
-							taskPatch.PreUpdateBackupDetail = &storepb.PreUpdateBackupDetail{
-								Database: *databaseName,
+							if err := checkShardDatabases(ctx, s.store, database, preUpdateBackupDetail.ShardDatabases); err != nil {
+								return status.Errorf(codes.InvalidArgument, "invalid shard databases: %v", err)
 							}
-							doUpdate = true
 						}
+						taskPatch.PreUpdateBackupDetail = preUpdateBackupDetail
+						doUpdate = true
+						return nil
+					}(); err != nil {
+						return nil, err
+					}
+
+					// BackupRequirement
+					if err := func() error {
+						if task.Type != api.TaskDatabaseDataUpdate {
+							return nil
+						}
+						payload := &storepb.TaskDatabaseUpdatePayload{}
+						if err := common.ProtojsonUnmarshaler.Unmarshal([]byte(task.Payload), payload); err != nil {
+							return status.Errorf(codes.Internal, "failed to unmarshal task payload: %v", err)
+						}
+						config, ok := spec.Config.(*v1pb.Plan_Spec_ChangeDatabaseConfig)
+						if !ok {
+							return nil
+						}
+						backupRequirement := storepb.TaskDatabaseUpdatePayload_BackupRequirement(config.ChangeDatabaseConfig.BackupRequirement)
+						if backupRequirement == payload.BackupRequirement {
+							return nil
+						}
+						taskPatch.BackupRequirement = &backupRequirement
+						doUpdate = true
+						return nil
+					}(); err != nil {
+						return nil, err
+					}
+
+					// BudgetDeadline
+					if err := func() error {
+						if task.Type != api.TaskDatabaseDataUpdate {
+							return nil
+						}
+						payload := &storepb.TaskDatabaseUpdatePayload{}
+						if err := common.ProtojsonUnmarshaler.Unmarshal([]byte(task.Payload), payload); err != nil {
+							return status.Errorf(codes.Internal, "failed to unmarshal task payload: %v", err)
+						}
+						config, ok := spec.Config.(*v1pb.Plan_Spec_ChangeDatabaseConfig)
+						if !ok {
+							return nil
+						}
+						if proto.Equal(config.ChangeDatabaseConfig.BudgetDeadline, payload.BudgetDeadline) {
+							return nil
+						}
+						var budgetDeadline time.Time
+						if config.ChangeDatabaseConfig.BudgetDeadline != nil {
+							budgetDeadline = config.ChangeDatabaseConfig.BudgetDeadline.AsTime()
+						}
+						taskPatch.BudgetDeadline = &budgetDeadline
+						doUpdate = true
 						return nil
 					}(); err != nil {
 						return nil, err
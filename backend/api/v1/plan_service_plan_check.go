@@ -167,6 +167,10 @@ func getPlanCheckRunsFromChangeDatabaseConfigDatabaseGroupTarget(ctx context.Con
 }
 
 func getPlanCheckRunsFromChangeDatabaseConfigDatabaseTarget(ctx context.Context, s *store.Store, plan *store.PlanMessage, config *storepb.PlanConfig_ChangeDatabaseConfig, scheduledDatabase map[int]bool) ([]*store.PlanCheckRunMessage, error) {
+	// The rollback restores the rows from the prior backup without a sheet to check.
+	if config.Type == storepb.PlanConfig_ChangeDatabaseConfig_DATA_ROLLBACK {
+		return nil, nil
+	}
 	instanceID, databaseName, err := common.GetInstanceDatabaseID(config.Target)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get instance and database from target %q", config.Target)
@@ -236,9 +240,7 @@ func getPlanCheckRunsFromChangeDatabaseConfigForDatabase(ctx context.Context, s
 
 	preUpdateBackupDetail := (*storepb.PreUpdateBackupDetail)(nil)
 	if config.PreUpdateBackupDetail != nil {
-		preUpdateBackupDetail = &storepb.PreUpdateBackupDetail{
-			Database: config.PreUpdateBackupDetail.Database,
-		}
+		preUpdateBackupDetail = convertToTaskPreUpdateBackupDetail(config.PreUpdateBackupDetail)
 	}
 	planCheckRuns = append(planCheckRuns, &store.PlanCheckRunMessage{
 		CreatorUID: api.SystemBotID,
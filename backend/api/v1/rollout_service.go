@@ -762,6 +762,10 @@ func (s *RolloutService) BatchCancelTaskRuns(ctx context.Context, request *v1pb.
 			if cancelFunc, ok := s.stateCfg.RunningTaskRunsCancelFunc.Load(taskRun.ID); ok {
 				cancelFunc.(context.CancelFunc)()
 			}
+			// The asynchronous prior backup outlives the runs of the task run, so it's canceled separately.
+			if cancelFunc, ok := s.stateCfg.PriorBackupAsyncCancelFunc.Load(taskRun.ID); ok {
+				cancelFunc.(context.CancelFunc)()
+			}
 		}
 	}
 
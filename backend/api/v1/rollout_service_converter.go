@@ -127,16 +127,25 @@ func convertToPlanSpecCreateDatabaseConfig(config *storepb.PlanConfig_Spec_Creat
 
 func convertToPlanSpecChangeDatabaseConfig(config *storepb.PlanConfig_Spec_ChangeDatabaseConfig) *v1pb.Plan_Spec_ChangeDatabaseConfig {
 	c := config.ChangeDatabaseConfig
+	var rollbackDetail *v1pb.Plan_ChangeDatabaseConfig_RollbackDetail
+	if c.RollbackDetail != nil {
+		rollbackDetail = &v1pb.Plan_ChangeDatabaseConfig_RollbackDetail{
+			TaskRun:          c.GetRollbackDetail().GetTaskRun(),
+			ConflictStrategy: c.GetRollbackDetail().GetConflictStrategy(),
+			Parallelism:      c.GetRollbackDetail().GetParallelism(),
+		}
+	}
 	return &v1pb.Plan_Spec_ChangeDatabaseConfig{
 		ChangeDatabaseConfig: &v1pb.Plan_ChangeDatabaseConfig{
-			Target:        c.Target,
-			Sheet:         c.Sheet,
-			Type:          convertToPlanSpecChangeDatabaseConfigType(c.Type),
-			SchemaVersion: c.SchemaVersion,
-			GhostFlags:    c.GhostFlags,
-			PreUpdateBackupDetail: &v1pb.Plan_ChangeDatabaseConfig_PreUpdateBackupDetail{
-				Database: c.PreUpdateBackupDetail.GetDatabase(),
-			},
+			Target:                c.Target,
+			Sheet:                 c.Sheet,
+			Type:                  convertToPlanSpecChangeDatabaseConfigType(c.Type),
+			SchemaVersion:         c.SchemaVersion,
+			GhostFlags:            c.GhostFlags,
+			PreUpdateBackupDetail: convertToPlanPreUpdateBackupDetail(c.PreUpdateBackupDetail),
+			RollbackDetail:        rollbackDetail,
+			BackupRequirement:     v1pb.Plan_ChangeDatabaseConfig_BackupRequirement(c.BackupRequirement),
+			BudgetDeadline:        c.BudgetDeadline,
 		},
 	}
 }
@@ -155,6 +164,8 @@ func convertToPlanSpecChangeDatabaseConfigType(t storepb.PlanConfig_ChangeDataba
 		return v1pb.Plan_ChangeDatabaseConfig_MIGRATE_GHOST
 	case storepb.PlanConfig_ChangeDatabaseConfig_DATA:
 		return v1pb.Plan_ChangeDatabaseConfig_DATA
+	case storepb.PlanConfig_ChangeDatabaseConfig_DATA_ROLLBACK:
+		return v1pb.Plan_ChangeDatabaseConfig_DATA_ROLLBACK
 	default:
 		return v1pb.Plan_ChangeDatabaseConfig_TYPE_UNSPECIFIED
 	}
@@ -236,10 +247,12 @@ func convertPlanConfigCreateDatabaseConfig(c *v1pb.Plan_CreateDatabaseConfig) *s
 
 func convertPlanSpecChangeDatabaseConfig(config *v1pb.Plan_Spec_ChangeDatabaseConfig) *storepb.PlanConfig_Spec_ChangeDatabaseConfig {
 	c := config.ChangeDatabaseConfig
-	var preUpdateBackupDetail *storepb.PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail
-	if c.PreUpdateBackupDetail != nil && c.GetPreUpdateBackupDetail().GetDatabase() != "" {
-		preUpdateBackupDetail = &storepb.PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail{
-			Database: c.GetPreUpdateBackupDetail().GetDatabase(),
+	var rollbackDetail *storepb.PlanConfig_ChangeDatabaseConfig_RollbackDetail
+	if c.RollbackDetail != nil {
+		rollbackDetail = &storepb.PlanConfig_ChangeDatabaseConfig_RollbackDetail{
+			TaskRun:          c.GetRollbackDetail().GetTaskRun(),
+			ConflictStrategy: c.GetRollbackDetail().GetConflictStrategy(),
+			Parallelism:      c.GetRollbackDetail().GetParallelism(),
 		}
 	}
 	return &storepb.PlanConfig_Spec_ChangeDatabaseConfig{
@@ -249,11 +262,54 @@ func convertPlanSpecChangeDatabaseConfig(config *v1pb.Plan_Spec_ChangeDatabaseCo
 			Type:                  storepb.PlanConfig_ChangeDatabaseConfig_Type(c.Type),
 			SchemaVersion:         c.SchemaVersion,
 			GhostFlags:            c.GhostFlags,
-			PreUpdateBackupDetail: preUpdateBackupDetail,
+			PreUpdateBackupDetail: convertPlanPreUpdateBackupDetail(c.PreUpdateBackupDetail),
+			RollbackDetail:        rollbackDetail,
+			BackupRequirement:     storepb.PlanConfig_ChangeDatabaseConfig_BackupRequirement(c.BackupRequirement),
+			BudgetDeadline:        c.BudgetDeadline,
 		},
 	}
 }
 
+func convertToPlanPreUpdateBackupDetail(detail *storepb.PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) *v1pb.Plan_ChangeDatabaseConfig_PreUpdateBackupDetail {
+	return &v1pb.Plan_ChangeDatabaseConfig_PreUpdateBackupDetail{
+		Database:          detail.GetDatabase(),
+		ShardDatabases:    detail.GetShardDatabases(),
+		EncryptionKey:     detail.GetEncryptionKey(),
+		ResourceGroup:     detail.GetResourceGroup(),
+		SampleRate:        detail.GetSampleRate(),
+		SinkTopic:         detail.GetSinkTopic(),
+		SessionRole:       detail.GetSessionRole(),
+		ProcedureTables:   detail.GetProcedureTables(),
+		BackupStatement:   detail.GetBackupStatement(),
+		SampleLimit:       detail.GetSampleLimit(),
+		SampleFromLast:    detail.GetSampleFromLast(),
+		PostSuccessPolicy: v1pb.Plan_ChangeDatabaseConfig_PreUpdateBackupDetail_PostSuccessPolicy(detail.GetPostSuccessPolicy()),
+		ObjectStorage:     detail.GetObjectStorage(),
+	}
+}
+
+// convertPlanPreUpdateBackupDetail returns nil if the backup is not set.
+func convertPlanPreUpdateBackupDetail(detail *v1pb.Plan_ChangeDatabaseConfig_PreUpdateBackupDetail) *storepb.PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail {
+	if detail.GetDatabase() == "" && detail.GetSinkTopic() == "" && !detail.GetObjectStorage() {
+		return nil
+	}
+	return &storepb.PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail{
+		Database:          detail.GetDatabase(),
+		ShardDatabases:    detail.GetShardDatabases(),
+		EncryptionKey:     detail.GetEncryptionKey(),
+		ResourceGroup:     detail.GetResourceGroup(),
+		SampleRate:        detail.GetSampleRate(),
+		SinkTopic:         detail.GetSinkTopic(),
+		SessionRole:       detail.GetSessionRole(),
+		ProcedureTables:   detail.GetProcedureTables(),
+		BackupStatement:   detail.GetBackupStatement(),
+		SampleLimit:       detail.GetSampleLimit(),
+		SampleFromLast:    detail.GetSampleFromLast(),
+		PostSuccessPolicy: storepb.PriorBackupPostSuccessPolicy(detail.GetPostSuccessPolicy()),
+		ObjectStorage:     detail.GetObjectStorage(),
+	}
+}
+
 func convertPlanSpecExportDataConfig(config *v1pb.Plan_Spec_ExportDataConfig) *storepb.PlanConfig_Spec_ExportDataConfig {
 	c := config.ExportDataConfig
 	return &storepb.PlanConfig_Spec_ExportDataConfig{
@@ -304,7 +304,7 @@ func getTaskCreatesFromExportDataConfig(ctx context.Context, s *store.Store, spe
 	return []*store.TaskMessage{taskCreate}, nil, nil
 }
 
-func getTaskCreatesFromChangeDatabaseConfigDatabaseTarget(ctx context.Context, s *store.Store, spec *storepb.PlanConfig_Spec, c *storepb.PlanConfig_ChangeDatabaseConfig, _ *store.ProjectMessage, registerEnvironmentID func(string) error) ([]*store.TaskMessage, []store.TaskIndexDAG, error) {
+func getTaskCreatesFromChangeDatabaseConfigDatabaseTarget(ctx context.Context, s *store.Store, spec *storepb.PlanConfig_Spec, c *storepb.PlanConfig_ChangeDatabaseConfig, project *store.ProjectMessage, registerEnvironmentID func(string) error) ([]*store.TaskMessage, []store.TaskIndexDAG, error) {
 	instanceID, databaseName, err := common.GetInstanceDatabaseID(c.Target)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "failed to get instance and database from target %q", c.Target)
@@ -460,25 +460,13 @@ func getTaskCreatesFromChangeDatabaseConfigDatabaseTarget(ctx context.Context, s
 		return taskCreateList, taskIndexDAGList, nil
 
 	case storepb.PlanConfig_ChangeDatabaseConfig_DATA:
-		_, sheetUID, err := common.GetProjectResourceIDSheetUID(c.Sheet)
-		if err != nil {
-			return nil, nil, errors.Wrapf(err, "failed to get sheet id from sheet %q", c.Sheet)
-		}
-		preUpdateBackupDetail := &storepb.PreUpdateBackupDetail{}
-		if c.GetPreUpdateBackupDetail().GetDatabase() != "" {
-			preUpdateBackupDetail.Database = c.GetPreUpdateBackupDetail().GetDatabase()
+		if err := checkShardDatabases(ctx, s, database, c.GetPreUpdateBackupDetail().GetShardDatabases()); err != nil {
+			return nil, nil, err
 		}
-		payload := &storepb.TaskDatabaseUpdatePayload{
-			SpecId:                spec.Id,
-			SheetId:               int32(sheetUID),
-			SchemaVersion:         getOrDefaultSchemaVersion(c.SchemaVersion),
-			PreUpdateBackupDetail: preUpdateBackupDetail,
-		}
-		bytes, err := protojson.Marshal(payload)
+		payloadString, err := getTaskDatabaseDataUpdatePayload(spec, c)
 		if err != nil {
-			return nil, nil, errors.Wrapf(err, "Failed to marshal database data update payload")
+			return nil, nil, err
 		}
-		payloadString := string(bytes)
 		taskCreate := &store.TaskMessage{
 			Name:              fmt.Sprintf("DML(data) for database %q", database.DatabaseName),
 			InstanceID:        instance.UID,
@@ -488,11 +476,136 @@ func getTaskCreatesFromChangeDatabaseConfigDatabaseTarget(ctx context.Context, s
 			Payload:           payloadString,
 		}
 		return []*store.TaskMessage{taskCreate}, nil, nil
+
+	case storepb.PlanConfig_ChangeDatabaseConfig_DATA_ROLLBACK:
+		payloadString, err := getTaskDatabaseDataRollbackPayload(spec, c, project.ResourceID, instance.Engine)
+		if err != nil {
+			return nil, nil, err
+		}
+		taskCreate := &store.TaskMessage{
+			Name:              fmt.Sprintf("Rollback DML(data) for database %q", database.DatabaseName),
+			InstanceID:        instance.UID,
+			DatabaseID:        &database.UID,
+			Type:              api.TaskDatabaseDataRollback,
+			EarliestAllowedTs: spec.EarliestAllowedTime.GetSeconds(),
+			Payload:           payloadString,
+		}
+		return []*store.TaskMessage{taskCreate}, nil, nil
 	default:
 		return nil, nil, errors.Errorf("unsupported change database config type %q", c.Type)
 	}
 }
 
+// getTaskDatabaseDataRollbackPayload returns the payload of the data rollback task of the spec, rolling back a task run of the project
+// on an instance of the engine.
+func getTaskDatabaseDataRollbackPayload(spec *storepb.PlanConfig_Spec, c *storepb.PlanConfig_ChangeDatabaseConfig, projectID string, engine storepb.Engine) (string, error) {
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_POSTGRES:
+	default:
+		return "", errors.Errorf("data rollback is not supported for engine %s", engine)
+	}
+	taskRunProjectID, _, _, _, taskRunUID, err := common.GetProjectIDRolloutIDStageIDTaskIDTaskRunID(c.GetRollbackDetail().GetTaskRun())
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get task run id from task run %q", c.GetRollbackDetail().GetTaskRun())
+	}
+	if taskRunProjectID != projectID {
+		return "", errors.Errorf("task run %q does not belong to project %q", c.GetRollbackDetail().GetTaskRun(), projectID)
+	}
+	payload := &storepb.TaskDatabaseDataRollbackPayload{
+		SpecId:           spec.Id,
+		TaskRunUid:       int32(taskRunUID),
+		ConflictStrategy: c.GetRollbackDetail().GetConflictStrategy(),
+		Parallelism:      c.GetRollbackDetail().GetParallelism(),
+	}
+	bytes, err := protojson.Marshal(payload)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to marshal database data rollback payload")
+	}
+	return string(bytes), nil
+}
+
+// getTaskDatabaseDataUpdatePayload returns the payload of the data update task of the spec.
+func getTaskDatabaseDataUpdatePayload(spec *storepb.PlanConfig_Spec, c *storepb.PlanConfig_ChangeDatabaseConfig) (string, error) {
+	_, sheetUID, err := common.GetProjectResourceIDSheetUID(c.Sheet)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get sheet id from sheet %q", c.Sheet)
+	}
+	payload := &storepb.TaskDatabaseUpdatePayload{
+		SpecId:                spec.Id,
+		SheetId:               int32(sheetUID),
+		SchemaVersion:         getOrDefaultSchemaVersion(c.SchemaVersion),
+		PreUpdateBackupDetail: convertToTaskPreUpdateBackupDetail(c.PreUpdateBackupDetail),
+		BackupRequirement:     storepb.TaskDatabaseUpdatePayload_BackupRequirement(c.BackupRequirement),
+		BudgetDeadline:        c.BudgetDeadline,
+	}
+	bytes, err := protojson.Marshal(payload)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to marshal database data update payload")
+	}
+	return string(bytes), nil
+}
+
+// convertToTaskPreUpdateBackupDetail converts the backup detail of the plan to the one of the task.
+func convertToTaskPreUpdateBackupDetail(detail *storepb.PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) *storepb.PreUpdateBackupDetail {
+	return &storepb.PreUpdateBackupDetail{
+		Database:          detail.GetDatabase(),
+		ShardDatabases:    detail.GetShardDatabases(),
+		EncryptionKey:     detail.GetEncryptionKey(),
+		ResourceGroup:     detail.GetResourceGroup(),
+		SampleRate:        detail.GetSampleRate(),
+		SinkTopic:         detail.GetSinkTopic(),
+		SessionRole:       detail.GetSessionRole(),
+		ProcedureTables:   detail.GetProcedureTables(),
+		BackupStatement:   detail.GetBackupStatement(),
+		SampleLimit:       detail.GetSampleLimit(),
+		SampleFromLast:    detail.GetSampleFromLast(),
+		PostSuccessPolicy: detail.GetPostSuccessPolicy(),
+		ObjectStorage:     detail.GetObjectStorage(),
+	}
+}
+
+// checkShardDatabases checks that the shard databases of the backup exist in the project and engine of the task database.
+func checkShardDatabases(ctx context.Context, s *store.Store, database *store.DatabaseMessage, shardDatabases []string) error {
+	instance, err := s.GetInstanceV2(ctx, &store.FindInstanceMessage{ResourceID: &database.InstanceID})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get instance %q", database.InstanceID)
+	}
+	for _, shardDatabase := range shardDatabases {
+		instanceID, databaseName, err := common.GetInstanceDatabaseID(shardDatabase)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get instance and database from shard database %q", shardDatabase)
+		}
+		if instanceID == database.InstanceID && databaseName == database.DatabaseName {
+			return errors.Errorf("shard database %q is the task database", shardDatabase)
+		}
+		shardInstance, err := s.GetInstanceV2(ctx, &store.FindInstanceMessage{ResourceID: &instanceID})
+		if err != nil {
+			return errors.Wrapf(err, "failed to get instance %q", instanceID)
+		}
+		if shardInstance == nil {
+			return errors.Errorf("instance %q not found", instanceID)
+		}
+		if shardInstance.Engine != instance.Engine {
+			return errors.Errorf("engine %s of shard database %q mismatches the engine %s of the task database", shardInstance.Engine, shardDatabase, instance.Engine)
+		}
+		shard, err := s.GetDatabaseV2(ctx, &store.FindDatabaseMessage{
+			InstanceID:          &instanceID,
+			DatabaseName:        &databaseName,
+			IgnoreCaseSensitive: store.IgnoreDatabaseAndTableCaseSensitive(shardInstance),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to get database %q", databaseName)
+		}
+		if shard == nil {
+			return errors.Errorf("shard database %q not found", shardDatabase)
+		}
+		if shard.ProjectID != database.ProjectID {
+			return errors.Errorf("shard database %q does not belong to project %q", shardDatabase, database.ProjectID)
+		}
+	}
+	return nil
+}
+
 // checkCharacterSetCollationOwner checks if the character set, collation and owner are legal according to the dbType.
 func checkCharacterSetCollationOwner(dbType storepb.Engine, characterSet, collation, owner string) error {
 	switch dbType {
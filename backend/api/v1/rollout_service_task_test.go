@@ -0,0 +1,154 @@
+package v1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/bytebase/bytebase/backend/common"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+	v1pb "github.com/bytebase/bytebase/proto/generated-go/v1"
+)
+
+func TestGetTaskDatabaseDataUpdatePayload(t *testing.T) {
+	a := require.New(t)
+
+	spec := &v1pb.Plan_Spec{
+		Id: "spec",
+		Config: &v1pb.Plan_Spec_ChangeDatabaseConfig{
+			ChangeDatabaseConfig: &v1pb.Plan_ChangeDatabaseConfig{
+				Target: "instances/i/databases/db",
+				Sheet:  "projects/p/sheets/101",
+				Type:   v1pb.Plan_ChangeDatabaseConfig_DATA,
+				// The pipeline must finish before the maintenance window ends.
+				BudgetDeadline: timestamppb.New(time.Date(2024, 1, 10, 22, 0, 0, 0, time.UTC)),
+				PreUpdateBackupDetail: &v1pb.Plan_ChangeDatabaseConfig_PreUpdateBackupDetail{
+					ShardDatabases:    []string{"instances/i2/databases/db"},
+					EncryptionKey:     "alias/backup",
+					ResourceGroup:     "rg",
+					SampleRate:        10,
+					SessionRole:       "backup_role",
+					ProcedureTables:   []string{"public.t"},
+					BackupStatement:   "DELETE FROM t;",
+					SampleLimit:       5,
+					SampleFromLast:    true,
+					PostSuccessPolicy: v1pb.Plan_ChangeDatabaseConfig_PreUpdateBackupDetail_DROP_IMMEDIATELY,
+					ObjectStorage:     true,
+				},
+			},
+		},
+	}
+	storeSpec := convertPlanSpec(spec)
+	// The plan keeps the backup options.
+	a.True(proto.Equal(spec, convertToPlanSpec(storeSpec)))
+
+	payloadString, err := getTaskDatabaseDataUpdatePayload(storeSpec, storeSpec.GetChangeDatabaseConfig())
+	a.NoError(err)
+	payload := &storepb.TaskDatabaseUpdatePayload{}
+	a.NoError(common.ProtojsonUnmarshaler.Unmarshal([]byte(payloadString), payload))
+	a.Equal("spec", payload.SpecId)
+	a.Equal(int32(101), payload.SheetId)
+	a.Equal(time.Date(2024, 1, 10, 22, 0, 0, 0, time.UTC), payload.BudgetDeadline.AsTime())
+	want := &storepb.PreUpdateBackupDetail{
+		ShardDatabases:    []string{"instances/i2/databases/db"},
+		EncryptionKey:     "alias/backup",
+		ResourceGroup:     "rg",
+		SampleRate:        10,
+		SessionRole:       "backup_role",
+		ProcedureTables:   []string{"public.t"},
+		BackupStatement:   "DELETE FROM t;",
+		SampleLimit:       5,
+		SampleFromLast:    true,
+		PostSuccessPolicy: storepb.PriorBackupPostSuccessPolicy_DROP_IMMEDIATELY,
+		ObjectStorage:     true,
+	}
+	a.True(proto.Equal(want, payload.PreUpdateBackupDetail), "got %v", payload.PreUpdateBackupDetail)
+
+	// The backup without the backup database, sink or object storage is not set.
+	spec.GetChangeDatabaseConfig().PreUpdateBackupDetail = &v1pb.Plan_ChangeDatabaseConfig_PreUpdateBackupDetail{EncryptionKey: "alias/backup"}
+	a.Nil(convertPlanSpec(spec).GetChangeDatabaseConfig().GetPreUpdateBackupDetail())
+}
+
+func TestGetTaskDatabaseDataUpdatePayloadBackupRequirement(t *testing.T) {
+	tests := []struct {
+		requirement v1pb.Plan_ChangeDatabaseConfig_BackupRequirement
+		want        storepb.TaskDatabaseUpdatePayload_BackupRequirement
+	}{
+		{
+			requirement: v1pb.Plan_ChangeDatabaseConfig_BACKUP_REQUIREMENT_UNSPECIFIED,
+			want:        storepb.TaskDatabaseUpdatePayload_BACKUP_REQUIREMENT_UNSPECIFIED,
+		},
+		{
+			requirement: v1pb.Plan_ChangeDatabaseConfig_BEST_EFFORT,
+			want:        storepb.TaskDatabaseUpdatePayload_BEST_EFFORT,
+		},
+		{
+			requirement: v1pb.Plan_ChangeDatabaseConfig_DISABLED,
+			want:        storepb.TaskDatabaseUpdatePayload_DISABLED,
+		},
+	}
+
+	a := require.New(t)
+	for _, test := range tests {
+		spec := convertPlanSpec(&v1pb.Plan_Spec{
+			Id: "spec",
+			Config: &v1pb.Plan_Spec_ChangeDatabaseConfig{
+				ChangeDatabaseConfig: &v1pb.Plan_ChangeDatabaseConfig{
+					Target: "instances/i/databases/db",
+					Sheet:  "projects/p/sheets/101",
+					Type:   v1pb.Plan_ChangeDatabaseConfig_DATA,
+					PreUpdateBackupDetail: &v1pb.Plan_ChangeDatabaseConfig_PreUpdateBackupDetail{
+						Database: "instances/i/databases/bbdataarchive",
+					},
+					BackupRequirement: test.requirement,
+				},
+			},
+		})
+		a.Equal(test.requirement, convertToPlanSpec(spec).GetChangeDatabaseConfig().GetBackupRequirement())
+
+		payloadString, err := getTaskDatabaseDataUpdatePayload(spec, spec.GetChangeDatabaseConfig())
+		a.NoError(err)
+		payload := &storepb.TaskDatabaseUpdatePayload{}
+		a.NoError(common.ProtojsonUnmarshaler.Unmarshal([]byte(payloadString), payload))
+		a.Equal(test.want, payload.BackupRequirement, test.requirement)
+	}
+}
+
+func TestGetTaskDatabaseDataRollbackPayload(t *testing.T) {
+	a := require.New(t)
+	spec := &storepb.PlanConfig_Spec{Id: "spec"}
+	c := &storepb.PlanConfig_ChangeDatabaseConfig{
+		Target: "instances/i/databases/db",
+		Type:   storepb.PlanConfig_ChangeDatabaseConfig_DATA_ROLLBACK,
+		RollbackDetail: &storepb.PlanConfig_ChangeDatabaseConfig_RollbackDetail{
+			TaskRun:          "projects/p/rollouts/1/stages/2/tasks/3/taskRuns/4",
+			ConflictStrategy: "SKIP",
+			Parallelism:      2,
+		},
+	}
+
+	payloadString, err := getTaskDatabaseDataRollbackPayload(spec, c, "p", storepb.Engine_POSTGRES)
+	a.NoError(err)
+	payload := &storepb.TaskDatabaseDataRollbackPayload{}
+	a.NoError(common.ProtojsonUnmarshaler.Unmarshal([]byte(payloadString), payload))
+	want := &storepb.TaskDatabaseDataRollbackPayload{
+		SpecId:           "spec",
+		TaskRunUid:       4,
+		ConflictStrategy: "SKIP",
+		Parallelism:      2,
+	}
+	a.True(proto.Equal(want, payload), "got %v", payload)
+
+	// The task runs of the other projects are not rolled back.
+	_, err = getTaskDatabaseDataRollbackPayload(spec, c, "other", storepb.Engine_MYSQL)
+	a.ErrorContains(err, `does not belong to project "other"`)
+
+	// The data rollback only restores the prior backups of MySQL and Postgres.
+	for _, engine := range []storepb.Engine{storepb.Engine_TIDB, storepb.Engine_ORACLE, storepb.Engine_MSSQL} {
+		_, err = getTaskDatabaseDataRollbackPayload(spec, c, "p", engine)
+		a.ErrorContains(err, "data rollback is not supported for engine", engine)
+	}
+}
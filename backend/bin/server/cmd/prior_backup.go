@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/bytebase/bytebase/backend/component/config"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+// priorBackupFlags are the command line config of the prior backups of the data update tasks.
+// See the PriorBackup fields of config.Profile for their details.
+var priorBackupFlags struct {
+	isolationLevel        string
+	skipTableComment      bool
+	lockRows              bool
+	bulkCopy              bool
+	bulkCopyRows          int64
+	perIssueDatabase      bool
+	replicateTablespace   bool
+	dropOrphans           bool
+	orderByDependency     bool
+	resourceGroup         string
+	reducedDurability     bool
+	immutableTable        bool
+	smallTableRows        int64
+	schemaSyncDelay       time.Duration
+	surrogateKey          bool
+	timeout               time.Duration
+	readTimeout           time.Duration
+	writeTimeout          time.Duration
+	consistentSnapshot    bool
+	exportSnapshot        bool
+	sessionRole           string
+	partitionRows         int64
+	databaseSnapshotRows  int64
+	analyzeTable          bool
+	engineConcurrency     map[string]int
+	maximumUncertainty    float64
+	failOnUncertainty     bool
+	namespaceLabel        string
+	sizeAnomalyFactor     float64
+	afterImage            bool
+	archive               bool
+	budgetPolicy          string
+	verifyDrivers         bool
+	retention             time.Duration
+	environmentRetentions map[string]string
+	async                 bool
+	maxGap                time.Duration
+	verifyCoverage        bool
+	consolidateComments   bool
+	nodeTag               string
+	failOnVersionGap      bool
+	maintenance           bool
+	savepointPolicy       string
+	postSuccessPolicy     string
+	postSuccessDropAfter  time.Duration
+	versionColumns        []string
+	sinkBrokers           []string
+	sinkTLS               bool
+	sinkUsername          string
+	sinkPassword          string
+	objectStoreURL        string
+	objectStoreEndpoint   string
+	objectStoreRegion     string
+	objectStoreKeyID      string
+	objectStoreSecret     string
+	objectStoreConnection string
+	// captureExplainPlan captures the EXPLAIN plans of the data update statements.
+	captureExplainPlan bool
+}
+
+func initPriorBackupFlags() {
+	f := rootCmd.PersistentFlags()
+	f.StringVar(&priorBackupFlags.isolationLevel, "prior-backup-isolation-level", "", "isolation level such as READ COMMITTED of the prior backup statements. Empty means a consistent snapshot on the engines supporting it")
+	f.BoolVar(&priorBackupFlags.skipTableComment, "prior-backup-skip-table-comment", false, "skip tagging the prior backup tables with the issue by table comments")
+	f.BoolVar(&priorBackupFlags.lockRows, "prior-backup-lock-rows", false, "run the prior backup statements in the data update transaction with SELECT ... FOR UPDATE. Postgres only")
+	f.BoolVar(&priorBackupFlags.bulkCopy, "prior-backup-bulk-copy", false, "copy the prior backup rows with the bulk copy facility of the engine such as Postgres COPY")
+	f.Int64Var(&priorBackupFlags.bulkCopyRows, "prior-backup-bulk-copy-rows", 0, "use the bulk copy for the source tables with at least this many rows. 0 disables the selection by size")
+	f.BoolVar(&priorBackupFlags.perIssueDatabase, "prior-backup-per-issue-database", false, "keep the prior backup tables of each issue in a dedicated backup database")
+	f.BoolVar(&priorBackupFlags.replicateTablespace, "prior-backup-replicate-tablespace", false, "create the prior backup tables in the tablespaces of the source tables on Oracle and Postgres")
+	f.BoolVar(&priorBackupFlags.dropOrphans, "prior-backup-drop-orphans", false, "drop the orphaned prior backup tables instead of only reporting them")
+	f.BoolVar(&priorBackupFlags.orderByDependency, "prior-backup-order-by-dependency", false, "order the prior backup statements by the foreign key dependencies of the source tables")
+	f.StringVar(&priorBackupFlags.resourceGroup, "prior-backup-resource-group", "", "TiDB resource control group of the prior backup sessions")
+	f.BoolVar(&priorBackupFlags.reducedDurability, "prior-backup-reduced-durability", false, "create the prior backup tables without redo logging during the copy")
+	f.BoolVar(&priorBackupFlags.immutableTable, "prior-backup-immutable-table", false, "create the prior backup tables as Oracle immutable tables")
+	f.Int64Var(&priorBackupFlags.smallTableRows, "prior-backup-small-table-rows", 0, "back up the tables with at most this many rows by the lightweight path. 0 disables it")
+	f.DurationVar(&priorBackupFlags.schemaSyncDelay, "prior-backup-schema-sync-delay", 0, "grace delay before syncing the schema after the prior backup tables are created")
+	f.BoolVar(&priorBackupFlags.surrogateKey, "prior-backup-surrogate-key", false, "add a surrogate key column to the prior backup tables on Oracle and Postgres")
+	f.DurationVar(&priorBackupFlags.timeout, "prior-backup-timeout", 0, "overall deadline of the prior backup of a task. 0 means no deadline")
+	f.DurationVar(&priorBackupFlags.readTimeout, "prior-backup-read-timeout", 0, "timeout of reading the rows from the source tables. 0 means no timeout")
+	f.DurationVar(&priorBackupFlags.writeTimeout, "prior-backup-write-timeout", 0, "timeout of writing the rows into the prior backup tables. 0 means no timeout")
+	f.BoolVar(&priorBackupFlags.consistentSnapshot, "prior-backup-consistent-snapshot", false, "read the source tables of a database in one consistent snapshot")
+	f.BoolVar(&priorBackupFlags.exportSnapshot, "prior-backup-export-snapshot", false, "share a snapshot exported by pg_export_snapshot across the prior backup statements on Postgres")
+	f.StringVar(&priorBackupFlags.sessionRole, "prior-backup-session-role", "", "database role the prior backup statements run under on Postgres and MSSQL")
+	f.Int64Var(&priorBackupFlags.partitionRows, "prior-backup-partition-rows", 0, "split the prior backup of a source table affecting more rows than this into multiple tables. 0 disables it")
+	f.Int64Var(&priorBackupFlags.databaseSnapshotRows, "prior-backup-database-snapshot-rows", 0, "back up the database by the native snapshot when the tasks affect at least this many rows. 0 disables it")
+	f.BoolVar(&priorBackupFlags.analyzeTable, "prior-backup-analyze-table", false, "update the optimizer statistics of the prior backup tables after they are created")
+	f.StringToIntVar(&priorBackupFlags.engineConcurrency, "prior-backup-engine-concurrency", nil, "maximum number of databases of each engine backed up concurrently, e.g. MYSQL=2,POSTGRES=4")
+	f.Float64Var(&priorBackupFlags.maximumUncertainty, "prior-backup-maximum-uncertainty", 0, "maximum uncertainty from 0 to 1 that a prior backup statement selects exactly the affected rows")
+	f.BoolVar(&priorBackupFlags.failOnUncertainty, "prior-backup-fail-on-uncertainty", false, "fail the prior backup instead of backing up the whole tables when a statement is too uncertain")
+	f.StringVar(&priorBackupFlags.namespaceLabel, "prior-backup-namespace-label", "", "database label whose value namespaces the prior backup table names")
+	f.Float64Var(&priorBackupFlags.sizeAnomalyFactor, "prior-backup-size-anomaly-factor", 0, "warn if a prior backup table has more than this factor times, or less than one over this factor of, the median rows of the recent backups of the same source table. 0 disables it")
+	f.BoolVar(&priorBackupFlags.afterImage, "prior-backup-after-image", false, "also create a companion table with the new values of the rows backed up for UPDATE")
+	f.BoolVar(&priorBackupFlags.archive, "prior-backup-archive", false, "also append the prior backup rows to the monthly-partitioned archive table of the source table")
+	f.StringVar(&priorBackupFlags.budgetPolicy, "prior-backup-budget-policy", "", "what happens to the prior backup estimated to run past the budget deadline of the task: WARN or DEFER")
+	f.BoolVar(&priorBackupFlags.verifyDrivers, "prior-backup-verify-drivers", false, "verify that the prior backup drivers are connected to the intended databases")
+	f.DurationVar(&priorBackupFlags.retention, "prior-backup-retention", 0, "default retention of the prior backup tables. 0 keeps them")
+	f.StringToStringVar(&priorBackupFlags.environmentRetentions, "prior-backup-environment-retentions", nil, "retentions of the prior backup tables by environment ID, e.g. prod=2160h,test=168h")
+	f.BoolVar(&priorBackupFlags.async, "prior-backup-async", false, "run the prior backups in the background instead of blocking the task runner")
+	f.DurationVar(&priorBackupFlags.maxGap, "prior-backup-max-gap", 0, "maximum time between the completion of the asynchronous prior backup and the data update. 0 means no limit")
+	f.BoolVar(&priorBackupFlags.verifyCoverage, "prior-backup-verify-coverage", false, "verify after the data update that the updated rows are in the prior backup tables")
+	f.BoolVar(&priorBackupFlags.consolidateComments, "prior-backup-consolidate-comments", false, "create one issue comment listing all the prior backup tables of a database")
+	f.StringVar(&priorBackupFlags.nodeTag, "prior-backup-node-tag", "", "node tag of the data sources the prior backups run on")
+	f.BoolVar(&priorBackupFlags.failOnVersionGap, "prior-backup-fail-on-version-gap", false, "fail the prior backup if the backup database runs an older engine version than the source")
+	f.BoolVar(&priorBackupFlags.maintenance, "prior-backup-maintenance", false, "run the maintenance such as VACUUM of each source table before copying its prior backup")
+	f.StringVar(&priorBackupFlags.savepointPolicy, "prior-backup-savepoint-policy", "", "run the prior backup in the data update transaction followed by a savepoint on Postgres and MySQL with InnoDB, with this rollback policy: KEEP_BACKUP or UNDO_BACKUP")
+	f.StringVar(&priorBackupFlags.postSuccessPolicy, "prior-backup-post-success-policy", "", "what happens to the prior backup tables after the data update succeeds: KEEP, DROP_AFTER or DROP_IMMEDIATELY")
+	f.DurationVar(&priorBackupFlags.postSuccessDropAfter, "prior-backup-post-success-drop-after", 0, "delay after the successful data update before the prior backup tables are dropped by DROP_AFTER")
+	f.StringSliceVar(&priorBackupFlags.versionColumns, "prior-backup-version-columns", nil, "names of the version or timestamp columns in order of preference, e.g. version,updated_at")
+	f.StringSliceVar(&priorBackupFlags.sinkBrokers, "prior-backup-sink-brokers", nil, "Kafka bootstrap brokers receiving the before-images of the prior backups published to the sink topics, e.g. broker1:9092,broker2:9092")
+	f.BoolVar(&priorBackupFlags.sinkTLS, "prior-backup-sink-tls", false, "connect to the prior backup sink brokers by TLS")
+	f.StringVar(&priorBackupFlags.sinkUsername, "prior-backup-sink-username", "", "SASL/PLAIN username of the prior backup sink brokers")
+	f.StringVar(&priorBackupFlags.sinkPassword, "prior-backup-sink-password", "", "SASL/PLAIN password of the prior backup sink brokers. Defaults to the PRIOR_BACKUP_SINK_PASSWORD environment variable")
+	f.StringVar(&priorBackupFlags.objectStoreURL, "prior-backup-object-store", "", "location of the prior backups kept in the object store, i.e. s3://bucket[/prefix], gs://bucket[/prefix] or azblob://container[/prefix]")
+	f.StringVar(&priorBackupFlags.objectStoreEndpoint, "prior-backup-object-store-endpoint", "", "endpoint of the S3-compatible object store such as MinIO")
+	f.StringVar(&priorBackupFlags.objectStoreRegion, "prior-backup-object-store-region", "", "region of the S3 bucket of the prior backups")
+	f.StringVar(&priorBackupFlags.objectStoreKeyID, "prior-backup-object-store-access-key-id", "", "access key ID of S3 or the HMAC key of GCS. The default AWS credential chain is used on S3 if not set")
+	f.StringVar(&priorBackupFlags.objectStoreSecret, "prior-backup-object-store-secret-access-key", "", "secret access key of S3 or the HMAC secret of GCS. Defaults to the PRIOR_BACKUP_OBJECT_STORE_SECRET_ACCESS_KEY environment variable")
+	f.StringVar(&priorBackupFlags.objectStoreConnection, "prior-backup-object-store-connection-string", "", "connection string of the Azure storage account. Defaults to the AZURE_STORAGE_CONNECTION_STRING environment variable")
+	f.BoolVar(&priorBackupFlags.captureExplainPlan, "data-update-capture-explain-plan", false, "capture the EXPLAIN plans of the data update statements before execution")
+}
+
+// setPriorBackupProfile sets the prior backup config of the profile from the command line flags.
+func setPriorBackupProfile(p *config.Profile) error {
+	engineConcurrency, err := getEngineConcurrency(priorBackupFlags.engineConcurrency)
+	if err != nil {
+		return errors.Wrapf(err, "invalid --prior-backup-engine-concurrency")
+	}
+	environmentRetentions, err := getEnvironmentRetentions(priorBackupFlags.environmentRetentions)
+	if err != nil {
+		return errors.Wrapf(err, "invalid --prior-backup-environment-retentions")
+	}
+	if priorBackupFlags.maximumUncertainty < 0 || priorBackupFlags.maximumUncertainty > 1 {
+		return errors.Errorf("invalid --prior-backup-maximum-uncertainty %v, must be from 0 to 1", priorBackupFlags.maximumUncertainty)
+	}
+	switch strings.ToUpper(priorBackupFlags.savepointPolicy) {
+	case "", "KEEP_BACKUP", "UNDO_BACKUP":
+	default:
+		return errors.Errorf("invalid --prior-backup-savepoint-policy %q, must be KEEP_BACKUP or UNDO_BACKUP", priorBackupFlags.savepointPolicy)
+	}
+
+	p.PriorBackupIsolationLevel = priorBackupFlags.isolationLevel
+	p.PriorBackupSkipTableComment = priorBackupFlags.skipTableComment
+	p.PriorBackupLockRows = priorBackupFlags.lockRows
+	p.PriorBackupBulkCopy = priorBackupFlags.bulkCopy
+	p.PriorBackupBulkCopyRows = priorBackupFlags.bulkCopyRows
+	p.PriorBackupPerIssueDatabase = priorBackupFlags.perIssueDatabase
+	p.PriorBackupReplicateTablespace = priorBackupFlags.replicateTablespace
+	p.PriorBackupDropOrphans = priorBackupFlags.dropOrphans
+	p.PriorBackupOrderByDependency = priorBackupFlags.orderByDependency
+	p.PriorBackupResourceGroup = priorBackupFlags.resourceGroup
+	p.PriorBackupReducedDurability = priorBackupFlags.reducedDurability
+	p.PriorBackupImmutableTable = priorBackupFlags.immutableTable
+	p.PriorBackupSmallTableRows = priorBackupFlags.smallTableRows
+	p.PriorBackupSchemaSyncDelay = priorBackupFlags.schemaSyncDelay
+	p.PriorBackupSurrogateKey = priorBackupFlags.surrogateKey
+	p.PriorBackupTimeout = priorBackupFlags.timeout
+	p.PriorBackupReadTimeout = priorBackupFlags.readTimeout
+	p.PriorBackupWriteTimeout = priorBackupFlags.writeTimeout
+	p.PriorBackupConsistentSnapshot = priorBackupFlags.consistentSnapshot
+	p.PriorBackupExportSnapshot = priorBackupFlags.exportSnapshot
+	p.PriorBackupSessionRole = priorBackupFlags.sessionRole
+	p.PriorBackupPartitionRows = priorBackupFlags.partitionRows
+	p.PriorBackupDatabaseSnapshotRows = priorBackupFlags.databaseSnapshotRows
+	p.PriorBackupAnalyzeTable = priorBackupFlags.analyzeTable
+	p.PriorBackupEngineConcurrency = engineConcurrency
+	p.PriorBackupMaximumUncertainty = priorBackupFlags.maximumUncertainty
+	p.PriorBackupFailOnUncertainty = priorBackupFlags.failOnUncertainty
+	p.PriorBackupNamespaceLabel = priorBackupFlags.namespaceLabel
+	p.PriorBackupSizeAnomalyFactor = priorBackupFlags.sizeAnomalyFactor
+	p.PriorBackupAfterImage = priorBackupFlags.afterImage
+	p.PriorBackupArchive = priorBackupFlags.archive
+	p.PriorBackupBudgetPolicy = priorBackupFlags.budgetPolicy
+	p.PriorBackupVerifyDrivers = priorBackupFlags.verifyDrivers
+	p.PriorBackupRetention = priorBackupFlags.retention
+	p.PriorBackupEnvironmentRetentions = environmentRetentions
+	p.PriorBackupAsync = priorBackupFlags.async
+	p.PriorBackupMaxGap = priorBackupFlags.maxGap
+	p.PriorBackupVerifyCoverage = priorBackupFlags.verifyCoverage
+	p.PriorBackupConsolidateComments = priorBackupFlags.consolidateComments
+	p.PriorBackupNodeTag = priorBackupFlags.nodeTag
+	p.PriorBackupFailOnVersionGap = priorBackupFlags.failOnVersionGap
+	p.PriorBackupMaintenance = priorBackupFlags.maintenance
+	p.PriorBackupSavepointPolicy = priorBackupFlags.savepointPolicy
+	p.PriorBackupPostSuccessPolicy = priorBackupFlags.postSuccessPolicy
+	p.PriorBackupPostSuccessDropAfter = priorBackupFlags.postSuccessDropAfter
+	p.PriorBackupVersionColumns = priorBackupFlags.versionColumns
+	p.PriorBackupSinkBrokers = priorBackupFlags.sinkBrokers
+	p.PriorBackupSinkTLS = priorBackupFlags.sinkTLS
+	p.PriorBackupSinkUsername = priorBackupFlags.sinkUsername
+	p.PriorBackupSinkPassword = priorBackupFlags.sinkPassword
+	p.PriorBackupObjectStoreURL = priorBackupFlags.objectStoreURL
+	p.PriorBackupObjectStoreEndpoint = priorBackupFlags.objectStoreEndpoint
+	p.PriorBackupObjectStoreRegion = priorBackupFlags.objectStoreRegion
+	p.PriorBackupObjectStoreAccessKeyID = priorBackupFlags.objectStoreKeyID
+	p.PriorBackupObjectStoreSecretAccessKey = priorBackupFlags.objectStoreSecret
+	p.PriorBackupObjectStoreConnectionString = priorBackupFlags.objectStoreConnection
+	p.DataUpdateCaptureExplainPlan = priorBackupFlags.captureExplainPlan
+	// The secrets are read from the environment variables if not set, so that they stay out of the process list and the usage.
+	if p.PriorBackupSinkPassword == "" {
+		p.PriorBackupSinkPassword = os.Getenv("PRIOR_BACKUP_SINK_PASSWORD")
+	}
+	if p.PriorBackupObjectStoreSecretAccessKey == "" {
+		p.PriorBackupObjectStoreSecretAccessKey = os.Getenv("PRIOR_BACKUP_OBJECT_STORE_SECRET_ACCESS_KEY")
+	}
+	if p.PriorBackupObjectStoreConnectionString == "" {
+		p.PriorBackupObjectStoreConnectionString = os.Getenv("AZURE_STORAGE_CONNECTION_STRING")
+	}
+	return nil
+}
+
+// getEngineConcurrency parses the concurrencies keyed by the engine names such as MYSQL.
+func getEngineConcurrency(values map[string]int) (map[storepb.Engine]int, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	concurrency := make(map[storepb.Engine]int)
+	for name, limit := range values {
+		engine, ok := storepb.Engine_value[strings.ToUpper(name)]
+		if !ok || engine == int32(storepb.Engine_ENGINE_UNSPECIFIED) {
+			return nil, errors.Errorf("unknown engine %q", name)
+		}
+		if limit <= 0 {
+			return nil, errors.Errorf("concurrency of engine %q must be positive", name)
+		}
+		concurrency[storepb.Engine(engine)] = limit
+	}
+	return concurrency, nil
+}
+
+// getEnvironmentRetentions parses the retentions keyed by the environment IDs.
+func getEnvironmentRetentions(values map[string]string) (map[string]time.Duration, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	retentions := make(map[string]time.Duration)
+	for environment, value := range values {
+		retention, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid retention of environment %q", environment)
+		}
+		if retention <= 0 {
+			return nil, errors.Errorf("retention of environment %q must be positive", environment)
+		}
+		retentions[environment] = retention
+	}
+	return retentions, nil
+}
@@ -124,6 +124,7 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&flags.lsp, "lsp", true, "whether to enable lsp in SQL Editor")
 	rootCmd.PersistentFlags().BoolVar(&flags.disableMetric, "disable-metric", false, "disable the metric collector")
 	rootCmd.PersistentFlags().BoolVar(&flags.disableSample, "disable-sample", false, "disable the sample instance")
+	initPriorBackupFlags()
 }
 
 // -----------------------------------Command Line Config END--------------------------------------
@@ -191,6 +192,10 @@ func start() {
 	}
 
 	profile := activeProfile(flags.dataDir)
+	if err := setPriorBackupProfile(profile); err != nil {
+		slog.Error(err.Error())
+		return
+	}
 
 	// The ideal bootstrap order is:
 	// 1. Connect to the metadb
@@ -0,0 +1,500 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+// PriorBackupDetailDiff is the difference between two prior backup details of the same data change,
+// e.g. the backups planned for a re-planned statement against the backups of a prior run.
+// The items are matched by their source tables because the backup table names change between runs.
+type PriorBackupDetailDiff struct {
+	// Added are the items only in the new detail.
+	Added []*storepb.PriorBackupDetail_Item
+	// Removed are the items only in the old detail.
+	Removed []*storepb.PriorBackupDetail_Item
+	// PositionChanged are the items backing up the same source table from statements at different positions.
+	PositionChanged []*PriorBackupItemChange
+	// Unchanged are the new items backing up the same source tables from statements at the same positions.
+	Unchanged []*storepb.PriorBackupDetail_Item
+}
+
+// PriorBackupItemChange is a pair of matched prior backup items.
+type PriorBackupItemChange struct {
+	Old *storepb.PriorBackupDetail_Item
+	New *storepb.PriorBackupDetail_Item
+}
+
+// IsEmpty returns whether the prior backup details back up the same tables from the same positions.
+func (d *PriorBackupDetailDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.PositionChanged) == 0
+}
+
+// DiffPriorBackupDetail returns the difference from the old prior backup detail to the new one.
+// The items of a source table are matched in order, and the extra items are added or removed.
+func DiffPriorBackupDetail(oldDetail, newDetail *storepb.PriorBackupDetail) *PriorBackupDetailDiff {
+	oldItems := make(map[string][]*storepb.PriorBackupDetail_Item)
+	for _, item := range oldDetail.GetItems() {
+		key := getPriorBackupSourceKey(item)
+		oldItems[key] = append(oldItems[key], item)
+	}
+
+	diff := &PriorBackupDetailDiff{}
+	for _, item := range newDetail.GetItems() {
+		key := getPriorBackupSourceKey(item)
+		candidates := oldItems[key]
+		if len(candidates) == 0 {
+			diff.Added = append(diff.Added, item)
+			continue
+		}
+		old := candidates[0]
+		oldItems[key] = candidates[1:]
+		if proto.Equal(old.GetStartPosition(), item.GetStartPosition()) && proto.Equal(old.GetEndPosition(), item.GetEndPosition()) {
+			diff.Unchanged = append(diff.Unchanged, item)
+		} else {
+			diff.PositionChanged = append(diff.PositionChanged, &PriorBackupItemChange{Old: old, New: item})
+		}
+	}
+	// Keep the removed items in the order of the old detail.
+	for _, item := range oldDetail.GetItems() {
+		key := getPriorBackupSourceKey(item)
+		for _, remaining := range oldItems[key] {
+			if remaining == item {
+				diff.Removed = append(diff.Removed, item)
+			}
+		}
+	}
+	return diff
+}
+
+// SelectPriorBackupItems returns the items of the prior backup detail backing up the selected source tables in the order of the detail,
+// so that the restores can restore a subset of the backed up tables. No selected tables selects all the items.
+// It returns an error if any selected table is not backed up by the detail.
+func SelectPriorBackupItems(detail *storepb.PriorBackupDetail, tables []*storepb.PriorBackupDetail_Item_Table) ([]*storepb.PriorBackupDetail_Item, error) {
+	if len(tables) == 0 {
+		return detail.GetItems(), nil
+	}
+	selected := make(map[string]bool)
+	for _, table := range tables {
+		selected[getPriorBackupTableKey(table)] = true
+	}
+	found := make(map[string]bool)
+	var items []*storepb.PriorBackupDetail_Item
+	for _, item := range detail.GetItems() {
+		key := getPriorBackupSourceKey(item)
+		if selected[key] {
+			found[key] = true
+			items = append(items, item)
+		}
+	}
+	for _, table := range tables {
+		if !found[getPriorBackupTableKey(table)] {
+			return nil, errors.Errorf("table %q of database %q is not backed up", table.GetTable(), table.GetDatabase())
+		}
+	}
+	return items, nil
+}
+
+// GroupPriorBackupParts groups the items of the prior backup detail into the backups of the source tables in order,
+// so that the restores restore all the parts of a backup split into multiple backup tables together.
+// The items not split are in groups of their own. It returns an error if the ranges of the parts don't cover all the rows.
+func GroupPriorBackupParts(items []*storepb.PriorBackupDetail_Item) ([][]*storepb.PriorBackupDetail_Item, error) {
+	var groups [][]*storepb.PriorBackupDetail_Item
+	for i := 0; i < len(items); {
+		item := items[i]
+		if item.GetRange() == nil {
+			groups = append(groups, []*storepb.PriorBackupDetail_Item{item})
+			i++
+			continue
+		}
+		if item.GetRange().Start != nil {
+			return nil, errors.Errorf("the first part of the backup of table %q is not unbounded", item.GetSourceTable().GetTable())
+		}
+		group := []*storepb.PriorBackupDetail_Item{item}
+		i++
+		for item.GetRange().End != nil {
+			if i >= len(items) {
+				return nil, errors.Errorf("the last part of the backup of table %q is missing", item.GetSourceTable().GetTable())
+			}
+			next := items[i]
+			if next.GetRange() == nil || getPriorBackupSourceKey(next) != getPriorBackupSourceKey(item) || !proto.Equal(next.GetStartPosition(), item.GetStartPosition()) {
+				return nil, errors.Errorf("the last part of the backup of table %q is missing", item.GetSourceTable().GetTable())
+			}
+			if next.GetRange().Start == nil || next.GetRange().GetStart() != item.GetRange().GetEnd() {
+				return nil, errors.Errorf("the parts of the backup of table %q are not contiguous at %q", item.GetSourceTable().GetTable(), item.GetRange().GetEnd())
+			}
+			group = append(group, next)
+			item = next
+			i++
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// GetPriorBackupSchemaDriftWarning returns the warning if the current schema version of the database differs from the one
+// captured at backup time, in which case the backup tables may be incompatible with the current tables on restore.
+// It returns empty if either version is unknown.
+func GetPriorBackupSchemaDriftWarning(detail *storepb.PriorBackupDetail, currentVersion string) string {
+	backupVersion := strings.TrimSpace(detail.GetSchemaVersion())
+	currentVersion = strings.TrimSpace(currentVersion)
+	if backupVersion == "" || currentVersion == "" || backupVersion == currentVersion {
+		return ""
+	}
+	return fmt.Sprintf("the schema version changed from %q at backup time to %q, the backup tables may be incompatible with the current tables", backupVersion, currentVersion)
+}
+
+// GetPriorBackupCheckConstraintWarnings returns the warnings about the current check constraints of the source table of the item
+// which were added or changed since the backup, since they may reject the backed up rows on restore. The constraints are compared
+// by their names and expressions. If strict, it returns an error instead of the warnings.
+func GetPriorBackupCheckConstraintWarnings(item *storepb.PriorBackupDetail_Item, current []*storepb.CheckConstraintMetadata, strict bool) ([]string, error) {
+	captured := make(map[string]string)
+	for _, constraint := range item.GetCheckConstraints() {
+		captured[constraint.GetName()] = strings.TrimSpace(constraint.GetExpression())
+	}
+	var warnings []string
+	for _, constraint := range current {
+		expression, ok := captured[constraint.GetName()]
+		switch {
+		case !ok:
+			warnings = append(warnings, fmt.Sprintf("check constraint %q %s of table %q was added after the backup, the backed up rows may violate it", constraint.GetName(), constraint.GetExpression(), item.GetSourceTable().GetTable()))
+		case expression != strings.TrimSpace(constraint.GetExpression()):
+			warnings = append(warnings, fmt.Sprintf("check constraint %q of table %q changed from %s to %s after the backup, the backed up rows may violate it", constraint.GetName(), item.GetSourceTable().GetTable(), expression, constraint.GetExpression()))
+		}
+	}
+	if strict && len(warnings) > 0 {
+		return nil, errors.Errorf("the restore may violate the check constraints: %s", strings.Join(warnings, "; "))
+	}
+	return warnings, nil
+}
+
+// PriorBackupExportVersion is the version of the portable JSON representation of the prior backup detail.
+// It's bumped on the incompatible changes of the representation, while the fields added compatibly keep the version.
+const PriorBackupExportVersion = 1
+
+// PriorBackupExport is the portable JSON representation of the prior backup detail for the third-party tooling.
+// It's independent of the internal proto, so that the tooling is stable across the changes of the proto.
+type PriorBackupExport struct {
+	// Version is the version of the representation, i.e. PriorBackupExportVersion.
+	Version int `json:"version"`
+	// Engine is the engine of the backed up database, e.g. POSTGRES.
+	Engine string `json:"engine"`
+	// Stage is the stage of the backup. Format: projects/{project}/rollouts/{rollout}/stages/{stage}.
+	Stage          string `json:"stage,omitempty"`
+	Principal      string `json:"principal,omitempty"`
+	SchemaVersion  string `json:"schemaVersion,omitempty"`
+	IsolationLevel string `json:"isolationLevel,omitempty"`
+	LogPosition    string `json:"logPosition,omitempty"`
+	Namespace      string `json:"namespace,omitempty"`
+	// SampleRate is one in how many affected rows are backed up if it's non-zero.
+	SampleRate int32 `json:"sampleRate,omitempty"`
+	// SampleLimit is the number of the first affected rows ordered by the primary key backed up if it's non-zero,
+	// or the last ones if SampleFromLast.
+	SampleLimit    int32                    `json:"sampleLimit,omitempty"`
+	SampleFromLast bool                     `json:"sampleFromLast,omitempty"`
+	TimedOut       bool                     `json:"timedOut,omitempty"`
+	Incomplete     bool                     `json:"incomplete,omitempty"`
+	Items          []*PriorBackupExportItem `json:"items"`
+}
+
+// PriorBackupExportItem is a backup table in the portable JSON representation of the prior backup detail.
+type PriorBackupExportItem struct {
+	Source *PriorBackupExportTable `json:"source"`
+	Target *PriorBackupExportTable `json:"target"`
+	// Start and End are the positions of the statement backed up in the sheet.
+	Start *PriorBackupExportPosition `json:"start,omitempty"`
+	End   *PriorBackupExportPosition `json:"end,omitempty"`
+	// Strategy is how the rows are copied into the backup table, i.e. STATEMENT, BULK_COPY or DEFERRED.
+	Strategy         string   `json:"strategy,omitempty"`
+	RowCount         *int64   `json:"rowCount,omitempty"`
+	Lightweight      bool     `json:"lightweight,omitempty"`
+	ExcludedColumns  []string `json:"excludedColumns,omitempty"`
+	RestoreStatement string   `json:"restoreStatement,omitempty"`
+	// VersionColumn is the version or timestamp column of the source table checked by the restore statement.
+	VersionColumn string `json:"versionColumn,omitempty"`
+}
+
+// PriorBackupExportTable is a table in the portable JSON representation of the prior backup detail.
+type PriorBackupExportTable struct {
+	// Database is the database of the table. Format: instances/{instance}/databases/{database}.
+	Database string `json:"database"`
+	Schema   string `json:"schema,omitempty"`
+	Table    string `json:"table"`
+}
+
+// PriorBackupExportPosition is a position in the portable JSON representation of the prior backup detail.
+type PriorBackupExportPosition struct {
+	Line   int32 `json:"line"`
+	Column int32 `json:"column"`
+}
+
+// ExportPriorBackupDetail returns the portable JSON representation of the prior backup detail of the database engine.
+// The fields only used internally, e.g. the encryption key and the snapshots, are not exported.
+func ExportPriorBackupDetail(engine storepb.Engine, detail *storepb.PriorBackupDetail) ([]byte, error) {
+	export := &PriorBackupExport{
+		Version:        PriorBackupExportVersion,
+		Engine:         engine.String(),
+		Stage:          detail.GetStage(),
+		Principal:      detail.GetPrincipal(),
+		SchemaVersion:  detail.GetSchemaVersion(),
+		IsolationLevel: detail.GetIsolationLevel(),
+		LogPosition:    detail.GetLogPosition(),
+		Namespace:      detail.GetNamespace(),
+		SampleRate:     detail.GetSampleRate(),
+		SampleLimit:    detail.GetSampleLimit(),
+		SampleFromLast: detail.GetSampleFromLast(),
+		TimedOut:       detail.GetTimedOut(),
+		Incomplete:     detail.GetIncomplete(),
+		Items:          []*PriorBackupExportItem{},
+	}
+	for _, item := range detail.GetItems() {
+		exportItem := &PriorBackupExportItem{
+			Source:           exportPriorBackupTable(item.GetSourceTable()),
+			Target:           exportPriorBackupTable(item.GetTargetTable()),
+			Start:            exportPriorBackupPosition(item.GetStartPosition()),
+			End:              exportPriorBackupPosition(item.GetEndPosition()),
+			RowCount:         item.RowCount,
+			Lightweight:      item.GetLightweight(),
+			ExcludedColumns:  item.GetExcludedColumns(),
+			RestoreStatement: item.GetRestoreStatement(),
+			VersionColumn:    item.GetVersionColumn(),
+		}
+		if item.GetStrategy() != storepb.PriorBackupDetail_Item_STRATEGY_UNSPECIFIED {
+			exportItem.Strategy = item.GetStrategy().String()
+		}
+		export.Items = append(export.Items, exportItem)
+	}
+	data, err := json.Marshal(export)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal prior backup detail")
+	}
+	return data, nil
+}
+
+// ImportPriorBackupDetail returns the database engine and the prior backup detail of the portable JSON representation.
+// It returns an error if the version of the representation is not supported.
+func ImportPriorBackupDetail(data []byte) (storepb.Engine, *storepb.PriorBackupDetail, error) {
+	export := &PriorBackupExport{}
+	if err := json.Unmarshal(data, export); err != nil {
+		return storepb.Engine_ENGINE_UNSPECIFIED, nil, errors.Wrap(err, "failed to unmarshal prior backup detail")
+	}
+	if export.Version < 1 || export.Version > PriorBackupExportVersion {
+		return storepb.Engine_ENGINE_UNSPECIFIED, nil, errors.Errorf("unsupported prior backup detail version %d, the supported version is %d", export.Version, PriorBackupExportVersion)
+	}
+	engine, ok := storepb.Engine_value[export.Engine]
+	if !ok {
+		return storepb.Engine_ENGINE_UNSPECIFIED, nil, errors.Errorf("unsupported engine %q", export.Engine)
+	}
+	detail := &storepb.PriorBackupDetail{
+		Stage:          export.Stage,
+		Principal:      export.Principal,
+		SchemaVersion:  export.SchemaVersion,
+		IsolationLevel: export.IsolationLevel,
+		LogPosition:    export.LogPosition,
+		Namespace:      export.Namespace,
+		SampleRate:     export.SampleRate,
+		SampleLimit:    export.SampleLimit,
+		SampleFromLast: export.SampleFromLast,
+		TimedOut:       export.TimedOut,
+		Incomplete:     export.Incomplete,
+	}
+	for _, exportItem := range export.Items {
+		item := &storepb.PriorBackupDetail_Item{
+			SourceTable:      importPriorBackupTable(exportItem.Source),
+			TargetTable:      importPriorBackupTable(exportItem.Target),
+			StartPosition:    importPriorBackupPosition(exportItem.Start),
+			EndPosition:      importPriorBackupPosition(exportItem.End),
+			RowCount:         exportItem.RowCount,
+			Lightweight:      exportItem.Lightweight,
+			ExcludedColumns:  exportItem.ExcludedColumns,
+			RestoreStatement: exportItem.RestoreStatement,
+			VersionColumn:    exportItem.VersionColumn,
+		}
+		if exportItem.Strategy != "" {
+			strategy, ok := storepb.PriorBackupDetail_Item_Strategy_value[exportItem.Strategy]
+			if !ok {
+				return storepb.Engine_ENGINE_UNSPECIFIED, nil, errors.Errorf("unsupported backup strategy %q", exportItem.Strategy)
+			}
+			item.Strategy = storepb.PriorBackupDetail_Item_Strategy(strategy)
+		}
+		detail.Items = append(detail.Items, item)
+	}
+	return storepb.Engine(engine), detail, nil
+}
+
+func exportPriorBackupTable(table *storepb.PriorBackupDetail_Item_Table) *PriorBackupExportTable {
+	if table == nil {
+		return nil
+	}
+	return &PriorBackupExportTable{Database: table.GetDatabase(), Schema: table.GetSchema(), Table: table.GetTable()}
+}
+
+func importPriorBackupTable(table *PriorBackupExportTable) *storepb.PriorBackupDetail_Item_Table {
+	if table == nil {
+		return nil
+	}
+	return &storepb.PriorBackupDetail_Item_Table{Database: table.Database, Schema: table.Schema, Table: table.Table}
+}
+
+func exportPriorBackupPosition(position *storepb.Position) *PriorBackupExportPosition {
+	if position == nil {
+		return nil
+	}
+	return &PriorBackupExportPosition{Line: position.GetLine(), Column: position.GetColumn()}
+}
+
+func importPriorBackupPosition(position *PriorBackupExportPosition) *storepb.Position {
+	if position == nil {
+		return nil
+	}
+	return &storepb.Position{Line: position.Line, Column: position.Column}
+}
+
+// PriorBackupObjectStore is the external object store such as S3 keeping the versioned prior backups.
+type PriorBackupObjectStore interface {
+	// PutObject writes the object under the key.
+	PutObject(ctx context.Context, key string, data []byte) error
+	// ListObjects returns the keys of the objects with the prefix.
+	ListObjects(ctx context.Context, prefix string) ([]string, error)
+	// GetObject returns the object under the key.
+	GetObject(ctx context.Context, key string) ([]byte, error)
+	// UploadObject writes the object under the key from the body, without holding the whole object in memory.
+	UploadObject(ctx context.Context, key string, body io.Reader) error
+	// DownloadObject returns the reader of the object under the key. The caller must close it.
+	DownloadObject(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// priorBackupObjectTimeLayout is the layout of the backup time in the object keys.
+// It has a fixed width in UTC so that the keys of a task sort in the order of the backup time.
+const priorBackupObjectTimeLayout = "20060102T150405.000000000Z"
+
+// PriorBackupVersion is a version of the prior backups of a task in the object store.
+type PriorBackupVersion struct {
+	IssueUID int
+	TaskUID  int
+	// Time is the backup time of the version.
+	Time time.Time
+	// Key is the key of the object holding the version.
+	Key string
+}
+
+// GetPriorBackupObjectKey returns the key of the prior backup version of the task taken at the backup time.
+// Format: prior-backups/issues/{issue}/tasks/{task}/{time}.jsonl, where time is the UTC backup time in nanoseconds, e.g. 20240102T150405.000000000Z.
+func GetPriorBackupObjectKey(issueUID, taskUID int, backupTime time.Time) string {
+	return fmt.Sprintf("%s%s.jsonl", getPriorBackupObjectPrefix(issueUID, taskUID), backupTime.UTC().Format(priorBackupObjectTimeLayout))
+}
+
+// GetPriorBackupExportPrefix returns the key prefix of the files of the rows of the task exported to the object store at the backup time.
+// Format: prior-backup-exports/issues/{issue}/tasks/{task}/{time}/, where time is in the same layout as the prior backup versions.
+func GetPriorBackupExportPrefix(issueUID, taskUID int, backupTime time.Time) string {
+	return fmt.Sprintf("prior-backup-exports/issues/%d/tasks/%d/%s/", issueUID, taskUID, backupTime.UTC().Format(priorBackupObjectTimeLayout))
+}
+
+// ListPriorBackupVersions returns the prior backup versions of the task in the object store ordered by the backup time.
+// The objects under the prefix of the task with unexpected keys are skipped.
+func ListPriorBackupVersions(ctx context.Context, objectStore PriorBackupObjectStore, issueUID, taskUID int) ([]*PriorBackupVersion, error) {
+	prefix := getPriorBackupObjectPrefix(issueUID, taskUID)
+	keys, err := objectStore.ListObjects(ctx, prefix)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list prior backups with prefix %q", prefix)
+	}
+	var versions []*PriorBackupVersion
+	for _, key := range keys {
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		name, ok = strings.CutSuffix(name, ".jsonl")
+		if !ok {
+			continue
+		}
+		backupTime, err := time.Parse(priorBackupObjectTimeLayout, name)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, &PriorBackupVersion{
+			IssueUID: issueUID,
+			TaskUID:  taskUID,
+			Time:     backupTime,
+			Key:      key,
+		})
+	}
+	slices.SortFunc(versions, func(a, b *PriorBackupVersion) int {
+		return a.Time.Compare(b.Time)
+	})
+	return versions, nil
+}
+
+// SelectPriorBackupVersion returns the latest version backed up at or before the point in time, or nil if there is none.
+// The versions must be ordered by the backup time.
+func SelectPriorBackupVersion(versions []*PriorBackupVersion, pointInTime time.Time) *PriorBackupVersion {
+	var selected *PriorBackupVersion
+	for _, version := range versions {
+		if version.Time.After(pointInTime) {
+			break
+		}
+		selected = version
+	}
+	return selected
+}
+
+// FetchPriorBackupVersion returns the before-images of the prior backup version, one JSON message per row.
+func FetchPriorBackupVersion(ctx context.Context, objectStore PriorBackupObjectStore, version *PriorBackupVersion) ([][]byte, error) {
+	data, err := objectStore.GetObject(ctx, version.Key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get prior backup %q", version.Key)
+	}
+	var messages [][]byte
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		messages = append(messages, []byte(line))
+	}
+	return messages, nil
+}
+
+func getPriorBackupObjectPrefix(issueUID, taskUID int) string {
+	return fmt.Sprintf("prior-backups/issues/%d/tasks/%d/", issueUID, taskUID)
+}
+
+func getPriorBackupSourceKey(item *storepb.PriorBackupDetail_Item) string {
+	return getPriorBackupTableKey(item.GetSourceTable())
+}
+
+func getPriorBackupTableKey(table *storepb.PriorBackupDetail_Item_Table) string {
+	return fmt.Sprintf("%s/%s/%s", table.GetDatabase(), table.GetSchema(), table.GetTable())
+}
+
+// ParsePriorBackupBlackoutWindow parses the daily UTC window such as "22:00-02:00" into the offsets of its start and end from midnight.
+// The window ending before it starts spans midnight.
+func ParsePriorBackupBlackoutWindow(window string) (time.Duration, time.Duration, error) {
+	startText, endText, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, 0, errors.Errorf("invalid prior backup blackout window %q, expected HH:MM-HH:MM", window)
+	}
+	start, err := time.Parse("15:04", strings.TrimSpace(startText))
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "invalid start of prior backup blackout window %q", window)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(endText))
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "invalid end of prior backup blackout window %q", window)
+	}
+	startOffset := time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+	endOffset := time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute
+	if startOffset == endOffset {
+		return 0, 0, errors.Errorf("invalid prior backup blackout window %q, the start equals the end", window)
+	}
+	return startOffset, endOffset, nil
+}
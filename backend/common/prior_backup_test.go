@@ -0,0 +1,314 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func TestDiffPriorBackupDetail(t *testing.T) {
+	a := require.New(t)
+	newItem := func(table, target string, line int32) *storepb.PriorBackupDetail_Item {
+		return &storepb.PriorBackupDetail_Item{
+			SourceTable:   &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: table},
+			TargetTable:   &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: target},
+			StartPosition: &storepb.Position{Line: line},
+			EndPosition:   &storepb.Position{Line: line, Column: 30},
+		}
+	}
+
+	oldDetail := &storepb.PriorBackupDetail{
+		Items: []*storepb.PriorBackupDetail_Item{
+			newItem("t1", "_20240101000000_0_t1", 1),
+			newItem("t2", "_20240101000000_1_t2", 2),
+			newItem("t3", "_20240101000000_2_t3", 3),
+		},
+	}
+	newDetail := &storepb.PriorBackupDetail{
+		Items: []*storepb.PriorBackupDetail_Item{
+			newItem("t1", "_20240102000000_0_t1", 1),
+			newItem("t3", "_20240102000000_1_t3", 2),
+			newItem("t4", "_20240102000000_2_t4", 3),
+		},
+	}
+
+	diff := DiffPriorBackupDetail(oldDetail, newDetail)
+	a.False(diff.IsEmpty())
+	a.Equal([]*storepb.PriorBackupDetail_Item{newDetail.Items[0]}, diff.Unchanged)
+	a.Equal([]*storepb.PriorBackupDetail_Item{newDetail.Items[2]}, diff.Added)
+	a.Equal([]*storepb.PriorBackupDetail_Item{oldDetail.Items[1]}, diff.Removed)
+	a.Equal([]*PriorBackupItemChange{{Old: oldDetail.Items[2], New: newDetail.Items[1]}}, diff.PositionChanged)
+
+	diff = DiffPriorBackupDetail(oldDetail, oldDetail)
+	a.True(diff.IsEmpty())
+	a.Len(diff.Unchanged, 3)
+
+	// The prior run has no backup.
+	diff = DiffPriorBackupDetail(nil, newDetail)
+	a.Len(diff.Added, 3)
+	a.Empty(diff.Removed)
+}
+
+func TestSelectPriorBackupItems(t *testing.T) {
+	a := require.New(t)
+	newItem := func(table, target string) *storepb.PriorBackupDetail_Item {
+		return &storepb.PriorBackupDetail_Item{
+			SourceTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Schema: "public", Table: table},
+			TargetTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Schema: "bbdataarchive", Table: target},
+		}
+	}
+	detail := &storepb.PriorBackupDetail{
+		Items: []*storepb.PriorBackupDetail_Item{
+			newItem("t1", "_20240101000000_0_t1"),
+			newItem("t2", "_20240101000000_1_t2"),
+			newItem("t3", "_20240101000000_2_t3"),
+		},
+	}
+
+	// Restore only one of the three backed up tables.
+	items, err := SelectPriorBackupItems(detail, []*storepb.PriorBackupDetail_Item_Table{
+		{Database: "instances/i/databases/db", Schema: "public", Table: "t2"},
+	})
+	a.NoError(err)
+	a.Equal([]*storepb.PriorBackupDetail_Item{detail.Items[1]}, items)
+
+	// No selected tables restore all the tables.
+	items, err = SelectPriorBackupItems(detail, nil)
+	a.NoError(err)
+	a.Equal(detail.Items, items)
+
+	// The selected tables must be backed up.
+	_, err = SelectPriorBackupItems(detail, []*storepb.PriorBackupDetail_Item_Table{
+		{Database: "instances/i/databases/db", Schema: "public", Table: "t2"},
+		{Database: "instances/i/databases/db", Schema: "public", Table: "t4"},
+	})
+	a.ErrorContains(err, `table "t4" of database "instances/i/databases/db" is not backed up`)
+}
+
+func TestGetPriorBackupSchemaDriftWarning(t *testing.T) {
+	a := require.New(t)
+	detail := &storepb.PriorBackupDetail{SchemaVersion: "20240101000000"}
+
+	a.Empty(GetPriorBackupSchemaDriftWarning(detail, "20240101000000"))
+	a.Equal(`the schema version changed from "20240101000000" at backup time to "20240102000000", the backup tables may be incompatible with the current tables`, GetPriorBackupSchemaDriftWarning(detail, "20240102000000"))
+	// The unknown versions are not compared.
+	a.Empty(GetPriorBackupSchemaDriftWarning(detail, ""))
+	a.Empty(GetPriorBackupSchemaDriftWarning(&storepb.PriorBackupDetail{}, "20240102000000"))
+}
+
+func TestGetPriorBackupCheckConstraintWarnings(t *testing.T) {
+	a := require.New(t)
+	item := &storepb.PriorBackupDetail_Item{
+		SourceTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: "t"},
+		CheckConstraints: []*storepb.CheckConstraintMetadata{
+			{Name: "t_a_check", Expression: "(a > 0)"},
+		},
+	}
+
+	// The constraints are unchanged.
+	warnings, err := GetPriorBackupCheckConstraintWarnings(item, []*storepb.CheckConstraintMetadata{{Name: "t_a_check", Expression: "(a > 0)"}}, true)
+	a.NoError(err)
+	a.Empty(warnings)
+	// The dropped constraints don't reject the backed up rows.
+	warnings, err = GetPriorBackupCheckConstraintWarnings(item, nil, true)
+	a.NoError(err)
+	a.Empty(warnings)
+
+	// A check constraint is added and another is changed between the backup and the restore.
+	current := []*storepb.CheckConstraintMetadata{
+		{Name: "t_a_check", Expression: "(a > 10)"},
+		{Name: "t_b_check", Expression: "(b IS NOT NULL)"},
+	}
+	warnings, err = GetPriorBackupCheckConstraintWarnings(item, current, false)
+	a.NoError(err)
+	a.Equal([]string{
+		`check constraint "t_a_check" of table "t" changed from (a > 0) to (a > 10) after the backup, the backed up rows may violate it`,
+		`check constraint "t_b_check" (b IS NOT NULL) of table "t" was added after the backup, the backed up rows may violate it`,
+	}, warnings)
+	_, err = GetPriorBackupCheckConstraintWarnings(item, current, true)
+	a.ErrorContains(err, "the restore may violate the check constraints")
+}
+
+func TestExportPriorBackupDetail(t *testing.T) {
+	a := require.New(t)
+	rowCount := int64(3)
+	detail := &storepb.PriorBackupDetail{
+		Stage:          "projects/p/rollouts/1/stages/2",
+		Principal:      "users/alice@example.com",
+		SchemaVersion:  "0001",
+		IsolationLevel: "REPEATABLE READ",
+		LogPosition:    "0/16B3748",
+		SampleLimit:    10,
+		SampleFromLast: true,
+		Items: []*storepb.PriorBackupDetail_Item{
+			{
+				SourceTable:      &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Schema: "public", Table: "t"},
+				TargetTable:      &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Schema: "bbdataarchive", Table: "_0_t"},
+				StartPosition:    &storepb.Position{Line: 1},
+				EndPosition:      &storepb.Position{Line: 1, Column: 30},
+				Strategy:         storepb.PriorBackupDetail_Item_BULK_COPY,
+				RowCount:         &rowCount,
+				ExcludedColumns:  []string{"secret"},
+				RestoreStatement: `INSERT INTO "public"."t" SELECT * FROM "bbdataarchive"."_0_t";`,
+				VersionColumn:    "updated_at",
+			},
+			{
+				SourceTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Schema: "public", Table: "t2"},
+				TargetTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Schema: "bbdataarchive", Table: "_1_t2"},
+				Lightweight: true,
+			},
+		},
+	}
+
+	data, err := ExportPriorBackupDetail(storepb.Engine_POSTGRES, detail)
+	a.NoError(err)
+	a.Contains(string(data), `"version":1`)
+	a.Contains(string(data), `"engine":"POSTGRES"`)
+	engine, imported, err := ImportPriorBackupDetail(data)
+	a.NoError(err)
+	a.Equal(storepb.Engine_POSTGRES, engine)
+	a.True(proto.Equal(detail, imported), "imported detail %v", imported)
+	// The representation is stable across the round trips.
+	again, err := ExportPriorBackupDetail(engine, imported)
+	a.NoError(err)
+	a.Equal(string(data), string(again))
+
+	// The internal fields are not exported.
+	data, err = ExportPriorBackupDetail(storepb.Engine_MYSQL, &storepb.PriorBackupDetail{EncryptionKey: "projects/p/keys/k"})
+	a.NoError(err)
+	a.Equal(`{"version":1,"engine":"MYSQL","items":[]}`, string(data))
+
+	// The unknown fields of the compatible additions are ignored.
+	_, imported, err = ImportPriorBackupDetail([]byte(`{"version":1,"engine":"MYSQL","checksum":"abc","items":[]}`))
+	a.NoError(err)
+	a.Empty(imported.GetItems())
+
+	for _, data := range []string{
+		`{"engine":"MYSQL","items":[]}`,
+		`{"version":2,"engine":"MYSQL","items":[]}`,
+		`{"version":1,"engine":"UNKNOWN","items":[]}`,
+		`{"version":1,"engine":"MYSQL","items":[{"strategy":"COPY"}]}`,
+		`not json`,
+	} {
+		_, _, err := ImportPriorBackupDetail([]byte(data))
+		a.Error(err, data)
+	}
+}
+
+func TestGroupPriorBackupParts(t *testing.T) {
+	a := require.New(t)
+	bound := func(value string) *string {
+		return &value
+	}
+	newItem := func(table string, line int32, start, end *string) *storepb.PriorBackupDetail_Item {
+		item := &storepb.PriorBackupDetail_Item{
+			SourceTable:   &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: table},
+			StartPosition: &storepb.Position{Line: line},
+		}
+		if start != nil || end != nil {
+			item.Range = &storepb.PriorBackupDetail_Item_Range{Column: "id", Start: start, End: end}
+		}
+		return item
+	}
+
+	t1 := newItem("t1", 1, nil, nil)
+	p1 := newItem("t2", 2, nil, bound("100"))
+	p2 := newItem("t2", 2, bound("100"), bound("200"))
+	p3 := newItem("t2", 2, bound("200"), nil)
+	t3 := newItem("t3", 3, nil, nil)
+	groups, err := GroupPriorBackupParts([]*storepb.PriorBackupDetail_Item{t1, p1, p2, p3, t3})
+	a.NoError(err)
+	a.Equal([][]*storepb.PriorBackupDetail_Item{{t1}, {p1, p2, p3}, {t3}}, groups)
+
+	// The parts must cover all the rows.
+	_, err = GroupPriorBackupParts([]*storepb.PriorBackupDetail_Item{p1, p3})
+	a.Error(err)
+	_, err = GroupPriorBackupParts([]*storepb.PriorBackupDetail_Item{p1, p2})
+	a.Error(err)
+	_, err = GroupPriorBackupParts([]*storepb.PriorBackupDetail_Item{p2, p3})
+	a.Error(err)
+	_, err = GroupPriorBackupParts([]*storepb.PriorBackupDetail_Item{p1, t3})
+	a.Error(err)
+}
+
+// fakeObjectStore is an in-memory object store.
+type fakeObjectStore struct {
+	objects map[string][]byte
+}
+
+func (s *fakeObjectStore) PutObject(_ context.Context, key string, data []byte) error {
+	s.objects[key] = data
+	return nil
+}
+
+func (s *fakeObjectStore) ListObjects(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *fakeObjectStore) GetObject(_ context.Context, key string) ([]byte, error) {
+	return s.objects[key], nil
+}
+
+func (s *fakeObjectStore) UploadObject(_ context.Context, key string, body io.Reader) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	s.objects[key] = data
+	return nil
+}
+
+func (s *fakeObjectStore) DownloadObject(_ context.Context, key string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(s.objects[key])), nil
+}
+
+func TestPriorBackupVersions(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+
+	first := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	second := first.Add(90 * time.Minute)
+	a.Equal("prior-backups/issues/12/tasks/34/20240102T150405.000000000Z.jsonl", GetPriorBackupObjectKey(12, 34, first))
+	// The backup time is in UTC.
+	a.Equal(GetPriorBackupObjectKey(12, 34, first), GetPriorBackupObjectKey(12, 34, first.In(time.FixedZone("UTC+8", 8*60*60))))
+	a.Equal("prior-backup-exports/issues/12/tasks/34/20240102T150405.000000000Z/", GetPriorBackupExportPrefix(12, 34, first))
+
+	objectStore := &fakeObjectStore{objects: map[string][]byte{}}
+	a.NoError(objectStore.PutObject(ctx, GetPriorBackupObjectKey(12, 34, second), []byte("{\"id\":2}\n")))
+	a.NoError(objectStore.PutObject(ctx, GetPriorBackupObjectKey(12, 34, first), []byte("{\"id\":1}\n{\"id\":3}\n")))
+	// The versions of other tasks and the unexpected keys are not listed.
+	a.NoError(objectStore.PutObject(ctx, GetPriorBackupObjectKey(12, 345, first), nil))
+	a.NoError(objectStore.PutObject(ctx, "prior-backups/issues/12/tasks/34/manifest.json", nil))
+
+	versions, err := ListPriorBackupVersions(ctx, objectStore, 12, 34)
+	a.NoError(err)
+	a.Len(versions, 2)
+	a.True(first.Equal(versions[0].Time))
+	a.Equal(GetPriorBackupObjectKey(12, 34, first), versions[0].Key)
+	a.True(second.Equal(versions[1].Time))
+	a.Equal(12, versions[1].IssueUID)
+	a.Equal(34, versions[1].TaskUID)
+
+	a.Nil(SelectPriorBackupVersion(versions, first.Add(-time.Second)))
+	a.Equal(versions[0], SelectPriorBackupVersion(versions, first))
+	a.Equal(versions[0], SelectPriorBackupVersion(versions, second.Add(-time.Second)))
+	a.Equal(versions[1], SelectPriorBackupVersion(versions, second.Add(time.Hour)))
+
+	messages, err := FetchPriorBackupVersion(ctx, objectStore, versions[0])
+	a.NoError(err)
+	a.Equal([][]byte{[]byte(`{"id":1}`), []byte(`{"id":3}`)}, messages)
+}
@@ -620,6 +620,10 @@ func FormatIssue(projectID string, issueUID int) string {
 	return fmt.Sprintf("%s%s/%s%d", ProjectNamePrefix, projectID, IssueNamePrefix, issueUID)
 }
 
+func FormatStage(projectID string, pipelineUID, stageUID int) string {
+	return fmt.Sprintf("%s%s/%s%d/%s%d", ProjectNamePrefix, projectID, RolloutPrefix, pipelineUID, StagePrefix, stageUID)
+}
+
 func FormatTask(projectID string, pipelineUID, stageUID, taskUID int) string {
 	return fmt.Sprintf("%s%s/%s%d/%s%d/%s%d", ProjectNamePrefix, projectID, RolloutPrefix, pipelineUID, StagePrefix, stageUID, TaskPrefix, taskUID)
 }
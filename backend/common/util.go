@@ -53,6 +53,10 @@ var (
 		storepb.Engine_MSSQL:            true,
 		storepb.Engine_DYNAMODB:         true,
 	}
+	// PriorBackupExportEngines are the engines whose prior backups can be exported to the object storage.
+	PriorBackupExportEngines = map[storepb.Engine]bool{
+		storepb.Engine_POSTGRES: true,
+	}
 	StatementReportEngines = map[storepb.Engine]bool{
 		storepb.Engine_POSTGRES:         true,
 		storepb.Engine_MYSQL:            true,
@@ -7,6 +7,7 @@ import (
 
 	"github.com/bytebase/bytebase/backend/common"
 	api "github.com/bytebase/bytebase/backend/legacyapi"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
 )
 
 // Profile is the configuration to start main server.
@@ -46,6 +47,190 @@ type Profile struct {
 	AppRunnerInterval time.Duration
 	// BackupRunnerInterval is the interval for backup runner.
 	BackupRunnerInterval time.Duration
+	// PriorBackupIsolationLevel is the isolation level such as "READ COMMITTED" used by the prior backup statements of data update tasks.
+	// Empty means using a consistent snapshot on the engines supporting it.
+	PriorBackupIsolationLevel string
+	// PriorBackupSkipTableComment skips tagging the prior backup tables with the issue by table comments, which requires ALTER TABLE.
+	// The issue association is still recorded in the issue comments and the prior backup detail.
+	PriorBackupSkipTableComment bool
+	// PriorBackupLockRows runs the prior backup statements in the data update transaction with SELECT ... FOR UPDATE,
+	// so the backed up rows are exactly those the data update modifies. Only supported by Postgres.
+	// The affected rows stay locked from the backup until the data update commits, so concurrent writers of these rows
+	// wait longer and may hit lock timeouts, and deadlocks become possible if they lock the same rows in a different order.
+	PriorBackupLockRows bool
+	// PriorBackupBulkCopy copies the prior backup rows with the bulk copy facility of the engine such as Postgres COPY
+	// instead of INSERT ... SELECT. It falls back to INSERT ... SELECT on the engines without bulk copy support.
+	PriorBackupBulkCopy bool
+	// PriorBackupBulkCopyRows selects the bulk copy for the source tables estimated to have at least this many rows by the synced metadata,
+	// on the engines supporting it, and INSERT ... SELECT for the smaller ones. Zero disables the selection by size.
+	PriorBackupBulkCopyRows int64
+	// PriorBackupPerIssueDatabase keeps the prior backup tables of each issue in a dedicated backup database named by the issue,
+	// created on demand, so that the backups of an issue can be cleaned up together. The backup database is a schema on Postgres.
+	// Only supported by MySQL, TiDB and Postgres, and the other engines use the configured backup database.
+	PriorBackupPerIssueDatabase bool
+	// PriorBackupReplicateTablespace creates the prior backup tables in the tablespaces of the source tables on Oracle and Postgres.
+	// By default the backup tables are created in the default tablespace. The MySQL and TiDB backup tables always use
+	// the storage engines of the source tables because they are created by CREATE TABLE ... LIKE.
+	PriorBackupReplicateTablespace bool
+	// PriorBackupDropOrphans drops the orphaned prior backup tables found by the reconciler.
+	// The orphaned backup tables are left behind by crashes and are only reported by default.
+	PriorBackupDropOrphans bool
+	// PriorBackupOrderByDependency orders the prior backup statements by the foreign key dependencies of the source tables,
+	// the referenced tables first, so that the backups can be restored in a safe order.
+	PriorBackupOrderByDependency bool
+	// PriorBackupResourceGroup is the TiDB resource control group of the prior backup sessions,
+	// so that the heavy backup copies are rate-limited by the database instead of impacting the foreground traffic.
+	// It can be overridden by the task.
+	PriorBackupResourceGroup string
+	// PriorBackupReducedDurability creates the prior backup tables without the redo logging during the copy for speed, i.e. UNLOGGED on Postgres
+	// and NOLOGGING on Oracle, and converts them into the logged tables after the copy completes and before the data update runs.
+	// The Oracle backup tables loaded without logging cannot be recovered from the redo logs until the next database backup.
+	// It's ignored on the other engines, for the bulk copies and the deferred backups, and for the immutable backup tables.
+	PriorBackupReducedDurability bool
+	// PriorBackupImmutableTable creates the prior backup tables as Oracle immutable tables, so that the backed up rows
+	// cannot be modified and the backup tables cannot be dropped during the retention period. The post-success policies,
+	// the cleanups and the retention job keep the immutable backup tables until the retention period ends.
+	// It's ignored on the engines without immutable tables.
+	PriorBackupImmutableTable bool
+	// PriorBackupSmallTableRows backs up the tables with at most this many rows by the synced metadata by a lightweight path,
+	// which skips tagging the backup tables and commenting on the issue. Zero backs up all tables by the full path.
+	PriorBackupSmallTableRows int64
+	// PriorBackupSchemaSyncDelay is the grace delay before syncing the schema after the prior backup tables are created,
+	// so that the DDL settles on the clustered engines with delayed catalog propagation. With the delay, the sync is
+	// retried after the same delay until the backup tables are visible. Zero syncs immediately and once.
+	PriorBackupSchemaSyncDelay time.Duration
+	// PriorBackupSurrogateKey adds a surrogate key column to the prior backup tables on Oracle and Postgres,
+	// so that the restores can match the rows unambiguously even for the tables without primary keys or with mutable primary keys.
+	PriorBackupSurrogateKey bool
+	// PriorBackupTimeout is the overall deadline of the prior backup of a task, independent of the statement timeouts.
+	// The remaining backup statements are aborted when it's exceeded. Zero means no deadline.
+	PriorBackupTimeout time.Duration
+	// PriorBackupReadTimeout and PriorBackupWriteTimeout bound reading the rows from the source tables and writing them into
+	// the backup tables separately for each backup table, on the engines copying the rows in separate phases such as the Postgres bulk copy.
+	// The backup statements reading and writing in one piece, e.g. CREATE TABLE ... AS SELECT, are bounded by the sum of both if both are set.
+	// Zero doesn't bound the phase.
+	PriorBackupReadTimeout  time.Duration
+	PriorBackupWriteTimeout time.Duration
+	// PriorBackupConsistentSnapshot reads the source tables of the prior backup statements of a database in one consistent snapshot
+	// on MySQL and Postgres, so that the backup tables are mutually consistent despite the concurrent writes.
+	PriorBackupConsistentSnapshot bool
+	// PriorBackupExportSnapshot exports a consistent snapshot of the source tables on Postgres by pg_export_snapshot,
+	// and executes each prior backup statement in a short transaction importing the snapshot, so that the source tables are read
+	// consistently without one long transaction holding their locks. It takes precedence over PriorBackupConsistentSnapshot.
+	PriorBackupExportSnapshot bool
+	// PriorBackupSessionRole is the database role the prior backup statements run under for least privilege on Postgres and MSSQL.
+	// The role is reset after each backup statement. It can be overridden by the task.
+	PriorBackupSessionRole string
+	// PriorBackupPartitionRows splits the prior backup of a source table affecting more rows than this into multiple backup tables
+	// by ranges of the single-column primary key on Postgres, about this many rows each. Zero disables the split.
+	PriorBackupPartitionRows int64
+	// PriorBackupDatabaseSnapshotRows backs up the task database by the native snapshot of the whole database, e.g. the fast clone
+	// of the cloud databases, instead of copying the rows into the backup tables, if the source tables are estimated to have
+	// at least this many rows in total by the synced metadata and the database driver supports it. Zero disables the snapshot.
+	PriorBackupDatabaseSnapshotRows int64
+	// PriorBackupAnalyzeTable updates the optimizer statistics of the prior backup tables after they are created,
+	// so that the verification and restore queries on the large backup tables are planned well. It's skipped on the unsupported engines.
+	PriorBackupAnalyzeTable bool
+	// PriorBackupEngineConcurrency is the maximum number of databases of each engine backed up concurrently across the tasks,
+	// since the engines tolerate the concurrent backup DDLs and bulk copies differently. The engines not in the map are not limited.
+	PriorBackupEngineConcurrency map[storepb.Engine]int
+	// PriorBackupMaximumUncertainty is the maximum uncertainty from 0 to 1 that a prior backup statement selects exactly the rows
+	// affected by the DML. The more uncertain statements back up the whole source tables instead.
+	PriorBackupMaximumUncertainty float64
+	// PriorBackupFailOnUncertainty fails the prior backup instead of backing up the whole source tables
+	// if any statement is more uncertain than PriorBackupMaximumUncertainty.
+	PriorBackupFailOnUncertainty bool
+	// PriorBackupNamespaceLabel is the database label whose value namespaces the prior backup table names, falling back to the project ID,
+	// so that the tenants sharing a backup database don't clash. Empty disables the namespaces.
+	PriorBackupNamespaceLabel string
+	// PriorBackupSizeAnomalyFactor warns in the task run result if a prior backup table has more than this factor times
+	// or less than one over this factor of the median rows of the recent backups of the same source table. Zero disables the warnings.
+	PriorBackupSizeAnomalyFactor float64
+	// PriorBackupAfterImage also creates a companion table of each prior backup table of UPDATE with the new values of the backed up rows,
+	// computed by applying the SET clause of the UPDATE to a copy of the backup table, so that the reviewers can diff the changes.
+	// Only supported by Postgres, and skipped for the UPDATE joining other tables or the deferred backups.
+	PriorBackupAfterImage bool
+	// PriorBackupArchive also appends the rows of each prior backup table to the monthly-partitioned archive table of its source table
+	// in the backup database for the long-term retention, creating the archive table and the partition of the month if needed.
+	// The retention job drops the whole partitions past the retention. Only supported by Postgres and MySQL.
+	PriorBackupArchive bool
+	// PriorBackupBudgetPolicy is what happens to the prior backup estimated to run past the budget deadline of the task,
+	// either "WARN" to back up anyway with a warning in the prior backup detail, or "DEFER" to defer the data update task
+	// until the deadline is extended. Defaults to "WARN".
+	PriorBackupBudgetPolicy string
+	// PriorBackupVerifyDrivers verifies that the drivers of the prior backups are connected to the intended source and backup databases
+	// before backing up, so that a misconfiguration cannot write the backup tables into the wrong database.
+	PriorBackupVerifyDrivers bool
+	// PriorBackupRetention is the default retention of the prior backup tables, after which the reconciler drops them.
+	// It can be overridden by the project setting. Zero keeps the backup tables.
+	PriorBackupRetention time.Duration
+	// PriorBackupEnvironmentRetentions are the retentions of the prior backup tables keyed by the environment IDs, overriding the default
+	// retention for the backup tables of the environments. The retention of the project setting still takes precedence. Zero keeps the backup tables.
+	PriorBackupEnvironmentRetentions map[string]time.Duration
+	// PriorBackupAsync runs the prior backups in the background instead of blocking the task runner for the whole copy.
+	// The data update of the task run is gated until the backup completes, and fails if the required backup fails.
+	PriorBackupAsync bool
+	// PriorBackupMaxGap is the maximum time between the completion of the asynchronous prior backup and the data update gated by it.
+	// The backup completed longer ago is discarded and backed up again in the background, since the concurrent changes meanwhile
+	// make it inconsistent. The synchronous backups complete right before the data update. Zero disables the check.
+	PriorBackupMaxGap time.Duration
+	// PriorBackupVerifyCoverage verifies after the data update that the rows modified by the UPDATE statements are in the prior backup tables
+	// by the primary keys on Postgres, and warns about the missing rows in the task run result, e.g. because the backup predicates diverged.
+	PriorBackupVerifyCoverage bool
+	// PriorBackupConsolidateComments creates one issue comment listing all the prior backup tables of a database
+	// instead of one comment per table, so that the DML touching many tables doesn't flood the issue timeline.
+	PriorBackupConsolidateComments bool
+	// PriorBackupNodeTag is the node tag of the data sources the prior backups run on, e.g. a node dedicated for the backups,
+	// so that the backups don't load the nodes serving the traffic. The tagged node must accept the backup writes.
+	// The backups fail if the instance has no data source with the tag. Empty uses the admin data source.
+	PriorBackupNodeTag string
+	// PriorBackupFailOnVersionGap fails the prior backup if the backup database runs an older engine version lacking the known features
+	// of the source database version, e.g. a data type, instead of warning that the backup may fail or lose fidelity.
+	PriorBackupFailOnVersionGap bool
+	// PriorBackupMaintenance runs the maintenance of each source table before copying its prior backup, i.e. VACUUM on Postgres
+	// and OPTIMIZE TABLE on MySQL, so that the backup reads don't scan the bloat of the tables. It's expensive and lock-prone:
+	// the maintenance reads the whole source tables, and OPTIMIZE TABLE rebuilds them blocking the writes for a while.
+	// It's skipped on the other engines and for the deferred backups, and a failed maintenance doesn't fail the backup.
+	PriorBackupMaintenance bool
+	// PriorBackupSavepointPolicy runs the prior backup statements in the data update transaction followed by a savepoint on Postgres
+	// and MySQL with InnoDB, so that the backup is consistent with the data update. It's how a failed data update treats the backup:
+	// "KEEP_BACKUP" rolls back to the savepoint and commits the backup, and "UNDO_BACKUP" rolls back the backup with the data update.
+	// Empty backs up the data before the data update transaction.
+	PriorBackupSavepointPolicy string
+	// PriorBackupPostSuccessPolicy is what happens to the prior backup tables after the data update succeeds: "KEEP" keeps them
+	// for audits until their retentions expire, "DROP_AFTER" has the retention job drop them after PriorBackupPostSuccessDropAfter,
+	// and "DROP_IMMEDIATELY" drops them right away to save space. It can be overridden by the task. Empty keeps the backup tables.
+	PriorBackupPostSuccessPolicy string
+	// PriorBackupPostSuccessDropAfter is the delay after the successful data update before the backup tables are dropped by the "DROP_AFTER" policy.
+	// Zero uses the default delay of one day.
+	PriorBackupPostSuccessDropAfter time.Duration
+	// PriorBackupVersionColumns are the names of the version or timestamp columns such as "version" and "updated_at" in order of preference.
+	// The first one the source table has is captured with its prior backup, and the restore statements only overwrite the current rows
+	// whose versions haven't advanced past the backup, so that the newer changes are not clobbered. Empty disables the optimistic restores.
+	PriorBackupVersionColumns []string
+	// PriorBackupSinkBrokers are the Kafka bootstrap brokers receiving the before-images of the prior backups published to the sink topics.
+	// Empty means the backups cannot be published to the sinks.
+	PriorBackupSinkBrokers []string
+	// PriorBackupSinkTLS connects to the sink brokers by TLS.
+	PriorBackupSinkTLS bool
+	// PriorBackupSinkUsername and PriorBackupSinkPassword authenticate to the sink brokers by SASL/PLAIN if the username is set.
+	PriorBackupSinkUsername string
+	PriorBackupSinkPassword string
+	// PriorBackupObjectStoreURL is the location of the prior backups kept in the external object store, i.e. s3://{bucket}[/{prefix}],
+	// gs://{bucket}[/{prefix}] or azblob://{container}[/{prefix}]. Empty means the backups cannot be kept in the object store.
+	PriorBackupObjectStoreURL string
+	// PriorBackupObjectStoreEndpoint overrides the endpoint of the S3-compatible object stores such as MinIO.
+	PriorBackupObjectStoreEndpoint string
+	// PriorBackupObjectStoreRegion is the region of the S3 bucket.
+	PriorBackupObjectStoreRegion string
+	// PriorBackupObjectStoreAccessKeyID and PriorBackupObjectStoreSecretAccessKey are the static credentials of S3 or the HMAC keys of GCS.
+	// The default credential chain of AWS is used on S3 if they are not set.
+	PriorBackupObjectStoreAccessKeyID     string
+	PriorBackupObjectStoreSecretAccessKey string
+	// PriorBackupObjectStoreConnectionString is the connection string of the Azure storage account.
+	PriorBackupObjectStoreConnectionString string
+	// DataUpdateCaptureExplainPlan captures the EXPLAIN plans of the data update statements before execution for performance post-mortems.
+	DataUpdateCaptureExplainPlan bool
 
 	// Version is the bytebase's server version
 	Version string
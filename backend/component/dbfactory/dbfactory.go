@@ -53,6 +53,25 @@ func (d *DBFactory) GetAdminDatabaseDriver(ctx context.Context, instance *store.
 	return d.GetDataSourceDriver(ctx, instance, dataSource, databaseName, datashare, false /* readOnly */, connectionContext)
 }
 
+// GetNodeTagDatabaseDriver gets the database driver using the instance's data source connecting to a node tagged with the role,
+// e.g. a backup node. It fails if no data source is tagged with the role. The driver is not read-only, since the purposes such as the backups write on the node.
+// Upon successful return, caller must call driver.Close(). Otherwise, it will leak the database connection.
+func (d *DBFactory) GetNodeTagDatabaseDriver(ctx context.Context, instance *store.InstanceMessage, database *store.DatabaseMessage, tag string, connectionContext db.ConnectionContext) (db.Driver, error) {
+	dataSource := utils.DataSourceFromInstanceWithNodeTag(instance, tag)
+	if dataSource == nil {
+		return nil, common.Errorf(common.Internal, "data source with node tag %q not found for instance %q", tag, instance.Title)
+	}
+	databaseName := ""
+	if database != nil {
+		databaseName = database.DatabaseName
+	}
+	datashare := false
+	if database != nil && database.DataShare {
+		datashare = true
+	}
+	return d.GetDataSourceDriver(ctx, instance, dataSource, databaseName, datashare, false /* readOnly */, connectionContext)
+}
+
 // GetDataSourceDriver returns the database driver for a data source.
 func (d *DBFactory) GetDataSourceDriver(ctx context.Context, instance *store.InstanceMessage, dataSource *store.DataSourceMessage, databaseName string, datashare, readOnly bool, connectionContext db.ConnectionContext) (db.Driver, error) {
 	dbBinDir := ""
@@ -3,6 +3,7 @@ package state
 
 import (
 	"sync"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru/v2"
 
@@ -12,6 +13,13 @@ import (
 // defaultInstanceMaximumConnections is the maximum number of connections outstanding per instance by default.
 const defaultInstanceMaximumConnections = 10
 
+const (
+	// priorBackupFailureThreshold is the number of consecutive failures opening the circuit of a backup database.
+	priorBackupFailureThreshold = 3
+	// priorBackupCooldown is the period the backups to a backup database fast-fail after its circuit opens.
+	priorBackupCooldown = 10 * time.Minute
+)
+
 // State is the state for all in-memory states within the server.
 type State struct {
 	// InstanceSlowQuerySyncChan is the channel for synchronizing slow query logs for instances.
@@ -43,6 +51,16 @@ type State struct {
 	RunningPlanCheckRunsCancelFunc sync.Map // map[planCheckRunUID]context.CancelFunc
 	// InstanceOutstandingConnections is the maximum number of connections per instance.
 	InstanceOutstandingConnections *connectionLimiter
+	// PriorBackupCircuitBreaker fast-fails the prior backups to the backup databases failing repeatedly.
+	PriorBackupCircuitBreaker *circuitBreaker
+	// PriorBackupDeferredTaskRuns is the map from task run ID to the time until which the task run is deferred
+	// because its prior backup is in a blackout window.
+	PriorBackupDeferredTaskRuns sync.Map // map[taskRunID]time.Time
+	// PriorBackupAsyncTaskRuns is the map from task run ID to the asynchronous prior backup of the task run,
+	// whose data update is gated until the backup completes.
+	PriorBackupAsyncTaskRuns sync.Map // map[taskRunID]*taskrun.asyncBackup
+	// PriorBackupAsyncCancelFunc is the cancelFunc of the asynchronous prior backups of the task runs.
+	PriorBackupAsyncCancelFunc sync.Map // map[taskRunID]context.CancelFunc
 
 	// IssueExternalApprovalRelayCancelChan cancels the external approval from relay for issue issueUID.
 	IssueExternalApprovalRelayCancelChan chan int
@@ -66,6 +84,7 @@ func New() (*State, error) {
 	return &State{
 		InstanceSlowQuerySyncChan:            make(chan *InstanceSlowQuerySyncMessage, 100),
 		InstanceOutstandingConnections:       &connectionLimiter{connections: map[int]int{}},
+		PriorBackupCircuitBreaker:            newCircuitBreaker(priorBackupFailureThreshold, priorBackupCooldown),
 		IssueExternalApprovalRelayCancelChan: make(chan int, 1),
 		TaskSkippedOrDoneChan:                make(chan int, 1000),
 		PlanCheckTickleChan:                  make(chan int, 1000),
@@ -108,3 +127,53 @@ func (c *connectionLimiter) Decrement(instanceID int) {
 	defer c.Unlock()
 	c.connections[instanceID]--
 }
+
+// circuitBreaker fast-fails the operations on a key after consecutive failures until the cooldown passes.
+type circuitBreaker struct {
+	sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  map[string]int
+	openUntil map[string]time.Time
+}
+
+// newCircuitBreaker creates a circuit breaker opening after threshold consecutive failures for the cooldown.
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		failures:  map[string]int{},
+		openUntil: map[string]time.Time{},
+	}
+}
+
+// Allow returns whether the operation on the key is allowed at now.
+// If not, it returns the time until which the operations are rejected.
+// After the cooldown, one failure opens the circuit again until an operation succeeds.
+func (c *circuitBreaker) Allow(key string, now time.Time) (bool, time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	openUntil, ok := c.openUntil[key]
+	if ok && now.Before(openUntil) {
+		return false, openUntil
+	}
+	return true, time.Time{}
+}
+
+// RecordSuccess closes the circuit of the key.
+func (c *circuitBreaker) RecordSuccess(key string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.failures, key)
+	delete(c.openUntil, key)
+}
+
+// RecordFailure records a failure of the key at now and opens the circuit after consecutive failures.
+func (c *circuitBreaker) RecordFailure(key string, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.failures[key]++
+	if c.failures[key] >= c.threshold {
+		c.openUntil[key] = now.Add(c.cooldown)
+	}
+}
@@ -0,0 +1,45 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	a := require.New(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := newCircuitBreaker(3, time.Minute)
+	const key = "instances/prod/databases/bbdataarchive"
+
+	// The circuit stays closed before the consecutive failures reach the threshold.
+	breaker.RecordFailure(key, now)
+	breaker.RecordFailure(key, now)
+	allowed, _ := breaker.Allow(key, now)
+	a.True(allowed)
+
+	// The circuit opens and fast-fails during the cooldown.
+	breaker.RecordFailure(key, now)
+	allowed, until := breaker.Allow(key, now.Add(30*time.Second))
+	a.False(allowed)
+	a.Equal(now.Add(time.Minute), until)
+	// Other keys are not affected.
+	allowed, _ = breaker.Allow("instances/prod/databases/other", now)
+	a.True(allowed)
+
+	// A trial is allowed after the cooldown, and a failure opens the circuit again.
+	now = now.Add(time.Minute)
+	allowed, _ = breaker.Allow(key, now)
+	a.True(allowed)
+	breaker.RecordFailure(key, now)
+	allowed, _ = breaker.Allow(key, now)
+	a.False(allowed)
+
+	// A success after the cooldown recovers the circuit.
+	now = now.Add(time.Minute)
+	breaker.RecordSuccess(key)
+	breaker.RecordFailure(key, now)
+	allowed, _ = breaker.Allow(key, now)
+	a.True(allowed)
+}
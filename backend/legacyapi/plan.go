@@ -124,6 +124,10 @@ const (
 	FeatureDatabaseGrouping FeatureType = "bb.feature.database-grouping"
 	// FeatureSchemaTemplate allows user to create and use the schema template.
 	FeatureSchemaTemplate FeatureType = "bb.feature.schema-template"
+	// FeatureEncryptedBackup allows user to encrypt the prior backups of the data changes with the referenced KMS key.
+	FeatureEncryptedBackup FeatureType = "bb.feature.encrypted-backup"
+	// FeatureBackupSink allows user to publish the prior backups of the data changes to a logical replication or CDC sink.
+	FeatureBackupSink FeatureType = "bb.feature.backup-sink"
 
 	// Database management.
 
@@ -228,6 +232,10 @@ func (e FeatureType) Name() string {
 		return "Database grouping"
 	case FeatureSchemaTemplate:
 		return "Schema template"
+	case FeatureEncryptedBackup:
+		return "Encrypted backup"
+	case FeatureBackupSink:
+		return "Backup sink"
 	// Database management
 	case FeatureReadReplicaConnection:
 		return "Read replica connection"
@@ -308,6 +316,8 @@ var FeatureMatrix = map[FeatureType][3]bool{
 	FeatureEncryptedSecrets: {false, true, true},
 	FeatureDatabaseGrouping: {false, false, true},
 	FeatureSchemaTemplate:   {false, false, true},
+	FeatureEncryptedBackup:  {false, false, true},
+	FeatureBackupSink:       {false, false, true},
 	// Database management
 	FeatureReadReplicaConnection:      {false, false, true},
 	FeatureInstanceSSHConnection:      {false, false, true},
@@ -337,6 +347,8 @@ var InstanceLimitFeature = map[FeatureType]bool{
 	FeatureEncryptedSecrets: true,
 	FeatureTaskScheduleTime: true,
 	FeatureOnlineMigration:  true,
+	FeatureEncryptedBackup:  true,
+	FeatureBackupSink:       true,
 	// Database management
 	FeatureReadReplicaConnection:      true,
 	FeatureInstanceSSHConnection:      true,
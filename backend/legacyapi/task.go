@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -50,6 +51,8 @@ const (
 	TaskDatabaseDataUpdate TaskType = "bb.task.database.data.update"
 	// TaskDatabaseDataExport is the task type for exporting database data.
 	TaskDatabaseDataExport TaskType = "bb.task.database.data.export"
+	// TaskDatabaseDataRollback is the task type for rolling back a data update from its prior backup.
+	TaskDatabaseDataRollback TaskType = "bb.task.database.data.rollback"
 )
 
 // Sequetial returns whether the task should be executed sequentially.
@@ -134,6 +137,9 @@ type TaskPatch struct {
 	ExportFormat          *storepb.ExportFormat
 	ExportPassword        *string
 	PreUpdateBackupDetail *storepb.PreUpdateBackupDetail
+	BackupRequirement     *storepb.TaskDatabaseUpdatePayload_BackupRequirement
+	// BudgetDeadline is the budget deadline of the data update, or the zero time to clear it.
+	BudgetDeadline *time.Time
 
 	// Flags for gh-ost.
 	Flags *map[string]string
@@ -0,0 +1 @@
+CREATE INDEX idx_task_run_prior_backup_detail ON task_run USING GIN ((result->'priorBackupDetail') jsonb_path_ops);
@@ -479,6 +479,8 @@ CREATE INDEX idx_task_run_task_id ON task_run(task_id);
 
 CREATE UNIQUE INDEX uk_task_run_task_id_attempt ON task_run (task_id, attempt);
 
+CREATE INDEX idx_task_run_prior_backup_detail ON task_run USING GIN ((result->'priorBackupDetail') jsonb_path_ops);
+
 ALTER SEQUENCE task_run_id_seq RESTART WITH 101;
 
 CREATE TABLE task_run_log (
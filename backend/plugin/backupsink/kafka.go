@@ -0,0 +1,92 @@
+// Package backupsink is the plugin publishing the before-images of the prior backups to the logical replication or CDC sinks.
+package backupsink
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+)
+
+// KafkaConfig is the config of the Kafka cluster receiving the before-images.
+type KafkaConfig struct {
+	// Brokers are the addresses of the bootstrap brokers, e.g. "broker1:9092".
+	Brokers []string
+	// TLS connects to the brokers by TLS.
+	TLS bool
+	// Username and Password authenticate by SASL/PLAIN if the username is set.
+	Username string
+	Password string
+	// Timeout is the time limit of producing the messages of a backup. Zero means no limit.
+	Timeout time.Duration
+}
+
+// KafkaProducer produces the before-images to the Kafka topics.
+// The messages of each call are produced to the first partition of the topic in one batch,
+// so that they keep their order and get consecutive offsets.
+type KafkaProducer struct {
+	client *kafka.Client
+}
+
+// NewKafkaProducer creates a Kafka producer.
+func NewKafkaProducer(config KafkaConfig) (*KafkaProducer, error) {
+	if len(config.Brokers) == 0 {
+		return nil, errors.New("kafka brokers are not set")
+	}
+	transport := &kafka.Transport{
+		ClientID: "bytebase",
+	}
+	if config.TLS {
+		transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if config.Username != "" {
+		transport.SASL = plain.Mechanism{Username: config.Username, Password: config.Password}
+	}
+	return &KafkaProducer{
+		client: &kafka.Client{
+			Addr:      kafka.TCP(config.Brokers...),
+			Timeout:   config.Timeout,
+			Transport: transport,
+		},
+	}, nil
+}
+
+// Produce produces the messages to the topic in order and returns the offset of each message.
+// The produce is acknowledged by all the in-sync replicas, so the before-images are not lost with the leader.
+func (p *KafkaProducer) Produce(ctx context.Context, topic string, messages [][]byte) ([]int64, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	records := make([]kafka.Record, 0, len(messages))
+	for _, message := range messages {
+		records = append(records, kafka.Record{Value: kafka.NewBytes(message)})
+	}
+	resp, err := p.client.Produce(ctx, &kafka.ProduceRequest{
+		Topic:        topic,
+		Partition:    0,
+		RequiredAcks: kafka.RequireAll,
+		Records:      kafka.NewRecordReader(records...),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to produce to topic %q", topic)
+	}
+	if resp.Error != nil {
+		return nil, errors.Wrapf(resp.Error, "failed to produce to topic %q", topic)
+	}
+	for i, err := range resp.RecordErrors {
+		return nil, errors.Wrapf(err, "failed to produce message %d to topic %q", i, topic)
+	}
+	return getOffsets(resp.BaseOffset, len(messages)), nil
+}
+
+// getOffsets returns the offsets of the messages produced in one batch starting from the base offset.
+func getOffsets(baseOffset int64, count int) []int64 {
+	offsets := make([]int64, 0, count)
+	for i := 0; i < count; i++ {
+		offsets = append(offsets, baseOffset+int64(i))
+	}
+	return offsets
+}
@@ -305,6 +305,13 @@ type Driver interface {
 	Dump(ctx context.Context, out io.Writer) (string, error)
 }
 
+// DatabaseSnapshotter is implemented by the drivers able to clone a whole database by the native snapshot
+// of the instance or its storage, e.g. the fast clone of the cloud databases or the snapshot of ZFS.
+type DatabaseSnapshotter interface {
+	// CreateDatabaseSnapshot creates the snapshot of the database and returns its identifier.
+	CreateDatabaseSnapshot(ctx context.Context, database string) (string, error)
+}
+
 // Register makes a database driver available by the provided type.
 // If Register is called twice with the same name or if driver is nil,
 // it panics.
@@ -337,6 +344,19 @@ func Open(ctx context.Context, dbType storepb.Engine, driverConfig DriverConfig,
 	return driver, nil
 }
 
+// PreludeRollbackPolicy is how a failure of the statements treats the prelude statements executed in the same transaction.
+type PreludeRollbackPolicy string
+
+const (
+	// PreludeRollbackAll rolls back the prelude statements with the statements.
+	PreludeRollbackAll PreludeRollbackPolicy = ""
+	// PreludeRollbackKeep rolls back to the savepoint after the prelude statements and commits the prelude statements.
+	PreludeRollbackKeep PreludeRollbackPolicy = "KEEP"
+)
+
+// PreludeSavepoint is the savepoint set after the prelude statements with PreludeRollbackKeep.
+const PreludeSavepoint = "bb_prelude"
+
 // ExecuteOptions is the options for execute.
 type ExecuteOptions struct {
 	CreateDatabase   bool
@@ -345,6 +365,25 @@ type ExecuteOptions struct {
 	// Record the connection id first before executing.
 	SetConnectionID    func(id string)
 	DeleteConnectionID func()
+
+	// IsolationLevel is the isolation level used to execute the statements.
+	// sql.LevelDefault uses the default isolation level of the database.
+	IsolationLevel sql.IsolationLevel
+
+	// ReadTimeout and WriteTimeout bound the phase reading the rows and the phase writing them separately, e.g. COPY TO
+	// out of the source and COPY FROM into the target of the Postgres bulk copy. Zero doesn't bound the phase.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// PreludeStatements are executed in the same transaction before the statements.
+	// They are not logged as commands of the statements. Only supported by Postgres and MySQL.
+	PreludeStatements []string
+	// PreludeRollbackPolicy is how a failure of the statements treats the prelude statements.
+	PreludeRollbackPolicy PreludeRollbackPolicy
+
+	// ResourceGroup is the resource control group of the session executing the statements,
+	// so that the statements are rate-limited by the database. Only supported by TiDB.
+	ResourceGroup string
 }
 
 func (o *ExecuteOptions) LogSchemaDumpStart() {
@@ -301,6 +301,19 @@ func (d *Driver) Execute(ctx context.Context, statement string, opts db.ExecuteO
 	}
 	slog.Debug("connectionID", slog.String("connectionID", connectionID))
 
+	if opts.IsolationLevel != sql.LevelDefault {
+		// DDL statements cause an implicit commit, so we set the isolation level on the session
+		// instead of the transaction to cover the statements following the implicit commit.
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET SESSION TRANSACTION ISOLATION LEVEL %s", strings.ToUpper(opts.IsolationLevel.String()))); err != nil {
+			return 0, errors.Wrapf(err, "failed to set isolation level")
+		}
+		defer func() {
+			if _, err := conn.ExecContext(ctx, "SET SESSION transaction_isolation = @@GLOBAL.transaction_isolation"); err != nil {
+				slog.Warn("failed to reset isolation level", slog.String("connectionID", connectionID), log.BBError(err))
+			}
+		}()
+	}
+
 	var totalCommands int
 	var commands []base.SingleSQL
 	var originalIndex []int32
@@ -357,6 +370,19 @@ func (d *Driver) Execute(ctx context.Context, statement string, opts db.ExecuteO
 			opts.LogTransactionControl(storepb.TaskRunLog_TransactionControl_ROLLBACK, rerr)
 		}()
 
+		// The DDL statements commit the transaction implicitly, so the prelude statements should not include them.
+		for _, prelude := range opts.PreludeStatements {
+			if _, err := exer.ExecContext(ctx, util.MySQLPrependBytebaseAppComment(prelude), nil); err != nil {
+				return errors.Wrapf(err, "failed to execute prelude statement %q", prelude)
+			}
+		}
+		keepPrelude := len(opts.PreludeStatements) > 0 && opts.PreludeRollbackPolicy == db.PreludeRollbackKeep
+		if keepPrelude {
+			if _, err := exer.ExecContext(ctx, fmt.Sprintf("SAVEPOINT %s", db.PreludeSavepoint), nil); err != nil {
+				return errors.Wrapf(err, "failed to set savepoint after prelude statements")
+			}
+		}
+
 		for i, command := range commands {
 			indexes := []int32{originalIndex[i]}
 			opts.LogCommandExecute(indexes)
@@ -373,6 +399,17 @@ func (d *Driver) Execute(ctx context.Context, statement string, opts db.ExecuteO
 
 				opts.LogCommandResponse(indexes, 0, nil, err.Error())
 
+				if keepPrelude && ctx.Err() == nil {
+					// The prelude statements are committed without the effects of the statements.
+					if _, err := exer.ExecContext(ctx, fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", db.PreludeSavepoint), nil); err != nil {
+						slog.Warn("failed to roll back to savepoint after prelude statements", slog.String("connectionID", connectionID), log.BBError(err))
+					} else if err := tx.Commit(); err != nil {
+						opts.LogTransactionControl(storepb.TaskRunLog_TransactionControl_COMMIT, err.Error())
+					} else {
+						opts.LogTransactionControl(storepb.TaskRunLog_TransactionControl_COMMIT, "")
+						committed = true
+					}
+				}
 				return &db.ErrorWithPosition{
 					Err: errors.Wrapf(err, "failed to execute context in a transaction"),
 					Start: &storepb.TaskRunResult_Position{
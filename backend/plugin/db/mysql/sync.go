@@ -34,6 +34,7 @@ const (
 	pkAutoRandomBitsSymbol = "PK_AUTO_RANDOM_BITS"
 	virtualGenerated       = "VIRTUAL GENERATED"
 	storedGenerated        = "STORED GENERATED"
+	invisibleColumn        = "INVISIBLE"
 )
 
 var (
@@ -350,6 +351,7 @@ func (driver *Driver) SyncDBSchema(ctx context.Context) (*storepb.DatabaseSchema
 				Expression: generationExpr.String,
 			}
 		}
+		column.Hidden = strings.Contains(strings.ToUpper(extra), invisibleColumn)
 	}
 	if err := columnRows.Err(); err != nil {
 		return nil, util.FormatErrorWithQuery(err, columnQuery)
@@ -0,0 +1,124 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"github.com/bytebase/bytebase/backend/plugin/db"
+)
+
+// CopyQueryToTable creates the table with the columns of the query and fills it with the query result by COPY.
+// The rows are streamed in binary format from COPY ... TO STDOUT on one connection to COPY ... FROM STDIN on another.
+// The read timeout and the write timeout of the options bound COPY TO and COPY FROM respectively.
+// The table is dropped if the copy fails, so that a partially filled table is never left behind.
+func (driver *Driver) CopyQueryToTable(ctx context.Context, query string, schema string, table string, opts db.ExecuteOptions) (int64, error) {
+	target := pgx.Identifier{schema, table}.Sanitize()
+	// Create the table by Execute so that its owner is the database owner.
+	if _, err := driver.Execute(ctx, fmt.Sprintf("CREATE TABLE %s AS %s WITH NO DATA;", target, query), db.ExecuteOptions{}); err != nil {
+		return 0, errors.Wrapf(err, "failed to create table %s", target)
+	}
+	rowsAffected, err := driver.copyQueryToTable(ctx, query, target, opts)
+	if err != nil {
+		// The context may be done by the timeouts, which must not stop the cleanup.
+		if _, dropErr := driver.Execute(context.WithoutCancel(ctx), fmt.Sprintf("DROP TABLE IF EXISTS %s;", target), db.ExecuteOptions{}); dropErr != nil {
+			err = multierr.Append(err, errors.Wrapf(dropErr, "failed to drop table %s", target))
+		}
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+// copyQueryToTable fills the created target table with the query result by COPY.
+func (driver *Driver) copyQueryToTable(ctx context.Context, query string, target string, opts db.ExecuteOptions) (int64, error) {
+	source, err := driver.db.Conn(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get source connection")
+	}
+	defer source.Close()
+	sink, err := driver.db.Conn(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get sink connection")
+	}
+	defer sink.Close()
+
+	copyTo := func(ctx context.Context, w io.Writer) error {
+		return source.Raw(func(driverConn any) error {
+			conn, ok := driverConn.(*stdlib.Conn)
+			if !ok {
+				return errors.Errorf("unexpected source connection type %T", driverConn)
+			}
+			_, err := conn.Conn().PgConn().CopyTo(ctx, w, fmt.Sprintf("COPY (%s) TO STDOUT (FORMAT binary)", query))
+			return err
+		})
+	}
+	copyFrom := func(ctx context.Context, r io.Reader) (int64, error) {
+		var rowsAffected int64
+		err := sink.Raw(func(driverConn any) error {
+			conn, ok := driverConn.(*stdlib.Conn)
+			if !ok {
+				return errors.Errorf("unexpected sink connection type %T", driverConn)
+			}
+			tag, err := conn.Conn().PgConn().CopyFrom(ctx, r, fmt.Sprintf("COPY %s FROM STDIN (FORMAT binary)", target))
+			if err != nil {
+				return err
+			}
+			rowsAffected = tag.RowsAffected()
+			return nil
+		})
+		return rowsAffected, err
+	}
+	rowsAffected, err := copyRows(ctx, opts.ReadTimeout, opts.WriteTimeout, copyTo, copyFrom)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to copy into table %s", target)
+	}
+	return rowsAffected, nil
+}
+
+// copyRows streams the rows written by copyTo to copyFrom through a pipe, and returns the rows read by copyFrom.
+// The read timeout and the write timeout bound copyTo and copyFrom respectively if they're non-zero.
+func copyRows(ctx context.Context, readTimeout, writeTimeout time.Duration, copyTo func(context.Context, io.Writer) error, copyFrom func(context.Context, io.Reader) (int64, error)) (int64, error) {
+	readCtx, readCancel := withOptionalTimeout(ctx, readTimeout)
+	defer readCancel()
+	writeCtx, writeCancel := withOptionalTimeout(ctx, writeTimeout)
+	defer writeCancel()
+
+	r, w := io.Pipe()
+	copyToErr := make(chan error, 1)
+	go func() {
+		err := copyTo(readCtx, w)
+		// Closing with nil error signals EOF to the sink.
+		w.CloseWithError(err)
+		copyToErr <- err
+	}()
+
+	rowsAffected, err := copyFrom(writeCtx, r)
+	// Unblock the source if the sink stops reading.
+	r.CloseWithError(err)
+	if err := <-copyToErr; err != nil {
+		if errors.Is(readCtx.Err(), context.DeadlineExceeded) {
+			return 0, errors.Errorf("copying out the query result exceeded the read timeout %s", readTimeout)
+		}
+		return 0, errors.Wrapf(err, "failed to copy out query result")
+	}
+	if err != nil {
+		if errors.Is(writeCtx.Err(), context.DeadlineExceeded) {
+			return 0, errors.Errorf("copying in the query result exceeded the write timeout %s", writeTimeout)
+		}
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
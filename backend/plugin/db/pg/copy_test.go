@@ -0,0 +1,56 @@
+package pg
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyRowsTimeouts(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	copyTo := func(_ context.Context, w io.Writer) error {
+		_, err := w.Write([]byte("row1\nrow2\n"))
+		return err
+	}
+	copyFrom := func(_ context.Context, r io.Reader) (int64, error) {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return 0, err
+		}
+		return int64(strings.Count(string(data), "\n")), nil
+	}
+	// slowCopyTo writes nothing until its phase is canceled.
+	slowCopyTo := func(ctx context.Context, _ io.Writer) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	// slowCopyFrom reads all the rows and then blocks until its phase is canceled.
+	slowCopyFrom := func(ctx context.Context, r io.Reader) (int64, error) {
+		if _, err := io.ReadAll(r); err != nil {
+			return 0, err
+		}
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+
+	rows, err := copyRows(ctx, 0, 0, copyTo, copyFrom)
+	a.NoError(err)
+	a.Equal(int64(2), rows)
+
+	// The read phase is bounded by the read timeout even if the write timeout is longer.
+	start := time.Now()
+	_, err = copyRows(ctx, 50*time.Millisecond, time.Hour, slowCopyTo, copyFrom)
+	a.ErrorContains(err, "exceeded the read timeout 50ms")
+	a.Less(time.Since(start), 10*time.Second)
+
+	// The write phase is bounded by the write timeout after the read phase completes within the read timeout.
+	start = time.Now()
+	_, err = copyRows(ctx, time.Hour, 50*time.Millisecond, copyTo, slowCopyFrom)
+	a.ErrorContains(err, "exceeded the write timeout 50ms")
+	a.Less(time.Since(start), 10*time.Second)
+}
@@ -374,7 +374,7 @@ func (driver *Driver) Execute(ctx context.Context, statement string, opts db.Exe
 		// we should execute it as a single statement without transaction.
 		// If the statement is a PL/pgSQL block, we should execute it as a single statement.
 		// https://www.postgresql.org/docs/current/plpgsql-control-structures.html
-		if len(singleSQLs) == 1 && isPlSQLBlock(singleSQLs[0].Text) {
+		if len(singleSQLs) == 1 && IsPlSQLBlock(singleSQLs[0].Text) {
 			isPlsql = true
 		}
 		// HACK(p0ny): always split for pg
@@ -434,6 +434,9 @@ func (driver *Driver) Execute(ctx context.Context, statement string, opts db.Exe
 	}
 
 	if isPlsql {
+		if len(opts.PreludeStatements) > 0 {
+			return 0, errors.Errorf("prelude statements are not supported for PL/pgSQL blocks")
+		}
 		// USE SET SESSION ROLE to set the role for the current session.
 		if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET SESSION ROLE '%s'", owner)); err != nil {
 			return 0, errors.Wrapf(err, "failed to set role to database owner %q", owner)
@@ -451,11 +454,15 @@ func (driver *Driver) Execute(ctx context.Context, statement string, opts db.Exe
 	totalRowsAffected := int64(0)
 
 	totalCommands := len(commands)
-	if totalCommands > 0 {
+	if totalCommands > 0 || len(opts.PreludeStatements) > 0 {
 		err = conn.Raw(func(driverConn any) error {
 			conn := driverConn.(*stdlib.Conn).Conn()
 
-			tx, err := conn.Begin(ctx)
+			txOptions := pgx.TxOptions{}
+			if opts.IsolationLevel != sql.LevelDefault {
+				txOptions.IsoLevel = pgx.TxIsoLevel(strings.ToLower(opts.IsolationLevel.String()))
+			}
+			tx, err := conn.BeginTx(ctx, txOptions)
 			if err != nil {
 				opts.LogTransactionControl(storepb.TaskRunLog_TransactionControl_BEGIN, err.Error())
 				return errors.Wrapf(err, "failed to begin transaction")
@@ -480,6 +487,18 @@ func (driver *Driver) Execute(ctx context.Context, statement string, opts db.Exe
 				return err
 			}
 
+			for _, prelude := range opts.PreludeStatements {
+				if _, err := tx.Exec(ctx, prelude); err != nil {
+					return errors.Wrapf(err, "failed to execute prelude statement %q", prelude)
+				}
+			}
+			keepPrelude := len(opts.PreludeStatements) > 0 && opts.PreludeRollbackPolicy == db.PreludeRollbackKeep
+			if keepPrelude {
+				if _, err := tx.Exec(ctx, fmt.Sprintf("SAVEPOINT %s", db.PreludeSavepoint)); err != nil {
+					return errors.Wrapf(err, "failed to set savepoint after prelude statements")
+				}
+			}
+
 			for i, command := range commands {
 				indexes := []int32{originalIndex[i]}
 				opts.LogCommandExecute(indexes)
@@ -489,6 +508,17 @@ func (driver *Driver) Execute(ctx context.Context, statement string, opts db.Exe
 				if err != nil {
 					opts.LogCommandResponse(indexes, 0, nil, err.Error())
 
+					if keepPrelude {
+						// The prelude statements are committed without the effects of the statements.
+						if _, err := tx.Exec(ctx, fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", db.PreludeSavepoint)); err != nil {
+							slog.Warn("failed to roll back to savepoint after prelude statements", log.BBError(err))
+						} else if err := tx.Commit(ctx); err != nil {
+							opts.LogTransactionControl(storepb.TaskRunLog_TransactionControl_COMMIT, err.Error())
+						} else {
+							opts.LogTransactionControl(storepb.TaskRunLog_TransactionControl_COMMIT, "")
+							committed = true
+						}
+					}
 					return &db.ErrorWithPosition{
 						Err: errors.Wrapf(err, "failed to execute context in a transaction"),
 						Start: &storepb.TaskRunResult_Position{
@@ -718,7 +748,8 @@ func getStatementWithResultLimit(stmt string, limit int) string {
 	return fmt.Sprintf("WITH result AS (\n%s\n) SELECT * FROM result LIMIT %d;", util.TrimStatement(stmt), limit)
 }
 
-func isPlSQLBlock(stmt string) bool {
+// IsPlSQLBlock returns whether the statement is a PL/pgSQL DO block, which is executed outside of a transaction.
+func IsPlSQLBlock(stmt string) bool {
 	tree, err := pgquery.Parse(stmt)
 	if err != nil {
 		return false
@@ -182,6 +182,34 @@ func (d *Driver) Execute(ctx context.Context, statement string, opts db.ExecuteO
 	}
 	slog.Debug("connectionID", slog.String("connectionID", connectionID))
 
+	if opts.IsolationLevel != sql.LevelDefault {
+		// DDL statements cause an implicit commit, so we set the isolation level on the session
+		// instead of the transaction to cover the statements following the implicit commit.
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET SESSION TRANSACTION ISOLATION LEVEL %s", strings.ToUpper(opts.IsolationLevel.String()))); err != nil {
+			return 0, errors.Wrapf(err, "failed to set isolation level")
+		}
+		defer func() {
+			if _, err := conn.ExecContext(ctx, "SET SESSION transaction_isolation = @@GLOBAL.transaction_isolation"); err != nil {
+				slog.Warn("failed to reset isolation level", slog.String("connectionID", connectionID), log.BBError(err))
+			}
+		}()
+	}
+	if opts.ResourceGroup != "" {
+		setResourceGroup, err := getSetResourceGroupStatement(opts.ResourceGroup)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := conn.ExecContext(ctx, setResourceGroup); err != nil {
+			return 0, errors.Wrapf(err, "failed to set resource group %q", opts.ResourceGroup)
+		}
+		defer func() {
+			// The connection goes back to the pool, so the session is bound back to the resource group of the user.
+			if _, err := conn.ExecContext(ctx, "SET RESOURCE GROUP ``"); err != nil {
+				slog.Warn("failed to reset resource group", slog.String("connectionID", connectionID), log.BBError(err))
+			}
+		}()
+	}
+
 	var remainingSQLsIndex, nonTransactionStmtsIndex []int
 	var nonTransactionStmts []string
 	var totalCommands int
@@ -399,6 +427,16 @@ func (d *Driver) StopConnectionByID(id string) error {
 	return err
 }
 
+var resourceGroupRegexp = regexp.MustCompile(`^[A-Za-z0-9_]{1,32}$`)
+
+// getSetResourceGroupStatement returns the statement binding the session to the resource group.
+func getSetResourceGroupStatement(resourceGroup string) (string, error) {
+	if !resourceGroupRegexp.MatchString(resourceGroup) {
+		return "", errors.Errorf("invalid resource group %q", resourceGroup)
+	}
+	return fmt.Sprintf("SET RESOURCE GROUP `%s`", resourceGroup), nil
+}
+
 func getConnectionID(ctx context.Context, conn *sql.Conn) (string, error) {
 	var id string
 	if err := conn.QueryRowContext(ctx, `SELECT CONNECTION_ID();`).Scan(&id); err != nil {
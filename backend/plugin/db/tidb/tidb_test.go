@@ -75,3 +75,13 @@ func TestIsNonTransactionStatement(t *testing.T) {
 		require.Equal(t, test.want, got)
 	}
 }
+
+func TestGetSetResourceGroupStatement(t *testing.T) {
+	a := require.New(t)
+	statement, err := getSetResourceGroupStatement("rg_backup")
+	a.NoError(err)
+	a.Equal("SET RESOURCE GROUP `rg_backup`", statement)
+
+	_, err = getSetResourceGroupStatement("rg`; DROP DATABASE db; --")
+	a.Error(err)
+}
@@ -0,0 +1,91 @@
+package objectstore
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/pkg/errors"
+)
+
+// azureStore is the object store of an Azure Blob Storage container.
+type azureStore struct {
+	client    *azblob.Client
+	container string
+	// prefix is prepended to the names of the blobs, so that the container can be shared.
+	prefix string
+}
+
+func newAzureStore(config Config, container, prefix string) (*azureStore, error) {
+	if config.ConnectionString == "" {
+		return nil, errors.New("Azure Blob Storage requires the connection string of the storage account")
+	}
+	client, err := azblob.NewClientFromConnectionString(config.ConnectionString, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create Azure Blob Storage client")
+	}
+	return &azureStore{client: client, container: container, prefix: prefix}, nil
+}
+
+// PutObject writes the object under the key.
+func (s *azureStore) PutObject(ctx context.Context, key string, data []byte) error {
+	if _, err := s.client.UploadBuffer(ctx, s.container, s.prefix+key, data, nil); err != nil {
+		return errors.Wrapf(err, "failed to put object %q", key)
+	}
+	return nil
+}
+
+// ListObjects returns the keys of the objects with the prefix.
+func (s *azureStore) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	blobPrefix := s.prefix + prefix
+	pager := s.client.NewListBlobsFlatPager(s.container, &azblob.ListBlobsFlatOptions{
+		Prefix: &blobPrefix,
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list objects with prefix %q", prefix)
+		}
+		for _, blob := range page.Segment.BlobItems {
+			if blob.Name == nil {
+				continue
+			}
+			keys = append(keys, strings.TrimPrefix(*blob.Name, s.prefix))
+		}
+	}
+	return keys, nil
+}
+
+// GetObject returns the object under the key.
+func (s *azureStore) GetObject(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.client.DownloadStream(ctx, s.container, s.prefix+key, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get object %q", key)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read object %q", key)
+	}
+	return data, nil
+}
+
+// UploadObject writes the object under the key from the body, without holding the whole object in memory.
+// The blob is uploaded in blocks, holding only the blocks being uploaded in memory.
+func (s *azureStore) UploadObject(ctx context.Context, key string, body io.Reader) error {
+	if _, err := s.client.UploadStream(ctx, s.container, s.prefix+key, body, nil); err != nil {
+		return errors.Wrapf(err, "failed to upload object %q", key)
+	}
+	return nil
+}
+
+// DownloadObject returns the reader of the object under the key. The caller must close it.
+func (s *azureStore) DownloadObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.client.DownloadStream(ctx, s.container, s.prefix+key, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get object %q", key)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,63 @@
+// Package objectstore is the plugin of the external object stores keeping the prior backups, i.e. S3, the S3-compatible stores
+// such as GCS by its interoperability API and MinIO, and Azure Blob Storage.
+package objectstore
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/bytebase/bytebase/backend/common"
+)
+
+// Config is the config of the object store.
+type Config struct {
+	// URL is the location of the objects, i.e. s3://{bucket}[/{prefix}], gs://{bucket}[/{prefix}] or azblob://{container}[/{prefix}].
+	URL string
+	// Endpoint overrides the endpoint of the S3-compatible stores such as MinIO. GCS uses https://storage.googleapis.com by default.
+	Endpoint string
+	// Region is the region of the S3 bucket.
+	Region string
+	// AccessKeyID and SecretAccessKey are the static credentials of S3, or the HMAC keys of GCS.
+	// The default credential chain of AWS is used on S3 if they are not set.
+	AccessKeyID     string
+	SecretAccessKey string
+	// ConnectionString is the connection string of the Azure storage account.
+	ConnectionString string
+}
+
+// gcsEndpoint is the endpoint of the GCS XML API interoperable with S3.
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// New creates the object store by the scheme of the URL.
+func New(ctx context.Context, config Config) (common.PriorBackupObjectStore, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid object store URL %q", config.URL)
+	}
+	if u.Host == "" {
+		return nil, errors.Errorf("object store URL %q has no bucket", config.URL)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	switch u.Scheme {
+	case "s3":
+		return newS3Store(ctx, config, u.Host, prefix)
+	case "gs":
+		if config.Endpoint == "" {
+			config.Endpoint = gcsEndpoint
+		}
+		if config.AccessKeyID == "" {
+			return nil, errors.New("GCS requires the HMAC keys as the access key ID and the secret access key")
+		}
+		return newS3Store(ctx, config, u.Host, prefix)
+	case "azblob":
+		return newAzureStore(config, u.Host, prefix)
+	default:
+		return nil, errors.Errorf("unsupported object store scheme %q, must be s3, gs or azblob", u.Scheme)
+	}
+}
@@ -0,0 +1,158 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+
+	for _, config := range []Config{
+		{URL: "ftp://bucket"},
+		{URL: "s3:///prefix"},
+		// GCS is accessed by the HMAC keys.
+		{URL: "gs://bucket"},
+		{URL: "azblob://container"},
+	} {
+		_, err := New(ctx, config)
+		a.Error(err, config.URL)
+	}
+
+	store, err := New(ctx, Config{URL: "gs://bucket/backups/", AccessKeyID: "id", SecretAccessKey: "secret"})
+	a.NoError(err)
+	a.Equal("backups/", store.(*s3Store).prefix)
+	a.Equal("bucket", store.(*s3Store).bucket)
+}
+
+// fakeS3 is the S3 server keeping the objects in memory, serving the path-style requests of a bucket.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	// uploads are the parts of the multipart uploads in progress by the upload IDs.
+	uploads map[string]map[int][]byte
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := strconv.Itoa(len(s.uploads) + 1)
+		s.uploads[uploadID] = map[int][]byte{}
+		_ = xml.NewEncoder(w).Encode(struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Key      string
+			UploadID string `xml:"UploadId"`
+		}{Key: key, UploadID: uploadID})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		part, _ := strconv.Atoi(query.Get("partNumber"))
+		data, _ := io.ReadAll(r.Body)
+		s.uploads[query.Get("uploadId")][part] = data
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, part))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts := s.uploads[query.Get("uploadId")]
+		var data []byte
+		for i := 1; i <= len(parts); i++ {
+			data = append(data, parts[i]...)
+		}
+		s.objects[key] = data
+		delete(s.uploads, query.Get("uploadId"))
+		_ = xml.NewEncoder(w).Encode(struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Key     string
+		}{Key: key})
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.objects[key] = data
+	case r.Method == http.MethodGet && query.Get("list-type") == "2":
+		type content struct {
+			Key string
+		}
+		result := struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []content
+		}{}
+		var keys []string
+		for key := range s.objects {
+			if strings.HasPrefix(key, query.Get("prefix")) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result.Contents = append(result.Contents, content{Key: key})
+		}
+		_ = xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	fake := &fakeS3{objects: map[string][]byte{"other/object": []byte("other")}, uploads: map[string]map[int][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := New(ctx, Config{
+		URL:             "s3://bucket/backups",
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		AccessKeyID:     "id",
+		SecretAccessKey: "secret",
+	})
+	a.NoError(err)
+
+	// The keys are under the prefix of the URL in the bucket.
+	key := "prior-backups/issues/1/tasks/2/20240102T150405.000000000Z.jsonl"
+	a.NoError(store.PutObject(ctx, key, []byte("{}\n")))
+	a.Equal([]byte("{}\n"), fake.objects["backups/"+key])
+
+	keys, err := store.ListObjects(ctx, "prior-backups/issues/1/")
+	a.NoError(err)
+	a.Equal([]string{key}, keys)
+
+	data, err := store.GetObject(ctx, key)
+	a.NoError(err)
+	a.Equal([]byte("{}\n"), data)
+	_, err = store.GetObject(ctx, "prior-backups/missing")
+	a.Error(err)
+
+	// The objects larger than a part are streamed by the multipart upload.
+	exportKey := "prior-backup-exports/issues/1/tasks/2/20240102T150405.000000000Z/0_t.csv.gz"
+	large := bytes.Repeat([]byte("0123456789"), int(manager.MinUploadPartSize)/5)
+	a.NoError(store.UploadObject(ctx, exportKey, bytes.NewReader(large)))
+	a.Empty(fake.uploads)
+	a.Equal(large, fake.objects["backups/"+exportKey])
+	body, err := store.DownloadObject(ctx, exportKey)
+	a.NoError(err)
+	defer body.Close()
+	data, err = io.ReadAll(body)
+	a.NoError(err)
+	a.Equal(large, data)
+}
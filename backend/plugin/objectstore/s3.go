@@ -0,0 +1,124 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/errors"
+)
+
+// s3Store is the object store of an S3 bucket or an S3-compatible one.
+type s3Store struct {
+	client *s3.Client
+	// uploader streams the large objects by the multipart uploads, holding only the parts being uploaded in memory.
+	uploader *manager.Uploader
+	bucket   string
+	// prefix is prepended to the keys of the objects, so that the bucket can be shared.
+	prefix string
+}
+
+func newS3Store(ctx context.Context, config Config, bucket, prefix string) (*s3Store, error) {
+	var options []func(*awsconfig.LoadOptions) error
+	if config.Region != "" {
+		options = append(options, awsconfig.WithRegion(config.Region))
+	}
+	if config.AccessKeyID != "" {
+		options = append(options, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(config.AccessKeyID, config.SecretAccessKey, "")))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load AWS config")
+	}
+	if cfg.Region == "" {
+		// The S3-compatible stores ignore the region, but the request signing requires one.
+		cfg.Region = "auto"
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(config.Endpoint)
+			// The S3-compatible stores don't support the virtual-hosted-style requests in general.
+			o.UsePathStyle = true
+		}
+	})
+	return &s3Store{client: client, uploader: manager.NewUploader(client), bucket: bucket, prefix: prefix}, nil
+}
+
+// PutObject writes the object under the key.
+func (s *s3Store) PutObject(ctx context.Context, key string, data []byte) error {
+	if _, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+		Body:   bytes.NewReader(data),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to put object %q", key)
+	}
+	return nil
+}
+
+// ListObjects returns the keys of the objects with the prefix.
+func (s *s3Store) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix + prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list objects with prefix %q", prefix)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(aws.ToString(object.Key), s.prefix))
+		}
+	}
+	return keys, nil
+}
+
+// GetObject returns the object under the key.
+func (s *s3Store) GetObject(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get object %q", key)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read object %q", key)
+	}
+	return data, nil
+}
+
+// UploadObject writes the object under the key from the body, without holding the whole object in memory.
+// The objects larger than a part are uploaded by the multipart upload, which is aborted on failures.
+func (s *s3Store) UploadObject(ctx context.Context, key string, body io.Reader) error {
+	if _, err := s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+		Body:   body,
+	}); err != nil {
+		return errors.Wrapf(err, "failed to upload object %q", key)
+	}
+	return nil
+}
+
+// DownloadObject returns the reader of the object under the key. The caller must close it.
+func (s *s3Store) DownloadObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get object %q", key)
+	}
+	return resp.Body, nil
+}
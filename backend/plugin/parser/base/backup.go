@@ -8,8 +8,51 @@ type BackupStatement struct {
 	Statement       string
 	SourceSchema    string
 	SourceTableName string
+	// TargetSchema is the schema of the backup table in the backup database, which matches the schema of the source table on MSSQL.
+	// Empty on the engines whose backup databases have no schemas or are the schemas themselves.
+	TargetSchema    string
 	TargetTableName string
 
 	StartPosition *storebp.Position
 	EndPosition   *storebp.Position
+
+	// Uncertainty is how uncertain the transformation is that the statement backs up exactly the rows affected by the DML,
+	// from 0 for certain to 1 for the DML affecting arbitrary rows, e.g. with LIMIT but no ORDER BY.
+	Uncertainty float64
+	// UncertaintyReason explains the uncertainty.
+	UncertaintyReason string
+
+	// UpdateSetClause is the SET clause of the UPDATE, e.g. SET c = c + 1, which computes the new values of the backed up rows
+	// when applied to the backup table aliased by UpdateAlias. Empty if the new values depend on more than the backed up rows,
+	// e.g. the UPDATE joins other tables, or the after-image is not requested.
+	UpdateSetClause string
+	UpdateAlias     string
+}
+
+// AffectedRowsCheckContext is the context of checking on a database that a backup statement captures the rows affected by its DML.
+type AffectedRowsCheckContext struct {
+	// SourceDatabase is the database of the source table, and BackupDatabase is the database or schema of the backup table.
+	SourceDatabase string
+	BackupDatabase string
+	// SnapshotTable is the table in the backup database keeping the rows of the source table before the DML.
+	SnapshotTable string
+	// Columns are the columns of the source table compared between the rows.
+	Columns []string
+}
+
+// AffectedRowsCheck is the queries checking that the backup table holds exactly the rows changed by the DML. The rows are compared
+// by the values of all the compared columns as sets, so the source table must not have duplicate rows, and the DML must change every row
+// it targets, since the rows updated to the same values are indistinguishable from the rows not targeted.
+type AffectedRowsCheck struct {
+	// Snapshot copies the rows of the source table into the snapshot table. It runs after the backup statement and before the DML.
+	Snapshot string
+	// The following queries run after the DML and each selects a count, which must be zero.
+	// Missing counts the rows changed or deleted by the DML but not in the backup table.
+	Missing string
+	// Extra counts the rows in the backup table that were not in the source table before the DML.
+	Extra string
+	// Unchanged counts the rows in the backup table left unchanged in the source table by the DML.
+	Unchanged string
+	// Cleanup drops the snapshot table.
+	Cleanup string
 }
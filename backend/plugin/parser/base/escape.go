@@ -0,0 +1,73 @@
+package base
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+var escapeStrategies = make(map[storepb.Engine]EscapeStrategy)
+
+// EscapeStrategy is the rules of an engine quoting the identifiers, so that the reserved words and the special characters can be used as names.
+type EscapeStrategy struct {
+	// Open and Close are the delimiters of the quoted identifiers, e.g. the backticks of MySQL and the brackets of MSSQL.
+	Open  string
+	Close string
+	// Escape replaces the closing delimiter in the identifiers. Empty doubles the closing delimiter as most engines do.
+	Escape string
+}
+
+// QuoteIdentifier quotes the identifier by the delimiters, escaping the closing delimiter in it.
+func (s EscapeStrategy) QuoteIdentifier(identifier string) string {
+	escape := s.Escape
+	if escape == "" {
+		escape = s.Close + s.Close
+	}
+	return s.Open + strings.ReplaceAll(identifier, s.Close, escape) + s.Close
+}
+
+// RegisterEscapeStrategy registers the identifier escaping rules for the engine.
+func RegisterEscapeStrategy(engine storepb.Engine, strategy EscapeStrategy) {
+	mux.Lock()
+	defer mux.Unlock()
+	if _, dup := escapeStrategies[engine]; dup {
+		panic(fmt.Sprintf("Register called twice %s", engine))
+	}
+	if strategy.Open == "" || strategy.Close == "" {
+		panic(fmt.Sprintf("Register called without the delimiters %s", engine))
+	}
+	escapeStrategies[engine] = strategy
+}
+
+// GetEscapeStrategy returns the identifier escaping rules of the engine, or false if the engine has none registered.
+func GetEscapeStrategy(engine storepb.Engine) (EscapeStrategy, bool) {
+	mux.Lock()
+	defer mux.Unlock()
+	strategy, ok := escapeStrategies[engine]
+	return strategy, ok
+}
+
+// QuoteIdentifier quotes the identifier by the escaping rules of the engine.
+func QuoteIdentifier(engine storepb.Engine, identifier string) (string, error) {
+	strategy, ok := GetEscapeStrategy(engine)
+	if !ok {
+		return "", errors.Errorf("identifier escaping is not supported for engine %s", engine)
+	}
+	return strategy.QuoteIdentifier(identifier), nil
+}
+
+// QuoteQualifiedName quotes the identifiers by the escaping rules of the engine and joins them by dots, e.g. "schema"."table" on Postgres.
+func QuoteQualifiedName(engine storepb.Engine, identifiers ...string) (string, error) {
+	var quoted []string
+	for _, identifier := range identifiers {
+		q, err := QuoteIdentifier(engine, identifier)
+		if err != nil {
+			return "", err
+		}
+		quoted = append(quoted, q)
+	}
+	return strings.Join(quoted, "."), nil
+}
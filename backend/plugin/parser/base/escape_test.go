@@ -0,0 +1,47 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func TestEscapeStrategy(t *testing.T) {
+	a := require.New(t)
+	// The fake engine quotes the identifiers by the angle brackets, and escapes the closing bracket by a backslash.
+	fakeEngine := storepb.Engine(-1)
+	t.Cleanup(func() {
+		unregisterEscapeStrategy(fakeEngine)
+		unregisterEscapeStrategy(storepb.Engine(-2))
+	})
+	_, ok := GetEscapeStrategy(fakeEngine)
+	a.False(ok)
+	_, err := QuoteIdentifier(fakeEngine, `a"b`)
+	a.Error(err)
+
+	RegisterEscapeStrategy(fakeEngine, EscapeStrategy{Open: "<", Close: ">", Escape: `\>`})
+	quoted, err := QuoteIdentifier(fakeEngine, "a>b")
+	a.NoError(err)
+	a.Equal(`<a\>b>`, quoted)
+	quoted, err = QuoteIdentifier(fakeEngine, "a<b")
+	a.NoError(err)
+	a.Equal("<a<b>", quoted)
+	a.Panics(func() {
+		RegisterEscapeStrategy(fakeEngine, EscapeStrategy{Open: `"`, Close: `"`})
+	})
+	a.Panics(func() {
+		RegisterEscapeStrategy(storepb.Engine(-2), EscapeStrategy{Open: "<"})
+	})
+
+	// The closing delimiter is doubled by default.
+	a.Equal("[a]]b]", EscapeStrategy{Open: "[", Close: "]"}.QuoteIdentifier("a]b"))
+}
+
+// unregisterEscapeStrategy removes the identifier escaping rules of the engine registered by the test.
+func unregisterEscapeStrategy(engine storepb.Engine) {
+	mux.Lock()
+	defer mux.Unlock()
+	delete(escapeStrategies, engine)
+}
@@ -24,6 +24,7 @@ var (
 	spans                   = make(map[storepb.Engine]GetQuerySpanFunc)
 	transformDMLToSelect    = make(map[storepb.Engine]TransformDMLToSelectFunc)
 	generateRestoreSQL      = make(map[storepb.Engine]GenerateRestoreSQLFunc)
+	affectedRowsChecks      = make(map[storepb.Engine]GenerateAffectedRowsCheckFunc)
 )
 
 type ValidateSQLForEditorFunc func(string) (bool, bool, error)
@@ -41,6 +42,9 @@ type TransformDMLToSelectFunc func(ctx context.Context, tCtx TransformContext, s
 
 type GenerateRestoreSQLFunc func(ctx context.Context, rCtx RestoreContext, statement string, backupDatabase string, backupTable string, originalDatabase string, originalTable string) (string, error)
 
+// GenerateAffectedRowsCheckFunc is the interface of generating the queries checking that a backup statement captures the rows affected by its DML.
+type GenerateAffectedRowsCheckFunc func(cCtx AffectedRowsCheckContext, statement BackupStatement) (*AffectedRowsCheck, error)
+
 func RegisterQueryValidator(engine storepb.Engine, f ValidateSQLForEditorFunc) {
 	mux.Lock()
 	defer mux.Unlock()
@@ -231,6 +235,26 @@ func GenerateRestoreSQL(ctx context.Context, engine storepb.Engine, rCtx Restore
 	return f(ctx, rCtx, statement, backupDatabase, backupTable, originalDatabase, originalTable)
 }
 
+// RegisterGenerateAffectedRowsCheck registers the generateAffectedRowsCheck function for the engine.
+func RegisterGenerateAffectedRowsCheck(engine storepb.Engine, f GenerateAffectedRowsCheckFunc) {
+	mux.Lock()
+	defer mux.Unlock()
+	if _, dup := affectedRowsChecks[engine]; dup {
+		panic(fmt.Sprintf("Register called twice %s", engine))
+	}
+	affectedRowsChecks[engine] = f
+}
+
+// GenerateAffectedRowsCheck generates the queries checking on a database that the backup statement transformed from a DML
+// captures exactly the rows the DML changes. It's the conformance contract of the transformations of the engines.
+func GenerateAffectedRowsCheck(engine storepb.Engine, cCtx AffectedRowsCheckContext, statement BackupStatement) (*AffectedRowsCheck, error) {
+	f, ok := affectedRowsChecks[engine]
+	if !ok {
+		return nil, errors.Errorf("engine %s is not supported", engine)
+	}
+	return f(cCtx, statement)
+}
+
 type ChangeSummary struct {
 	ChangedResources *model.ChangedResources
 	SampleDMLS       []string
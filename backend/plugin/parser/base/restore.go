@@ -1,6 +1,42 @@
 package base
 
+// RestoreConflictStrategy is how the restore handles the backup rows conflicting with the current rows on the keys.
+type RestoreConflictStrategy string
+
+const (
+	// RestoreConflictDefault restores the rows of UPDATE by overwriting the updated columns, and fails the restore of DELETE on conflict.
+	RestoreConflictDefault RestoreConflictStrategy = ""
+	// RestoreConflictOverwrite overwrites all columns of the conflicting rows with the backup rows.
+	RestoreConflictOverwrite RestoreConflictStrategy = "OVERWRITE"
+	// RestoreConflictSkip keeps the conflicting rows and skips the backup rows.
+	RestoreConflictSkip RestoreConflictStrategy = "SKIP"
+	// RestoreConflictError fails the restore on conflict.
+	RestoreConflictError RestoreConflictStrategy = "ERROR"
+)
+
 type RestoreContext struct {
 	InstanceID              string
 	GetDatabaseMetadataFunc GetDatabaseMetadataFunc
+	// ConflictStrategy is how the restore handles the backup rows conflicting with the current rows.
+	ConflictStrategy RestoreConflictStrategy
+	// BackupColumns are the columns of the original table at backup time. If set, the restore supplies the defaults at backup time
+	// for the excluded columns, and skips the columns added after the backup. Empty restores all the current columns from the backup.
+	BackupColumns []RestoreColumn
+	// TargetDatabase is the database the rows are restored into instead of the original database, e.g. a clone or a staging database
+	// on the same instance. Its table must have the restored columns of the original table. Empty restores into the original database.
+	TargetDatabase string
+	// VersionColumn is the version or timestamp column of the original table, e.g. version or updated_at. If set, the restore only
+	// overwrites the current rows whose versions haven't advanced past the backup, so that the newer changes are not clobbered.
+	VersionColumn string
+}
+
+// RestoreColumn is a column of the original table at backup time.
+type RestoreColumn struct {
+	Name string
+	// Default is the default expression of the column at backup time, or empty if it has none.
+	Default string
+	// Excluded is whether the values of the column were excluded from the backup.
+	Excluded bool
+	// Collation is the explicit collation of the column at backup time, or empty if it used the default collation.
+	Collation string
 }
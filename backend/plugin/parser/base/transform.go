@@ -4,4 +4,10 @@ type TransformContext struct {
 	Version                 any
 	InstanceID              string
 	GetDatabaseMetadataFunc GetDatabaseMetadataFunc
+	// LockRows appends a row locking clause to the backup statements so that the backed up rows
+	// stay locked until the transaction ends. Only supported by Postgres.
+	LockRows bool
+	// AfterImage sets the SET clauses of the UPDATE statements in the backup statements, so that the new values
+	// of the backed up rows can be computed from the backup tables. Only supported by Postgres.
+	AfterImage bool
 }
@@ -0,0 +1,32 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func TestGenerateAffectedRowsCheck(t *testing.T) {
+	a := require.New(t)
+	cCtx := base.AffectedRowsCheckContext{
+		SourceDatabase: "db",
+		BackupDatabase: "bbdataarchive",
+		SnapshotTable:  "_snapshot_t",
+		Columns:        []string{"id", "a"},
+	}
+	statement := base.BackupStatement{SourceTableName: "t", TargetTableName: "_0_t"}
+	check, err := base.GenerateAffectedRowsCheck(storepb.Engine_MYSQL, cCtx, statement)
+	a.NoError(err)
+	a.Equal("CREATE TABLE `bbdataarchive`.`_snapshot_t` AS SELECT * FROM `db`.`t`;", check.Snapshot)
+	a.Equal("SELECT COUNT(*) FROM `bbdataarchive`.`_snapshot_t` s WHERE NOT EXISTS (SELECT 1 FROM `db`.`t` c WHERE c.`id` <=> s.`id` AND c.`a` <=> s.`a`) AND NOT EXISTS (SELECT 1 FROM `bbdataarchive`.`_0_t` b WHERE b.`id` <=> s.`id` AND b.`a` <=> s.`a`);", check.Missing)
+	a.Equal("SELECT COUNT(*) FROM `bbdataarchive`.`_0_t` b WHERE NOT EXISTS (SELECT 1 FROM `bbdataarchive`.`_snapshot_t` s WHERE s.`id` <=> b.`id` AND s.`a` <=> b.`a`);", check.Extra)
+	a.Equal("SELECT COUNT(*) FROM `bbdataarchive`.`_0_t` b WHERE EXISTS (SELECT 1 FROM `db`.`t` c WHERE c.`id` <=> b.`id` AND c.`a` <=> b.`a`);", check.Unchanged)
+	a.Equal("DROP TABLE IF EXISTS `bbdataarchive`.`_snapshot_t`;", check.Cleanup)
+
+	cCtx.Columns = nil
+	_, err = base.GenerateAffectedRowsCheck(storepb.Engine_MYSQL, cCtx, statement)
+	a.ErrorContains(err, "no columns to compare")
+}
@@ -3,6 +3,8 @@ package mysql
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/antlr4-go/antlr/v4"
@@ -53,6 +55,8 @@ type statementInfo struct {
 	statement string
 	tree      antlr.ParserRuleContext
 	table     *TableReference
+	// joined is true for the multi-table statements, where the join may match a row of the table multiple times.
+	joined bool
 }
 
 func prepareTransformation(databaseName, statement string) ([]statementInfo, error) {
@@ -100,24 +104,36 @@ func generateSQL(ctx context.Context, tCtx base.TransformContext, statementInfoL
 		targetTable, _ = common.TruncateString(targetTable, maxTableNameLength)
 		// If enforce_gtid_consistency = true on MySQL 5.6+, we cannot run CREATE TABLE .. AS SELECT.
 		// So we need to create the table first and then run INSERT INTO .. SELECT.
+		// CREATE TABLE .. LIKE also keeps the SRID attributes of the spatial columns, which CREATE TABLE .. AS SELECT drops.
 		var buf strings.Builder
-		if _, err := buf.WriteString(fmt.Sprintf("CREATE TABLE `%s`.`%s` LIKE `%s`.`%s`;\n", databaseName, targetTable, table.Database, table.Table)); err != nil {
+		if _, err := buf.WriteString(fmt.Sprintf("CREATE TABLE %s.%s LIKE %s.%s;\n", quoteIdentifier(databaseName), quoteIdentifier(targetTable), quoteIdentifier(table.Database), quoteIdentifier(table.Table))); err != nil {
 			return nil, errors.Wrap(err, "failed to write create table statement")
 		}
-		generatedColumns, normalColumns, err := classifyColumns(ctx, tCtx.GetDatabaseMetadataFunc, tCtx.InstanceID, table)
+		columns, err := classifyColumns(ctx, tCtx.GetDatabaseMetadataFunc, tCtx.InstanceID, table)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to classify columns")
 		}
+		normalColumns := columns.normal
 		tableNameOrAlias := table.Table
 		if len(table.Alias) > 0 {
 			tableNameOrAlias = table.Alias
 		}
-		if len(generatedColumns) == 0 {
-			if _, err := buf.WriteString(fmt.Sprintf("INSERT INTO `%s`.`%s` SELECT `%s`.* FROM ", databaseName, targetTable, tableNameOrAlias)); err != nil {
+		// The rows matched multiple times by the join are backed up once, or they violate the keys of the backup table.
+		selectKeyword := "SELECT"
+		if statementInfo.joined {
+			selectKeyword = "SELECT DISTINCT"
+		}
+		// The subqueries of the statement may reference the common table expressions.
+		if withClause := getWithClause(statementInfo.tree); withClause != "" {
+			selectKeyword = fmt.Sprintf("%s %s", withClause, selectKeyword)
+		}
+		// SELECT * skips the hidden columns, so they are listed explicitly to back up the rows completely.
+		if len(columns.generated) == 0 && len(columns.hidden) == 0 {
+			if _, err := buf.WriteString(fmt.Sprintf("INSERT INTO %s.%s %s %s.* FROM ", quoteIdentifier(databaseName), quoteIdentifier(targetTable), selectKeyword, quoteIdentifier(tableNameOrAlias))); err != nil {
 				return nil, errors.Wrap(err, "failed to write insert into statement")
 			}
 		} else {
-			if _, err := buf.WriteString(fmt.Sprintf("INSERT INTO `%s`.`%s` (", databaseName, targetTable)); err != nil {
+			if _, err := buf.WriteString(fmt.Sprintf("INSERT INTO %s.%s (", quoteIdentifier(databaseName), quoteIdentifier(targetTable))); err != nil {
 				return nil, errors.Wrap(err, "failed to write insert into statement")
 			}
 			for i, column := range normalColumns {
@@ -126,11 +142,11 @@ func generateSQL(ctx context.Context, tCtx base.TransformContext, statementInfoL
 						return nil, errors.Wrap(err, "failed to write comma")
 					}
 				}
-				if _, err := buf.WriteString(fmt.Sprintf("`%s`", column)); err != nil {
+				if _, err := buf.WriteString(quoteIdentifier(column)); err != nil {
 					return nil, errors.Wrap(err, "failed to write column")
 				}
 			}
-			if _, err := buf.WriteString(") SELECT "); err != nil {
+			if _, err := buf.WriteString(fmt.Sprintf(") %s ", selectKeyword)); err != nil {
 				return nil, errors.Wrap(err, "failed to write select")
 			}
 			for i, column := range normalColumns {
@@ -139,7 +155,7 @@ func generateSQL(ctx context.Context, tCtx base.TransformContext, statementInfoL
 						return nil, errors.Wrap(err, "failed to write comma")
 					}
 				}
-				if _, err := buf.WriteString(fmt.Sprintf("`%s`.`%s`", tableNameOrAlias, column)); err != nil {
+				if _, err := buf.WriteString(fmt.Sprintf("%s.%s", quoteIdentifier(tableNameOrAlias), quoteIdentifier(column))); err != nil {
 					return nil, errors.Wrap(err, "failed to write column")
 				}
 			}
@@ -153,10 +169,13 @@ func generateSQL(ctx context.Context, tCtx base.TransformContext, statementInfoL
 		if err := buf.WriteByte(';'); err != nil {
 			return nil, errors.Wrap(err, "failed to write semicolon")
 		}
+		uncertainty, uncertaintyReason := getUncertainty(statementInfo.tree)
 		result = append(result, base.BackupStatement{
-			Statement:       buf.String(),
-			SourceTableName: table.Table,
-			TargetTableName: targetTable,
+			Statement:         buf.String(),
+			SourceTableName:   table.Table,
+			TargetTableName:   targetTable,
+			Uncertainty:       uncertainty,
+			UncertaintyReason: uncertaintyReason,
 			StartPosition: &store.Position{
 				Line:   int32(statementInfo.tree.GetStart().GetLine()),
 				Column: int32(statementInfo.tree.GetStart().GetColumn()),
@@ -170,36 +189,116 @@ func generateSQL(ctx context.Context, tCtx base.TransformContext, statementInfoL
 	return result, nil
 }
 
-func classifyColumns(ctx context.Context, getDatabaseMetadataFunc base.GetDatabaseMetadataFunc, instanceID string, table *TableReference) ([]string, []string, error) {
+// quoteIdentifier quotes the identifier by backticks, so that the reserved words and the special characters can be used as names.
+func quoteIdentifier(identifier string) string {
+	return escapeStrategy.QuoteIdentifier(identifier)
+}
+
+// tableColumns are the columns of a table classified by how they can be copied.
+type tableColumns struct {
+	// generated are the generated columns, which cannot be written.
+	generated []string
+	// normal are the columns other than the generated ones, including the hidden ones.
+	normal []string
+	// hidden are the invisible columns, which are skipped by SELECT * and INSERT without column list.
+	hidden []string
+	// key are the columns of the primary key and the unique indexes, which match the rows on restore.
+	key []string
+}
+
+func classifyColumns(ctx context.Context, getDatabaseMetadataFunc base.GetDatabaseMetadataFunc, instanceID string, table *TableReference) (*tableColumns, error) {
 	if getDatabaseMetadataFunc == nil {
-		return nil, nil, errors.New("GetDatabaseMetadataFunc is not set")
+		return nil, errors.New("GetDatabaseMetadataFunc is not set")
 	}
 
 	_, metadata, err := getDatabaseMetadataFunc(ctx, instanceID, table.Database)
 	if err != nil {
-		return nil, nil, errors.Wrapf(err, "failed to get database metadata for InstanceID %q, Database %q", instanceID, table.Database)
+		return nil, errors.Wrapf(err, "failed to get database metadata for InstanceID %q, Database %q", instanceID, table.Database)
 	}
 
 	schemaMetadata := metadata.GetSchema("")
 	if schemaMetadata == nil {
-		return nil, nil, errors.New("failed to get schema metadata")
+		return nil, errors.New("failed to get schema metadata")
 	}
 
 	tableMetadata := schemaMetadata.GetTable(table.Table)
 	if tableMetadata == nil {
-		return nil, nil, errors.New("failed to get table metadata for table " + table.Table)
+		return nil, errors.New("failed to get table metadata for table " + table.Table)
 	}
 
-	var generatedColumns, normalColumns []string
+	columns := &tableColumns{}
 	for _, column := range tableMetadata.GetColumns() {
 		if column.GetGeneration() != nil {
-			generatedColumns = append(generatedColumns, column.GetName())
-		} else {
-			normalColumns = append(normalColumns, column.GetName())
+			columns.generated = append(columns.generated, column.GetName())
+			continue
+		}
+		columns.normal = append(columns.normal, column.GetName())
+		if column.GetHidden() {
+			columns.hidden = append(columns.hidden, column.GetName())
 		}
 	}
+	for _, index := range tableMetadata.GetProto().GetIndexes() {
+		if index.GetPrimary() || index.GetUnique() {
+			columns.key = append(columns.key, index.GetExpressions()...)
+		}
+	}
+
+	return columns, nil
+}
+
+// getRestoreColumns returns the columns written on restore.
+// The hidden columns are not written unless they match the rows, e.g. the generated invisible primary key.
+func (c *tableColumns) getRestoreColumns() []string {
+	var result []string
+	for _, column := range c.normal {
+		if slices.Contains(c.hidden, column) && !slices.Contains(c.key, column) {
+			continue
+		}
+		result = append(result, column)
+	}
+	return result
+}
+
+// volatileFunctionRegexp matches the calls of the functions returning different values on each call.
+var volatileFunctionRegexp = regexp.MustCompile(`(?i)\b(RAND|UUID|UUID_SHORT|SYSDATE)\s*\(`)
+
+// getUncertainty returns how uncertain the backup statement selects exactly the rows affected by the DML, and the reason.
+func getUncertainty(tree antlr.ParserRuleContext) (float64, string) {
+	var whereClause parser.IWhereClauseContext
+	var limited bool
+	switch ctx := tree.(type) {
+	case *parser.UpdateStatementContext:
+		whereClause = ctx.WhereClause()
+		limited = ctx.SimpleLimitClause() != nil && ctx.OrderClause() == nil
+	case *parser.DeleteStatementContext:
+		whereClause = ctx.WhereClause()
+		limited = ctx.SimpleLimitClause() != nil && ctx.OrderClause() == nil
+	default:
+		return 0, ""
+	}
+	if limited {
+		return 1, "LIMIT without ORDER BY affects arbitrary rows"
+	}
+	if whereClause != nil && volatileFunctionRegexp.MatchString(whereClause.GetParser().GetTokenStream().GetTextFromRuleContext(whereClause)) {
+		return 1, "the WHERE clause calls volatile functions"
+	}
+	return 0, ""
+}
 
-	return generatedColumns, normalColumns, nil
+// getWithClause returns the WITH clause of the DML, which the subqueries of the DML may reference.
+func getWithClause(tree antlr.ParserRuleContext) string {
+	var withClause parser.IWithClauseContext
+	var tokens antlr.TokenStream
+	switch ctx := tree.(type) {
+	case *parser.UpdateStatementContext:
+		withClause, tokens = ctx.WithClause(), ctx.GetParser().GetTokenStream()
+	case *parser.DeleteStatementContext:
+		withClause, tokens = ctx.WithClause(), ctx.GetParser().GetTokenStream()
+	}
+	if withClause == nil {
+		return ""
+	}
+	return tokens.GetTextFromRuleContext(withClause)
 }
 
 func extractSuffixSelectStatement(tree antlr.Tree, buf *strings.Builder) error {
@@ -434,6 +533,7 @@ func (l *tableReferenceListener) EnterUpdateStatement(ctx *parser.UpdateStatemen
 			statement: ctx.GetParser().GetTokenStream().GetTextFromRuleContext(ctx),
 			tree:      ctx,
 			table:     singleTable,
+			joined:    len(singleTables.singleTables) > 1 || singleTables.derived,
 		})
 	}
 }
@@ -443,13 +543,25 @@ type singleTableListener struct {
 
 	databaseName string
 	singleTables map[string]*TableReference
-	err          error
+	// derived is true if the statement joins the derived tables.
+	derived bool
+	err     error
+}
+
+func (l *singleTableListener) EnterDerivedTable(ctx *parser.DerivedTableContext) {
+	if !isInSubquery(ctx) {
+		l.derived = true
+	}
 }
 
 func (l *singleTableListener) EnterSingleTable(ctx *parser.SingleTableContext) {
 	if l.err != nil {
 		return
 	}
+	// The tables of the derived tables are read by the subqueries, and they are not the targets of the statement.
+	if isInSubquery(ctx) {
+		return
+	}
 	database, tableName := NormalizeMySQLTableRef(ctx.TableRef())
 	if len(database) > 0 && database != l.databaseName {
 		l.err = errors.Errorf("database is not matched: %s != %s", database, l.databaseName)
@@ -470,6 +582,16 @@ func (l *singleTableListener) EnterSingleTable(ctx *parser.SingleTableContext) {
 	}
 }
 
+// isInSubquery returns whether the tree is in a subquery.
+func isInSubquery(tree antlr.Tree) bool {
+	for parent := tree.GetParent(); parent != nil; parent = parent.GetParent() {
+		if _, ok := parent.(*parser.SubqueryContext); ok {
+			return true
+		}
+	}
+	return false
+}
+
 type updateTableListener struct {
 	*parser.BaseMySQLParserListener
 
@@ -131,6 +131,52 @@ func fixedMockDatabaseMetadataGetter(_ context.Context, _ string, database strin
 							},
 						},
 					},
+					{
+						Name: "t_invisible",
+						Columns: []*store.ColumnMetadata{
+							{
+								Name: "a",
+							},
+							{
+								Name:   "b_invisible",
+								Hidden: true,
+							},
+							{
+								Name:   "my_row_id",
+								Hidden: true,
+							},
+						},
+						Indexes: []*store.IndexMetadata{
+							{
+								Name:        "PRIMARY",
+								Expressions: []string{"my_row_id"},
+								Primary:     true,
+								Unique:      true,
+							},
+						},
+					},
+					{
+						Name: "order",
+						Columns: []*store.ColumnMetadata{
+							{
+								Name: "select",
+							},
+							{
+								Name: "desc",
+								Generation: &store.GenerationMetadata{
+									Expression: "`select` + 1",
+								},
+							},
+						},
+					},
+					{
+						Name: "select",
+						Columns: []*store.ColumnMetadata{
+							{
+								Name: "from",
+							},
+						},
+					},
 					{
 						Name: "test2",
 						Columns: []*store.ColumnMetadata{
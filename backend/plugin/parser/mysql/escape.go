@@ -0,0 +1,17 @@
+package mysql
+
+import (
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+// escapeStrategy quotes the identifiers by backticks, and the backticks in them are doubled.
+var escapeStrategy = base.EscapeStrategy{Open: "`", Close: "`"}
+
+func init() {
+	base.RegisterEscapeStrategy(storepb.Engine_MYSQL, escapeStrategy)
+	base.RegisterEscapeStrategy(storepb.Engine_MARIADB, escapeStrategy)
+	base.RegisterEscapeStrategy(storepb.Engine_OCEANBASE, escapeStrategy)
+	base.RegisterEscapeStrategy(storepb.Engine_STARROCKS, escapeStrategy)
+	base.RegisterEscapeStrategy(storepb.Engine_DORIS, escapeStrategy)
+}
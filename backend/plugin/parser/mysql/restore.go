@@ -3,6 +3,7 @@ package mysql
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/antlr4-go/antlr/v4"
@@ -27,7 +28,7 @@ func GenerateRestoreSQL(ctx context.Context, rCtx base.RestoreContext, statement
 		return "", errors.Errorf("expected 1 statement, but got %d", len(parseResult))
 	}
 
-	generatedColumns, normalColumns, err := classifyColumns(ctx, rCtx.GetDatabaseMetadataFunc, rCtx.InstanceID, &TableReference{
+	columns, err := classifyColumns(ctx, rCtx.GetDatabaseMetadataFunc, rCtx.InstanceID, &TableReference{
 		Database: originalDatabase,
 		Table:    originalTable,
 	})
@@ -35,6 +36,20 @@ func GenerateRestoreSQL(ctx context.Context, rCtx base.RestoreContext, statement
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to classify columns for %s.%s", originalDatabase, originalTable)
 	}
+	targetDatabase := originalDatabase
+	if rCtx.TargetDatabase != "" && rCtx.TargetDatabase != originalDatabase {
+		targetColumns, err := classifyColumns(ctx, rCtx.GetDatabaseMetadataFunc, rCtx.InstanceID, &TableReference{
+			Database: rCtx.TargetDatabase,
+			Table:    originalTable,
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to classify columns for %s.%s", rCtx.TargetDatabase, originalTable)
+		}
+		if err := checkRestoreTargetColumns(columns, targetColumns); err != nil {
+			return "", errors.Wrapf(err, "table %s.%s is incompatible with %s.%s", rCtx.TargetDatabase, originalTable, originalDatabase, originalTable)
+		}
+		targetDatabase = rCtx.TargetDatabase
+	}
 
 	g := &generator{
 		ctx:              ctx,
@@ -43,8 +58,8 @@ func GenerateRestoreSQL(ctx context.Context, rCtx base.RestoreContext, statement
 		backupTable:      backupTable,
 		originalDatabase: originalDatabase,
 		originalTable:    originalTable,
-		generatedColumns: generatedColumns,
-		normalColumns:    normalColumns,
+		targetDatabase:   targetDatabase,
+		columns:          columns,
 	}
 	var buf strings.Builder
 	antlr.ParseTreeWalkerDefault.Walk(g, parseResult[0].Tree)
@@ -68,10 +83,11 @@ type generator struct {
 	backupTable      string
 	originalDatabase string
 	originalTable    string
-	generatedColumns []string
-	normalColumns    []string
-	result           string
-	err              error
+	// targetDatabase is the database the rows are restored into.
+	targetDatabase string
+	columns        *tableColumns
+	result         string
+	err            error
 }
 
 func (g *generator) EnterDeleteStatement(ctx *parser.DeleteStatementContext) {
@@ -79,16 +95,7 @@ func (g *generator) EnterDeleteStatement(ctx *parser.DeleteStatementContext) {
 		return
 	}
 
-	if len(g.generatedColumns) == 0 {
-		g.result = fmt.Sprintf("INSERT INTO `%s`.`%s` SELECT * FROM `%s`.`%s`;", g.originalDatabase, g.originalTable, g.backupDatabase, g.backupTable)
-	} else {
-		var quotedColumns []string
-		for _, column := range g.normalColumns {
-			quotedColumns = append(quotedColumns, fmt.Sprintf("`%s`", column))
-		}
-		quotedColumnList := strings.Join(quotedColumns, ", ")
-		g.result = fmt.Sprintf("INSERT INTO `%s`.`%s` (%s) SELECT %s FROM `%s`.`%s`;", g.originalDatabase, g.originalTable, quotedColumnList, quotedColumnList, g.backupDatabase, g.backupTable)
-	}
+	g.result, g.err = g.getInsertStatement(nil)
 }
 
 func (g *generator) EnterUpdateStatement(ctx *parser.UpdateStatementContext) {
@@ -117,42 +124,84 @@ func (g *generator) EnterUpdateStatement(ctx *parser.UpdateStatementContext) {
 
 	antlr.ParseTreeWalkerDefault.Walk(setFields, ctx.UpdateList())
 
+	g.result, g.err = g.getInsertStatement(setFields.result)
+}
+
+// getInsertStatement returns the statement inserting the backup rows into the original table.
+// The rows conflicting with the current rows are resolved by the conflict strategy, and the updated columns are overwritten by default.
+func (g *generator) getInsertStatement(updatedColumns []string) (string, error) {
+	insertKeyword := "INSERT"
+	switch g.rCtx.ConflictStrategy {
+	case base.RestoreConflictDefault:
+	case base.RestoreConflictOverwrite:
+		updatedColumns = g.columns.getRestoreColumns()
+	case base.RestoreConflictSkip:
+		// INSERT IGNORE skips the rows conflicting with the primary key or the unique indexes.
+		insertKeyword, updatedColumns = "INSERT IGNORE", nil
+	case base.RestoreConflictError:
+		updatedColumns = nil
+	default:
+		return "", errors.Errorf("unsupported restore conflict strategy %q", g.rCtx.ConflictStrategy)
+	}
+
 	var buf strings.Builder
-	if len(g.generatedColumns) == 0 {
-		if _, err := fmt.Fprintf(&buf, "INSERT INTO `%s`.`%s` SELECT * FROM `%s`.`%s` ON DUPLICATE KEY UPDATE ", g.originalDatabase, g.originalTable, g.backupDatabase, g.backupTable); err != nil {
-			g.err = err
-			return
+	// The columns are listed for the target database since the columns of its table may be in a different order.
+	if len(g.columns.generated) == 0 && len(g.columns.hidden) == 0 && g.targetDatabase == g.originalDatabase {
+		if _, err := fmt.Fprintf(&buf, "%s INTO `%s`.`%s` SELECT * FROM `%s`.`%s`", insertKeyword, g.targetDatabase, g.originalTable, g.backupDatabase, g.backupTable); err != nil {
+			return "", err
 		}
 	} else {
 		var quotedColumns []string
-		for _, column := range g.normalColumns {
+		for _, column := range g.columns.getRestoreColumns() {
 			quotedColumns = append(quotedColumns, fmt.Sprintf("`%s`", column))
 		}
 		quotedColumnList := strings.Join(quotedColumns, ", ")
-		if _, err := fmt.Fprintf(&buf, "INSERT INTO `%s`.`%s` (%s) SELECT %s FROM `%s`.`%s` ON DUPLICATE KEY UPDATE ", g.originalDatabase, g.originalTable, quotedColumnList, quotedColumnList, g.backupDatabase, g.backupTable); err != nil {
-			g.err = err
-			return
+		if _, err := fmt.Fprintf(&buf, "%s INTO `%s`.`%s` (%s) SELECT %s FROM `%s`.`%s`", insertKeyword, g.targetDatabase, g.originalTable, quotedColumnList, quotedColumnList, g.backupDatabase, g.backupTable); err != nil {
+			return "", err
 		}
 	}
 
-	for i, field := range setFields.result {
-		if i > 0 {
-			if _, err := buf.WriteString(", "); err != nil {
-				g.err = err
-				return
-			}
+	versionColumn := g.rCtx.VersionColumn
+	if !slices.Contains(g.columns.getRestoreColumns(), versionColumn) {
+		versionColumn = ""
+	}
+	if versionColumn != "" && slices.Contains(updatedColumns, versionColumn) {
+		// The assignments are evaluated from left to right, so the version column is assigned last to keep comparing the current version.
+		updatedColumns = append(slices.DeleteFunc(slices.Clone(updatedColumns), func(column string) bool { return column == versionColumn }), versionColumn)
+	}
+	for i, column := range updatedColumns {
+		separator := ", "
+		if i == 0 {
+			separator = " ON DUPLICATE KEY UPDATE "
 		}
-
-		if _, err := fmt.Fprintf(&buf, "`%s` = VALUES(`%s`)", field, field); err != nil {
-			g.err = err
-			return
+		value := fmt.Sprintf("VALUES(`%s`)", column)
+		if versionColumn != "" {
+			// The rows changed since the backup have advanced their versions past the backup, and keep their current values.
+			value = fmt.Sprintf("IF(`%s` <= VALUES(`%s`), VALUES(`%s`), `%s`)", versionColumn, versionColumn, column, column)
+		}
+		if _, err := fmt.Fprintf(&buf, "%s`%s` = %s", separator, column, value); err != nil {
+			return "", err
 		}
 	}
 	if _, err := buf.WriteString(";"); err != nil {
-		g.err = err
-		return
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// checkRestoreTargetColumns checks that the table in the target database has the restored columns of the original table
+// as writable columns. The other columns of the target table take their defaults.
+func checkRestoreTargetColumns(original *tableColumns, target *tableColumns) error {
+	var missing []string
+	for _, column := range original.getRestoreColumns() {
+		if !slices.Contains(target.normal, column) {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("the target table has no writable columns %s", strings.Join(missing, ", "))
 	}
-	g.result = buf.String()
+	return nil
 }
 
 type setFieldListener struct {
@@ -10,6 +10,8 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	"github.com/bytebase/bytebase/backend/store/model"
+	"github.com/bytebase/bytebase/proto/generated-go/store"
 )
 
 type restoreCase struct {
@@ -18,6 +20,7 @@ type restoreCase struct {
 	BackupTable      string
 	OriginalDatabase string
 	OriginalTable    string
+	ConflictStrategy string `yaml:"conflictstrategy,omitempty"`
 	Result           string
 }
 
@@ -43,6 +46,7 @@ func TestRestore(t *testing.T) {
 	for i, t := range tests {
 		result, err := GenerateRestoreSQL(context.Background(), base.RestoreContext{
 			GetDatabaseMetadataFunc: fixedMockDatabaseMetadataGetter,
+			ConflictStrategy:        base.RestoreConflictStrategy(t.ConflictStrategy),
 		}, t.Input, t.BackupDatabase, t.BackupTable, t.OriginalDatabase, t.OriginalTable)
 		a.NoError(err)
 
@@ -59,3 +63,78 @@ func TestRestore(t *testing.T) {
 		a.NoError(err)
 	}
 }
+
+func TestRestoreTargetDatabase(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	getDatabaseMetadata := func(ctx context.Context, instanceID string, database string) (string, *model.DatabaseMetadata, error) {
+		if database != "staging_legacy" {
+			return fixedMockDatabaseMetadataGetter(ctx, instanceID, database)
+		}
+		// The table of the legacy staging database lacks column c.
+		return database, model.NewDatabaseMetadata(&store.DatabaseSchemaMetadata{
+			Name: database,
+			Schemas: []*store.SchemaMetadata{
+				{
+					Tables: []*store.TableMetadata{
+						{Name: "t1", Columns: []*store.ColumnMetadata{{Name: "a"}, {Name: "b"}}},
+					},
+				},
+			},
+		}), nil
+	}
+
+	result, err := GenerateRestoreSQL(ctx, base.RestoreContext{
+		GetDatabaseMetadataFunc: getDatabaseMetadata,
+		TargetDatabase:          "staging",
+	}, "DELETE FROM t1 WHERE a = 1;", "bbarchive", "prefix_1_t1", "db", "t1")
+	a.NoError(err)
+	a.Equal("/*\nOriginal SQL:\nDELETE FROM t1 WHERE a = 1;\n*/\nINSERT INTO `staging`.`t1` (`a`, `b`, `c`) SELECT `a`, `b`, `c` FROM `bbarchive`.`prefix_1_t1`;", result)
+
+	// The target database is the original database.
+	result, err = GenerateRestoreSQL(ctx, base.RestoreContext{
+		GetDatabaseMetadataFunc: getDatabaseMetadata,
+		TargetDatabase:          "db",
+	}, "DELETE FROM t1 WHERE a = 1;", "bbarchive", "prefix_1_t1", "db", "t1")
+	a.NoError(err)
+	a.Equal("/*\nOriginal SQL:\nDELETE FROM t1 WHERE a = 1;\n*/\nINSERT INTO `db`.`t1` SELECT * FROM `bbarchive`.`prefix_1_t1`;", result)
+
+	_, err = GenerateRestoreSQL(ctx, base.RestoreContext{
+		GetDatabaseMetadataFunc: getDatabaseMetadata,
+		TargetDatabase:          "staging_legacy",
+	}, "DELETE FROM t1 WHERE a = 1;", "bbarchive", "prefix_1_t1", "db", "t1")
+	a.ErrorContains(err, "the target table has no writable columns c")
+}
+
+func TestRestoreVersionColumn(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	getDatabaseMetadata := func(_ context.Context, _ string, database string) (string, *model.DatabaseMetadata, error) {
+		return database, model.NewDatabaseMetadata(&store.DatabaseSchemaMetadata{
+			Name: database,
+			Schemas: []*store.SchemaMetadata{
+				{
+					Tables: []*store.TableMetadata{
+						{Name: "t", Columns: []*store.ColumnMetadata{{Name: "id"}, {Name: "a"}, {Name: "version"}}},
+					},
+				},
+			},
+		}), nil
+	}
+
+	// The rows advanced past the backup keep their current values, and the version column is assigned last.
+	result, err := GenerateRestoreSQL(ctx, base.RestoreContext{
+		GetDatabaseMetadataFunc: getDatabaseMetadata,
+		VersionColumn:           "version",
+	}, "UPDATE t SET version = version + 1, a = 1;", "bbarchive", "prefix_1_t", "db", "t")
+	a.NoError(err)
+	a.Equal("/*\nOriginal SQL:\nUPDATE t SET version = version + 1, a = 1;\n*/\nINSERT INTO `db`.`t` SELECT * FROM `bbarchive`.`prefix_1_t` ON DUPLICATE KEY UPDATE `a` = IF(`version` <= VALUES(`version`), VALUES(`a`), `a`), `version` = IF(`version` <= VALUES(`version`), VALUES(`version`), `version`);", result)
+
+	// The version column not in the table is ignored.
+	result, err = GenerateRestoreSQL(ctx, base.RestoreContext{
+		GetDatabaseMetadataFunc: getDatabaseMetadata,
+		VersionColumn:           "updated_at",
+	}, "UPDATE t SET a = 1;", "bbarchive", "prefix_1_t", "db", "t")
+	a.NoError(err)
+	a.Equal("/*\nOriginal SQL:\nUPDATE t SET a = 1;\n*/\nINSERT INTO `db`.`t` SELECT * FROM `bbarchive`.`prefix_1_t` ON DUPLICATE KEY UPDATE `a` = VALUES(`a`);", result)
+}
@@ -0,0 +1,45 @@
+package pg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	storebp "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func init() {
+	base.RegisterGenerateAffectedRowsCheck(storebp.Engine_POSTGRES, GenerateAffectedRowsCheck)
+}
+
+// GenerateAffectedRowsCheck generates the queries checking that the backup table of the statement holds exactly the rows changed by its DML.
+// The rows are matched by IS NOT DISTINCT FROM on the columns, since the rows with NULL values are never equal by "=".
+func GenerateAffectedRowsCheck(cCtx base.AffectedRowsCheckContext, statement base.BackupStatement) (*base.AffectedRowsCheck, error) {
+	if len(cCtx.Columns) == 0 {
+		return nil, errors.New("no columns to compare")
+	}
+	// The backup schema is in the source database, so the source table is qualified by its schema.
+	schema := statement.SourceSchema
+	if schema == "" {
+		schema = "public"
+	}
+	source := fmt.Sprintf("%s.%s", quoteIdentifier(schema), quoteIdentifier(statement.SourceTableName))
+	backup := fmt.Sprintf("%s.%s", quoteIdentifier(cCtx.BackupDatabase), quoteIdentifier(statement.TargetTableName))
+	snapshot := fmt.Sprintf("%s.%s", quoteIdentifier(cCtx.BackupDatabase), quoteIdentifier(cCtx.SnapshotTable))
+	match := func(left, right string) string {
+		var conditions []string
+		for _, column := range cCtx.Columns {
+			conditions = append(conditions, fmt.Sprintf("%s.%s IS NOT DISTINCT FROM %s.%s", left, quoteIdentifier(column), right, quoteIdentifier(column)))
+		}
+		return strings.Join(conditions, " AND ")
+	}
+	return &base.AffectedRowsCheck{
+		Snapshot:  fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM %s;", snapshot, source),
+		Missing:   fmt.Sprintf("SELECT COUNT(*) FROM %s s WHERE NOT EXISTS (SELECT 1 FROM %s c WHERE %s) AND NOT EXISTS (SELECT 1 FROM %s b WHERE %s);", snapshot, source, match("c", "s"), backup, match("b", "s")),
+		Extra:     fmt.Sprintf("SELECT COUNT(*) FROM %s b WHERE NOT EXISTS (SELECT 1 FROM %s s WHERE %s);", backup, snapshot, match("s", "b")),
+		Unchanged: fmt.Sprintf("SELECT COUNT(*) FROM %s b WHERE EXISTS (SELECT 1 FROM %s c WHERE %s);", backup, source, match("c", "b")),
+		Cleanup:   fmt.Sprintf("DROP TABLE IF EXISTS %s;", snapshot),
+	}, nil
+}
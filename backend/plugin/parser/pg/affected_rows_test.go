@@ -0,0 +1,34 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	storebp "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func TestGenerateAffectedRowsCheck(t *testing.T) {
+	a := require.New(t)
+	cCtx := base.AffectedRowsCheckContext{
+		SourceDatabase: "db",
+		BackupDatabase: "bbdataarchive",
+		SnapshotTable:  "_snapshot_t",
+		Columns:        []string{"id", "a"},
+	}
+	// The source table without the schema is in the public schema.
+	statement := base.BackupStatement{SourceTableName: "t", TargetTableName: "_0_t"}
+	check, err := base.GenerateAffectedRowsCheck(storebp.Engine_POSTGRES, cCtx, statement)
+	a.NoError(err)
+	a.Equal(`CREATE TABLE "bbdataarchive"."_snapshot_t" AS SELECT * FROM "public"."t";`, check.Snapshot)
+	a.Equal(`SELECT COUNT(*) FROM "bbdataarchive"."_snapshot_t" s WHERE NOT EXISTS (SELECT 1 FROM "public"."t" c WHERE c."id" IS NOT DISTINCT FROM s."id" AND c."a" IS NOT DISTINCT FROM s."a") AND NOT EXISTS (SELECT 1 FROM "bbdataarchive"."_0_t" b WHERE b."id" IS NOT DISTINCT FROM s."id" AND b."a" IS NOT DISTINCT FROM s."a");`, check.Missing)
+	a.Equal(`SELECT COUNT(*) FROM "bbdataarchive"."_0_t" b WHERE NOT EXISTS (SELECT 1 FROM "bbdataarchive"."_snapshot_t" s WHERE s."id" IS NOT DISTINCT FROM b."id" AND s."a" IS NOT DISTINCT FROM b."a");`, check.Extra)
+	a.Equal(`SELECT COUNT(*) FROM "bbdataarchive"."_0_t" b WHERE EXISTS (SELECT 1 FROM "public"."t" c WHERE c."id" IS NOT DISTINCT FROM b."id" AND c."a" IS NOT DISTINCT FROM b."a");`, check.Unchanged)
+	a.Equal(`DROP TABLE IF EXISTS "bbdataarchive"."_snapshot_t";`, check.Cleanup)
+
+	statement.SourceSchema = "s1"
+	check, err = base.GenerateAffectedRowsCheck(storebp.Engine_POSTGRES, cCtx, statement)
+	a.NoError(err)
+	a.Equal(`CREATE TABLE "bbdataarchive"."_snapshot_t" AS SELECT * FROM "s1"."t";`, check.Snapshot)
+}
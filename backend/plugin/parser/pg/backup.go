@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"regexp"
 	"strings"
 
 	"github.com/antlr4-go/antlr/v4"
@@ -30,14 +31,19 @@ type TableReference struct {
 
 func (t *TableReference) String() string {
 	if t.Database != "" {
-		return fmt.Sprintf(`"%s"."%s"."%s"`, t.Database, t.Schema, t.Table)
+		return fmt.Sprintf(`%s.%s.%s`, quoteIdentifier(t.Database), quoteIdentifier(t.Schema), quoteIdentifier(t.Table))
 	}
 
 	if t.Schema != "" {
-		return fmt.Sprintf(`"%s"."%s"`, t.Schema, t.Table)
+		return fmt.Sprintf(`%s.%s`, quoteIdentifier(t.Schema), quoteIdentifier(t.Table))
 	}
 
-	return fmt.Sprintf(`"%s"`, t.Table)
+	return quoteIdentifier(t.Table)
+}
+
+// quoteIdentifier quotes the identifier by double quotes, so that the reserved words and the special characters can be used as names.
+func quoteIdentifier(identifier string) string {
+	return escapeStrategy.QuoteIdentifier(identifier)
 }
 
 type statementInfo struct {
@@ -51,32 +57,53 @@ func init() {
 	base.RegisterTransformDMLToSelect(storebp.Engine_POSTGRES, TransformDMLToSelect)
 }
 
-func TransformDMLToSelect(_ context.Context, _ base.TransformContext, statement string, _ string, targetSchema string, tablePrefix string) ([]base.BackupStatement, error) {
+func TransformDMLToSelect(_ context.Context, tCtx base.TransformContext, statement string, _ string, targetSchema string, tablePrefix string) ([]base.BackupStatement, error) {
 	statementInfoList, err := prepareTransformation(statement)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to prepare transformation")
 	}
 
-	return generateSQL(statementInfoList, targetSchema, tablePrefix)
+	return generateSQL(statementInfoList, targetSchema, tablePrefix, tCtx.LockRows, tCtx.AfterImage)
 }
 
-func generateSQL(statementInfoList []statementInfo, targetSchema string, tablePrefix string) ([]base.BackupStatement, error) {
+func generateSQL(statementInfoList []statementInfo, targetSchema string, tablePrefix string, lockRows bool, afterImage bool) ([]base.BackupStatement, error) {
 	var result []base.BackupStatement
 	offsetLength := 1
 	if len(statementInfoList) > 1 {
 		offsetLength = base.GetOffsetLength(statementInfoList[len(statementInfoList)-1].offset)
 	}
 
+	targetTables := make(map[string]bool)
 	for _, info := range statementInfoList {
 		table := info.table
 		targetTable := fmt.Sprintf("%s_%0*d_%s", tablePrefix, offsetLength, info.offset, table.Table)
 		targetTable, _ = common.TruncateString(targetTable, maxTableNameLength)
+		// The data-modifying CTEs of a statement may change the same table more than once.
+		for i := 2; targetTables[targetTable]; i++ {
+			suffix := fmt.Sprintf("_%d", i)
+			targetTable, _ = common.TruncateString(fmt.Sprintf("%s_%0*d_%s", tablePrefix, offsetLength, info.offset, table.Table), maxTableNameLength-len(suffix))
+			targetTable += suffix
+		}
+		targetTables[targetTable] = true
 		var buf strings.Builder
-		if _, err := fmt.Fprintf(&buf, `CREATE TABLE "%s"."%s" AS SELECT `, targetSchema, targetTable); err != nil {
+		// CREATE TABLE AS keeps the column types with their modifiers, e.g. the SRID of geometry(Point,4326) on PostGIS.
+		if _, err := fmt.Fprintf(&buf, `CREATE TABLE %s.%s AS `, quoteIdentifier(targetSchema), quoteIdentifier(targetTable)); err != nil {
+			return nil, errors.Wrap(err, "failed to write to buffer")
+		}
+		withClause, inexactCTEs, err := getWithClause(info.tree)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get WITH clause")
+		}
+		if withClause != "" {
+			if _, err := fmt.Fprintf(&buf, "%s ", withClause); err != nil {
+				return nil, errors.Wrap(err, "failed to write to buffer")
+			}
+		}
+		if _, err := buf.WriteString("SELECT "); err != nil {
 			return nil, errors.Wrap(err, "failed to write to buffer")
 		}
 		if table.Alias != "" {
-			if _, err := fmt.Fprintf(&buf, `"%s".* `, table.Alias); err != nil {
+			if _, err := fmt.Fprintf(&buf, `%s.* `, quoteIdentifier(table.Alias)); err != nil {
 				return nil, errors.Wrap(err, "failed to write to buffer")
 			}
 		} else {
@@ -89,15 +116,38 @@ func generateSQL(statementInfoList []statementInfo, targetSchema string, tablePr
 			return nil, errors.Wrap(err, "failed to write suffix select clause")
 		}
 
+		if lockRows {
+			// Only lock the rows of the table to be backed up, not the other tables in the FROM clause.
+			lockTarget := quoteIdentifier(table.Table)
+			if table.Alias != "" {
+				lockTarget = quoteIdentifier(table.Alias)
+			}
+			if _, err := fmt.Fprintf(&buf, " FOR UPDATE OF %s", lockTarget); err != nil {
+				return nil, errors.Wrap(err, "failed to write to buffer")
+			}
+		}
+
 		if _, err := buf.WriteString(";"); err != nil {
 			return nil, errors.Wrap(err, "failed to write to buffer")
 		}
 
+		uncertainty, uncertaintyReason := getUncertainty(info.tree)
+		if uncertainty == 0 && readsCTEs(info.tree, inexactCTEs) {
+			uncertainty, uncertaintyReason = 1, "the statement reads the rows returned by data-modifying CTEs"
+		}
+		var updateSetClause, updateAlias string
+		if afterImage && withClause == "" {
+			updateSetClause, updateAlias = getUpdateSetClause(info.tree, table)
+		}
 		result = append(result, base.BackupStatement{
-			Statement:       buf.String(),
-			SourceSchema:    table.Schema,
-			SourceTableName: table.Table,
-			TargetTableName: targetTable,
+			Statement:         buf.String(),
+			SourceSchema:      table.Schema,
+			SourceTableName:   table.Table,
+			TargetTableName:   targetTable,
+			Uncertainty:       uncertainty,
+			UncertaintyReason: uncertaintyReason,
+			UpdateSetClause:   updateSetClause,
+			UpdateAlias:       updateAlias,
 			StartPosition: &storebp.Position{
 				Line:   int32(info.tree.GetStart().GetLine()),
 				Column: int32(info.tree.GetStart().GetColumn()),
@@ -112,9 +162,194 @@ func generateSQL(statementInfoList []statementInfo, targetSchema string, tablePr
 	return result, nil
 }
 
+// getUpdateSetClause returns the SET clause of the UPDATE and the name by which the SET clause refers to the target table,
+// which is the alias or the table name. It returns empty if the new values cannot be computed from the backed up rows alone,
+// i.e. the DML is not an UPDATE, or the UPDATE joins the FROM list or is in a data-modifying CTE.
+func getUpdateSetClause(tree antlr.ParserRuleContext, table *TableReference) (string, string) {
+	ctx, ok := tree.(*parser.UpdatestmtContext)
+	if !ok || ctx.From_clause() != nil || getDataModifyingCTE(ctx) != nil {
+		return "", ""
+	}
+	alias := table.Alias
+	if alias == "" {
+		alias = table.Table
+	}
+	tokens := ctx.GetParser().GetTokenStream()
+	return fmt.Sprintf("SET %s", tokens.GetTextFromRuleContext(ctx.Set_clause_list())), alias
+}
+
+// volatileFunctionRegexp matches the calls of the functions returning different values on each call.
+var volatileFunctionRegexp = regexp.MustCompile(`(?i)\b(random|gen_random_uuid|clock_timestamp|timeofday)\s*\(`)
+
+// getUncertainty returns how uncertain the backup statement selects exactly the rows affected by the DML, and the reason.
+func getUncertainty(tree antlr.ParserRuleContext) (float64, string) {
+	var where parser.IWhere_or_current_clauseContext
+	switch ctx := tree.(type) {
+	case *parser.UpdatestmtContext:
+		where = ctx.Where_or_current_clause()
+	case *parser.DeletestmtContext:
+		where = ctx.Where_or_current_clause()
+	}
+	if where == nil {
+		return 0, ""
+	}
+	if where.CURRENT_P() != nil {
+		return 1, "WHERE CURRENT OF depends on the cursor position"
+	}
+	if volatileFunctionRegexp.MatchString(where.GetParser().GetTokenStream().GetTextFromRuleContext(where)) {
+		return 1, "the WHERE clause calls volatile functions"
+	}
+	return 0, ""
+}
+
+// getWithClause returns the WITH clause of the DML, which the subqueries of the DML may reference,
+// so that the backup SELECT selects the same rows as the DML.
+// The WITH clause includes the CTEs before the data-modifying CTE of the DML, and the WITH clause of the DML itself.
+// The data-modifying CTEs are rewritten to select the rows they return, so that the backup doesn't modify rows.
+// It also returns the names of the rewritten CTEs which cannot select the same rows, i.e. the new rows of UPDATE and INSERT.
+func getWithClause(tree antlr.ParserRuleContext) (string, []string, error) {
+	var tokens antlr.TokenStream
+	var withClause parser.IWith_clauseContext
+	switch ctx := tree.(type) {
+	case *parser.UpdatestmtContext:
+		tokens = ctx.GetParser().GetTokenStream()
+		if ctx.Opt_with_clause() != nil {
+			withClause = ctx.Opt_with_clause().With_clause()
+		}
+	case *parser.DeletestmtContext:
+		tokens = ctx.GetParser().GetTokenStream()
+		if ctx.Opt_with_clause() != nil {
+			withClause = ctx.Opt_with_clause().With_clause()
+		}
+	case *parser.InsertstmtContext:
+		tokens = ctx.GetParser().GetTokenStream()
+		if ctx.Opt_with_clause() != nil {
+			withClause = ctx.Opt_with_clause().With_clause()
+		}
+	default:
+		return "", nil, nil
+	}
+
+	var ctes []parser.ICommon_table_exprContext
+	recursive := false
+	rewrite := false
+	if cte := getDataModifyingCTE(tree); cte != nil {
+		rewrite = true
+		if cteList, ok := cte.GetParent().(*parser.Cte_listContext); ok {
+			for _, item := range cteList.AllCommon_table_expr() {
+				if item == parser.ICommon_table_exprContext(cte) {
+					break
+				}
+				ctes = append(ctes, item)
+			}
+			if withClause, ok := cteList.GetParent().(*parser.With_clauseContext); ok && withClause.RECURSIVE() != nil {
+				recursive = true
+			}
+		}
+	}
+	if withClause != nil {
+		ctes = append(ctes, withClause.Cte_list().AllCommon_table_expr()...)
+		recursive = recursive || withClause.RECURSIVE() != nil
+	}
+	for _, cte := range ctes {
+		if cte.Preparablestmt().Selectstmt() == nil {
+			rewrite = true
+		}
+	}
+	if !rewrite {
+		if withClause == nil {
+			return "", nil, nil
+		}
+		return tokens.GetTextFromRuleContext(withClause), nil, nil
+	}
+
+	var items, inexactNames []string
+	for _, cte := range ctes {
+		preparable := cte.Preparablestmt()
+		if preparable.Selectstmt() != nil {
+			items = append(items, tokens.GetTextFromRuleContext(cte))
+			continue
+		}
+		var returning parser.IReturning_clauseContext
+		var dml antlr.ParserRuleContext
+		exact := false
+		switch {
+		case preparable.Deletestmt() != nil:
+			returning, dml, exact = preparable.Deletestmt().Returning_clause(), preparable.Deletestmt(), true
+		case preparable.Updatestmt() != nil:
+			returning, dml = preparable.Updatestmt().Returning_clause(), preparable.Updatestmt()
+		case preparable.Insertstmt() != nil:
+			returning, dml = preparable.Insertstmt().Returning_clause(), preparable.Insertstmt()
+		}
+		// The data-modifying CTE without RETURNING cannot be referenced.
+		if returning == nil {
+			continue
+		}
+
+		var buf strings.Builder
+		if _, err := fmt.Fprintf(&buf, "%s (SELECT %s ", tokens.GetTextFromInterval(antlr.NewInterval(cte.GetStart().GetTokenIndex(), cte.AS().GetSymbol().GetTokenIndex())), tokens.GetTextFromRuleContext(returning.Target_list())); err != nil {
+			return "", nil, errors.Wrap(err, "failed to write to buffer")
+		}
+		if insert, ok := dml.(*parser.InsertstmtContext); ok {
+			// The rows to insert don't exist yet.
+			if _, err := fmt.Fprintf(&buf, "FROM %s WHERE false", tokens.GetTextFromRuleContext(insert.Insert_target())); err != nil {
+				return "", nil, errors.Wrap(err, "failed to write to buffer")
+			}
+		} else if err := writeSuffixSelectClause(&buf, dml); err != nil {
+			return "", nil, err
+		}
+		if _, err := buf.WriteString(")"); err != nil {
+			return "", nil, errors.Wrap(err, "failed to write to buffer")
+		}
+		items = append(items, buf.String())
+		if !exact {
+			inexactNames = append(inexactNames, NormalizePostgreSQLColid(cte.Name().Colid()))
+		}
+	}
+	if len(items) == 0 {
+		return "", inexactNames, nil
+	}
+	keyword := "WITH"
+	if recursive {
+		keyword = "WITH RECURSIVE"
+	}
+	return fmt.Sprintf("%s %s", keyword, strings.Join(items, ", ")), inexactNames, nil
+}
+
+// readsCTEs returns whether the DML other than its WITH clause references any of the CTEs.
+func readsCTEs(tree antlr.ParserRuleContext, names []string) bool {
+	if len(names) == 0 {
+		return false
+	}
+	var tokens antlr.TokenStream
+	var withClause parser.IOpt_with_clauseContext
+	switch ctx := tree.(type) {
+	case *parser.UpdatestmtContext:
+		tokens, withClause = ctx.GetParser().GetTokenStream(), ctx.Opt_with_clause()
+	case *parser.DeletestmtContext:
+		tokens, withClause = ctx.GetParser().GetTokenStream(), ctx.Opt_with_clause()
+	case *parser.InsertstmtContext:
+		tokens, withClause = ctx.GetParser().GetTokenStream(), ctx.Opt_with_clause()
+	default:
+		return false
+	}
+	start := tree.GetStart().GetTokenIndex()
+	if withClause != nil && withClause.GetStop() != nil {
+		start = withClause.GetStop().GetTokenIndex() + 1
+	}
+	text := tokens.GetTextFromInterval(antlr.NewInterval(start, tree.GetStop().GetTokenIndex()))
+	for _, name := range names {
+		if regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(name) + `\b`).MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
 func writeSuffixSelectClause(buf *strings.Builder, tree antlr.Tree) error {
 	extractor := &suffixSelectClauseExtractor{
-		buf: buf,
+		buf:  buf,
+		root: tree,
 	}
 	antlr.ParseTreeWalkerDefault.Walk(extractor, tree)
 	return extractor.err
@@ -124,11 +359,13 @@ type suffixSelectClauseExtractor struct {
 	*parser.BasePostgreSQLParserListener
 
 	buf *strings.Builder
-	err error
+	// root is the DML to back up, the other DMLs in its WITH clause are skipped.
+	root antlr.Tree
+	err  error
 }
 
 func (e *suffixSelectClauseExtractor) EnterUpdatestmt(ctx *parser.UpdatestmtContext) {
-	if e.err != nil || !isTopLevel(ctx.GetParent()) {
+	if e.err != nil || e.root != antlr.Tree(ctx) {
 		return
 	}
 
@@ -136,21 +373,39 @@ func (e *suffixSelectClauseExtractor) EnterUpdatestmt(ctx *parser.UpdatestmtCont
 		e.err = errors.Wrap(err, "failed to write to buffer")
 	}
 
+	where := ctx.Where_or_current_clause()
+	if ctx.From_clause() != nil && (where == nil || where.A_expr() != nil) {
+		// The join with the FROM list may match a target row multiple times, but the row is updated only once.
+		// So the FROM list is moved into EXISTS to back up each target row exactly once.
+		if _, err := fmt.Fprintf(e.buf, " WHERE EXISTS (SELECT 1 FROM %s", ctx.GetParser().GetTokenStream().GetTextFromRuleContext(ctx.From_clause().From_list())); err != nil {
+			e.err = errors.Wrap(err, "failed to write to buffer")
+		}
+		if where != nil {
+			if _, err := fmt.Fprintf(e.buf, " %s", ctx.GetParser().GetTokenStream().GetTextFromRuleContext(where)); err != nil {
+				e.err = errors.Wrap(err, "failed to write to buffer")
+			}
+		}
+		if _, err := e.buf.WriteString(")"); err != nil {
+			e.err = errors.Wrap(err, "failed to write to buffer")
+		}
+		return
+	}
+
 	if ctx.From_clause() != nil {
 		if _, err := fmt.Fprintf(e.buf, ", %s", ctx.GetParser().GetTokenStream().GetTextFromRuleContext(ctx.From_clause().From_list())); err != nil {
 			e.err = errors.Wrap(err, "failed to write to buffer")
 		}
 	}
 
-	if ctx.Where_or_current_clause() != nil {
-		if _, err := fmt.Fprintf(e.buf, " %s", ctx.GetParser().GetTokenStream().GetTextFromRuleContext(ctx.Where_or_current_clause())); err != nil {
+	if where != nil {
+		if _, err := fmt.Fprintf(e.buf, " %s", ctx.GetParser().GetTokenStream().GetTextFromRuleContext(where)); err != nil {
 			e.err = errors.Wrap(err, "failed to write to buffer")
 		}
 	}
 }
 
 func (e *suffixSelectClauseExtractor) EnterDeletestmt(ctx *parser.DeletestmtContext) {
-	if e.err != nil || !isTopLevel(ctx.GetParent()) {
+	if e.err != nil || e.root != antlr.Tree(ctx) {
 		return
 	}
 
@@ -171,6 +426,83 @@ func (e *suffixSelectClauseExtractor) EnterDeletestmt(ctx *parser.DeletestmtCont
 	}
 }
 
+// EnterInsertstmt backs up the existing rows conflicting with the rows to insert, which are the rows to be updated.
+func (e *suffixSelectClauseExtractor) EnterInsertstmt(ctx *parser.InsertstmtContext) {
+	if e.err != nil || e.root != antlr.Tree(ctx) {
+		return
+	}
+	conflictColumns, insertColumns, ok := getUpsertColumns(ctx)
+	if !ok {
+		return
+	}
+
+	tokens := ctx.GetParser().GetTokenStream()
+	table := newTableReference(NormalizePostgreSQLQualifiedName(ctx.Insert_target().Qualified_name()))
+	if table == nil {
+		return
+	}
+	target := table.String()
+	if ctx.Insert_target().Colid() != nil {
+		target = quoteIdentifier(NormalizePostgreSQLColid(ctx.Insert_target().Colid()))
+	}
+	var targetColumns, sourceColumns, allColumns []string
+	for _, column := range conflictColumns {
+		targetColumns = append(targetColumns, fmt.Sprintf(`%s.%s`, target, quoteIdentifier(column)))
+		sourceColumns = append(sourceColumns, quoteIdentifier(column))
+	}
+	for _, column := range insertColumns {
+		allColumns = append(allColumns, quoteIdentifier(column))
+	}
+	if _, err := fmt.Fprintf(
+		e.buf,
+		"FROM %s WHERE (%s) IN (SELECT %s FROM (%s) AS bb_upsert(%s))",
+		tokens.GetTextFromRuleContext(ctx.Insert_target()),
+		strings.Join(targetColumns, ", "),
+		strings.Join(sourceColumns, ", "),
+		tokens.GetTextFromRuleContext(ctx.Insert_rest().Selectstmt()),
+		strings.Join(allColumns, ", "),
+	); err != nil {
+		e.err = errors.Wrap(err, "failed to write to buffer")
+	}
+}
+
+// getUpsertColumns returns the conflict columns and the insert columns of the INSERT ... ON CONFLICT DO UPDATE statement.
+// It returns false if the statement doesn't update rows, or the updated rows cannot be determined by the conflict columns
+// such as ON CONFLICT ON CONSTRAINT, conflict expressions and conflict columns not in the insert column list.
+func getUpsertColumns(ctx *parser.InsertstmtContext) ([]string, []string, bool) {
+	onConflict := ctx.Opt_on_conflict()
+	if onConflict == nil || onConflict.CONFLICT() == nil || onConflict.UPDATE() == nil {
+		return nil, nil, false
+	}
+	if onConflict.Opt_conf_expr() == nil || onConflict.Opt_conf_expr().Index_params() == nil {
+		return nil, nil, false
+	}
+	insertRest := ctx.Insert_rest()
+	if insertRest == nil || insertRest.Insert_column_list() == nil || insertRest.Selectstmt() == nil {
+		return nil, nil, false
+	}
+
+	var insertColumns []string
+	insertColumnSet := make(map[string]bool)
+	for _, item := range insertRest.Insert_column_list().AllInsert_column_item() {
+		column := NormalizePostgreSQLColid(item.Colid())
+		insertColumns = append(insertColumns, column)
+		insertColumnSet[column] = true
+	}
+	var conflictColumns []string
+	for _, elem := range onConflict.Opt_conf_expr().Index_params().AllIndex_elem() {
+		if elem.Colid() == nil {
+			return nil, nil, false
+		}
+		column := NormalizePostgreSQLColid(elem.Colid())
+		if !insertColumnSet[column] {
+			return nil, nil, false
+		}
+		conflictColumns = append(conflictColumns, column)
+	}
+	return conflictColumns, insertColumns, len(conflictColumns) > 0
+}
+
 func prepareTransformation(statement string) ([]statementInfo, error) {
 	tree, err := ParsePostgreSQL(statement)
 	if err != nil {
@@ -204,6 +536,34 @@ func isTopLevel(ctx antlr.Tree) bool {
 	}
 }
 
+// isBackupTarget returns whether the DML is a top-level statement, or the sub-statement of a data-modifying CTE
+// in a top-level statement, e.g. WITH x AS (UPDATE ... RETURNING ...) SELECT ..., which modifies rows as well.
+func isBackupTarget(ctx antlr.ParserRuleContext) bool {
+	if isTopLevel(ctx.GetParent()) {
+		return true
+	}
+	cte := getDataModifyingCTE(ctx)
+	if cte == nil {
+		return false
+	}
+	for parent := cte.GetParent(); parent != nil; parent = parent.GetParent() {
+		if stmt, ok := parent.(*parser.StmtContext); ok {
+			return isTopLevel(stmt)
+		}
+	}
+	return false
+}
+
+// getDataModifyingCTE returns the CTE of which the DML is the sub-statement, or nil if the DML is not in a CTE.
+func getDataModifyingCTE(ctx antlr.ParserRuleContext) *parser.Common_table_exprContext {
+	preparable, ok := ctx.GetParent().(*parser.PreparablestmtContext)
+	if !ok {
+		return nil
+	}
+	cte, _ := preparable.GetParent().(*parser.Common_table_exprContext)
+	return cte
+}
+
 func (e *dmlExtractor) ExitStmt(ctx *parser.StmtContext) {
 	if isTopLevel(ctx) {
 		e.offset++
@@ -211,7 +571,7 @@ func (e *dmlExtractor) ExitStmt(ctx *parser.StmtContext) {
 }
 
 func (e *dmlExtractor) EnterUpdatestmt(ctx *parser.UpdatestmtContext) {
-	if isTopLevel(ctx.GetParent()) {
+	if isBackupTarget(ctx) {
 		table := extractTableReference(ctx.Relation_expr_opt_alias())
 		if table == nil {
 			return
@@ -227,7 +587,7 @@ func (e *dmlExtractor) EnterUpdatestmt(ctx *parser.UpdatestmtContext) {
 }
 
 func (e *dmlExtractor) EnterDeletestmt(ctx *parser.DeletestmtContext) {
-	if isTopLevel(ctx.GetParent()) {
+	if isBackupTarget(ctx) {
 		table := extractTableReference(ctx.Relation_expr_opt_alias())
 		if table == nil {
 			return
@@ -242,19 +602,53 @@ func (e *dmlExtractor) EnterDeletestmt(ctx *parser.DeletestmtContext) {
 	}
 }
 
+func (e *dmlExtractor) EnterInsertstmt(ctx *parser.InsertstmtContext) {
+	if !isBackupTarget(ctx) {
+		return
+	}
+	if _, _, ok := getUpsertColumns(ctx); !ok {
+		return
+	}
+	table := newTableReference(NormalizePostgreSQLQualifiedName(ctx.Insert_target().Qualified_name()))
+	if table == nil {
+		return
+	}
+	if ctx.Insert_target().Colid() != nil {
+		table.Alias = NormalizePostgreSQLColid(ctx.Insert_target().Colid())
+	}
+
+	e.dmls = append(e.dmls, statementInfo{
+		offset:    e.offset,
+		statement: ctx.GetParser().GetTokenStream().GetTextFromRuleContext(ctx),
+		tree:      ctx,
+		table:     table,
+	})
+}
+
 func extractTableReference(ctx parser.IRelation_expr_opt_aliasContext) *TableReference {
 	if ctx == nil {
 		return nil
 	}
 
-	table := TableReference{}
-
 	relationExpr := ctx.Relation_expr()
 	if relationExpr == nil {
 		return nil
 	}
 
-	list := NormalizePostgreSQLQualifiedName(relationExpr.Qualified_name())
+	table := newTableReference(NormalizePostgreSQLQualifiedName(relationExpr.Qualified_name()))
+	if table == nil {
+		return nil
+	}
+
+	if ctx.Colid() != nil {
+		table.Alias = NormalizePostgreSQLColid(ctx.Colid())
+	}
+	return table
+}
+
+// newTableReference returns the table reference of the normalized qualified name.
+func newTableReference(list []string) *TableReference {
+	table := TableReference{}
 	switch len(list) {
 	case 3:
 		table.Database = list[0]
@@ -269,9 +663,5 @@ func extractTableReference(ctx parser.IRelation_expr_opt_aliasContext) *TableRef
 		slog.Debug("Invalid table name", log.BBError(errors.Errorf("Invalid table name: %v", list)))
 		return nil
 	}
-
-	if ctx.Colid() != nil {
-		table.Alias = NormalizePostgreSQLColid(ctx.Colid())
-	}
 	return &table
 }
@@ -60,3 +60,136 @@ func TestBackup(t *testing.T) {
 		a.NoError(err)
 	}
 }
+
+func TestBackupLockRows(t *testing.T) {
+	a := require.New(t)
+	statement := "UPDATE t AS x SET c1 = 2 FROM test WHERE x.c1 = 1 AND x.c2 = test.c2;\nDELETE FROM test WHERE c1 = 1;"
+	result, err := TransformDMLToSelect(context.Background(), base.TransformContext{LockRows: true}, statement, "", "backupSchema", "rollback")
+	a.NoError(err)
+	a.Len(result, 2)
+	a.Equal(`CREATE TABLE "backupSchema"."rollback_0_t" AS SELECT "x".* FROM t AS x WHERE EXISTS (SELECT 1 FROM test WHERE x.c1 = 1 AND x.c2 = test.c2) FOR UPDATE OF "x";`, result[0].Statement)
+	a.Equal(`CREATE TABLE "backupSchema"."rollback_1_test" AS SELECT "test".* FROM test WHERE c1 = 1 FOR UPDATE OF "test";`, result[1].Statement)
+}
+
+func TestBackupJoinUpdate(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      []string
+	}{
+		{
+			// The target row matching multiple rows of the FROM list is backed up once.
+			statement: `UPDATE orders AS o SET status = c.status FROM customers AS c WHERE o.customer_id = c.id AND c.vip;`,
+			want:      []string{`CREATE TABLE "backupSchema"."rollback_0_orders" AS SELECT "o".* FROM orders AS o WHERE EXISTS (SELECT 1 FROM customers AS c WHERE o.customer_id = c.id AND c.vip);`},
+		},
+		{
+			statement: `UPDATE public.orders SET status = 'done' FROM customers JOIN regions ON customers.region_id = regions.id WHERE orders.customer_id = customers.id;`,
+			want:      []string{`CREATE TABLE "backupSchema"."rollback_0_orders" AS SELECT "public"."orders".* FROM public.orders WHERE EXISTS (SELECT 1 FROM customers JOIN regions ON customers.region_id = regions.id WHERE orders.customer_id = customers.id);`},
+		},
+		{
+			statement: `UPDATE orders SET status = 'done' FROM customers;`,
+			want:      []string{`CREATE TABLE "backupSchema"."rollback_0_orders" AS SELECT "orders".* FROM orders WHERE EXISTS (SELECT 1 FROM customers);`},
+		},
+	}
+
+	a := require.New(t)
+	for _, test := range tests {
+		result, err := TransformDMLToSelect(context.Background(), base.TransformContext{}, test.statement, "", "backupSchema", "rollback")
+		a.NoError(err)
+		var got []string
+		for _, backup := range result {
+			got = append(got, backup.Statement)
+			a.Equal("orders", backup.SourceTableName)
+		}
+		a.Equal(test.want, got, test.statement)
+	}
+}
+
+func TestBackupReservedWords(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      []string
+	}{
+		{
+			statement: `UPDATE "order" SET "select" = 1 WHERE "select" = 2;`,
+			want:      []string{`CREATE TABLE "backupSchema"."rollback_0_order" AS SELECT "order".* FROM "order" WHERE "select" = 2;`},
+		},
+		{
+			statement: `DELETE FROM "user"."table" AS "from" WHERE "from"."select" = 1;`,
+			want:      []string{`CREATE TABLE "backupSchema"."rollback_0_table" AS SELECT "from".* FROM "user"."table" AS "from" WHERE "from"."select" = 1;`},
+		},
+		{
+			statement: `INSERT INTO "order" ("select", "desc") VALUES (1, 'a') ON CONFLICT ("select") DO UPDATE SET "desc" = EXCLUDED."desc";`,
+			want:      []string{`CREATE TABLE "backupSchema"."rollback_0_order" AS SELECT "order".* FROM "order" WHERE ("order"."select") IN (SELECT "select" FROM (VALUES (1, 'a')) AS bb_upsert("select", "desc"));`},
+		},
+		{
+			// The double quotes in the names are escaped.
+			statement: `DELETE FROM "a""b" WHERE c = 1;`,
+			want:      []string{`CREATE TABLE "backupSchema"."rollback_0_a""b" AS SELECT "a""b".* FROM "a""b" WHERE c = 1;`},
+		},
+	}
+
+	a := require.New(t)
+	for _, test := range tests {
+		result, err := TransformDMLToSelect(context.Background(), base.TransformContext{}, test.statement, "", "backupSchema", "rollback")
+		a.NoError(err)
+		var got []string
+		for _, backup := range result {
+			got = append(got, backup.Statement)
+		}
+		a.Equal(test.want, got, test.statement)
+	}
+}
+
+func TestBackupUpsert(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      []string
+	}{
+		{
+			statement: `INSERT INTO t (id, name) VALUES (1, 'a'), (2, 'b') ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name;`,
+			want:      []string{`CREATE TABLE "backupSchema"."rollback_0_t" AS SELECT "t".* FROM t WHERE ("t"."id") IN (SELECT "id" FROM (VALUES (1, 'a'), (2, 'b')) AS bb_upsert("id", "name"));`},
+		},
+		{
+			statement: `INSERT INTO public.t AS x (id, k, name) SELECT id, k, name FROM s ON CONFLICT (id, k) DO UPDATE SET name = EXCLUDED.name;`,
+			want:      []string{`CREATE TABLE "backupSchema"."rollback_0_t" AS SELECT "x".* FROM public.t AS x WHERE ("x"."id", "x"."k") IN (SELECT "id", "k" FROM (SELECT id, k, name FROM s) AS bb_upsert("id", "k", "name"));`},
+		},
+		{
+			// Inserted rows are not backed up.
+			statement: `INSERT INTO t (id, name) VALUES (1, 'a') ON CONFLICT (id) DO NOTHING;`,
+		},
+		{
+			statement: `INSERT INTO t (id, name) VALUES (1, 'a');`,
+		},
+	}
+
+	a := require.New(t)
+	for _, test := range tests {
+		result, err := TransformDMLToSelect(context.Background(), base.TransformContext{}, test.statement, "", "backupSchema", "rollback")
+		a.NoError(err)
+		var got []string
+		for _, backup := range result {
+			got = append(got, backup.Statement)
+		}
+		a.Equal(test.want, got, test.statement)
+	}
+}
+
+func TestBackupUpdateSetClause(t *testing.T) {
+	a := require.New(t)
+	statement := "UPDATE t AS x SET c1 = c1 + 1, c2 = upper(x.c2) WHERE c1 > 1;\nUPDATE public.t SET (c1, c2) = (0, 'a');\nUPDATE t SET c1 = 2 FROM test WHERE t.c2 = test.c2;\nDELETE FROM test WHERE c1 = 1;"
+	result, err := TransformDMLToSelect(context.Background(), base.TransformContext{AfterImage: true}, statement, "", "backupSchema", "rollback")
+	a.NoError(err)
+	a.Len(result, 4)
+	a.Equal("SET c1 = c1 + 1, c2 = upper(x.c2)", result[0].UpdateSetClause)
+	a.Equal("x", result[0].UpdateAlias)
+	a.Equal("SET (c1, c2) = (0, 'a')", result[1].UpdateSetClause)
+	a.Equal("t", result[1].UpdateAlias)
+	// The new values of the joined UPDATE depend on the FROM list.
+	a.Empty(result[2].UpdateSetClause)
+	a.Empty(result[3].UpdateSetClause)
+
+	// The SET clauses are not set unless requested.
+	result, err = TransformDMLToSelect(context.Background(), base.TransformContext{}, statement, "", "backupSchema", "rollback")
+	a.NoError(err)
+	a.Empty(result[0].UpdateSetClause)
+}
@@ -0,0 +1,15 @@
+package pg
+
+import (
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+// escapeStrategy quotes the identifiers by double quotes, and the double quotes in them are doubled.
+var escapeStrategy = base.EscapeStrategy{Open: `"`, Close: `"`}
+
+func init() {
+	base.RegisterEscapeStrategy(storepb.Engine_POSTGRES, escapeStrategy)
+	base.RegisterEscapeStrategy(storepb.Engine_REDSHIFT, escapeStrategy)
+	base.RegisterEscapeStrategy(storepb.Engine_RISINGWAVE, escapeStrategy)
+}
@@ -0,0 +1,237 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/pkg/errors"
+
+	parser "github.com/bytebase/postgresql-parser"
+
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	storebp "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func init() {
+	base.RegisterGenerateRestoreSQL(storebp.Engine_POSTGRES, GenerateRestoreSQL)
+}
+
+// GenerateRestoreSQL generates the statement inserting the rows backed up before the DML back into the original table.
+// The backup table is in the backup schema, and has the same columns as the original table.
+func GenerateRestoreSQL(ctx context.Context, rCtx base.RestoreContext, statement string, backupSchema string, backupTable string, originalDatabase string, originalTable string) (string, error) {
+	// The backup schema is in the original database, which the statements of another database cannot read.
+	if rCtx.TargetDatabase != "" && rCtx.TargetDatabase != originalDatabase {
+		return "", errors.Errorf("restoring into database %q other than the original database %q is not supported", rCtx.TargetDatabase, originalDatabase)
+	}
+	statementInfoList, err := prepareTransformation(statement)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to prepare transformation")
+	}
+	// The statement may change multiple tables by the data-modifying CTEs.
+	var info *statementInfo
+	for i := range statementInfoList {
+		if statementInfoList[i].table.Table == originalTable {
+			info = &statementInfoList[i]
+			break
+		}
+	}
+	if info == nil {
+		return "", errors.Errorf("the statement doesn't change table %q", originalTable)
+	}
+	table := &TableReference{Schema: info.table.Schema, Table: originalTable}
+	if table.Schema == "" {
+		table.Schema = "public"
+	}
+
+	columns, key, collations, err := getRestoreColumns(ctx, rCtx, originalDatabase, table)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get columns for %s", table.String())
+	}
+	if err := checkRestoreCollations(collations, rCtx.BackupColumns); err != nil {
+		return "", errors.Wrapf(err, "failed to restore into %s", table.String())
+	}
+
+	insertColumns, values, backedUp := getRestoreColumnValues(columns, rCtx.BackupColumns)
+	// The updated columns are overwritten on conflict by default. The columns without backed up values are never overwritten.
+	updatedColumns := slices.DeleteFunc(getUpdatedColumns(info.tree), func(column string) bool {
+		return !backedUp[column]
+	})
+	skip := false
+	switch rCtx.ConflictStrategy {
+	case base.RestoreConflictDefault:
+	case base.RestoreConflictOverwrite:
+		updatedColumns = nil
+		for _, column := range columns {
+			if !slices.Contains(key, column) && backedUp[column] {
+				updatedColumns = append(updatedColumns, column)
+			}
+		}
+		// The conflicting rows equal the backup rows if all columns are keys.
+		skip = len(updatedColumns) == 0
+	case base.RestoreConflictSkip:
+		updatedColumns, skip = nil, true
+	case base.RestoreConflictError:
+		updatedColumns = nil
+	default:
+		return "", errors.Errorf("unsupported restore conflict strategy %q", rCtx.ConflictStrategy)
+	}
+
+	var quotedColumns []string
+	for _, column := range insertColumns {
+		quotedColumns = append(quotedColumns, quoteIdentifier(column))
+	}
+	var buf strings.Builder
+	if _, err := fmt.Fprintf(&buf, "/*\nOriginal SQL:\n%s\n*/\nINSERT INTO %s (%s) SELECT %s FROM %s.%s", statement, table.String(), strings.Join(quotedColumns, ", "), strings.Join(values, ", "), quoteIdentifier(backupSchema), quoteIdentifier(backupTable)); err != nil {
+		return "", errors.Wrap(err, "failed to write to buffer")
+	}
+	switch {
+	case skip:
+		// ON CONFLICT DO NOTHING without the conflict target skips the rows conflicting with any unique index.
+		if _, err := buf.WriteString(" ON CONFLICT DO NOTHING"); err != nil {
+			return "", errors.Wrap(err, "failed to write to buffer")
+		}
+	case len(updatedColumns) > 0:
+		if len(key) == 0 {
+			return "", errors.Errorf("table %s has no primary key or unique index to match the rows on restore", table.String())
+		}
+		var quotedKey []string
+		for _, column := range key {
+			quotedKey = append(quotedKey, quoteIdentifier(column))
+		}
+		var assignments []string
+		for _, column := range updatedColumns {
+			assignments = append(assignments, fmt.Sprintf("%s = EXCLUDED.%s", quoteIdentifier(column), quoteIdentifier(column)))
+		}
+		if _, err := fmt.Fprintf(&buf, " ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(quotedKey, ", "), strings.Join(assignments, ", ")); err != nil {
+			return "", errors.Wrap(err, "failed to write to buffer")
+		}
+		// The rows changed since the backup have advanced their versions past the backup.
+		if backedUp[rCtx.VersionColumn] {
+			if _, err := fmt.Fprintf(&buf, " WHERE %s.%s <= EXCLUDED.%s", table.String(), quoteIdentifier(rCtx.VersionColumn), quoteIdentifier(rCtx.VersionColumn)); err != nil {
+				return "", errors.Wrap(err, "failed to write to buffer")
+			}
+		}
+	}
+	if _, err := buf.WriteString(";"); err != nil {
+		return "", errors.Wrap(err, "failed to write to buffer")
+	}
+	return buf.String(), nil
+}
+
+// getRestoreColumnValues returns the columns inserted on restore, the values selected for them from the backup table,
+// and the set of the columns restored with the backed up values. With the columns at backup time, the excluded columns
+// take their defaults at backup time explicitly or are skipped without defaults, and the columns added after the backup are skipped.
+func getRestoreColumnValues(columns []string, backupColumns []base.RestoreColumn) ([]string, []string, map[string]bool) {
+	var insertColumns, values []string
+	backedUp := make(map[string]bool)
+	for _, column := range columns {
+		if len(backupColumns) > 0 {
+			index := slices.IndexFunc(backupColumns, func(c base.RestoreColumn) bool { return c.Name == column })
+			if index < 0 {
+				continue
+			}
+			if backupColumns[index].Excluded {
+				if backupColumns[index].Default != "" {
+					insertColumns = append(insertColumns, column)
+					values = append(values, backupColumns[index].Default)
+				}
+				continue
+			}
+		}
+		insertColumns = append(insertColumns, column)
+		values = append(values, quoteIdentifier(column))
+		backedUp[column] = true
+	}
+	return insertColumns, values, backedUp
+}
+
+// getRestoreColumns returns the columns written on restore, which are the columns other than the generated ones,
+// the columns of the primary key, or the first unique index if there is no primary key, and the current collations of the columns.
+func getRestoreColumns(ctx context.Context, rCtx base.RestoreContext, database string, table *TableReference) ([]string, []string, map[string]string, error) {
+	if rCtx.GetDatabaseMetadataFunc == nil {
+		return nil, nil, nil, errors.New("GetDatabaseMetadataFunc is not set")
+	}
+	_, metadata, err := rCtx.GetDatabaseMetadataFunc(ctx, rCtx.InstanceID, database)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "failed to get database metadata for InstanceID %q, Database %q", rCtx.InstanceID, database)
+	}
+	schemaMetadata := metadata.GetSchema(table.Schema)
+	if schemaMetadata == nil {
+		return nil, nil, nil, errors.Errorf("failed to get schema metadata for schema %s", table.Schema)
+	}
+	tableMetadata := schemaMetadata.GetTable(table.Table)
+	if tableMetadata == nil {
+		return nil, nil, nil, errors.Errorf("failed to get table metadata for table %s", table.Table)
+	}
+
+	var columns []string
+	collations := make(map[string]string)
+	for _, column := range tableMetadata.GetColumns() {
+		if column.GetGeneration() != nil {
+			continue
+		}
+		columns = append(columns, column.GetName())
+		collations[column.GetName()] = column.GetCollation()
+	}
+	var key []string
+	for _, index := range tableMetadata.GetProto().GetIndexes() {
+		if index.GetPrimary() {
+			key = index.GetExpressions()
+			break
+		}
+		if index.GetUnique() && key == nil {
+			key = index.GetExpressions()
+		}
+	}
+	return columns, key, collations, nil
+}
+
+// checkRestoreCollations checks that the collations of the restored columns haven't changed since the backup.
+// The collations decide the uniqueness and the comparisons of the restored rows, e.g. matching the conflicting rows,
+// so the backed up rows may be merged or rejected differently under the changed collations.
+func checkRestoreCollations(collations map[string]string, backupColumns []base.RestoreColumn) error {
+	for _, column := range backupColumns {
+		if column.Excluded {
+			continue
+		}
+		current, ok := collations[column.Name]
+		if !ok || current == column.Collation {
+			continue
+		}
+		return errors.Errorf("the collation of column %q has changed from %q to %q since the backup", column.Name, column.Collation, current)
+	}
+	return nil
+}
+
+// getUpdatedColumns returns the columns assigned by the UPDATE or the INSERT ... ON CONFLICT DO UPDATE statement.
+func getUpdatedColumns(tree antlr.ParserRuleContext) []string {
+	var setClauseList parser.ISet_clause_listContext
+	switch ctx := tree.(type) {
+	case *parser.UpdatestmtContext:
+		setClauseList = ctx.Set_clause_list()
+	case *parser.InsertstmtContext:
+		if ctx.Opt_on_conflict() != nil {
+			setClauseList = ctx.Opt_on_conflict().Set_clause_list()
+		}
+	}
+	if setClauseList == nil {
+		return nil
+	}
+
+	var result []string
+	for _, setClause := range setClauseList.AllSet_clause() {
+		if setClause.Set_target() != nil {
+			result = append(result, NormalizePostgreSQLColid(setClause.Set_target().Colid()))
+			continue
+		}
+		if setClause.Set_target_list() != nil {
+			for _, target := range setClause.Set_target_list().AllSet_target() {
+				result = append(result, NormalizePostgreSQLColid(target.Colid()))
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,133 @@
+package pg
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+type restoreCase struct {
+	Input            string
+	BackupSchema     string
+	BackupTable      string
+	OriginalDatabase string
+	OriginalTable    string
+	ConflictStrategy string               `yaml:"conflictstrategy,omitempty"`
+	BackupColumns    []base.RestoreColumn `yaml:"backupcolumns,omitempty"`
+	Result           string
+}
+
+func TestRestore(t *testing.T) {
+	tests := []restoreCase{}
+
+	const (
+		record = false
+	)
+	var (
+		filepath = "test-data/test_restore.yaml"
+	)
+
+	a := require.New(t)
+	yamlFile, err := os.Open(filepath)
+	a.NoError(err)
+
+	byteValue, err := io.ReadAll(yamlFile)
+	a.NoError(yamlFile.Close())
+	a.NoError(err)
+	a.NoError(yaml.Unmarshal(byteValue, &tests))
+
+	getDatabaseMetadata, _ := buildMockDatabaseMetadataGetter([]*storepb.DatabaseSchemaMetadata{
+		{
+			Name: "db",
+			Schemas: []*storepb.SchemaMetadata{
+				{
+					Name: "public",
+					Tables: []*storepb.TableMetadata{
+						{
+							Name:    "t",
+							Columns: []*storepb.ColumnMetadata{{Name: "id"}, {Name: "a"}, {Name: "b"}},
+							Indexes: []*storepb.IndexMetadata{{Name: "t_pkey", Expressions: []string{"id"}, Primary: true, Unique: true}},
+						},
+					},
+				},
+			},
+		},
+	})
+	for i, t := range tests {
+		result, err := GenerateRestoreSQL(context.Background(), base.RestoreContext{
+			GetDatabaseMetadataFunc: getDatabaseMetadata,
+			ConflictStrategy:        base.RestoreConflictStrategy(t.ConflictStrategy),
+			BackupColumns:           t.BackupColumns,
+		}, t.Input, t.BackupSchema, t.BackupTable, t.OriginalDatabase, t.OriginalTable)
+		a.NoError(err)
+
+		if record {
+			tests[i].Result = result
+		} else {
+			a.Equal(t.Result, result, t.Input)
+		}
+	}
+	if record {
+		byteValue, err := yaml.Marshal(tests)
+		a.NoError(err)
+		err = os.WriteFile(filepath, byteValue, 0644)
+		a.NoError(err)
+	}
+}
+
+func TestRestoreCollation(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	newGetter := func(collation string) base.GetDatabaseMetadataFunc {
+		getDatabaseMetadata, _ := buildMockDatabaseMetadataGetter([]*storepb.DatabaseSchemaMetadata{
+			{
+				Name: "db",
+				Schemas: []*storepb.SchemaMetadata{
+					{
+						Name: "public",
+						Tables: []*storepb.TableMetadata{
+							{
+								Name:    "t",
+								Columns: []*storepb.ColumnMetadata{{Name: "id"}, {Name: "name", Collation: collation}},
+								Indexes: []*storepb.IndexMetadata{{Name: "t_name_key", Expressions: []string{"name"}, Unique: true}},
+							},
+						},
+					},
+				},
+			},
+		})
+		return getDatabaseMetadata
+	}
+	// The name column has the explicit case-insensitive collation at backup time.
+	backupColumns := []base.RestoreColumn{{Name: "id"}, {Name: "name", Collation: "und-x-icu-ci"}}
+
+	// The restore matches the conflicting rows by the unique index under the same collation.
+	result, err := GenerateRestoreSQL(ctx, base.RestoreContext{
+		GetDatabaseMetadataFunc: newGetter("und-x-icu-ci"),
+		BackupColumns:           backupColumns,
+	}, "UPDATE t SET id = 1;", "bbdataarchive", "_0_t", "db", "t")
+	a.NoError(err)
+	a.Equal("/*\nOriginal SQL:\nUPDATE t SET id = 1;\n*/\nINSERT INTO \"public\".\"t\" (\"id\", \"name\") SELECT \"id\", \"name\" FROM \"bbdataarchive\".\"_0_t\" ON CONFLICT (\"name\") DO UPDATE SET \"id\" = EXCLUDED.\"id\";", result)
+
+	// The rows would be matched differently under the changed collation.
+	_, err = GenerateRestoreSQL(ctx, base.RestoreContext{
+		GetDatabaseMetadataFunc: newGetter(""),
+		BackupColumns:           backupColumns,
+	}, "UPDATE t SET id = 1;", "bbdataarchive", "_0_t", "db", "t")
+	a.ErrorContains(err, `the collation of column "name" has changed from "und-x-icu-ci" to "" since the backup`)
+
+	// The collation of the excluded column doesn't matter.
+	backupColumns[1].Excluded = true
+	_, err = GenerateRestoreSQL(ctx, base.RestoreContext{
+		GetDatabaseMetadataFunc: newGetter(""),
+		BackupColumns:           backupColumns,
+	}, "UPDATE t SET id = 1;", "bbdataarchive", "_0_t", "db", "t")
+	a.NoError(err)
+}
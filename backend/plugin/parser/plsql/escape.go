@@ -0,0 +1,13 @@
+package plsql
+
+import (
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func init() {
+	// The identifiers are quoted by double quotes, and the double quotes in them are doubled.
+	base.RegisterEscapeStrategy(storepb.Engine_ORACLE, base.EscapeStrategy{Open: `"`, Close: `"`})
+	base.RegisterEscapeStrategy(storepb.Engine_DM, base.EscapeStrategy{Open: `"`, Close: `"`})
+	base.RegisterEscapeStrategy(storepb.Engine_OCEANBASE_ORACLE, base.EscapeStrategy{Open: `"`, Close: `"`})
+}
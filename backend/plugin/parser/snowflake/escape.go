@@ -0,0 +1,11 @@
+package snowflake
+
+import (
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func init() {
+	// The identifiers are quoted by double quotes, and the double quotes in them are doubled.
+	base.RegisterEscapeStrategy(storepb.Engine_SNOWFLAKE, base.EscapeStrategy{Open: `"`, Close: `"`})
+}
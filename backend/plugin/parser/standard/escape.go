@@ -0,0 +1,14 @@
+package standard
+
+import (
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func init() {
+	// The identifiers are quoted by double quotes, and the double quotes in them are doubled.
+	base.RegisterEscapeStrategy(storepb.Engine_SQLITE, base.EscapeStrategy{Open: `"`, Close: `"`})
+	// The identifiers are quoted by backticks, and the backticks in them are doubled.
+	base.RegisterEscapeStrategy(storepb.Engine_HIVE, base.EscapeStrategy{Open: "`", Close: "`"})
+	base.RegisterEscapeStrategy(storepb.Engine_DATABRICKS, base.EscapeStrategy{Open: "`", Close: "`"})
+}
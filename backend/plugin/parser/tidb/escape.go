@@ -0,0 +1,11 @@
+package tidb
+
+import (
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func init() {
+	// The identifiers are quoted by backticks, and the backticks in them are doubled.
+	base.RegisterEscapeStrategy(storepb.Engine_TIDB, base.EscapeStrategy{Open: "`", Close: "`"})
+}
@@ -34,6 +34,7 @@ const (
 	StatementTypeUpdate
 	StatementTypeInsert
 	StatementTypeDelete
+	StatementTypeMerge
 )
 
 type TableReference struct {
@@ -74,15 +75,20 @@ func generateSQL(statementInfoList []statementInfo, targetDatabase string, table
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to extract suffix select statement")
 		}
+		sourceTable, err := base.QuoteQualifiedName(storepb.Engine_MSSQL, table.Database, table.Schema, table.Table)
+		if len(table.Alias) != 0 {
+			sourceTable, err = base.QuoteQualifiedName(storepb.Engine_MSSQL, table.Alias)
+		}
+		if err != nil {
+			return nil, err
+		}
+		backupTable, err := base.QuoteQualifiedName(storepb.Engine_MSSQL, targetDatabase, table.Schema, targetTable)
+		if err != nil {
+			return nil, err
+		}
 		var buf strings.Builder
-		if len(table.Alias) == 0 {
-			if _, err := buf.WriteString(fmt.Sprintf(`SELECT "%s"."%s"."%s".* `, table.Database, table.Schema, table.Table)); err != nil {
-				return nil, errors.Wrap(err, "failed to write buffer")
-			}
-		} else {
-			if _, err := buf.WriteString(fmt.Sprintf(`SELECT "%s".* `, table.Alias)); err != nil {
-				return nil, errors.Wrap(err, "failed to write buffer")
-			}
+		if _, err := buf.WriteString(fmt.Sprintf(`SELECT %s.* `, sourceTable)); err != nil {
+			return nil, errors.Wrap(err, "failed to write buffer")
 		}
 		if len(topClause) > 0 {
 			if _, err := buf.WriteString(topClause); err != nil {
@@ -92,7 +98,8 @@ func generateSQL(statementInfoList []statementInfo, targetDatabase string, table
 				return nil, errors.Wrap(err, "failed to write buffer")
 			}
 		}
-		if _, err := buf.WriteString(fmt.Sprintf(`INTO "%s"."%s"."%s" `, targetDatabase, defaultSchema, targetTable)); err != nil {
+		// The backup table is created in the schema of the same name as the source table in the backup database.
+		if _, err := buf.WriteString(fmt.Sprintf(`INTO %s `, backupTable)); err != nil {
 			return nil, errors.Wrap(err, "failed to write buffer")
 		}
 		if len(fromClause) > 0 {
@@ -107,6 +114,7 @@ func generateSQL(statementInfoList []statementInfo, targetDatabase string, table
 			Statement:       buf.String(),
 			SourceSchema:    table.Schema,
 			SourceTableName: table.Table,
+			TargetSchema:    table.Schema,
 			TargetTableName: targetTable,
 			StartPosition: &storepb.Position{
 				Line:   int32(statementInfo.tree.GetStart().GetLine()),
@@ -270,6 +278,53 @@ func (e *suffixSelectStatementExtractor) EnterDelete_statement(ctx *parser.Delet
 	}
 }
 
+// EnterMerge_statement backs up the target rows matched by the source, which are the rows that may be updated or deleted.
+// If the statement modifies the target rows not matched by the source, all rows of the target table are backed up.
+func (e *suffixSelectStatementExtractor) EnterMerge_statement(ctx *parser.Merge_statementContext) {
+	if e.err != nil || !IsTopLevel(ctx.GetParent()) {
+		return
+	}
+
+	tokens := ctx.GetParser().GetTokenStream()
+	var buf strings.Builder
+	if _, err := fmt.Fprintf(&buf, "FROM %s", tokens.GetTextFromRuleContext(ctx.Ddl_object())); err != nil {
+		e.err = errors.Wrap(err, "failed to write buffer")
+		return
+	}
+	if ctx.As_table_alias() != nil {
+		if _, err := fmt.Fprintf(&buf, " %s", tokens.GetTextFromRuleContext(ctx.As_table_alias())); err != nil {
+			e.err = errors.Wrap(err, "failed to write buffer")
+			return
+		}
+	}
+	_, bySource := getMergeActions(ctx)
+	if !bySource {
+		if _, err := fmt.Fprintf(&buf, " WHERE EXISTS (SELECT 1 FROM %s WHERE %s)", tokens.GetTextFromRuleContext(ctx.Table_sources()), tokens.GetTextFromRuleContext(ctx.Search_condition())); err != nil {
+			e.err = errors.Wrap(err, "failed to write buffer")
+			return
+		}
+	}
+	e.fromClause = buf.String()
+}
+
+// getMergeActions returns whether the MERGE statement modifies the target rows matched by the source,
+// and whether it modifies the target rows not matched by the source.
+func getMergeActions(ctx *parser.Merge_statementContext) (bool, bool) {
+	var matched, bySource bool
+	for _, whenMatches := range ctx.AllWhen_matches() {
+		text := strings.ToUpper(strings.Join(strings.Fields(ctx.GetParser().GetTokenStream().GetTextFromRuleContext(whenMatches)), " "))
+		switch {
+		case strings.HasPrefix(text, "WHEN MATCHED"):
+			matched = true
+		case strings.HasPrefix(text, "WHEN NOT MATCHED BY SOURCE"):
+			bySource = true
+		default:
+			// WHEN NOT MATCHED [BY TARGET] only inserts rows.
+		}
+	}
+	return matched, bySource
+}
+
 func prepareTransformation(databaseName, statement string) ([]statementInfo, error) {
 	parseResult, err := ParseTSQL(statement)
 	if err != nil {
@@ -360,6 +415,35 @@ func (e *dmlExtractor) EnterDelete_statement(ctx *parser.Delete_statementContext
 	}
 }
 
+func (e *dmlExtractor) EnterMerge_statement(ctx *parser.Merge_statementContext) {
+	if !IsTopLevel(ctx.GetParent()) {
+		return
+	}
+	// The MERGE statement only inserting rows doesn't need a backup.
+	if matched, bySource := getMergeActions(ctx); !matched && !bySource {
+		return
+	}
+	extractor := &tableExtractor{
+		databaseName: e.databaseName,
+	}
+	antlr.ParseTreeWalkerDefault.Walk(extractor, ctx.Ddl_object())
+	table := extractor.table
+	if table == nil {
+		return
+	}
+	table.StatementType = StatementTypeMerge
+	if ctx.As_table_alias() != nil {
+		table.Alias = unquote(ctx.As_table_alias().Table_alias().GetText())
+	}
+
+	e.dmls = append(e.dmls, statementInfo{
+		offset:    e.offset,
+		statement: ctx.GetParser().GetTokenStream().GetTextFromRuleContext(ctx),
+		tree:      ctx,
+		table:     table,
+	})
+}
+
 func extractPhysicalTable(ctx antlr.Tree, table *TableReference) *TableReference {
 	if ctx == nil || table == nil {
 		return table
@@ -60,3 +60,51 @@ func TestBackup(t *testing.T) {
 		a.NoError(err)
 	}
 }
+
+func TestBackupMerge(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      []string
+	}{
+		{
+			statement: `MERGE INTO t AS tgt USING (VALUES (1, 'a')) AS src (id, name) ON tgt.id = src.id WHEN MATCHED THEN UPDATE SET tgt.name = src.name WHEN NOT MATCHED THEN INSERT (id, name) VALUES (src.id, src.name);`,
+			want:      []string{`SELECT [tgt].* INTO [backupDB].[dbo].[rollback_0_t] FROM t AS tgt WHERE EXISTS (SELECT 1 FROM (VALUES (1, 'a')) AS src (id, name) WHERE tgt.id = src.id);`},
+		},
+		{
+			statement: `MERGE t USING s ON t.id = s.id WHEN NOT MATCHED BY SOURCE THEN DELETE;`,
+			want:      []string{`SELECT [db].[dbo].[t].* INTO [backupDB].[dbo].[rollback_0_t] FROM t;`},
+		},
+		{
+			// Inserted rows are not backed up.
+			statement: `MERGE t USING s ON t.id = s.id WHEN NOT MATCHED THEN INSERT (id) VALUES (s.id);`,
+		},
+	}
+
+	a := require.New(t)
+	for _, test := range tests {
+		result, err := TransformDMLToSelect(context.Background(), base.TransformContext{}, test.statement, "db", "backupDB", "rollback")
+		a.NoError(err)
+		var got []string
+		for _, backup := range result {
+			got = append(got, backup.Statement)
+		}
+		a.Equal(test.want, got, test.statement)
+	}
+}
+
+func TestBackupSchema(t *testing.T) {
+	a := require.New(t)
+	// The backup table is created in the schema of the source table.
+	result, err := TransformDMLToSelect(context.Background(), base.TransformContext{}, `DELETE FROM sales.orders WHERE id = 1;`, "db", "backupDB", "rollback")
+	a.NoError(err)
+	a.Len(result, 1)
+	a.Equal("SELECT [db].[sales].[orders].* INTO [backupDB].[sales].[rollback_0_orders] FROM sales.orders WHERE id = 1\n;", result[0].Statement)
+	a.Equal("sales", result[0].SourceSchema)
+	a.Equal("sales", result[0].TargetSchema)
+
+	// The tables without the schema are in the default schema.
+	result, err = TransformDMLToSelect(context.Background(), base.TransformContext{}, `UPDATE orders SET status = 'done' WHERE id = 1;`, "db", "backupDB", "rollback")
+	a.NoError(err)
+	a.Len(result, 1)
+	a.Equal("dbo", result[0].TargetSchema)
+}
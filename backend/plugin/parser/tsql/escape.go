@@ -0,0 +1,11 @@
+package tsql
+
+import (
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func init() {
+	// The identifiers are quoted by brackets, and the closing brackets in them are doubled.
+	base.RegisterEscapeStrategy(storepb.Engine_MSSQL, base.EscapeStrategy{Open: "[", Close: "]"})
+}
@@ -843,7 +843,7 @@ func getRiskSourceFromPlan(config *storepb.PlanConfig) store.RiskSource {
 				switch v.ChangeDatabaseConfig.Type {
 				case storepb.PlanConfig_ChangeDatabaseConfig_MIGRATE, storepb.PlanConfig_ChangeDatabaseConfig_MIGRATE_GHOST, storepb.PlanConfig_ChangeDatabaseConfig_MIGRATE_SDL:
 					return store.RiskSourceDatabaseSchemaUpdate
-				case storepb.PlanConfig_ChangeDatabaseConfig_DATA:
+				case storepb.PlanConfig_ChangeDatabaseConfig_DATA, storepb.PlanConfig_ChangeDatabaseConfig_DATA_ROLLBACK:
 					return store.RiskSourceDatabaseDataUpdate
 				}
 			}
@@ -99,6 +99,16 @@ func (e *StatementAdviseExecutor) Run(ctx context.Context, config *storepb.PlanC
 	if instance == nil {
 		return nil, errors.Errorf("instance not found UID %v", instanceUID)
 	}
+	if preUpdateBackupDetail.GetObjectStorage() && !common.PriorBackupExportEngines[instance.Engine] {
+		return []*storepb.PlanCheckRunResult_Result{
+			{
+				Status:  storepb.PlanCheckRunResult_Result_ERROR,
+				Code:    common.NotImplemented.Int32(),
+				Title:   "Prior backup to object storage is not supported",
+				Content: fmt.Sprintf("Exporting the prior backup to the object storage is not supported for %s", instance.Engine),
+			},
+		}, nil
+	}
 	if !common.StatementAdviseEngines[instance.Engine] {
 		return []*storepb.PlanCheckRunResult_Result{
 			{
@@ -0,0 +1,199 @@
+package priorbackup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/bytebase/bytebase/backend/common"
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	"github.com/bytebase/bytebase/backend/store"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+// archivePartition is a monthly partition of an archive table recorded in the prior backup details.
+type archivePartition struct {
+	instanceID string
+	// sourceDatabase is the database of the source table, which contains the backup database as a schema on Postgres.
+	sourceDatabase string
+	// backupDatabase is the backup database name containing the archive table.
+	backupDatabase string
+	table          string
+	partition      string
+	// project is the project of the task that appended to the partition.
+	project string
+	endTime time.Time
+}
+
+func (p *archivePartition) key() string {
+	return fmt.Sprintf("%s/%s/%s", p.sourceDatabase, getBackupTableKey(p.instanceID, p.backupDatabase, p.table), p.partition)
+}
+
+// getArchivePartitions returns the archive partitions recorded in the prior backup details.
+// The partition appended to by the tasks of multiple projects is returned once for each project.
+func getArchivePartitions(details []*storepb.PriorBackupDetail) ([]*archivePartition, error) {
+	var partitions []*archivePartition
+	seen := make(map[string]bool)
+	for _, detail := range details {
+		var project string
+		if detail.GetStage() != "" {
+			projectID, _, _, err := common.GetProjectIDRolloutIDMaybeStageID(detail.GetStage())
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse stage %q", detail.GetStage())
+			}
+			project = projectID
+		}
+		for _, item := range detail.GetItems() {
+			archive := item.GetArchivePartition()
+			if archive == nil {
+				continue
+			}
+			instanceID, backupDatabase, err := common.GetInstanceDatabaseID(archive.GetTable().GetDatabase())
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse backup database %q", archive.GetTable().GetDatabase())
+			}
+			_, sourceDatabase, err := common.GetInstanceDatabaseID(item.GetSourceTable().GetDatabase())
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse source database %q", item.GetSourceTable().GetDatabase())
+			}
+			partition := &archivePartition{
+				instanceID:     instanceID,
+				sourceDatabase: sourceDatabase,
+				backupDatabase: backupDatabase,
+				table:          archive.GetTable().GetTable(),
+				partition:      archive.GetPartition(),
+				project:        project,
+				endTime:        archive.GetEndTime().AsTime(),
+			}
+			if seen[partition.key()+"/"+project] {
+				continue
+			}
+			seen[partition.key()+"/"+project] = true
+			partitions = append(partitions, partition)
+		}
+	}
+	return partitions, nil
+}
+
+// findExpiredArchivePartitions returns the archive partitions whose rows, backed up before the end times of the partitions,
+// are all older than the retentions of the projects appending to them, falling back to the default retention.
+// The partition shared by multiple projects expires only if it's expired for all of them. Zero retention keeps the partitions.
+func findExpiredArchivePartitions(partitions []*archivePartition, retentions map[string]time.Duration, defaultRetention time.Duration, now time.Time) []*archivePartition {
+	expired := make(map[string]bool)
+	kept := make(map[string]bool)
+	var result []*archivePartition
+	for _, partition := range partitions {
+		retention, ok := retentions[partition.project]
+		if !ok {
+			retention = defaultRetention
+		}
+		if retention <= 0 || now.Sub(partition.endTime) < retention {
+			kept[partition.key()] = true
+			continue
+		}
+		if !expired[partition.key()] {
+			expired[partition.key()] = true
+			result = append(result, partition)
+		}
+	}
+	return slices.DeleteFunc(result, func(partition *archivePartition) bool {
+		return kept[partition.key()]
+	})
+}
+
+func (r *Reconciler) dropArchivePartition(ctx context.Context, partition *archivePartition) error {
+	instance, err := r.store.GetInstanceV2(ctx, &store.FindInstanceMessage{ResourceID: &partition.instanceID})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get instance %q", partition.instanceID)
+	}
+	if instance == nil || instance.Deleted {
+		return nil
+	}
+	// The backup database is a schema in the source database on Postgres.
+	databaseName := partition.backupDatabase
+	if instance.Engine == storepb.Engine_POSTGRES {
+		databaseName = partition.sourceDatabase
+	}
+	database, err := r.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{InstanceID: &partition.instanceID, DatabaseName: &databaseName})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get database %q", databaseName)
+	}
+	if database == nil {
+		return nil
+	}
+	driver, err := r.dbFactory.GetAdminDatabaseDriver(ctx, instance, database, db.ConnectionContext{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get database driver")
+	}
+	defer driver.Close(ctx)
+	var existing []string
+	if instance.Engine == storepb.Engine_MYSQL {
+		existing, err = GetArchivePartitions(ctx, driver.GetDB(), partition.backupDatabase, partition.table)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get partitions of archive table %q", partition.table)
+		}
+		// The partition was dropped by the previous runs.
+		if !slices.Contains(existing, partition.partition) {
+			return nil
+		}
+	}
+	statement, err := GetDropArchivePartitionStatement(instance.Engine, partition.backupDatabase, partition.table, partition.partition, existing)
+	if err != nil {
+		return err
+	}
+	if _, err := driver.Execute(ctx, statement, db.ExecuteOptions{}); err != nil {
+		return errors.Wrapf(err, "failed to drop partition %q of archive table %q", partition.partition, partition.table)
+	}
+	return nil
+}
+
+// GetDropArchivePartitionStatement returns the statement dropping the partition of the archive table in the backup database.
+// The partitions are the partition tables on Postgres. The existing partitions of the archive table are required on MySQL,
+// where the archive table is dropped instead of its last partition, and recreated by the next archive.
+func GetDropArchivePartitionStatement(engine storepb.Engine, backupDatabase, table, partition string, existing []string) (string, error) {
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		return GetDropBackupTableStatement(engine, backupDatabase, "", partition)
+	case storepb.Engine_MYSQL:
+		if len(existing) == 1 && existing[0] == partition {
+			return GetDropBackupTableStatement(engine, backupDatabase, "", table)
+		}
+		name, err := base.QuoteQualifiedName(engine, backupDatabase, table)
+		if err != nil {
+			return "", err
+		}
+		quotedPartition, err := base.QuoteIdentifier(engine, partition)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("ALTER TABLE %s DROP PARTITION %s;", name, quotedPartition), nil
+	default:
+		return "", errors.Errorf("unsupported engine %s", engine)
+	}
+}
+
+// GetArchivePartitions returns the partitions of the archive table on MySQL, or empty if the archive table doesn't exist.
+func GetArchivePartitions(ctx context.Context, sqlDB *sql.DB, database, table string) ([]string, error) {
+	rows, err := sqlDB.QueryContext(ctx, "SELECT PARTITION_NAME FROM information_schema.PARTITIONS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND PARTITION_NAME IS NOT NULL", database, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var partitions []string
+	for rows.Next() {
+		var partition string
+		if err := rows.Scan(&partition); err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, partition)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return partitions, nil
+}
@@ -0,0 +1,601 @@
+// Package priorbackup is a runner that maintains the prior backup tables of the data changes.
+package priorbackup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/bytebase/bytebase/backend/common"
+	"github.com/bytebase/bytebase/backend/common/log"
+	"github.com/bytebase/bytebase/backend/component/config"
+	"github.com/bytebase/bytebase/backend/component/dbfactory"
+	api "github.com/bytebase/bytebase/backend/legacyapi"
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	"github.com/bytebase/bytebase/backend/store"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+const (
+	reconcileInterval = 24 * time.Hour
+	// orphanGracePeriod is the age that a backup table must reach before it's considered orphaned,
+	// so that the backup tables of the running tasks are not reported.
+	orphanGracePeriod = 24 * time.Hour
+	// backupDatabaseName is the default backup database name, and the per-issue backup databases are prefixed by it.
+	backupDatabaseName = "bbdataarchive"
+	// backupTableTimeLayout is the layout of the timestamp in the backup table prefix.
+	backupTableTimeLayout = "20060102150405"
+)
+
+// backupTableRegexp matches the backup table names created by the prior backup.
+// Format: [{namespace}]_{timestamp}[_{attempt}]_{offset}_{table}.
+var backupTableRegexp = regexp.MustCompile(`^[a-z0-9]*_(\d{14})(_\d)?_\d+_.+$`)
+
+var (
+	// backupTableIssueRegexp matches the issue of the backup table in its comment.
+	backupTableIssueRegexp = regexp.MustCompile(`^issue (\d+)\b`)
+	// backupTableProjectRegexp and backupTableEnvironmentRegexp match the project and environment labels in the backup table comments.
+	// Format: issue {issue}[ in namespace {namespace}][ of project {project}][ in environment {environment}]...
+	backupTableProjectRegexp     = regexp.MustCompile(`^issue \d+\b.*? of project (\S+)`)
+	backupTableEnvironmentRegexp = regexp.MustCompile(`^issue \d+\b.*? in environment (\S+)`)
+)
+
+// NewReconciler creates a new prior backup reconciler.
+func NewReconciler(store *store.Store, dbFactory *dbfactory.DBFactory, profile *config.Profile) *Reconciler {
+	return &Reconciler{
+		store:     store,
+		dbFactory: dbFactory,
+		profile:   profile,
+		clock:     realClock{},
+	}
+}
+
+// Reconciler detects the orphaned prior backup tables that have no corresponding task run results,
+// and drops the backup tables and the archive partitions past their retentions.
+// Orphans are left behind by crashes between creating the backup tables and recording the task run results.
+// The dropped backup tables are recorded in the timelines of their issues.
+type Reconciler struct {
+	store     *store.Store
+	dbFactory *dbfactory.DBFactory
+	profile   *config.Profile
+	// clock tells the time that the retentions and the orphan grace period are measured against.
+	clock Clock
+}
+
+// Clock tells the current time. The tests replace the real clock to control the expiries deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the clock of the wall time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// now returns the current time by the clock of the reconciler, or the wall time if the clock is not set.
+func (r *Reconciler) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock.Now()
+}
+
+// Run will run the prior backup reconciler.
+func (r *Reconciler) Run(ctx context.Context, wg *sync.WaitGroup) {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+	defer wg.Done()
+	slog.Debug(fmt.Sprintf("Prior backup reconciler started and will run every %s", reconcileInterval.String()))
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Debug("Prior backup reconciler received context cancellation")
+			return
+		case <-ticker.C:
+			if err := r.reconcile(ctx); err != nil {
+				slog.Error("Failed to reconcile prior backup tables", log.BBError(err))
+			}
+		}
+	}
+}
+
+// backupTable is a table in a backup database.
+type backupTable struct {
+	instance *store.InstanceMessage
+	// database is the database containing the backup table.
+	// It's the source database on Postgres because the backup database is a schema.
+	database *store.DatabaseMessage
+	// backupDatabase is the backup database name recorded in the prior backup detail.
+	backupDatabase string
+	// schema is the schema of the backup table in the backup database on MSSQL, where the backup tables are in the schemas of the source tables.
+	schema string
+	table  string
+	// issue is the UID of the issue in the comment of the backup table, or zero if it's not tagged.
+	issue int
+	// project and environment are the labels of the backup table in its comment, or empty if it's not labeled.
+	project     string
+	environment string
+}
+
+// getProjectID returns the project of the backup table by its label, falling back to the project of its database.
+func (t *backupTable) getProjectID() string {
+	if t.project != "" {
+		return t.project
+	}
+	return t.database.ProjectID
+}
+
+// getEnvironmentID returns the environment of the backup table by its label, falling back to the effective environment of its database.
+func (t *backupTable) getEnvironmentID() string {
+	if t.environment != "" {
+		return t.environment
+	}
+	return t.database.EffectiveEnvironmentID
+}
+
+func (t *backupTable) key() string {
+	return getBackupTableKey(t.instance.ResourceID, t.backupDatabase, t.table)
+}
+
+func getBackupTableKey(instanceID, backupDatabase, table string) string {
+	return fmt.Sprintf("%s/%s/%s", instanceID, backupDatabase, table)
+}
+
+func (r *Reconciler) reconcile(ctx context.Context) error {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err, ok := rec.(error)
+			if !ok {
+				err = errors.Errorf("%v", rec)
+			}
+			slog.Error("prior backup reconciler PANIC RECOVER", log.BBError(err), log.BBStack("panic-stack"))
+		}
+	}()
+
+	details, err := r.store.ListPriorBackupDetails(ctx, &store.FindPriorBackupDetailMessage{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list prior backup details")
+	}
+	known, err := getKnownBackupTables(details)
+	if err != nil {
+		return err
+	}
+	scheduled, err := getScheduledBackupDrops(details)
+	if err != nil {
+		return err
+	}
+	immutable, err := getImmutableBackupTables(details)
+	if err != nil {
+		return err
+	}
+	tables, err := r.listBackupTables(ctx)
+	if err != nil {
+		return err
+	}
+	retentions, err := r.getBackupRetentions(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The expiries and the orphans are found at the same time.
+	now := r.now()
+	expired := make(map[string]bool)
+	var droppedExpired, droppedOrphans []*backupTable
+	for _, table := range findExpiredBackupTables(tables, retentions, r.profile.PriorBackupEnvironmentRetentions, scheduled, r.profile.PriorBackupRetention, now) {
+		expired[table.key()] = true
+		// The immutable backup tables cannot be dropped until they're idle for the retention days, so they're dropped in a later run.
+		if until, ok := immutable[table.key()]; ok && now.Before(until) {
+			slog.Info("kept the expired immutable prior backup table until it can be dropped",
+				slog.String("instance", table.instance.ResourceID),
+				slog.String("table", table.table),
+				slog.Time("immutableUntil", until),
+			)
+			continue
+		}
+		slog.Info("dropping expired prior backup table",
+			slog.String("instance", table.instance.ResourceID),
+			slog.String("project", table.getProjectID()),
+			slog.String("environment", table.getEnvironmentID()),
+			slog.String("backupDatabase", table.backupDatabase),
+			slog.String("table", table.table),
+		)
+		if err := r.dropBackupTable(ctx, table); err != nil {
+			slog.Error("failed to drop expired prior backup table",
+				slog.String("instance", table.instance.ResourceID),
+				slog.String("table", table.table),
+				log.BBError(err),
+			)
+			continue
+		}
+		droppedExpired = append(droppedExpired, table)
+	}
+	partitions, err := getArchivePartitions(details)
+	if err != nil {
+		return err
+	}
+	for _, partition := range findExpiredArchivePartitions(partitions, retentions, r.profile.PriorBackupRetention, now) {
+		slog.Info("dropping expired prior backup archive partition",
+			slog.String("instance", partition.instanceID),
+			slog.String("backupDatabase", partition.backupDatabase),
+			slog.String("table", partition.table),
+			slog.String("partition", partition.partition),
+		)
+		if err := r.dropArchivePartition(ctx, partition); err != nil {
+			slog.Error("failed to drop expired prior backup archive partition",
+				slog.String("instance", partition.instanceID),
+				slog.String("table", partition.table),
+				slog.String("partition", partition.partition),
+				log.BBError(err),
+			)
+		}
+	}
+	var remaining []*backupTable
+	for _, table := range tables {
+		if !expired[table.key()] {
+			remaining = append(remaining, table)
+		}
+	}
+
+	for _, orphan := range findOrphanBackupTables(remaining, known, now) {
+		slog.Warn("found orphaned prior backup table",
+			slog.String("instance", orphan.instance.ResourceID),
+			slog.String("database", orphan.database.DatabaseName),
+			slog.String("backupDatabase", orphan.backupDatabase),
+			slog.String("table", orphan.table),
+		)
+		if !r.profile.PriorBackupDropOrphans {
+			continue
+		}
+		if err := r.dropBackupTable(ctx, orphan); err != nil {
+			slog.Error("failed to drop orphaned prior backup table",
+				slog.String("instance", orphan.instance.ResourceID),
+				slog.String("table", orphan.table),
+				log.BBError(err),
+			)
+			continue
+		}
+		droppedOrphans = append(droppedOrphans, orphan)
+	}
+
+	for _, comment := range getBackupCleanupComments(droppedExpired, droppedOrphans) {
+		if _, err := r.store.CreateIssueComment(ctx, comment, api.SystemBotID); err != nil {
+			slog.Warn("failed to create issue comment for the prior backup cleanup",
+				slog.Int("issue", comment.IssueUID),
+				log.BBError(err),
+			)
+		}
+	}
+	return nil
+}
+
+// getBackupCleanupComments returns the issue comments recording the dropped backup tables, one for each issue in the order of
+// the first dropped table of the issue. The backup tables not tagged with their issues are not recorded.
+func getBackupCleanupComments(expired, orphans []*backupTable) []*store.IssueCommentMessage {
+	var comments []*store.IssueCommentMessage
+	events := make(map[int]*storepb.IssueCommentPayload_PriorBackupCleanup)
+	lines := make(map[int][]string)
+	add := func(table *backupTable, orphan bool) {
+		if table.issue == 0 {
+			return
+		}
+		event, ok := events[table.issue]
+		if !ok {
+			event = &storepb.IssueCommentPayload_PriorBackupCleanup{}
+			events[table.issue] = event
+			comments = append(comments, &store.IssueCommentMessage{
+				IssueUID: table.issue,
+				Payload: &storepb.IssueCommentPayload{
+					Event: &storepb.IssueCommentPayload_PriorBackupCleanup_{PriorBackupCleanup: event},
+				},
+			})
+		}
+		event.Tables = append(event.Tables, &storepb.IssueCommentPayload_PriorBackupCleanup_Table{
+			Database: common.FormatDatabase(table.instance.ResourceID, table.backupDatabase),
+			Table:    table.table,
+			Orphan:   orphan,
+		})
+		reason := "past the retention"
+		if orphan {
+			reason = "orphaned"
+		}
+		lines[table.issue] = append(lines[table.issue], fmt.Sprintf("- %s.%s: %s", table.backupDatabase, table.table, reason))
+	}
+	for _, table := range expired {
+		add(table, false)
+	}
+	for _, table := range orphans {
+		add(table, true)
+	}
+	for _, comment := range comments {
+		comment.Payload.Comment = fmt.Sprintf("Dropped prior backup tables:\n%s", strings.Join(lines[comment.IssueUID], "\n"))
+	}
+	return comments
+}
+
+// getKnownBackupTables returns the keys of the backup tables recorded in the prior backup details.
+func getKnownBackupTables(details []*storepb.PriorBackupDetail) (map[string]bool, error) {
+	known := make(map[string]bool)
+	for _, detail := range details {
+		for _, item := range detail.GetItems() {
+			// The items exported to the sinks or the object storage have no backup tables.
+			if item.GetTargetTable() == nil {
+				continue
+			}
+			instanceID, databaseName, err := common.GetInstanceDatabaseID(item.GetTargetTable().GetDatabase())
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse backup database %q", item.GetTargetTable().GetDatabase())
+			}
+			known[getBackupTableKey(instanceID, databaseName, item.GetTargetTable().GetTable())] = true
+			// The after-image table is in the same backup database as the backup table.
+			if afterImageTable := item.GetAfterImageTable(); afterImageTable != nil {
+				known[getBackupTableKey(instanceID, databaseName, afterImageTable.GetTable())] = true
+			}
+		}
+	}
+	return known, nil
+}
+
+// getImmutableBackupTables returns the times until which the immutable backup tables in the prior backup details cannot be dropped,
+// keyed by the backup tables.
+func getImmutableBackupTables(details []*storepb.PriorBackupDetail) (map[string]time.Time, error) {
+	immutable := make(map[string]time.Time)
+	for _, detail := range details {
+		for _, item := range detail.GetItems() {
+			if item.GetTargetTable() == nil || item.GetImmutableUntil() == nil {
+				continue
+			}
+			instanceID, databaseName, err := common.GetInstanceDatabaseID(item.GetTargetTable().GetDatabase())
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse backup database %q", item.GetTargetTable().GetDatabase())
+			}
+			immutable[getBackupTableKey(instanceID, databaseName, item.GetTargetTable().GetTable())] = item.GetImmutableUntil().AsTime()
+		}
+	}
+	return immutable, nil
+}
+
+// getScheduledBackupDrops returns the drop times of the backup tables scheduled by the post-success policies in the prior backup details,
+// keyed by the backup tables.
+func getScheduledBackupDrops(details []*storepb.PriorBackupDetail) (map[string]time.Time, error) {
+	scheduled := make(map[string]time.Time)
+	for _, detail := range details {
+		if detail.GetDropTime() == nil {
+			continue
+		}
+		for _, item := range detail.GetItems() {
+			// The items exported to the sinks or the object storage have no backup tables.
+			if item.GetTargetTable() == nil {
+				continue
+			}
+			instanceID, databaseName, err := common.GetInstanceDatabaseID(item.GetTargetTable().GetDatabase())
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse backup database %q", item.GetTargetTable().GetDatabase())
+			}
+			scheduled[getBackupTableKey(instanceID, databaseName, item.GetTargetTable().GetTable())] = detail.GetDropTime().AsTime()
+			if afterImageTable := item.GetAfterImageTable(); afterImageTable != nil {
+				scheduled[getBackupTableKey(instanceID, databaseName, afterImageTable.GetTable())] = detail.GetDropTime().AsTime()
+			}
+		}
+	}
+	return scheduled, nil
+}
+
+// listBackupTables lists the tables in the backup databases from the synced database schemas.
+func (r *Reconciler) listBackupTables(ctx context.Context) ([]*backupTable, error) {
+	instances, err := r.store.ListInstancesV2(ctx, &store.FindInstanceMessage{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list instances")
+	}
+	var tables []*backupTable
+	for _, instance := range instances {
+		if instance.Deleted {
+			continue
+		}
+		databases, err := r.store.ListDatabases(ctx, &store.FindDatabaseMessage{InstanceID: &instance.ResourceID})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list databases of instance %q", instance.ResourceID)
+		}
+		for _, database := range databases {
+			if database.SyncState != api.OK {
+				continue
+			}
+			// The backup database is a schema in the source database on Postgres.
+			if instance.Engine != storepb.Engine_POSTGRES && !isBackupDatabase(database.DatabaseName) {
+				continue
+			}
+			dbSchema, err := r.store.GetDBSchema(ctx, database.UID)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get schema of database %q", database.DatabaseName)
+			}
+			if dbSchema == nil {
+				continue
+			}
+			for _, schema := range dbSchema.GetMetadata().GetSchemas() {
+				backupDatabase := database.DatabaseName
+				var backupSchema string
+				switch instance.Engine {
+				case storepb.Engine_POSTGRES:
+					if !isBackupDatabase(schema.GetName()) {
+						continue
+					}
+					backupDatabase = schema.GetName()
+				case storepb.Engine_MSSQL:
+					backupSchema = schema.GetName()
+				default:
+				}
+				for _, table := range schema.GetTables() {
+					project, environment := getBackupTableLabels(table.GetComment())
+					tables = append(tables, &backupTable{
+						instance:       instance,
+						database:       database,
+						backupDatabase: backupDatabase,
+						schema:         backupSchema,
+						table:          table.GetName(),
+						issue:          getBackupTableIssue(table.GetComment()),
+						project:        project,
+						environment:    environment,
+					})
+				}
+			}
+		}
+	}
+	return tables, nil
+}
+
+// isBackupDatabase returns whether the database is the default or a per-issue backup database.
+func isBackupDatabase(name string) bool {
+	name = strings.ToLower(name)
+	return name == backupDatabaseName || strings.HasPrefix(name, backupDatabaseName+"_issue_")
+}
+
+// getBackupTableTime returns the creation time of the backup table by its name, or false if it's not named by the prior backup.
+func getBackupTableTime(table string) (time.Time, bool) {
+	matches := backupTableRegexp.FindStringSubmatch(table)
+	if matches == nil {
+		return time.Time{}, false
+	}
+	createdTime, err := time.ParseInLocation(backupTableTimeLayout, matches[1], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdTime, true
+}
+
+// getBackupTableIssue returns the issue UID of the backup table by its comment tagged by the prior backup, or zero if it's not tagged.
+func getBackupTableIssue(comment string) int {
+	matches := backupTableIssueRegexp.FindStringSubmatch(comment)
+	if matches == nil {
+		return 0
+	}
+	issue, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0
+	}
+	return issue
+}
+
+// getBackupTableLabels returns the project and environment labels of the backup table by its comment tagged by the prior backup.
+func getBackupTableLabels(comment string) (string, string) {
+	var project, environment string
+	if matches := backupTableProjectRegexp.FindStringSubmatch(comment); matches != nil {
+		project = matches[1]
+	}
+	if matches := backupTableEnvironmentRegexp.FindStringSubmatch(comment); matches != nil {
+		environment = matches[1]
+	}
+	return project, environment
+}
+
+// getBackupRetentions returns the retentions of the prior backup tables configured by the projects, keyed by the project IDs.
+func (r *Reconciler) getBackupRetentions(ctx context.Context) (map[string]time.Duration, error) {
+	projects, err := r.store.ListProjectV2(ctx, &store.FindProjectMessage{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list projects")
+	}
+	retentions := make(map[string]time.Duration)
+	for _, project := range projects {
+		if retention := project.Setting.GetPriorBackupRetention(); retention != nil {
+			retentions[project.ResourceID] = retention.AsDuration()
+		}
+	}
+	return retentions, nil
+}
+
+// findExpiredBackupTables returns the backup tables older than the retentions of their projects by the labels or their databases,
+// falling back to the retentions of their environments and then the default retention, and the backup tables past their scheduled
+// drop times regardless of the retentions. Zero retention keeps the backup tables. The tables not named by the prior backup are ignored.
+func findExpiredBackupTables(tables []*backupTable, retentions, environmentRetentions map[string]time.Duration, scheduled map[string]time.Time, defaultRetention time.Duration, now time.Time) []*backupTable {
+	var expired []*backupTable
+	for _, table := range tables {
+		if dropTime, ok := scheduled[table.key()]; ok && !now.Before(dropTime) {
+			expired = append(expired, table)
+			continue
+		}
+		retention, ok := retentions[table.getProjectID()]
+		if !ok {
+			retention, ok = environmentRetentions[table.getEnvironmentID()]
+		}
+		if !ok {
+			retention = defaultRetention
+		}
+		if retention <= 0 {
+			continue
+		}
+		createdTime, ok := getBackupTableTime(table.table)
+		if !ok {
+			continue
+		}
+		if now.Sub(createdTime) >= retention {
+			expired = append(expired, table)
+		}
+	}
+	return expired
+}
+
+// findOrphanBackupTables returns the backup tables older than the grace period and not recorded in the known backup tables.
+// The tables not named by the prior backup are ignored.
+func findOrphanBackupTables(tables []*backupTable, known map[string]bool, now time.Time) []*backupTable {
+	var orphans []*backupTable
+	for _, table := range tables {
+		createdTime, ok := getBackupTableTime(table.table)
+		if !ok {
+			continue
+		}
+		if now.Sub(createdTime) < orphanGracePeriod {
+			continue
+		}
+		if known[table.key()] {
+			continue
+		}
+		orphans = append(orphans, table)
+	}
+	return orphans
+}
+
+func (r *Reconciler) dropBackupTable(ctx context.Context, table *backupTable) error {
+	statement, err := GetDropBackupTableStatement(table.instance.Engine, table.backupDatabase, table.schema, table.table)
+	if err != nil {
+		return err
+	}
+	driver, err := r.dbFactory.GetAdminDatabaseDriver(ctx, table.instance, table.database, db.ConnectionContext{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get database driver")
+	}
+	defer driver.Close(ctx)
+	if _, err := driver.Execute(ctx, statement, db.ExecuteOptions{}); err != nil {
+		return errors.Wrapf(err, "failed to drop backup table %q", table.table)
+	}
+	return nil
+}
+
+// GetDropBackupTableStatement returns the statement dropping the backup table in the backup database.
+// The schema of the backup table is only used on MSSQL, defaulting to dbo.
+func GetDropBackupTableStatement(engine storepb.Engine, backupDatabase, schema, table string) (string, error) {
+	identifiers := []string{backupDatabase, table}
+	// The backup database is a database instead of a schema on MSSQL, where the backup tables are in the schemas of the source tables.
+	if engine == storepb.Engine_MSSQL {
+		if schema == "" {
+			schema = "dbo"
+		}
+		identifiers = []string{backupDatabase, schema, table}
+	}
+	name, err := base.QuoteQualifiedName(engine, identifiers...)
+	if err != nil {
+		return "", err
+	}
+	// Oracle doesn't support DROP TABLE IF EXISTS.
+	if engine == storepb.Engine_ORACLE {
+		return fmt.Sprintf("DROP TABLE %s;", name), nil
+	}
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s;", name), nil
+}
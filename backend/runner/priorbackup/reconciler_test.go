@@ -0,0 +1,378 @@
+package priorbackup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	// Register the escaping strategies.
+	_ "github.com/bytebase/bytebase/backend/plugin/parser/mysql"
+	_ "github.com/bytebase/bytebase/backend/plugin/parser/pg"
+	_ "github.com/bytebase/bytebase/backend/plugin/parser/tsql"
+	"github.com/bytebase/bytebase/backend/store"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func TestFindOrphanBackupTables(t *testing.T) {
+	a := require.New(t)
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+
+	known, err := getKnownBackupTables([]*storepb.PriorBackupDetail{
+		{
+			Items: []*storepb.PriorBackupDetail_Item{
+				{
+					SourceTable:     &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: "t"},
+					TargetTable:     &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: "_20240101000000_0_t"},
+					AfterImageTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: "_20240101000000_0_t_after"},
+				},
+			},
+		},
+	})
+	a.NoError(err)
+
+	instance := &store.InstanceMessage{ResourceID: "i", Engine: storepb.Engine_MYSQL}
+	database := &store.DatabaseMessage{InstanceID: "i", DatabaseName: "bbdataarchive"}
+	newTable := func(name string) *backupTable {
+		return &backupTable{instance: instance, database: database, backupDatabase: "bbdataarchive", table: name}
+	}
+	tables := []*backupTable{
+		// Recorded in the task run result.
+		newTable("_20240101000000_0_t"),
+		newTable("_20240101000000_0_t_after"),
+		// Orphaned by a crash.
+		newTable("_20240102000000_1_0_t"),
+		// Orphaned by a crash in the namespace of a tenant.
+		newTable("acme_20240102000000_0_t"),
+		// Created by a running task.
+		newTable("_20240109230000_0_t"),
+		// Not created by the prior backup.
+		newTable("t"),
+	}
+
+	orphans := findOrphanBackupTables(tables, known, now)
+	a.Len(orphans, 2)
+	a.Equal("_20240102000000_1_0_t", orphans[0].table)
+	a.Equal("acme_20240102000000_0_t", orphans[1].table)
+
+	statement, err := GetDropBackupTableStatement(storepb.Engine_MYSQL, orphans[0].backupDatabase, orphans[0].schema, orphans[0].table)
+	a.NoError(err)
+	a.Equal("DROP TABLE IF EXISTS `bbdataarchive`.`_20240102000000_1_0_t`;", statement)
+	// The MSSQL backup tables are in the schemas of the source tables.
+	statement, err = GetDropBackupTableStatement(storepb.Engine_MSSQL, "bbdataarchive", "sales", "_20240102000000_0_t")
+	a.NoError(err)
+	a.Equal("DROP TABLE IF EXISTS [bbdataarchive].[sales].[_20240102000000_0_t];", statement)
+	statement, err = GetDropBackupTableStatement(storepb.Engine_MSSQL, "bbdataarchive", "", "_20240102000000_0_t")
+	a.NoError(err)
+	a.Equal("DROP TABLE IF EXISTS [bbdataarchive].[dbo].[_20240102000000_0_t];", statement)
+}
+
+func TestFindExpiredBackupTables(t *testing.T) {
+	a := require.New(t)
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+
+	instance := &store.InstanceMessage{ResourceID: "i", Engine: storepb.Engine_POSTGRES}
+	newTable := func(project, name string) *backupTable {
+		database := &store.DatabaseMessage{InstanceID: "i", DatabaseName: project + "_db", ProjectID: project}
+		return &backupTable{instance: instance, database: database, backupDatabase: "bbdataarchive", table: name}
+	}
+	tables := []*backupTable{
+		// The finance project keeps the backups for 30 days.
+		newTable("finance", "_20231201000000_0_t"),
+		newTable("finance", "_20240101000000_0_t"),
+		// The sandbox project keeps the backups for 1 day.
+		newTable("sandbox", "_20240108000000_0_t"),
+		newTable("sandbox", "_20240109120000_0_t"),
+		// The other projects fall back to the default 7 days.
+		newTable("default", "_20240102000000_0_t"),
+		newTable("default", "_20240104000000_0_t"),
+		// Not created by the prior backup.
+		newTable("sandbox", "t"),
+	}
+	retentions := map[string]time.Duration{
+		"finance": 30 * 24 * time.Hour,
+		"sandbox": 24 * time.Hour,
+	}
+
+	var got []string
+	for _, table := range findExpiredBackupTables(tables, retentions, nil, nil, 7*24*time.Hour, now) {
+		got = append(got, table.database.ProjectID+"/"+table.table)
+	}
+	a.Equal([]string{"finance/_20231201000000_0_t", "sandbox/_20240108000000_0_t", "default/_20240102000000_0_t"}, got)
+
+	// The sandbox backups expire later in the day, and the finance backups stay.
+	got = nil
+	for _, table := range findExpiredBackupTables(tables, retentions, nil, nil, 7*24*time.Hour, now.Add(12*time.Hour)) {
+		got = append(got, table.database.ProjectID+"/"+table.table)
+	}
+	a.Equal([]string{"finance/_20231201000000_0_t", "sandbox/_20240108000000_0_t", "sandbox/_20240109120000_0_t", "default/_20240102000000_0_t"}, got)
+
+	// Zero default retention keeps the backups of the projects without the retention.
+	got = nil
+	for _, table := range findExpiredBackupTables(tables, retentions, nil, nil, 0, now) {
+		got = append(got, table.database.ProjectID+"/"+table.table)
+	}
+	a.Equal([]string{"finance/_20231201000000_0_t", "sandbox/_20240108000000_0_t"}, got)
+}
+
+func TestIsBackupDatabase(t *testing.T) {
+	a := require.New(t)
+	a.True(isBackupDatabase("bbdataarchive"))
+	a.True(isBackupDatabase("BBDATAARCHIVE"))
+	a.True(isBackupDatabase("bbdataarchive_issue_12"))
+	a.False(isBackupDatabase("db"))
+}
+
+func TestGetBackupTableLabels(t *testing.T) {
+	a := require.New(t)
+	project, environment := getBackupTableLabels("issue 1 in namespace acme of project payments in environment prod by alice@example.com at schema version 0001")
+	a.Equal("payments", project)
+	a.Equal("prod", environment)
+	project, environment = getBackupTableLabels("issue 1 of project payments")
+	a.Equal("payments", project)
+	a.Empty(environment)
+	// The backup tables tagged before the labels or not by the prior backup are not labeled.
+	project, environment = getBackupTableLabels("issue 1 by alice@example.com")
+	a.Empty(project)
+	a.Empty(environment)
+	project, _ = getBackupTableLabels("copy of project payments")
+	a.Empty(project)
+
+	// The retention of the labeled project applies to the backup tables in the backup database of another project.
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	instance := &store.InstanceMessage{ResourceID: "i", Engine: storepb.Engine_MYSQL}
+	database := &store.DatabaseMessage{InstanceID: "i", DatabaseName: "bbdataarchive", ProjectID: "default"}
+	tables := []*backupTable{
+		{instance: instance, database: database, backupDatabase: "bbdataarchive", table: "_20240108000000_0_t", project: "sandbox", environment: "test"},
+		{instance: instance, database: database, backupDatabase: "bbdataarchive", table: "_20240108000000_1_t"},
+	}
+	expired := findExpiredBackupTables(tables, map[string]time.Duration{"sandbox": 24 * time.Hour}, nil, nil, 7*24*time.Hour, now)
+	a.Len(expired, 1)
+	a.Equal("_20240108000000_0_t", expired[0].table)
+}
+
+func TestGetScheduledBackupDrops(t *testing.T) {
+	a := require.New(t)
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	newDetail := func(policy storepb.PriorBackupPostSuccessPolicy, dropTime *timestamppb.Timestamp, table string) *storepb.PriorBackupDetail {
+		return &storepb.PriorBackupDetail{
+			Items: []*storepb.PriorBackupDetail_Item{
+				{
+					SourceTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: "t"},
+					TargetTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: table},
+				},
+			},
+			PostSuccessPolicy: policy,
+			DropTime:          dropTime,
+		}
+	}
+	scheduled, err := getScheduledBackupDrops([]*storepb.PriorBackupDetail{
+		// The kept backup tables are only dropped by the retention.
+		newDetail(storepb.PriorBackupPostSuccessPolicy_KEEP, nil, "_20240109000000_0_t"),
+		// The backup tables are dropped after the delay.
+		newDetail(storepb.PriorBackupPostSuccessPolicy_DROP_AFTER, timestamppb.New(now.Add(12*time.Hour)), "_20240109000000_1_t"),
+		// The backup tables failed to be dropped immediately are dropped by the next reconcile.
+		newDetail(storepb.PriorBackupPostSuccessPolicy_DROP_IMMEDIATELY, timestamppb.New(now.Add(-time.Hour)), "_20240109000000_2_t"),
+	})
+	a.NoError(err)
+	a.Len(scheduled, 2)
+
+	instance := &store.InstanceMessage{ResourceID: "i", Engine: storepb.Engine_MYSQL}
+	database := &store.DatabaseMessage{InstanceID: "i", DatabaseName: "bbdataarchive"}
+	var tables []*backupTable
+	for _, name := range []string{"_20240109000000_0_t", "_20240109000000_1_t", "_20240109000000_2_t"} {
+		tables = append(tables, &backupTable{instance: instance, database: database, backupDatabase: "bbdataarchive", table: name})
+	}
+	// The scheduled drops apply even if zero retention keeps the backup tables.
+	var got []string
+	for _, table := range findExpiredBackupTables(tables, nil, nil, scheduled, 0, now) {
+		got = append(got, table.table)
+	}
+	a.Equal([]string{"_20240109000000_2_t"}, got)
+	got = nil
+	for _, table := range findExpiredBackupTables(tables, nil, nil, scheduled, 0, now.Add(12*time.Hour)) {
+		got = append(got, table.table)
+	}
+	a.Equal([]string{"_20240109000000_1_t", "_20240109000000_2_t"}, got)
+}
+
+func TestGetImmutableBackupTables(t *testing.T) {
+	a := require.New(t)
+	immutableUntil := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+	immutable, err := getImmutableBackupTables([]*storepb.PriorBackupDetail{
+		{
+			Items: []*storepb.PriorBackupDetail_Item{
+				{
+					TargetTable:     &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/BBDATAARCHIVE", Table: "_20240110000000_0_T"},
+					AfterImageTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/BBDATAARCHIVE", Table: "_20240110000000_0_T_AFTER"},
+					ImmutableUntil:  timestamppb.New(immutableUntil),
+				},
+				// The mutable backup tables and the items without backup tables are not immutable.
+				{TargetTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/BBDATAARCHIVE", Table: "_20240110000000_1_T"}},
+				{Sink: &storepb.PriorBackupDetail_Item_Sink{Topic: "t"}},
+			},
+		},
+	})
+	a.NoError(err)
+	// The after-image tables are not immutable.
+	a.Equal(map[string]time.Time{getBackupTableKey("i", "BBDATAARCHIVE", "_20240110000000_0_T"): immutableUntil}, immutable)
+
+	_, err = getImmutableBackupTables([]*storepb.PriorBackupDetail{
+		{Items: []*storepb.PriorBackupDetail_Item{{TargetTable: &storepb.PriorBackupDetail_Item_Table{Database: "invalid"}, ImmutableUntil: timestamppb.New(immutableUntil)}}},
+	})
+	a.Error(err)
+}
+
+func TestFindExpiredArchivePartitions(t *testing.T) {
+	a := require.New(t)
+	now := time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC)
+	newDetail := func(stage, database, partition string, end time.Time) *storepb.PriorBackupDetail {
+		return &storepb.PriorBackupDetail{
+			Stage: stage,
+			Items: []*storepb.PriorBackupDetail_Item{
+				{
+					SourceTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/" + database, Table: "t"},
+					TargetTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: "_20240101000000_0_t"},
+					ArchivePartition: &storepb.PriorBackupDetail_Item_ArchivePartition{
+						Table:     &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: "archive_" + database + "_t"},
+						Partition: partition,
+						EndTime:   timestamppb.New(end),
+					},
+				},
+			},
+		}
+	}
+	partitions, err := getArchivePartitions([]*storepb.PriorBackupDetail{
+		newDetail("projects/p1/rollouts/1/stages/1", "db1", "p202401", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)),
+		// Appended to by another task of the same project.
+		newDetail("projects/p1/rollouts/2/stages/2", "db1", "p202401", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)),
+		newDetail("projects/p1/rollouts/3/stages/3", "db1", "p202403", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)),
+		// Shared with a project of a longer retention.
+		newDetail("projects/p1/rollouts/4/stages/4", "db2", "p202402", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)),
+		newDetail("projects/p2/rollouts/5/stages/5", "db2", "p202402", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)),
+		// Not archived.
+		{Items: []*storepb.PriorBackupDetail_Item{{TargetTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: "_20240101000000_0_t"}}}},
+	})
+	a.NoError(err)
+	a.Len(partitions, 4)
+	a.Equal("db1", partitions[0].sourceDatabase)
+	a.Equal("bbdataarchive", partitions[0].backupDatabase)
+
+	// The partitions expire by the end times, after which no rows were appended to them.
+	expired := findExpiredArchivePartitions(partitions, map[string]time.Duration{"p2": 90 * 24 * time.Hour}, 30*24*time.Hour, now)
+	a.Len(expired, 1)
+	a.Equal("archive_db1_t", expired[0].table)
+	a.Equal("p202401", expired[0].partition)
+
+	// Zero retention keeps the partitions.
+	a.Empty(findExpiredArchivePartitions(partitions, nil, 0, now))
+
+	statement, err := GetDropArchivePartitionStatement(storepb.Engine_MYSQL, "bbdataarchive", "archive_db1_t", "p202401", []string{"p202401", "p202403"})
+	a.NoError(err)
+	a.Equal("ALTER TABLE `bbdataarchive`.`archive_db1_t` DROP PARTITION `p202401`;", statement)
+	// The last partition cannot be dropped on MySQL.
+	statement, err = GetDropArchivePartitionStatement(storepb.Engine_MYSQL, "bbdataarchive", "archive_db1_t", "p202401", []string{"p202401"})
+	a.NoError(err)
+	a.Equal("DROP TABLE IF EXISTS `bbdataarchive`.`archive_db1_t`;", statement)
+	statement, err = GetDropArchivePartitionStatement(storepb.Engine_POSTGRES, "bbdataarchive", "archive_public_t", "archive_public_t_p202401", nil)
+	a.NoError(err)
+	a.Equal(`DROP TABLE IF EXISTS "bbdataarchive"."archive_public_t_p202401";`, statement)
+}
+
+func TestFindExpiredBackupTablesByEnvironment(t *testing.T) {
+	a := require.New(t)
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+
+	instance := &store.InstanceMessage{ResourceID: "i", Engine: storepb.Engine_MYSQL}
+	newTable := func(project, environment, label, name string) *backupTable {
+		database := &store.DatabaseMessage{InstanceID: "i", DatabaseName: "bbdataarchive", ProjectID: project, EffectiveEnvironmentID: environment}
+		return &backupTable{instance: instance, database: database, backupDatabase: "bbdataarchive", table: name, environment: label}
+	}
+	tables := []*backupTable{
+		// The test environment keeps the backups for 1 day.
+		newTable("default", "test", "", "_20240108000000_0_t"),
+		// The label takes precedence over the environment of the backup database.
+		newTable("default", "prod", "test", "_20240108000000_1_t"),
+		// The prod environment keeps the backups for 30 days.
+		newTable("default", "prod", "", "_20240101000000_0_t"),
+		// The retention of the project takes precedence over the environment.
+		newTable("finance", "test", "", "_20240108000000_2_t"),
+		// The other environments fall back to the default 7 days.
+		newTable("default", "staging", "", "_20240102000000_0_t"),
+	}
+	retentions := map[string]time.Duration{"finance": 30 * 24 * time.Hour}
+	environmentRetentions := map[string]time.Duration{
+		"test": 24 * time.Hour,
+		"prod": 30 * 24 * time.Hour,
+	}
+
+	var got []string
+	for _, table := range findExpiredBackupTables(tables, retentions, environmentRetentions, nil, 7*24*time.Hour, now) {
+		got = append(got, table.table)
+	}
+	a.Equal([]string{"_20240108000000_0_t", "_20240108000000_1_t", "_20240102000000_0_t"}, got)
+}
+
+func TestGetBackupCleanupComments(t *testing.T) {
+	a := require.New(t)
+	a.Equal(12, getBackupTableIssue("issue 12 of project payments in environment prod by alice@example.com"))
+	a.Equal(0, getBackupTableIssue("issue12"))
+	a.Equal(0, getBackupTableIssue("copy of issue 12"))
+
+	instance := &store.InstanceMessage{ResourceID: "i", Engine: storepb.Engine_MYSQL}
+	database := &store.DatabaseMessage{InstanceID: "i", DatabaseName: "bbdataarchive"}
+	newTable := func(issue int, name string) *backupTable {
+		return &backupTable{instance: instance, database: database, backupDatabase: "bbdataarchive", table: name, issue: issue}
+	}
+	comments := getBackupCleanupComments(
+		[]*backupTable{newTable(2, "_20240101000000_0_t"), newTable(1, "_20240101000000_0_u"), newTable(0, "_20240101000000_0_v")},
+		[]*backupTable{newTable(2, "_20240102000000_0_t")},
+	)
+	a.Len(comments, 2)
+	a.Equal(2, comments[0].IssueUID)
+	a.Equal("Dropped prior backup tables:\n- bbdataarchive._20240101000000_0_t: past the retention\n- bbdataarchive._20240102000000_0_t: orphaned", comments[0].Payload.Comment)
+	tables := comments[0].Payload.GetPriorBackupCleanup().GetTables()
+	a.Len(tables, 2)
+	a.Equal("instances/i/databases/bbdataarchive", tables[0].Database)
+	a.False(tables[0].Orphan)
+	a.True(tables[1].Orphan)
+	a.Equal(1, comments[1].IssueUID)
+	a.Len(comments[1].Payload.GetPriorBackupCleanup().GetTables(), 1)
+
+	// The items without the backup tables, e.g. exported to the object storage, are skipped.
+	known, err := getKnownBackupTables([]*storepb.PriorBackupDetail{
+		{
+			Items: []*storepb.PriorBackupDetail_Item{
+				{SourceTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: "t"}, ObjectKey: "prior-backup-exports/0_t.csv.gz"},
+			},
+		},
+	})
+	a.NoError(err)
+	a.Empty(known)
+}
+
+// fakeClock is the clock stopped at the time.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestReconcilerClock(t *testing.T) {
+	a := require.New(t)
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+
+	// The reconciler measures the expiries against its clock.
+	r := &Reconciler{clock: fakeClock{now: now}}
+	a.Equal(now, r.now())
+	tables := []*backupTable{{instance: &store.InstanceMessage{ResourceID: "i"}, database: &store.DatabaseMessage{DatabaseName: "db"}, backupDatabase: "bbdataarchive", table: "_20240101000000_0_t"}}
+	a.Len(findExpiredBackupTables(tables, nil, nil, nil, 7*24*time.Hour, r.now()), 1)
+	r.clock = fakeClock{now: now.Add(-3 * 24 * time.Hour)}
+	a.Empty(findExpiredBackupTables(tables, nil, nil, nil, 7*24*time.Hour, r.now()))
+
+	// The reconciler falls back to the wall time without a clock.
+	a.WithinDuration(time.Now(), (&Reconciler{}).now(), time.Minute)
+}
@@ -0,0 +1,540 @@
+package taskrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/conc/pool"
+
+	"github.com/bytebase/bytebase/backend/common"
+	"github.com/bytebase/bytebase/backend/common/log"
+	"github.com/bytebase/bytebase/backend/component/dbfactory"
+	"github.com/bytebase/bytebase/backend/component/state"
+	api "github.com/bytebase/bytebase/backend/legacyapi"
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	"github.com/bytebase/bytebase/backend/store"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+// NewDataRollbackExecutor creates a data rollback task executor.
+func NewDataRollbackExecutor(store *store.Store, dbFactory *dbfactory.DBFactory, stateCfg *state.State, objectStore common.PriorBackupObjectStore) Executor {
+	return &DataRollbackExecutor{
+		store:       store,
+		dbFactory:   dbFactory,
+		stateCfg:    stateCfg,
+		objectStore: objectStore,
+		clock:       realClock{},
+		idGenerator: randomIDGenerator{},
+	}
+}
+
+// DataRollbackExecutor is the data rollback task executor. It rolls back a data update by restoring the rows
+// from the prior backup tables of its task run, inserting back the deleted rows and updating back the changed columns.
+// The rows exported to the object store are staged into the tables first. The progress is published to the state
+// by the restored tables and chunks.
+type DataRollbackExecutor struct {
+	store       *store.Store
+	dbFactory   *dbfactory.DBFactory
+	stateCfg    *state.State
+	objectStore common.PriorBackupObjectStore
+	// clock tells the time of the progress.
+	clock Clock
+	// idGenerator generates the IDs of the staging tables, which tell apart the concurrent rollbacks of the same task run.
+	idGenerator IDGenerator
+}
+
+// RunOnce will run the data rollback task executor once.
+func (exec *DataRollbackExecutor) RunOnce(ctx context.Context, driverCtx context.Context, task *store.TaskMessage, rollbackTaskRunUID int) (bool, *storepb.TaskRunResult, error) {
+	payload := &storepb.TaskDatabaseDataRollbackPayload{}
+	if err := common.ProtojsonUnmarshaler.Unmarshal([]byte(task.Payload), payload); err != nil {
+		return true, nil, errors.Wrap(err, "invalid database data rollback payload")
+	}
+	taskRunUID := int(payload.TaskRunUid)
+	taskRuns, err := exec.store.ListTaskRunsV2(ctx, &store.FindTaskRunMessage{UID: &taskRunUID})
+	if err != nil {
+		return true, nil, errors.Wrapf(err, "failed to get task run %d", taskRunUID)
+	}
+	if len(taskRuns) == 0 {
+		return true, nil, errors.Errorf("task run %d not found", taskRunUID)
+	}
+	taskRun := taskRuns[0]
+	if taskRun.Status != api.TaskRunDone {
+		return true, nil, errors.Errorf("task run %d of the data update is %s, only the done data updates can be rolled back", taskRunUID, taskRun.Status)
+	}
+	updateTask, err := exec.store.GetTaskV2ByID(ctx, taskRun.TaskUID)
+	if err != nil {
+		return true, nil, errors.Wrapf(err, "failed to get task %d", taskRun.TaskUID)
+	}
+	if updateTask == nil || updateTask.Type != api.TaskDatabaseDataUpdate {
+		return true, nil, errors.Errorf("task run %d is not of a data update task", taskRunUID)
+	}
+	if updateTask.DatabaseID == nil || task.DatabaseID == nil || *updateTask.DatabaseID != *task.DatabaseID {
+		return true, nil, errors.Errorf("task run %d did not update the database of the rollback task", taskRunUID)
+	}
+	updatePayload := &storepb.TaskDatabaseUpdatePayload{}
+	if err := common.ProtojsonUnmarshaler.Unmarshal([]byte(updateTask.Payload), updatePayload); err != nil {
+		return true, nil, errors.Wrap(err, "invalid database data update payload")
+	}
+	statement, err := exec.store.GetSheetStatementByID(ctx, int(updatePayload.SheetId))
+	if err != nil {
+		return true, nil, err
+	}
+
+	database, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{UID: task.DatabaseID})
+	if err != nil {
+		return true, nil, errors.Wrap(err, "failed to get database")
+	}
+	if database == nil {
+		return true, nil, errors.Errorf("database not found")
+	}
+	instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{ResourceID: &database.InstanceID})
+	if err != nil {
+		return true, nil, errors.Wrap(err, "failed to get instance")
+	}
+	if instance == nil {
+		return true, nil, errors.Errorf("instance not found")
+	}
+
+	driver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, database, db.ConnectionContext{})
+	if err != nil {
+		return true, nil, errors.Wrap(err, "failed to get database driver")
+	}
+	defer driver.Close(driverCtx)
+
+	stagingID, err := exec.idGenerator.NewID()
+	if err != nil {
+		return true, nil, err
+	}
+	detail, dropStatements, err := stageBackupExports(driverCtx, driver.GetDB(), exec.objectStore, instance.Engine, taskRunUID, stagingID, taskRun.ResultProto.GetPriorBackupDetail())
+	// The staging tables are only for the rollback.
+	defer func() {
+		for _, dropStatement := range dropStatements {
+			if _, err := driver.GetDB().ExecContext(driverCtx, dropStatement); err != nil {
+				slog.Warn("failed to drop prior backup staging table", slog.String("statement", dropStatement), log.BBError(err))
+			}
+		}
+	}()
+	if err != nil {
+		return true, nil, err
+	}
+	rCtx := base.RestoreContext{
+		InstanceID:              instance.ResourceID,
+		GetDatabaseMetadataFunc: BuildGetDatabaseMetadataFunc(exec.store),
+		ConflictStrategy:        base.RestoreConflictStrategy(strings.ToUpper(payload.ConflictStrategy)),
+	}
+	statements, err := GetRollbackStatements(ctx, instance.Engine, rCtx, statement, common.FormatDatabase(instance.ResourceID, database.DatabaseName), detail)
+	if err != nil {
+		return true, nil, err
+	}
+	progress := newRollbackProgress(exec.stateCfg, exec.clock, task.ID, statements)
+	opts := db.ExecuteOptions{
+		SetConnectionID: func(id string) {
+			exec.stateCfg.TaskRunConnectionID.Store(rollbackTaskRunUID, id)
+		},
+		DeleteConnectionID: func() {
+			exec.stateCfg.TaskRunConnectionID.Delete(rollbackTaskRunUID)
+		},
+	}
+	if payload.Parallelism > 1 {
+		dbSchema, err := exec.store.GetDBSchema(ctx, database.UID)
+		if err != nil {
+			return true, nil, errors.Wrap(err, "failed to get database schema")
+		}
+		// The foreign keys ordering the tables are unknown without the synced schema.
+		if dbSchema == nil {
+			return true, nil, errors.Errorf("the schema of database %q is not synced for the parallel rollback", database.DatabaseName)
+		}
+		references := getForeignKeyReferences(instance.Engine, dbSchema.GetMetadata())
+		// The task run has one connection id, which doesn't track the connections of the concurrent tables.
+		if err := executeRollbackStatementsInParallel(driverCtx, driver, instance.Engine, statements, references, int(payload.Parallelism), db.ExecuteOptions{}, progress); err != nil {
+			return true, nil, err
+		}
+	} else if err := executeRollbackStatements(driverCtx, driver, instance.Engine, statements, opts, progress); err != nil {
+		return true, nil, err
+	}
+	return true, &storepb.TaskRunResult{
+		Detail: fmt.Sprintf("Rolled back the data update of task run %d on %d tables from %d prior backup tables", taskRunUID, countRollbackTables(statements), len(statements)),
+	}, nil
+}
+
+// RollbackStatement is the statement restoring the rows of a prior backup table into its source table.
+// The backup of a source table may be split into multiple backup tables.
+type RollbackStatement struct {
+	// Table is the source table in the format of schema.table with the default schema of the engine, or table if the engine has no schemas.
+	Table string
+	// BackupTable is the prior backup table restored from.
+	BackupTable string
+	Statement   string
+}
+
+// executeRollbackStatements executes the rollback statements by the driver in one transaction, so that the rollback is never applied partially.
+// Each statement is a chunk of the rollback, completed once the driver responds to its last command. The driver stops before the next
+// chunk once the context is canceled. The drivers executing a large script as one command complete all the chunks on the commit.
+func executeRollbackStatements(ctx context.Context, driver db.Driver, engine storepb.Engine, statements []RollbackStatement, opts db.ExecuteOptions, progress *rollbackProgress) error {
+	script, lastCommandIndexes, err := getRollbackScript(engine, statements)
+	if err != nil {
+		return err
+	}
+	completed := 0
+	var failed *RollbackStatement
+	opts.CreateTaskRunLog = func(_ time.Time, e *storepb.TaskRunLog) error {
+		response := e.GetCommandResponse()
+		if e.Type != storepb.TaskRunLog_COMMAND_RESPONSE || len(response.GetCommandIndexes()) == 0 || completed >= len(statements) {
+			return nil
+		}
+		index := int(slices.Max(response.GetCommandIndexes()))
+		if response.GetError() != "" {
+			// The statement with the failed command is the first one not completed.
+			failed = &statements[completed]
+			return nil
+		}
+		for completed < len(statements) && lastCommandIndexes[completed] <= index {
+			progress.complete(statements[completed].Table)
+			completed++
+		}
+		return nil
+	}
+	if _, err := driver.Execute(ctx, script, opts); err != nil {
+		if ctx.Err() != nil {
+			return progress.cancel(ctx)
+		}
+		if failed != nil {
+			err = errors.Wrapf(err, "failed to restore table %q from backup table %q", failed.Table, failed.BackupTable)
+			progress.fail(failed.Table, err)
+			return err
+		}
+		err = errors.Wrap(err, "failed to execute rollback statements")
+		progress.fail("", err)
+		return err
+	}
+	for ; completed < len(statements); completed++ {
+		progress.complete(statements[completed].Table)
+	}
+	return nil
+}
+
+// getRollbackScript joins the rollback statements into the script executed by the driver,
+// and returns the index of the last command of each statement in the script.
+func getRollbackScript(engine storepb.Engine, statements []RollbackStatement) (string, []int, error) {
+	var buf strings.Builder
+	var lastCommandIndexes []int
+	commands := 0
+	for _, statement := range statements {
+		list, err := base.SplitMultiSQL(engine, statement.Statement)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "failed to split the restore statement of table %q", statement.Table)
+		}
+		commands += max(len(base.FilterEmptySQL(list)), 1)
+		lastCommandIndexes = append(lastCommandIndexes, commands-1)
+		text := strings.TrimSpace(statement.Statement)
+		if !strings.HasSuffix(text, ";") {
+			text += ";"
+		}
+		if _, err := buf.WriteString(text + "\n"); err != nil {
+			return "", nil, err
+		}
+	}
+	return buf.String(), lastCommandIndexes, nil
+}
+
+// executeRollbackStatementsInParallel restores the tables of the rollback statements concurrently by at most parallelism workers,
+// each table in its own transaction. The tables wait for the tables referenced by their foreign keys, which are the keys of the
+// references, to be restored first, and the first failure cancels the others. The tables restored before the failure stay restored,
+// so the failure applies the rollback partially, and the error names the restored tables.
+func executeRollbackStatementsInParallel(ctx context.Context, driver db.Driver, engine storepb.Engine, statements []RollbackStatement, references map[string][]string, parallelism int, opts db.ExecuteOptions, progress *rollbackProgress) error {
+	// The statements of each table keep their order.
+	var tables []string
+	tableStatements := make(map[string][]RollbackStatement)
+	for _, statement := range statements {
+		if _, ok := tableStatements[statement.Table]; !ok {
+			tables = append(tables, statement.Table)
+		}
+		tableStatements[statement.Table] = append(tableStatements[statement.Table], statement)
+	}
+	tables = orderRollbackTablesByDependency(tables, references)
+	// The tables are submitted in the dependency order, so that the earliest table not restored never waits for a worker.
+	restored := make(map[string]chan struct{})
+	for _, table := range tables {
+		restored[table] = make(chan struct{})
+	}
+	p := pool.New().WithErrors().WithContext(ctx).WithCancelOnError().WithFirstError().WithMaxGoroutines(parallelism)
+	for i, table := range tables {
+		// Only the tables earlier in the order are waited for, which breaks the dependency cycles.
+		var dependencies []chan struct{}
+		for _, referenced := range references[table] {
+			if j := slices.Index(tables, referenced); j >= 0 && j < i {
+				dependencies = append(dependencies, restored[referenced])
+			}
+		}
+		p.Go(func(ctx context.Context) error {
+			for _, dependency := range dependencies {
+				select {
+				case <-dependency:
+				case <-ctx.Done():
+					return progress.cancel(ctx)
+				}
+			}
+			if err := executeRollbackStatements(ctx, driver, engine, tableStatements[table], opts, progress); err != nil {
+				return err
+			}
+			close(restored[table])
+			return nil
+		})
+	}
+	if err := p.Wait(); err != nil {
+		if restored := progress.getRestoredTables(); len(restored) > 0 {
+			return errors.Wrapf(err, "the rollback is applied partially, where the tables %s stay restored", strings.Join(restored, ", "))
+		}
+		return err
+	}
+	return nil
+}
+
+// orderRollbackTablesByDependency orders the tables topologically by the references, so that the referenced tables come first.
+// The tables keep their original order otherwise, and the tables in a dependency cycle are left in their original order after the others.
+func orderRollbackTablesByDependency(tables []string, references map[string][]string) []string {
+	var ordered []string
+	emitted := make(map[string]bool)
+	for progress := true; progress; {
+		progress = false
+		for _, table := range tables {
+			if emitted[table] {
+				continue
+			}
+			ready := true
+			for _, referenced := range references[table] {
+				if slices.Contains(tables, referenced) && !emitted[referenced] {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+			ordered = append(ordered, table)
+			emitted[table] = true
+			progress = true
+		}
+	}
+	for _, table := range tables {
+		if !emitted[table] {
+			ordered = append(ordered, table)
+		}
+	}
+	return ordered
+}
+
+// rollbackProgress publishes the progress of the rollback to the state, where the units are the chunks of the rollback.
+// It's shared by the tables restored concurrently.
+type rollbackProgress struct {
+	stateCfg  *state.State
+	clock     Clock
+	taskID    int
+	createdTs int64
+
+	mu      sync.Mutex
+	payload rollbackProgressPayload
+}
+
+// rollbackProgressPayload is the payload of the rollback progress with the progress of each table.
+type rollbackProgressPayload struct {
+	Tables []*rollbackTableProgress `json:"tables"`
+	// Canceled is whether the rollback is canceled. The chunks of the uncommitted transactions are rolled back,
+	// while the tables already committed by the parallel rollback stay restored.
+	Canceled bool `json:"canceled"`
+	// Failed is whether the rollback failed. The parallel rollback cancels the other tables on the failure,
+	// which is recorded as the failure rather than the cancellation.
+	Failed bool `json:"failed"`
+}
+
+// rollbackTableProgress is the progress of restoring a source table, whose chunks are the restore statements of its prior backup tables.
+type rollbackTableProgress struct {
+	Table           string `json:"table"`
+	TotalChunks     int64  `json:"totalChunks"`
+	CompletedChunks int64  `json:"completedChunks"`
+	// Error is the error restoring the table.
+	Error string `json:"error,omitempty"`
+}
+
+// newRollbackProgress returns the progress of the rollback statements, and publishes it without the completed chunks.
+func newRollbackProgress(stateCfg *state.State, clock Clock, taskID int, statements []RollbackStatement) *rollbackProgress {
+	p := &rollbackProgress{stateCfg: stateCfg, clock: clock, taskID: taskID, createdTs: clock.Now().Unix()}
+	for _, statement := range statements {
+		if table := p.getTable(statement.Table); table != nil {
+			table.TotalChunks++
+			continue
+		}
+		p.payload.Tables = append(p.payload.Tables, &rollbackTableProgress{Table: statement.Table, TotalChunks: 1})
+	}
+	p.publish()
+	return p
+}
+
+// getRestoredTables returns the tables with all the chunks completed.
+func (p *rollbackProgress) getRestoredTables() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var tables []string
+	for _, t := range p.payload.Tables {
+		if t.CompletedChunks == t.TotalChunks {
+			tables = append(tables, t.Table)
+		}
+	}
+	return tables
+}
+
+func (p *rollbackProgress) getTable(table string) *rollbackTableProgress {
+	for _, t := range p.payload.Tables {
+		if t.Table == table {
+			return t
+		}
+	}
+	return nil
+}
+
+// complete completes a chunk of the table.
+func (p *rollbackProgress) complete(table string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.getTable(table).CompletedChunks++
+	p.publish()
+}
+
+// fail records the failure of the rollback, and the error of the table if the failed table is known.
+func (p *rollbackProgress) fail(table string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.payload.Failed = true
+	if t := p.getTable(table); t != nil {
+		t.Error = err.Error()
+	}
+	p.publish()
+}
+
+// cancel records the cancellation of the rollback and returns the error of it.
+// The tables canceled by the failure of another table don't record the cancellation, because the failure is recorded first.
+func (p *rollbackProgress) cancel(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.payload.Failed {
+		p.payload.Canceled = true
+	}
+	total, completed := p.publish()
+	if p.payload.Failed {
+		return errors.Wrapf(ctx.Err(), "rollback is stopped by the failure after restoring %d of %d chunks", completed, total)
+	}
+	return errors.Wrapf(ctx.Err(), "rollback is canceled after restoring %d of %d chunks, and the chunks of the uncommitted transactions are rolled back", completed, total)
+}
+
+// publish publishes the progress and returns the total and completed chunks. The caller holds the lock except on creation.
+func (p *rollbackProgress) publish() (int64, int64) {
+	var total, completed int64
+	for _, t := range p.payload.Tables {
+		total += t.TotalChunks
+		completed += t.CompletedChunks
+	}
+	payload, err := json.Marshal(p.payload)
+	if err != nil {
+		slog.Warn("failed to marshal rollback progress", log.BBError(err))
+	}
+	p.stateCfg.TaskProgress.Store(p.taskID, api.Progress{
+		TotalUnit:     total,
+		CompletedUnit: completed,
+		CreatedTs:     p.createdTs,
+		UpdatedTs:     p.clock.Now().Unix(),
+		Payload:       string(payload),
+	})
+	return total, completed
+}
+
+// countRollbackTables returns the number of the source tables restored by the rollback statements.
+func countRollbackTables(statements []RollbackStatement) int {
+	tables := map[string]bool{}
+	for _, statement := range statements {
+		tables[statement.Table] = true
+	}
+	return len(tables)
+}
+
+// GetRollbackStatements returns the statements restoring the rows of the data update from its prior backup tables in the database,
+// in the reverse order of the DML, so that the rows changed by multiple statements end up as they were before the first one.
+// The database is in the format of instances/{instance}/databases/{database}. The restore statements recorded at backup time are used
+// for the default conflict strategy, and generated from the DML of each item otherwise. The restore statements of the whole tables
+// backed up are always generated, since the ones recorded before may conflict with the rows left untouched by the DML.
+func GetRollbackStatements(ctx context.Context, engine storepb.Engine, rCtx base.RestoreContext, statement, database string, detail *storepb.PriorBackupDetail) ([]RollbackStatement, error) {
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_POSTGRES:
+	default:
+		return nil, errors.Errorf("data rollback is not supported for engine %s", engine)
+	}
+	if len(detail.GetItems()) == 0 {
+		return nil, errors.New("the data update has no prior backup to roll back from")
+	}
+	// The sample backups miss the rows by design.
+	if detail.GetSampleRate() > 0 || detail.GetSampleLimit() > 0 {
+		return nil, errors.New("the sample prior backup cannot be rolled back from")
+	}
+	if detail.GetIncomplete() {
+		return nil, errors.New("the incomplete prior backup cannot be rolled back from")
+	}
+	if detail.GetDropTime() != nil {
+		return nil, errors.Errorf("the prior backup tables are dropped by the post-success policy at %s", detail.GetDropTime().AsTime().Format(time.RFC3339))
+	}
+	_, databaseName, err := common.GetInstanceDatabaseID(database)
+	if err != nil {
+		return nil, err
+	}
+	list, err := base.SplitMultiSQL(engine, statement)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to split statement")
+	}
+
+	var statements []RollbackStatement
+	items := slices.Clone(detail.GetItems())
+	slices.Reverse(items)
+	for _, item := range items {
+		if item.GetTargetTable() == nil {
+			return nil, errors.Errorf("the prior backup of table %q has no backup table", item.GetSourceTable().GetTable())
+		}
+		// The backups of the shards are rolled back by the tasks of the shards.
+		if item.GetSourceTable().GetDatabase() != database {
+			continue
+		}
+		rollbackStatement := RollbackStatement{
+			Table:       getTableKey(engine, item.GetSourceTable().GetSchema(), item.GetSourceTable().GetTable()),
+			BackupTable: item.GetTargetTable().GetTable(),
+		}
+		if item.GetRestoreStatement() != "" && rCtx.ConflictStrategy == base.RestoreConflictDefault && item.GetFullTableReason() == "" {
+			rollbackStatement.Statement = item.GetRestoreStatement()
+			statements = append(statements, rollbackStatement)
+			continue
+		}
+		dml := getBackupItemStatement(list, item.GetStartPosition())
+		if dml == "" {
+			return nil, errors.Errorf("failed to find the statement backed up into table %q", item.GetTargetTable().GetTable())
+		}
+		// The backup database name is the backup schema in the source database on Postgres.
+		_, backupDatabaseName, err := common.GetInstanceDatabaseID(item.GetTargetTable().GetDatabase())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse backup database %q", item.GetTargetTable().GetDatabase())
+		}
+		restoreStatement, err := base.GenerateRestoreSQL(ctx, engine, getItemRestoreContext(rCtx, item), dml, backupDatabaseName, item.GetTargetTable().GetTable(), databaseName, item.GetSourceTable().GetTable())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to generate the restore statement of backup table %q", item.GetTargetTable().GetTable())
+		}
+		rollbackStatement.Statement = restoreStatement
+		statements = append(statements, rollbackStatement)
+	}
+	if len(statements) == 0 {
+		return nil, errors.Errorf("the data update has no prior backup of database %q", database)
+	}
+	return statements, nil
+}
@@ -0,0 +1,399 @@
+package taskrun
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/bytebase/bytebase/backend/component/state"
+	api "github.com/bytebase/bytebase/backend/legacyapi"
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	"github.com/bytebase/bytebase/backend/store/model"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func TestGetRollbackStatements(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	getDatabaseMetadata := func(_ context.Context, _, database string) (string, *model.DatabaseMetadata, error) {
+		return database, model.NewDatabaseMetadata(&storepb.DatabaseSchemaMetadata{
+			Name: database,
+			Schemas: []*storepb.SchemaMetadata{
+				{
+					Name: "public",
+					Tables: []*storepb.TableMetadata{
+						{
+							Name:    "t",
+							Columns: []*storepb.ColumnMetadata{{Name: "id"}, {Name: "a"}},
+							Indexes: []*storepb.IndexMetadata{{Name: "t_pkey", Expressions: []string{"id"}, Primary: true, Unique: true}},
+						},
+					},
+				},
+			},
+		}), nil
+	}
+	rCtx := base.RestoreContext{GetDatabaseMetadataFunc: getDatabaseMetadata}
+	statement := "UPDATE t SET a = 1 WHERE id = 1;\nDELETE FROM t WHERE id = 2;"
+	detail := &storepb.PriorBackupDetail{
+		Items: []*storepb.PriorBackupDetail_Item{
+			{
+				SourceTable:      &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: "t"},
+				TargetTable:      &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: "_0_0_t"},
+				StartPosition:    &storepb.Position{Line: 1},
+				RestoreStatement: "/* restore update */",
+			},
+			{
+				SourceTable:   &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: "t"},
+				TargetTable:   &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: "_0_1_t"},
+				StartPosition: &storepb.Position{Line: 2},
+			},
+			// The backup of the shard is rolled back by the task of the shard.
+			{
+				SourceTable:      &storepb.PriorBackupDetail_Item_Table{Database: "instances/shard/databases/db", Table: "t"},
+				TargetTable:      &storepb.PriorBackupDetail_Item_Table{Database: "instances/shard/databases/bbdataarchive", Table: "_0_0_t"},
+				StartPosition:    &storepb.Position{Line: 1},
+				RestoreStatement: "/* restore shard */",
+			},
+		},
+	}
+
+	// The statements are in the reverse order of the DML, and the restore statements without the records at backup time are generated.
+	statements, err := GetRollbackStatements(ctx, storepb.Engine_POSTGRES, rCtx, statement, "instances/i/databases/db", detail)
+	a.NoError(err)
+	a.Equal([]RollbackStatement{
+		{
+			Table:       "public.t",
+			BackupTable: "_0_1_t",
+			Statement: `/*
+Original SQL:
+
+DELETE FROM t WHERE id = 2;
+*/
+INSERT INTO "public"."t" ("id", "a") SELECT "id", "a" FROM "bbdataarchive"."_0_1_t";`,
+		},
+		{Table: "public.t", BackupTable: "_0_0_t", Statement: "/* restore update */"},
+	}, statements)
+	a.Equal(1, countRollbackTables(statements))
+
+	// The whole table backed up for the uncertain rows overwrites the rows left untouched by the DML instead of conflicting with them.
+	fullTable := &storepb.PriorBackupDetail{
+		Items: []*storepb.PriorBackupDetail_Item{
+			{
+				SourceTable:      &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Schema: "public", Table: "t"},
+				TargetTable:      &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: "_0_1_t"},
+				StartPosition:    &storepb.Position{Line: 2},
+				RestoreStatement: `INSERT INTO "public"."t" ("id", "a") SELECT "id", "a" FROM "bbdataarchive"."_0_1_t";`,
+				FullTableReason:  "uncertain predicate",
+			},
+		},
+	}
+	statements, err = GetRollbackStatements(ctx, storepb.Engine_POSTGRES, rCtx, statement, "instances/i/databases/db", fullTable)
+	a.NoError(err)
+	a.Len(statements, 1)
+	a.Equal("public.t", statements[0].Table)
+	a.Contains(statements[0].Statement, `INSERT INTO "public"."t" ("id", "a") SELECT "id", "a" FROM "bbdataarchive"."_0_1_t" ON CONFLICT ("id") DO UPDATE SET "a" = EXCLUDED."a";`)
+
+	// The restore statements are generated for the conflict strategy other than the default one.
+	rCtx.ConflictStrategy = base.RestoreConflictSkip
+	statements, err = GetRollbackStatements(ctx, storepb.Engine_POSTGRES, rCtx, statement, "instances/i/databases/db", detail)
+	a.NoError(err)
+	a.Len(statements, 2)
+	a.Contains(statements[1].Statement, `INSERT INTO "public"."t" ("id", "a") SELECT "id", "a" FROM "bbdataarchive"."_0_0_t" ON CONFLICT DO NOTHING;`)
+
+	_, err = GetRollbackStatements(ctx, storepb.Engine_POSTGRES, rCtx, statement, "instances/i/databases/other", detail)
+	a.ErrorContains(err, `the data update has no prior backup of database "instances/i/databases/other"`)
+	_, err = GetRollbackStatements(ctx, storepb.Engine_ORACLE, rCtx, statement, "instances/i/databases/db", detail)
+	a.ErrorContains(err, "data rollback is not supported for engine ORACLE")
+	_, err = GetRollbackStatements(ctx, storepb.Engine_POSTGRES, rCtx, statement, "instances/i/databases/db", nil)
+	a.ErrorContains(err, "the data update has no prior backup to roll back from")
+	_, err = GetRollbackStatements(ctx, storepb.Engine_POSTGRES, rCtx, statement, "instances/i/databases/db", &storepb.PriorBackupDetail{Items: detail.Items, SampleRate: 10})
+	a.ErrorContains(err, "the sample prior backup cannot be rolled back from")
+	_, err = GetRollbackStatements(ctx, storepb.Engine_POSTGRES, rCtx, statement, "instances/i/databases/db", &storepb.PriorBackupDetail{Items: detail.Items, Incomplete: true, TimedOut: true})
+	a.ErrorContains(err, "the incomplete prior backup cannot be rolled back from")
+	_, err = GetRollbackStatements(ctx, storepb.Engine_POSTGRES, rCtx, statement, "instances/i/databases/db", &storepb.PriorBackupDetail{
+		Items:    detail.Items,
+		DropTime: timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+	})
+	a.ErrorContains(err, "the prior backup tables are dropped by the post-success policy at 2024-01-02T03:04:05Z")
+	// The backups published to the sinks have no backup tables.
+	_, err = GetRollbackStatements(ctx, storepb.Engine_POSTGRES, rCtx, statement, "instances/i/databases/db", &storepb.PriorBackupDetail{
+		Items: []*storepb.PriorBackupDetail_Item{{SourceTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: "t"}}},
+	})
+	a.ErrorContains(err, `the prior backup of table "t" has no backup table`)
+}
+
+// rollbackDriver is a driver executing the commands of the statement in one transaction like the MySQL and Postgres drivers,
+// and logging the responses of the commands.
+type rollbackDriver struct {
+	db.Driver
+
+	sqlDB *sql.DB
+}
+
+func (d *rollbackDriver) Execute(ctx context.Context, statement string, opts db.ExecuteOptions) (int64, error) {
+	list, err := base.SplitMultiSQL(storepb.Engine_MYSQL, statement)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := d.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for i, command := range base.FilterEmptySQL(list) {
+		indexes := []int32{int32(i)}
+		opts.LogCommandExecute(indexes)
+		if _, err := tx.ExecContext(ctx, command.Text); err != nil {
+			opts.LogCommandResponse(indexes, 0, nil, err.Error())
+			return 0, err
+		}
+		opts.LogCommandResponse(indexes, 0, nil, "")
+	}
+	return 0, tx.Commit()
+}
+
+func TestExecuteRollbackStatements(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	a.NoError(err)
+	defer sqlDB.Close()
+	driver := &rollbackDriver{sqlDB: sqlDB}
+	// Each connection has its own in-memory databases.
+	sqlDB.SetMaxOpenConns(1)
+	for _, s := range []string{
+		`CREATE TABLE t1 (id INT PRIMARY KEY)`,
+		`CREATE TABLE t2 (id INT PRIMARY KEY)`,
+		`INSERT INTO t2 VALUES (1)`,
+	} {
+		_, err := sqlDB.ExecContext(ctx, s)
+		a.NoError(err)
+	}
+	countRows := func(table string) int {
+		var count int
+		a.NoError(sqlDB.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count))
+		return count
+	}
+
+	// The rollback is never applied partially.
+	statements := []RollbackStatement{
+		{Table: "t1", BackupTable: "_0_0_t1", Statement: "INSERT INTO t1 VALUES (1)"},
+		{Table: "t2", BackupTable: "_0_1_t2", Statement: "INSERT INTO t2 VALUES (1)"},
+	}
+	err = executeRollbackStatements(ctx, driver, storepb.Engine_MYSQL, statements, db.ExecuteOptions{}, newRollbackProgress(&state.State{}, realClock{}, 1, statements))
+	a.ErrorContains(err, `failed to restore table "t2" from backup table "_0_1_t2"`)
+	a.Zero(countRows("t1"))
+
+	statements[1].Statement = "INSERT INTO t2 VALUES (2)"
+	a.NoError(executeRollbackStatements(ctx, driver, storepb.Engine_MYSQL, statements, db.ExecuteOptions{}, newRollbackProgress(&state.State{}, realClock{}, 1, statements)))
+	a.Equal(1, countRows("t1"))
+	a.Equal(2, countRows("t2"))
+}
+
+func TestExecuteRollbackStatementsProgress(t *testing.T) {
+	a := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stateCfg := &state.State{}
+	getProgress := func() (api.Progress, rollbackProgressPayload) {
+		value, ok := stateCfg.TaskProgress.Load(1)
+		a.True(ok)
+		progress := value.(api.Progress)
+		var payload rollbackProgressPayload
+		a.NoError(json.Unmarshal([]byte(progress.Payload), &payload))
+		return progress, payload
+	}
+	// The chunks record the completed units when they run, and the chunk canceling the rollback cancels the context.
+	var completed []int64
+	sql.Register("sqlite3_rollback_progress", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("record_progress", func() int64 {
+				progress, _ := getProgress()
+				completed = append(completed, progress.CompletedUnit)
+				return 1
+			}, false); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("cancel_rollback", func() int64 {
+				cancel()
+				return 1
+			}, false)
+		},
+	})
+	sqlDB, err := sql.Open("sqlite3_rollback_progress", ":memory:")
+	a.NoError(err)
+	defer sqlDB.Close()
+	driver := &rollbackDriver{sqlDB: sqlDB}
+
+	statements := []RollbackStatement{
+		{Table: "t1", BackupTable: "_0_0_t1_0", Statement: "SELECT record_progress()"},
+		{Table: "t1", BackupTable: "_0_0_t1_1", Statement: "SELECT record_progress()"},
+		{Table: "t2", BackupTable: "_0_1_t2", Statement: "SELECT record_progress()"},
+	}
+	// The progress tells the time by the clock.
+	clock := &fakeClock{now: time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)}
+	progress := newRollbackProgress(stateCfg, clock, 1, statements)
+	got, payload := getProgress()
+	a.Equal(clock.now.Unix(), got.CreatedTs)
+	a.Equal(clock.now.Unix(), got.UpdatedTs)
+	a.Equal(int64(3), got.TotalUnit)
+	a.Zero(got.CompletedUnit)
+	a.Equal([]*rollbackTableProgress{
+		{Table: "t1", TotalChunks: 2},
+		{Table: "t2", TotalChunks: 1},
+	}, payload.Tables)
+
+	// The progress advances by the chunks of each table.
+	clock.now = clock.now.Add(time.Minute)
+	a.NoError(executeRollbackStatements(ctx, driver, storepb.Engine_MYSQL, statements, db.ExecuteOptions{}, progress))
+	a.Equal([]int64{0, 1, 2}, completed)
+	got, payload = getProgress()
+	a.Equal(int64(3), got.CompletedUnit)
+	a.Equal(clock.now.Unix(), got.UpdatedTs)
+	a.Equal([]*rollbackTableProgress{
+		{Table: "t1", TotalChunks: 2, CompletedChunks: 2},
+		{Table: "t2", TotalChunks: 1, CompletedChunks: 1},
+	}, payload.Tables)
+	a.False(payload.Canceled)
+
+	// The cancellation stops the further chunks and is recorded.
+	completed = nil
+	statements[1].Statement = "SELECT record_progress(), cancel_rollback()"
+	err = executeRollbackStatements(ctx, driver, storepb.Engine_MYSQL, statements, db.ExecuteOptions{}, newRollbackProgress(stateCfg, realClock{}, 1, statements))
+	a.ErrorIs(err, context.Canceled)
+	a.ErrorContains(err, "rollback is canceled after restoring")
+	a.Equal([]int64{0, 1}, completed)
+	_, payload = getProgress()
+	a.True(payload.Canceled)
+	a.False(payload.Failed)
+	a.Equal(int64(0), payload.Tables[1].CompletedChunks)
+}
+
+func TestGetRollbackScript(t *testing.T) {
+	a := require.New(t)
+	// The chunk with multiple commands completes on the response of its last command.
+	script, lastCommandIndexes, err := getRollbackScript(storepb.Engine_MYSQL, []RollbackStatement{
+		{Table: "t1", BackupTable: "_0_0_t1", Statement: "INSERT INTO t1 SELECT * FROM _0_0_t1"},
+		{Table: "t2", BackupTable: "_0_1_t2", Statement: "DELETE FROM t2 WHERE id = 1;\nINSERT INTO t2 SELECT * FROM _0_1_t2;"},
+	})
+	a.NoError(err)
+	a.Equal("INSERT INTO t1 SELECT * FROM _0_0_t1;\nDELETE FROM t2 WHERE id = 1;\nINSERT INTO t2 SELECT * FROM _0_1_t2;\n", script)
+	a.Equal([]int{0, 2}, lastCommandIndexes)
+}
+
+func TestExecuteRollbackStatementsInParallel(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	var mu sync.Mutex
+	var marks []string
+	// The chunks of the independent tables wait for each other to run concurrently.
+	arrived := make(chan struct{}, 2)
+	sql.Register("sqlite3_rollback_parallel", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("mark", func(table string) int64 {
+				mu.Lock()
+				defer mu.Unlock()
+				marks = append(marks, table)
+				return 1
+			}, false); err != nil {
+				return err
+			}
+			if err := conn.RegisterFunc("barrier", func() (int64, error) {
+				arrived <- struct{}{}
+				for deadline := time.After(5 * time.Second); len(arrived) < 2; {
+					select {
+					case <-deadline:
+						return 0, errors.New("the tables are not restored concurrently")
+					case <-time.After(10 * time.Millisecond):
+					}
+				}
+				return 1, nil
+			}, false); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("fail", func() (int64, error) {
+				return 0, errors.New("restore failed")
+			}, false)
+		},
+	})
+	sqlDB, err := sql.Open("sqlite3_rollback_parallel", ":memory:")
+	a.NoError(err)
+	defer sqlDB.Close()
+	driver := &rollbackDriver{sqlDB: sqlDB}
+
+	// child references parent, and the other tables are independent.
+	references := map[string][]string{"public.child": {"public.parent"}}
+	statements := []RollbackStatement{
+		{Table: "public.child", BackupTable: "_0_2_child", Statement: "SELECT mark('child')"},
+		{Table: "public.parent", BackupTable: "_0_1_parent", Statement: "SELECT barrier()"},
+		{Table: "public.other", BackupTable: "_0_0_other", Statement: "SELECT barrier()"},
+		{Table: "public.parent", BackupTable: "_0_3_parent", Statement: "SELECT mark('parent')"},
+	}
+	a.Equal([]string{"public.parent", "public.other", "public.child"}, orderRollbackTablesByDependency([]string{"public.child", "public.parent", "public.other"}, references))
+	stateCfg := &state.State{}
+	a.NoError(executeRollbackStatementsInParallel(ctx, driver, storepb.Engine_MYSQL, statements, references, 3, db.ExecuteOptions{}, newRollbackProgress(stateCfg, realClock{}, 1, statements)))
+	a.Equal([]string{"parent", "child"}, marks)
+	value, ok := stateCfg.TaskProgress.Load(1)
+	a.True(ok)
+	a.Equal(int64(4), value.(api.Progress).CompletedUnit)
+
+	getPayload := func() rollbackProgressPayload {
+		value, ok := stateCfg.TaskProgress.Load(1)
+		a.True(ok)
+		var payload rollbackProgressPayload
+		a.NoError(json.Unmarshal([]byte(value.(api.Progress).Payload), &payload))
+		return payload
+	}
+
+	// The first failure cancels the tables not restored yet, and is recorded as the failure rather than the cancellation.
+	marks = nil
+	statements = []RollbackStatement{
+		{Table: "public.child", BackupTable: "_0_1_child", Statement: "SELECT mark('child')"},
+		{Table: "public.parent", BackupTable: "_0_0_parent", Statement: "SELECT fail()"},
+	}
+	err = executeRollbackStatementsInParallel(ctx, driver, storepb.Engine_MYSQL, statements, references, 3, db.ExecuteOptions{}, newRollbackProgress(stateCfg, realClock{}, 1, statements))
+	a.EqualError(err, `failed to restore table "public.parent" from backup table "_0_0_parent": restore failed`)
+	a.Empty(marks)
+	payload := getPayload()
+	a.True(payload.Failed)
+	a.False(payload.Canceled)
+	a.Equal([]*rollbackTableProgress{
+		{Table: "public.child", TotalChunks: 1},
+		{Table: "public.parent", TotalChunks: 1, Error: `failed to restore table "public.parent" from backup table "_0_0_parent": restore failed`},
+	}, payload.Tables)
+
+	// The tables restored before the failure stay restored, which the error reports.
+	marks = nil
+	statements = []RollbackStatement{
+		{Table: "public.child", BackupTable: "_0_1_child", Statement: "SELECT fail()"},
+		{Table: "public.parent", BackupTable: "_0_0_parent", Statement: "SELECT mark('parent')"},
+	}
+	err = executeRollbackStatementsInParallel(ctx, driver, storepb.Engine_MYSQL, statements, references, 3, db.ExecuteOptions{}, newRollbackProgress(stateCfg, realClock{}, 1, statements))
+	a.EqualError(err, `the rollback is applied partially, where the tables public.parent stay restored: failed to restore table "public.child" from backup table "_0_1_child": restore failed`)
+	a.Equal([]string{"parent"}, marks)
+	payload = getPayload()
+	a.True(payload.Failed)
+	a.False(payload.Canceled)
+	a.Equal([]*rollbackTableProgress{
+		{Table: "public.child", TotalChunks: 1, Error: `failed to restore table "public.child" from backup table "_0_1_child": restore failed`},
+		{Table: "public.parent", TotalChunks: 1, CompletedChunks: 1},
+	}, payload.Tables)
+
+	// The tables in a dependency cycle don't wait for each other.
+	references = map[string][]string{"public.a": {"public.b"}, "public.b": {"public.a"}}
+	statements = []RollbackStatement{
+		{Table: "public.a", BackupTable: "_0_0_a", Statement: "SELECT mark('a')"},
+		{Table: "public.b", BackupTable: "_0_1_b", Statement: "SELECT mark('b')"},
+	}
+	a.NoError(executeRollbackStatementsInParallel(ctx, driver, storepb.Engine_MYSQL, statements, references, 1, db.ExecuteOptions{}, newRollbackProgress(stateCfg, realClock{}, 1, statements)))
+}
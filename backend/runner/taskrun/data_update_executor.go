@@ -1,12 +1,31 @@
 package taskrun
 
 import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sourcegraph/conc/pool"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
 
@@ -16,18 +35,22 @@ import (
 	"github.com/bytebase/bytebase/backend/component/dbfactory"
 	"github.com/bytebase/bytebase/backend/component/state"
 	enterprise "github.com/bytebase/bytebase/backend/enterprise/api"
+	"github.com/bytebase/bytebase/backend/runner/priorbackup"
 	"github.com/bytebase/bytebase/backend/runner/schemasync"
 
 	api "github.com/bytebase/bytebase/backend/legacyapi"
 	"github.com/bytebase/bytebase/backend/plugin/db"
 	"github.com/bytebase/bytebase/backend/plugin/db/oracle"
+	pgdriver "github.com/bytebase/bytebase/backend/plugin/db/pg"
 	"github.com/bytebase/bytebase/backend/plugin/parser/base"
 	"github.com/bytebase/bytebase/backend/store"
 	"github.com/bytebase/bytebase/backend/store/model"
 )
 
 // NewDataUpdateExecutor creates a data update (DML) task executor.
-func NewDataUpdateExecutor(store *store.Store, dbFactory *dbfactory.DBFactory, license enterprise.LicenseService, stateCfg *state.State, schemaSyncer *schemasync.Syncer, profile *config.Profile) Executor {
+// The producer publishes the prior backups to the sink, and the tasks backing up to a sink fail if it's nil.
+// The object store keeps the versions of the prior backups published to the sink if it's not nil.
+func NewDataUpdateExecutor(store *store.Store, dbFactory *dbfactory.DBFactory, license enterprise.LicenseService, stateCfg *state.State, schemaSyncer *schemasync.Syncer, profile *config.Profile, producer PriorBackupProducer, objectStore common.PriorBackupObjectStore) Executor {
 	return &DataUpdateExecutor{
 		store:        store,
 		dbFactory:    dbFactory,
@@ -35,6 +58,10 @@ func NewDataUpdateExecutor(store *store.Store, dbFactory *dbfactory.DBFactory, l
 		stateCfg:     stateCfg,
 		schemaSyncer: schemaSyncer,
 		profile:      profile,
+		producer:     producer,
+		objectStore:  objectStore,
+		limiter:      newBackupLimiter(profile.PriorBackupEngineConcurrency),
+		clock:        realClock{},
 	}
 }
 
@@ -46,6 +73,72 @@ type DataUpdateExecutor struct {
 	stateCfg     *state.State
 	schemaSyncer *schemasync.Syncer
 	profile      *config.Profile
+	producer     PriorBackupProducer
+	objectStore  common.PriorBackupObjectStore
+	limiter      *backupLimiter
+	// clock tells the time of the time-derived fields, e.g. the backup table names, the blackout deferrals and the drop times.
+	clock Clock
+
+	// failureHook injects the failures at the failure points for tests. It's never set in production.
+	failureHook func(point failurePoint) error
+}
+
+// Clock tells the current time. The tests replace the real clock to control the time-derived fields deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the clock of the wall time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// now returns the current time by the clock of the executor. The constructor sets the real clock, and the tests set their own.
+func (exec *DataUpdateExecutor) now() time.Time {
+	return exec.clock.Now()
+}
+
+// IDGenerator generates the IDs telling apart the names generated concurrently. The tests replace the random generator
+// to control the generated names deterministically.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// randomIDGenerator generates the random IDs of 8 hex digits.
+type randomIDGenerator struct{}
+
+func (randomIDGenerator) NewID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate id")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// failurePoint is a point of the data update execution where the tests can inject failures.
+type failurePoint string
+
+const (
+	// failurePointBackup fails the backup before backing up any database.
+	failurePointBackup failurePoint = "backup"
+	// failurePointBackupStatement fails each backup statement before it's executed.
+	failurePointBackupStatement failurePoint = "backup-statement"
+	// failurePointComment fails tagging each backup table with the table comment.
+	failurePointComment failurePoint = "comment"
+	// failurePointSync fails each sync of the backup database schema.
+	failurePointSync failurePoint = "sync"
+	// failurePointMigration fails the data update before it's executed.
+	failurePointMigration failurePoint = "migration"
+)
+
+// injectFailure returns the failure injected at the failure point. It's a no-op without the failure hook.
+func (exec *DataUpdateExecutor) injectFailure(point failurePoint) error {
+	if exec.failureHook == nil {
+		return nil
+	}
+	return exec.failureHook(point)
 }
 
 // RunOnce will run the data update (DML) task executor once.
@@ -60,177 +153,5315 @@ func (exec *DataUpdateExecutor) RunOnce(ctx context.Context, driverCtx context.C
 	if err != nil {
 		return true, nil, err
 	}
-	priorBackupDetail, err := exec.backupData(ctx, driverCtx, statement, payload, task)
+	requirement := getBackupRequirement(payload)
+	savepointPolicy, err := getBackupSavepointPolicy(exec.profile.PriorBackupSavepointPolicy)
 	if err != nil {
 		return true, nil, err
 	}
+	var priorBackupDetail *storepb.PriorBackupDetail
+	var backupStatements []string
+	if exec.profile.PriorBackupAsync && requirement != storepb.TaskDatabaseUpdatePayload_DISABLED {
+		// The backup outlives this run, so it's bound to the server context instead of the driver context of the run.
+		priorBackupDetail, backupStatements, err = exec.getAsyncBackup(ctx, task, taskRunUID, func(backupCtx context.Context) (*storepb.PriorBackupDetail, []string, error) {
+			return exec.backupData(ctx, backupCtx, statement, payload, requirement, task, taskRunUID)
+		}, func(detail *storepb.PriorBackupDetail) {
+			exec.dropTaskBackupTables(ctx, task, detail)
+		})
+		if errors.Is(err, errBackupInProgress) {
+			// The data update is gated until the backup completes, and the scheduler skips the task run meanwhile.
+			return false, nil, err
+		}
+	} else {
+		// The synchronous backup completes right before the data update, so it never goes stale.
+		priorBackupDetail, backupStatements, err = exec.backupData(ctx, driverCtx, statement, payload, requirement, task, taskRunUID)
+	}
+	var backupDeferredErr *backupDeferredError
+	if errors.As(err, &backupDeferredErr) {
+		// The data update waits for the backup instead of running without it, and the scheduler retries after the deferral.
+		exec.stateCfg.PriorBackupDeferredTaskRuns.Store(taskRunUID, backupDeferredErr.until)
+		return false, nil, err
+	}
+	var priorBackupErr error
+	if err != nil {
+		// The detail of the failed backup keeps track of the backup tables created before the failure.
+		if len(priorBackupDetail.GetItems()) == 0 {
+			priorBackupDetail = nil
+		}
+		if err := checkBackupShortfall(requirement, err); err != nil {
+			return true, nil, &backupFailedError{detail: priorBackupDetail, err: err}
+		}
+		slog.Warn("failed to back up data, update the data without the backup", slog.Int("task", task.ID), log.BBError(err))
+		if priorBackupDetail != nil {
+			priorBackupDetail.Incomplete = true
+		}
+		priorBackupErr = err
+		backupStatements = nil
+	}
+	warnings := priorBackupDetail.GetWarnings()
+	if exec.profile.PriorBackupSizeAnomalyFactor > 0 && priorBackupDetail != nil {
+		// The history is read before the task run result is saved, so it doesn't include the current backup.
+		warnings = append(warnings, exec.getBackupSizeAnomalies(ctx, priorBackupDetail)...)
+	}
+	var explainPlans []string
+	if exec.profile.DataUpdateCaptureExplainPlan {
+		explainPlans, err = exec.captureExplainPlans(ctx, driverCtx, statement, task)
+		if err != nil {
+			// The plan is only used for post-mortems, so failing to capture it should not block the data update.
+			slog.Warn("failed to capture explain plans", slog.Int("task", task.ID), log.BBError(err))
+		}
+	}
 	version := model.Version{Version: payload.SchemaVersion}
-	terminated, result, err := runMigration(ctx, driverCtx, exec.store, exec.dbFactory, exec.stateCfg, exec.profile, task, taskRunUID, db.Data, statement, version, &sheetID)
+	// The deferred backup statements run in the data update transaction, e.g. to lock the backed up rows.
+	opts := db.ExecuteOptions{PreludeStatements: backupStatements}
+	if len(backupStatements) > 0 {
+		instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &task.InstanceID})
+		if err != nil {
+			return true, nil, errors.Wrap(err, "failed to get instance")
+		}
+		// The drivers cannot run the deferred backup statements with these statements, so the task fails instead of updating the data without the backup.
+		if err := checkDeferredBackupStatement(instance.Engine, statement); err != nil {
+			exec.dropUndoneBackupTables(ctx, task, priorBackupDetail)
+			return true, nil, err
+		}
+		opts.PreludeRollbackPolicy = savepointPolicy
+	}
+	if err := exec.injectFailure(failurePointMigration); err != nil {
+		return true, nil, err
+	}
+	terminated, result, err := runMigrationWithOptions(ctx, driverCtx, exec.store, exec.dbFactory, exec.stateCfg, exec.profile, task, taskRunUID, db.Data, statement, version, &sheetID, opts)
+	if len(backupStatements) > 0 {
+		switch {
+		case err == nil:
+			exec.syncBackupSchema(ctx, task, priorBackupDetail)
+		case opts.PreludeRollbackPolicy == db.PreludeRollbackKeep:
+			slog.Info("kept the prior backup of the failed data update", slog.Int("task", task.ID))
+			exec.syncBackupSchema(ctx, task, priorBackupDetail)
+		default:
+			exec.dropUndoneBackupTables(ctx, task, priorBackupDetail)
+		}
+	}
+	if err == nil && hasCompleteBackup(priorBackupDetail) {
+		exec.applyBackupPostSuccessPolicy(ctx, task, priorBackupDetail)
+	}
+	// The sample backups miss the rows by design.
+	if err == nil && exec.profile.PriorBackupVerifyCoverage && hasCompleteBackup(priorBackupDetail) && priorBackupDetail.GetSampleRate() == 0 && priorBackupDetail.GetSampleLimit() == 0 {
+		uncovered, err := exec.verifyBackupCoverage(ctx, driverCtx, statement, task, priorBackupDetail)
+		if err != nil {
+			// The verification only flags the missed rows, so failing to verify should not fail the applied data update.
+			slog.Warn("failed to verify prior backup coverage", slog.Int("task", task.ID), log.BBError(err))
+		}
+		warnings = append(warnings, uncovered...)
+	}
 	if result != nil {
 		// Save prior backup detail to task run result.
 		result.PriorBackupDetail = priorBackupDetail
+		if priorBackupErr != nil {
+			result.PriorBackupError = priorBackupErr.Error()
+		}
+		result.ExplainPlans = explainPlans
+		result.Warnings = warnings
 	}
 	return terminated, result, err
 }
 
+// hasCompleteBackup returns whether the data update has the backup tables to roll back from.
+// The incomplete backups only cover the backup tables created before the backup failed.
+func hasCompleteBackup(detail *storepb.PriorBackupDetail) bool {
+	return len(detail.GetItems()) > 0 && !detail.GetIncomplete()
+}
+
+// isBackupStale returns whether more than the maximum gap has elapsed since the backup completed. Zero maxGap disables the check.
+func isBackupStale(detail *storepb.PriorBackupDetail, maxGap time.Duration, now time.Time) bool {
+	if maxGap <= 0 || detail.GetCompleteTime() == nil {
+		return false
+	}
+	return now.Sub(detail.GetCompleteTime().AsTime()) > maxGap
+}
+
+// maximumConcurrentShardBackups is the maximum number of shard databases backed up concurrently.
+const maximumConcurrentShardBackups = 4
+
 func (exec *DataUpdateExecutor) backupData(
 	ctx context.Context,
 	driverCtx context.Context,
 	statement string,
 	payload *storepb.TaskDatabaseUpdatePayload,
+	requirement storepb.TaskDatabaseUpdatePayload_BackupRequirement,
 	task *store.TaskMessage,
-) (*storepb.PriorBackupDetail, error) {
-	if payload.PreUpdateBackupDetail == nil || payload.PreUpdateBackupDetail.Database == "" {
-		return nil, nil
+	taskRunUID int,
+) (*storepb.PriorBackupDetail, []string, error) {
+	if requirement == storepb.TaskDatabaseUpdatePayload_DISABLED {
+		return nil, nil, nil
+	}
+	if payload.PreUpdateBackupDetail == nil || (payload.PreUpdateBackupDetail.Database == "" && payload.PreUpdateBackupDetail.SinkTopic == "" && !payload.PreUpdateBackupDetail.ObjectStorage) {
+		return nil, nil, errors.New("backup database is not set")
+	}
+	if err := exec.injectFailure(failurePointBackup); err != nil {
+		return nil, nil, err
+	}
+	if payload.PreUpdateBackupDetail.Database != "" && payload.PreUpdateBackupDetail.SinkTopic == "" {
+		backupInstanceID, _, err := common.GetInstanceDatabaseID(payload.PreUpdateBackupDetail.Database)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to parse backup database %q", payload.PreUpdateBackupDetail.Database)
+		}
+		backupInstance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{ResourceID: &backupInstanceID})
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to get backup instance")
+		}
+		if backupInstance == nil {
+			return nil, nil, errors.Errorf("backup instance %q not found", backupInstanceID)
+		}
+		if err := exec.checkBackupBlackout(backupInstance.Options.GetBackupBlackoutWindows()); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &task.InstanceID})
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get instance")
+		return nil, nil, errors.Wrap(err, "failed to get instance")
 	}
 	database, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{UID: task.DatabaseID})
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get database")
+		return nil, nil, errors.Wrap(err, "failed to get database")
 	}
 	issue, err := exec.store.GetIssueV2(ctx, &store.FindIssueMessage{PipelineID: &task.PipelineID})
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to find issue for pipeline %v", task.PipelineID)
+		return nil, nil, errors.Wrapf(err, "failed to find issue for pipeline %v", task.PipelineID)
 	}
 	if issue == nil {
-		return nil, errors.Errorf("issue not found for pipeline %v", task.PipelineID)
+		return nil, nil, errors.Errorf("issue not found for pipeline %v", task.PipelineID)
 	}
+	// Deferred so that the gauge is decremented on failures and panics too.
+	defer trackActiveBackup(instance.Engine, instance.ResourceID)()
+	// The asynchronous backups only live in memory, so the backup tables of the backup interrupted before, e.g. by a restart,
+	// are dropped before backing up again.
+	exec.dropInterruptedBackupTables(ctx, task, taskRunUID)
 
-	sourceDatabaseName := common.FormatDatabase(database.InstanceID, database.DatabaseName)
-	// Format: instances/{instance}/databases/{database}
-	targetDatabaseName := payload.PreUpdateBackupDetail.Database
-	var backupDatabase *store.DatabaseMessage
-	var backupDriver db.Driver
+	if topic := payload.PreUpdateBackupDetail.SinkTopic; topic != "" {
+		if err := exec.license.IsFeatureEnabledForInstance(api.FeatureBackupSink, instance); err != nil {
+			return nil, nil, err
+		}
+		items, images, err := exec.publishBackupData(ctx, driverCtx, statement, instance, database, topic)
+		if err != nil {
+			return nil, nil, err
+		}
+		detail := &storepb.PriorBackupDetail{
+			Items:         items,
+			SchemaVersion: payload.SchemaVersion,
+			Stage:         common.FormatStage(issue.Project.ResourceID, task.PipelineID, task.StageID),
+		}
+		if exec.objectStore != nil {
+			key := common.GetPriorBackupObjectKey(issue.UID, task.ID, exec.now())
+			if err := putBeforeImages(driverCtx, exec.objectStore, key, images); err != nil {
+				return nil, nil, err
+			}
+			detail.ObjectKey = key
+		}
+		return detail, nil, nil
+	}
+	if payload.PreUpdateBackupDetail.ObjectStorage {
+		backupTime := exec.now()
+		prefix := common.GetPriorBackupExportPrefix(issue.UID, task.ID, backupTime)
+		items, manifestKey, err := exec.exportBackupData(ctx, driverCtx, statement, instance, database, prefix)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &storepb.PriorBackupDetail{
+			Items:             items,
+			SchemaVersion:     payload.SchemaVersion,
+			Stage:             common.FormatStage(issue.Project.ResourceID, task.PipelineID, task.StageID),
+			ExportManifestKey: manifestKey,
+			CompleteTime:      timestamppb.New(exec.now()),
+		}, nil, nil
+	}
 
-	backupInstanceID, backupDatabaseName, err := common.GetInstanceDatabaseID(targetDatabaseName)
+	backupDetail := payload.PreUpdateBackupDetail
+	perIssue := exec.profile.PriorBackupPerIssueDatabase && supportPerIssueBackupDatabase(instance.Engine)
+	if perIssue {
+		backupDetail, err = getPerIssueBackupDetail(backupDetail, issue.UID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	targets, err := getBackupTargets(common.FormatDatabase(database.InstanceID, database.DatabaseName), backupDetail)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse backup database")
+		return nil, nil, err
 	}
 
-	if instance.Engine != storepb.Engine_POSTGRES {
-		backupDatabase, err = exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{InstanceID: &backupInstanceID, DatabaseName: &backupDatabaseName})
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to get backup database")
+	isolationLevel, err := getBackupIsolationLevel(instance.Engine, exec.profile.PriorBackupIsolationLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+	priorBackupDetail := &storepb.PriorBackupDetail{}
+	if isolationLevel != sql.LevelDefault {
+		priorBackupDetail.IsolationLevel = strings.ToUpper(isolationLevel.String())
+	}
+	priorBackupDetail.DependencyOrdered = exec.profile.PriorBackupOrderByDependency
+	principal := exec.getBackupPrincipal(ctx, taskRunUID)
+	priorBackupDetail.Principal = common.FormatUserEmail(principal)
+	priorBackupDetail.SchemaVersion = payload.SchemaVersion
+	priorBackupDetail.Stage = common.FormatStage(issue.Project.ResourceID, task.PipelineID, task.StageID)
+	priorBackupDetail.LogPosition = exec.getBackupLogPosition(driverCtx, instance, database)
+	priorBackupDetail.SessionSettings = exec.getBackupSessionSettings(driverCtx, instance, database)
+	priorBackupDetail.Namespace = getBackupNamespace(database, exec.profile.PriorBackupNamespaceLabel)
+	if backupDetail.SampleRate < 0 {
+		return nil, nil, errors.Errorf("invalid backup sample rate %d", backupDetail.SampleRate)
+	}
+	if backupDetail.SampleRate > 1 {
+		if instance.Engine != storepb.Engine_POSTGRES {
+			return nil, nil, errors.Errorf("sample backup is not supported for engine %s", instance.Engine)
 		}
-		if backupDatabase == nil {
-			return nil, errors.Errorf("backup database %q not found", targetDatabaseName)
+		priorBackupDetail.SampleRate = backupDetail.SampleRate
+	}
+	if backupDetail.SampleLimit < 0 {
+		return nil, nil, errors.Errorf("invalid backup sample limit %d", backupDetail.SampleLimit)
+	}
+	if backupDetail.SampleLimit > 0 {
+		if priorBackupDetail.SampleRate > 0 {
+			return nil, nil, errors.New("the backup sample rate and the backup sample limit cannot be set together")
+		}
+		if instance.Engine != storepb.Engine_POSTGRES {
+			return nil, nil, errors.Errorf("bounded sample backup is not supported for engine %s", instance.Engine)
 		}
-		backupDriver, err = exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, backupDatabase, db.ConnectionContext{})
+		priorBackupDetail.SampleLimit = backupDetail.SampleLimit
+		priorBackupDetail.SampleFromLast = backupDetail.SampleFromLast
+	}
+	if backupDetail.EncryptionKey != "" {
+		if err := exec.license.IsFeatureEnabledForInstance(api.FeatureEncryptedBackup, instance); err != nil {
+			return nil, nil, err
+		}
+		if err := validateBackupEncryptionKey(instance.Engine, backupDetail.EncryptionKey); err != nil {
+			return nil, nil, err
+		}
+		priorBackupDetail.EncryptionKey = backupDetail.EncryptionKey
+	}
+	priorBackupDetail.Warnings, err = exec.checkBackupVersions(ctx, instance.Engine, targets)
+	if err != nil {
+		return nil, nil, err
+	}
+	savepoint := exec.profile.PriorBackupSavepointPolicy != "" && supportBackupSavepoint(instance.Engine)
+	lockRows := exec.profile.PriorBackupLockRows && instance.Engine == storepb.Engine_POSTGRES
+	if lockRows || savepoint {
+		// The backup statements of the task database deferred to the data update transaction ignore the isolation level,
+		// and run at the level of the transaction, i.e. the driver default.
+		priorBackupDetail.IsolationLevel = ""
+	}
+	priorBackupDetail.PostSuccessPolicy, err = getBackupPostSuccessPolicy(exec.profile.PriorBackupPostSuccessPolicy, backupDetail.PostSuccessPolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+	if deadline := payload.GetBudgetDeadline(); deadline != nil {
+		warning, err := exec.checkBackupBudget(ctx, statement, instance, database, targets[0], deadline.AsTime())
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to get backup database driver")
+			return nil, nil, err
+		}
+		if warning != "" {
+			priorBackupDetail.Warnings = append(priorBackupDetail.Warnings, warning)
 		}
-		defer backupDriver.Close(driverCtx)
 	}
 
-	driver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, database, db.ConnectionContext{})
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get database driver")
+	// The snapshot only clones the task database, so the backups of the shards are always copied.
+	if exec.profile.PriorBackupDatabaseSnapshotRows > 0 && len(targets) == 1 && priorBackupDetail.SampleRate == 0 && priorBackupDetail.SampleLimit == 0 {
+		snapshot, err := exec.backupDatabaseSnapshot(ctx, driverCtx, statement, instance, database, targets[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		if snapshot != nil {
+			priorBackupDetail.DatabaseSnapshot = snapshot
+			priorBackupDetail.CompleteTime = timestamppb.New(exec.now())
+			return priorBackupDetail, nil, nil
+		}
 	}
-	defer driver.Close(driverCtx)
 
-	tc := base.TransformContext{
-		InstanceID:              instance.ResourceID,
-		GetDatabaseMetadataFunc: BuildGetDatabaseMetadataFunc(exec.store),
+	opts := &backupOptions{
+		// All shards share the same backup table prefix so that the backup tables of one task can be found together.
+		prefix:          getBackupTablePrefix(priorBackupDetail.Namespace, exec.now()),
+		isolationLevel:  isolationLevel,
+		provision:       perIssue,
+		encryptionKey:   backupDetail.EncryptionKey,
+		resourceGroup:   getBackupResourceGroup(instance.Engine, exec.profile.PriorBackupResourceGroup, backupDetail.ResourceGroup),
+		sessionRole:     getBackupSessionRole(instance.Engine, exec.profile.PriorBackupSessionRole, backupDetail.SessionRole),
+		sampleRate:      priorBackupDetail.SampleRate,
+		sampleLimit:     priorBackupDetail.SampleLimit,
+		sampleFromLast:  priorBackupDetail.SampleFromLast,
+		readTimeout:     exec.profile.PriorBackupReadTimeout,
+		writeTimeout:    exec.profile.PriorBackupWriteTimeout,
+		principal:       principal,
+		schemaVersion:   payload.SchemaVersion,
+		namespace:       priorBackupDetail.Namespace,
+		procedureTables: backupDetail.ProcedureTables,
+		backupStatement: backupDetail.BackupStatement,
+		sessionSettings: priorBackupDetail.SessionSettings,
+		logBackupTables: func(items []*storepb.PriorBackupDetail_Item) {
+			exec.store.CreateTaskRunLogS(ctx, taskRunUID, exec.now(), exec.profile.DeployID, &storepb.TaskRunLog{
+				Type:             storepb.TaskRunLog_PRIOR_BACKUP_START,
+				PriorBackupStart: &storepb.TaskRunLog_PriorBackupStart{Items: items},
+			})
+		},
 	}
-	if instance.Engine == storepb.Engine_ORACLE {
-		oracleDriver, ok := driver.(*oracle.Driver)
-		if ok {
-			if version, err := oracleDriver.GetVersion(); err == nil {
-				tc.Version = version
+	targetItems := make([][]*storepb.PriorBackupDetail_Item, len(targets))
+	var deferredStatements []string
+	err = withBackupTimeout(driverCtx, exec.profile.PriorBackupTimeout, func(driverCtx context.Context) error {
+		p := pool.New().WithErrors().WithMaxGoroutines(maximumConcurrentShardBackups)
+		for i, target := range targets {
+			targetOpts := opts
+			// Only the task database is modified by the data update transaction that the backup can be deferred to.
+			if i == 0 && lockRows {
+				lockRowsOpts := *opts
+				lockRowsOpts.deferred = true
+				lockRowsOpts.lockRows = true
+				targetOpts = &lockRowsOpts
+			} else if i == 0 && savepoint {
+				savepointOpts := *opts
+				savepointOpts.deferred = true
+				targetOpts = &savepointOpts
 			}
+			p.Go(func() error {
+				release, err := exec.limiter.acquire(driverCtx, instance.Engine)
+				if err != nil {
+					return errors.Wrapf(err, "failed to backup database %q", target.source)
+				}
+				defer release()
+				items, statements, err := exec.backupDatabaseData(ctx, driverCtx, statement, task, issue, instance.Engine, target, targetOpts)
+				// The items are the backup tables created even if the backup failed halfway.
+				targetItems[i] = items
+				if err != nil {
+					return errors.Wrapf(err, "failed to backup database %q", target.source)
+				}
+				if i == 0 {
+					deferredStatements = statements
+				}
+				return nil
+			})
+		}
+		return p.Wait()
+	})
+	for _, items := range targetItems {
+		priorBackupDetail.Items = append(priorBackupDetail.Items, items...)
+	}
+	if err != nil {
+		var backupTimeoutErr *backupTimeoutError
+		if errors.As(err, &backupTimeoutErr) {
+			priorBackupDetail.TimedOut = true
 		}
+		exec.logPriorBackupEnd(ctx, taskRunUID, priorBackupDetail, err)
+		// The detail keeps track of the backup tables created before the failure.
+		return priorBackupDetail, nil, err
 	}
 
-	prefix := "_" + time.Now().Format("20060102150405")
-	statements, err := base.TransformDMLToSelect(ctx, instance.Engine, tc, statement, database.DatabaseName, backupDatabaseName, prefix)
+	priorBackupDetail.CompleteTime = timestamppb.New(exec.now())
+	exec.logPriorBackupEnd(ctx, taskRunUID, priorBackupDetail, nil)
+	return priorBackupDetail, deferredStatements, nil
+}
+
+// logPriorBackupEnd logs the end of the prior backup of the task run, after which its backup tables are tracked by the detail.
+func (exec *DataUpdateExecutor) logPriorBackupEnd(ctx context.Context, taskRunUID int, detail *storepb.PriorBackupDetail, err error) {
+	end := &storepb.TaskRunLog_PriorBackupEnd{PriorBackupDetail: detail}
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to transform DML to select")
+		end.Error = err.Error()
 	}
+	exec.store.CreateTaskRunLogS(ctx, taskRunUID, exec.now(), exec.profile.DeployID, &storepb.TaskRunLog{
+		Type:           storepb.TaskRunLog_PRIOR_BACKUP_END,
+		PriorBackupEnd: end,
+	})
+}
 
-	priorBackupDetail := &storepb.PriorBackupDetail{}
-	for _, statement := range statements {
-		if _, err := driver.Execute(driverCtx, statement.Statement, db.ExecuteOptions{}); err != nil {
-			return nil, errors.Wrapf(err, "failed to execute backup statement %q", statement.Statement)
+// getBackupLogPosition returns the position of the transaction log of the database, or empty if it cannot be captured.
+func (exec *DataUpdateExecutor) getBackupLogPosition(ctx context.Context, instance *store.InstanceMessage, database *store.DatabaseMessage) string {
+	switch instance.Engine {
+	case storepb.Engine_POSTGRES, storepb.Engine_MYSQL:
+	default:
+		return ""
+	}
+	driver, err := exec.dbFactory.GetAdminDatabaseDriver(ctx, instance, database, db.ConnectionContext{})
+	if err != nil {
+		slog.Warn("failed to get database driver", log.BBError(err))
+		return ""
+	}
+	defer driver.Close(ctx)
+	position, err := GetBackupLogPosition(ctx, driver.GetDB(), instance.Engine)
+	if err != nil {
+		// The log position is only for the correlation, so the backup doesn't fail without it.
+		slog.Warn("failed to get the log position of the backup", slog.String("database", database.DatabaseName), log.BBError(err))
+		return ""
+	}
+	return position
+}
+
+// GetBackupLogPosition returns the current position of the transaction log, the WAL LSN on Postgres and the executed GTID set on MySQL.
+// The GTID set is empty if GTID is disabled on MySQL.
+func GetBackupLogPosition(ctx context.Context, sqlDB *sql.DB, engine storepb.Engine) (string, error) {
+	var query string
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		query = "SELECT pg_current_wal_lsn()::text"
+	case storepb.Engine_MYSQL:
+		query = "SELECT @@GLOBAL.gtid_executed"
+	default:
+		return "", errors.Errorf("log position is not supported for engine %s", engine)
+	}
+	var position string
+	if err := sqlDB.QueryRowContext(ctx, query).Scan(&position); err != nil {
+		return "", errors.Wrap(err, "failed to get log position")
+	}
+	return position, nil
+}
+
+// getBackupSessionSettings returns the session settings of the database affecting the temporal values and the DML, or empty if they cannot be captured.
+func (exec *DataUpdateExecutor) getBackupSessionSettings(ctx context.Context, instance *store.InstanceMessage, database *store.DatabaseMessage) map[string]string {
+	switch instance.Engine {
+	case storepb.Engine_MYSQL, storepb.Engine_ORACLE:
+	default:
+		return nil
+	}
+	driver, err := exec.dbFactory.GetAdminDatabaseDriver(ctx, instance, database, db.ConnectionContext{})
+	if err != nil {
+		slog.Warn("failed to get database driver", log.BBError(err))
+		return nil
+	}
+	defer driver.Close(ctx)
+	settings, err := GetBackupSessionSettings(ctx, driver.GetDB(), instance.Engine)
+	if err != nil {
+		// The restores fall back to the session settings at restore time, so the backup doesn't fail without them.
+		slog.Warn("failed to get the session settings of the backup", slog.String("database", database.DatabaseName), log.BBError(err))
+		return nil
+	}
+	return settings
+}
+
+// GetBackupSessionSettings returns the session settings affecting the temporal values and the DML, sql_mode and time_zone on MySQL,
+// and the NLS parameters and TIME_ZONE on Oracle. The new sessions of the data update have the same settings as the new session here.
+func GetBackupSessionSettings(ctx context.Context, sqlDB *sql.DB, engine storepb.Engine) (map[string]string, error) {
+	settings := make(map[string]string)
+	switch engine {
+	case storepb.Engine_MYSQL:
+		var sqlMode, timeZone string
+		if err := sqlDB.QueryRowContext(ctx, "SELECT @@SESSION.sql_mode, @@SESSION.time_zone").Scan(&sqlMode, &timeZone); err != nil {
+			return nil, errors.Wrap(err, "failed to get session settings")
 		}
-		switch instance.Engine {
-		case storepb.Engine_TIDB:
-			if _, err := driver.Execute(driverCtx, fmt.Sprintf("ALTER TABLE `%s`.`%s` COMMENT = 'issue %d'", backupDatabaseName, statement.TargetTableName, issue.UID), db.ExecuteOptions{}); err != nil {
-				return nil, errors.Wrap(err, "failed to set table comment")
-			}
-		case storepb.Engine_MYSQL:
-			if _, err := driver.Execute(driverCtx, fmt.Sprintf("ALTER TABLE `%s`.`%s` COMMENT = 'issue %d'", backupDatabaseName, statement.TargetTableName, issue.UID), db.ExecuteOptions{}); err != nil {
-				return nil, errors.Wrap(err, "failed to set table comment")
-			}
-		case storepb.Engine_MSSQL:
-			if _, err := backupDriver.Execute(driverCtx, fmt.Sprintf("EXEC sp_addextendedproperty 'MS_Description', 'issue %d', 'SCHEMA', 'dbo', 'TABLE', '%s'", issue.UID, statement.TargetTableName), db.ExecuteOptions{}); err != nil {
-				return nil, errors.Wrap(err, "failed to set table comment")
-			}
-		case storepb.Engine_POSTGRES:
-			if _, err := driver.Execute(driverCtx, fmt.Sprintf(`COMMENT ON TABLE "%s"."%s" IS 'issue %d'`, backupDatabaseName, statement.TargetTableName, issue.UID), db.ExecuteOptions{}); err != nil {
-				return nil, errors.Wrap(err, "failed to set table comment")
+		settings["sql_mode"] = sqlMode
+		settings["time_zone"] = timeZone
+	case storepb.Engine_ORACLE:
+		rows, err := sqlDB.QueryContext(ctx, "SELECT PARAMETER, VALUE FROM NLS_SESSION_PARAMETERS")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get NLS session parameters")
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var parameter string
+			var value sql.NullString
+			if err := rows.Scan(&parameter, &value); err != nil {
+				return nil, errors.Wrap(err, "failed to scan NLS session parameter")
 			}
-		case storepb.Engine_ORACLE:
-			if _, err := driver.Execute(driverCtx, fmt.Sprintf(`COMMENT ON TABLE "%s"."%s" IS 'issue %d'`, backupDatabaseName, statement.TargetTableName, issue.UID), db.ExecuteOptions{}); err != nil {
-				return nil, errors.Wrap(err, "failed to set table comment")
+			if value.Valid {
+				settings[parameter] = value.String
 			}
 		}
+		if err := rows.Err(); err != nil {
+			return nil, errors.Wrap(err, "failed to get NLS session parameters")
+		}
+		var timeZone string
+		if err := sqlDB.QueryRowContext(ctx, "SELECT SESSIONTIMEZONE FROM DUAL").Scan(&timeZone); err != nil {
+			return nil, errors.Wrap(err, "failed to get session time zone")
+		}
+		settings["TIME_ZONE"] = timeZone
+	default:
+		return nil, errors.Errorf("session settings are not supported for engine %s", engine)
+	}
+	return settings, nil
+}
 
-		priorBackupDetail.Items = append(priorBackupDetail.Items, &storepb.PriorBackupDetail_Item{
-			SourceTable: &storepb.PriorBackupDetail_Item_Table{
-				Database: sourceDatabaseName,
-				Table:    statement.SourceTableName,
-			},
-			TargetTable: &storepb.PriorBackupDetail_Item_Table{
-				Database: targetDatabaseName,
-				Schema:   "",
-				Table:    statement.TargetTableName,
-			},
-			StartPosition: statement.StartPosition,
-			EndPosition:   statement.EndPosition,
-		})
+// sessionSettingNameRegexp matches the names of the session settings that can be applied.
+var sessionSettingNameRegexp = regexp.MustCompile(`^[A-Za-z_]+$`)
 
-		if _, err := exec.store.CreateIssueComment(ctx, &store.IssueCommentMessage{
-			IssueUID: issue.UID,
-			Payload: &storepb.IssueCommentPayload{
-				Event: &storepb.IssueCommentPayload_TaskPriorBackup_{
-					TaskPriorBackup: &storepb.IssueCommentPayload_TaskPriorBackup{
-						Task:     common.FormatTask(issue.Project.ResourceID, task.PipelineID, task.StageID, task.ID),
-						Database: backupDatabaseName,
-						Tables: []*storepb.IssueCommentPayload_TaskPriorBackup_Table{
-							{
-								Schema: "",
-								Table:  statement.TargetTableName,
-							},
-						},
-					},
-				},
-			},
-		}, api.SystemBotID); err != nil {
-			slog.Warn("failed to create issue comment", "task", task.ID, log.BBError(err))
+// GetSessionSettingStatement returns the statement applying the session settings captured at backup time, or empty if there are none.
+// NLS_LANGUAGE and NLS_TERRITORY are applied first on Oracle because they reset the NLS parameters derived from them.
+func GetSessionSettingStatement(engine storepb.Engine, settings map[string]string) string {
+	var names []string
+	for name := range settings {
+		if sessionSettingNameRegexp.MatchString(name) {
+			names = append(names, name)
 		}
 	}
+	if len(names) == 0 {
+		return ""
+	}
+	rank := func(name string) int {
+		switch name {
+		case "NLS_LANGUAGE":
+			return 0
+		case "NLS_TERRITORY":
+			return 1
+		default:
+			return 2
+		}
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		if c := rank(a) - rank(b); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	var assignments []string
+	for _, name := range names {
+		assignments = append(assignments, fmt.Sprintf("%s = '%s'", name, strings.ReplaceAll(settings[name], "'", "''")))
+	}
+	switch engine {
+	case storepb.Engine_MYSQL:
+		return fmt.Sprintf("SET SESSION %s;", strings.Join(assignments, ", "))
+	case storepb.Engine_ORACLE:
+		return fmt.Sprintf("ALTER SESSION SET %s;", strings.Join(assignments, " "))
+	default:
+		return ""
+	}
+}
 
-	if instance.Engine != storepb.Engine_POSTGRES {
-		if err := exec.schemaSyncer.SyncDatabaseSchema(ctx, backupDatabase, false /* force */); err != nil {
-			slog.Error("failed to sync backup database schema",
-				slog.String("database", payload.PreUpdateBackupDetail.Database),
-				log.BBError(err),
-			)
+// setRestoreSessionSettings prefixes the restore statements of the items with the statement applying the session settings at backup time.
+func setRestoreSessionSettings(engine storepb.Engine, settings map[string]string, items []*storepb.PriorBackupDetail_Item) {
+	statement := GetSessionSettingStatement(engine, settings)
+	if statement == "" {
+		return
+	}
+	for _, item := range items {
+		if item.GetRestoreStatement() == "" {
+			continue
 		}
-	} else {
-		if err := exec.schemaSyncer.SyncDatabaseSchema(ctx, database, false /* force */); err != nil {
-			slog.Error("failed to sync backup database schema",
-				slog.String("database", fmt.Sprintf("/instances/%s/databases/%s", instance.ResourceID, database.DatabaseName)),
-				log.BBError(err),
-			)
+		item.RestoreStatement = statement + "\n" + item.RestoreStatement
+	}
+}
+
+// backupLimiter limits the number of databases of each engine backed up concurrently across the tasks.
+// A nil limiter doesn't limit any engines.
+type backupLimiter struct {
+	// slots are the semaphores of the limited engines.
+	slots map[storepb.Engine]chan struct{}
+}
+
+func newBackupLimiter(limits map[storepb.Engine]int) *backupLimiter {
+	slots := make(map[storepb.Engine]chan struct{})
+	for engine, limit := range limits {
+		if limit > 0 {
+			slots[engine] = make(chan struct{}, limit)
+		}
+	}
+	return &backupLimiter{slots: slots}
+}
+
+// acquire waits until a database of the engine can be backed up, and returns the function releasing it.
+func (l *backupLimiter) acquire(ctx context.Context, engine storepb.Engine) (func(), error) {
+	if l == nil || l.slots[engine] == nil {
+		return func() {}, nil
+	}
+	slots := l.slots[engine]
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, errors.Wrapf(ctx.Err(), "failed to wait for the backup concurrency of engine %s", engine)
+	}
+}
+
+// backupTimeoutError is the error of the prior backup aborted by the overall backup timeout.
+type backupTimeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e *backupTimeoutError) Error() string {
+	return fmt.Sprintf("prior backup exceeded the timeout %s: %v", e.timeout, e.err)
+}
+
+func (e *backupTimeoutError) Unwrap() error {
+	return e.err
+}
+
+// backupFailedError is the error of the data update failed by the prior backup.
+type backupFailedError struct {
+	// detail is the prior backup detail of the backup tables created before the failure.
+	detail *storepb.PriorBackupDetail
+	err    error
+}
+
+func (e *backupFailedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *backupFailedError) Unwrap() error {
+	return e.err
+}
+
+// backupDeferredError is the error of the prior backup deferred by a blackout window or the time budget of the task.
+type backupDeferredError struct {
+	until time.Time
+	// reason is what the backup is deferred by.
+	reason string
+}
+
+func (e *backupDeferredError) Error() string {
+	return fmt.Sprintf("prior backup is deferred by %s until %s", e.reason, e.until.Format(time.RFC3339))
+}
+
+// errBackupInProgress is the error of the asynchronous prior backup still in progress.
+var errBackupInProgress = errors.New("prior backup is in progress")
+
+// asyncBackup is the prior backup of a task run running in the background.
+type asyncBackup struct {
+	// done is closed when the backup completes.
+	done chan struct{}
+	// cancel cancels the context of the backup.
+	cancel     context.CancelFunc
+	detail     *storepb.PriorBackupDetail
+	statements []string
+	err        error
+}
+
+// inProgress returns whether the backup has not completed.
+func (b *asyncBackup) inProgress() bool {
+	select {
+	case <-b.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// getAsyncBackup returns the result of the asynchronous prior backup of the task run, which is started by the backup function
+// on the first call. It returns errBackupInProgress until the backup completes, and forgets the backup after returning its result,
+// so that a failed backup fails or degrades the data update by the backup requirement as the synchronous one does.
+// The completed backup gone stale before the data update runs is discarded and backed up again in the background too.
+// The backup is bound to a context canceled by the cancel func of the task run in PriorBackupAsyncCancelFunc. The database is held
+// by the task run while the backup runs, and released when the backup completes or is canceled.
+func (exec *DataUpdateExecutor) getAsyncBackup(ctx context.Context, task *store.TaskMessage, taskRunUID int, backup func(backupCtx context.Context) (*storepb.PriorBackupDetail, []string, error), discard func(*storepb.PriorBackupDetail)) (*storepb.PriorBackupDetail, []string, error) {
+	var stale *storepb.PriorBackupDetail
+	if value, ok := exec.stateCfg.PriorBackupAsyncTaskRuns.Load(taskRunUID); ok {
+		b, ok := value.(*asyncBackup)
+		if !ok {
+			return nil, nil, errors.Errorf("invalid asynchronous prior backup of task run %d", taskRunUID)
+		}
+		if b.inProgress() {
+			return nil, nil, errBackupInProgress
+		}
+		b.cancel()
+		exec.stateCfg.PriorBackupAsyncTaskRuns.Delete(taskRunUID)
+		exec.stateCfg.PriorBackupAsyncCancelFunc.Delete(taskRunUID)
+		if b.err != nil || !isBackupStale(b.detail, exec.profile.PriorBackupMaxGap, exec.now()) {
+			return b.detail, b.statements, b.err
+		}
+		slog.Info("the prior backup is stale, back up again in the background",
+			slog.Time("completeTime", b.detail.GetCompleteTime().AsTime()),
+			slog.Duration("maxGap", exec.profile.PriorBackupMaxGap),
+		)
+		stale = b.detail
+	}
+
+	release := func() {
+		if task.DatabaseID != nil {
+			exec.stateCfg.RunningDatabaseMigration.CompareAndDelete(*task.DatabaseID, task.ID)
+		}
+	}
+	backupCtx, cancel := context.WithCancel(ctx)
+	b := &asyncBackup{done: make(chan struct{}), cancel: cancel}
+	exec.stateCfg.PriorBackupAsyncTaskRuns.Store(taskRunUID, b)
+	exec.stateCfg.PriorBackupAsyncCancelFunc.Store(taskRunUID, context.CancelFunc(func() {
+		cancel()
+		exec.stateCfg.PriorBackupAsyncTaskRuns.Delete(taskRunUID)
+		exec.stateCfg.PriorBackupAsyncCancelFunc.Delete(taskRunUID)
+		// The canceled task run is not scheduled again, so the database held by it is released here.
+		release()
+	}))
+	go func() {
+		// The stale backup is discarded before backing up again, so that it's never left behind even if the new backup fails.
+		if stale != nil {
+			discard(stale)
+		}
+		b.detail, b.statements, b.err = backup(backupCtx)
+		close(b.done)
+		// The database is held again when the data update gated by the backup is scheduled.
+		release()
+		// Tickle the scheduler to run the data update gated by the backup without waiting for the next tick.
+		select {
+		case exec.stateCfg.TaskRunTickleChan <- 0:
+		default:
+		}
+	}()
+	return nil, nil, errBackupInProgress
+}
+
+// checkBackupBlackout returns a backupDeferredError if the backup is in any blackout window of the backup instance.
+func (exec *DataUpdateExecutor) checkBackupBlackout(windows []string) error {
+	until, err := getBackupBlackoutEnd(windows, exec.now())
+	if err != nil {
+		return err
+	}
+	if !until.IsZero() {
+		return &backupDeferredError{until: until, reason: "the blackout window"}
+	}
+	return nil
+}
+
+// getBackupBlackoutEnd returns the end of the blackout window containing the time, or the zero time if the time is not in any window.
+// The windows are daily UTC windows such as "22:00-02:00", which spans midnight since it ends before it starts.
+func getBackupBlackoutEnd(windows []string, now time.Time) (time.Time, error) {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	elapsed := now.Sub(midnight)
+	var until time.Time
+	for _, window := range windows {
+		startOffset, endOffset, err := common.ParsePriorBackupBlackoutWindow(window)
+		if err != nil {
+			return time.Time{}, err
+		}
+		var windowEnd time.Time
+		switch {
+		case startOffset < endOffset:
+			if elapsed >= startOffset && elapsed < endOffset {
+				windowEnd = midnight.Add(endOffset)
+			}
+		default:
+			// The window spans midnight.
+			if elapsed >= startOffset {
+				windowEnd = midnight.AddDate(0, 0, 1).Add(endOffset)
+			} else if elapsed < endOffset {
+				windowEnd = midnight.Add(endOffset)
+			}
 		}
+		if windowEnd.After(until) {
+			until = windowEnd
+		}
+	}
+	return until, nil
+}
+
+// withBackupTimeout runs the backup with the driver context bounded by the overall backup timeout.
+// It returns a backupTimeoutError if the backup failed because the timeout was exceeded. Zero timeout means no deadline.
+func withBackupTimeout(driverCtx context.Context, timeout time.Duration, backup func(driverCtx context.Context) error) error {
+	if timeout <= 0 {
+		return backup(driverCtx)
+	}
+	backupCtx, cancel := context.WithTimeout(driverCtx, timeout)
+	defer cancel()
+	err := backup(backupCtx)
+	if err != nil && driverCtx.Err() == nil && errors.Is(backupCtx.Err(), context.DeadlineExceeded) {
+		return &backupTimeoutError{timeout: timeout, err: err}
+	}
+	return err
+}
+
+// getBackupRequirement returns the backup requirement of the task.
+// The unspecified requirement is REQUIRED if the backup database, sink or object storage is set, and DISABLED otherwise.
+func getBackupRequirement(payload *storepb.TaskDatabaseUpdatePayload) storepb.TaskDatabaseUpdatePayload_BackupRequirement {
+	if requirement := payload.GetBackupRequirement(); requirement != storepb.TaskDatabaseUpdatePayload_BACKUP_REQUIREMENT_UNSPECIFIED {
+		return requirement
 	}
+	if payload.GetPreUpdateBackupDetail().GetDatabase() == "" && payload.GetPreUpdateBackupDetail().GetSinkTopic() == "" && !payload.GetPreUpdateBackupDetail().GetObjectStorage() {
+		return storepb.TaskDatabaseUpdatePayload_DISABLED
+	}
+	return storepb.TaskDatabaseUpdatePayload_REQUIRED
+}
+
+// checkBackupShortfall returns the error failing the task for the backup shortfall by the backup requirement,
+// or nil if the data can be updated without the backup.
+func checkBackupShortfall(requirement storepb.TaskDatabaseUpdatePayload_BackupRequirement, err error) error {
+	switch requirement {
+	case storepb.TaskDatabaseUpdatePayload_BEST_EFFORT, storepb.TaskDatabaseUpdatePayload_DISABLED:
+		return nil
+	default:
+		return err
+	}
+}
+
+// backupOptions are the options of backing up a target.
+type backupOptions struct {
+	// prefix is the backup table prefix.
+	prefix         string
+	isolationLevel sql.IsolationLevel
+	// provision creates the backup database if it doesn't exist.
+	provision bool
+	// deferred defers the backup statements to the data update transaction.
+	deferred bool
+	// lockRows locks the backed up rows by the deferred backup statements.
+	lockRows bool
+	// encryptionKey is the reference to the KMS key encrypting the backup tables.
+	encryptionKey string
+	// resourceGroup is the resource control group of the backup sessions.
+	resourceGroup string
+	// sessionRole is the database role the backup statements run under.
+	sessionRole string
+	// logBackupTables logs the backup tables before they're created, so that they're dropped if the backup is interrupted.
+	logBackupTables func([]*storepb.PriorBackupDetail_Item)
+	// sampleRate backs up one in sampleRate of the affected rows on average if it's non-zero.
+	sampleRate int32
+	// sampleLimit backs up only the first sampleLimit affected rows ordered by the primary key if it's non-zero,
+	// or the last ones if sampleFromLast.
+	sampleLimit    int32
+	sampleFromLast bool
+	// readTimeout and writeTimeout bound reading the rows from the source table and writing them into the backup table.
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	// principal is the email of the principal who initiated the data change.
+	principal string
+	// schemaVersion is the schema version of the task.
+	schemaVersion string
+	// namespace is the tenant namespace of the backup tables.
+	namespace string
+	// procedureTables are the tables declared to be modified by the stored procedures called in the statement.
+	procedureTables []string
+	// backupStatement is the statement the backup is derived from instead of the DML if set.
+	backupStatement string
+	// sessionSettings are the session settings of the task database at backup time applied by the restore statements.
+	sessionSettings map[string]string
+}
+
+// maximumBackupNamespaceLength is the maximum length of the backup namespace, leaving room for the source table names in the backup table names.
+const maximumBackupNamespaceLength = 16
 
-	return priorBackupDetail, nil
+// backupNamespaceInvalidRegexp matches the characters not allowed in the backup namespace.
+// The underscores are not allowed because they separate the parts of the backup table names.
+var backupNamespaceInvalidRegexp = regexp.MustCompile(`[^a-z0-9]`)
+
+// getBackupNamespace returns the tenant namespace of the backup tables of the database, which is the value of the label,
+// or the project ID if the database doesn't have the label. It returns empty if the label is not configured.
+func getBackupNamespace(database *store.DatabaseMessage, label string) string {
+	if label == "" {
+		return ""
+	}
+	namespace := database.Metadata.GetLabels()[label]
+	if namespace == "" {
+		namespace = database.ProjectID
+	}
+	namespace = backupNamespaceInvalidRegexp.ReplaceAllString(strings.ToLower(namespace), "")
+	namespace, _ = common.TruncateString(namespace, maximumBackupNamespaceLength)
+	return namespace
+}
+
+// getBackupTablePrefix returns the prefix of the backup table names, e.g. _20240101000000, or acme_20240101000000 in the namespace acme.
+func getBackupTablePrefix(namespace string, now time.Time) string {
+	return namespace + "_" + now.Format("20060102150405")
+}
+
+// getBackupPrincipal returns the email of the principal who initiated the task run.
+// It falls back to the system bot if the task run is unattended or its creator cannot be found.
+func (exec *DataUpdateExecutor) getBackupPrincipal(ctx context.Context, taskRunUID int) string {
+	taskRuns, err := exec.store.ListTaskRunsV2(ctx, &store.FindTaskRunMessage{UID: &taskRunUID})
+	if err != nil {
+		slog.Warn("failed to get task run", slog.Int("taskRun", taskRunUID), log.BBError(err))
+		return api.SystemBotEmail
+	}
+	if len(taskRuns) == 0 || taskRuns[0].Creator == nil {
+		return api.SystemBotEmail
+	}
+	return taskRuns[0].Creator.Email
+}
+
+// supportPerIssueBackupDatabase returns whether the per-issue backup database can be created on the engine.
+func supportPerIssueBackupDatabase(engine storepb.Engine) bool {
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_TIDB, storepb.Engine_POSTGRES:
+		return true
+	default:
+		return false
+	}
+}
+
+// getPerIssueBackupDatabaseName returns the name of the backup database dedicated to the issue.
+func getPerIssueBackupDatabaseName(backupDatabaseName string, issueUID int) string {
+	return fmt.Sprintf("%s_issue_%d", backupDatabaseName, issueUID)
+}
+
+// getPerIssueBackupDetail returns the backup detail with the backup database replaced by the one dedicated to the issue.
+func getPerIssueBackupDetail(backupDetail *storepb.PreUpdateBackupDetail, issueUID int) (*storepb.PreUpdateBackupDetail, error) {
+	instanceID, backupDatabaseName, err := common.GetInstanceDatabaseID(backupDetail.Database)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse backup database")
+	}
+	perIssueBackupDetail, ok := proto.Clone(backupDetail).(*storepb.PreUpdateBackupDetail)
+	if !ok {
+		return nil, errors.Errorf("failed to clone backup detail")
+	}
+	perIssueBackupDetail.Database = common.FormatDatabase(instanceID, getPerIssueBackupDatabaseName(backupDatabaseName, issueUID))
+	return perIssueBackupDetail, nil
+}
+
+// backupTarget is a source database and the database keeping its prior backup.
+type backupTarget struct {
+	// Format: instances/{instance}/databases/{database}
+	source string
+	// Format: instances/{instance}/databases/{database}
+	target string
+}
+
+// backupVersionGap is a feature of the source database since an engine version, which the backup database of an older version lacks.
+type backupVersionGap struct {
+	engine  storepb.Engine
+	version string
+	feature string
+}
+
+// backupVersionGaps are the known features the backup tables fail to hold or lose fidelity of on the older backup databases.
+var backupVersionGaps = []backupVersionGap{
+	{engine: storepb.Engine_MYSQL, version: "8.0", feature: "the utf8mb4_0900 collations"},
+	{engine: storepb.Engine_MYSQL, version: "8.0.23", feature: "the invisible columns"},
+	{engine: storepb.Engine_POSTGRES, version: "12", feature: "the stored generated columns"},
+	{engine: storepb.Engine_POSTGRES, version: "14", feature: "the multirange types"},
+	{engine: storepb.Engine_ORACLE, version: "21", feature: "the JSON data type"},
+	{engine: storepb.Engine_ORACLE, version: "23", feature: "the BOOLEAN data type"},
+}
+
+// checkBackupVersions returns the warnings about the backup databases on the other instances running different engine versions
+// from the source databases by the synced instance versions. It fails on the known incompatible gaps if configured.
+func (exec *DataUpdateExecutor) checkBackupVersions(ctx context.Context, engine storepb.Engine, targets []*backupTarget) ([]string, error) {
+	var warnings []string
+	for _, target := range targets {
+		sourceInstanceID, _, err := common.GetInstanceDatabaseID(target.source)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse source database")
+		}
+		backupInstanceID, _, err := common.GetInstanceDatabaseID(target.target)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse backup database")
+		}
+		if sourceInstanceID == backupInstanceID {
+			continue
+		}
+		sourceInstance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{ResourceID: &sourceInstanceID})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get instance")
+		}
+		backupInstance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{ResourceID: &backupInstanceID})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get backup instance")
+		}
+		if sourceInstance == nil || backupInstance == nil {
+			continue
+		}
+		versionWarnings, incompatible := getBackupVersionWarnings(engine, sourceInstance.EngineVersion, backupInstance.EngineVersion)
+		if incompatible && exec.profile.PriorBackupFailOnVersionGap {
+			return nil, errors.Errorf("backup database %q is incompatible with the source database %q: %s", target.target, target.source, strings.Join(versionWarnings, "; "))
+		}
+		for _, warning := range versionWarnings {
+			warnings = append(warnings, fmt.Sprintf("backup database %q: %s", target.target, warning))
+		}
+	}
+	return warnings, nil
+}
+
+// getBackupVersionWarnings returns the warnings about the backup database running a different engine version from the source database,
+// and whether the backup database lacks any known feature of the source database version. The unknown versions are not compared.
+func getBackupVersionWarnings(engine storepb.Engine, sourceVersion, backupVersion string) ([]string, bool) {
+	source, backup := parseEngineVersion(sourceVersion), parseEngineVersion(backupVersion)
+	if len(source) == 0 || len(backup) == 0 || (source[0] == backup[0] && compareEngineVersions(source, backup) <= 0) {
+		// The same major version or a newer minor version of the backup database holds the backup tables.
+		return nil, false
+	}
+	var warnings []string
+	incompatible := false
+	for _, gap := range backupVersionGaps {
+		if gap.engine != engine {
+			continue
+		}
+		since := parseEngineVersion(gap.version)
+		if compareEngineVersions(source, since) >= 0 && compareEngineVersions(backup, since) < 0 {
+			warnings = append(warnings, fmt.Sprintf("the backup database version %s lacks %s of the source database version %s since %s", backupVersion, gap.feature, sourceVersion, gap.version))
+			incompatible = true
+		}
+	}
+	if !incompatible {
+		warnings = append(warnings, fmt.Sprintf("the backup database version %s differs from the source database version %s, the backup may fail or lose fidelity", backupVersion, sourceVersion))
+	}
+	return warnings, incompatible
+}
+
+// parseEngineVersion returns the leading numeric components of the engine version, e.g. [8 0 33] of 8.0.33-log.
+func parseEngineVersion(version string) []int {
+	var result []int
+	for _, part := range strings.Split(version, ".") {
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			break
+		}
+		v, err := strconv.Atoi(part[:end])
+		if err != nil {
+			break
+		}
+		result = append(result, v)
+		if end < len(part) {
+			break
+		}
+	}
+	return result
+}
+
+// compareEngineVersions compares the engine versions by the numeric components, the missing ones being zero.
+func compareEngineVersions(a, b []int) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return cmp.Compare(x, y)
+		}
+	}
+	return 0
+}
+
+// getBackupTargets returns the backup targets for the task database and its shard databases.
+// The backup database of a shard is the database with the same name as the backup database on the shard instance.
+func getBackupTargets(sourceDatabase string, backupDetail *storepb.PreUpdateBackupDetail) ([]*backupTarget, error) {
+	targets := []*backupTarget{
+		{
+			source: sourceDatabase,
+			target: backupDetail.Database,
+		},
+	}
+	_, backupDatabaseName, err := common.GetInstanceDatabaseID(backupDetail.Database)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse backup database")
+	}
+	seen := map[string]bool{sourceDatabase: true}
+	for _, shard := range backupDetail.ShardDatabases {
+		shardInstanceID, _, err := common.GetInstanceDatabaseID(shard)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse shard database %q", shard)
+		}
+		if seen[shard] {
+			continue
+		}
+		seen[shard] = true
+		targets = append(targets, &backupTarget{
+			source: shard,
+			target: common.FormatDatabase(shardInstanceID, backupDatabaseName),
+		})
+	}
+	return targets, nil
+}
+
+// backupDatabaseData backs up the rows affected by the statement in the source database of the target.
+func (exec *DataUpdateExecutor) backupDatabaseData(
+	ctx context.Context,
+	driverCtx context.Context,
+	statement string,
+	task *store.TaskMessage,
+	issue *store.IssueMessage,
+	engine storepb.Engine,
+	target *backupTarget,
+	opts *backupOptions,
+) ([]*storepb.PriorBackupDetail_Item, []string, error) {
+	instanceID, databaseName, err := common.GetInstanceDatabaseID(target.source)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse source database")
+	}
+	instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{ResourceID: &instanceID})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get instance")
+	}
+	if instance == nil {
+		return nil, nil, errors.Errorf("instance %q not found", instanceID)
+	}
+	if instance.Engine != engine {
+		return nil, nil, errors.Errorf("engine %s of database %q mismatches the task engine %s", instance.Engine, target.source, engine)
+	}
+	database, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{InstanceID: &instanceID, DatabaseName: &databaseName})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get database")
+	}
+	if database == nil {
+		return nil, nil, errors.Errorf("database %q not found", target.source)
+	}
+	// The shards may be transferred to another project after the task is created.
+	if database.ProjectID != issue.Project.ResourceID {
+		return nil, nil, errors.Errorf("database %q does not belong to project %q", target.source, issue.Project.ResourceID)
+	}
+
+	sourceDatabaseName := target.source
+	// Format: instances/{instance}/databases/{database}
+	targetDatabaseName := target.target
+	var backupDatabase *store.DatabaseMessage
+	var backupDriver db.Driver
+
+	backupInstanceID, backupDatabaseName, err := common.GetInstanceDatabaseID(targetDatabaseName)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse backup database")
+	}
+	breaker := exec.stateCfg.PriorBackupCircuitBreaker
+	if allowed, until := breaker.Allow(targetDatabaseName, exec.now()); !allowed {
+		return nil, nil, errors.Errorf("backup database %q failed repeatedly, skip backups until %s", targetDatabaseName, until.Format(time.RFC3339))
+	}
+
+	crossRegion := false
+	if backupInstanceID != instance.ResourceID {
+		backupInstance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{ResourceID: &backupInstanceID})
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to get backup instance")
+		}
+		if backupInstance != nil && isCrossRegion(instance, backupInstance) {
+			crossRegion = true
+			slog.Warn("the backup database is in a different region from the source database, the backup may take much longer",
+				slog.String("database", sourceDatabaseName),
+				slog.String("region", instance.Options.GetRegion()),
+				slog.String("backupDatabase", targetDatabaseName),
+				slog.String("backupRegion", backupInstance.Options.GetRegion()),
+			)
+		}
+	}
+
+	if instance.Engine != storepb.Engine_POSTGRES {
+		backupDatabase, err = exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{InstanceID: &backupInstanceID, DatabaseName: &backupDatabaseName})
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to get backup database")
+		}
+		if backupDatabase == nil && opts.provision {
+			backupDatabase, err = exec.createBackupDatabase(ctx, driverCtx, instance, database, backupDatabaseName)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to create backup database %q", targetDatabaseName)
+			}
+		}
+		if backupDatabase == nil {
+			return nil, nil, errors.Errorf("backup database %q not found", targetDatabaseName)
+		}
+		backupDriver, err = exec.getBackupDriver(driverCtx, instance, backupDatabase)
+		if err != nil {
+			breaker.RecordFailure(targetDatabaseName, exec.now())
+			return nil, nil, errors.Wrap(err, "failed to get backup database driver")
+		}
+		defer backupDriver.Close(driverCtx)
+		if exec.profile.PriorBackupVerifyDrivers {
+			if err := checkDriverDatabase(driverCtx, backupDriver, instance.Engine, backupDatabaseName); err != nil {
+				return nil, nil, errors.Wrap(err, "backup database driver")
+			}
+		}
+	}
+
+	driver, err := exec.getBackupDriver(driverCtx, instance, database)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get database driver")
+	}
+	defer driver.Close(driverCtx)
+	// The backup tables are written by the source database driver into the backup schema on Postgres.
+	if exec.profile.PriorBackupVerifyDrivers {
+		if err := checkDriverDatabase(driverCtx, driver, instance.Engine, database.DatabaseName); err != nil {
+			return nil, nil, errors.Wrap(err, "source database driver")
+		}
+	}
+
+	if instance.Engine == storepb.Engine_POSTGRES && opts.provision {
+		schema, err := base.QuoteIdentifier(instance.Engine, backupDatabaseName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := driver.Execute(driverCtx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;", schema), db.ExecuteOptions{}); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to create backup schema %q", backupDatabaseName)
+		}
+	}
+
+	if opts.encryptionKey != "" {
+		if err := checkBackupEncryptionKey(driverCtx, driver.GetDB(), instance.Engine, opts.encryptionKey); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tc := base.TransformContext{
+		InstanceID:              instance.ResourceID,
+		GetDatabaseMetadataFunc: BuildGetDatabaseMetadataFunc(exec.store),
+		LockRows:                opts.lockRows,
+		AfterImage:              exec.profile.PriorBackupAfterImage,
+	}
+	if instance.Engine == storepb.Engine_ORACLE {
+		oracleDriver, ok := driver.(*oracle.Driver)
+		if ok {
+			if version, err := oracleDriver.GetVersion(); err == nil {
+				tc.Version = version
+			}
+		}
+	}
+
+	// The positions of the backup statements are in the statement the backup is derived from.
+	backupSource := statement
+	if opts.backupStatement != "" {
+		backupSource = opts.backupStatement
+	}
+	statements, err := transformBackupStatement(ctx, instance.Engine, tc, statement, opts.backupStatement, database.DatabaseName, backupDatabaseName, opts.prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	dbSchema, err := exec.store.GetDBSchema(ctx, database.UID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get database schema")
+	}
+	var metadata *storepb.DatabaseSchemaMetadata
+	var config *storepb.DatabaseConfig
+	if dbSchema != nil {
+		metadata = dbSchema.GetMetadata()
+		config = dbSchema.GetConfig()
+	}
+	if exec.profile.PriorBackupOrderByDependency && metadata != nil && len(statements) > 1 {
+		statements = orderBackupStatementsByDependency(instance.Engine, statements, metadata)
+	}
+	if err := exec.applyFullTableBackup(ctx, instance.Engine, tc, database.DatabaseName, backupDatabaseName, statements); err != nil {
+		return nil, nil, err
+	}
+	procedureStatements, err := GetProcedureBackupStatements(ctx, instance.Engine, tc, statement, database.DatabaseName, backupDatabaseName, opts.prefix, opts.procedureTables)
+	if err != nil {
+		return nil, nil, err
+	}
+	statements = append(statements, procedureStatements...)
+	// The ranges of the parts are keyed by their backup tables.
+	var ranges map[string]*storepb.PriorBackupDetail_Item_Range
+	if exec.profile.PriorBackupPartitionRows > 0 && !opts.deferred && opts.sampleRate == 0 && opts.sampleLimit == 0 {
+		statements, ranges, err = partitionBackupStatements(driverCtx, driver.GetDB(), instance.Engine, backupDatabaseName, statements, metadata, exec.profile.PriorBackupPartitionRows)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// The backup statements are executed by the snapshot instead of the driver if the source tables are read in one snapshot.
+	var snapshot *BackupSnapshot
+	var exportedSnapshot *ExportedBackupSnapshot
+	executor := driver
+	if exec.profile.PriorBackupExportSnapshot && !opts.deferred && instance.Engine == storepb.Engine_POSTGRES {
+		exportedSnapshot, err = ExportBackupSnapshot(driverCtx, driver, instance.Engine)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer exportedSnapshot.Release()
+		executor = exportedSnapshot
+	} else if exec.profile.PriorBackupConsistentSnapshot && !opts.deferred && len(statements) > 1 && supportBackupSnapshot(instance.Engine) {
+		snapshot, err = BeginBackupSnapshot(driverCtx, driver, instance.Engine)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer snapshot.Rollback()
+		executor = snapshot
+	}
+
+	items, deferredStatements, err := exec.backupTables(driverCtx, &backupTableContext{
+		instance:           instance,
+		database:           database,
+		issue:              issue,
+		sourceDatabaseName: sourceDatabaseName,
+		targetDatabaseName: targetDatabaseName,
+		backupDatabaseName: backupDatabaseName,
+		driver:             driver,
+		backupDriver:       backupDriver,
+		executor:           executor,
+		exportedSnapshot:   exportedSnapshot,
+		metadata:           metadata,
+		config:             config,
+		ranges:             ranges,
+		crossRegion:        crossRegion,
+		opts:               opts,
+	}, statements)
+	if err != nil {
+		items = getFailedBackupItems(instance.Engine, snapshot, items)
+	}
+	// The backup tables created before a failure are recorded too, so that they can be found and cleaned up.
+	exec.createPriorBackupComments(ctx, issue, task, backupDatabaseName, items)
+	if err != nil {
+		return items, nil, err
+	}
+
+	// The sample backups cannot be restored.
+	if opts.sampleRate == 0 && opts.sampleLimit == 0 {
+		setBackupRestoreStatements(ctx, instance.Engine, base.RestoreContext{
+			InstanceID:              instance.ResourceID,
+			GetDatabaseMetadataFunc: tc.GetDatabaseMetadataFunc,
+		}, backupSource, database.DatabaseName, backupDatabaseName, items)
+		setRestoreSessionSettings(instance.Engine, opts.sessionSettings, items)
+	}
+
+	if opts.deferred {
+		// The backup tables are created by the data update transaction and synced afterwards.
+		return items, deferredStatements, nil
+	}
+	if snapshot != nil {
+		if err := snapshot.Commit(); err != nil {
+			breaker.RecordFailure(targetDatabaseName, exec.now())
+			return getFailedBackupItems(instance.Engine, snapshot, items), nil, err
+		}
+	}
+	breaker.RecordSuccess(targetDatabaseName)
+	// The backup tables are in the backup schema of the task database on Postgres.
+	syncDatabase := backupDatabase
+	if instance.Engine == storepb.Engine_POSTGRES {
+		syncDatabase = database
+	}
+	if err := exec.syncBackupDatabaseSchema(ctx, syncDatabase, items); err != nil {
+		slog.Error("failed to sync backup database schema",
+			slog.String("database", common.FormatDatabase(syncDatabase.InstanceID, syncDatabase.DatabaseName)),
+			log.BBError(err),
+		)
+	}
+
+	return items, nil, nil
+}
+
+// backupTableContext is the context of backing up the tables of a source database into the backup database.
+type backupTableContext struct {
+	instance *store.InstanceMessage
+	database *store.DatabaseMessage
+	issue    *store.IssueMessage
+	// sourceDatabaseName and targetDatabaseName are the source and backup databases.
+	// Format: instances/{instance}/databases/{database}
+	sourceDatabaseName string
+	targetDatabaseName string
+	backupDatabaseName string
+	// driver is the source database driver, and backupDriver is the backup database driver if the backup database is not a schema of the source database.
+	driver       db.Driver
+	backupDriver db.Driver
+	// executor executes the backup statements, i.e. the source database driver or the snapshot reading the source tables.
+	executor         db.Driver
+	exportedSnapshot *ExportedBackupSnapshot
+	metadata         *storepb.DatabaseSchemaMetadata
+	config           *storepb.DatabaseConfig
+	// ranges are the ranges of the parts keyed by their backup tables.
+	ranges      map[string]*storepb.PriorBackupDetail_Item_Range
+	crossRegion bool
+	opts        *backupOptions
+}
+
+// backupTables backs up the tables by the backup statements.
+// The items of the backup tables created before a failure are returned with the error, so that they're tracked and cleaned up.
+func (exec *DataUpdateExecutor) backupTables(driverCtx context.Context, t *backupTableContext, statements []base.BackupStatement) ([]*storepb.PriorBackupDetail_Item, []string, error) {
+	breaker := exec.stateCfg.PriorBackupCircuitBreaker
+	var items []*storepb.PriorBackupDetail_Item
+	var deferredStatements []string
+	if t.opts.logBackupTables != nil {
+		var planned []*storepb.PriorBackupDetail_Item
+		for _, statement := range statements {
+			planned = append(planned, getCreatedBackupItem(t.instance, t.sourceDatabaseName, t.targetDatabaseName, statement, exec.getBackupImmutableUntil(t.instance.Engine)))
+		}
+		t.opts.logBackupTables(planned)
+	}
+	// The schemas of the backup tables created in the backup database.
+	backupSchemas := make(map[string]bool)
+	for _, statement := range statements {
+		schemaStatement, err := GetBackupSchemaStatement(t.instance.Engine, statement.TargetSchema)
+		if err != nil {
+			return items, nil, err
+		}
+		if schemaStatement != "" && t.opts.provision && !backupSchemas[statement.TargetSchema] {
+			if _, err := t.backupDriver.Execute(driverCtx, schemaStatement, db.ExecuteOptions{}); err != nil {
+				return items, nil, errors.Wrapf(err, "failed to create backup schema %q", statement.TargetSchema)
+			}
+			backupSchemas[statement.TargetSchema] = true
+		}
+		storageEngine, tablespace, err := getSourceTableStorage(driverCtx, t.driver.GetDB(), t.instance.Engine, t.database.DatabaseName, statement)
+		if err != nil {
+			slog.Warn("failed to get the storage of the source table", slog.String("table", statement.SourceTableName), log.BBError(err))
+		}
+		var ownedSequences []*storepb.PriorBackupDetail_Item_OwnedSequence
+		if t.instance.Engine == storepb.Engine_POSTGRES {
+			ownedSequences, err = GetOwnedSequences(driverCtx, t.driver.GetDB(), statement)
+			if err != nil {
+				slog.Warn("failed to get the owned sequences of the source table", slog.String("table", statement.SourceTableName), log.BBError(err))
+			}
+		}
+		var surrogateKey storepb.PriorBackupDetail_Item_SurrogateKey
+		if exec.profile.PriorBackupSurrogateKey {
+			if keyed, key, ok := GetSurrogateKeyBackupStatement(t.instance.Engine, statement); ok {
+				statement.Statement = keyed
+				surrogateKey = key
+			}
+		}
+		excludedColumns, err := applyNoBackupColumns(t.instance.Engine, &statement, t.metadata, t.config)
+		if err != nil {
+			return items, nil, err
+		}
+		if t.opts.sampleRate > 0 {
+			sampled, err := GetSampleBackupStatement(t.instance.Engine, t.backupDatabaseName, statement, t.opts.sampleRate)
+			if err != nil {
+				return items, nil, err
+			}
+			statement.Statement = sampled
+		}
+		if t.opts.sampleLimit > 0 {
+			sampled, err := GetBoundedSampleBackupStatement(t.instance.Engine, t.backupDatabaseName, statement, getPrimaryKeyColumns(findBackupSourceTable(t.instance.Engine, statement, t.metadata)), t.opts.sampleLimit, t.opts.sampleFromLast)
+			if err != nil {
+				return items, nil, err
+			}
+			statement.Statement = sampled
+		}
+		if exec.profile.PriorBackupReplicateTablespace && tablespace != "" {
+			if replicated, ok := setBackupTablespace(t.instance.Engine, t.backupDatabaseName, statement, tablespace); ok {
+				statement.Statement = replicated
+			}
+		}
+		if exec.profile.PriorBackupImmutableTable && t.instance.Engine == storepb.Engine_ORACLE {
+			immutable, ok := setBackupImmutable(t.backupDatabaseName, statement)
+			if !ok {
+				return items, nil, errors.Errorf("failed to create immutable backup table for statement %q", statement.Statement)
+			}
+			statement.Statement = immutable
+		}
+		lightweight := isSmallBackupTable(t.instance.Engine, statement, t.metadata, exec.profile.PriorBackupSmallTableRows)
+		var fullTableReason string
+		if statement.Uncertainty > exec.profile.PriorBackupMaximumUncertainty {
+			fullTableReason = statement.UncertaintyReason
+		}
+		var commentStatement string
+		if !lightweight {
+			commentStatement, err = exec.getBackupTableCommentStatement(t.instance.Engine, t.backupDatabaseName, statement.TargetSchema, statement.TargetTableName, t.issue.UID, t.opts.namespace, t.issue.Project.ResourceID, t.database.EffectiveEnvironmentID, t.opts.principal, t.opts.schemaVersion)
+			if err != nil {
+				return items, nil, err
+			}
+		}
+		var encryptionStatement string
+		if t.opts.encryptionKey != "" {
+			encryptionStatement, err = getBackupEncryptionStatement(t.instance.Engine, t.backupDatabaseName, statement.TargetTableName)
+			if err != nil {
+				return items, nil, err
+			}
+		}
+		// The strategy is selected by the estimated rows of the source table by the synced metadata.
+		strategy, strategyReason := getBackupStrategy(t.instance.Engine, exec.profile.PriorBackupBulkCopy, t.crossRegion, findBackupSourceTable(t.instance.Engine, statement, t.metadata).GetRowCount(), exec.profile.PriorBackupBulkCopyRows)
+		var durabilityStatement string
+		if exec.profile.PriorBackupReducedDurability && !t.opts.deferred && strategy == backupStrategyStatement {
+			if reduced, ok := setBackupReducedDurability(t.instance.Engine, t.backupDatabaseName, statement); ok {
+				durabilityStatement, err = getBackupDurabilityStatement(t.instance.Engine, t.backupDatabaseName, statement.TargetTableName)
+				if err != nil {
+					return items, nil, err
+				}
+				statement.Statement = reduced
+			}
+		}
+		itemStrategy := backupStrategyDeferred
+		var rowCount *int64
+		var afterImageTable *storepb.PriorBackupDetail_Item_Table
+		var archivePartition *storepb.PriorBackupDetail_Item_ArchivePartition
+		var commentSkipReason string
+		if t.opts.deferred {
+			strategyReason = "the backup statement is deferred to the data update transaction to be consistent with it"
+			if t.opts.lockRows {
+				strategyReason = "the backup statement is deferred to the data update transaction to lock the backed up rows"
+			}
+			immediateStatements, statements := splitDeferredBackupStatements(t.instance.Engine, statement.Statement, commentStatement)
+			for _, immediateStatement := range immediateStatements {
+				if _, err := t.driver.Execute(driverCtx, immediateStatement, db.ExecuteOptions{}); err != nil {
+					return items, nil, errors.Wrapf(err, "failed to prepare deferred backup table %q", statement.TargetTableName)
+				}
+			}
+			deferredStatements = append(deferredStatements, statements...)
+		} else {
+			// The maintenance cannot run in the snapshot transaction, so it runs by the source database driver.
+			maintainBackupSourceTable(driverCtx, t.driver, t.instance.Engine, exec.profile.PriorBackupMaintenance, t.database.DatabaseName, statement)
+			err = exec.injectFailure(failurePointBackupStatement)
+			if err == nil {
+				var backedUpRows int64
+				var fallbackReason string
+				itemStrategy, fallbackReason, backedUpRows, err = executeBackupStatement(driverCtx, t.executor, t.instance.Engine, t.backupDatabaseName, statement, strategy, t.opts)
+				rowCount = &backedUpRows
+				if fallbackReason != "" {
+					strategyReason = fallbackReason
+				}
+			}
+			if err != nil {
+				if driverCtx.Err() == nil {
+					breaker.RecordFailure(t.targetDatabaseName, exec.now())
+				}
+				// Keep track of the backup tables created before the failure.
+				return items, nil, err
+			}
+			if durabilityStatement != "" {
+				// The backup table must survive the crashes before the data update runs.
+				durabilityStatement, executeOptions := withBackupSessionRole(t.instance.Engine, t.opts.sessionRole, durabilityStatement, db.ExecuteOptions{})
+				if _, err := t.executor.Execute(driverCtx, durabilityStatement, executeOptions); err != nil {
+					breaker.RecordFailure(t.targetDatabaseName, exec.now())
+					// Keep track of the backup table created before the failure.
+					return append(items, getCreatedBackupItem(t.instance, t.sourceDatabaseName, t.targetDatabaseName, statement, exec.getBackupImmutableUntil(t.instance.Engine))), nil, errors.Wrapf(err, "failed to make backup table %q durable", statement.TargetTableName)
+				}
+			}
+			if encryptionStatement != "" {
+				encryptionStatement, executeOptions := withBackupSessionRole(t.instance.Engine, t.opts.sessionRole, encryptionStatement, db.ExecuteOptions{})
+				if _, err := t.executor.Execute(driverCtx, encryptionStatement, executeOptions); err != nil {
+					breaker.RecordFailure(t.targetDatabaseName, exec.now())
+					// Keep track of the backup table created before the failure.
+					return append(items, getCreatedBackupItem(t.instance, t.sourceDatabaseName, t.targetDatabaseName, statement, exec.getBackupImmutableUntil(t.instance.Engine))), nil, errors.Wrapf(err, "failed to encrypt backup table %q", statement.TargetTableName)
+				}
+			}
+			if commentStatement != "" {
+				// The ALTER TABLE of the tag conflicts with the online DDL tools operating on the backup table.
+				tool, err := waitForOnlineDDL(driverCtx, t.driver.GetDB(), t.instance.Engine, t.backupDatabaseName, statement.TargetTableName, maximumOnlineDDLChecks, onlineDDLCheckInterval)
+				if err != nil {
+					slog.Warn("failed to check online DDL on backup table", slog.String("backupTable", statement.TargetTableName), log.BBError(err))
+				} else if tool != "" {
+					commentSkipReason = fmt.Sprintf("%s is in progress on the backup table", tool)
+					commentStatement = ""
+					slog.Warn("skip tagging backup table", slog.String("backupTable", statement.TargetTableName), slog.String("reason", commentSkipReason))
+				}
+			}
+			if commentStatement != "" {
+				commentDriver := t.executor
+				if t.instance.Engine == storepb.Engine_MSSQL {
+					commentDriver = t.backupDriver
+				}
+				// The backup table is owned by the session role, which is required to comment on it.
+				commentStatement, executeOptions := withBackupSessionRole(t.instance.Engine, t.opts.sessionRole, commentStatement, db.ExecuteOptions{})
+				err := exec.injectFailure(failurePointComment)
+				if err == nil {
+					_, err = commentDriver.Execute(driverCtx, commentStatement, executeOptions)
+				}
+				if err != nil {
+					breaker.RecordFailure(t.targetDatabaseName, exec.now())
+					// Keep track of the backup table created before the failure.
+					return append(items, getCreatedBackupItem(t.instance, t.sourceDatabaseName, t.targetDatabaseName, statement, exec.getBackupImmutableUntil(t.instance.Engine))), nil, errors.Wrap(err, "failed to set table comment")
+				}
+			}
+			if exec.profile.PriorBackupAnalyzeTable {
+				analyzeStatement, err := GetBackupAnalyzeStatement(t.instance.Engine, t.backupDatabaseName, statement.TargetSchema, statement.TargetTableName)
+				if err != nil {
+					slog.Warn("failed to analyze backup table", slog.String("backupTable", statement.TargetTableName), log.BBError(err))
+				} else if analyzeStatement != "" {
+					analyzeDriver := t.executor
+					if t.instance.Engine == storepb.Engine_MSSQL {
+						analyzeDriver = t.backupDriver
+					}
+					// The statistics only speed up the queries on the backup table, so the backup doesn't fail without them.
+					analyzeStatement, executeOptions := withBackupSessionRole(t.instance.Engine, t.opts.sessionRole, analyzeStatement, db.ExecuteOptions{})
+					if _, err := analyzeDriver.Execute(driverCtx, analyzeStatement, executeOptions); err != nil {
+						slog.Warn("failed to analyze backup table", slog.String("backupTable", statement.TargetTableName), log.BBError(err))
+					}
+				}
+			}
+			// The after-image is only for reviewing the changes, so the backup doesn't fail without it.
+			afterImageName, afterImageStatement, err := GetAfterImageStatement(t.instance.Engine, t.backupDatabaseName, statement)
+			if err != nil {
+				slog.Warn("failed to create after-image table", slog.String("backupTable", statement.TargetTableName), log.BBError(err))
+			} else if afterImageStatement != "" {
+				afterImageStatement, executeOptions := withBackupSessionRole(t.instance.Engine, t.opts.sessionRole, afterImageStatement, db.ExecuteOptions{})
+				if _, err := t.executor.Execute(driverCtx, afterImageStatement, executeOptions); err != nil {
+					slog.Warn("failed to create after-image table", slog.String("backupTable", statement.TargetTableName), log.BBError(err))
+				} else {
+					afterImageTable = &storepb.PriorBackupDetail_Item_Table{
+						Database: t.targetDatabaseName,
+						Table:    afterImageName,
+					}
+				}
+			}
+			if exec.profile.PriorBackupArchive && supportBackupArchive(t.instance.Engine) {
+				// The archive is only for the long-term retention, so the backup doesn't fail without it.
+				partition, err := archiveBackupTable(driverCtx, t.executor, t.instance.Engine, t.database.DatabaseName, t.targetDatabaseName, t.backupDatabaseName, statement, t.opts.sessionRole, exec.now())
+				if err != nil {
+					slog.Warn("failed to archive backup table", slog.String("backupTable", statement.TargetTableName), log.BBError(err))
+				} else {
+					archivePartition = partition
+				}
+			}
+		}
+
+		items = append(items, &storepb.PriorBackupDetail_Item{
+			SourceTable: &storepb.PriorBackupDetail_Item_Table{
+				Database: t.sourceDatabaseName,
+				Schema:   statement.SourceSchema,
+				Table:    normalizeBackupTableName(t.instance, statement.SourceTableName),
+			},
+			TargetTable: &storepb.PriorBackupDetail_Item_Table{
+				Database: t.targetDatabaseName,
+				Schema:   statement.TargetSchema,
+				Table:    normalizeBackupTableName(t.instance, statement.TargetTableName),
+			},
+			StartPosition:          statement.StartPosition,
+			EndPosition:            statement.EndPosition,
+			StorageEngine:          storageEngine,
+			Tablespace:             tablespace,
+			Lightweight:            lightweight,
+			OwnedSequences:         ownedSequences,
+			Strategy:               itemStrategy.toProto(),
+			StrategyReason:         strategyReason,
+			SurrogateKey:           surrogateKey,
+			ExcludedColumns:        excludedColumns,
+			Range:                  t.ranges[statement.TargetTableName],
+			FullTableReason:        fullTableReason,
+			Snapshot:               getExportedSnapshotID(t.exportedSnapshot),
+			RowCount:               rowCount,
+			Partitions:             getSourceTablePartitions(t.instance.Engine, statement, t.metadata),
+			AfterImageTable:        afterImageTable,
+			CheckConstraints:       findBackupSourceTable(t.instance.Engine, statement, t.metadata).GetCheckConstraints(),
+			TableCommentSkipReason: commentSkipReason,
+			Columns:                getBackupColumns(findBackupSourceTable(t.instance.Engine, statement, t.metadata)),
+			VersionColumn:          getBackupVersionColumn(findBackupSourceTable(t.instance.Engine, statement, t.metadata), exec.profile.PriorBackupVersionColumns),
+			ArchivePartition:       archivePartition,
+			ImmutableUntil:         exec.getBackupImmutableUntil(t.instance.Engine),
+		})
+		slog.Info("backed up table",
+			slog.String("table", statement.SourceTableName),
+			slog.String("backupTable", statement.TargetTableName),
+			slog.String("strategy", itemStrategy.toProto().String()),
+		)
+	}
+	return items, deferredStatements, nil
+}
+
+// getFailedBackupItems returns the items of the backup tables left behind by the failed backup.
+// The backup tables created in the snapshot transaction on Postgres are rolled back with it,
+// while the DDL statements commit implicitly on MySQL, so the backup tables are left behind.
+func getFailedBackupItems(engine storepb.Engine, snapshot *BackupSnapshot, items []*storepb.PriorBackupDetail_Item) []*storepb.PriorBackupDetail_Item {
+	if snapshot != nil && engine == storepb.Engine_POSTGRES {
+		return nil
+	}
+	return items
+}
+
+// createPriorBackupComments records the backup tables except the lightweight ones in the issue comments,
+// one comment for each table or one for all the tables if the comments are consolidated.
+func (exec *DataUpdateExecutor) createPriorBackupComments(ctx context.Context, issue *store.IssueMessage, task *store.TaskMessage, backupDatabaseName string, items []*storepb.PriorBackupDetail_Item) {
+	tables := getPriorBackupCommentTables(items)
+	if len(tables) == 0 {
+		return
+	}
+	comments := [][]*storepb.IssueCommentPayload_TaskPriorBackup_Table{tables}
+	if !exec.profile.PriorBackupConsolidateComments {
+		comments = nil
+		for _, table := range tables {
+			comments = append(comments, []*storepb.IssueCommentPayload_TaskPriorBackup_Table{table})
+		}
+	}
+	for _, tables := range comments {
+		if _, err := exec.store.CreateIssueComment(ctx, getPriorBackupComment(issue, task, backupDatabaseName, tables), api.SystemBotID); err != nil {
+			slog.Warn("failed to create issue comment", "task", task.ID, log.BBError(err))
+		}
+	}
+}
+
+// getPriorBackupCommentTables returns the backup tables listed in the issue comments. The lightweight backup tables are not listed.
+func getPriorBackupCommentTables(items []*storepb.PriorBackupDetail_Item) []*storepb.IssueCommentPayload_TaskPriorBackup_Table {
+	var tables []*storepb.IssueCommentPayload_TaskPriorBackup_Table
+	for _, item := range items {
+		if item.GetLightweight() {
+			continue
+		}
+		tables = append(tables, &storepb.IssueCommentPayload_TaskPriorBackup_Table{
+			Schema: item.GetTargetTable().GetSchema(),
+			Table:  item.GetTargetTable().GetTable(),
+		})
+	}
+	return tables
+}
+
+// getCreatedBackupItem returns the item of the backup table created by the statement before the backup failed,
+// so that the backup table is tracked and cleaned up like the others.
+func getCreatedBackupItem(instance *store.InstanceMessage, sourceDatabaseName, targetDatabaseName string, statement base.BackupStatement, immutableUntil *timestamppb.Timestamp) *storepb.PriorBackupDetail_Item {
+	return &storepb.PriorBackupDetail_Item{
+		SourceTable: &storepb.PriorBackupDetail_Item_Table{
+			Database: sourceDatabaseName,
+			Schema:   statement.SourceSchema,
+			Table:    normalizeBackupTableName(instance, statement.SourceTableName),
+		},
+		TargetTable: &storepb.PriorBackupDetail_Item_Table{
+			Database: targetDatabaseName,
+			Schema:   statement.TargetSchema,
+			Table:    normalizeBackupTableName(instance, statement.TargetTableName),
+		},
+		StartPosition:  statement.StartPosition,
+		EndPosition:    statement.EndPosition,
+		ImmutableUntil: immutableUntil,
+	}
+}
+
+// activeBackupsGauge is the number of the prior backups in progress by the engine and the instance,
+// exposed by the Prometheus metrics endpoint to detect the pile-ups.
+var activeBackupsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "bytebase_prior_backup_active",
+	Help: "The number of the prior backups in progress.",
+}, []string{"engine", "instance"})
+
+// trackActiveBackup increments the active backups gauge of the instance and returns the function decrementing it.
+func trackActiveBackup(engine storepb.Engine, instanceID string) func() {
+	gauge := activeBackupsGauge.WithLabelValues(engine.String(), instanceID)
+	gauge.Inc()
+	return gauge.Dec
+}
+
+// getBackupDriver returns the driver of the database for the prior backups, connecting to the node tagged for the backups if configured.
+func (exec *DataUpdateExecutor) getBackupDriver(ctx context.Context, instance *store.InstanceMessage, database *store.DatabaseMessage) (db.Driver, error) {
+	if exec.profile.PriorBackupNodeTag == "" {
+		return exec.dbFactory.GetAdminDatabaseDriver(ctx, instance, database, db.ConnectionContext{})
+	}
+	return exec.dbFactory.GetNodeTagDatabaseDriver(ctx, instance, database, exec.profile.PriorBackupNodeTag, db.ConnectionContext{})
+}
+
+// setBackupRestoreStatements sets the statements restoring the backed up rows of the items into the source tables by the DML
+// backed up by each item. The items are left without the restore statements if they are not supported for the engine or the DML.
+func setBackupRestoreStatements(ctx context.Context, engine storepb.Engine, rCtx base.RestoreContext, statement, sourceDatabase, backupDatabase string, items []*storepb.PriorBackupDetail_Item) {
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_POSTGRES:
+	default:
+		return
+	}
+	list, err := base.SplitMultiSQL(engine, statement)
+	if err != nil {
+		slog.Warn("failed to split statement for restore statements", log.BBError(err))
+		return
+	}
+	for _, item := range items {
+		dml := getBackupItemStatement(list, item.GetStartPosition())
+		if dml == "" {
+			continue
+		}
+		restoreStatement, err := base.GenerateRestoreSQL(ctx, engine, getItemRestoreContext(rCtx, item), dml, backupDatabase, item.GetTargetTable().GetTable(), sourceDatabase, item.GetSourceTable().GetTable())
+		if err != nil {
+			slog.Warn("failed to generate restore statement", slog.String("backupTable", item.GetTargetTable().GetTable()), log.BBError(err))
+			continue
+		}
+		item.RestoreStatement = restoreStatement
+	}
+}
+
+// getItemRestoreContext returns the restore context of the item with its columns and version column at backup time.
+// The whole table backed up for the uncertain rows has the rows left untouched by the DML, which conflict with the current rows
+// on restore. They are overwritten with their values at backup time by default, which are the values before the DML.
+func getItemRestoreContext(rCtx base.RestoreContext, item *storepb.PriorBackupDetail_Item) base.RestoreContext {
+	itemCtx := rCtx
+	itemCtx.BackupColumns = getRestoreColumns(item)
+	itemCtx.VersionColumn = item.GetVersionColumn()
+	if item.GetFullTableReason() != "" && itemCtx.ConflictStrategy == base.RestoreConflictDefault {
+		itemCtx.ConflictStrategy = base.RestoreConflictOverwrite
+	}
+	return itemCtx
+}
+
+// getBackupColumns returns the columns of the source table with their defaults and collations at backup time,
+// so that the restores don't depend on the defaults changed afterwards.
+func getBackupColumns(table *storepb.TableMetadata) []*storepb.PriorBackupDetail_Item_Column {
+	var columns []*storepb.PriorBackupDetail_Item_Column
+	for _, column := range table.GetColumns() {
+		c := &storepb.PriorBackupDetail_Item_Column{Name: column.GetName(), Collation: column.GetCollation()}
+		switch {
+		case column.GetDefaultExpression() != "":
+			c.Default = column.GetDefaultExpression()
+		case column.GetDefault() != nil:
+			c.Default = fmt.Sprintf("'%s'", strings.ReplaceAll(column.GetDefault().GetValue(), "'", "''"))
+		case column.GetDefaultNull():
+			c.Default = "NULL"
+		}
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// getBackupVersionColumn returns the first of the version columns in the source table, or empty if it has none of them.
+// The names are matched case-insensitively since the identifiers are folded differently by the engines.
+func getBackupVersionColumn(table *storepb.TableMetadata, versionColumns []string) string {
+	for _, name := range versionColumns {
+		for _, column := range table.GetColumns() {
+			if strings.EqualFold(column.GetName(), name) {
+				return column.GetName()
+			}
+		}
+	}
+	return ""
+}
+
+// getRestoreColumns returns the columns of the source table at backup time for restoring the item.
+func getRestoreColumns(item *storepb.PriorBackupDetail_Item) []base.RestoreColumn {
+	var columns []base.RestoreColumn
+	for _, column := range item.GetColumns() {
+		columns = append(columns, base.RestoreColumn{
+			Name:      column.GetName(),
+			Default:   column.GetDefault(),
+			Excluded:  slices.Contains(item.GetExcludedColumns(), column.GetName()),
+			Collation: column.GetCollation(),
+		})
+	}
+	return columns
+}
+
+// getBackupItemStatement returns the statement of the split statements at the position, or empty if there is none.
+// The line of the position is one-based, and the column is zero-based.
+func getBackupItemStatement(list []base.SingleSQL, position *storepb.Position) string {
+	if position == nil {
+		return ""
+	}
+	line, column := int(position.GetLine())-1, int(position.GetColumn())
+	for _, singleSQL := range list {
+		if singleSQL.Empty {
+			continue
+		}
+		if line < singleSQL.FirstStatementLine || (line == singleSQL.FirstStatementLine && column < singleSQL.FirstStatementColumn) {
+			continue
+		}
+		if line > singleSQL.LastLine || (line == singleSQL.LastLine && column > singleSQL.LastColumn) {
+			continue
+		}
+		return singleSQL.Text
+	}
+	return ""
+}
+
+const maximumBackupSchemaSyncAttempts = 5
+
+// syncBackupDatabaseSchema syncs the schema of the database holding the backup tables.
+// With the grace delay in the profile, it waits before syncing and retries until the backup tables are visible,
+// because the catalog of the clustered engines may not have propagated the new tables right after they are created.
+func (exec *DataUpdateExecutor) syncBackupDatabaseSchema(ctx context.Context, database *store.DatabaseMessage, items []*storepb.PriorBackupDetail_Item) error {
+	sync := func() error {
+		if err := exec.injectFailure(failurePointSync); err != nil {
+			return err
+		}
+		return exec.schemaSyncer.SyncDatabaseSchema(ctx, database, false /* force */)
+	}
+	visible := func() (bool, error) {
+		dbSchema, err := exec.store.GetDBSchema(ctx, database.UID)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get database schema")
+		}
+		if dbSchema == nil {
+			return false, nil
+		}
+		return hasBackupTables(dbSchema.GetMetadata(), items), nil
+	}
+	return syncUntilVisible(ctx, exec.profile.PriorBackupSchemaSyncDelay, maximumBackupSchemaSyncAttempts, sync, visible)
+}
+
+// syncUntilVisible syncs once if the delay is zero.
+// Otherwise, it waits for the delay before each sync, until the synced schema is visible or the attempts are exhausted.
+func syncUntilVisible(ctx context.Context, delay time.Duration, attempts int, sync func() error, visible func() (bool, error)) error {
+	if delay <= 0 {
+		return sync()
+	}
+	for i := 0; i < attempts; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if err := sync(); err != nil {
+			return err
+		}
+		ok, err := visible()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return errors.Errorf("backup tables are not visible after %d schema syncs", attempts)
+}
+
+// hasBackupTables returns whether all the backup tables are in the metadata.
+// The backup table names are unique by the prefix, so they are looked up in all schemas.
+func hasBackupTables(metadata *storepb.DatabaseSchemaMetadata, items []*storepb.PriorBackupDetail_Item) bool {
+	tables := make(map[string]bool)
+	for _, schema := range metadata.GetSchemas() {
+		for _, table := range schema.GetTables() {
+			tables[table.GetName()] = true
+		}
+	}
+	for _, item := range items {
+		if !tables[item.GetTargetTable().GetTable()] {
+			return false
+		}
+	}
+	return true
+}
+
+// backupStrategy is how the rows are copied into the backup tables.
+type backupStrategy int
+
+const (
+	// backupStrategyStatement executes the backup statements.
+	backupStrategyStatement backupStrategy = iota
+	// backupStrategyBulkCopy streams the rows into the backup tables by the bulk copy of the engine.
+	backupStrategyBulkCopy
+	// backupStrategyDeferred defers the backup statements to the data update transaction.
+	backupStrategyDeferred
+)
+
+// toProto returns the strategy recorded in the prior backup detail.
+func (s backupStrategy) toProto() storepb.PriorBackupDetail_Item_Strategy {
+	switch s {
+	case backupStrategyStatement:
+		return storepb.PriorBackupDetail_Item_STATEMENT
+	case backupStrategyBulkCopy:
+		return storepb.PriorBackupDetail_Item_BULK_COPY
+	case backupStrategyDeferred:
+		return storepb.PriorBackupDetail_Item_DEFERRED
+	default:
+		return storepb.PriorBackupDetail_Item_STRATEGY_UNSPECIFIED
+	}
+}
+
+// getBackupStrategy returns the cheapest viable strategy copying the rows of a source table into the backup table, and the reason.
+// The bulk copy is preferred for the cross-region backup databases because the round trips are expensive, and for the large tables
+// estimated to have at least bulkCopyRows rows, on the engines supporting it. Zero bulkCopyRows disables the selection by size.
+func getBackupStrategy(engine storepb.Engine, bulkCopy bool, crossRegion bool, estimatedRows, bulkCopyRows int64) (backupStrategy, string) {
+	if engine != storepb.Engine_POSTGRES {
+		return backupStrategyStatement, fmt.Sprintf("the bulk copy is not supported on %s", engine)
+	}
+	switch {
+	case bulkCopy:
+		return backupStrategyBulkCopy, "the bulk copy is configured"
+	case crossRegion:
+		return backupStrategyBulkCopy, "the backup database is in a different region"
+	case bulkCopyRows > 0 && estimatedRows >= bulkCopyRows:
+		return backupStrategyBulkCopy, fmt.Sprintf("the source table is estimated to have %d rows, at least %d", estimatedRows, bulkCopyRows)
+	case bulkCopyRows > 0:
+		return backupStrategyStatement, fmt.Sprintf("the source table is estimated to have %d rows, fewer than %d", estimatedRows, bulkCopyRows)
+	default:
+		return backupStrategyStatement, "the bulk copy is not configured"
+	}
+}
+
+// isCrossRegion returns whether the instances are known to be in different regions.
+func isCrossRegion(source, backup *store.InstanceMessage) bool {
+	sourceRegion, backupRegion := source.Options.GetRegion(), backup.Options.GetRegion()
+	return sourceRegion != "" && backupRegion != "" && sourceRegion != backupRegion
+}
+
+// backupDatabaseSnapshot backs up the task database by the native snapshot of the whole database if the source tables are estimated
+// to have at least the configured number of rows. It returns nil if the snapshot isn't selected, so that the rows are copied instead.
+func (exec *DataUpdateExecutor) backupDatabaseSnapshot(ctx context.Context, driverCtx context.Context, statement string, instance *store.InstanceMessage, database *store.DatabaseMessage, target *backupTarget) (*storepb.PriorBackupDetail_DatabaseSnapshot, error) {
+	_, backupDatabaseName, err := common.GetInstanceDatabaseID(target.target)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse backup database")
+	}
+	tc := base.TransformContext{
+		InstanceID:              instance.ResourceID,
+		GetDatabaseMetadataFunc: BuildGetDatabaseMetadataFunc(exec.store),
+	}
+	statements, err := transformDMLToBackup(ctx, instance.Engine, tc, statement, database.DatabaseName, backupDatabaseName, "_"+exec.now().Format("20060102150405"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to transform DML to select")
+	}
+	dbSchema, err := exec.store.GetDBSchema(ctx, database.UID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get database schema")
+	}
+	estimatedRows := getBackupRowsEstimate(EstimateBackupImpact(instance.Engine, statements, dbSchema.GetMetadata()))
+	if estimatedRows < exec.profile.PriorBackupDatabaseSnapshotRows {
+		return nil, nil
+	}
+	driver, err := exec.getBackupDriver(driverCtx, instance, database)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get database driver")
+	}
+	defer driver.Close(driverCtx)
+	return CreateBackupDatabaseSnapshot(driverCtx, driver, common.FormatDatabase(instance.ResourceID, database.DatabaseName), database.DatabaseName, estimatedRows, exec.profile.PriorBackupDatabaseSnapshotRows)
+}
+
+// getBackupRowsEstimate returns the total estimated rows of the source tables. The tables without statistics are not counted.
+func getBackupRowsEstimate(estimates []*storepb.IssueCommentPayload_TaskPriorBackupEstimate_Table) int64 {
+	var total int64
+	for _, estimate := range estimates {
+		if estimate.Unknown {
+			continue
+		}
+		total += estimate.Rows
+	}
+	return total
+}
+
+// CreateBackupDatabaseSnapshot creates the native snapshot of the database by the driver if the estimated rows reach snapshotRows.
+// The database is in the format of instances/{instance}/databases/{database}. It returns nil if the driver doesn't support the snapshots.
+func CreateBackupDatabaseSnapshot(ctx context.Context, driver db.Driver, database, databaseName string, estimatedRows, snapshotRows int64) (*storepb.PriorBackupDetail_DatabaseSnapshot, error) {
+	if snapshotRows <= 0 || estimatedRows < snapshotRows {
+		return nil, nil
+	}
+	snapshotter, ok := driver.(db.DatabaseSnapshotter)
+	if !ok {
+		return nil, nil
+	}
+	snapshot, err := snapshotter.CreateDatabaseSnapshot(ctx, databaseName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the snapshot of database %q", database)
+	}
+	return &storepb.PriorBackupDetail_DatabaseSnapshot{
+		Database: database,
+		Snapshot: snapshot,
+		Reason:   fmt.Sprintf("the source tables are estimated to have %d rows, at least %d", estimatedRows, snapshotRows),
+	}, nil
+}
+
+// executeBackupStatement copies the rows of the backup statement into the backup table.
+// It uses the bulk copy of the engine if the strategy prefers it and it's available, otherwise executes the backup statement.
+// It returns the strategy actually used, and the reason if the bulk copy preferred by the strategy is unavailable.
+func executeBackupStatement(ctx context.Context, driver db.Driver, engine storepb.Engine, backupDatabaseName string, statement base.BackupStatement, strategy backupStrategy, opts *backupOptions) (backupStrategy, string, int64, error) {
+	var fallbackReason string
+	if strategy == backupStrategyBulkCopy {
+		var query string
+		var pgDriver *pgdriver.Driver
+		query, pgDriver, fallbackReason = getBulkCopyDriver(driver, engine, backupDatabaseName, statement, opts)
+		if fallbackReason == "" {
+			rowCount, err := pgDriver.CopyQueryToTable(ctx, query, backupDatabaseName, statement.TargetTableName, db.ExecuteOptions{ReadTimeout: opts.readTimeout, WriteTimeout: opts.writeTimeout})
+			if err != nil {
+				return strategy, "", 0, errors.Wrapf(err, "failed to bulk copy backup statement %q", statement.Statement)
+			}
+			return backupStrategyBulkCopy, "", rowCount, nil
+		}
+	}
+	backupStatement, executeOptions := withBackupSessionRole(engine, opts.sessionRole, statement.Statement, db.ExecuteOptions{IsolationLevel: opts.isolationLevel, ResourceGroup: opts.resourceGroup})
+	// The statement reads and writes the rows in one piece, so it's bounded by the sum of the timeouts set. Zero means no timeout.
+	if timeout := opts.readTimeout + opts.writeTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	rowCount, err := driver.Execute(ctx, backupStatement, executeOptions)
+	if err != nil {
+		return backupStrategyStatement, fallbackReason, 0, errors.Wrapf(err, "failed to execute backup statement %q", statement.Statement)
+	}
+	return backupStrategyStatement, fallbackReason, rowCount, nil
+}
+
+// getBulkCopyDriver returns the query and the driver bulk copying the rows of the backup statement, or the reason the bulk copy is unavailable.
+func getBulkCopyDriver(driver db.Driver, engine storepb.Engine, backupDatabaseName string, statement base.BackupStatement, opts *backupOptions) (string, *pgdriver.Driver, string) {
+	if opts.sessionRole != "" {
+		return "", nil, "the bulk copy doesn't switch to the session role"
+	}
+	query, ok := getBulkCopyQuery(engine, backupDatabaseName, statement)
+	if !ok {
+		return "", nil, "the bulk copy doesn't support the backup statement"
+	}
+	switch d := driver.(type) {
+	case *pgdriver.Driver:
+		return query, d, ""
+	case *BackupSnapshot, *ExportedBackupSnapshot:
+		return "", nil, "the bulk copy cannot read the consistent snapshot of the source tables"
+	default:
+		return "", nil, fmt.Sprintf("the bulk copy is not supported by the %s driver", engine)
+	}
+}
+
+// PriorBackupProducer publishes the before-images of the prior backups to a logical replication or CDC sink such as Kafka.
+type PriorBackupProducer interface {
+	// Produce publishes the messages to the topic in order and returns the offset of each message.
+	Produce(ctx context.Context, topic string, messages [][]byte) ([]int64, error)
+}
+
+// sinkBackupSchema is the backup schema of the backup statements transformed for the sink and the object storage export.
+// Only the SELECT queries of the statements are executed, so the schema is never created.
+const sinkBackupSchema = "bbdataarchive"
+
+// beforeImage is the message published to the sink for each affected row.
+type beforeImage struct {
+	// Format: instances/{instance}/databases/{database}
+	Database string         `json:"database"`
+	Schema   string         `json:"schema,omitempty"`
+	Table    string         `json:"table"`
+	Before   map[string]any `json:"before"`
+}
+
+// publishBackupData publishes the before-images of the rows affected by the statement to the sink topic.
+// It returns the published before-images of all the source tables in order.
+func (exec *DataUpdateExecutor) publishBackupData(ctx context.Context, driverCtx context.Context, statement string, instance *store.InstanceMessage, database *store.DatabaseMessage, topic string) ([]*storepb.PriorBackupDetail_Item, []*beforeImage, error) {
+	if exec.producer == nil {
+		return nil, nil, errors.New("prior backup sink producer is not configured")
+	}
+	if instance.Engine != storepb.Engine_POSTGRES {
+		return nil, nil, errors.Errorf("backup sink is not supported for engine %s", instance.Engine)
+	}
+	driver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, database, db.ConnectionContext{})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get database driver")
+	}
+	defer driver.Close(driverCtx)
+
+	tc := base.TransformContext{
+		InstanceID:              instance.ResourceID,
+		GetDatabaseMetadataFunc: BuildGetDatabaseMetadataFunc(exec.store),
+	}
+	statements, err := transformDMLToBackup(ctx, instance.Engine, tc, statement, database.DatabaseName, sinkBackupSchema, "_"+exec.now().Format("20060102150405"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to transform DML to select")
+	}
+	dbSchema, err := exec.store.GetDBSchema(ctx, database.UID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get database schema")
+	}
+	sourceDatabaseName := common.FormatDatabase(database.InstanceID, database.DatabaseName)
+	var items []*storepb.PriorBackupDetail_Item
+	var allImages []*beforeImage
+	for _, statement := range statements {
+		excludedColumns, err := applyNoBackupColumns(instance.Engine, &statement, dbSchema.GetMetadata(), dbSchema.GetConfig())
+		if err != nil {
+			return nil, nil, err
+		}
+		query, ok := getBulkCopyQuery(instance.Engine, sinkBackupSchema, statement)
+		if !ok {
+			return nil, nil, errors.Errorf("failed to get the backup query of statement %q", statement.Statement)
+		}
+		sourceTable := &storepb.PriorBackupDetail_Item_Table{
+			Database: sourceDatabaseName,
+			Schema:   statement.SourceSchema,
+			Table:    statement.SourceTableName,
+		}
+		images, err := queryBeforeImages(driverCtx, driver.GetDB(), query, sourceTable)
+		if err != nil {
+			return nil, nil, err
+		}
+		sink, err := publishBeforeImages(driverCtx, exec.producer, topic, images)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to publish the before-images of table %q", statement.SourceTableName)
+		}
+		items = append(items, &storepb.PriorBackupDetail_Item{
+			SourceTable:     sourceTable,
+			StartPosition:   statement.StartPosition,
+			EndPosition:     statement.EndPosition,
+			Sink:            sink,
+			ExcludedColumns: excludedColumns,
+		})
+		slog.Info("published before-images",
+			slog.String("table", statement.SourceTableName),
+			slog.String("topic", topic),
+			slog.Int("rows", len(images)),
+		)
+		allImages = append(allImages, images...)
+	}
+	return items, allImages, nil
+}
+
+// queryBeforeImages returns the before-images of the rows selected by the backup query.
+func queryBeforeImages(ctx context.Context, sqlDB *sql.DB, query string, table *storepb.PriorBackupDetail_Item_Table) ([]*beforeImage, error) {
+	rows, err := sqlDB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query backup rows %q", query)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get backup columns")
+	}
+	var images []*beforeImage
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, errors.Wrap(err, "failed to scan backup row")
+		}
+		before := make(map[string]any, len(columns))
+		for i, column := range columns {
+			if value, ok := values[i].([]byte); ok {
+				before[column] = string(value)
+				continue
+			}
+			before[column] = values[i]
+		}
+		images = append(images, &beforeImage{
+			Database: table.Database,
+			Schema:   table.Schema,
+			Table:    table.Table,
+			Before:   before,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read backup rows")
+	}
+	return images, nil
+}
+
+// publishBeforeImages publishes the before-images to the topic and returns the sink recording their offsets.
+func publishBeforeImages(ctx context.Context, producer PriorBackupProducer, topic string, images []*beforeImage) (*storepb.PriorBackupDetail_Item_Sink, error) {
+	sink := &storepb.PriorBackupDetail_Item_Sink{
+		Topic:       topic,
+		StartOffset: -1,
+		EndOffset:   -1,
+	}
+	if len(images) == 0 {
+		return sink, nil
+	}
+	var messages [][]byte
+	for _, image := range images {
+		message, err := json.Marshal(image)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal before-image")
+		}
+		messages = append(messages, message)
+	}
+	offsets, err := producer.Produce(ctx, topic, messages)
+	if err != nil {
+		return nil, err
+	}
+	if len(offsets) != len(messages) {
+		return nil, errors.Errorf("expected %d offsets but got %d", len(messages), len(offsets))
+	}
+	sink.StartOffset = offsets[0]
+	sink.EndOffset = offsets[len(offsets)-1]
+	return sink, nil
+}
+
+// putBeforeImages writes the before-images to the object store under the key of the prior backup version, one JSON message per line.
+func putBeforeImages(ctx context.Context, objectStore common.PriorBackupObjectStore, key string, images []*beforeImage) error {
+	var buf bytes.Buffer
+	for _, image := range images {
+		message, err := json.Marshal(image)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal before-image")
+		}
+		buf.Write(message)
+		buf.WriteByte('\n')
+	}
+	if err := objectStore.PutObject(ctx, key, buf.Bytes()); err != nil {
+		return errors.Wrapf(err, "failed to put prior backup %q", key)
+	}
+	return nil
+}
+
+// backupExportFormat is the format of the files of the rows exported to the object store.
+const backupExportFormat = "csv.gz"
+
+// backupExportNull is the value of NULL in the exported CSV files, the same as the text format of Postgres COPY.
+// The values starting with a backslash are escaped by another one, so that a text value never reads as NULL,
+// and the binary values are written as a backslash, x and the hex digits, the same as the hex format of Postgres bytea.
+const backupExportNull = `\N`
+
+// backupExportManifest is the manifest of the rows of a task exported to the object store, listing the file of each source table.
+type backupExportManifest struct {
+	// Format: instances/{instance}/databases/{database}
+	Database string               `json:"database"`
+	Format   string               `json:"format"`
+	Tables   []*backupExportTable `json:"tables"`
+}
+
+// backupExportTable is the file of the rows exported from a source table.
+type backupExportTable struct {
+	Schema  string   `json:"schema,omitempty"`
+	Table   string   `json:"table"`
+	Key     string   `json:"key"`
+	Columns []string `json:"columns"`
+	Rows    int64    `json:"rows"`
+}
+
+// exportBackupData exports the rows affected by the statement to the object store under the prefix instead of the backup tables,
+// one gzip-compressed CSV file for each backup statement, and the manifest listing them. It returns the items and the key of the manifest.
+func (exec *DataUpdateExecutor) exportBackupData(ctx context.Context, driverCtx context.Context, statement string, instance *store.InstanceMessage, database *store.DatabaseMessage, prefix string) ([]*storepb.PriorBackupDetail_Item, string, error) {
+	if exec.objectStore == nil {
+		return nil, "", errors.New("prior backup object store is not configured")
+	}
+	if !common.PriorBackupExportEngines[instance.Engine] {
+		return nil, "", errors.Errorf("backup export is not supported for engine %s", instance.Engine)
+	}
+	driver, err := exec.getBackupDriver(driverCtx, instance, database)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to get database driver")
+	}
+	defer driver.Close(driverCtx)
+
+	tc := base.TransformContext{
+		InstanceID:              instance.ResourceID,
+		GetDatabaseMetadataFunc: BuildGetDatabaseMetadataFunc(exec.store),
+	}
+	statements, err := transformDMLToBackup(ctx, instance.Engine, tc, statement, database.DatabaseName, sinkBackupSchema, "_"+exec.now().Format("20060102150405"))
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to transform DML to select")
+	}
+	dbSchema, err := exec.store.GetDBSchema(ctx, database.UID)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to get database schema")
+	}
+	sourceDatabaseName := common.FormatDatabase(database.InstanceID, database.DatabaseName)
+	manifest := &backupExportManifest{Database: sourceDatabaseName, Format: backupExportFormat}
+	var items []*storepb.PriorBackupDetail_Item
+	for i, statement := range statements {
+		excludedColumns, err := applyNoBackupColumns(instance.Engine, &statement, dbSchema.GetMetadata(), dbSchema.GetConfig())
+		if err != nil {
+			return nil, "", err
+		}
+		query, ok := getBulkCopyQuery(instance.Engine, sinkBackupSchema, statement)
+		if !ok {
+			return nil, "", errors.Errorf("failed to get the backup query of statement %q", statement.Statement)
+		}
+		rows, err := driver.GetDB().QueryContext(driverCtx, query)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to query backup rows %q", query)
+		}
+		binaryColumns, err := getBackupExportBinaryColumns(rows)
+		if err != nil {
+			rows.Close()
+			return nil, "", err
+		}
+		key := fmt.Sprintf("%s%d_%s.%s", prefix, i, statement.SourceTableName, backupExportFormat)
+		columns, rowCount, err := uploadBackupExport(driverCtx, exec.objectStore, key, rows, binaryColumns)
+		rows.Close()
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to export the rows of table %q", statement.SourceTableName)
+		}
+		manifest.Tables = append(manifest.Tables, &backupExportTable{
+			Schema:  statement.SourceSchema,
+			Table:   statement.SourceTableName,
+			Key:     key,
+			Columns: columns,
+			Rows:    rowCount,
+		})
+		items = append(items, &storepb.PriorBackupDetail_Item{
+			SourceTable: &storepb.PriorBackupDetail_Item_Table{
+				Database: sourceDatabaseName,
+				Schema:   statement.SourceSchema,
+				Table:    statement.SourceTableName,
+			},
+			StartPosition:   statement.StartPosition,
+			EndPosition:     statement.EndPosition,
+			ExcludedColumns: excludedColumns,
+			ObjectKey:       key,
+			RowCount:        &rowCount,
+		})
+		slog.Info("exported prior backup rows",
+			slog.String("table", statement.SourceTableName),
+			slog.String("key", key),
+			slog.Int64("rows", rowCount),
+		)
+	}
+	// The manifest is written last, so that the export with the manifest is complete.
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to marshal prior backup export manifest")
+	}
+	manifestKey := prefix + "manifest.json"
+	if err := exec.objectStore.PutObject(driverCtx, manifestKey, data); err != nil {
+		return nil, "", errors.Wrapf(err, "failed to put prior backup export manifest %q", manifestKey)
+	}
+	return items, manifestKey, nil
+}
+
+// backupExportRows is the rows exported to the object store, implemented by *sql.Rows.
+type backupExportRows interface {
+	Columns() ([]string, error)
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+}
+
+// getBackupExportBinaryColumns returns the indexes of the bytea columns of the rows, whose values are exported in hex.
+func getBackupExportBinaryColumns(rows *sql.Rows) (map[int]bool, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get backup column types")
+	}
+	binaryColumns := map[int]bool{}
+	for i, columnType := range columnTypes {
+		if strings.EqualFold(columnType.DatabaseTypeName(), "bytea") {
+			binaryColumns[i] = true
+		}
+	}
+	return binaryColumns, nil
+}
+
+// uploadBackupExport uploads the rows to the object store under the key while they're read, so that the rows of a huge table are never held in memory.
+// It returns the columns and the number of rows.
+func uploadBackupExport(ctx context.Context, objectStore common.PriorBackupObjectStore, key string, rows backupExportRows, binaryColumns map[int]bool) ([]string, int64, error) {
+	pr, pw := io.Pipe()
+	var columns []string
+	var rowCount int64
+	exported := make(chan error, 1)
+	go func() {
+		var err error
+		columns, rowCount, err = writeBackupExport(pw, rows, binaryColumns)
+		// The upload reads to the end of the export, or fails with the error of the export.
+		pw.CloseWithError(err)
+		exported <- err
+	}()
+	uploadErr := objectStore.UploadObject(ctx, key, pr)
+	// Unblock the export if the upload stops reading before the end.
+	pr.Close()
+	exportErr := <-exported
+	if exportErr != nil && !errors.Is(exportErr, io.ErrClosedPipe) {
+		return nil, 0, exportErr
+	}
+	if uploadErr != nil {
+		return nil, 0, errors.Wrapf(uploadErr, "failed to upload prior backup export %q", key)
+	}
+	if exportErr != nil {
+		return nil, 0, exportErr
+	}
+	return columns, rowCount, nil
+}
+
+// writeBackupExport writes the rows as the gzip-compressed CSV with the header of the columns, and returns the columns and the number of rows.
+// NULL is written as \N, the values of the binary columns are written in hex, and the other values are written as text escaped by escapeBackupExportText.
+func writeBackupExport(w io.Writer, rows backupExportRows, binaryColumns map[int]bool) ([]string, int64, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to get backup columns")
+	}
+	gz := gzip.NewWriter(w)
+	writer := csv.NewWriter(gz)
+	if err := writer.Write(columns); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to write CSV header")
+	}
+	var rowCount int64
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, 0, errors.Wrap(err, "failed to scan backup row")
+		}
+		for i, value := range values {
+			switch v := value.(type) {
+			case nil:
+				record[i] = backupExportNull
+			case []byte:
+				if binaryColumns[i] {
+					record[i] = `\x` + hex.EncodeToString(v)
+					continue
+				}
+				record[i] = escapeBackupExportText(string(v))
+			case time.Time:
+				record[i] = v.Format(time.RFC3339Nano)
+			default:
+				record[i] = escapeBackupExportText(fmt.Sprint(v))
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, 0, errors.Wrap(err, "failed to write CSV record")
+		}
+		rowCount++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to read backup rows")
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to flush CSV")
+	}
+	if err := gz.Close(); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to close gzip writer")
+	}
+	return columns, rowCount, nil
+}
+
+// escapeBackupExportText escapes the text value starting with a backslash by another one, so that it's never read as NULL or a binary value.
+func escapeBackupExportText(value string) string {
+	if strings.HasPrefix(value, `\`) {
+		return `\` + value
+	}
+	return value
+}
+
+// decodeBackupExportValue decodes the value written by writeBackupExport, returning nil for NULL and []byte for the binary value.
+func decodeBackupExportValue(value string) (any, error) {
+	switch {
+	case value == backupExportNull:
+		return nil, nil
+	case strings.HasPrefix(value, `\\`):
+		return value[1:], nil
+	case strings.HasPrefix(value, `\x`):
+		data, err := hex.DecodeString(value[2:])
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode binary value")
+		}
+		return data, nil
+	case strings.HasPrefix(value, `\`):
+		return nil, errors.Errorf("invalid escaped value %q", value)
+	default:
+		return value, nil
+	}
+}
+
+// backupExportStageRows is the number of the exported rows inserted into the staging table by a statement.
+const backupExportStageRows = 1000
+
+// backupExportExecer executes the statements staging the exported rows, implemented by *sql.DB.
+type backupExportExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// stageBackupExports loads the rows exported to the object store into the staging tables in the backup schema of the source database,
+// so that the data update is rolled back from them like from the prior backup tables. It returns the detail with the exported items
+// backed up into the staging tables, and the statements dropping the staging tables created, even on failures.
+// The staging tables are named by the task run and the staging id, which tells apart the concurrent rollbacks of the task run.
+func stageBackupExports(ctx context.Context, execer backupExportExecer, objectStore common.PriorBackupObjectStore, engine storepb.Engine, taskRunUID int, stagingID string, detail *storepb.PriorBackupDetail) (*storepb.PriorBackupDetail, []string, error) {
+	if detail.GetExportManifestKey() == "" {
+		return detail, nil, nil
+	}
+	if objectStore == nil {
+		return nil, nil, errors.New("prior backup object store is not configured")
+	}
+	if !common.PriorBackupExportEngines[engine] {
+		return nil, nil, errors.Errorf("backup export is not supported for engine %s", engine)
+	}
+	schema, err := base.QuoteIdentifier(engine, sinkBackupSchema)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := execer.ExecContext(ctx, fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %s`, schema)); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to create backup schema %q", sinkBackupSchema)
+	}
+	staged, ok := proto.Clone(detail).(*storepb.PriorBackupDetail)
+	if !ok {
+		return nil, nil, errors.New("failed to clone prior backup detail")
+	}
+	var dropStatements []string
+	for i, item := range staged.Items {
+		if item.GetObjectKey() == "" || item.GetTargetTable() != nil {
+			continue
+		}
+		instanceID, _, err := common.GetInstanceDatabaseID(item.GetSourceTable().GetDatabase())
+		if err != nil {
+			return nil, dropStatements, errors.Wrapf(err, "failed to parse source database %q", item.GetSourceTable().GetDatabase())
+		}
+		// The source table name is truncated to fit the identifier length like the backup table names, while the index keeps the staging tables apart.
+		prefix := fmt.Sprintf("_rollback_%d_%s_%d_", taskRunUID, stagingID, i)
+		sourceTable, _ := common.TruncateString(item.GetSourceTable().GetTable(), maximumPostgresIdentifierLength-len(prefix))
+		table := prefix + sourceTable
+		stagingTable, err := base.QuoteQualifiedName(engine, sinkBackupSchema, table)
+		if err != nil {
+			return nil, dropStatements, err
+		}
+		dropStatements = append(dropStatements, fmt.Sprintf(`DROP TABLE IF EXISTS %s`, stagingTable))
+		if err := stageBackupExport(ctx, execer, objectStore, engine, item, stagingTable); err != nil {
+			return nil, dropStatements, errors.Wrapf(err, "failed to stage prior backup export %q", item.GetObjectKey())
+		}
+		item.TargetTable = &storepb.PriorBackupDetail_Item_Table{
+			Database: common.FormatDatabase(instanceID, sinkBackupSchema),
+			Table:    table,
+		}
+	}
+	return staged, dropStatements, nil
+}
+
+// stageBackupExport creates the staging table of the exported columns of the source table, and inserts the exported rows in batches
+// while they're downloaded. The text values are converted to the column types by json_populate_recordset.
+func stageBackupExport(ctx context.Context, execer backupExportExecer, objectStore common.PriorBackupObjectStore, engine storepb.Engine, item *storepb.PriorBackupDetail_Item, stagingTable string) error {
+	body, err := objectStore.DownloadObject(ctx, item.GetObjectKey())
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	reader, err := newBackupExportReader(body)
+	if err != nil {
+		return err
+	}
+	schema := item.GetSourceTable().GetSchema()
+	if schema == "" {
+		schema = "public"
+	}
+	var columns []string
+	for _, column := range reader.columns {
+		quoted, err := base.QuoteIdentifier(engine, column)
+		if err != nil {
+			return err
+		}
+		columns = append(columns, quoted)
+	}
+	sourceTable, err := base.QuoteQualifiedName(engine, schema, item.GetSourceTable().GetTable())
+	if err != nil {
+		return err
+	}
+	createStatement := fmt.Sprintf(`CREATE TABLE %s AS SELECT %s FROM %s WITH NO DATA`, stagingTable, strings.Join(columns, ", "), sourceTable)
+	if _, err := execer.ExecContext(ctx, createStatement); err != nil {
+		return errors.Wrapf(err, "failed to create staging table %s", stagingTable)
+	}
+	insertStatement := fmt.Sprintf(`INSERT INTO %s SELECT * FROM json_populate_recordset(NULL::%s, $1::json)`, stagingTable, stagingTable)
+	for {
+		batch, err := reader.nextBatch(backupExportStageRows)
+		if err != nil {
+			return err
+		}
+		if batch == nil {
+			return nil
+		}
+		if _, err := execer.ExecContext(ctx, insertStatement, string(batch)); err != nil {
+			return errors.Wrapf(err, "failed to insert into staging table %s", stagingTable)
+		}
+	}
+}
+
+// backupExportReader reads the rows written by writeBackupExport.
+type backupExportReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newBackupExportReader(r io.Reader) (*backupExportReader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create gzip reader")
+	}
+	reader := csv.NewReader(gz)
+	columns, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CSV header")
+	}
+	return &backupExportReader{reader: reader, columns: columns}, nil
+}
+
+// nextBatch returns the next rows up to the limit as the JSON array of the objects by the columns, or nil after the last row.
+// NULL is read as null, and the binary values are passed in the hex format of the bytea input, which json_populate_recordset converts to bytea.
+func (r *backupExportReader) nextBatch(limit int) ([]byte, error) {
+	var rows []map[string]any
+	for len(rows) < limit {
+		record, err := r.reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CSV record")
+		}
+		row := make(map[string]any, len(r.columns))
+		for i, column := range r.columns {
+			value, err := decodeBackupExportValue(record[i])
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read column %q", column)
+			}
+			if data, ok := value.([]byte); ok {
+				value = `\x` + hex.EncodeToString(data)
+			}
+			row[column] = value
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal rows")
+	}
+	return data, nil
+}
+
+// supportBackupSnapshot returns whether the backup statements can read the source tables in one consistent snapshot on the engine.
+func supportBackupSnapshot(engine storepb.Engine) bool {
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_POSTGRES:
+		return true
+	default:
+		return false
+	}
+}
+
+// BackupSnapshot is the driver executing the backup statements in one transaction reading a consistent snapshot of the source tables,
+// e.g. a REPEATABLE READ transaction on Postgres and a transaction WITH CONSISTENT SNAPSHOT on MySQL.
+// The other driver methods are served by the underlying driver.
+type BackupSnapshot struct {
+	db.Driver
+
+	engine storepb.Engine
+	conn   *sql.Conn
+}
+
+// BeginBackupSnapshot opens the consistent snapshot on a dedicated connection of the driver.
+func BeginBackupSnapshot(ctx context.Context, driver db.Driver, engine storepb.Engine) (*BackupSnapshot, error) {
+	var begins []string
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		begins = []string{"BEGIN ISOLATION LEVEL REPEATABLE READ"}
+	case storepb.Engine_MYSQL:
+		begins = []string{"SET TRANSACTION ISOLATION LEVEL REPEATABLE READ", "START TRANSACTION WITH CONSISTENT SNAPSHOT"}
+	default:
+		return nil, errors.Errorf("consistent snapshot backup is not supported for engine %s", engine)
+	}
+	conn, err := driver.GetDB().Conn(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get connection for the backup snapshot")
+	}
+	for _, begin := range begins {
+		if _, err := conn.ExecContext(ctx, begin); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "failed to begin the backup snapshot")
+		}
+	}
+	return &BackupSnapshot{Driver: driver, engine: engine, conn: conn}, nil
+}
+
+// Execute executes the statement in the snapshot transaction after the prelude statements.
+// The DDL statements commit the transaction implicitly on MySQL, so they are executed by the underlying driver instead.
+func (s *BackupSnapshot) Execute(ctx context.Context, statement string, opts db.ExecuteOptions) (int64, error) {
+	if s.conn == nil {
+		return 0, errors.New("the backup snapshot is closed")
+	}
+	if s.engine != storepb.Engine_MYSQL {
+		for _, prelude := range opts.PreludeStatements {
+			if _, err := s.conn.ExecContext(ctx, prelude); err != nil {
+				return 0, errors.Wrapf(err, "failed to execute prelude statement %q", prelude)
+			}
+		}
+		result, err := s.conn.ExecContext(ctx, statement)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+	var rowsAffected int64
+	for _, part := range strings.Split(statement, ";\n") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.HasPrefix(strings.ToUpper(part), "INSERT ") {
+			if _, err := s.Driver.Execute(ctx, part, opts); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		result, err := s.conn.ExecContext(ctx, part)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		rowsAffected += affected
+	}
+	return rowsAffected, nil
+}
+
+// Commit commits the snapshot transaction and releases the connection.
+func (s *BackupSnapshot) Commit() error {
+	if s.conn == nil {
+		return errors.New("the backup snapshot is closed")
+	}
+	defer s.release()
+	// The snapshot must be committed even if the task is canceled after the backup statements succeeded.
+	if _, err := s.conn.ExecContext(context.Background(), "COMMIT"); err != nil {
+		return errors.Wrap(err, "failed to commit the backup snapshot")
+	}
+	return nil
+}
+
+// Rollback rolls back the snapshot transaction and releases the connection. It's a no-op after Commit.
+func (s *BackupSnapshot) Rollback() {
+	if s.conn == nil {
+		return
+	}
+	defer s.release()
+	if _, err := s.conn.ExecContext(context.Background(), "ROLLBACK"); err != nil {
+		slog.Warn("failed to roll back the backup snapshot", log.BBError(err))
+	}
+}
+
+func (s *BackupSnapshot) release() {
+	if err := s.conn.Close(); err != nil {
+		slog.Warn("failed to close the backup snapshot connection", log.BBError(err))
+	}
+	s.conn = nil
+}
+
+// getExportedSnapshotID returns the identifier of the exported snapshot, or empty if the snapshot is nil.
+func getExportedSnapshotID(s *ExportedBackupSnapshot) string {
+	if s == nil {
+		return ""
+	}
+	return s.ID
+}
+
+// ExportedBackupSnapshot is the driver executing each backup statement in its own transaction importing a snapshot
+// exported by pg_export_snapshot on Postgres, so that the backup statements read a consistent snapshot of the source tables
+// while the locks on the source tables are released after each statement.
+// The exporting transaction holds no locks and is kept open until Close, so that the snapshot can be imported.
+// The other driver methods are served by the underlying driver.
+type ExportedBackupSnapshot struct {
+	db.Driver
+
+	// ID is the identifier of the exported snapshot, e.g. 00000003-0000001B-1.
+	ID   string
+	conn *sql.Conn
+}
+
+// ExportBackupSnapshot exports a consistent snapshot from a REPEATABLE READ transaction on a dedicated connection of the driver.
+func ExportBackupSnapshot(ctx context.Context, driver db.Driver, engine storepb.Engine) (*ExportedBackupSnapshot, error) {
+	if engine != storepb.Engine_POSTGRES {
+		return nil, errors.Errorf("exported snapshot backup is not supported for engine %s", engine)
+	}
+	conn, err := driver.GetDB().Conn(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get connection for the exported backup snapshot")
+	}
+	if _, err := conn.ExecContext(ctx, "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to begin the exported backup snapshot")
+	}
+	var id string
+	if err := conn.QueryRowContext(ctx, "SELECT pg_export_snapshot()").Scan(&id); err != nil {
+		if _, err := conn.ExecContext(context.Background(), "ROLLBACK"); err != nil {
+			slog.Warn("failed to roll back the exported backup snapshot", log.BBError(err))
+		}
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to export the backup snapshot")
+	}
+	return &ExportedBackupSnapshot{Driver: driver, ID: id, conn: conn}, nil
+}
+
+// Execute executes the statement after the prelude statements in a transaction importing the exported snapshot.
+func (s *ExportedBackupSnapshot) Execute(ctx context.Context, statement string, opts db.ExecuteOptions) (int64, error) {
+	if s.conn == nil {
+		return 0, errors.New("the exported backup snapshot is closed")
+	}
+	tx, err := s.Driver.GetDB().BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+	// SET TRANSACTION SNAPSHOT must be the first statement of the transaction.
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", s.ID)); err != nil {
+		return 0, errors.Wrapf(err, "failed to import the backup snapshot %q", s.ID)
+	}
+	for _, prelude := range opts.PreludeStatements {
+		if _, err := tx.ExecContext(ctx, prelude); err != nil {
+			return 0, errors.Wrapf(err, "failed to execute prelude statement %q", prelude)
+		}
+	}
+	result, err := tx.ExecContext(ctx, statement)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "failed to commit transaction")
+	}
+	return rowsAffected, nil
+}
+
+// Release ends the exporting transaction and releases the connection. The snapshot cannot be imported afterwards.
+func (s *ExportedBackupSnapshot) Release() {
+	if s.conn == nil {
+		return
+	}
+	if _, err := s.conn.ExecContext(context.Background(), "COMMIT"); err != nil {
+		slog.Warn("failed to end the exported backup snapshot", log.BBError(err))
+	}
+	if err := s.conn.Close(); err != nil {
+		slog.Warn("failed to close the exported backup snapshot connection", log.BBError(err))
+	}
+	s.conn = nil
+}
+
+// getBulkCopyQuery returns the query selecting the rows to back up if the engine supports bulk copy.
+func getBulkCopyQuery(engine storepb.Engine, backupDatabaseName string, statement base.BackupStatement) (string, bool) {
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		table, err := base.QuoteQualifiedName(engine, backupDatabaseName, statement.TargetTableName)
+		if err != nil {
+			return "", false
+		}
+		query, ok := strings.CutPrefix(statement.Statement, fmt.Sprintf("CREATE TABLE %s AS ", table))
+		if !ok {
+			return "", false
+		}
+		return strings.TrimSuffix(strings.TrimSpace(query), ";"), true
+	default:
+		return "", false
+	}
+}
+
+// GetSampleBackupStatement returns the backup statement copying one in sampleRate of the rows on average.
+// The sampling predicate only applies to the backup SELECT, and the rows affected by the DML are not changed.
+func GetSampleBackupStatement(engine storepb.Engine, backupDatabaseName string, statement base.BackupStatement, sampleRate int32) (string, error) {
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		query, ok := getBulkCopyQuery(engine, backupDatabaseName, statement)
+		if !ok {
+			return "", errors.Errorf("failed to sample backup statement %q", statement.Statement)
+		}
+		table, err := base.QuoteQualifiedName(engine, backupDatabaseName, statement.TargetTableName)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM (%s) AS bb_sample WHERE random() < 1.0 / %d;", table, query, sampleRate), nil
+	default:
+		return "", errors.Errorf("sample backup is not supported for engine %s", engine)
+	}
+}
+
+// GetBoundedSampleBackupStatement returns the backup statement copying only the first limit rows ordered by the primary key,
+// or the last ones if fromLast. The bound only applies to the backup SELECT, and the rows affected by the DML are not changed.
+func GetBoundedSampleBackupStatement(engine storepb.Engine, backupDatabaseName string, statement base.BackupStatement, key []string, limit int32, fromLast bool) (string, error) {
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		if len(key) == 0 {
+			return "", errors.Errorf("bounded sample backup requires the primary key of table %q", statement.SourceTableName)
+		}
+		query, ok := getBulkCopyQuery(engine, backupDatabaseName, statement)
+		if !ok {
+			return "", errors.Errorf("failed to sample backup statement %q", statement.Statement)
+		}
+		var orderBy []string
+		for _, column := range key {
+			column, err := base.QuoteIdentifier(engine, column)
+			if err != nil {
+				return "", err
+			}
+			if fromLast {
+				column += " DESC"
+			}
+			orderBy = append(orderBy, column)
+		}
+		table, err := base.QuoteQualifiedName(engine, backupDatabaseName, statement.TargetTableName)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM (%s) AS bb_sample ORDER BY %s LIMIT %d;", table, query, strings.Join(orderBy, ", "), limit), nil
+	default:
+		return "", errors.Errorf("bounded sample backup is not supported for engine %s", engine)
+	}
+}
+
+// applyFullTableBackup backs up the whole source tables of the backup statements more uncertain than the maximum uncertainty,
+// or fails if the profile requires the certainty.
+func (exec *DataUpdateExecutor) applyFullTableBackup(ctx context.Context, engine storepb.Engine, tc base.TransformContext, sourceDatabase, backupDatabase string, statements []base.BackupStatement) error {
+	for i, statement := range statements {
+		if statement.Uncertainty <= exec.profile.PriorBackupMaximumUncertainty {
+			continue
+		}
+		if exec.profile.PriorBackupFailOnUncertainty {
+			return errors.Errorf("cannot determine the rows of table %q affected by the statement at line %d: %s", statement.SourceTableName, statement.StartPosition.GetLine(), statement.UncertaintyReason)
+		}
+		fullTable, err := getFullTableBackupStatement(ctx, engine, tc, sourceDatabase, backupDatabase, statement)
+		if err != nil {
+			return err
+		}
+		statements[i].Statement = fullTable
+		slog.Warn("backing up the whole table",
+			slog.String("table", statement.SourceTableName),
+			slog.String("reason", statement.UncertaintyReason),
+		)
+	}
+	return nil
+}
+
+// getFullTableBackupStatement returns the statement backing up the whole source table of the backup statement into its backup table.
+func getFullTableBackupStatement(ctx context.Context, engine storepb.Engine, tc base.TransformContext, sourceDatabase, backupDatabase string, statement base.BackupStatement) (string, error) {
+	identifiers := []string{statement.SourceTableName}
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_TIDB:
+	case storepb.Engine_POSTGRES:
+		if statement.SourceSchema != "" {
+			identifiers = []string{statement.SourceSchema, statement.SourceTableName}
+		}
+	default:
+		return "", errors.Errorf("full table backup is not supported for engine %s", engine)
+	}
+	table, err := base.QuoteQualifiedName(engine, identifiers...)
+	if err != nil {
+		return "", err
+	}
+	dml := fmt.Sprintf("DELETE FROM %s;", table)
+	// The whole table is backed up as if all the rows were deleted.
+	fullTable, err := base.TransformDMLToSelect(ctx, engine, tc, dml, sourceDatabase, backupDatabase, "_bbfull")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to back up the whole table %q", statement.SourceTableName)
+	}
+	if len(fullTable) != 1 {
+		return "", errors.Errorf("expected one backup statement of the whole table %q but got %d", statement.SourceTableName, len(fullTable))
+	}
+	return strings.ReplaceAll(fullTable[0].Statement, fullTable[0].TargetTableName, statement.TargetTableName), nil
+}
+
+// maximumBackupPartitions is the maximum number of backup tables the backup of one source table is split into.
+const maximumBackupPartitions = 64
+
+// maximumPostgresIdentifierLength is the maximum length of the identifiers such as the table names on Postgres.
+const maximumPostgresIdentifierLength = 63
+
+// backupPartitionAlias is the alias of the backup query in the backup statements of the parts.
+const backupPartitionAlias = "bb_partition"
+
+// getBackupPartitionColumn returns the column of the source table of the backup statement splitting the backup, which is
+// the single-column primary key, or the first single-column unique key if there is no primary key, and whether it's nullable.
+// Only Postgres is supported.
+func getBackupPartitionColumn(engine storepb.Engine, statement base.BackupStatement, metadata *storepb.DatabaseSchemaMetadata) (string, bool, bool) {
+	if engine != storepb.Engine_POSTGRES {
+		return "", false, false
+	}
+	table := findBackupSourceTable(engine, statement, metadata)
+	if table == nil {
+		return "", false, false
+	}
+	var column string
+	for _, index := range table.GetIndexes() {
+		if len(index.GetExpressions()) != 1 {
+			continue
+		}
+		if index.GetPrimary() {
+			return index.GetExpressions()[0], false, true
+		}
+		if index.GetUnique() && column == "" {
+			column = index.GetExpressions()[0]
+		}
+	}
+	if column == "" {
+		return "", false, false
+	}
+	for _, c := range table.GetColumns() {
+		if c.GetName() == column {
+			return column, c.GetNullable(), true
+		}
+	}
+	// The unique key is an expression rather than a column.
+	return "", false, false
+}
+
+// isNullsFirst returns whether the engine sorts NULLs before the other values in ascending order.
+func isNullsFirst(engine storepb.Engine) bool {
+	switch engine {
+	case storepb.Engine_POSTGRES, storepb.Engine_REDSHIFT, storepb.Engine_RISINGWAVE, storepb.Engine_ORACLE, storepb.Engine_OCEANBASE_ORACLE, storepb.Engine_DM, storepb.Engine_SNOWFLAKE:
+		return false
+	default:
+		// MySQL, TiDB, MSSQL and SQLite sort NULLs first.
+		return true
+	}
+}
+
+// partitionBackupStatements splits the backup statements affecting more than partitionRows rows into the backup statements of the parts.
+// It returns the backup statements in order with the ranges of the parts keyed by their backup tables.
+func partitionBackupStatements(ctx context.Context, sqlDB *sql.DB, engine storepb.Engine, backupDatabaseName string, statements []base.BackupStatement, metadata *storepb.DatabaseSchemaMetadata, partitionRows int64) ([]base.BackupStatement, map[string]*storepb.PriorBackupDetail_Item_Range, error) {
+	var result []base.BackupStatement
+	ranges := make(map[string]*storepb.PriorBackupDetail_Item_Range)
+	for _, statement := range statements {
+		column, nullable, ok := getBackupPartitionColumn(engine, statement, metadata)
+		if !ok {
+			result = append(result, statement)
+			continue
+		}
+		bounds, err := GetBackupPartitionBounds(ctx, sqlDB, engine, backupDatabaseName, statement, column, partitionRows)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(bounds) == 0 {
+			result = append(result, statement)
+			continue
+		}
+		parts, partRanges, err := GetPartitionedBackupStatements(engine, backupDatabaseName, statement, column, nullable, bounds)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, part := range parts {
+			ranges[part.TargetTableName] = partRanges[i]
+		}
+		result = append(result, parts...)
+		slog.Info("split backup table",
+			slog.String("table", statement.SourceTableName),
+			slog.String("column", column),
+			slog.Int("parts", len(parts)),
+		)
+	}
+	return result, ranges, nil
+}
+
+// GetBackupPartitionBounds returns the bounds of the primary key column splitting the rows backed up by the statement into parts of
+// about partitionRows rows each, at the quantiles of the column. It returns no bounds if the rows fit in one part.
+func GetBackupPartitionBounds(ctx context.Context, sqlDB *sql.DB, engine storepb.Engine, backupDatabaseName string, statement base.BackupStatement, column string, partitionRows int64) ([]string, error) {
+	query, ok := getBulkCopyQuery(engine, backupDatabaseName, statement)
+	if !ok {
+		return nil, errors.Errorf("failed to split backup statement %q", statement.Statement)
+	}
+	alias, err := base.QuoteIdentifier(engine, backupPartitionAlias)
+	if err != nil {
+		return nil, err
+	}
+	quotedColumn, err := base.QuoteQualifiedName(engine, backupPartitionAlias, column)
+	if err != nil {
+		return nil, err
+	}
+	var rows int64
+	if err := sqlDB.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM (%s) AS %s`, query, alias)).Scan(&rows); err != nil {
+		return nil, errors.Wrapf(err, "failed to count the backup rows of table %q", statement.SourceTableName)
+	}
+	parts := (rows + partitionRows - 1) / partitionRows
+	if parts <= 1 {
+		return nil, nil
+	}
+	if parts > maximumBackupPartitions {
+		parts = maximumBackupPartitions
+	}
+	var fractions []string
+	for i := int64(1); i < parts; i++ {
+		fractions = append(fractions, strconv.FormatFloat(float64(i)/float64(parts), 'f', -1, 64))
+	}
+	boundQuery := fmt.Sprintf(
+		`SELECT bound::text FROM unnest((SELECT percentile_disc(ARRAY[%s]::float8[]) WITHIN GROUP (ORDER BY %s) FROM (%s) AS %s)) WITH ORDINALITY AS b(bound, i) ORDER BY i`,
+		strings.Join(fractions, ", "), quotedColumn, query, alias,
+	)
+	boundRows, err := sqlDB.QueryContext(ctx, boundQuery)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the partition bounds of table %q", statement.SourceTableName)
+	}
+	defer boundRows.Close()
+	var bounds []string
+	for boundRows.Next() {
+		var bound string
+		if err := boundRows.Scan(&bound); err != nil {
+			return nil, errors.Wrap(err, "failed to scan partition bound")
+		}
+		// The bounds of the skewed columns may repeat, which would make empty parts.
+		if len(bounds) > 0 && bounds[len(bounds)-1] == bound {
+			continue
+		}
+		bounds = append(bounds, bound)
+	}
+	if err := boundRows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read partition bounds")
+	}
+	return bounds, nil
+}
+
+// GetPartitionedBackupStatements returns the backup statements of the parts of the backup split by the ascending bounds of the column,
+// one more part than the bounds, and the ranges of the parts. The first and the last parts are unbounded, so that the parts cover all the rows.
+// The range comparisons never match NULLs, so the rows whose nullable column is NULL are in the part at the end where the engine sorts NULLs.
+func GetPartitionedBackupStatements(engine storepb.Engine, backupDatabaseName string, statement base.BackupStatement, column string, nullable bool, bounds []string) ([]base.BackupStatement, []*storepb.PriorBackupDetail_Item_Range, error) {
+	query, ok := getBulkCopyQuery(engine, backupDatabaseName, statement)
+	if !ok {
+		return nil, nil, errors.Errorf("failed to split backup statement %q", statement.Statement)
+	}
+	alias, err := base.QuoteIdentifier(engine, backupPartitionAlias)
+	if err != nil {
+		return nil, nil, err
+	}
+	quotedColumn, err := base.QuoteQualifiedName(engine, backupPartitionAlias, column)
+	if err != nil {
+		return nil, nil, err
+	}
+	quoteBound := func(bound string) string {
+		return fmt.Sprintf("'%s'", strings.ReplaceAll(bound, "'", "''"))
+	}
+	var parts []base.BackupStatement
+	var ranges []*storepb.PriorBackupDetail_Item_Range
+	for i := 0; i <= len(bounds); i++ {
+		partRange := &storepb.PriorBackupDetail_Item_Range{Column: column}
+		var predicates []string
+		if i > 0 {
+			partRange.Start = &bounds[i-1]
+			predicates = append(predicates, fmt.Sprintf("%s >= %s", quotedColumn, quoteBound(bounds[i-1])))
+		}
+		if i < len(bounds) {
+			partRange.End = &bounds[i]
+			predicates = append(predicates, fmt.Sprintf("%s < %s", quotedColumn, quoteBound(bounds[i])))
+		}
+		predicate := strings.Join(predicates, " AND ")
+		if nullable && ((isNullsFirst(engine) && i == 0) || (!isNullsFirst(engine) && i == len(bounds))) {
+			partRange.Nulls = true
+			predicate = fmt.Sprintf("(%s OR %s IS NULL)", predicate, quotedColumn)
+		}
+		suffix := fmt.Sprintf("_p%d", i+1)
+		targetTable, _ := common.TruncateString(statement.TargetTableName, maximumPostgresIdentifierLength-len(suffix))
+		targetTable += suffix
+		quotedTargetTable, err := base.QuoteQualifiedName(engine, backupDatabaseName, targetTable)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		part := statement
+		part.TargetTableName = targetTable
+		// The backup query is selected by all columns with the qualifier, so that the later rewrites of the backup statement still apply.
+		part.Statement = fmt.Sprintf(`CREATE TABLE %s AS SELECT %s.* FROM (%s) AS %s WHERE %s;`,
+			quotedTargetTable, alias, query, alias, predicate)
+		parts = append(parts, part)
+		ranges = append(ranges, partRange)
+	}
+	return parts, ranges, nil
+}
+
+// createBackupDatabase creates the backup database on the instance of the source database and registers it in the project of the source database.
+func (exec *DataUpdateExecutor) createBackupDatabase(ctx context.Context, driverCtx context.Context, instance *store.InstanceMessage, source *store.DatabaseMessage, backupDatabaseName string) (*store.DatabaseMessage, error) {
+	driver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, nil /* database */, db.ConnectionContext{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get instance driver")
+	}
+	defer driver.Close(driverCtx)
+	database, err := base.QuoteIdentifier(instance.Engine, backupDatabaseName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := driver.Execute(driverCtx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s;", database), db.ExecuteOptions{CreateDatabase: true}); err != nil {
+		return nil, err
+	}
+	return exec.store.UpsertDatabase(ctx, &store.DatabaseMessage{
+		ProjectID:            source.ProjectID,
+		InstanceID:           instance.ResourceID,
+		DatabaseName:         backupDatabaseName,
+		EnvironmentID:        source.EnvironmentID,
+		SyncState:            api.OK,
+		SuccessfulSyncTimeTs: exec.now().Unix(),
+		Metadata:             &storepb.DatabaseMetadata{},
+	})
+}
+
+// maximumBackupTablePrefixAttempts is the maximum number of backup table prefixes tried to avoid name collisions.
+const maximumBackupTablePrefixAttempts = 10
+
+// transformDMLToBackup transforms the statement to the backup statements.
+// If a backup table name collides with a table referenced by the statement, it retries with another table prefix.
+func transformDMLToBackup(ctx context.Context, engine storepb.Engine, tc base.TransformContext, statement, sourceDatabase, backupDatabase, prefix string) ([]base.BackupStatement, error) {
+	for i := 0; i < maximumBackupTablePrefixAttempts; i++ {
+		tablePrefix := prefix
+		if i > 0 {
+			tablePrefix = fmt.Sprintf("%s_%d", prefix, i)
+		}
+		statements, err := base.TransformDMLToSelect(ctx, engine, tc, statement, sourceDatabase, backupDatabase, tablePrefix)
+		if err != nil {
+			return nil, err
+		}
+		if !hasBackupTableCollision(statement, statements) {
+			return statements, nil
+		}
+		slog.Warn("backup table name collides with the statement, retry with another prefix", slog.String("prefix", tablePrefix))
+	}
+	return nil, errors.Errorf("backup table names collide with the tables referenced by the statement after %d attempts", maximumBackupTablePrefixAttempts)
+}
+
+// procedureCallRegexp matches the statement calling a stored procedure, possibly after comments.
+var procedureCallRegexp = regexp.MustCompile(`(?is)^(?:\s+|--[^\n]*|/\*.*?\*/)*CALL\b`)
+
+// procedureBackupReason is the reason of backing up the whole tables declared to be modified by the stored procedures.
+const procedureBackupReason = "the rows are modified by the stored procedures called in the statement"
+
+// transformBackupStatement transforms the DML into the backup statements, or the override backup statement instead if set,
+// which must modify the same tables as the DML.
+func transformBackupStatement(ctx context.Context, engine storepb.Engine, tc base.TransformContext, statement, override, sourceDatabase, backupDatabase, prefix string) ([]base.BackupStatement, error) {
+	statements, err := transformDMLToBackup(ctx, engine, tc, statement, sourceDatabase, backupDatabase, prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to transform DML to select")
+	}
+	if override == "" {
+		return statements, nil
+	}
+	overrideStatements, err := transformDMLToBackup(ctx, engine, tc, override, sourceDatabase, backupDatabase, prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to transform backup statement to select")
+	}
+	tables := func(statements []base.BackupStatement) []string {
+		var result []string
+		for _, statement := range statements {
+			schema := statement.SourceSchema
+			if schema == "" {
+				schema = getDefaultSchema(engine)
+			}
+			table := fmt.Sprintf("%s.%s", schema, statement.SourceTableName)
+			if !slices.Contains(result, table) {
+				result = append(result, table)
+			}
+		}
+		slices.Sort(result)
+		return result
+	}
+	if want, got := tables(statements), tables(overrideStatements); !slices.Equal(want, got) {
+		return nil, errors.Errorf("the backup statement modifies tables %v, but the statement modifies tables %v", got, want)
+	}
+	return overrideStatements, nil
+}
+
+// GetProcedureBackupStatements returns the statements backing up the whole tables declared to be modified by the stored procedures
+// called in the statement, since the rows changed by the procedures cannot be derived from the statement. The tables are "schema.table"
+// or "table", and are backed up at the first procedure call. It returns an error if the statement calls procedures without declared tables.
+func GetProcedureBackupStatements(ctx context.Context, engine storepb.Engine, tc base.TransformContext, statement, sourceDatabase, backupDatabase, prefix string, tables []string) ([]base.BackupStatement, error) {
+	list, err := base.SplitMultiSQL(engine, statement)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to split statement")
+	}
+	var call *base.SingleSQL
+	for i := range list {
+		if procedureCallRegexp.MatchString(list[i].Text) {
+			call = &list[i]
+			break
+		}
+	}
+	if call == nil {
+		return nil, nil
+	}
+	if len(tables) == 0 {
+		return nil, errors.Errorf("the statement at line %d calls stored procedures, declare the tables modified by the procedures to back them up", call.FirstStatementLine+1)
+	}
+
+	var result []base.BackupStatement
+	targetTables := make(map[string]bool)
+	for _, table := range tables {
+		schema, name, ok := strings.Cut(table, ".")
+		if !ok {
+			schema, name = "", table
+		} else if getDefaultSchema(engine) == "" {
+			return nil, errors.Errorf("table %q declared for the stored procedures must not be qualified on %s", table, engine)
+		}
+		if name == "" {
+			return nil, errors.Errorf("invalid table %q declared for the stored procedures", table)
+		}
+		// The declared tables are not parsed, so fold them as the engine does.
+		schema, name = foldIdentifier(engine, schema), foldIdentifier(engine, name)
+		targetTable, _ := common.TruncateString(fmt.Sprintf("%s_call_%s", prefix, name), maximumPostgresIdentifierLength)
+		if targetTables[targetTable] {
+			continue
+		}
+		targetTables[targetTable] = true
+		backupStatement := base.BackupStatement{
+			SourceSchema:      schema,
+			SourceTableName:   name,
+			TargetTableName:   targetTable,
+			Uncertainty:       1,
+			UncertaintyReason: procedureBackupReason,
+			StartPosition: &storepb.Position{
+				Line:   int32(call.FirstStatementLine + 1),
+				Column: int32(call.FirstStatementColumn),
+			},
+			EndPosition: &storepb.Position{
+				Line:   int32(call.LastLine + 1),
+				Column: int32(call.LastColumn),
+			},
+		}
+		fullTable, err := getFullTableBackupStatement(ctx, engine, tc, sourceDatabase, backupDatabase, backupStatement)
+		if err != nil {
+			return nil, err
+		}
+		backupStatement.Statement = fullTable
+		result = append(result, backupStatement)
+	}
+	return result, nil
+}
+
+// foldIdentifier folds the unquoted identifier to the case the engine stores it in, i.e. lower case on Postgres,
+// and upper case on Oracle and Snowflake. The quoted identifier is kept as is without the quotes.
+func foldIdentifier(engine storepb.Engine, identifier string) string {
+	if len(identifier) >= 2 && identifier[0] == '"' && identifier[len(identifier)-1] == '"' {
+		return strings.ReplaceAll(identifier[1:len(identifier)-1], `""`, `"`)
+	}
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		return strings.ToLower(identifier)
+	case storepb.Engine_ORACLE, storepb.Engine_SNOWFLAKE:
+		return strings.ToUpper(identifier)
+	default:
+		return identifier
+	}
+}
+
+// normalizeBackupTableName returns the table name recorded in the prior backup detail as it's stored by the instance,
+// so that the items match the synced metadata the restore tooling looks up. The parsers already fold the names on
+// Postgres and Oracle, and MySQL stores the names in lower case with lower_case_table_names = 1.
+// The other engines store the names as given even if they compare them case-insensitively.
+func normalizeBackupTableName(instance *store.InstanceMessage, name string) string {
+	switch instance.Engine {
+	case storepb.Engine_MYSQL, storepb.Engine_MARIADB:
+		if instance.Metadata.GetMysqlLowerCaseTableNames() == 1 {
+			return strings.ToLower(name)
+		}
+	default:
+	}
+	return name
+}
+
+// orderBackupStatementsByDependency orders the backup statements topologically by the foreign keys of the source tables,
+// so that the statements of the referenced tables come first. The statements keep their original order otherwise,
+// and the statements in a dependency cycle are left in their original order after the others.
+func orderBackupStatementsByDependency(engine storepb.Engine, statements []base.BackupStatement, metadata *storepb.DatabaseSchemaMetadata) []base.BackupStatement {
+	references := getForeignKeyReferences(engine, metadata)
+
+	// pending counts the statements of the tables not yet emitted.
+	pending := make(map[string]int)
+	for _, statement := range statements {
+		pending[getTableKey(engine, statement.SourceSchema, statement.SourceTableName)]++
+	}
+	var ordered []base.BackupStatement
+	emitted := make([]bool, len(statements))
+	for progress := true; progress; {
+		progress = false
+		for i, statement := range statements {
+			if emitted[i] {
+				continue
+			}
+			key := getTableKey(engine, statement.SourceSchema, statement.SourceTableName)
+			ready := true
+			for _, referenced := range references[key] {
+				if pending[referenced] > 0 {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+			ordered = append(ordered, statement)
+			emitted[i] = true
+			pending[key]--
+			progress = true
+		}
+	}
+	for i, statement := range statements {
+		if !emitted[i] {
+			ordered = append(ordered, statement)
+		}
+	}
+	return ordered
+}
+
+// getForeignKeyReferences returns the map from each table to the tables referenced by its foreign keys by their table keys.
+func getForeignKeyReferences(engine storepb.Engine, metadata *storepb.DatabaseSchemaMetadata) map[string][]string {
+	references := make(map[string][]string)
+	for _, schema := range metadata.GetSchemas() {
+		for _, table := range schema.GetTables() {
+			key := getTableKey(engine, schema.GetName(), table.GetName())
+			for _, fk := range table.GetForeignKeys() {
+				referencedSchema := fk.GetReferencedSchema()
+				if referencedSchema == "" {
+					referencedSchema = schema.GetName()
+				}
+				referenced := getTableKey(engine, referencedSchema, fk.GetReferencedTable())
+				if referenced != key {
+					references[key] = append(references[key], referenced)
+				}
+			}
+		}
+	}
+	return references
+}
+
+// getTableKey returns the key of the table in the format of schema.table with the default schema of the engine,
+// or table if the engine has no schemas.
+func getTableKey(engine storepb.Engine, schema, table string) string {
+	if schema == "" {
+		schema = getDefaultSchema(engine)
+	}
+	if schema == "" {
+		return table
+	}
+	return fmt.Sprintf("%s.%s", schema, table)
+}
+
+// isSmallBackupTable returns whether the source table of the backup statement has at most threshold rows by the synced metadata,
+// so that its backup skips the overhead of the table comment and the issue comment.
+// The tables not found in the metadata are not small.
+func isSmallBackupTable(engine storepb.Engine, statement base.BackupStatement, metadata *storepb.DatabaseSchemaMetadata, threshold int64) bool {
+	if threshold <= 0 {
+		return false
+	}
+	table := findBackupSourceTable(engine, statement, metadata)
+	if table == nil {
+		return false
+	}
+	return table.GetRowCount() <= threshold
+}
+
+// findBackupSourceTable returns the metadata of the source table of the backup statement, or nil if it's not found.
+func findBackupSourceTable(engine storepb.Engine, statement base.BackupStatement, metadata *storepb.DatabaseSchemaMetadata) *storepb.TableMetadata {
+	schemaName := statement.SourceSchema
+	if schemaName == "" {
+		schemaName = getDefaultSchema(engine)
+	}
+	for _, schema := range metadata.GetSchemas() {
+		if schema.GetName() != schemaName {
+			continue
+		}
+		for _, table := range schema.GetTables() {
+			if table.GetName() == statement.SourceTableName {
+				return table
+			}
+		}
+	}
+	return nil
+}
+
+// getSourceTablePartitions returns the partitions of the source table of the backup statement by the synced metadata,
+// which are captured for the restores to route the rows to the right partitions.
+func getSourceTablePartitions(engine storepb.Engine, statement base.BackupStatement, metadata *storepb.DatabaseSchemaMetadata) []*storepb.TablePartitionMetadata {
+	return findBackupSourceTable(engine, statement, metadata).GetPartitions()
+}
+
+// backupThroughput is the assumed number of bytes copied into the backup tables per second for estimating the backup duration.
+const backupThroughput = 32 * 1024 * 1024
+
+// EstimateBackupImpact estimates the rows, bytes and duration of backing up the source table of each backup statement
+// by the synced table statistics. The estimates are the upper bounds as if all the rows of the tables were affected.
+func EstimateBackupImpact(engine storepb.Engine, statements []base.BackupStatement, metadata *storepb.DatabaseSchemaMetadata) []*storepb.IssueCommentPayload_TaskPriorBackupEstimate_Table {
+	var estimates []*storepb.IssueCommentPayload_TaskPriorBackupEstimate_Table
+	for _, statement := range statements {
+		estimate := &storepb.IssueCommentPayload_TaskPriorBackupEstimate_Table{
+			Schema: statement.SourceSchema,
+			Table:  statement.SourceTableName,
+		}
+		table := findBackupSourceTable(engine, statement, metadata)
+		if table == nil {
+			estimate.Unknown = true
+		} else {
+			estimate.Rows = table.GetRowCount()
+			estimate.Bytes = table.GetDataSize()
+			estimate.Duration = durationpb.New(time.Duration(float64(table.GetDataSize()) / backupThroughput * float64(time.Second)).Round(time.Second))
+		}
+		estimates = append(estimates, estimate)
+	}
+	return estimates
+}
+
+const (
+	// backupBudgetWarn backs up anyway with a warning if the backup is estimated to exceed the time budget.
+	backupBudgetWarn = "WARN"
+	// backupBudgetDefer defers the data update task if the backup is estimated to exceed the time budget.
+	backupBudgetDefer = "DEFER"
+)
+
+// backupBudgetRecheckInterval is the interval to check again whether the deferred backup fits in the time budget,
+// which fits only after the budget deadline of the task is extended.
+const backupBudgetRecheckInterval = 10 * time.Minute
+
+// checkBackupBudget compares the estimated duration of the backup into the target with the time budget remaining before the deadline.
+// It returns the warning if the backup is estimated to exceed the budget, or the deferred error by the DEFER policy.
+func (exec *DataUpdateExecutor) checkBackupBudget(ctx context.Context, statement string, instance *store.InstanceMessage, database *store.DatabaseMessage, target *backupTarget, deadline time.Time) (string, error) {
+	_, backupDatabaseName, err := common.GetInstanceDatabaseID(target.target)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse backup database")
+	}
+	tc := base.TransformContext{
+		InstanceID:              instance.ResourceID,
+		GetDatabaseMetadataFunc: BuildGetDatabaseMetadataFunc(exec.store),
+	}
+	statements, err := transformDMLToBackup(ctx, instance.Engine, tc, statement, database.DatabaseName, backupDatabaseName, "_"+exec.now().Format("20060102150405"))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to transform DML to select")
+	}
+	dbSchema, err := exec.store.GetDBSchema(ctx, database.UID)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get database schema")
+	}
+	estimate := getBackupDurationEstimate(EstimateBackupImpact(instance.Engine, statements, dbSchema.GetMetadata()))
+	return getBackupBudgetVerdict(exec.profile.PriorBackupBudgetPolicy, estimate, deadline, exec.now())
+}
+
+// getBackupDurationEstimate returns the total estimated duration of backing up the tables. The tables without statistics are not counted.
+func getBackupDurationEstimate(estimates []*storepb.IssueCommentPayload_TaskPriorBackupEstimate_Table) time.Duration {
+	var total time.Duration
+	for _, estimate := range estimates {
+		if estimate.Unknown {
+			continue
+		}
+		total += estimate.Duration.AsDuration()
+	}
+	return total
+}
+
+// getBackupBudgetVerdict returns the warning or the deferred error by the configured policy if the estimated duration of the backup
+// exceeds the time budget remaining before the deadline.
+func getBackupBudgetVerdict(policy string, estimate time.Duration, deadline, now time.Time) (string, error) {
+	switch strings.ToUpper(policy) {
+	case "", backupBudgetWarn, backupBudgetDefer:
+	default:
+		return "", errors.Errorf("unsupported backup budget policy %q", policy)
+	}
+	remaining := max(deadline.Sub(now), 0)
+	if estimate <= remaining {
+		return "", nil
+	}
+	if strings.ToUpper(policy) == backupBudgetDefer {
+		return "", &backupDeferredError{until: now.Add(backupBudgetRecheckInterval), reason: "the remaining time budget"}
+	}
+	return fmt.Sprintf("the prior backup is estimated to take %s, more than the remaining time budget %s before %s", estimate, remaining.Round(time.Second), deadline.Format(time.RFC3339)), nil
+}
+
+// ReportPriorBackupCost posts the estimated prior backup cost of each affected table of the data update task as an issue comment,
+// so that the reviewers see the backup impact before approving. No backups are created.
+func (exec *DataUpdateExecutor) ReportPriorBackupCost(ctx context.Context, task *store.TaskMessage) error {
+	payload := &storepb.TaskDatabaseUpdatePayload{}
+	if err := common.ProtojsonUnmarshaler.Unmarshal([]byte(task.Payload), payload); err != nil {
+		return errors.Wrap(err, "invalid database data update payload")
+	}
+	if getBackupRequirement(payload) == storepb.TaskDatabaseUpdatePayload_DISABLED {
+		return nil
+	}
+	statement, err := exec.store.GetSheetStatementByID(ctx, int(payload.SheetId))
+	if err != nil {
+		return err
+	}
+	instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &task.InstanceID})
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance")
+	}
+	database, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{UID: task.DatabaseID})
+	if err != nil {
+		return errors.Wrap(err, "failed to get database")
+	}
+	issue, err := exec.store.GetIssueV2(ctx, &store.FindIssueMessage{PipelineID: &task.PipelineID})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find issue for pipeline %v", task.PipelineID)
+	}
+	if issue == nil {
+		return errors.Errorf("issue not found for pipeline %v", task.PipelineID)
+	}
+	backupDatabaseName := sinkBackupSchema
+	if payload.GetPreUpdateBackupDetail().GetDatabase() != "" {
+		_, backupDatabaseName, err = common.GetInstanceDatabaseID(payload.PreUpdateBackupDetail.Database)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse backup database")
+		}
+	}
+
+	tc := base.TransformContext{
+		InstanceID:              instance.ResourceID,
+		GetDatabaseMetadataFunc: BuildGetDatabaseMetadataFunc(exec.store),
+	}
+	statements, err := transformDMLToBackup(ctx, instance.Engine, tc, statement, database.DatabaseName, backupDatabaseName, "_"+exec.now().Format("20060102150405"))
+	if err != nil {
+		return errors.Wrap(err, "failed to transform DML to select")
+	}
+	dbSchema, err := exec.store.GetDBSchema(ctx, database.UID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get database schema")
+	}
+	estimates := EstimateBackupImpact(instance.Engine, statements, dbSchema.GetMetadata())
+	if _, err := exec.store.CreateIssueComment(ctx, getBackupCostComment(issue, task, estimates), api.SystemBotID); err != nil {
+		return errors.Wrap(err, "failed to create issue comment")
+	}
+	return nil
+}
+
+// getPriorBackupComment returns the issue comment recording the prior backup tables of the task in the backup database.
+func getPriorBackupComment(issue *store.IssueMessage, task *store.TaskMessage, backupDatabaseName string, tables []*storepb.IssueCommentPayload_TaskPriorBackup_Table) *store.IssueCommentMessage {
+	return &store.IssueCommentMessage{
+		IssueUID: issue.UID,
+		Payload: &storepb.IssueCommentPayload{
+			Event: &storepb.IssueCommentPayload_TaskPriorBackup_{
+				TaskPriorBackup: &storepb.IssueCommentPayload_TaskPriorBackup{
+					Task:     common.FormatTask(issue.Project.ResourceID, task.PipelineID, task.StageID, task.ID),
+					Database: backupDatabaseName,
+					Tables:   tables,
+				},
+			},
+		},
+	}
+}
+
+// getBackupCostComment returns the issue comment reporting the estimated prior backup cost of the task.
+func getBackupCostComment(issue *store.IssueMessage, task *store.TaskMessage, estimates []*storepb.IssueCommentPayload_TaskPriorBackupEstimate_Table) *store.IssueCommentMessage {
+	var lines []string
+	for _, estimate := range estimates {
+		name := estimate.Table
+		if estimate.Schema != "" {
+			name = fmt.Sprintf("%s.%s", estimate.Schema, estimate.Table)
+		}
+		if estimate.Unknown {
+			lines = append(lines, fmt.Sprintf("- %s: unknown", name))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("- %s: up to %d rows, %d bytes, %s", name, estimate.Rows, estimate.Bytes, estimate.Duration.AsDuration()))
+	}
+	return &store.IssueCommentMessage{
+		IssueUID: issue.UID,
+		Payload: &storepb.IssueCommentPayload{
+			Comment: fmt.Sprintf("Estimated prior backup cost:\n%s", strings.Join(lines, "\n")),
+			Event: &storepb.IssueCommentPayload_TaskPriorBackupEstimate_{
+				TaskPriorBackupEstimate: &storepb.IssueCommentPayload_TaskPriorBackupEstimate{
+					Task:   common.FormatTask(issue.Project.ResourceID, task.PipelineID, task.StageID, task.ID),
+					Tables: estimates,
+				},
+			},
+		},
+	}
+}
+
+// getDefaultSchema returns the schema of the tables referenced without schema.
+func getDefaultSchema(engine storepb.Engine) string {
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		return "public"
+	case storepb.Engine_MSSQL:
+		return "dbo"
+	default:
+		return ""
+	}
+}
+
+// getCurrentDatabaseQuery returns the query of the database the session is connected to, or empty if it's not supported on the engine.
+func getCurrentDatabaseQuery(engine storepb.Engine) string {
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_TIDB, storepb.Engine_MARIADB, storepb.Engine_OCEANBASE:
+		return "SELECT DATABASE()"
+	case storepb.Engine_POSTGRES:
+		return "SELECT current_database()"
+	case storepb.Engine_MSSQL:
+		return "SELECT DB_NAME()"
+	case storepb.Engine_ORACLE:
+		return "SELECT SYS_CONTEXT('USERENV', 'CURRENT_SCHEMA') FROM DUAL"
+	default:
+		return ""
+	}
+}
+
+// checkDriverDatabase returns an error if the driver is not connected to the database.
+// It's skipped on the engines without the query of the current database.
+func checkDriverDatabase(ctx context.Context, driver db.Driver, engine storepb.Engine, database string) error {
+	query := getCurrentDatabaseQuery(engine)
+	if query == "" {
+		return nil
+	}
+	var current sql.NullString
+	if err := driver.GetDB().QueryRowContext(ctx, query).Scan(&current); err != nil {
+		return errors.Wrap(err, "failed to get the current database")
+	}
+	if current.String != database {
+		return errors.Errorf("the driver is connected to database %q instead of %q", current.String, database)
+	}
+	return nil
+}
+
+// hasBackupTableCollision returns whether the statement may reference a backup table to be created.
+// It matches the names textually, so a false positive only results in another table prefix.
+func hasBackupTableCollision(statement string, backupStatements []base.BackupStatement) bool {
+	lowerStatement := strings.ToLower(statement)
+	for _, backupStatement := range backupStatements {
+		if strings.Contains(lowerStatement, strings.ToLower(backupStatement.TargetTableName)) {
+			return true
+		}
+	}
+	return false
+}
+
+// getSourceTableStorage returns the storage engine on MySQL and TiDB, or the tablespace on Oracle and Postgres of the source table.
+func getSourceTableStorage(ctx context.Context, sqlDB *sql.DB, engine storepb.Engine, databaseName string, statement base.BackupStatement) (string, string, error) {
+	var query string
+	var args []any
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_TIDB:
+		query = "SELECT IFNULL(ENGINE, '') FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?"
+		args = []any{databaseName, statement.SourceTableName}
+	case storepb.Engine_POSTGRES:
+		schema := statement.SourceSchema
+		if schema == "" {
+			schema = "public"
+		}
+		query = "SELECT COALESCE(tablespace, '') FROM pg_tables WHERE schemaname = $1 AND tablename = $2"
+		args = []any{schema, statement.SourceTableName}
+	case storepb.Engine_ORACLE:
+		owner := statement.SourceSchema
+		if owner == "" {
+			owner = databaseName
+		}
+		query = "SELECT NVL(TABLESPACE_NAME, ' ') FROM ALL_TABLES WHERE OWNER = :1 AND TABLE_NAME = :2"
+		args = []any{owner, statement.SourceTableName}
+	default:
+		return "", "", nil
+	}
+
+	var value string
+	if err := sqlDB.QueryRowContext(ctx, query, args...).Scan(&value); err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	value = strings.TrimSpace(value)
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_TIDB:
+		return value, "", nil
+	default:
+		return "", value, nil
+	}
+}
+
+// GetOwnedSequences returns the sequences owned by the columns of the Postgres source table,
+// including the serial sequences and the identity sequences.
+func GetOwnedSequences(ctx context.Context, sqlDB *sql.DB, statement base.BackupStatement) ([]*storepb.PriorBackupDetail_Item_OwnedSequence, error) {
+	schema := statement.SourceSchema
+	if schema == "" {
+		schema = "public"
+	}
+	// The serial sequences depend on the columns automatically, and the identity sequences internally.
+	query := `
+		SELECT a.attname, sn.nspname, s.relname, a.attidentity::text
+		FROM pg_class t
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN pg_depend d ON d.refclassid = 'pg_class'::regclass AND d.refobjid = t.oid AND d.classid = 'pg_class'::regclass AND d.deptype IN ('a', 'i')
+		JOIN pg_class s ON s.oid = d.objid AND s.relkind = 'S'
+		JOIN pg_namespace sn ON sn.oid = s.relnamespace
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = d.refobjsubid
+		WHERE n.nspname = $1 AND t.relname = $2
+		ORDER BY a.attnum`
+	rows, err := sqlDB.QueryContext(ctx, query, schema, statement.SourceTableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []*storepb.PriorBackupDetail_Item_OwnedSequence
+	for rows.Next() {
+		var identity string
+		sequence := &storepb.PriorBackupDetail_Item_OwnedSequence{}
+		if err := rows.Scan(&sequence.Column, &sequence.Schema, &sequence.Sequence, &identity); err != nil {
+			return nil, err
+		}
+		switch identity {
+		case "a":
+			sequence.IdentityGeneration = "ALWAYS"
+		case "d":
+			sequence.IdentityGeneration = "BY DEFAULT"
+		}
+		result = append(result, sequence)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetOwnedSequenceRestoreStatements returns the statements run after restoring the backup rows into the Postgres source table.
+// They move the owned sequences past the restored values, so that the sequences keep their ownership and the next values don't collide.
+// The rows of the ALWAYS identity columns must be restored by INSERT ... OVERRIDING SYSTEM VALUE.
+func GetOwnedSequenceRestoreStatements(item *storepb.PriorBackupDetail_Item) ([]string, error) {
+	schema := item.GetSourceTable().GetSchema()
+	if schema == "" {
+		schema = "public"
+	}
+	table, err := base.QuoteQualifiedName(storepb.Engine_POSTGRES, schema, item.GetSourceTable().GetTable())
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, sequence := range item.GetOwnedSequences() {
+		sequenceName, err := base.QuoteQualifiedName(storepb.Engine_POSTGRES, sequence.Schema, sequence.Sequence)
+		if err != nil {
+			return nil, err
+		}
+		column, err := base.QuoteIdentifier(storepb.Engine_POSTGRES, sequence.Column)
+		if err != nil {
+			return nil, err
+		}
+		// The sequence name is a regclass literal.
+		sequenceName = strings.ReplaceAll(sequenceName, "'", "''")
+		// setval with is_called false makes the next value the given one, and keeps the sequence untouched for empty tables.
+		result = append(result, fmt.Sprintf(
+			`SELECT setval('%s', GREATEST((SELECT MAX(%s) + 1 FROM %s), nextval('%s')), false);`,
+			sequenceName, column, table, sequenceName,
+		))
+	}
+	return result, nil
+}
+
+// backupSelectAllRegexp matches the CREATE TABLE ... AS SELECT backup statements selecting all columns of the source table,
+// e.g. CREATE TABLE "bbdataarchive"."_0_t" AS SELECT "t".* FROM ..., and captures the qualifier of the source table.
+var backupSelectAllRegexp = regexp.MustCompile(`^CREATE TABLE "(?:[^"]|"")*"\."(?:[^"]|"")*" AS SELECT ((?:"(?:[^"]|"")*"\.)+)\* `)
+
+// backupSelectAllPrefixRegexp is backupSelectAllRegexp matching the statements with more select items after all the columns.
+var backupSelectAllPrefixRegexp = regexp.MustCompile(`^CREATE TABLE "(?:[^"]|"")*"\."(?:[^"]|"")*" AS SELECT ((?:"(?:[^"]|"")*"\.)+)\*`)
+
+// backupSurrogateKeyColumn is the surrogate key column of the backup tables.
+const backupSurrogateKeyColumn = "bb_surrogate_key"
+
+// GetSurrogateKeyBackupStatement returns the backup statement adding the surrogate key column to the backup table.
+// The surrogate key is the source ROWID on Oracle, and a random UUID on Postgres.
+func GetSurrogateKeyBackupStatement(engine storepb.Engine, statement base.BackupStatement) (string, storepb.PriorBackupDetail_Item_SurrogateKey, bool) {
+	match := backupSelectAllRegexp.FindStringSubmatchIndex(statement.Statement)
+	if match == nil {
+		return "", storepb.PriorBackupDetail_Item_SURROGATE_KEY_UNSPECIFIED, false
+	}
+	qualifier := statement.Statement[match[2]:match[3]]
+	var column string
+	var key storepb.PriorBackupDetail_Item_SurrogateKey
+	switch engine {
+	case storepb.Engine_ORACLE:
+		column = fmt.Sprintf(`ROWIDTOCHAR(%sROWID) AS "%s"`, qualifier, backupSurrogateKeyColumn)
+		key = storepb.PriorBackupDetail_Item_ROWID
+	case storepb.Engine_POSTGRES:
+		column = fmt.Sprintf(`gen_random_uuid() AS "%s"`, backupSurrogateKeyColumn)
+		key = storepb.PriorBackupDetail_Item_UUID
+	default:
+		return "", storepb.PriorBackupDetail_Item_SURROGATE_KEY_UNSPECIFIED, false
+	}
+	selectAll := statement.Statement[:match[1]-1]
+	return fmt.Sprintf("%s, %s %s", selectAll, column, statement.Statement[match[1]:]), key, true
+}
+
+// noBackupColumnLabel is the column label excluding the column from the prior backups if its value is "true",
+// e.g. for the columns holding secrets that must not be duplicated into the backup tables.
+const noBackupColumnLabel = "no-backup"
+
+// getNoBackupColumns returns the columns of the source table of the backup statement labeled as no-backup in the database config.
+func getNoBackupColumns(engine storepb.Engine, statement base.BackupStatement, config *storepb.DatabaseConfig) map[string]bool {
+	schemaName := statement.SourceSchema
+	if schemaName == "" {
+		schemaName = getDefaultSchema(engine)
+	}
+	columns := make(map[string]bool)
+	for _, schemaConfig := range config.GetSchemaConfigs() {
+		if schemaConfig.GetName() != schemaName {
+			continue
+		}
+		for _, tableConfig := range schemaConfig.GetTableConfigs() {
+			if tableConfig.GetName() != statement.SourceTableName {
+				continue
+			}
+			for _, columnConfig := range tableConfig.GetColumnConfigs() {
+				if columnConfig.GetLabels()[noBackupColumnLabel] == "true" {
+					columns[columnConfig.GetName()] = true
+				}
+			}
+		}
+	}
+	return columns
+}
+
+// applyNoBackupColumns excludes the no-backup columns of the source table from the backup statement and returns the excluded columns.
+// The secrets must not be duplicated into the backup tables, so it fails if the columns cannot be excluded.
+func applyNoBackupColumns(engine storepb.Engine, statement *base.BackupStatement, metadata *storepb.DatabaseSchemaMetadata, config *storepb.DatabaseConfig) ([]string, error) {
+	noBackupColumns := getNoBackupColumns(engine, *statement, config)
+	if len(noBackupColumns) == 0 {
+		return nil, nil
+	}
+	table := findBackupSourceTable(engine, *statement, metadata)
+	excluded, ok := excludeBackupColumns(engine, *statement, table, noBackupColumns)
+	if !ok {
+		return nil, errors.Errorf("failed to exclude the no-backup columns of table %q from the backup", statement.SourceTableName)
+	}
+	statement.Statement = excluded
+	var excludedColumns []string
+	for _, column := range table.GetColumns() {
+		if noBackupColumns[column.GetName()] {
+			excludedColumns = append(excludedColumns, column.GetName())
+		}
+	}
+	return excludedColumns, nil
+}
+
+// excludeBackupColumns returns the backup statement selecting NULL for the excluded columns of the source table instead of their values.
+// The backup table keeps all the columns with their types in order, so that the other columns can still be restored.
+// Only Oracle and Postgres are supported.
+func excludeBackupColumns(engine storepb.Engine, statement base.BackupStatement, table *storepb.TableMetadata, excluded map[string]bool) (string, bool) {
+	var nullFormat string
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		nullFormat = `CASE WHEN FALSE THEN %s"%s" END AS "%s"`
+	case storepb.Engine_ORACLE:
+		nullFormat = `CASE WHEN 1 = 0 THEN %s"%s" END AS "%s"`
+	default:
+		return "", false
+	}
+	if table == nil {
+		return "", false
+	}
+	match := backupSelectAllPrefixRegexp.FindStringSubmatchIndex(statement.Statement)
+	if match == nil {
+		return "", false
+	}
+	qualifier := statement.Statement[match[2]:match[3]]
+	var columns []string
+	for _, column := range table.GetColumns() {
+		// The hidden columns are not selected by *.
+		if column.GetHidden() {
+			continue
+		}
+		name := strings.ReplaceAll(column.GetName(), `"`, `""`)
+		if excluded[column.GetName()] {
+			columns = append(columns, fmt.Sprintf(nullFormat, qualifier, name, name))
+			continue
+		}
+		columns = append(columns, fmt.Sprintf(`%s"%s"`, qualifier, name))
+	}
+	return fmt.Sprintf("%s%s%s", statement.Statement[:match[2]], strings.Join(columns, ", "), statement.Statement[match[1]:]), true
+}
+
+// setBackupTablespace returns the CREATE TABLE ... AS SELECT backup statement creating the backup table in the tablespace.
+func setBackupTablespace(engine storepb.Engine, backupDatabaseName string, statement base.BackupStatement, tablespace string) (string, bool) {
+	table, err := base.QuoteQualifiedName(engine, backupDatabaseName, statement.TargetTableName)
+	if err != nil {
+		return "", false
+	}
+	quotedTablespace, err := base.QuoteIdentifier(engine, tablespace)
+	if err != nil {
+		return "", false
+	}
+	query, ok := strings.CutPrefix(statement.Statement, fmt.Sprintf("CREATE TABLE %s AS ", table))
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("CREATE TABLE %s TABLESPACE %s AS %s", table, quotedTablespace, query), true
+}
+
+// backupTableAsRegexp matches the CREATE TABLE ... AS SELECT backup statement, optionally in the replicated tablespace.
+var backupTableAsRegexp = regexp.MustCompile(`^(CREATE TABLE "(?:[^"]|"")*"\."(?:[^"]|"")*"(?: TABLESPACE "(?:[^"]|"")*")?) AS `)
+
+// setBackupReducedDurability returns the CREATE TABLE ... AS SELECT backup statement creating the backup table without the redo logging,
+// i.e. UNLOGGED on Postgres and NOLOGGING on Oracle. It returns false if the engine or the statement is not supported.
+func setBackupReducedDurability(engine storepb.Engine, backupDatabaseName string, statement base.BackupStatement) (string, bool) {
+	table, err := base.QuoteQualifiedName(engine, backupDatabaseName, statement.TargetTableName)
+	if err != nil {
+		return "", false
+	}
+	if !strings.HasPrefix(statement.Statement, fmt.Sprintf("CREATE TABLE %s ", table)) {
+		return "", false
+	}
+	match := backupTableAsRegexp.FindStringSubmatchIndex(statement.Statement)
+	if match == nil {
+		return "", false
+	}
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		return "CREATE UNLOGGED TABLE " + strings.TrimPrefix(statement.Statement, "CREATE TABLE "), true
+	case storepb.Engine_ORACLE:
+		return statement.Statement[:match[3]] + " NOLOGGING" + statement.Statement[match[3]:], true
+	default:
+		return "", false
+	}
+}
+
+// getBackupDurabilityStatement returns the statement converting the backup table created without the redo logging into a logged table.
+func getBackupDurabilityStatement(engine storepb.Engine, backupDatabaseName, backupTableName string) (string, error) {
+	var format string
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		format = "ALTER TABLE %s SET LOGGED"
+	case storepb.Engine_ORACLE:
+		format = "ALTER TABLE %s LOGGING"
+	default:
+		return "", nil
+	}
+	table, err := base.QuoteQualifiedName(engine, backupDatabaseName, backupTableName)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(format, table), nil
+}
+
+// immutableBackupTableRetentionDays is the number of days that the rows in the immutable backup tables cannot be deleted,
+// and the idle days before the immutable backup tables can be dropped. Oracle requires at least 16 days.
+const immutableBackupTableRetentionDays = 16
+
+// getBackupImmutableUntil returns the time until which the backup table created now cannot be dropped, or nil if it's not immutable.
+func (exec *DataUpdateExecutor) getBackupImmutableUntil(engine storepb.Engine) *timestamppb.Timestamp {
+	if !exec.profile.PriorBackupImmutableTable || engine != storepb.Engine_ORACLE {
+		return nil
+	}
+	return timestamppb.New(exec.now().AddDate(0, 0, immutableBackupTableRetentionDays))
+}
+
+// isBackupImmutable returns whether the backup table of the item cannot be dropped yet.
+func isBackupImmutable(item *storepb.PriorBackupDetail_Item, now time.Time) bool {
+	return item.GetImmutableUntil() != nil && now.Before(item.GetImmutableUntil().AsTime())
+}
+
+// setBackupImmutable returns the Oracle CREATE TABLE ... AS SELECT backup statement creating an immutable backup table.
+func setBackupImmutable(backupDatabaseName string, statement base.BackupStatement) (string, bool) {
+	table, err := base.QuoteQualifiedName(storepb.Engine_ORACLE, backupDatabaseName, statement.TargetTableName)
+	if err != nil {
+		return "", false
+	}
+	rest, ok := strings.CutPrefix(statement.Statement, fmt.Sprintf("CREATE TABLE %s ", table))
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("CREATE IMMUTABLE TABLE %s NO DROP UNTIL %d DAYS IDLE NO DELETE UNTIL %d DAYS AFTER INSERT %s",
+		table, immutableBackupTableRetentionDays, immutableBackupTableRetentionDays, rest), true
+}
+
+// syncBackupSchema syncs the schema of the task database after the backup tables are created by the data update transaction.
+func (exec *DataUpdateExecutor) syncBackupSchema(ctx context.Context, task *store.TaskMessage, detail *storepb.PriorBackupDetail) {
+	database, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{UID: task.DatabaseID})
+	if err != nil || database == nil {
+		slog.Error("failed to get database", slog.Int("task", task.ID), log.BBError(err))
+		return
+	}
+	databases := []*store.DatabaseMessage{database}
+	// The backup tables are in the backup database instead of the task database on MySQL.
+	for _, name := range getBackupTargetDatabases(detail.GetItems()) {
+		if name == common.FormatDatabase(database.InstanceID, database.DatabaseName) {
+			continue
+		}
+		instanceID, databaseName, err := common.GetInstanceDatabaseID(name)
+		if err != nil {
+			slog.Error("failed to parse backup database", slog.String("database", name), log.BBError(err))
+			continue
+		}
+		backupDatabase, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{InstanceID: &instanceID, DatabaseName: &databaseName})
+		if err != nil || backupDatabase == nil {
+			slog.Error("failed to get backup database", slog.String("database", name), log.BBError(err))
+			continue
+		}
+		databases = append(databases, backupDatabase)
+	}
+	for _, database := range databases {
+		if err := exec.schemaSyncer.SyncDatabaseSchema(ctx, database, false /* force */); err != nil {
+			slog.Error("failed to sync backup database schema",
+				slog.String("database", common.FormatDatabase(database.InstanceID, database.DatabaseName)),
+				log.BBError(err),
+			)
+		}
+	}
+}
+
+// getBackupTargetDatabases returns the distinct databases of the backup tables in order.
+func getBackupTargetDatabases(items []*storepb.PriorBackupDetail_Item) []string {
+	var databases []string
+	for _, item := range items {
+		if database := item.GetTargetTable().GetDatabase(); !slices.Contains(databases, database) {
+			databases = append(databases, database)
+		}
+	}
+	return databases
+}
+
+// dropUndoneBackupTables drops the backup tables left empty by the backup deferred to the failed data update transaction.
+// Failing to drop them only leaves the empty tables to the cleanup, since the data update has failed anyway.
+func (exec *DataUpdateExecutor) dropUndoneBackupTables(ctx context.Context, task *store.TaskMessage, detail *storepb.PriorBackupDetail) {
+	instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &task.InstanceID})
+	if err != nil || instance == nil {
+		slog.Warn("failed to get instance", slog.Int("task", task.ID), log.BBError(err))
+		return
+	}
+	statements := getUndoneBackupStatements(instance.Engine, detail.GetItems())
+	if len(statements) == 0 {
+		return
+	}
+	database, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{UID: task.DatabaseID})
+	if err != nil || database == nil {
+		slog.Warn("failed to get database", slog.Int("task", task.ID), log.BBError(err))
+		return
+	}
+	driver, err := exec.dbFactory.GetAdminDatabaseDriver(ctx, instance, database, db.ConnectionContext{})
+	if err != nil {
+		slog.Warn("failed to get database driver", slog.Int("task", task.ID), log.BBError(err))
+		return
+	}
+	defer driver.Close(ctx)
+	for _, statement := range statements {
+		if _, err := driver.Execute(ctx, statement, db.ExecuteOptions{}); err != nil {
+			slog.Warn("failed to drop undone backup table", slog.String("statement", statement), log.BBError(err))
+		}
+	}
+}
+
+// getUndoneBackupStatements returns the statements dropping the backup tables left after the data update transaction
+// with the deferred backup rolled back. Only MySQL creates the tables before the transaction, since its DDL commits implicitly.
+func getUndoneBackupStatements(engine storepb.Engine, items []*storepb.PriorBackupDetail_Item) []string {
+	if engine != storepb.Engine_MYSQL {
+		return nil
+	}
+	var statements []string
+	for _, item := range items {
+		_, databaseName, err := common.GetInstanceDatabaseID(item.GetTargetTable().GetDatabase())
+		if err != nil {
+			continue
+		}
+		statement, err := priorbackup.GetDropBackupTableStatement(engine, databaseName, "", item.GetTargetTable().GetTable())
+		if err != nil {
+			continue
+		}
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+// defaultBackupDropAfter is the delay after the successful data update before the backup tables are dropped by the DROP_AFTER policy.
+const defaultBackupDropAfter = 24 * time.Hour
+
+// getBackupPostSuccessPolicy returns the post-success policy of the backup tables, preferring the policy of the task over the configured one.
+func getBackupPostSuccessPolicy(configured string, policy storepb.PriorBackupPostSuccessPolicy) (storepb.PriorBackupPostSuccessPolicy, error) {
+	if policy != storepb.PriorBackupPostSuccessPolicy_POST_SUCCESS_POLICY_UNSPECIFIED {
+		return policy, nil
+	}
+	if configured == "" {
+		return storepb.PriorBackupPostSuccessPolicy_KEEP, nil
+	}
+	value, ok := storepb.PriorBackupPostSuccessPolicy_value[strings.ToUpper(configured)]
+	if !ok || value == int32(storepb.PriorBackupPostSuccessPolicy_POST_SUCCESS_POLICY_UNSPECIFIED) {
+		return storepb.PriorBackupPostSuccessPolicy_POST_SUCCESS_POLICY_UNSPECIFIED, errors.Errorf("unsupported backup post-success policy %q", configured)
+	}
+	return storepb.PriorBackupPostSuccessPolicy(value), nil
+}
+
+// setBackupDropTime schedules the backup tables to be dropped by the retention job by the post-success policy of the successful data update.
+// The backup tables dropped immediately are also scheduled, so that the retention job drops those the data update fails to drop.
+// The immutable backup tables are scheduled no earlier than they can be dropped, with a warning in the detail.
+// It returns whether the backup tables are dropped immediately.
+func setBackupDropTime(detail *storepb.PriorBackupDetail, dropAfter time.Duration, now time.Time) bool {
+	var dropTime time.Time
+	switch detail.GetPostSuccessPolicy() {
+	case storepb.PriorBackupPostSuccessPolicy_DROP_AFTER:
+		if dropAfter <= 0 {
+			dropAfter = defaultBackupDropAfter
+		}
+		dropTime = now.Add(dropAfter)
+	case storepb.PriorBackupPostSuccessPolicy_DROP_IMMEDIATELY:
+		dropTime = now
+	default:
+		return false
+	}
+	var immutableUntil time.Time
+	for _, item := range detail.GetItems() {
+		if until := item.GetImmutableUntil(); until != nil && until.AsTime().After(immutableUntil) {
+			immutableUntil = until.AsTime()
+		}
+	}
+	if immutableUntil.After(dropTime) {
+		detail.DropTime = timestamppb.New(immutableUntil)
+		detail.Warnings = append(detail.Warnings, fmt.Sprintf("the immutable backup tables are kept until %s by the %s policy", immutableUntil.Format(time.RFC3339), detail.GetPostSuccessPolicy()))
+		return false
+	}
+	detail.DropTime = timestamppb.New(dropTime)
+	return detail.GetPostSuccessPolicy() == storepb.PriorBackupPostSuccessPolicy_DROP_IMMEDIATELY
+}
+
+// applyBackupPostSuccessPolicy applies the post-success policy of the backup tables after the data update succeeds.
+// Failing to drop the backup tables only leaves them to the retention job, since the data update has succeeded anyway.
+func (exec *DataUpdateExecutor) applyBackupPostSuccessPolicy(ctx context.Context, task *store.TaskMessage, detail *storepb.PriorBackupDetail) {
+	if !setBackupDropTime(detail, exec.profile.PriorBackupPostSuccessDropAfter, exec.now()) {
+		return
+	}
+	exec.dropTaskBackupTables(ctx, task, detail)
+}
+
+// dropTaskBackupTables drops the backup tables of the task on its instance and syncs the schemas of the backup databases.
+func (exec *DataUpdateExecutor) dropTaskBackupTables(ctx context.Context, task *store.TaskMessage, detail *storepb.PriorBackupDetail) {
+	instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &task.InstanceID})
+	if err != nil || instance == nil {
+		slog.Warn("failed to get instance", slog.Int("task", task.ID), log.BBError(err))
+		return
+	}
+	database, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{UID: task.DatabaseID})
+	if err != nil || database == nil {
+		slog.Warn("failed to get database", slog.Int("task", task.ID), log.BBError(err))
+		return
+	}
+	driver, err := exec.dbFactory.GetAdminDatabaseDriver(ctx, instance, database, db.ConnectionContext{})
+	if err != nil {
+		slog.Warn("failed to get database driver", slog.Int("task", task.ID), log.BBError(err))
+		return
+	}
+	defer driver.Close(ctx)
+	dropBackupTables(ctx, driver, instance, detail.GetItems(), exec.now())
+	exec.syncBackupSchema(ctx, task, detail)
+}
+
+// dropInterruptedBackupTables drops the backup tables of the prior backup of the task run interrupted before its end.
+func (exec *DataUpdateExecutor) dropInterruptedBackupTables(ctx context.Context, task *store.TaskMessage, taskRunUID int) {
+	logs, err := exec.store.ListTaskRunLogs(ctx, taskRunUID)
+	if err != nil {
+		slog.Warn("failed to list task run logs", slog.Int("taskRun", taskRunUID), log.BBError(err))
+		return
+	}
+	items := getInterruptedBackupItems(logs)
+	if len(items) == 0 {
+		return
+	}
+	slog.Info("drop the backup tables of the interrupted prior backup", slog.Int("task", task.ID), slog.Int("tables", len(items)))
+	detail := &storepb.PriorBackupDetail{Items: items}
+	exec.dropTaskBackupTables(ctx, task, detail)
+	// The end is logged so that the dropped backup tables are not dropped again.
+	exec.logPriorBackupEnd(ctx, taskRunUID, detail, errors.New("the prior backup was interrupted and its backup tables were dropped"))
+}
+
+// getInterruptedBackupItems returns the backup tables logged by the prior backup starts after the last prior backup end,
+// i.e. those of the prior backup interrupted before its end.
+func getInterruptedBackupItems(logs []*store.TaskRunLog) []*storepb.PriorBackupDetail_Item {
+	var items []*storepb.PriorBackupDetail_Item
+	for _, l := range logs {
+		switch l.Payload.GetType() {
+		case storepb.TaskRunLog_PRIOR_BACKUP_START:
+			items = append(items, l.Payload.GetPriorBackupStart().GetItems()...)
+		case storepb.TaskRunLog_PRIOR_BACKUP_END:
+			items = nil
+		default:
+		}
+	}
+	return items
+}
+
+// dropBackupTables drops the backup tables and the after-image tables on the instance. The backup tables on the other instances,
+// e.g. those of the shards, and the immutable backup tables that cannot be dropped yet are left to the retention job.
+func dropBackupTables(ctx context.Context, driver db.Driver, instance *store.InstanceMessage, items []*storepb.PriorBackupDetail_Item, now time.Time) {
+	for _, item := range items {
+		tables := []*storepb.PriorBackupDetail_Item_Table{item.GetTargetTable()}
+		if isBackupImmutable(item, now) {
+			slog.Info("kept the immutable backup table until it can be dropped",
+				slog.String("table", item.GetTargetTable().GetTable()),
+				slog.Time("immutableUntil", item.GetImmutableUntil().AsTime()),
+			)
+			tables = nil
+		}
+		if item.GetAfterImageTable() != nil {
+			tables = append(tables, item.GetAfterImageTable())
+		}
+		for _, table := range tables {
+			// The backups published to the sinks have no backup tables.
+			if table == nil {
+				continue
+			}
+			instanceID, databaseName, err := common.GetInstanceDatabaseID(table.GetDatabase())
+			if err != nil || instanceID != instance.ResourceID {
+				continue
+			}
+			statement, err := priorbackup.GetDropBackupTableStatement(instance.Engine, databaseName, table.GetSchema(), table.GetTable())
+			if err != nil {
+				slog.Warn("failed to get the statement dropping backup table", slog.String("table", table.GetTable()), log.BBError(err))
+				continue
+			}
+			if _, err := driver.Execute(ctx, statement, db.ExecuteOptions{}); err != nil {
+				slog.Warn("failed to drop backup table", slog.String("statement", statement), log.BBError(err))
+			}
+		}
+	}
+}
+
+// getBackupTableCommentStatement returns the statement tagging the backup table with the issue, the tenant namespace,
+// the project and environment labels for governance, the principal initiating the change and the schema version of the task.
+// It returns empty if the engine is not supported or the table comment is disabled in the profile.
+func (exec *DataUpdateExecutor) getBackupTableCommentStatement(engine storepb.Engine, backupDatabaseName, backupSchemaName, backupTableName string, issueUID int, namespace, project, environment, principal, schemaVersion string) (string, error) {
+	if exec.profile.PriorBackupSkipTableComment {
+		return "", nil
+	}
+	// The issue, the namespace and the project and environment labels are parsed by the cleanup tooling and the reconciler,
+	// so they're never truncated.
+	marker := fmt.Sprintf("issue %d", issueUID)
+	if namespace != "" {
+		marker = fmt.Sprintf("%s in namespace %s", marker, namespace)
+	}
+	if project != "" {
+		marker = fmt.Sprintf("%s of project %s", marker, project)
+	}
+	if environment != "" {
+		marker = fmt.Sprintf("%s in environment %s", marker, environment)
+	}
+	var description string
+	if principal != "" {
+		description = fmt.Sprintf("%s by %s", description, principal)
+	}
+	if schemaVersion != "" {
+		description = fmt.Sprintf("%s at schema version %s", description, schemaVersion)
+	}
+	marker = strings.ReplaceAll(truncateBackupTableComment(marker, description, getMaximumTableCommentBytes(engine)), "'", "''")
+	switch engine {
+	case storepb.Engine_TIDB, storepb.Engine_MYSQL:
+		table, err := base.QuoteQualifiedName(engine, backupDatabaseName, backupTableName)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("ALTER TABLE %s COMMENT = '%s'", table, marker), nil
+	case storepb.Engine_MSSQL:
+		// The schema and the table are the string arguments of the procedure.
+		return fmt.Sprintf("EXEC sp_addextendedproperty 'MS_Description', '%s', 'SCHEMA', '%s', 'TABLE', '%s'", marker,
+			strings.ReplaceAll(getMSSQLBackupSchema(backupSchemaName), "'", "''"), strings.ReplaceAll(backupTableName, "'", "''")), nil
+	case storepb.Engine_POSTGRES, storepb.Engine_ORACLE:
+		table, err := base.QuoteQualifiedName(engine, backupDatabaseName, backupTableName)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("COMMENT ON TABLE %s IS '%s'", table, marker), nil
+	default:
+		return "", nil
+	}
+}
+
+// backupTableCommentTruncatedSuffix marks the backup table comments truncated to the engine limits.
+const backupTableCommentTruncatedSuffix = " (truncated)"
+
+// getMaximumTableCommentBytes returns the maximum length in bytes of the table comments on the engine, or zero if it's unlimited.
+func getMaximumTableCommentBytes(engine storepb.Engine) int {
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_TIDB:
+		return 2048
+	case storepb.Engine_ORACLE:
+		return 4000
+	case storepb.Engine_MSSQL:
+		// The extended property value is a sql_variant holding at most 7500 bytes of the varchar literal.
+		return 7500
+	default:
+		return 0
+	}
+}
+
+// truncateBackupTableComment returns the backup table comment of the marker and the description within the maximum bytes.
+// Only the description is truncated on a character boundary and marked as truncated, so the marker stays machine-readable
+// even if it doesn't fit by itself. Zero maximum bytes means unlimited.
+func truncateBackupTableComment(marker, description string, maximumBytes int) string {
+	if maximumBytes == 0 || len(marker)+len(description) <= maximumBytes {
+		return marker + description
+	}
+	end := 0
+	for i := range description {
+		if len(marker)+i+len(backupTableCommentTruncatedSuffix) > maximumBytes {
+			break
+		}
+		end = i
+	}
+	return marker + description[:end] + backupTableCommentTruncatedSuffix
+}
+
+// onlineDDLCheckInterval is the interval of checking whether the online DDL on the backup table has finished.
+const onlineDDLCheckInterval = 5 * time.Second
+
+// maximumOnlineDDLChecks is the number of checks of the online DDL on the backup table before skipping the tag.
+const maximumOnlineDDLChecks = 3
+
+// waitForOnlineDDL checks the online DDL on the backup table up to the number of checks at the interval, and returns
+// the online DDL still in progress after the checks, or empty if there is none.
+func waitForOnlineDDL(ctx context.Context, sqlDB *sql.DB, engine storepb.Engine, backupDatabaseName, backupTableName string, checks int, interval time.Duration) (string, error) {
+	for i := 0; ; i++ {
+		tool, err := GetOnlineDDLInProgress(ctx, sqlDB, engine, backupDatabaseName, backupTableName)
+		if err != nil || tool == "" || i+1 >= checks {
+			return tool, err
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// GetOnlineDDLInProgress returns the online DDL in progress on the table, or empty if there is none or the check is not supported on the engine.
+// On MySQL, gh-ost is detected by its ghost and changelog tables, and pt-online-schema-change by its new table and triggers.
+// On TiDB, the online DDL is native and detected by the unfinished DDL jobs.
+func GetOnlineDDLInProgress(ctx context.Context, sqlDB *sql.DB, engine storepb.Engine, database, table string) (string, error) {
+	switch engine {
+	case storepb.Engine_MYSQL:
+		var name string
+		err := sqlDB.QueryRowContext(ctx, "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME IN (?, ?, ?) LIMIT 1",
+			database, fmt.Sprintf("_%s_gho", table), fmt.Sprintf("_%s_ghc", table), fmt.Sprintf("_%s_new", table)).Scan(&name)
+		switch {
+		case err == nil:
+			if name == fmt.Sprintf("_%s_new", table) {
+				return "pt-online-schema-change", nil
+			}
+			return "gh-ost", nil
+		case !errors.Is(err, sql.ErrNoRows):
+			return "", errors.Wrapf(err, "failed to check gh-ost on table %q", table)
+		}
+		err = sqlDB.QueryRowContext(ctx, `SELECT TRIGGER_NAME FROM information_schema.TRIGGERS WHERE EVENT_OBJECT_SCHEMA = ? AND EVENT_OBJECT_TABLE = ? AND TRIGGER_NAME LIKE 'pt\_osc\_%' LIMIT 1`,
+			database, table).Scan(&name)
+		switch {
+		case err == nil:
+			return "pt-online-schema-change", nil
+		case !errors.Is(err, sql.ErrNoRows):
+			return "", errors.Wrapf(err, "failed to check pt-online-schema-change on table %q", table)
+		}
+		return "", nil
+	case storepb.Engine_TIDB:
+		var jobID int64
+		err := sqlDB.QueryRowContext(ctx, "SELECT JOB_ID FROM information_schema.DDL_JOBS WHERE DB_NAME = ? AND TABLE_NAME = ? AND STATE NOT IN ('synced', 'cancelled', 'rollback done') LIMIT 1",
+			database, table).Scan(&jobID)
+		switch {
+		case err == nil:
+			return fmt.Sprintf("TiDB DDL job %d", jobID), nil
+		case !errors.Is(err, sql.ErrNoRows):
+			return "", errors.Wrapf(err, "failed to check DDL jobs on table %q", table)
+		}
+		return "", nil
+	default:
+		return "", nil
+	}
+}
+
+// GetBackupMaintenanceStatement returns the statement maintaining the source table of the backup statement before the backup copy,
+// or empty if it's not supported on the engine.
+func GetBackupMaintenanceStatement(engine storepb.Engine, sourceDatabase string, statement base.BackupStatement) (string, error) {
+	switch engine {
+	case storepb.Engine_MYSQL:
+		table, err := base.QuoteQualifiedName(engine, sourceDatabase, statement.SourceTableName)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("OPTIMIZE TABLE %s", table), nil
+	case storepb.Engine_POSTGRES:
+		schema := statement.SourceSchema
+		if schema == "" {
+			schema = getDefaultSchema(engine)
+		}
+		table, err := base.QuoteQualifiedName(engine, schema, statement.SourceTableName)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("VACUUM %s", table), nil
+	default:
+		return "", nil
+	}
+}
+
+// maintainBackupSourceTable runs the maintenance of the source table of the backup statement before the backup copy if it's enabled.
+// The maintenance only speeds up the backup reads, so the backup doesn't fail without it.
+func maintainBackupSourceTable(ctx context.Context, driver db.Driver, engine storepb.Engine, enabled bool, sourceDatabase string, statement base.BackupStatement) {
+	if !enabled {
+		return
+	}
+	maintenanceStatement, err := GetBackupMaintenanceStatement(engine, sourceDatabase, statement)
+	if err != nil {
+		slog.Warn("failed to maintain backup source table", slog.String("table", statement.SourceTableName), log.BBError(err))
+		return
+	}
+	if maintenanceStatement == "" {
+		return
+	}
+	slog.Info("maintaining backup source table", slog.String("statement", maintenanceStatement))
+	if _, err := driver.Execute(ctx, maintenanceStatement, db.ExecuteOptions{}); err != nil {
+		slog.Warn("failed to maintain backup source table", slog.String("table", statement.SourceTableName), log.BBError(err))
+	}
+}
+
+// GetBackupSchemaStatement returns the statement creating the schema of the backup tables in the backup database if it doesn't exist,
+// or empty if the backup tables are in the default schema or the engine has no such schemas. Only MSSQL backs up into the schemas
+// matching the source tables.
+func GetBackupSchemaStatement(engine storepb.Engine, schema string) (string, error) {
+	if engine != storepb.Engine_MSSQL || getMSSQLBackupSchema(schema) == "dbo" {
+		return "", nil
+	}
+	name, err := base.QuoteIdentifier(engine, schema)
+	if err != nil {
+		return "", err
+	}
+	// CREATE SCHEMA must be the only statement in its batch.
+	return fmt.Sprintf("IF SCHEMA_ID(N'%s') IS NULL EXEC(N'CREATE SCHEMA %s')", strings.ReplaceAll(schema, "'", "''"), strings.ReplaceAll(name, "'", "''")), nil
+}
+
+// getMSSQLBackupSchema returns the schema of the MSSQL backup table, which is dbo for the backup tables recorded without the schema.
+func getMSSQLBackupSchema(schema string) string {
+	if schema == "" {
+		return "dbo"
+	}
+	return schema
+}
+
+// GetBackupAnalyzeStatement returns the statement updating the optimizer statistics of the backup table,
+// or empty if it's not supported on the engine. The backup schema is only used on MSSQL.
+func GetBackupAnalyzeStatement(engine storepb.Engine, backupDatabaseName, backupSchemaName, backupTableName string) (string, error) {
+	var format string
+	identifiers := []string{backupDatabaseName, backupTableName}
+	switch engine {
+	case storepb.Engine_TIDB, storepb.Engine_MYSQL:
+		format = "ANALYZE TABLE %s"
+	case storepb.Engine_MSSQL:
+		format = "UPDATE STATISTICS %s"
+		identifiers = []string{getMSSQLBackupSchema(backupSchemaName), backupTableName}
+	case storepb.Engine_POSTGRES:
+		format = "ANALYZE %s"
+	case storepb.Engine_ORACLE:
+		// The owner and the table are the string arguments of the procedure.
+		return fmt.Sprintf("BEGIN DBMS_STATS.GATHER_TABLE_STATS('%s', '%s'); END;", strings.ReplaceAll(backupDatabaseName, "'", "''"), strings.ReplaceAll(backupTableName, "'", "''")), nil
+	default:
+		return "", nil
+	}
+	table, err := base.QuoteQualifiedName(engine, identifiers...)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(format, table), nil
+}
+
+// afterImageTableSuffix is the suffix of the after-image table names to the backup table names.
+const afterImageTableSuffix = "_after"
+
+// GetAfterImageStatement returns the name of the after-image table of the backup table and the statement creating it,
+// which copies the backup table and applies the SET clause of the UPDATE to the copy, so that the copy has the new values
+// of the backed up rows. The SET clause refers to the copy by the alias of the UPDATE, and its subqueries read the source
+// tables before the data update. It returns empty if the engine or the statement doesn't support the after-image.
+func GetAfterImageStatement(engine storepb.Engine, backupDatabaseName string, statement base.BackupStatement) (string, string, error) {
+	if engine != storepb.Engine_POSTGRES || statement.UpdateSetClause == "" {
+		return "", "", nil
+	}
+	table, _ := common.TruncateString(statement.TargetTableName, maximumPostgresIdentifierLength-len(afterImageTableSuffix))
+	table += afterImageTableSuffix
+	afterImage, err := base.QuoteQualifiedName(engine, backupDatabaseName, table)
+	if err != nil {
+		return "", "", err
+	}
+	backup, err := base.QuoteQualifiedName(engine, backupDatabaseName, statement.TargetTableName)
+	if err != nil {
+		return "", "", err
+	}
+	alias, err := base.QuoteIdentifier(engine, statement.UpdateAlias)
+	if err != nil {
+		return "", "", err
+	}
+	return table, fmt.Sprintf(`CREATE TABLE %s AS SELECT * FROM %s; UPDATE %s AS %s %s;`, afterImage, backup, afterImage, alias, statement.UpdateSetClause), nil
+}
+
+const (
+	// archiveTablePrefix is the prefix of the archive table names to the source table names.
+	archiveTablePrefix = "archive_"
+	// archiveTimeColumn is the column of the archive tables recording the backup times of the rows, by which the archive tables are partitioned.
+	archiveTimeColumn = "_bb_archived_at"
+	// archivePartitionLayout is the layout of the month in the archive partition names.
+	archivePartitionLayout = "200601"
+)
+
+// supportBackupArchive returns whether the backup tables can be archived into the partitioned archive tables.
+func supportBackupArchive(engine storepb.Engine) bool {
+	return engine == storepb.Engine_POSTGRES || engine == storepb.Engine_MYSQL
+}
+
+// GetArchivePartition returns the name of the monthly archive partition containing the backup time, and the start and end times of the month in UTC.
+func GetArchivePartition(t time.Time) (string, time.Time, time.Time) {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return "p" + start.Format(archivePartitionLayout), start, start.AddDate(0, 1, 0)
+}
+
+// GetArchiveTableName returns the name of the archive table of the source table of the backup statement, qualified by the source schema
+// on Postgres, where the backup database is a schema of the source database, and by the source database otherwise, where the backup
+// database is shared by the source databases. The name is truncated so that the partition tables on Postgres fit in the identifier length.
+func GetArchiveTableName(engine storepb.Engine, sourceDatabase string, statement base.BackupStatement) string {
+	qualifier := sourceDatabase
+	if engine == storepb.Engine_POSTGRES {
+		qualifier = statement.SourceSchema
+	}
+	name := archiveTablePrefix + statement.SourceTableName
+	if qualifier != "" {
+		name = archiveTablePrefix + qualifier + "_" + statement.SourceTableName
+	}
+	name, _ = common.TruncateString(name, maximumPostgresIdentifierLength-len("_p")-len(archivePartitionLayout))
+	return name
+}
+
+// GetArchiveStatements returns the statements appending the rows of the backup table to the monthly partition of the archive table
+// at the backup time, creating the archive table partitioned by the backup times and the partition if needed. The archive table has
+// the columns of the backup table followed by the backup time column on Postgres, and preceded by it on MySQL. The partitions are
+// the partition tables named by the archive table and the partition on Postgres. The existing partitions of the archive table are
+// required on MySQL to add the partition only if missing, and empty if the archive table doesn't exist.
+func GetArchiveStatements(engine storepb.Engine, backupDatabaseName, backupTableName, archiveTableName string, partitions []string, now time.Time) ([]string, string, error) {
+	partition, start, end := GetArchivePartition(now)
+	backupTable, err := base.QuoteQualifiedName(engine, backupDatabaseName, backupTableName)
+	if err != nil {
+		return nil, "", err
+	}
+	archiveTable, err := base.QuoteQualifiedName(engine, backupDatabaseName, archiveTableName)
+	if err != nil {
+		return nil, "", err
+	}
+	timeColumn, err := base.QuoteIdentifier(engine, archiveTimeColumn)
+	if err != nil {
+		return nil, "", err
+	}
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		partitionTableName := archiveTableName + "_" + partition
+		partitionTable, err := base.QuoteQualifiedName(engine, backupDatabaseName, partitionTableName)
+		if err != nil {
+			return nil, "", err
+		}
+		return []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (LIKE %s, %s timestamptz NOT NULL) PARTITION BY RANGE (%s);`,
+				archiveTable, backupTable, timeColumn, timeColumn),
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s');`,
+				partitionTable, archiveTable, start.Format(time.RFC3339), end.Format(time.RFC3339)),
+			fmt.Sprintf(`INSERT INTO %s SELECT *, '%s'::timestamptz FROM %s;`,
+				archiveTable, now.UTC().Format(time.RFC3339Nano), backupTable),
+		}, partitionTableName, nil
+	case storepb.Engine_MYSQL:
+		const layout = "2006-01-02 15:04:05"
+		quotedPartition, err := base.QuoteIdentifier(engine, partition)
+		if err != nil {
+			return nil, "", err
+		}
+		quotedBackupTableName, err := base.QuoteIdentifier(engine, backupTableName)
+		if err != nil {
+			return nil, "", err
+		}
+		partitionDefinition := fmt.Sprintf("PARTITION %s VALUES LESS THAN ('%s')", quotedPartition, end.Format(layout))
+		statements := []string{
+			// The columns selected by CREATE TABLE ... SELECT follow the defined columns.
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s DATETIME(6) NOT NULL) PARTITION BY RANGE COLUMNS(%s) (%s) SELECT * FROM %s WHERE FALSE;",
+				archiveTable, timeColumn, timeColumn, partitionDefinition, backupTable),
+		}
+		if len(partitions) > 0 && !slices.Contains(partitions, partition) {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD PARTITION (%s);", archiveTable, partitionDefinition))
+		}
+		statements = append(statements, fmt.Sprintf("INSERT INTO %s SELECT '%s', %s.* FROM %s;",
+			archiveTable, now.UTC().Format("2006-01-02 15:04:05.000000"), quotedBackupTableName, backupTable))
+		return statements, partition, nil
+	default:
+		return nil, "", errors.Errorf("backup archive is not supported for engine %s", engine)
+	}
+}
+
+// archiveBackupTable appends the rows of the backup table to the partition of the archive table at the backup time,
+// and returns the archive partition recorded in the prior backup item.
+func archiveBackupTable(ctx context.Context, driver db.Driver, engine storepb.Engine, sourceDatabase, targetDatabaseName, backupDatabaseName string, statement base.BackupStatement, sessionRole string, now time.Time) (*storepb.PriorBackupDetail_Item_ArchivePartition, error) {
+	archiveTableName := GetArchiveTableName(engine, sourceDatabase, statement)
+	var partitions []string
+	if engine == storepb.Engine_MYSQL {
+		var err error
+		partitions, err = priorbackup.GetArchivePartitions(ctx, driver.GetDB(), backupDatabaseName, archiveTableName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get partitions of archive table %q", archiveTableName)
+		}
+	}
+	statements, partition, err := GetArchiveStatements(engine, backupDatabaseName, statement.TargetTableName, archiveTableName, partitions, now)
+	if err != nil {
+		return nil, err
+	}
+	archiveStatement, executeOptions := withBackupSessionRole(engine, sessionRole, strings.Join(statements, "\n"), db.ExecuteOptions{})
+	if _, err := driver.Execute(ctx, archiveStatement, executeOptions); err != nil {
+		return nil, errors.Wrapf(err, "failed to append to archive table %q", archiveTableName)
+	}
+	_, start, end := GetArchivePartition(now)
+	return &storepb.PriorBackupDetail_Item_ArchivePartition{
+		Table: &storepb.PriorBackupDetail_Item_Table{
+			Database: targetDatabaseName,
+			Table:    archiveTableName,
+		},
+		Partition: partition,
+		StartTime: timestamppb.New(start),
+		EndTime:   timestamppb.New(end),
+	}, nil
+}
+
+// backupEncryptionKeyRegexp matches the KMS key references, e.g. key ids, aliases and ARNs.
+var backupEncryptionKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9/_:.\-]{0,255}$`)
+
+// validateBackupEncryptionKey validates the reference to the KMS key encrypting the backup tables on the engine.
+func validateBackupEncryptionKey(engine storepb.Engine, key string) error {
+	if !backupEncryptionKeyRegexp.MatchString(key) {
+		return errors.Errorf("invalid backup encryption key %q", key)
+	}
+	switch engine {
+	case storepb.Engine_MYSQL:
+		return nil
+	default:
+		return errors.Errorf("backup encryption is not supported for engine %s", engine)
+	}
+}
+
+// backupKeyringAWS is the MySQL keyring plugin whose master key is a KMS key.
+const backupKeyringAWS = "keyring_aws"
+
+// checkBackupEncryptionKey checks that the referenced key is the master key of the server keyring.
+// MySQL encrypts the tables by the master key of the keyring, so the key cannot be chosen per table.
+// Only the keyring_aws plugin binds the master key to a KMS key, so the other keyrings such as keyring_file
+// and the KMIP keyring_okv cannot be checked against the key references and are rejected.
+func checkBackupEncryptionKey(ctx context.Context, sqlDB *sql.DB, engine storepb.Engine, key string) error {
+	if engine != storepb.Engine_MYSQL {
+		return errors.Errorf("backup encryption is not supported for engine %s", engine)
+	}
+	keyring, err := getBackupKeyring(ctx, sqlDB)
+	if err != nil {
+		return err
+	}
+	if err := checkBackupKeyring(keyring); err != nil {
+		return err
+	}
+	var masterKey sql.NullString
+	if err := sqlDB.QueryRowContext(ctx, "SELECT @@GLOBAL.keyring_aws_cmk_id").Scan(&masterKey); err != nil {
+		return errors.Wrap(err, "failed to get the keyring master key")
+	}
+	if masterKey.String != key {
+		return errors.Errorf("backup encryption key %q mismatches the keyring master key %q", key, masterKey.String)
+	}
+	return nil
+}
+
+// getBackupKeyring returns the keyring in use on the server, i.e. the active keyring plugin,
+// or the keyring component on MySQL 8.0.24 and later. Empty means no keyring is in use.
+func getBackupKeyring(ctx context.Context, sqlDB *sql.DB) (string, error) {
+	var plugin sql.NullString
+	err := sqlDB.QueryRowContext(ctx, "SELECT PLUGIN_NAME FROM INFORMATION_SCHEMA.PLUGINS WHERE PLUGIN_NAME LIKE 'keyring%' AND PLUGIN_STATUS = 'ACTIVE' LIMIT 1").Scan(&plugin)
+	if err == nil {
+		return plugin.String, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", errors.Wrap(err, "failed to get the keyring plugin")
+	}
+	var component sql.NullString
+	// The keyring component status table doesn't exist before MySQL 8.0.24.
+	if err := sqlDB.QueryRowContext(ctx, "SELECT STATUS_VALUE FROM performance_schema.keyring_component_status WHERE STATUS_KEY = 'Component_name'").Scan(&component); err != nil {
+		return "", nil
+	}
+	return component.String, nil
+}
+
+// checkBackupKeyring returns an error if the keyring cannot encrypt the backup tables by the referenced KMS key.
+func checkBackupKeyring(keyring string) error {
+	switch keyring {
+	case backupKeyringAWS:
+		return nil
+	case "":
+		return errors.New("backup encryption requires a keyring, but no keyring is installed")
+	default:
+		return errors.Errorf("backup encryption key references are only supported by the %s keyring plugin binding the master key to the KMS key, but the server uses %s", backupKeyringAWS, keyring)
+	}
+}
+
+// getBackupEncryptionStatement returns the statement encrypting the backup table.
+func getBackupEncryptionStatement(engine storepb.Engine, backupDatabaseName, backupTableName string) (string, error) {
+	switch engine {
+	case storepb.Engine_MYSQL:
+		table, err := base.QuoteQualifiedName(engine, backupDatabaseName, backupTableName)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("ALTER TABLE %s ENCRYPTION = 'Y'", table), nil
+	default:
+		return "", nil
+	}
+}
+
+// getBackupResourceGroup returns the resource control group of the backup sessions.
+// The group requested by the task overrides the configured one, and it's empty on the engines without resource control.
+func getBackupResourceGroup(engine storepb.Engine, configured, requested string) string {
+	if engine != storepb.Engine_TIDB {
+		return ""
+	}
+	if requested != "" {
+		return requested
+	}
+	return configured
+}
+
+// getBackupSessionRole returns the database role of the backup sessions.
+// The role requested by the task overrides the configured one, and it's empty on the engines without session role switching.
+func getBackupSessionRole(engine storepb.Engine, configured, requested string) string {
+	switch engine {
+	case storepb.Engine_POSTGRES, storepb.Engine_MSSQL:
+	default:
+		return ""
+	}
+	if requested != "" {
+		return requested
+	}
+	return configured
+}
+
+// withBackupSessionRole returns the statement and the execute options running the statement under the session role,
+// which is reset after the statement. On Postgres the role is set for the transaction of the statement by SET LOCAL ROLE,
+// and on MSSQL the statement is wrapped in EXECUTE AS USER and REVERT.
+func withBackupSessionRole(engine storepb.Engine, role, statement string, opts db.ExecuteOptions) (string, db.ExecuteOptions) {
+	if role == "" {
+		return statement, opts
+	}
+	switch engine {
+	case storepb.Engine_POSTGRES:
+		opts.PreludeStatements = append(opts.PreludeStatements, fmt.Sprintf(`SET LOCAL ROLE "%s"`, strings.ReplaceAll(role, `"`, `""`)))
+		return statement, opts
+	case storepb.Engine_MSSQL:
+		return fmt.Sprintf("EXECUTE AS USER = N'%s';\n%s\nREVERT;", strings.ReplaceAll(role, "'", "''"), statement), opts
+	default:
+		return statement, opts
+	}
+}
+
+// backupIsolationLevels are the isolation levels that can be configured for the backup statements.
+var backupIsolationLevels = map[string]sql.IsolationLevel{
+	"READ UNCOMMITTED": sql.LevelReadUncommitted,
+	"READ COMMITTED":   sql.LevelReadCommitted,
+	"REPEATABLE READ":  sql.LevelRepeatableRead,
+	"SERIALIZABLE":     sql.LevelSerializable,
+}
+
+const (
+	// backupSavepointKeep commits the deferred backup if the data update fails after the savepoint.
+	backupSavepointKeep = "KEEP_BACKUP"
+	// backupSavepointUndo rolls back the deferred backup with the failed data update.
+	backupSavepointUndo = "UNDO_BACKUP"
+)
+
+// getBackupSavepointPolicy returns how the failed data update treats the backup deferred to its transaction by the configured policy.
+func getBackupSavepointPolicy(configured string) (db.PreludeRollbackPolicy, error) {
+	switch strings.ToUpper(configured) {
+	case "", backupSavepointUndo:
+		return db.PreludeRollbackAll, nil
+	case backupSavepointKeep:
+		return db.PreludeRollbackKeep, nil
+	default:
+		return db.PreludeRollbackAll, errors.Errorf("unsupported backup savepoint policy %q", configured)
+	}
+}
+
+// supportBackupSavepoint returns whether the backup can be deferred to the data update transaction followed by a savepoint.
+// The savepoints on MySQL require the transactional storage engines such as InnoDB.
+func supportBackupSavepoint(engine storepb.Engine) bool {
+	return engine == storepb.Engine_POSTGRES || engine == storepb.Engine_MYSQL
+}
+
+// checkDeferredBackupStatement returns the error if the data update statement cannot run the backup statements deferred to its transaction,
+// i.e. the empty statement on MySQL, which runs no transaction, and the PL/pgSQL block on Postgres, which runs outside of the transaction.
+func checkDeferredBackupStatement(engine storepb.Engine, statement string) error {
+	statements, err := base.SplitMultiSQL(engine, statement)
+	if err != nil {
+		return errors.Wrap(err, "failed to split data update statement")
+	}
+	var commands []string
+	for _, statement := range statements {
+		if !statement.Empty {
+			commands = append(commands, statement.Text)
+		}
+	}
+	switch engine {
+	case storepb.Engine_MYSQL:
+		if len(commands) == 0 {
+			return errors.New("the prior backup cannot be deferred to the empty data update statement")
+		}
+	case storepb.Engine_POSTGRES:
+		if len(commands) == 1 && pgdriver.IsPlSQLBlock(commands[0]) {
+			return errors.New("the prior backup cannot be deferred to the PL/pgSQL block, which runs outside of the data update transaction")
+		}
+	}
+	return nil
+}
+
+// splitDeferredBackupStatements splits the backup statements deferred to the data update transaction into the statements
+// executed before the transaction and those deferred. The DDL statements commit the transaction implicitly on MySQL,
+// so only the INSERT statements are deferred, the same as the backup snapshot.
+func splitDeferredBackupStatements(engine storepb.Engine, statements ...string) ([]string, []string) {
+	var immediate, deferred []string
+	for _, statement := range statements {
+		if statement == "" {
+			continue
+		}
+		if engine != storepb.Engine_MYSQL {
+			deferred = append(deferred, statement)
+			continue
+		}
+		for _, part := range strings.Split(statement, ";\n") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			if strings.HasPrefix(strings.ToUpper(part), "INSERT ") {
+				deferred = append(deferred, part)
+			} else {
+				immediate = append(immediate, part)
+			}
+		}
+	}
+	return immediate, deferred
+}
+
+// getBackupIsolationLevel returns the isolation level for the backup statements.
+// It defaults to a consistent snapshot on the engines supporting it, and sql.LevelDefault for the other engines.
+func getBackupIsolationLevel(engine storepb.Engine, configured string) (sql.IsolationLevel, error) {
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_TIDB, storepb.Engine_POSTGRES:
+	default:
+		return sql.LevelDefault, nil
+	}
+	if configured == "" {
+		return sql.LevelRepeatableRead, nil
+	}
+	level, ok := backupIsolationLevels[strings.ToUpper(configured)]
+	if !ok {
+		return sql.LevelDefault, errors.Errorf("unsupported backup isolation level %q", configured)
+	}
+	return level, nil
+}
+
+const (
+	// backupSizeHistoryLimit is the maximum number of recent backups of a source table compared with the current backup.
+	backupSizeHistoryLimit = 20
+	// minimumBackupSizeHistory is the minimum number of recent backups of a source table to detect the size anomalies.
+	minimumBackupSizeHistory = 3
+)
+
+// getBackupSizeAnomalies returns the warnings about the prior backup tables whose sizes deviate from the recent backups of the same source tables.
+// Failing to read the history only skips the detection, since the warnings are advisory.
+func (exec *DataUpdateExecutor) getBackupSizeAnomalies(ctx context.Context, detail *storepb.PriorBackupDetail) []string {
+	var warnings []string
+	for _, item := range detail.GetItems() {
+		if item.RowCount == nil {
+			continue
+		}
+		history, err := exec.store.ListPriorBackupRowCounts(ctx, item.GetSourceTable(), backupSizeHistoryLimit)
+		if err != nil {
+			slog.Warn("failed to list prior backup row counts", slog.String("table", item.GetSourceTable().GetTable()), log.BBError(err))
+			continue
+		}
+		table := item.GetSourceTable().GetTable()
+		if schema := item.GetSourceTable().GetSchema(); schema != "" {
+			table = fmt.Sprintf("%s.%s", schema, table)
+		}
+		if warning := getBackupSizeAnomaly(table, item.GetRowCount(), history, exec.profile.PriorBackupSizeAnomalyFactor); warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+// ListStagePriorBackupItems lists the prior backup items created by the tasks of the stage, so that they can be restored together.
+// Format of the stage: projects/{project}/rollouts/{rollout}/stages/{stage}
+func ListStagePriorBackupItems(ctx context.Context, stores *store.Store, stage string) ([]*storepb.PriorBackupDetail_Item, error) {
+	projectID, pipelineID, stageID, err := common.GetProjectIDRolloutIDMaybeStageID(stage)
+	if err != nil {
+		return nil, err
+	}
+	if stageID == nil {
+		return nil, errors.Errorf("invalid stage %q", stage)
+	}
+	details, err := stores.ListPriorBackupDetails(ctx, &store.FindPriorBackupDetailMessage{
+		ProjectID:  &projectID,
+		PipelineID: &pipelineID,
+		StageID:    stageID,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list prior backup details of stage %q", stage)
+	}
+	return getStagePriorBackupItems(details, stage), nil
+}
+
+// getStagePriorBackupItems returns the items of the prior backup details of the stage in the order of the details.
+func getStagePriorBackupItems(details []*storepb.PriorBackupDetail, stage string) []*storepb.PriorBackupDetail_Item {
+	var items []*storepb.PriorBackupDetail_Item
+	for _, detail := range details {
+		if detail.GetStage() != stage {
+			continue
+		}
+		items = append(items, detail.GetItems()...)
+	}
+	return items
+}
+
+// getBackupSizeAnomaly returns the warning if the backed up rows are more than factor times or less than one over factor of
+// the median of the history, or empty if the size is normal or the history is too short to tell.
+func getBackupSizeAnomaly(table string, rows int64, history []int64, factor float64) string {
+	if factor <= 0 || len(history) < minimumBackupSizeHistory {
+		return ""
+	}
+	sorted := slices.Clone(history)
+	slices.Sort(sorted)
+	median := float64(sorted[len(sorted)/2])
+	if len(sorted)%2 == 0 {
+		median = float64(sorted[len(sorted)/2-1]+sorted[len(sorted)/2]) / 2
+	}
+	switch {
+	// The median is floored at one row to avoid flagging every non-empty backup of the tables that are usually not backed up at all.
+	case float64(rows) > max(median, 1)*factor:
+		return fmt.Sprintf("the prior backup of %s has %d rows, more than %g times the median %g of the recent %d backups", table, rows, factor, max(median, 1), len(history))
+	case float64(rows) < median/factor:
+		return fmt.Sprintf("the prior backup of %s has %d rows, less than 1/%g of the median %g of the recent %d backups", table, rows, factor, median, len(history))
+	}
+	return ""
+}
+
+// maximumExplainStatements is the maximum number of statements whose plans are captured in one task.
+const maximumExplainStatements = 100
+
+// captureExplainPlans returns the EXPLAIN plans of the statements before they are executed.
+// It returns nil if the engine doesn't support EXPLAIN.
+func (exec *DataUpdateExecutor) captureExplainPlans(ctx context.Context, driverCtx context.Context, statement string, task *store.TaskMessage) ([]string, error) {
+	instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &task.InstanceID})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get instance")
+	}
+	if getExplainStatement(instance.Engine, "") == "" {
+		return nil, nil
+	}
+	database, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{UID: task.DatabaseID})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get database")
+	}
+
+	list, err := base.SplitMultiSQL(instance.Engine, statement)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to split statement")
+	}
+	list = base.FilterEmptySQL(list)
+	if len(list) > maximumExplainStatements {
+		list = list[:maximumExplainStatements]
+	}
+
+	driver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, database, db.ConnectionContext{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get database driver")
+	}
+	defer driver.Close(driverCtx)
+
+	var plans []string
+	for _, item := range list {
+		plan, err := queryExplainPlan(driverCtx, driver.GetDB(), getExplainStatement(instance.Engine, item.Text))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to explain statement %q", item.Text)
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// maximumUncoveredRows is the maximum number of the uncovered rows reported per backup statement.
+const maximumUncoveredRows = 10
+
+// verifyBackupCoverage returns the warnings of the rows modified by the data update but missing from the prior backup tables of the
+// task database, which are found by evaluating the predicates of the UPDATE statements again after the data update and matching
+// the rows with the backup rows by the primary keys. Only the UPDATE statements without FROM on Postgres are verified. The rows no
+// longer matching the predicates after the update, e.g. because the predicates depend on the updated columns, are not verified.
+func (exec *DataUpdateExecutor) verifyBackupCoverage(ctx context.Context, driverCtx context.Context, statement string, task *store.TaskMessage, detail *storepb.PriorBackupDetail) ([]string, error) {
+	instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &task.InstanceID})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get instance")
+	}
+	if instance.Engine != storepb.Engine_POSTGRES {
+		return nil, nil
+	}
+	database, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{UID: task.DatabaseID})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get database")
+	}
+	dbSchema, err := exec.store.GetDBSchema(ctx, database.UID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get database schema")
+	}
+	if dbSchema == nil {
+		return nil, nil
+	}
+
+	// The backup tables are grouped by the backup statements, which may be split into parts.
+	sourceDatabase := common.FormatDatabase(instance.ResourceID, database.DatabaseName)
+	backupTables := make(map[string][]string)
+	backupSchema := ""
+	for _, item := range detail.GetItems() {
+		if item.GetSourceTable().GetDatabase() != sourceDatabase || item.GetSink() != nil {
+			continue
+		}
+		_, schema, err := common.GetInstanceDatabaseID(item.GetTargetTable().GetDatabase())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse backup database")
+		}
+		backupSchema = schema
+		key := getBackupCoverageKey(item.GetSourceTable().GetTable(), item.GetStartPosition())
+		backupTables[key] = append(backupTables[key], item.GetTargetTable().GetTable())
+	}
+	if len(backupTables) == 0 {
+		return nil, nil
+	}
+
+	// The backup statements are only generated for the predicates and never executed.
+	tc := base.TransformContext{
+		InstanceID:              instance.ResourceID,
+		GetDatabaseMetadataFunc: BuildGetDatabaseMetadataFunc(exec.store),
+		AfterImage:              true,
+	}
+	statements, err := base.TransformDMLToSelect(ctx, instance.Engine, tc, statement, database.DatabaseName, backupSchema, "_bbcoverage")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to transform DML to select")
+	}
+	driver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, database, db.ConnectionContext{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get database driver")
+	}
+	defer driver.Close(driverCtx)
+
+	var warnings []string
+	for _, backupStatement := range statements {
+		// The UPDATE statements joining other tables or in CTEs have no SET clause of the backed up rows alone.
+		if backupStatement.UpdateSetClause == "" {
+			continue
+		}
+		tables := backupTables[getBackupCoverageKey(backupStatement.SourceTableName, backupStatement.StartPosition)]
+		if len(tables) == 0 {
+			continue
+		}
+		key := getPrimaryKeyColumns(findBackupSourceTable(instance.Engine, backupStatement, dbSchema.GetMetadata()))
+		query, ok := GetBackupCoverageQuery(instance.Engine, backupStatement, backupSchema, tables, key)
+		if !ok {
+			continue
+		}
+		uncovered, err := queryUncoveredRows(driverCtx, driver.GetDB(), query)
+		if err != nil {
+			return warnings, errors.Wrapf(err, "failed to verify backup coverage of table %q", backupStatement.SourceTableName)
+		}
+		if len(uncovered) > 0 {
+			warnings = append(warnings, fmt.Sprintf("rows of table %q modified by the statement at line %d are missing from the prior backup, e.g. %s",
+				backupStatement.SourceTableName, backupStatement.StartPosition.GetLine(), strings.Join(uncovered, ", ")))
+		}
+	}
+	return warnings, nil
+}
+
+// getBackupCoverageKey returns the key of the backup statement by its source table and position.
+func getBackupCoverageKey(table string, position *storepb.Position) string {
+	return fmt.Sprintf("%s:%d:%d", table, position.GetLine(), position.GetColumn())
+}
+
+// getPrimaryKeyColumns returns the columns of the primary key of the table, or nil if it has none.
+func getPrimaryKeyColumns(table *storepb.TableMetadata) []string {
+	for _, index := range table.GetIndexes() {
+		if index.GetPrimary() {
+			return index.GetExpressions()
+		}
+	}
+	return nil
+}
+
+// GetBackupCoverageQuery returns the query of the primary keys of the rows selected by the backup statement but missing from
+// the backup tables, or false if it's not supported. Only Postgres is supported.
+func GetBackupCoverageQuery(engine storepb.Engine, statement base.BackupStatement, backupSchema string, backupTables []string, key []string) (string, bool) {
+	if engine != storepb.Engine_POSTGRES || len(key) == 0 || len(backupTables) == 0 {
+		return "", false
+	}
+	targetTable, err := base.QuoteQualifiedName(engine, backupSchema, statement.TargetTableName)
+	if err != nil {
+		return "", false
+	}
+	query, ok := strings.CutPrefix(statement.Statement, fmt.Sprintf(`CREATE TABLE %s AS `, targetTable))
+	if !ok {
+		return "", false
+	}
+	query = strings.TrimSuffix(query, ";")
+	var keyColumns, conditions []string
+	for _, column := range key {
+		quoted, err := base.QuoteIdentifier(engine, column)
+		if err != nil {
+			return "", false
+		}
+		keyColumns = append(keyColumns, fmt.Sprintf("bb_modified.%s", quoted))
+		conditions = append(conditions, fmt.Sprintf("bb_backup.%s = bb_modified.%s", quoted, quoted))
+	}
+	var missing []string
+	for _, table := range backupTables {
+		backupTable, err := base.QuoteQualifiedName(engine, backupSchema, table)
+		if err != nil {
+			return "", false
+		}
+		missing = append(missing, fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s AS bb_backup WHERE %s)", backupTable, strings.Join(conditions, " AND ")))
+	}
+	return fmt.Sprintf("SELECT ROW(%s)::text FROM (%s) AS bb_modified WHERE %s LIMIT %d", strings.Join(keyColumns, ", "), query, strings.Join(missing, " AND "), maximumUncoveredRows), true
+}
+
+// queryUncoveredRows returns the keys of the uncovered rows returned by the coverage query.
+func queryUncoveredRows(ctx context.Context, sqlDB *sql.DB, query string) ([]string, error) {
+	rows, err := sqlDB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		result = append(result, key)
+	}
+	return result, rows.Err()
+}
+
+// getExplainStatement returns the engine-appropriate EXPLAIN statement for the statement.
+// It returns empty if the engine doesn't support EXPLAIN. The statement is never executed by the returned EXPLAIN.
+func getExplainStatement(engine storepb.Engine, statement string) string {
+	statement = strings.TrimRight(strings.TrimSpace(statement), ";")
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_MARIADB:
+		return fmt.Sprintf("EXPLAIN FORMAT=JSON %s", statement)
+	case storepb.Engine_TIDB:
+		return fmt.Sprintf("EXPLAIN %s", statement)
+	case storepb.Engine_POSTGRES:
+		return fmt.Sprintf("EXPLAIN (FORMAT JSON) %s", statement)
+	default:
+		return ""
+	}
+}
+
+// queryExplainPlan runs the EXPLAIN statement and returns the plan with one line per row and tab-separated columns.
+func queryExplainPlan(ctx context.Context, sqlDB *sql.DB, explainStatement string) (string, error) {
+	rows, err := sqlDB.QueryContext(ctx, explainStatement)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		scanArgs := make([]any, len(columns))
+		for i := range values {
+			scanArgs[i] = &values[i]
+		}
+		if err := rows.Scan(scanArgs...); err != nil {
+			return "", err
+		}
+		var fields []string
+		for _, value := range values {
+			fields = append(fields, value.String)
+		}
+		lines = append(lines, strings.Join(fields, "\t"))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
 }
 
 func BuildGetDatabaseMetadataFunc(storeInstance *store.Store) base.GetDatabaseMetadataFunc {
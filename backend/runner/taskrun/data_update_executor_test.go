@@ -0,0 +1,2954 @@
+package taskrun
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/bytebase/bytebase/backend/common"
+	"github.com/bytebase/bytebase/backend/component/config"
+	"github.com/bytebase/bytebase/backend/component/state"
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	"github.com/bytebase/bytebase/backend/store"
+	"github.com/bytebase/bytebase/backend/store/model"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func TestGetBackupIsolationLevel(t *testing.T) {
+	tests := []struct {
+		engine     storepb.Engine
+		configured string
+		want       sql.IsolationLevel
+		wantErr    bool
+	}{
+		{
+			engine: storepb.Engine_MYSQL,
+			want:   sql.LevelRepeatableRead,
+		},
+		{
+			engine: storepb.Engine_POSTGRES,
+			want:   sql.LevelRepeatableRead,
+		},
+		{
+			engine:     storepb.Engine_MYSQL,
+			configured: "READ COMMITTED",
+			want:       sql.LevelReadCommitted,
+		},
+		{
+			engine:     storepb.Engine_POSTGRES,
+			configured: "serializable",
+			want:       sql.LevelSerializable,
+		},
+		{
+			engine:     storepb.Engine_POSTGRES,
+			configured: "SNAPSHOT",
+			wantErr:    true,
+		},
+		{
+			engine:     storepb.Engine_MSSQL,
+			configured: "SERIALIZABLE",
+			want:       sql.LevelDefault,
+		},
+	}
+
+	a := require.New(t)
+	for _, test := range tests {
+		got, err := getBackupIsolationLevel(test.engine, test.configured)
+		if test.wantErr {
+			a.Error(err)
+			continue
+		}
+		a.NoError(err)
+		a.Equal(test.want, got)
+	}
+}
+
+func TestGetBackupTargets(t *testing.T) {
+	a := require.New(t)
+	targets, err := getBackupTargets("instances/shard0/databases/db", &storepb.PreUpdateBackupDetail{
+		Database: "instances/shard0/databases/bbdataarchive",
+		ShardDatabases: []string{
+			"instances/shard1/databases/db",
+			"instances/shard2/databases/db",
+			"instances/shard1/databases/db",
+		},
+	})
+	a.NoError(err)
+	a.Equal([]*backupTarget{
+		{
+			source: "instances/shard0/databases/db",
+			target: "instances/shard0/databases/bbdataarchive",
+		},
+		{
+			source: "instances/shard1/databases/db",
+			target: "instances/shard1/databases/bbdataarchive",
+		},
+		{
+			source: "instances/shard2/databases/db",
+			target: "instances/shard2/databases/bbdataarchive",
+		},
+	}, targets)
+
+	_, err = getBackupTargets("instances/shard0/databases/db", &storepb.PreUpdateBackupDetail{
+		Database:       "instances/shard0/databases/bbdataarchive",
+		ShardDatabases: []string{"shard1/db"},
+	})
+	a.Error(err)
+}
+
+func TestGetBackupTableCommentStatement(t *testing.T) {
+	a := require.New(t)
+	engines := []storepb.Engine{
+		storepb.Engine_MYSQL,
+		storepb.Engine_TIDB,
+		storepb.Engine_MSSQL,
+		storepb.Engine_POSTGRES,
+		storepb.Engine_ORACLE,
+	}
+
+	exec := &DataUpdateExecutor{profile: &config.Profile{}}
+	tests := []struct {
+		engine                                                    storepb.Engine
+		database, table                                           string
+		namespace, project, environment, principal, schemaVersion string
+		want                                                      string
+	}{
+		{engine: storepb.Engine_MYSQL, database: "bbdataarchive", table: "_20240101000000_0_t", want: "ALTER TABLE `bbdataarchive`.`_20240101000000_0_t` COMMENT = 'issue 1'"},
+		{engine: storepb.Engine_POSTGRES, database: "bbdataarchive", table: "_20240101000000_0_t", want: `COMMENT ON TABLE "bbdataarchive"."_20240101000000_0_t" IS 'issue 1'`},
+		// The principal initiating the change is captured in the marker.
+		{engine: storepb.Engine_MYSQL, database: "bbdataarchive", table: "_20240101000000_0_t", principal: "alice@example.com", want: "ALTER TABLE `bbdataarchive`.`_20240101000000_0_t` COMMENT = 'issue 1 by alice@example.com'"},
+		{engine: storepb.Engine_POSTGRES, database: "bbdataarchive", table: "_20240101000000_0_t", principal: "o'brien@example.com", want: `COMMENT ON TABLE "bbdataarchive"."_20240101000000_0_t" IS 'issue 1 by o''brien@example.com'`},
+		// The tenant namespace is captured in the marker.
+		{engine: storepb.Engine_POSTGRES, database: "bbdataarchive", table: "acme_20240101000000_0_t", namespace: "acme", principal: "alice@example.com", want: `COMMENT ON TABLE "bbdataarchive"."acme_20240101000000_0_t" IS 'issue 1 in namespace acme by alice@example.com'`},
+		// The project and environment labels are captured in the marker.
+		{engine: storepb.Engine_MYSQL, database: "bbdataarchive", table: "acme_20240101000000_0_t", namespace: "acme", project: "payments", environment: "prod", principal: "alice@example.com", want: "ALTER TABLE `bbdataarchive`.`acme_20240101000000_0_t` COMMENT = 'issue 1 in namespace acme of project payments in environment prod by alice@example.com'"},
+		// The schema version of the task is captured in the marker.
+		{engine: storepb.Engine_POSTGRES, database: "bbdataarchive", table: "_20240101000000_0_t", principal: "alice@example.com", schemaVersion: "20240101000000-dml", want: `COMMENT ON TABLE "bbdataarchive"."_20240101000000_0_t" IS 'issue 1 by alice@example.com at schema version 20240101000000-dml'`},
+		// The names are escaped.
+		{engine: storepb.Engine_MYSQL, database: "bb`archive", table: "_20240101000000_0_order", want: "ALTER TABLE `bb``archive`.`_20240101000000_0_order` COMMENT = 'issue 1'"},
+		{engine: storepb.Engine_POSTGRES, database: `bb"archive`, table: "_20240101000000_0_select", want: `COMMENT ON TABLE "bb""archive"."_20240101000000_0_select" IS 'issue 1'`},
+	}
+	for _, test := range tests {
+		got, err := exec.getBackupTableCommentStatement(test.engine, test.database, "", test.table, 1, test.namespace, test.project, test.environment, test.principal, test.schemaVersion)
+		a.NoError(err)
+		a.Equal(test.want, got)
+	}
+	for _, engine := range engines {
+		got, err := exec.getBackupTableCommentStatement(engine, "bbdataarchive", "", "_20240101000000_0_t", 1, "", "", "", "", "")
+		a.NoError(err)
+		a.NotEmpty(got, engine)
+	}
+
+	exec = &DataUpdateExecutor{profile: &config.Profile{PriorBackupSkipTableComment: true}}
+	for _, engine := range engines {
+		got, err := exec.getBackupTableCommentStatement(engine, "bbdataarchive", "", "_20240101000000_0_t", 1, "", "", "", "", "")
+		a.NoError(err)
+		a.Empty(got, engine)
+	}
+}
+
+func TestGetBackupTableCommentStatementTruncated(t *testing.T) {
+	a := require.New(t)
+	exec := &DataUpdateExecutor{profile: &config.Profile{}}
+	principal := strings.Repeat("o'brien", 1000) + "@example.com"
+	schemaVersion := strings.Repeat("版本", 2000)
+	commentRegexp := regexp.MustCompile(`'(issue 7 in namespace acme by (?:[^']|'')*)'`)
+	for _, engine := range []storepb.Engine{storepb.Engine_MYSQL, storepb.Engine_TIDB, storepb.Engine_MSSQL, storepb.Engine_ORACLE} {
+		statement, err := exec.getBackupTableCommentStatement(engine, "bbdataarchive", "", "acme_20240101000000_0_t", 7, "acme", "", "", principal, schemaVersion)
+		a.NoError(err)
+		matches := commentRegexp.FindStringSubmatch(statement)
+		a.Len(matches, 2, engine)
+		// The issue and the namespace survive, and the comment fits in the engine limit with the truncation marked.
+		comment := strings.ReplaceAll(matches[1], "''", "'")
+		a.True(strings.HasPrefix(comment, "issue 7 in namespace acme by o'brien"), engine)
+		a.True(strings.HasSuffix(comment, backupTableCommentTruncatedSuffix), engine)
+		a.LessOrEqual(len(comment), getMaximumTableCommentBytes(engine), engine)
+		a.True(utf8.ValidString(comment), engine)
+	}
+
+	// Postgres has no practical limit of the comments.
+	statement, err := exec.getBackupTableCommentStatement(storepb.Engine_POSTGRES, "bbdataarchive", "", "acme_20240101000000_0_t", 7, "acme", "", "", principal, schemaVersion)
+	a.NoError(err)
+	a.NotContains(statement, backupTableCommentTruncatedSuffix)
+	a.Contains(statement, strings.ReplaceAll(schemaVersion, "'", "''"))
+
+	// The comment within the limit is kept as is.
+	a.Equal("issue 7 by alice", truncateBackupTableComment("issue 7", " by alice", 16))
+	// The marker is kept even if it doesn't fit.
+	a.Equal("issue 7 (truncated)", truncateBackupTableComment("issue 7", " by alice", 15))
+	a.Equal("issue 7 by (truncated)", truncateBackupTableComment("issue 7", " by alice@example.com", 22))
+}
+
+func TestGetBackupNamespace(t *testing.T) {
+	a := require.New(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+
+	acme := &store.DatabaseMessage{ProjectID: "shared", Metadata: &storepb.DatabaseMetadata{Labels: map[string]string{"tenant": "Acme Corp"}}}
+	globex := &store.DatabaseMessage{ProjectID: "shared", Metadata: &storepb.DatabaseMetadata{Labels: map[string]string{"tenant": "globex"}}}
+	unlabeled := &store.DatabaseMessage{ProjectID: "project_a"}
+
+	// The backup tables of the tenants sharing a backup database are named apart.
+	a.Equal("acmecorp", getBackupNamespace(acme, "tenant"))
+	a.Equal("globex", getBackupNamespace(globex, "tenant"))
+	a.Equal("acmecorp_20240101000000", getBackupTablePrefix(getBackupNamespace(acme, "tenant"), now))
+	a.Equal("globex_20240101000000", getBackupTablePrefix(getBackupNamespace(globex, "tenant"), now))
+
+	// The project ID is the namespace of the databases without the label.
+	a.Equal("projecta", getBackupNamespace(unlabeled, "tenant"))
+	a.Equal("abcdefghijklmnop", getBackupNamespace(&store.DatabaseMessage{ProjectID: "abcdefghijklmnopqrstuvwxyz"}, "tenant"))
+
+	// The backup table names are not namespaced without the label configured.
+	a.Empty(getBackupNamespace(acme, ""))
+	a.Equal("_20240101000000", getBackupTablePrefix(getBackupNamespace(acme, ""), now))
+}
+
+func TestGetBackupAnalyzeStatement(t *testing.T) {
+	a := require.New(t)
+	tests := []struct {
+		engine   storepb.Engine
+		database string
+		schema   string
+		table    string
+		want     string
+	}{
+		{engine: storepb.Engine_POSTGRES, database: "bbdataarchive", table: "_20240101000000_0_t", want: `ANALYZE "bbdataarchive"."_20240101000000_0_t"`},
+		{engine: storepb.Engine_MYSQL, database: "bbdataarchive", table: "_20240101000000_0_t", want: "ANALYZE TABLE `bbdataarchive`.`_20240101000000_0_t`"},
+		{engine: storepb.Engine_MSSQL, database: "bbdataarchive", table: "_20240101000000_0_t", want: "UPDATE STATISTICS [dbo].[_20240101000000_0_t]"},
+		{engine: storepb.Engine_MSSQL, database: "bbdataarchive", schema: "sales", table: "_20240101000000_0_t", want: "UPDATE STATISTICS [sales].[_20240101000000_0_t]"},
+		{engine: storepb.Engine_SNOWFLAKE, database: "bbdataarchive", table: "_20240101000000_0_t"},
+		// The names are escaped.
+		{engine: storepb.Engine_POSTGRES, database: `bb"archive`, table: "_20240101000000_0_order", want: `ANALYZE "bb""archive"."_20240101000000_0_order"`},
+		{engine: storepb.Engine_MYSQL, database: "bb`archive", table: "_20240101000000_0_select", want: "ANALYZE TABLE `bb``archive`.`_20240101000000_0_select`"},
+		{engine: storepb.Engine_MSSQL, database: "bbdataarchive", schema: "sa]les", table: "_20240101000000_0_t", want: "UPDATE STATISTICS [sa]]les].[_20240101000000_0_t]"},
+	}
+	for _, test := range tests {
+		got, err := GetBackupAnalyzeStatement(test.engine, test.database, test.schema, test.table)
+		a.NoError(err)
+		a.Equal(test.want, got)
+	}
+}
+
+func TestGetBackupSchemaStatement(t *testing.T) {
+	a := require.New(t)
+	tests := []struct {
+		engine storepb.Engine
+		schema string
+		want   string
+	}{
+		{engine: storepb.Engine_MSSQL, schema: "sales", want: "IF SCHEMA_ID(N'sales') IS NULL EXEC(N'CREATE SCHEMA [sales]')"},
+		{engine: storepb.Engine_MSSQL, schema: "o'neil]", want: "IF SCHEMA_ID(N'o''neil]') IS NULL EXEC(N'CREATE SCHEMA [o''neil]]]')"},
+		// The default schema always exists.
+		{engine: storepb.Engine_MSSQL, schema: "dbo"},
+		{engine: storepb.Engine_MSSQL, schema: ""},
+		{engine: storepb.Engine_POSTGRES, schema: "sales"},
+	}
+	for _, test := range tests {
+		got, err := GetBackupSchemaStatement(test.engine, test.schema)
+		a.NoError(err)
+		a.Equal(test.want, got, test.schema)
+	}
+
+	// The backup table is tagged in its schema.
+	exec := &DataUpdateExecutor{profile: &config.Profile{}}
+	comment, err := exec.getBackupTableCommentStatement(storepb.Engine_MSSQL, "bbdataarchive", "sales", "_20240101000000_0_t", 1, "", "", "", "", "")
+	a.NoError(err)
+	a.Equal("EXEC sp_addextendedproperty 'MS_Description', 'issue 1', 'SCHEMA', 'sales', 'TABLE', '_20240101000000_0_t'", comment)
+	comment, err = exec.getBackupTableCommentStatement(storepb.Engine_MSSQL, "bbdataarchive", "", "_20240101000000_0_t", 1, "", "", "", "", "")
+	a.NoError(err)
+	a.Equal("EXEC sp_addextendedproperty 'MS_Description', 'issue 1', 'SCHEMA', 'dbo', 'TABLE', '_20240101000000_0_t'", comment)
+}
+
+func TestGetAfterImageStatement(t *testing.T) {
+	a := require.New(t)
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "bbdataarchive"."_20240101000000_0_t" AS SELECT "x".* FROM t AS x WHERE c1 > 1;`,
+		SourceTableName: "t",
+		TargetTableName: "_20240101000000_0_t",
+		UpdateSetClause: "SET c1 = c1 + 1, c2 = upper(x.c2)",
+		UpdateAlias:     "x",
+	}
+	table, got, err := GetAfterImageStatement(storepb.Engine_POSTGRES, "bbdataarchive", statement)
+	a.NoError(err)
+	a.Equal("_20240101000000_0_t_after", table)
+	a.Equal(`CREATE TABLE "bbdataarchive"."_20240101000000_0_t_after" AS SELECT * FROM "bbdataarchive"."_20240101000000_0_t"; UPDATE "bbdataarchive"."_20240101000000_0_t_after" AS "x" SET c1 = c1 + 1, c2 = upper(x.c2);`, got)
+
+	// The after-image table name fits in the identifier length.
+	long := statement
+	long.TargetTableName = "_20240101000000_0_" + strings.Repeat("t", 45)
+	table, _, err = GetAfterImageStatement(storepb.Engine_POSTGRES, "bbdataarchive", long)
+	a.NoError(err)
+	a.Len(table, maximumPostgresIdentifierLength)
+	a.True(strings.HasSuffix(table, "_after"))
+
+	// Not UPDATE, or unsupported engines.
+	table, got, err = GetAfterImageStatement(storepb.Engine_POSTGRES, "bbdataarchive", base.BackupStatement{TargetTableName: "_20240101000000_0_t"})
+	a.NoError(err)
+	a.Empty(table)
+	a.Empty(got)
+	_, got, err = GetAfterImageStatement(storepb.Engine_MYSQL, "bbdataarchive", statement)
+	a.NoError(err)
+	a.Empty(got)
+}
+
+func TestGetArchiveStatements(t *testing.T) {
+	a := require.New(t)
+	// The last second of January lands in the January partition, and the partitions are monthly in UTC.
+	now := time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)
+	partition, start, end := GetArchivePartition(now)
+	a.Equal("p202401", partition)
+	a.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), start)
+	a.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), end)
+	partition, _, end = GetArchivePartition(time.Date(2024, 12, 15, 0, 0, 0, 0, time.FixedZone("UTC+8", 8*60*60)))
+	a.Equal("p202412", partition)
+	a.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), end)
+
+	statement := base.BackupStatement{SourceSchema: "public", SourceTableName: "t", TargetTableName: "_20240131235959_0_t"}
+	archiveTable := GetArchiveTableName(storepb.Engine_POSTGRES, "db", statement)
+	a.Equal("archive_public_t", archiveTable)
+	statements, partition, err := GetArchiveStatements(storepb.Engine_POSTGRES, "bbdataarchive", statement.TargetTableName, archiveTable, nil, now)
+	a.NoError(err)
+	a.Equal("archive_public_t_p202401", partition)
+	a.Equal([]string{
+		`CREATE TABLE IF NOT EXISTS "bbdataarchive"."archive_public_t" (LIKE "bbdataarchive"."_20240131235959_0_t", "_bb_archived_at" timestamptz NOT NULL) PARTITION BY RANGE ("_bb_archived_at");`,
+		`CREATE TABLE IF NOT EXISTS "bbdataarchive"."archive_public_t_p202401" PARTITION OF "bbdataarchive"."archive_public_t" FOR VALUES FROM ('2024-01-01T00:00:00Z') TO ('2024-02-01T00:00:00Z');`,
+		`INSERT INTO "bbdataarchive"."archive_public_t" SELECT *, '2024-01-31T23:59:59Z'::timestamptz FROM "bbdataarchive"."_20240131235959_0_t";`,
+	}, statements)
+
+	// The partition tables fit in the identifier length.
+	long := statement
+	long.SourceTableName = strings.Repeat("t", 63)
+	a.Len(GetArchiveTableName(storepb.Engine_POSTGRES, "db", long)+"_p202401", maximumPostgresIdentifierLength)
+
+	// The archive table of MySQL is qualified by the source database sharing the backup database.
+	statement = base.BackupStatement{SourceTableName: "t", TargetTableName: "_20240131235959_0_t"}
+	archiveTable = GetArchiveTableName(storepb.Engine_MYSQL, "db", statement)
+	a.Equal("archive_db_t", archiveTable)
+	statements, partition, err = GetArchiveStatements(storepb.Engine_MYSQL, "bbdataarchive", statement.TargetTableName, archiveTable, nil, now)
+	a.NoError(err)
+	a.Equal("p202401", partition)
+	a.Equal([]string{
+		"CREATE TABLE IF NOT EXISTS `bbdataarchive`.`archive_db_t` (`_bb_archived_at` DATETIME(6) NOT NULL) PARTITION BY RANGE COLUMNS(`_bb_archived_at`) (PARTITION `p202401` VALUES LESS THAN ('2024-02-01 00:00:00')) SELECT * FROM `bbdataarchive`.`_20240131235959_0_t` WHERE FALSE;",
+		"INSERT INTO `bbdataarchive`.`archive_db_t` SELECT '2024-01-31 23:59:59.000000', `_20240131235959_0_t`.* FROM `bbdataarchive`.`_20240131235959_0_t`;",
+	}, statements)
+
+	// The missing partition of the existing archive table is added on MySQL.
+	statements, _, err = GetArchiveStatements(storepb.Engine_MYSQL, "bbdataarchive", statement.TargetTableName, archiveTable, []string{"p202312"}, now)
+	a.NoError(err)
+	a.Len(statements, 3)
+	a.Equal("ALTER TABLE `bbdataarchive`.`archive_db_t` ADD PARTITION (PARTITION `p202401` VALUES LESS THAN ('2024-02-01 00:00:00'));", statements[1])
+	statements, _, err = GetArchiveStatements(storepb.Engine_MYSQL, "bbdataarchive", statement.TargetTableName, archiveTable, []string{"p202312", "p202401"}, now)
+	a.NoError(err)
+	a.Len(statements, 2)
+
+	_, _, err = GetArchiveStatements(storepb.Engine_ORACLE, "BBDATAARCHIVE", statement.TargetTableName, archiveTable, nil, now)
+	a.Error(err)
+}
+
+func TestGetSessionSettingStatement(t *testing.T) {
+	a := require.New(t)
+	a.Equal("SET SESSION sql_mode = 'STRICT_TRANS_TABLES,NO_ZERO_DATE', time_zone = '+08:00';", GetSessionSettingStatement(storepb.Engine_MYSQL, map[string]string{
+		"time_zone": "+08:00",
+		"sql_mode":  "STRICT_TRANS_TABLES,NO_ZERO_DATE",
+	}))
+	// The NLS parameters derived from NLS_LANGUAGE and NLS_TERRITORY are applied after them.
+	a.Equal("ALTER SESSION SET NLS_LANGUAGE = 'AMERICAN' NLS_TERRITORY = 'AMERICA' NLS_DATE_FORMAT = 'DD-MON-RR' TIME_ZONE = 'Asia/Shanghai';", GetSessionSettingStatement(storepb.Engine_ORACLE, map[string]string{
+		"TIME_ZONE":       "Asia/Shanghai",
+		"NLS_DATE_FORMAT": "DD-MON-RR",
+		"NLS_TERRITORY":   "AMERICA",
+		"NLS_LANGUAGE":    "AMERICAN",
+	}))
+	// The values are quoted, and the invalid names are skipped.
+	a.Equal("SET SESSION time_zone = 'a''b';", GetSessionSettingStatement(storepb.Engine_MYSQL, map[string]string{"time_zone": "a'b", "x; DROP TABLE t": "1"}))
+	a.Empty(GetSessionSettingStatement(storepb.Engine_MYSQL, nil))
+	a.Empty(GetSessionSettingStatement(storepb.Engine_POSTGRES, map[string]string{"TimeZone": "UTC"}))
+
+	items := []*storepb.PriorBackupDetail_Item{
+		{RestoreStatement: "INSERT INTO t SELECT * FROM b;"},
+		{},
+	}
+	setRestoreSessionSettings(storepb.Engine_MYSQL, map[string]string{"time_zone": "+08:00"}, items)
+	a.Equal("SET SESSION time_zone = '+08:00';\nINSERT INTO t SELECT * FROM b;", items[0].RestoreStatement)
+	a.Empty(items[1].RestoreStatement)
+}
+
+func TestGetExplainStatement(t *testing.T) {
+	tests := []struct {
+		engine    storepb.Engine
+		statement string
+		want      string
+	}{
+		{
+			engine:    storepb.Engine_MYSQL,
+			statement: "UPDATE t SET a = 1 WHERE id > 10;",
+			want:      "EXPLAIN FORMAT=JSON UPDATE t SET a = 1 WHERE id > 10",
+		},
+		{
+			engine:    storepb.Engine_POSTGRES,
+			statement: "\nDELETE FROM t WHERE id = 1;\n",
+			want:      "EXPLAIN (FORMAT JSON) DELETE FROM t WHERE id = 1",
+		},
+		{
+			engine:    storepb.Engine_SNOWFLAKE,
+			statement: "UPDATE t SET a = 1",
+			want:      "",
+		},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.want, getExplainStatement(test.engine, test.statement), test.engine)
+	}
+}
+
+func TestGetBulkCopyQuery(t *testing.T) {
+	a := require.New(t)
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "bbdataarchive"."_20240101_0_t" AS SELECT "t".* FROM t WHERE id > 1;`,
+		TargetTableName: "_20240101_0_t",
+	}
+	query, ok := getBulkCopyQuery(storepb.Engine_POSTGRES, "bbdataarchive", statement)
+	a.True(ok)
+	a.Equal(`SELECT "t".* FROM t WHERE id > 1`, query)
+
+	_, ok = getBulkCopyQuery(storepb.Engine_POSTGRES, "other", statement)
+	a.False(ok)
+	_, ok = getBulkCopyQuery(storepb.Engine_MYSQL, "bbdataarchive", statement)
+	a.False(ok)
+
+	// The names are escaped.
+	statement = base.BackupStatement{
+		Statement:       `CREATE TABLE "bb""archive"."_20240101_0_order" AS SELECT "order".* FROM "order" WHERE id > 1;`,
+		TargetTableName: "_20240101_0_order",
+	}
+	query, ok = getBulkCopyQuery(storepb.Engine_POSTGRES, `bb"archive`, statement)
+	a.True(ok)
+	a.Equal(`SELECT "order".* FROM "order" WHERE id > 1`, query)
+}
+
+func TestGetPerIssueBackupDetail(t *testing.T) {
+	a := require.New(t)
+	backupDetail := &storepb.PreUpdateBackupDetail{
+		Database:       "instances/shard0/databases/bbdataarchive",
+		ShardDatabases: []string{"instances/shard1/databases/db"},
+	}
+
+	issue1, err := getPerIssueBackupDetail(backupDetail, 1)
+	a.NoError(err)
+	issue2, err := getPerIssueBackupDetail(backupDetail, 2)
+	a.NoError(err)
+	a.Equal("instances/shard0/databases/bbdataarchive_issue_1", issue1.Database)
+	a.Equal("instances/shard0/databases/bbdataarchive_issue_2", issue2.Database)
+	// The configured backup detail is not modified.
+	a.Equal("instances/shard0/databases/bbdataarchive", backupDetail.Database)
+
+	// The shard backups of an issue go to the backup database of the issue on the shard instance.
+	targets, err := getBackupTargets("instances/shard0/databases/db", issue1)
+	a.NoError(err)
+	a.Len(targets, 2)
+	a.Equal("instances/shard1/databases/bbdataarchive_issue_1", targets[1].target)
+
+	_, err = getPerIssueBackupDetail(&storepb.PreUpdateBackupDetail{Database: "bbdataarchive"}, 1)
+	a.Error(err)
+}
+
+func TestTransformDMLToBackupCollision(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	// The statement coincidentally references the backup table name generated with the prefix.
+	statement := `UPDATE t SET a = 1 WHERE id IN (SELECT id FROM "_20240101000000_0_t");`
+
+	statements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "db", "bbdataarchive", "_20240101000000")
+	a.NoError(err)
+	a.True(hasBackupTableCollision(statement, statements))
+
+	statements, err = transformDMLToBackup(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "db", "bbdataarchive", "_20240101000000")
+	a.NoError(err)
+	a.Len(statements, 1)
+	a.Equal("_20240101000000_1_0_t", statements[0].TargetTableName)
+	a.False(hasBackupTableCollision(statement, statements))
+}
+
+func TestSetBackupTablespace(t *testing.T) {
+	a := require.New(t)
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "BBDATAARCHIVE"."_20240101_0_T" AS SELECT "T".* FROM t WHERE id > 1;`,
+		TargetTableName: "_20240101_0_T",
+	}
+	got, ok := setBackupTablespace(storepb.Engine_ORACLE, "BBDATAARCHIVE", statement, "USERS")
+	a.True(ok)
+	a.Equal(`CREATE TABLE "BBDATAARCHIVE"."_20240101_0_T" TABLESPACE "USERS" AS SELECT "T".* FROM t WHERE id > 1;`, got)
+
+	_, ok = setBackupTablespace(storepb.Engine_ORACLE, "OTHER", statement, "USERS")
+	a.False(ok)
+
+	// The tablespace name is escaped.
+	got, ok = setBackupTablespace(storepb.Engine_ORACLE, "BBDATAARCHIVE", statement, `US"ERS`)
+	a.True(ok)
+	a.Equal(`CREATE TABLE "BBDATAARCHIVE"."_20240101_0_T" TABLESPACE "US""ERS" AS SELECT "T".* FROM t WHERE id > 1;`, got)
+}
+
+func TestSetBackupReducedDurability(t *testing.T) {
+	a := require.New(t)
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "bbdataarchive"."_0_t" AS SELECT "t".* FROM "public"."t" WHERE id > 1;`,
+		TargetTableName: "_0_t",
+	}
+	// The Postgres backup table is copied unlogged and then converted into a logged table before the data update.
+	reduced, ok := setBackupReducedDurability(storepb.Engine_POSTGRES, "bbdataarchive", statement)
+	a.True(ok)
+	a.Equal(`CREATE UNLOGGED TABLE "bbdataarchive"."_0_t" AS SELECT "t".* FROM "public"."t" WHERE id > 1;`, reduced)
+	durability, err := getBackupDurabilityStatement(storepb.Engine_POSTGRES, "bbdataarchive", "_0_t")
+	a.NoError(err)
+	a.Equal(`ALTER TABLE "bbdataarchive"."_0_t" SET LOGGED`, durability)
+
+	// The NOLOGGING clause follows the replicated tablespace on Oracle.
+	statement = base.BackupStatement{
+		Statement:       `CREATE TABLE "BBDATAARCHIVE"."_0_T" AS SELECT "T".* FROM t WHERE id > 1;`,
+		TargetTableName: "_0_T",
+	}
+	reduced, ok = setBackupReducedDurability(storepb.Engine_ORACLE, "BBDATAARCHIVE", statement)
+	a.True(ok)
+	a.Equal(`CREATE TABLE "BBDATAARCHIVE"."_0_T" NOLOGGING AS SELECT "T".* FROM t WHERE id > 1;`, reduced)
+	statement.Statement, ok = setBackupTablespace(storepb.Engine_ORACLE, "BBDATAARCHIVE", statement, "USERS")
+	a.True(ok)
+	reduced, ok = setBackupReducedDurability(storepb.Engine_ORACLE, "BBDATAARCHIVE", statement)
+	a.True(ok)
+	a.Equal(`CREATE TABLE "BBDATAARCHIVE"."_0_T" TABLESPACE "USERS" NOLOGGING AS SELECT "T".* FROM t WHERE id > 1;`, reduced)
+	durability, err = getBackupDurabilityStatement(storepb.Engine_ORACLE, "BBDATAARCHIVE", "_0_T")
+	a.NoError(err)
+	a.Equal(`ALTER TABLE "BBDATAARCHIVE"."_0_T" LOGGING`, durability)
+
+	// The immutable backup tables and the other engines are not supported.
+	statement.Statement, ok = setBackupImmutable("BBDATAARCHIVE", statement)
+	a.True(ok)
+	_, ok = setBackupReducedDurability(storepb.Engine_ORACLE, "BBDATAARCHIVE", statement)
+	a.False(ok)
+	_, ok = setBackupReducedDurability(storepb.Engine_MYSQL, "bbdataarchive", base.BackupStatement{Statement: "CREATE TABLE `bbdataarchive`.`_0_t` LIKE `db`.`t`;", TargetTableName: "_0_t"})
+	a.False(ok)
+	durability, err = getBackupDurabilityStatement(storepb.Engine_MYSQL, "bbdataarchive", "_0_t")
+	a.NoError(err)
+	a.Empty(durability)
+
+	// The names are escaped.
+	statement = base.BackupStatement{
+		Statement:       `CREATE TABLE "bb""archive"."_0_t" AS SELECT "t".* FROM "public"."t" WHERE id > 1;`,
+		TargetTableName: "_0_t",
+	}
+	reduced, ok = setBackupReducedDurability(storepb.Engine_POSTGRES, `bb"archive`, statement)
+	a.True(ok)
+	a.Equal(`CREATE UNLOGGED TABLE "bb""archive"."_0_t" AS SELECT "t".* FROM "public"."t" WHERE id > 1;`, reduced)
+	durability, err = getBackupDurabilityStatement(storepb.Engine_POSTGRES, `bb"archive`, "_0_t")
+	a.NoError(err)
+	a.Equal(`ALTER TABLE "bb""archive"."_0_t" SET LOGGED`, durability)
+}
+
+func TestBackupEncryptionKey(t *testing.T) {
+	a := require.New(t)
+	for _, key := range []string{
+		"1234abcd-12ab-34cd-56ef-1234567890ab",
+		"alias/bytebase-backup",
+		"arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab",
+	} {
+		a.NoError(validateBackupEncryptionKey(storepb.Engine_MYSQL, key))
+	}
+	a.Error(validateBackupEncryptionKey(storepb.Engine_MYSQL, "alias/backup' OR '1'='1"))
+	a.Error(validateBackupEncryptionKey(storepb.Engine_MYSQL, ""))
+	a.Error(validateBackupEncryptionKey(storepb.Engine_POSTGRES, "alias/bytebase-backup"))
+
+	encryption, err := getBackupEncryptionStatement(storepb.Engine_MYSQL, "bbdataarchive", "_20240101_db_t")
+	a.NoError(err)
+	a.Equal("ALTER TABLE `bbdataarchive`.`_20240101_db_t` ENCRYPTION = 'Y'", encryption)
+	encryption, err = getBackupEncryptionStatement(storepb.Engine_POSTGRES, "bbdataarchive", "_20240101_db_t")
+	a.NoError(err)
+	a.Equal("", encryption)
+
+	// The per-issue backup database keeps the referenced key.
+	detail, err := getPerIssueBackupDetail(&storepb.PreUpdateBackupDetail{
+		Database:      "instances/i/databases/bbdataarchive",
+		EncryptionKey: "alias/bytebase-backup",
+	}, 42)
+	a.NoError(err)
+	a.Equal("alias/bytebase-backup", detail.EncryptionKey)
+}
+
+func TestBackupKeyring(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+
+	a.NoError(checkBackupKeyring("keyring_aws"))
+	a.ErrorContains(checkBackupKeyring(""), "no keyring is installed")
+	for _, keyring := range []string{"keyring_file", "keyring_okv", "component_keyring_file"} {
+		a.ErrorContains(checkBackupKeyring(keyring), keyring)
+	}
+
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	a.NoError(err)
+	defer sqlDB.Close()
+	// Each connection has its own in-memory databases.
+	sqlDB.SetMaxOpenConns(1)
+	for _, s := range []string{
+		`ATTACH ':memory:' AS INFORMATION_SCHEMA`,
+		`ATTACH ':memory:' AS performance_schema`,
+		`CREATE TABLE INFORMATION_SCHEMA.PLUGINS (PLUGIN_NAME TEXT, PLUGIN_STATUS TEXT)`,
+		`CREATE TABLE performance_schema.keyring_component_status (STATUS_KEY TEXT, STATUS_VALUE TEXT)`,
+	} {
+		_, err := sqlDB.ExecContext(ctx, s)
+		a.NoError(err)
+	}
+	keyring, err := getBackupKeyring(ctx, sqlDB)
+	a.NoError(err)
+	a.Equal("", keyring)
+
+	// The keyring component is detected without the keyring plugins.
+	_, err = sqlDB.ExecContext(ctx, `INSERT INTO performance_schema.keyring_component_status VALUES ('Component_name', 'component_keyring_file')`)
+	a.NoError(err)
+	keyring, err = getBackupKeyring(ctx, sqlDB)
+	a.NoError(err)
+	a.Equal("component_keyring_file", keyring)
+
+	// The inactive keyring plugins are ignored.
+	_, err = sqlDB.ExecContext(ctx, `INSERT INTO INFORMATION_SCHEMA.PLUGINS VALUES ('keyring_okv', 'DISABLED'), ('keyring_aws', 'ACTIVE')`)
+	a.NoError(err)
+	keyring, err = getBackupKeyring(ctx, sqlDB)
+	a.NoError(err)
+	a.Equal("keyring_aws", keyring)
+}
+
+func TestOrderBackupStatementsByDependency(t *testing.T) {
+	a := require.New(t)
+	metadata := &storepb.DatabaseSchemaMetadata{
+		Name: "db",
+		Schemas: []*storepb.SchemaMetadata{
+			{
+				Name: "public",
+				Tables: []*storepb.TableMetadata{
+					{Name: "customers"},
+					{
+						Name:        "orders",
+						ForeignKeys: []*storepb.ForeignKeyMetadata{{Name: "fk_customer", ReferencedSchema: "public", ReferencedTable: "customers"}},
+					},
+					{
+						Name:        "order_items",
+						ForeignKeys: []*storepb.ForeignKeyMetadata{{Name: "fk_order", ReferencedSchema: "public", ReferencedTable: "orders"}},
+					},
+					{Name: "logs"},
+				},
+			},
+		},
+	}
+	statements := []base.BackupStatement{
+		{SourceTableName: "order_items", TargetTableName: "_0_order_items"},
+		{SourceTableName: "logs", TargetTableName: "_1_logs"},
+		{SourceSchema: "public", SourceTableName: "orders", TargetTableName: "_2_orders"},
+		{SourceTableName: "customers", TargetTableName: "_3_customers"},
+	}
+
+	var got []string
+	for _, statement := range orderBackupStatementsByDependency(storepb.Engine_POSTGRES, statements, metadata) {
+		got = append(got, statement.TargetTableName)
+	}
+	a.Equal([]string{"_1_logs", "_3_customers", "_2_orders", "_0_order_items"}, got)
+}
+
+func TestIsSmallBackupTable(t *testing.T) {
+	a := require.New(t)
+	metadata := &storepb.DatabaseSchemaMetadata{
+		Name: "db",
+		Schemas: []*storepb.SchemaMetadata{
+			{
+				Name: "public",
+				Tables: []*storepb.TableMetadata{
+					{Name: "t", RowCount: 100},
+				},
+			},
+		},
+	}
+	statement := base.BackupStatement{SourceTableName: "t", TargetTableName: "_0_t"}
+
+	// The threshold is inclusive.
+	a.True(isSmallBackupTable(storepb.Engine_POSTGRES, statement, metadata, 100))
+	a.False(isSmallBackupTable(storepb.Engine_POSTGRES, statement, metadata, 99))
+	a.True(isSmallBackupTable(storepb.Engine_POSTGRES, statement, metadata, 101))
+	// Zero backs up all tables by the full path.
+	a.False(isSmallBackupTable(storepb.Engine_POSTGRES, statement, metadata, 0))
+	// The tables not found in the metadata are backed up by the full path.
+	a.False(isSmallBackupTable(storepb.Engine_POSTGRES, base.BackupStatement{SourceTableName: "unknown"}, metadata, 100))
+	a.False(isSmallBackupTable(storepb.Engine_POSTGRES, statement, nil, 100))
+}
+
+func TestGetSourceTablePartitions(t *testing.T) {
+	a := require.New(t)
+	partitions := []*storepb.TablePartitionMetadata{
+		{Name: "p2023", Type: storepb.TablePartitionMetadata_RANGE, Expression: "YEAR(`created_at`)", Value: "2024"},
+		{Name: "p2024", Type: storepb.TablePartitionMetadata_RANGE, Expression: "YEAR(`created_at`)", Value: "2025"},
+		{Name: "pmax", Type: storepb.TablePartitionMetadata_RANGE, Expression: "YEAR(`created_at`)", Value: "MAXVALUE"},
+	}
+	metadata := &storepb.DatabaseSchemaMetadata{
+		Name: "db",
+		Schemas: []*storepb.SchemaMetadata{
+			{
+				Tables: []*storepb.TableMetadata{
+					{Name: "orders", Partitions: partitions},
+					{Name: "t"},
+				},
+			},
+		},
+	}
+
+	got := getSourceTablePartitions(storepb.Engine_MYSQL, base.BackupStatement{SourceTableName: "orders", TargetTableName: "_0_orders"}, metadata)
+	a.Len(got, 3)
+	for i, partition := range got {
+		a.Equal(partitions[i].GetName(), partition.GetName())
+		a.Equal(storepb.TablePartitionMetadata_RANGE, partition.GetType())
+		a.Equal("YEAR(`created_at`)", partition.GetExpression())
+		a.Equal(partitions[i].GetValue(), partition.GetValue())
+	}
+	a.Empty(getSourceTablePartitions(storepb.Engine_MYSQL, base.BackupStatement{SourceTableName: "t", TargetTableName: "_0_t"}, metadata))
+	a.Empty(getSourceTablePartitions(storepb.Engine_MYSQL, base.BackupStatement{SourceTableName: "unknown"}, metadata))
+}
+
+func TestGetBackupResourceGroup(t *testing.T) {
+	a := require.New(t)
+	a.Equal("rg_server", getBackupResourceGroup(storepb.Engine_TIDB, "rg_server", ""))
+	a.Equal("rg_task", getBackupResourceGroup(storepb.Engine_TIDB, "rg_server", "rg_task"))
+	a.Equal("", getBackupResourceGroup(storepb.Engine_TIDB, "", ""))
+	a.Equal("", getBackupResourceGroup(storepb.Engine_MYSQL, "rg_server", "rg_task"))
+}
+
+func TestGetSampleBackupStatement(t *testing.T) {
+	a := require.New(t)
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "bbdataarchive"."_20240101_0_t" AS SELECT "t".* FROM t WHERE id > 1;`,
+		TargetTableName: "_20240101_0_t",
+	}
+	got, err := GetSampleBackupStatement(storepb.Engine_POSTGRES, "bbdataarchive", statement, 10)
+	a.NoError(err)
+	a.Equal(`CREATE TABLE "bbdataarchive"."_20240101_0_t" AS SELECT * FROM (SELECT "t".* FROM t WHERE id > 1) AS bb_sample WHERE random() < 1.0 / 10;`, got)
+
+	_, err = GetSampleBackupStatement(storepb.Engine_MYSQL, "bbdataarchive", statement, 10)
+	a.Error(err)
+}
+
+func TestGetBoundedSampleBackupStatement(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	// The main schema of SQLite stands in for the backup schema.
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "main"."_0_t" AS SELECT "t".* FROM t WHERE v > 1;`,
+		SourceTableName: "t",
+		TargetTableName: "_0_t",
+	}
+	driver := newConnectedDriver(t, "sample")
+	// Each connection opens its own in-memory database.
+	driver.GetDB().SetMaxOpenConns(1)
+	_, err := driver.GetDB().ExecContext(ctx, "CREATE TABLE t (id INTEGER PRIMARY KEY, v INTEGER); INSERT INTO t VALUES (1, 5), (2, 0), (3, 5), (4, 5), (5, 5), (6, 5);")
+	a.NoError(err)
+
+	for _, fromLast := range []bool{false, true} {
+		got, err := GetBoundedSampleBackupStatement(storepb.Engine_POSTGRES, "main", statement, []string{"id"}, 3, fromLast)
+		a.NoError(err)
+		_, err = driver.GetDB().ExecContext(ctx, got)
+		a.NoError(err, got)
+		rows, err := driver.GetDB().QueryContext(ctx, `SELECT id FROM "_0_t" ORDER BY id`)
+		a.NoError(err)
+		var ids []int
+		for rows.Next() {
+			var id int
+			a.NoError(rows.Scan(&id))
+			ids = append(ids, id)
+		}
+		a.NoError(rows.Err())
+		rows.Close()
+		// Exactly the bound of the affected rows are captured.
+		if fromLast {
+			a.Equal([]int{4, 5, 6}, ids)
+		} else {
+			a.Equal([]int{1, 3, 4}, ids)
+		}
+		_, err = driver.GetDB().ExecContext(ctx, `DROP TABLE "_0_t"`)
+		a.NoError(err)
+	}
+
+	_, err = GetBoundedSampleBackupStatement(storepb.Engine_POSTGRES, "main", statement, nil, 3, false)
+	a.ErrorContains(err, "requires the primary key")
+	_, err = GetBoundedSampleBackupStatement(storepb.Engine_MYSQL, "main", statement, []string{"id"}, 3, false)
+	a.Error(err)
+}
+
+func TestGetBackupStrategy(t *testing.T) {
+	a := require.New(t)
+	source := &store.InstanceMessage{ResourceID: "source", Options: &storepb.InstanceOptions{Region: "us-east-1"}}
+	sameRegion := &store.InstanceMessage{ResourceID: "backup", Options: &storepb.InstanceOptions{Region: "us-east-1"}}
+	otherRegion := &store.InstanceMessage{ResourceID: "backup", Options: &storepb.InstanceOptions{Region: "eu-west-1"}}
+	unknownRegion := &store.InstanceMessage{ResourceID: "backup"}
+
+	a.False(isCrossRegion(source, sameRegion))
+	a.True(isCrossRegion(source, otherRegion))
+	a.False(isCrossRegion(source, unknownRegion))
+
+	strategy, _ := getBackupStrategy(storepb.Engine_POSTGRES, false, isCrossRegion(source, sameRegion), 0, 0)
+	a.Equal(backupStrategyStatement, strategy)
+	strategy, _ = getBackupStrategy(storepb.Engine_POSTGRES, false, isCrossRegion(source, otherRegion), 0, 0)
+	a.Equal(backupStrategyBulkCopy, strategy)
+	strategy, _ = getBackupStrategy(storepb.Engine_POSTGRES, true, false, 0, 0)
+	a.Equal(backupStrategyBulkCopy, strategy)
+	// The engines without bulk copy execute the backup statements.
+	strategy, reason := getBackupStrategy(storepb.Engine_MYSQL, false, isCrossRegion(source, otherRegion), 1000000, 1000)
+	a.Equal(backupStrategyStatement, strategy)
+	a.Equal("the bulk copy is not supported on MYSQL", reason)
+
+	// The small tables are copied by the backup statements, and the large tables by the bulk copy.
+	strategy, reason = getBackupStrategy(storepb.Engine_POSTGRES, false, false, 10, 1000)
+	a.Equal(backupStrategyStatement, strategy)
+	a.Equal("the source table is estimated to have 10 rows, fewer than 1000", reason)
+	strategy, reason = getBackupStrategy(storepb.Engine_POSTGRES, false, false, 1000000, 1000)
+	a.Equal(backupStrategyBulkCopy, strategy)
+	a.Equal("the source table is estimated to have 1000000 rows, at least 1000", reason)
+}
+
+func TestSetBackupImmutable(t *testing.T) {
+	a := require.New(t)
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "BBDATAARCHIVE"."_20240101_0_T" AS SELECT "T".* FROM t WHERE id > 1;`,
+		TargetTableName: "_20240101_0_T",
+	}
+	got, ok := setBackupImmutable("BBDATAARCHIVE", statement)
+	a.True(ok)
+	a.Equal(`CREATE IMMUTABLE TABLE "BBDATAARCHIVE"."_20240101_0_T" NO DROP UNTIL 16 DAYS IDLE NO DELETE UNTIL 16 DAYS AFTER INSERT AS SELECT "T".* FROM t WHERE id > 1;`, got)
+
+	// The immutable clauses precede the replicated tablespace.
+	statement.Statement, ok = setBackupTablespace(storepb.Engine_ORACLE, "BBDATAARCHIVE", statement, "USERS")
+	a.True(ok)
+	got, ok = setBackupImmutable("BBDATAARCHIVE", statement)
+	a.True(ok)
+	a.Equal(`CREATE IMMUTABLE TABLE "BBDATAARCHIVE"."_20240101_0_T" NO DROP UNTIL 16 DAYS IDLE NO DELETE UNTIL 16 DAYS AFTER INSERT TABLESPACE "USERS" AS SELECT "T".* FROM t WHERE id > 1;`, got)
+
+	// The immutable backup tables cannot be dropped until they're idle for the retention days.
+	clock := &fakeClock{now: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)}
+	exec := &DataUpdateExecutor{profile: &config.Profile{PriorBackupImmutableTable: true}, clock: clock}
+	a.Equal(time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC), exec.getBackupImmutableUntil(storepb.Engine_ORACLE).AsTime())
+	a.Nil(exec.getBackupImmutableUntil(storepb.Engine_POSTGRES))
+	item := &storepb.PriorBackupDetail_Item{ImmutableUntil: exec.getBackupImmutableUntil(storepb.Engine_ORACLE)}
+	a.True(isBackupImmutable(item, clock.now))
+	a.False(isBackupImmutable(item, time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)))
+	a.False(isBackupImmutable(&storepb.PriorBackupDetail_Item{}, clock.now))
+}
+
+func TestSyncUntilVisible(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+
+	// The catalog shows the backup tables after the third sync.
+	syncs := 0
+	sync := func() error {
+		syncs++
+		return nil
+	}
+	visible := func() (bool, error) {
+		return syncs >= 3, nil
+	}
+	a.NoError(syncUntilVisible(ctx, time.Millisecond, 5, sync, visible))
+	a.Equal(3, syncs)
+
+	syncs = 0
+	a.Error(syncUntilVisible(ctx, time.Millisecond, 2, sync, visible))
+	a.Equal(2, syncs)
+
+	// Zero delay syncs once without checking the visibility.
+	syncs = 0
+	a.NoError(syncUntilVisible(ctx, 0, 5, sync, visible))
+	a.Equal(1, syncs)
+}
+
+func TestHasBackupTables(t *testing.T) {
+	a := require.New(t)
+	metadata := &storepb.DatabaseSchemaMetadata{
+		Schemas: []*storepb.SchemaMetadata{
+			{
+				Name:   "bbdataarchive",
+				Tables: []*storepb.TableMetadata{{Name: "_0_t"}},
+			},
+		},
+	}
+	item := func(table string) *storepb.PriorBackupDetail_Item {
+		return &storepb.PriorBackupDetail_Item{TargetTable: &storepb.PriorBackupDetail_Item_Table{Table: table}}
+	}
+	a.True(hasBackupTables(metadata, []*storepb.PriorBackupDetail_Item{item("_0_t")}))
+	a.False(hasBackupTables(metadata, []*storepb.PriorBackupDetail_Item{item("_0_t"), item("_1_t")}))
+	a.False(hasBackupTables(nil, []*storepb.PriorBackupDetail_Item{item("_0_t")}))
+}
+
+func TestGetBackupRequirement(t *testing.T) {
+	a := require.New(t)
+	detail := &storepb.PreUpdateBackupDetail{Database: "instances/i/databases/bbdataarchive"}
+	tests := []struct {
+		payload *storepb.TaskDatabaseUpdatePayload
+		want    storepb.TaskDatabaseUpdatePayload_BackupRequirement
+	}{
+		{
+			payload: &storepb.TaskDatabaseUpdatePayload{},
+			want:    storepb.TaskDatabaseUpdatePayload_DISABLED,
+		},
+		{
+			payload: &storepb.TaskDatabaseUpdatePayload{PreUpdateBackupDetail: detail},
+			want:    storepb.TaskDatabaseUpdatePayload_REQUIRED,
+		},
+		{
+			// The missing backup database is a shortfall of the required backup.
+			payload: &storepb.TaskDatabaseUpdatePayload{BackupRequirement: storepb.TaskDatabaseUpdatePayload_REQUIRED},
+			want:    storepb.TaskDatabaseUpdatePayload_REQUIRED,
+		},
+		{
+			payload: &storepb.TaskDatabaseUpdatePayload{PreUpdateBackupDetail: detail, BackupRequirement: storepb.TaskDatabaseUpdatePayload_BEST_EFFORT},
+			want:    storepb.TaskDatabaseUpdatePayload_BEST_EFFORT,
+		},
+		{
+			payload: &storepb.TaskDatabaseUpdatePayload{PreUpdateBackupDetail: detail, BackupRequirement: storepb.TaskDatabaseUpdatePayload_DISABLED},
+			want:    storepb.TaskDatabaseUpdatePayload_DISABLED,
+		},
+	}
+	for _, test := range tests {
+		a.Equal(test.want, getBackupRequirement(test.payload), test.payload.String())
+	}
+}
+
+func TestCheckBackupShortfall(t *testing.T) {
+	a := require.New(t)
+	shortfall := errors.New("engine SNOWFLAKE is not supported")
+	a.ErrorIs(checkBackupShortfall(storepb.TaskDatabaseUpdatePayload_REQUIRED, shortfall), shortfall)
+	a.NoError(checkBackupShortfall(storepb.TaskDatabaseUpdatePayload_BEST_EFFORT, shortfall))
+	a.NoError(checkBackupShortfall(storepb.TaskDatabaseUpdatePayload_DISABLED, shortfall))
+}
+
+func TestHasCompleteBackup(t *testing.T) {
+	a := require.New(t)
+	items := []*storepb.PriorBackupDetail_Item{{TargetTable: &storepb.PriorBackupDetail_Item_Table{Table: "_0_t"}}}
+	a.True(hasCompleteBackup(&storepb.PriorBackupDetail{Items: items}))
+	a.False(hasCompleteBackup(nil))
+	a.False(hasCompleteBackup(&storepb.PriorBackupDetail{}))
+	// The backup timed out under the BEST_EFFORT requirement only has the backup tables created before the timeout.
+	a.False(hasCompleteBackup(&storepb.PriorBackupDetail{Items: items, TimedOut: true, Incomplete: true}))
+}
+
+// statementDriver is a driver recording the executed statements.
+type statementDriver struct {
+	db.Driver
+
+	statements []string
+	preludes   []string
+}
+
+func (d *statementDriver) Execute(_ context.Context, statement string, opts db.ExecuteOptions) (int64, error) {
+	d.statements = append(d.statements, statement)
+	d.preludes = append(d.preludes, opts.PreludeStatements...)
+	return 0, nil
+}
+
+// failingStatementDriver is a driver failing the statements containing the failure.
+type failingStatementDriver struct {
+	statementDriver
+
+	failure string
+}
+
+func (d *failingStatementDriver) Execute(ctx context.Context, statement string, opts db.ExecuteOptions) (int64, error) {
+	if strings.Contains(statement, d.failure) {
+		return 0, errors.Errorf("failed to execute %q", statement)
+	}
+	return d.statementDriver.Execute(ctx, statement, opts)
+}
+
+func TestMaintainBackupSourceTable(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	statement := base.BackupStatement{SourceTableName: "t", TargetTableName: "_0_t"}
+
+	// The maintenance runs only when enabled.
+	driver := &statementDriver{}
+	maintainBackupSourceTable(ctx, driver, storepb.Engine_POSTGRES, false, "db", statement)
+	a.Empty(driver.statements)
+	maintainBackupSourceTable(ctx, driver, storepb.Engine_POSTGRES, true, "db", statement)
+	a.Equal([]string{`VACUUM "public"."t"`}, driver.statements)
+
+	driver = &statementDriver{}
+	maintainBackupSourceTable(ctx, driver, storepb.Engine_MYSQL, true, "db", statement)
+	a.Equal([]string{"OPTIMIZE TABLE `db`.`t`"}, driver.statements)
+
+	// It's skipped on the other engines.
+	driver = &statementDriver{}
+	maintainBackupSourceTable(ctx, driver, storepb.Engine_TIDB, true, "db", statement)
+	a.Empty(driver.statements)
+}
+
+func TestBackupSavepointPolicy(t *testing.T) {
+	a := require.New(t)
+
+	policy, err := getBackupSavepointPolicy("")
+	a.NoError(err)
+	a.Equal(db.PreludeRollbackAll, policy)
+	policy, err = getBackupSavepointPolicy("keep_backup")
+	a.NoError(err)
+	a.Equal(db.PreludeRollbackKeep, policy)
+	policy, err = getBackupSavepointPolicy(backupSavepointUndo)
+	a.NoError(err)
+	a.Equal(db.PreludeRollbackAll, policy)
+	_, err = getBackupSavepointPolicy("ROLLBACK")
+	a.Error(err)
+
+	a.True(supportBackupSavepoint(storepb.Engine_POSTGRES))
+	a.True(supportBackupSavepoint(storepb.Engine_MYSQL))
+	a.False(supportBackupSavepoint(storepb.Engine_ORACLE))
+
+	// The DDL statements are executed before the data update transaction on MySQL.
+	immediate, deferred := splitDeferredBackupStatements(storepb.Engine_MYSQL,
+		"CREATE TABLE `bbdataarchive`.`_0_t` LIKE `db`.`t`;\nINSERT INTO `bbdataarchive`.`_0_t` SELECT `t`.* FROM `t` WHERE id = 1;",
+		"ALTER TABLE `bbdataarchive`.`_0_t` COMMENT = 'issue 1'",
+		"",
+	)
+	a.Equal([]string{"CREATE TABLE `bbdataarchive`.`_0_t` LIKE `db`.`t`", "ALTER TABLE `bbdataarchive`.`_0_t` COMMENT = 'issue 1'"}, immediate)
+	a.Equal([]string{"INSERT INTO `bbdataarchive`.`_0_t` SELECT `t`.* FROM `t` WHERE id = 1;"}, deferred)
+	postgresStatement := `CREATE TABLE "bbdataarchive"."_0_t" AS SELECT "t".* FROM t WHERE id = 1;`
+	immediate, deferred = splitDeferredBackupStatements(storepb.Engine_POSTGRES, postgresStatement)
+	a.Empty(immediate)
+	a.Equal([]string{postgresStatement}, deferred)
+
+	// The failed data update with the backup undone drops the backup tables created before the transaction on MySQL.
+	items := []*storepb.PriorBackupDetail_Item{
+		{TargetTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: "_0_t"}},
+	}
+	a.Equal([]string{"DROP TABLE IF EXISTS `bbdataarchive`.`_0_t`;"}, getUndoneBackupStatements(storepb.Engine_MYSQL, items))
+	a.Empty(getUndoneBackupStatements(storepb.Engine_POSTGRES, items))
+	a.Equal([]string{"instances/i/databases/bbdataarchive"}, getBackupTargetDatabases(append(items, items[0])))
+}
+
+func TestCheckDeferredBackupStatement(t *testing.T) {
+	a := require.New(t)
+	a.NoError(checkDeferredBackupStatement(storepb.Engine_MYSQL, "UPDATE t SET a = 1 WHERE id = 1;"))
+	a.NoError(checkDeferredBackupStatement(storepb.Engine_POSTGRES, "UPDATE t SET a = 1 WHERE id = 1;"))
+	a.NoError(checkDeferredBackupStatement(storepb.Engine_POSTGRES, "UPDATE t SET a = 1; DELETE FROM t WHERE id = 2;"))
+
+	// MySQL runs no transaction for the empty statement, so the deferred backup would be dropped.
+	a.Error(checkDeferredBackupStatement(storepb.Engine_MYSQL, ""))
+	a.Error(checkDeferredBackupStatement(storepb.Engine_MYSQL, "-- nothing to update\n"))
+	// Postgres runs the PL/pgSQL block outside of the transaction.
+	a.Error(checkDeferredBackupStatement(storepb.Engine_POSTGRES, "DO $$ BEGIN UPDATE t SET a = 1; END $$;"))
+}
+
+func TestExecuteBackupStatementStrategy(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "bbdataarchive"."_0_t" AS SELECT "t".* FROM t WHERE id = 1;`,
+		SourceTableName: "t",
+		TargetTableName: "_0_t",
+	}
+
+	driver := &statementDriver{}
+	strategy, fallbackReason, _, err := executeBackupStatement(ctx, driver, storepb.Engine_POSTGRES, "bbdataarchive", statement, backupStrategyStatement, &backupOptions{})
+	a.NoError(err)
+	a.Equal(storepb.PriorBackupDetail_Item_STATEMENT, strategy.toProto())
+	a.Empty(fallbackReason)
+	a.Equal([]string{statement.Statement}, driver.statements)
+
+	// The bulk copy falls back to executing the statement if the driver cannot bulk copy.
+	driver = &statementDriver{}
+	strategy, fallbackReason, _, err = executeBackupStatement(ctx, driver, storepb.Engine_POSTGRES, "bbdataarchive", statement, backupStrategyBulkCopy, &backupOptions{})
+	a.NoError(err)
+	a.Equal(storepb.PriorBackupDetail_Item_STATEMENT, strategy.toProto())
+	a.Equal("the bulk copy is not supported by the POSTGRES driver", fallbackReason)
+	a.Equal([]string{statement.Statement}, driver.statements)
+
+	// The snapshot transaction cannot bulk copy either.
+	_, _, fallbackReason = getBulkCopyDriver(&BackupSnapshot{Driver: driver}, storepb.Engine_POSTGRES, "bbdataarchive", statement, &backupOptions{})
+	a.Equal("the bulk copy cannot read the consistent snapshot of the source tables", fallbackReason)
+	_, _, fallbackReason = getBulkCopyDriver(driver, storepb.Engine_POSTGRES, "bbdataarchive", base.BackupStatement{Statement: "SELECT 1;", TargetTableName: "_0_t"}, &backupOptions{})
+	a.Equal("the bulk copy doesn't support the backup statement", fallbackReason)
+
+	a.Equal(storepb.PriorBackupDetail_Item_BULK_COPY, backupStrategyBulkCopy.toProto())
+	a.Equal(storepb.PriorBackupDetail_Item_DEFERRED, backupStrategyDeferred.toProto())
+}
+
+func TestExecuteBackupStatementTimeout(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "bbdataarchive"."_0_t" AS SELECT "t".* FROM t WHERE id = 1;`,
+		SourceTableName: "t",
+		TargetTableName: "_0_t",
+	}
+
+	// Either timeout alone bounds the statement.
+	for _, opts := range []*backupOptions{
+		{readTimeout: 10 * time.Millisecond},
+		{writeTimeout: 10 * time.Millisecond},
+		{readTimeout: 10 * time.Millisecond, writeTimeout: 10 * time.Millisecond},
+	} {
+		_, _, _, err := executeBackupStatement(ctx, &slowStatementDriver{}, storepb.Engine_POSTGRES, "bbdataarchive", statement, backupStrategyStatement, opts)
+		a.ErrorIs(err, context.DeadlineExceeded)
+	}
+}
+
+// slowStatementDriver is a driver blocking the statements after the first ones until the context is done.
+type slowStatementDriver struct {
+	db.Driver
+
+	fast       int
+	statements []string
+}
+
+func (d *slowStatementDriver) Execute(ctx context.Context, statement string, _ db.ExecuteOptions) (int64, error) {
+	if len(d.statements) >= d.fast {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	d.statements = append(d.statements, statement)
+	return 0, nil
+}
+
+func TestWithBackupTimeout(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	statements := []base.BackupStatement{
+		{Statement: `CREATE TABLE "bbdataarchive"."_0_t1" AS SELECT "t1".* FROM t1;`, TargetTableName: "_0_t1"},
+		{Statement: `CREATE TABLE "bbdataarchive"."_0_t2" AS SELECT "t2".* FROM t2;`, TargetTableName: "_0_t2"},
+		{Statement: `CREATE TABLE "bbdataarchive"."_0_t3" AS SELECT "t3".* FROM t3;`, TargetTableName: "_0_t3"},
+	}
+	driver := &slowStatementDriver{fast: 1}
+	var created []string
+	backup := func(driverCtx context.Context) error {
+		for _, statement := range statements {
+			if _, _, _, err := executeBackupStatement(driverCtx, driver, storepb.Engine_POSTGRES, "bbdataarchive", statement, backupStrategyStatement, &backupOptions{}); err != nil {
+				return err
+			}
+			created = append(created, statement.TargetTableName)
+		}
+		return nil
+	}
+
+	// The second statement trips the overall deadline and the remaining statement is aborted.
+	err := withBackupTimeout(ctx, 50*time.Millisecond, backup)
+	var backupTimeoutErr *backupTimeoutError
+	a.True(errors.As(err, &backupTimeoutErr))
+	a.ErrorIs(err, context.DeadlineExceeded)
+	a.Equal([]string{"_0_t1"}, created)
+	a.Equal([]string{statements[0].Statement}, driver.statements)
+
+	// The backup fails without a timeout error if the task is canceled.
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	driver, created = &slowStatementDriver{}, nil
+	err = withBackupTimeout(canceledCtx, time.Minute, backup)
+	a.ErrorIs(err, context.Canceled)
+	a.False(errors.As(err, &backupTimeoutErr))
+
+	// No timeout means no deadline.
+	driver, created = &slowStatementDriver{fast: len(statements)}, nil
+	a.NoError(withBackupTimeout(ctx, 0, backup))
+	a.Equal([]string{"_0_t1", "_0_t2", "_0_t3"}, created)
+}
+
+func TestBackupFailedError(t *testing.T) {
+	a := require.New(t)
+
+	// The failed backup keeps the detail of the backup tables created before the failure, whatever the cause.
+	timeoutErr := &backupTimeoutError{timeout: time.Minute, err: context.DeadlineExceeded}
+	detail := &storepb.PriorBackupDetail{
+		TimedOut: true,
+		Items: []*storepb.PriorBackupDetail_Item{
+			{TargetTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: "_0_t1"}},
+		},
+	}
+	var err error = &backupFailedError{detail: detail, err: errors.Wrap(timeoutErr, "failed to backup database")}
+	var backupFailedErr *backupFailedError
+	a.True(errors.As(err, &backupFailedErr))
+	a.Equal(detail, backupFailedErr.detail)
+	var backupTimeoutErr *backupTimeoutError
+	a.True(errors.As(err, &backupTimeoutErr))
+	a.ErrorIs(err, context.DeadlineExceeded)
+
+	injected := errors.New("injected failure")
+	err = &backupFailedError{detail: detail, err: injected}
+	a.ErrorIs(err, injected)
+	a.Equal(injected.Error(), err.Error())
+}
+
+func TestGetSurrogateKeyBackupStatement(t *testing.T) {
+	a := require.New(t)
+	tests := []struct {
+		engine    storepb.Engine
+		statement string
+		want      string
+		key       storepb.PriorBackupDetail_Item_SurrogateKey
+		ok        bool
+	}{
+		{
+			engine:    storepb.Engine_POSTGRES,
+			statement: `CREATE TABLE "bbdataarchive"."_0_t" AS SELECT "public"."t".* FROM "public"."t" WHERE a = 1;`,
+			want:      `CREATE TABLE "bbdataarchive"."_0_t" AS SELECT "public"."t".*, gen_random_uuid() AS "bb_surrogate_key" FROM "public"."t" WHERE a = 1;`,
+			key:       storepb.PriorBackupDetail_Item_UUID,
+			ok:        true,
+		},
+		{
+			engine:    storepb.Engine_ORACLE,
+			statement: `CREATE TABLE "BBDATAARCHIVE"."_0_T" AS SELECT "x""y".* FROM "T" "x""y" WHERE A = 1;`,
+			want:      `CREATE TABLE "BBDATAARCHIVE"."_0_T" AS SELECT "x""y".*, ROWIDTOCHAR("x""y".ROWID) AS "bb_surrogate_key" FROM "T" "x""y" WHERE A = 1;`,
+			key:       storepb.PriorBackupDetail_Item_ROWID,
+			ok:        true,
+		},
+		{
+			engine:    storepb.Engine_MYSQL,
+			statement: "CREATE TABLE `bbdataarchive`.`_0_t` LIKE `db`.`t`;",
+		},
+		{
+			// The DISTINCT backups of the JOIN-based updates must not get a surrogate key per joined row.
+			engine:    storepb.Engine_POSTGRES,
+			statement: `CREATE TABLE "bbdataarchive"."_0_t" AS SELECT DISTINCT "t".* FROM "t";`,
+		},
+	}
+	for _, test := range tests {
+		got, key, ok := GetSurrogateKeyBackupStatement(test.engine, base.BackupStatement{Statement: test.statement})
+		a.Equal(test.ok, ok, test.statement)
+		a.Equal(test.want, got, test.statement)
+		a.Equal(test.key, key, test.statement)
+	}
+}
+
+// fakeProducer is a producer appending the messages to the in-memory topics.
+type fakeProducer struct {
+	topics map[string][][]byte
+}
+
+func (p *fakeProducer) Produce(_ context.Context, topic string, messages [][]byte) ([]int64, error) {
+	var offsets []int64
+	for _, message := range messages {
+		offsets = append(offsets, int64(len(p.topics[topic])))
+		p.topics[topic] = append(p.topics[topic], message)
+	}
+	return offsets, nil
+}
+
+func TestPublishBeforeImages(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	producer := &fakeProducer{topics: map[string][][]byte{"backup": {[]byte("{}")}}}
+	images := []*beforeImage{
+		{Database: "instances/i/databases/db", Schema: "public", Table: "t", Before: map[string]any{"id": 1, "name": "a"}},
+		{Database: "instances/i/databases/db", Schema: "public", Table: "t", Before: map[string]any{"id": 2, "name": nil}},
+	}
+
+	sink, err := publishBeforeImages(ctx, producer, "backup", images)
+	a.NoError(err)
+	a.Equal("backup", sink.Topic)
+	a.Equal(int64(1), sink.StartOffset)
+	a.Equal(int64(2), sink.EndOffset)
+	a.Len(producer.topics["backup"], 3)
+	a.JSONEq(`{"database":"instances/i/databases/db","schema":"public","table":"t","before":{"id":1,"name":"a"}}`, string(producer.topics["backup"][1]))
+	a.JSONEq(`{"database":"instances/i/databases/db","schema":"public","table":"t","before":{"id":2,"name":null}}`, string(producer.topics["backup"][2]))
+
+	// Nothing is published if no rows were affected.
+	sink, err = publishBeforeImages(ctx, producer, "backup", nil)
+	a.NoError(err)
+	a.Equal(int64(-1), sink.StartOffset)
+	a.Equal(int64(-1), sink.EndOffset)
+	a.Len(producer.topics["backup"], 3)
+}
+
+// fakeExportRows is the rows of the fixed values.
+type fakeExportRows struct {
+	columns []string
+	values  [][]any
+	next    int
+}
+
+func (r *fakeExportRows) Columns() ([]string, error) {
+	return r.columns, nil
+}
+
+func (r *fakeExportRows) Next() bool {
+	r.next++
+	return r.next <= len(r.values)
+}
+
+func (r *fakeExportRows) Scan(dest ...any) error {
+	for i, value := range r.values[r.next-1] {
+		*dest[i].(*any) = value
+	}
+	return nil
+}
+
+func (*fakeExportRows) Err() error {
+	return nil
+}
+
+func TestWriteBackupExport(t *testing.T) {
+	a := require.New(t)
+	rows := &fakeExportRows{
+		columns: []string{"id", "name", "created_at"},
+		values: [][]any{
+			{int64(1), []byte("a,b"), time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{int64(2), nil, "2024-01-02"},
+		},
+	}
+	var buf bytes.Buffer
+	columns, rowCount, err := writeBackupExport(&buf, rows, nil)
+	a.NoError(err)
+	a.Equal([]string{"id", "name", "created_at"}, columns)
+	a.Equal(int64(2), rowCount)
+
+	reader, err := gzip.NewReader(&buf)
+	a.NoError(err)
+	data, err := io.ReadAll(reader)
+	a.NoError(err)
+	a.Equal("id,name,created_at\n1,\"a,b\",2024-01-02T03:04:05Z\n2,\\N,2024-01-02\n", string(data))
+
+	// The export without the affected rows only has the header.
+	buf.Reset()
+	_, rowCount, err = writeBackupExport(&buf, &fakeExportRows{columns: []string{"id"}}, nil)
+	a.NoError(err)
+	a.Zero(rowCount)
+}
+
+// fakeObjectStore is an in-memory object store. The uploads fail with uploadErr after reading the first bytes if it's set.
+type fakeObjectStore struct {
+	objects   map[string][]byte
+	uploadErr error
+}
+
+func (s *fakeObjectStore) PutObject(_ context.Context, key string, data []byte) error {
+	s.objects[key] = data
+	return nil
+}
+
+func (s *fakeObjectStore) ListObjects(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *fakeObjectStore) GetObject(_ context.Context, key string) ([]byte, error) {
+	return s.objects[key], nil
+}
+
+func (s *fakeObjectStore) UploadObject(_ context.Context, key string, body io.Reader) error {
+	if s.uploadErr != nil {
+		if _, err := body.Read(make([]byte, 1)); err != nil {
+			return err
+		}
+		return s.uploadErr
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	s.objects[key] = data
+	return nil
+}
+
+func (s *fakeObjectStore) DownloadObject(_ context.Context, key string) (io.ReadCloser, error) {
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, errors.Errorf("object %q not found", key)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func TestUploadBackupExport(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	newRows := func(n int) *fakeExportRows {
+		rows := &fakeExportRows{columns: []string{"id"}}
+		for i := 0; i < n; i++ {
+			rows.values = append(rows.values, []any{int64(i)})
+		}
+		return rows
+	}
+
+	// The rows are streamed to the object store.
+	objectStore := &fakeObjectStore{objects: map[string][]byte{}}
+	columns, rowCount, err := uploadBackupExport(ctx, objectStore, "0_t.csv.gz", newRows(100000), nil)
+	a.NoError(err)
+	a.Equal([]string{"id"}, columns)
+	a.Equal(int64(100000), rowCount)
+	reader, err := gzip.NewReader(bytes.NewReader(objectStore.objects["0_t.csv.gz"]))
+	a.NoError(err)
+	data, err := io.ReadAll(reader)
+	a.NoError(err)
+	a.Equal(100001, strings.Count(string(data), "\n"))
+
+	// The export stops when the upload fails.
+	rows := newRows(100000)
+	objectStore = &fakeObjectStore{objects: map[string][]byte{}, uploadErr: errors.New("connection reset")}
+	_, _, err = uploadBackupExport(ctx, objectStore, "0_t.csv.gz", rows, nil)
+	a.ErrorContains(err, "connection reset")
+	a.Less(rows.next, 100000)
+	a.Empty(objectStore.objects)
+
+	// The upload fails with the error of the export.
+	_, _, err = uploadBackupExport(ctx, &fakeObjectStore{objects: map[string][]byte{}}, "0_t.csv.gz", &failingExportRows{}, nil)
+	a.ErrorContains(err, "failed to scan backup row")
+}
+
+// failingExportRows is the rows failing to scan.
+type failingExportRows struct {
+	fakeExportRows
+}
+
+func (*failingExportRows) Next() bool {
+	return true
+}
+
+func (*failingExportRows) Scan(...any) error {
+	return errors.New("invalid row")
+}
+
+// recordingExecer records the statements and their arguments.
+type recordingExecer struct {
+	statements []string
+	args       [][]any
+}
+
+func (e *recordingExecer) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	e.statements = append(e.statements, query)
+	e.args = append(e.args, args)
+	return nil, nil
+}
+
+func TestStageBackupExports(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	var buf bytes.Buffer
+	_, _, err := writeBackupExport(&buf, &fakeExportRows{
+		columns: []string{"id", "name"},
+		values:  [][]any{{int64(1), "a"}, {int64(2), nil}, {int64(3), "c"}},
+	}, nil)
+	a.NoError(err)
+	objectStore := &fakeObjectStore{objects: map[string][]byte{"exports/0_t.csv.gz": buf.Bytes()}}
+	detail := &storepb.PriorBackupDetail{
+		ExportManifestKey: "exports/manifest.json",
+		Items: []*storepb.PriorBackupDetail_Item{
+			{
+				SourceTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Schema: "s", Table: "t"},
+				ObjectKey:   "exports/0_t.csv.gz",
+			},
+		},
+	}
+
+	// The exported rows are inserted into the staging table in batches, which is then restored from like a backup table.
+	execer := &recordingExecer{}
+	staged, dropStatements, err := stageBackupExports(ctx, execer, objectStore, storepb.Engine_POSTGRES, 7, "0a1b2c3d", detail)
+	a.NoError(err)
+	a.Equal("instances/i/databases/bbdataarchive", staged.Items[0].GetTargetTable().GetDatabase())
+	a.Equal("_rollback_7_0a1b2c3d_0_t", staged.Items[0].GetTargetTable().GetTable())
+	a.Nil(detail.Items[0].GetTargetTable())
+	a.Equal([]string{`DROP TABLE IF EXISTS "bbdataarchive"."_rollback_7_0a1b2c3d_0_t"`}, dropStatements)
+	a.Equal([]string{
+		`CREATE SCHEMA IF NOT EXISTS "bbdataarchive"`,
+		`CREATE TABLE "bbdataarchive"."_rollback_7_0a1b2c3d_0_t" AS SELECT "id", "name" FROM "s"."t" WITH NO DATA`,
+		`INSERT INTO "bbdataarchive"."_rollback_7_0a1b2c3d_0_t" SELECT * FROM json_populate_recordset(NULL::"bbdataarchive"."_rollback_7_0a1b2c3d_0_t", $1::json)`,
+	}, execer.statements)
+	a.Equal([]any{`[{"id":"1","name":"a"},{"id":"2","name":null},{"id":"3","name":"c"}]`}, execer.args[2])
+
+	// The details without the exports are restored from the backup tables as is.
+	staged, dropStatements, err = stageBackupExports(ctx, execer, nil, storepb.Engine_POSTGRES, 7, "0a1b2c3d", &storepb.PriorBackupDetail{})
+	a.NoError(err)
+	a.NotNil(staged)
+	a.Empty(dropStatements)
+
+	_, _, err = stageBackupExports(ctx, execer, nil, storepb.Engine_POSTGRES, 7, "0a1b2c3d", detail)
+	a.ErrorContains(err, "prior backup object store is not configured")
+	_, _, err = stageBackupExports(ctx, execer, objectStore, storepb.Engine_MYSQL, 7, "0a1b2c3d", detail)
+	a.ErrorContains(err, "backup export is not supported for engine MYSQL")
+	// The staging table is dropped if staging fails.
+	_, dropStatements, err = stageBackupExports(ctx, execer, &fakeObjectStore{objects: map[string][]byte{}}, storepb.Engine_POSTGRES, 7, "0a1b2c3d", detail)
+	a.ErrorContains(err, `failed to stage prior backup export "exports/0_t.csv.gz"`)
+	a.Len(dropStatements, 1)
+
+	// The long source table name is truncated to fit the identifier length.
+	detail.Items[0].SourceTable.Table = strings.Repeat("t", 70)
+	staged, _, err = stageBackupExports(ctx, &recordingExecer{}, objectStore, storepb.Engine_POSTGRES, 7, "0a1b2c3d", detail)
+	a.NoError(err)
+	a.Equal("_rollback_7_0a1b2c3d_0_"+strings.Repeat("t", 40), staged.Items[0].GetTargetTable().GetTable())
+	a.Len(staged.Items[0].GetTargetTable().GetTable(), maximumPostgresIdentifierLength)
+}
+
+func TestBackupExportReaderBatches(t *testing.T) {
+	a := require.New(t)
+	var buf bytes.Buffer
+	_, _, err := writeBackupExport(&buf, &fakeExportRows{
+		columns: []string{"id"},
+		values:  [][]any{{int64(1)}, {int64(2)}, {int64(3)}},
+	}, nil)
+	a.NoError(err)
+	reader, err := newBackupExportReader(&buf)
+	a.NoError(err)
+	a.Equal([]string{"id"}, reader.columns)
+	var batches []string
+	for {
+		batch, err := reader.nextBatch(2)
+		a.NoError(err)
+		if batch == nil {
+			break
+		}
+		batches = append(batches, string(batch))
+	}
+	a.Equal([]string{`[{"id":"1"},{"id":"2"}]`, `[{"id":"3"}]`}, batches)
+}
+
+func TestBackupExportRoundTrip(t *testing.T) {
+	a := require.New(t)
+	// The binary values aren't valid UTF-8, and the text values look like NULL or the binary values.
+	rows := &fakeExportRows{
+		columns: []string{"id", "data", "name"},
+		values: [][]any{
+			{int64(1), []byte{0xff, 0x00, '\\'}, `\N`},
+			{int64(2), []byte(`\N`), `\x41`},
+			{int64(3), nil, nil},
+			{int64(4), []byte{}, `\\`},
+		},
+	}
+	var buf bytes.Buffer
+	_, _, err := writeBackupExport(&buf, rows, map[int]bool{1: true})
+	a.NoError(err)
+	reader, err := newBackupExportReader(&buf)
+	a.NoError(err)
+	batch, err := reader.nextBatch(10)
+	a.NoError(err)
+	a.JSONEq(`[
+		{"id":"1","data":"\\xff005c","name":"\\N"},
+		{"id":"2","data":"\\x5c4e","name":"\\x41"},
+		{"id":"3","data":null,"name":null},
+		{"id":"4","data":"\\x","name":"\\\\"}
+	]`, string(batch))
+
+	value, err := decodeBackupExportValue(`\xff005c`)
+	a.NoError(err)
+	a.Equal([]byte{0xff, 0x00, '\\'}, value)
+	_, err = decodeBackupExportValue(`\xzz`)
+	a.ErrorContains(err, "failed to decode binary value")
+	_, err = decodeBackupExportValue(`\t`)
+	a.ErrorContains(err, "invalid escaped value")
+}
+
+func TestGetPriorBackupComment(t *testing.T) {
+	a := require.New(t)
+	issue := &store.IssueMessage{UID: 1, Project: &store.ProjectMessage{ResourceID: "p"}}
+	task := &store.TaskMessage{ID: 4, PipelineID: 2, StageID: 3}
+	// The consolidated comment lists all the backup tables of a multi-table backup.
+	comment := getPriorBackupComment(issue, task, "bbdataarchive", []*storepb.IssueCommentPayload_TaskPriorBackup_Table{
+		{Table: "_0_0_t1"},
+		{Table: "_0_1_t2"},
+		{Table: "_0_2_t3"},
+	})
+	a.Equal(1, comment.IssueUID)
+	backup := comment.Payload.GetTaskPriorBackup()
+	a.Equal("projects/p/rollouts/2/stages/3/tasks/4", backup.GetTask())
+	a.Equal("bbdataarchive", backup.GetDatabase())
+	var tables []string
+	for _, table := range backup.GetTables() {
+		tables = append(tables, table.GetTable())
+	}
+	a.Equal([]string{"_0_0_t1", "_0_1_t2", "_0_2_t3"}, tables)
+
+	// The comment tables are in the schemas of the backup tables.
+	commentTables := getPriorBackupCommentTables([]*storepb.PriorBackupDetail_Item{
+		{TargetTable: &storepb.PriorBackupDetail_Item_Table{Schema: "s1", Table: "_0_0_t1"}},
+		{TargetTable: &storepb.PriorBackupDetail_Item_Table{Schema: "s2", Table: "_0_1_t2"}, Lightweight: true},
+		{TargetTable: &storepb.PriorBackupDetail_Item_Table{Table: "_0_2_t3"}},
+	})
+	a.Len(commentTables, 2)
+	a.Equal("s1", commentTables[0].GetSchema())
+	a.Equal("_0_0_t1", commentTables[0].GetTable())
+	a.Equal("", commentTables[1].GetSchema())
+	a.Equal("_0_2_t3", commentTables[1].GetTable())
+}
+
+func TestGetBackupCostComment(t *testing.T) {
+	a := require.New(t)
+	metadata := &storepb.DatabaseSchemaMetadata{
+		Schemas: []*storepb.SchemaMetadata{
+			{
+				Name: "public",
+				Tables: []*storepb.TableMetadata{
+					{Name: "t1", RowCount: 1000, DataSize: 64 * 1024 * 1024},
+					{Name: "t2", RowCount: 10, DataSize: 8192},
+				},
+			},
+		},
+	}
+	statements := []base.BackupStatement{
+		{SourceSchema: "public", SourceTableName: "t1"},
+		{SourceTableName: "t2"},
+		{SourceSchema: "public", SourceTableName: "t3"},
+	}
+	estimates := EstimateBackupImpact(storepb.Engine_POSTGRES, statements, metadata)
+	a.Len(estimates, 3)
+	a.Equal(int64(1000), estimates[0].Rows)
+	a.Equal(int64(64*1024*1024), estimates[0].Bytes)
+	a.Equal(2*time.Second, estimates[0].Duration.AsDuration())
+	a.Equal(int64(10), estimates[1].Rows)
+	a.True(estimates[2].Unknown)
+
+	issue := &store.IssueMessage{UID: 1, Project: &store.ProjectMessage{ResourceID: "p"}}
+	task := &store.TaskMessage{ID: 4, PipelineID: 2, StageID: 3}
+	comment := getBackupCostComment(issue, task, estimates)
+	a.Equal(1, comment.IssueUID)
+	a.Equal("Estimated prior backup cost:\n- public.t1: up to 1000 rows, 67108864 bytes, 2s\n- t2: up to 10 rows, 8192 bytes, 0s\n- public.t3: unknown", comment.Payload.Comment)
+	event := comment.Payload.GetTaskPriorBackupEstimate()
+	a.Equal("projects/p/rollouts/2/stages/3/tasks/4", event.Task)
+	a.Equal(estimates, event.Tables)
+}
+
+func TestBackupBudget(t *testing.T) {
+	a := require.New(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	deadline := now.Add(time.Minute)
+	estimate := getBackupDurationEstimate([]*storepb.IssueCommentPayload_TaskPriorBackupEstimate_Table{
+		{Table: "t1", Duration: durationpb.New(time.Minute)},
+		{Table: "t2", Duration: durationpb.New(30 * time.Second)},
+		{Table: "t3", Unknown: true},
+	})
+	a.Equal(90*time.Second, estimate)
+
+	// The backup fitting in the remaining budget is neither warned about nor deferred.
+	warning, err := getBackupBudgetVerdict(backupBudgetDefer, time.Minute, deadline, now)
+	a.NoError(err)
+	a.Empty(warning)
+
+	// The backup exceeding the remaining budget defers the task by the DEFER policy.
+	_, err = getBackupBudgetVerdict("defer", estimate, deadline, now)
+	var deferredErr *backupDeferredError
+	a.ErrorAs(err, &deferredErr)
+	a.Equal(now.Add(backupBudgetRecheckInterval), deferredErr.until)
+	a.ErrorContains(err, "prior backup is deferred by the remaining time budget")
+
+	// The backup exceeding the remaining budget is warned about by default.
+	warning, err = getBackupBudgetVerdict("", estimate, deadline, now)
+	a.NoError(err)
+	a.Equal("the prior backup is estimated to take 1m30s, more than the remaining time budget 1m0s before 2024-01-01T12:01:00Z", warning)
+
+	// The budget past its deadline has no time remaining.
+	warning, err = getBackupBudgetVerdict(backupBudgetWarn, time.Second, now.Add(-time.Hour), now)
+	a.NoError(err)
+	a.Contains(warning, "remaining time budget 0s")
+
+	_, err = getBackupBudgetVerdict("SKIP", estimate, deadline, now)
+	a.ErrorContains(err, `unsupported backup budget policy "SKIP"`)
+}
+
+// snapshotDriver is a driver supporting the native database snapshots.
+type snapshotDriver struct {
+	statementDriver
+
+	snapshots []string
+}
+
+func (d *snapshotDriver) CreateDatabaseSnapshot(_ context.Context, database string) (string, error) {
+	d.snapshots = append(d.snapshots, database)
+	return fmt.Sprintf("%s-snapshot-%d", database, len(d.snapshots)), nil
+}
+
+func TestCreateBackupDatabaseSnapshot(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	rows := getBackupRowsEstimate([]*storepb.IssueCommentPayload_TaskPriorBackupEstimate_Table{
+		{Table: "t1", Rows: 800},
+		{Table: "t2", Rows: 200},
+		{Table: "t3", Unknown: true},
+	})
+	a.Equal(int64(1000), rows)
+
+	// The snapshot-capable driver clones the database instead of executing the backup statements.
+	driver := &snapshotDriver{}
+	snapshot, err := CreateBackupDatabaseSnapshot(ctx, driver, "instances/i/databases/db", "db", rows, 1000)
+	a.NoError(err)
+	a.Equal("instances/i/databases/db", snapshot.GetDatabase())
+	a.Equal("db-snapshot-1", snapshot.GetSnapshot())
+	a.Equal("the source tables are estimated to have 1000 rows, at least 1000", snapshot.GetReason())
+	a.Equal([]string{"db"}, driver.snapshots)
+	a.Empty(driver.statements)
+
+	// The smaller changes are copied.
+	snapshot, err = CreateBackupDatabaseSnapshot(ctx, driver, "instances/i/databases/db", "db", rows, 1001)
+	a.NoError(err)
+	a.Nil(snapshot)
+	snapshot, err = CreateBackupDatabaseSnapshot(ctx, driver, "instances/i/databases/db", "db", rows, 0)
+	a.NoError(err)
+	a.Nil(snapshot)
+	a.Len(driver.snapshots, 1)
+
+	// The drivers without the snapshots fall back to copying the rows.
+	snapshot, err = CreateBackupDatabaseSnapshot(ctx, &statementDriver{}, "instances/i/databases/db", "db", rows, 1000)
+	a.NoError(err)
+	a.Nil(snapshot)
+}
+
+func TestExcludeBackupColumns(t *testing.T) {
+	a := require.New(t)
+	config := &storepb.DatabaseConfig{
+		SchemaConfigs: []*storepb.SchemaConfig{
+			{
+				Name: "public",
+				TableConfigs: []*storepb.TableConfig{
+					{
+						Name: "users",
+						ColumnConfigs: []*storepb.ColumnConfig{
+							{Name: "password", Labels: map[string]string{"no-backup": "true"}},
+							{Name: "email", Labels: map[string]string{"no-backup": "false"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	table := &storepb.TableMetadata{
+		Name: "users",
+		Columns: []*storepb.ColumnMetadata{
+			{Name: "id"},
+			{Name: "email"},
+			{Name: "password"},
+		},
+	}
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "bbdataarchive"."_0_users" AS SELECT "public"."users".* FROM "public"."users" WHERE id = 1;`,
+		SourceSchema:    "public",
+		SourceTableName: "users",
+	}
+
+	noBackupColumns := getNoBackupColumns(storepb.Engine_POSTGRES, statement, config)
+	a.Equal(map[string]bool{"password": true}, noBackupColumns)
+	a.Empty(getNoBackupColumns(storepb.Engine_POSTGRES, base.BackupStatement{SourceTableName: "orders"}, config))
+
+	excluded, ok := excludeBackupColumns(storepb.Engine_POSTGRES, statement, table, noBackupColumns)
+	a.True(ok)
+	a.Equal(`CREATE TABLE "bbdataarchive"."_0_users" AS SELECT "public"."users"."id", "public"."users"."email", CASE WHEN FALSE THEN "public"."users"."password" END AS "password" FROM "public"."users" WHERE id = 1;`, excluded)
+
+	// The surrogate key is kept after the columns.
+	keyed, _, ok := GetSurrogateKeyBackupStatement(storepb.Engine_POSTGRES, statement)
+	a.True(ok)
+	excluded, ok = excludeBackupColumns(storepb.Engine_POSTGRES, base.BackupStatement{Statement: keyed}, table, noBackupColumns)
+	a.True(ok)
+	a.Equal(`CREATE TABLE "bbdataarchive"."_0_users" AS SELECT "public"."users"."id", "public"."users"."email", CASE WHEN FALSE THEN "public"."users"."password" END AS "password", gen_random_uuid() AS "bb_surrogate_key" FROM "public"."users" WHERE id = 1;`, excluded)
+
+	// The columns cannot be excluded on the other engines.
+	_, ok = excludeBackupColumns(storepb.Engine_MYSQL, statement, table, noBackupColumns)
+	a.False(ok)
+}
+
+func TestBackupSessionRole(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "bbdataarchive"."_0_t" AS SELECT "t".* FROM t WHERE id = 1;`,
+		SourceTableName: "t",
+		TargetTableName: "_0_t",
+	}
+
+	// The task overrides the configured role, and the engines without session role switching have no role.
+	a.Equal("backup_role", getBackupSessionRole(storepb.Engine_POSTGRES, "backup_role", ""))
+	a.Equal("task_role", getBackupSessionRole(storepb.Engine_MSSQL, "backup_role", "task_role"))
+	a.Empty(getBackupSessionRole(storepb.Engine_MYSQL, "backup_role", "task_role"))
+
+	// The backup statement runs under the role set for its transaction on Postgres, even if bulk copy is preferred.
+	driver := &statementDriver{}
+	strategy, fallbackReason, _, err := executeBackupStatement(ctx, driver, storepb.Engine_POSTGRES, "bbdataarchive", statement, backupStrategyBulkCopy, &backupOptions{sessionRole: `backup"role`})
+	a.NoError(err)
+	a.Equal(backupStrategyStatement, strategy)
+	a.Equal("the bulk copy doesn't switch to the session role", fallbackReason)
+	a.Equal([]string{statement.Statement}, driver.statements)
+	a.Equal([]string{`SET LOCAL ROLE "backup""role"`}, driver.preludes)
+
+	// The role is not switched if it's not set.
+	driver = &statementDriver{}
+	_, _, _, err = executeBackupStatement(ctx, driver, storepb.Engine_POSTGRES, "bbdataarchive", statement, backupStrategyStatement, &backupOptions{})
+	a.NoError(err)
+	a.Empty(driver.preludes)
+
+	mssqlStatement, opts := withBackupSessionRole(storepb.Engine_MSSQL, "backup_role", "SELECT * INTO [bbdataarchive].[dbo].[_0_t] FROM [t];", db.ExecuteOptions{})
+	a.Equal("EXECUTE AS USER = N'backup_role';\nSELECT * INTO [bbdataarchive].[dbo].[_0_t] FROM [t];\nREVERT;", mssqlStatement)
+	a.Empty(opts.PreludeStatements)
+}
+
+func TestInjectFailure(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+
+	// The failure injection is a no-op without the failure hook.
+	exec := &DataUpdateExecutor{profile: &config.Profile{}}
+	a.NoError(exec.injectFailure(failurePointBackup))
+
+	injected := errors.New("injected failure")
+	var points []failurePoint
+	exec.failureHook = func(point failurePoint) error {
+		points = append(points, point)
+		if point == failurePointBackup {
+			return injected
+		}
+		return nil
+	}
+	payload := &storepb.TaskDatabaseUpdatePayload{
+		PreUpdateBackupDetail: &storepb.PreUpdateBackupDetail{Database: "instances/i/databases/bbdataarchive"},
+	}
+	_, _, err := exec.backupData(ctx, ctx, "UPDATE t SET a = 1;", payload, storepb.TaskDatabaseUpdatePayload_REQUIRED, &store.TaskMessage{}, 0)
+	a.ErrorIs(err, injected)
+	a.Equal([]failurePoint{failurePointBackup}, points)
+	// The backup phase failure fails the task only if the backup is required.
+	a.ErrorIs(checkBackupShortfall(storepb.TaskDatabaseUpdatePayload_REQUIRED, err), injected)
+	a.NoError(checkBackupShortfall(storepb.TaskDatabaseUpdatePayload_BEST_EFFORT, err))
+
+	// The failure points are not reached if the backup is disabled.
+	points = nil
+	_, _, err = exec.backupData(ctx, ctx, "UPDATE t SET a = 1;", payload, storepb.TaskDatabaseUpdatePayload_DISABLED, &store.TaskMessage{}, 0)
+	a.NoError(err)
+	a.Empty(points)
+}
+
+func TestBackupTablesFailure(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	stateCfg, err := state.New()
+	a.NoError(err)
+	statements := []base.BackupStatement{
+		{Statement: "CREATE TABLE `bbdataarchive`.`_0_0_t1` LIKE `db`.`t1`; INSERT INTO `bbdataarchive`.`_0_0_t1` SELECT * FROM `db`.`t1`;", SourceTableName: "t1", TargetTableName: "_0_0_t1"},
+		{Statement: "CREATE TABLE `bbdataarchive`.`_0_1_t2` LIKE `db`.`t2`; INSERT INTO `bbdataarchive`.`_0_1_t2` SELECT * FROM `db`.`t2`;", SourceTableName: "t2", TargetTableName: "_0_1_t2"},
+		{Statement: "CREATE TABLE `bbdataarchive`.`_0_2_t3` LIKE `db`.`t3`; INSERT INTO `bbdataarchive`.`_0_2_t3` SELECT * FROM `db`.`t3`;", SourceTableName: "t3", TargetTableName: "_0_2_t3"},
+	}
+	// failAt fails the second time the failure point is reached, i.e. after the first table is backed up.
+	failAt := func(exec *DataUpdateExecutor, failed failurePoint) {
+		reached := 0
+		exec.failureHook = func(point failurePoint) error {
+			if point != failed {
+				return nil
+			}
+			reached++
+			if reached == 2 {
+				return errors.Errorf("injected %s failure", point)
+			}
+			return nil
+		}
+	}
+	getTables := func(items []*storepb.PriorBackupDetail_Item) []string {
+		var tables []string
+		for _, item := range items {
+			tables = append(tables, item.GetTargetTable().GetTable())
+		}
+		return tables
+	}
+
+	for _, tc := range []struct {
+		point failurePoint
+		// want are the backup tables tracked after the failure.
+		want []string
+	}{
+		// The second backup table is not created.
+		{point: failurePointBackupStatement, want: []string{"_0_0_t1"}},
+		// The second backup table is created before tagging it fails.
+		{point: failurePointComment, want: []string{"_0_0_t1", "_0_1_t2"}},
+	} {
+		// The queries of the source table storage and the online DDL fail without breaking the backup.
+		sqlDB, err := sql.Open("sqlite3", ":memory:")
+		a.NoError(err)
+		defer sqlDB.Close()
+		driver := &connectedDriver{Driver: &statementDriver{}, sqlDB: sqlDB}
+		exec := &DataUpdateExecutor{profile: &config.Profile{}, stateCfg: stateCfg, clock: realClock{}}
+		failAt(exec, tc.point)
+		items, _, err := exec.backupTables(ctx, &backupTableContext{
+			instance:           &store.InstanceMessage{ResourceID: "i", Engine: storepb.Engine_MYSQL},
+			database:           &store.DatabaseMessage{DatabaseName: "db"},
+			issue:              &store.IssueMessage{UID: 1, Project: &store.ProjectMessage{ResourceID: "p"}},
+			sourceDatabaseName: "instances/i/databases/db",
+			targetDatabaseName: "instances/i/databases/bbdataarchive",
+			backupDatabaseName: "bbdataarchive",
+			driver:             driver,
+			backupDriver:       driver,
+			executor:           driver,
+			opts:               &backupOptions{},
+		}, statements)
+		a.ErrorContains(err, fmt.Sprintf("injected %s failure", tc.point))
+		a.Equal(tc.want, getTables(items), tc.point)
+		for _, item := range items {
+			a.Equal("instances/i/databases/bbdataarchive", item.GetTargetTable().GetDatabase())
+			a.Equal("instances/i/databases/db", item.GetSourceTable().GetDatabase())
+		}
+		// The third table is not backed up after the failure.
+		a.NotContains(strings.Join(driver.Driver.(*statementDriver).statements, "\n"), "_0_2_t3")
+	}
+
+	// The second backup table is created before making it durable fails.
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	a.NoError(err)
+	defer sqlDB.Close()
+	driver := &connectedDriver{Driver: &failingStatementDriver{failure: `"_0_1_t2" SET LOGGED`}, sqlDB: sqlDB}
+	exec := &DataUpdateExecutor{profile: &config.Profile{PriorBackupReducedDurability: true}, stateCfg: stateCfg, clock: realClock{}}
+	items, _, err := exec.backupTables(ctx, &backupTableContext{
+		instance:           &store.InstanceMessage{ResourceID: "i", Engine: storepb.Engine_POSTGRES},
+		database:           &store.DatabaseMessage{DatabaseName: "db"},
+		issue:              &store.IssueMessage{UID: 1, Project: &store.ProjectMessage{ResourceID: "p"}},
+		sourceDatabaseName: "instances/i/databases/db",
+		targetDatabaseName: "instances/i/databases/bbdataarchive",
+		backupDatabaseName: "bbdataarchive",
+		driver:             driver,
+		backupDriver:       driver,
+		executor:           driver,
+		opts:               &backupOptions{},
+	}, []base.BackupStatement{
+		{Statement: `CREATE TABLE "bbdataarchive"."_0_0_t1" AS SELECT "t1".* FROM t1;`, SourceTableName: "t1", TargetTableName: "_0_0_t1"},
+		{Statement: `CREATE TABLE "bbdataarchive"."_0_1_t2" AS SELECT "t2".* FROM t2;`, SourceTableName: "t2", TargetTableName: "_0_1_t2"},
+		{Statement: `CREATE TABLE "bbdataarchive"."_0_2_t3" AS SELECT "t3".* FROM t3;`, SourceTableName: "t3", TargetTableName: "_0_2_t3"},
+	})
+	a.ErrorContains(err, `failed to make backup table "_0_1_t2" durable`)
+	a.Equal([]string{"_0_0_t1", "_0_1_t2"}, getTables(items))
+
+	// The backup tables are rolled back with the snapshot transaction on Postgres only.
+	items = []*storepb.PriorBackupDetail_Item{{TargetTable: &storepb.PriorBackupDetail_Item_Table{Table: "_0_0_t1"}}}
+	a.Nil(getFailedBackupItems(storepb.Engine_POSTGRES, &BackupSnapshot{}, items))
+	a.Equal(items, getFailedBackupItems(storepb.Engine_MYSQL, &BackupSnapshot{}, items))
+	a.Equal(items, getFailedBackupItems(storepb.Engine_POSTGRES, nil, items))
+}
+
+func TestGetPartitionedBackupStatements(t *testing.T) {
+	a := require.New(t)
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "bbdataarchive"."_0_t" AS SELECT "t".* FROM t WHERE a > 0;`,
+		SourceTableName: "t",
+		TargetTableName: "_0_t",
+	}
+	bounds := []string{"250", "500", "750"}
+	parts, ranges, err := GetPartitionedBackupStatements(storepb.Engine_POSTGRES, "bbdataarchive", statement, "id", false, bounds)
+	a.NoError(err)
+	a.Len(parts, 4)
+	a.Len(ranges, 4)
+	a.Equal(`CREATE TABLE "bbdataarchive"."_0_t_p1" AS SELECT "bb_partition".* FROM (SELECT "t".* FROM t WHERE a > 0) AS "bb_partition" WHERE "bb_partition"."id" < '250';`, parts[0].Statement)
+	a.Equal(`CREATE TABLE "bbdataarchive"."_0_t_p2" AS SELECT "bb_partition".* FROM (SELECT "t".* FROM t WHERE a > 0) AS "bb_partition" WHERE "bb_partition"."id" >= '250' AND "bb_partition"."id" < '500';`, parts[1].Statement)
+	a.Equal(`CREATE TABLE "bbdataarchive"."_0_t_p4" AS SELECT "bb_partition".* FROM (SELECT "t".* FROM t WHERE a > 0) AS "bb_partition" WHERE "bb_partition"."id" >= '750';`, parts[3].Statement)
+	for _, part := range parts {
+		a.Equal("t", part.SourceTableName)
+	}
+
+	// Every row is in exactly one part.
+	for id := 1; id <= 1000; id++ {
+		matched := 0
+		for _, r := range ranges {
+			a.Equal("id", r.GetColumn())
+			if r.Start != nil {
+				start, err := strconv.Atoi(r.GetStart())
+				a.NoError(err)
+				if id < start {
+					continue
+				}
+			}
+			if r.End != nil {
+				end, err := strconv.Atoi(r.GetEnd())
+				a.NoError(err)
+				if id >= end {
+					continue
+				}
+			}
+			matched++
+		}
+		a.Equal(1, matched, id)
+	}
+
+	// The later rewrites still apply to the parts.
+	_, _, ok := GetSurrogateKeyBackupStatement(storepb.Engine_POSTGRES, parts[0])
+	a.True(ok)
+
+	_, _, err = GetPartitionedBackupStatements(storepb.Engine_MYSQL, "bbdataarchive", statement, "id", false, bounds)
+	a.Error(err)
+}
+
+func TestGetPartitionedBackupStatementsNullable(t *testing.T) {
+	a := require.New(t)
+	statement := base.BackupStatement{
+		Statement:       `CREATE TABLE "bbdataarchive"."_0_t" AS SELECT "t".* FROM t WHERE a > 0;`,
+		SourceTableName: "t",
+		TargetTableName: "_0_t",
+	}
+	metadata := &storepb.DatabaseSchemaMetadata{
+		Schemas: []*storepb.SchemaMetadata{{
+			Name: "public",
+			Tables: []*storepb.TableMetadata{{
+				Name:    "t",
+				Columns: []*storepb.ColumnMetadata{{Name: "code", Nullable: true}, {Name: "a"}},
+				Indexes: []*storepb.IndexMetadata{{Name: "t_code_key", Expressions: []string{"code"}, Unique: true}},
+			}},
+		}},
+	}
+	column, nullable, ok := getBackupPartitionColumn(storepb.Engine_POSTGRES, statement, metadata)
+	a.True(ok)
+	a.Equal("code", column)
+	a.True(nullable)
+
+	bounds := []string{"250", "500", "750"}
+	parts, ranges, err := GetPartitionedBackupStatements(storepb.Engine_POSTGRES, "bbdataarchive", statement, column, nullable, bounds)
+	a.NoError(err)
+	a.Len(parts, 4)
+	// Postgres sorts NULLs last, so the NULLs are in the last part.
+	a.Equal(`CREATE TABLE "bbdataarchive"."_0_t_p1" AS SELECT "bb_partition".* FROM (SELECT "t".* FROM t WHERE a > 0) AS "bb_partition" WHERE "bb_partition"."code" < '250';`, parts[0].Statement)
+	a.Equal(`CREATE TABLE "bbdataarchive"."_0_t_p4" AS SELECT "bb_partition".* FROM (SELECT "t".* FROM t WHERE a > 0) AS "bb_partition" WHERE ("bb_partition"."code" >= '750' OR "bb_partition"."code" IS NULL);`, parts[3].Statement)
+	a.False(ranges[0].GetNulls())
+	a.True(ranges[3].GetNulls())
+
+	// Every row is in exactly one part, including the rows whose column is NULL.
+	var codes []*int
+	for code := 1; code <= 1000; code++ {
+		codes = append(codes, &code)
+		if code%100 == 0 {
+			codes = append(codes, nil)
+		}
+	}
+	for _, code := range codes {
+		matched := 0
+		for _, r := range ranges {
+			if code == nil {
+				if r.GetNulls() {
+					matched++
+				}
+				continue
+			}
+			if r.Start != nil {
+				start, err := strconv.Atoi(r.GetStart())
+				a.NoError(err)
+				if *code < start {
+					continue
+				}
+			}
+			if r.End != nil {
+				end, err := strconv.Atoi(r.GetEnd())
+				a.NoError(err)
+				if *code >= end {
+					continue
+				}
+			}
+			matched++
+		}
+		a.Equal(1, matched, code)
+	}
+
+	// The primary key takes precedence over the unique keys and is never NULL.
+	metadata.Schemas[0].Tables[0].Indexes = append(metadata.Schemas[0].Tables[0].Indexes, &storepb.IndexMetadata{Name: "t_pkey", Expressions: []string{"a"}, Primary: true, Unique: true})
+	column, nullable, ok = getBackupPartitionColumn(storepb.Engine_POSTGRES, statement, metadata)
+	a.True(ok)
+	a.Equal("a", column)
+	a.False(nullable)
+
+	a.False(isNullsFirst(storepb.Engine_POSTGRES))
+	a.False(isNullsFirst(storepb.Engine_ORACLE))
+	a.True(isNullsFirst(storepb.Engine_MYSQL))
+	a.True(isNullsFirst(storepb.Engine_MSSQL))
+}
+
+func TestBackupLimiter(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	limiter := newBackupLimiter(map[storepb.Engine]int{
+		storepb.Engine_MYSQL:    1,
+		storepb.Engine_POSTGRES: 3,
+	})
+	// blocked returns whether one more database of the engine would wait for the limit.
+	blocked := func(engine storepb.Engine) bool {
+		waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		release, err := limiter.acquire(waitCtx, engine)
+		if err != nil {
+			return true
+		}
+		release()
+		return false
+	}
+
+	var releases []func()
+	release, err := limiter.acquire(ctx, storepb.Engine_MYSQL)
+	a.NoError(err)
+	a.True(blocked(storepb.Engine_MYSQL))
+	releases = append(releases, release)
+	for i := 0; i < 3; i++ {
+		a.False(blocked(storepb.Engine_POSTGRES))
+		release, err := limiter.acquire(ctx, storepb.Engine_POSTGRES)
+		a.NoError(err)
+		releases = append(releases, release)
+	}
+	a.True(blocked(storepb.Engine_POSTGRES))
+	// The engines not in the limits are not limited.
+	for i := 0; i < 10; i++ {
+		_, err := limiter.acquire(ctx, storepb.Engine_TIDB)
+		a.NoError(err)
+	}
+
+	// The released slots can be acquired again.
+	for _, release := range releases {
+		release()
+	}
+	a.False(blocked(storepb.Engine_MYSQL))
+	a.False(blocked(storepb.Engine_POSTGRES))
+
+	// A nil limiter doesn't limit any engines.
+	var unlimited *backupLimiter
+	_, err = unlimited.acquire(ctx, storepb.Engine_MYSQL)
+	a.NoError(err)
+}
+
+func TestApplyFullTableBackup(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	newStatements := func() []base.BackupStatement {
+		statements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, "UPDATE t SET a = 1 WHERE id = 1;\nDELETE FROM public.t2 WHERE random() < 0.1;", "db", "bbdataarchive", "_0")
+		a.NoError(err)
+		a.Len(statements, 2)
+		return statements
+	}
+
+	// The statement with the low confidence backs up the whole table into the same backup table.
+	statements := newStatements()
+	a.Zero(statements[0].Uncertainty)
+	a.Equal(float64(1), statements[1].Uncertainty)
+	exec := &DataUpdateExecutor{profile: &config.Profile{}}
+	a.NoError(exec.applyFullTableBackup(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, "db", "bbdataarchive", statements))
+	a.Equal(newStatements()[0], statements[0])
+	a.Equal(`CREATE TABLE "bbdataarchive"."_0_1_t2" AS SELECT "public"."t2".* FROM "public"."t2";`, statements[1].Statement)
+	a.Equal("_0_1_t2", statements[1].TargetTableName)
+
+	// The statement within the maximum uncertainty is kept.
+	statements = newStatements()
+	exec = &DataUpdateExecutor{profile: &config.Profile{PriorBackupMaximumUncertainty: 1}}
+	a.NoError(exec.applyFullTableBackup(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, "db", "bbdataarchive", statements))
+	a.Equal(newStatements(), statements)
+
+	// The strict profile fails the backup instead.
+	exec = &DataUpdateExecutor{profile: &config.Profile{PriorBackupFailOnUncertainty: true}}
+	err := exec.applyFullTableBackup(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, "db", "bbdataarchive", newStatements())
+	a.ErrorContains(err, "volatile functions")
+}
+
+func TestTransformBackupStatement(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	statement := "UPDATE t SET a = 1 WHERE deleted_at IS NULL AND id < 10;"
+
+	// The backup is derived from the DML by default.
+	statements, err := transformBackupStatement(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "", "db", "bbdataarchive", "_0")
+	a.NoError(err)
+	a.Len(statements, 1)
+	a.Contains(statements[0].Statement, "deleted_at IS NULL")
+
+	// The override statement drives the backup, e.g. to also back up the soft-deleted rows.
+	statements, err = transformBackupStatement(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "UPDATE public.t SET a = 1 WHERE id < 10;", "db", "bbdataarchive", "_0")
+	a.NoError(err)
+	a.Len(statements, 1)
+	a.Equal(`CREATE TABLE "bbdataarchive"."_0_0_t" AS SELECT "public"."t".* FROM public.t WHERE id < 10;`, statements[0].Statement)
+
+	// The override statement must modify the same tables.
+	_, err = transformBackupStatement(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "UPDATE t2 SET a = 1 WHERE id < 10;", "db", "bbdataarchive", "_0")
+	a.ErrorContains(err, "the backup statement modifies tables [public.t2], but the statement modifies tables [public.t]")
+	_, err = transformBackupStatement(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "UPDATE t SET a = 1; DELETE FROM t2;", "db", "bbdataarchive", "_0")
+	a.ErrorContains(err, "modifies tables [public.t public.t2]")
+}
+
+func TestGetProcedureBackupStatements(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+
+	// The statement without procedure calls backs up nothing extra.
+	statements, err := GetProcedureBackupStatements(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, "UPDATE t SET a = 1 WHERE id = 1;", "db", "bbdataarchive", "_0", []string{"t"})
+	a.NoError(err)
+	a.Empty(statements)
+
+	// The procedure calls require the declared tables.
+	statement := "UPDATE t SET a = 1 WHERE id = 1;\n/* bump */ call bump(5);"
+	_, err = GetProcedureBackupStatements(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "db", "bbdataarchive", "_0", nil)
+	a.ErrorContains(err, "line 2 calls stored procedures")
+
+	// The declared tables are backed up as a whole at the first call.
+	statements, err = GetProcedureBackupStatements(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "db", "bbdataarchive", "_0", []string{"t", "s.t2", "t"})
+	a.NoError(err)
+	a.Len(statements, 2)
+	a.Equal(`CREATE TABLE "bbdataarchive"."_0_call_t" AS SELECT "t".* FROM "t";`, statements[0].Statement)
+	a.Equal("_0_call_t", statements[0].TargetTableName)
+	a.Equal(procedureBackupReason, statements[0].UncertaintyReason)
+	a.Equal(int32(2), statements[0].StartPosition.GetLine())
+	a.Equal(`CREATE TABLE "bbdataarchive"."_0_call_t2" AS SELECT "s"."t2".* FROM "s"."t2";`, statements[1].Statement)
+	a.Equal("s", statements[1].SourceSchema)
+
+	// The tables cannot be qualified on the engines without schemas.
+	_, err = GetProcedureBackupStatements(ctx, storepb.Engine_MYSQL, base.TransformContext{}, "CALL bump(5);", "db", "bbdataarchive", "_0", []string{"db.t"})
+	a.ErrorContains(err, "must not be qualified")
+}
+
+func TestNormalizeBackupTableName(t *testing.T) {
+	a := require.New(t)
+	tests := []struct {
+		engine     storepb.Engine
+		identifier string
+		want       string
+	}{
+		{storepb.Engine_POSTGRES, "MyTable", "mytable"},
+		{storepb.Engine_POSTGRES, `"MyTable"`, "MyTable"},
+		{storepb.Engine_SNOWFLAKE, "MyTable", "MYTABLE"},
+		{storepb.Engine_SNOWFLAKE, `"MyTable"`, "MyTable"},
+		{storepb.Engine_SNOWFLAKE, `"My""Table"`, `My"Table`},
+		{storepb.Engine_ORACLE, "t", "T"},
+		{storepb.Engine_MYSQL, "MyTable", "MyTable"},
+	}
+	for _, test := range tests {
+		a.Equal(test.want, foldIdentifier(test.engine, test.identifier), "%s %s", test.engine, test.identifier)
+	}
+
+	// The declared tables of the procedure calls are folded.
+	statements, err := GetProcedureBackupStatements(context.Background(), storepb.Engine_POSTGRES, base.TransformContext{}, "CALL bump(5);", "db", "bbdataarchive", "_0", []string{"Public.MyTable", `"Quoted"`})
+	a.NoError(err)
+	a.Len(statements, 2)
+	a.Equal("public", statements[0].SourceSchema)
+	a.Equal("mytable", statements[0].SourceTableName)
+	a.Equal("_0_call_mytable", statements[0].TargetTableName)
+	a.Equal("Quoted", statements[1].SourceTableName)
+
+	// The recorded names are lower case on MySQL with lower_case_table_names = 1 only.
+	instance := &store.InstanceMessage{Engine: storepb.Engine_MYSQL, Metadata: &storepb.InstanceMetadata{MysqlLowerCaseTableNames: 1}}
+	a.Equal("_0_mytable", normalizeBackupTableName(instance, "_0_MyTable"))
+	instance.Metadata.MysqlLowerCaseTableNames = 2
+	a.Equal("_0_MyTable", normalizeBackupTableName(instance, "_0_MyTable"))
+	a.Equal("MyTable", normalizeBackupTableName(&store.InstanceMessage{Engine: storepb.Engine_POSTGRES}, "MyTable"))
+}
+
+func TestGetBackupSizeAnomaly(t *testing.T) {
+	a := require.New(t)
+	history := []int64{100, 90, 120, 110, 95}
+
+	a.Empty(getBackupSizeAnomaly("public.t", 150, history, 3))
+	a.Empty(getBackupSizeAnomaly("public.t", 40, history, 3))
+	a.Equal("the prior backup of public.t has 500 rows, more than 3 times the median 100 of the recent 5 backups", getBackupSizeAnomaly("public.t", 500, history, 3))
+	a.Equal("the prior backup of public.t has 10 rows, less than 1/3 of the median 100 of the recent 5 backups", getBackupSizeAnomaly("public.t", 10, history, 3))
+	// The median of an even history is the mean of the middle two.
+	a.Equal("the prior backup of t has 400 rows, more than 3 times the median 105 of the recent 4 backups", getBackupSizeAnomaly("t", 400, []int64{100, 110, 90, 120}, 3))
+	// The median is at least one row.
+	a.Equal("the prior backup of t has 5 rows, more than 3 times the median 1 of the recent 3 backups", getBackupSizeAnomaly("t", 5, []int64{0, 0, 0}, 3))
+	a.Empty(getBackupSizeAnomaly("t", 0, []int64{0, 0, 0}, 3))
+
+	// Too little history or disabled.
+	a.Empty(getBackupSizeAnomaly("t", 500, []int64{100, 100}, 3))
+	a.Empty(getBackupSizeAnomaly("t", 500, history, 0))
+}
+
+func TestGetBackupBlackoutEnd(t *testing.T) {
+	a := require.New(t)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 2, hour, minute, 0, 0, time.UTC)
+	}
+
+	until, err := getBackupBlackoutEnd([]string{"01:00-03:00"}, at(2, 0))
+	a.NoError(err)
+	a.Equal(at(3, 0), until)
+	// The end is exclusive.
+	until, err = getBackupBlackoutEnd([]string{"01:00-03:00"}, at(3, 0))
+	a.NoError(err)
+	a.True(until.IsZero())
+
+	// The window spanning midnight.
+	until, err = getBackupBlackoutEnd([]string{"22:00-02:00"}, at(23, 30))
+	a.NoError(err)
+	a.Equal(time.Date(2024, 1, 3, 2, 0, 0, 0, time.UTC), until)
+	until, err = getBackupBlackoutEnd([]string{"22:00-02:00"}, at(1, 0))
+	a.NoError(err)
+	a.Equal(at(2, 0), until)
+	until, err = getBackupBlackoutEnd([]string{"22:00-02:00"}, at(12, 0))
+	a.NoError(err)
+	a.True(until.IsZero())
+
+	// The overlapping windows defer until the later end, and the time is compared in UTC.
+	until, err = getBackupBlackoutEnd([]string{"01:00-03:00", "02:00-04:00"}, at(2, 30).In(time.FixedZone("UTC+8", 8*60*60)))
+	a.NoError(err)
+	a.Equal(at(4, 0), until)
+
+	for _, window := range []string{"01:00", "1:00-25:00", "03:00-03:00"} {
+		_, err = getBackupBlackoutEnd([]string{window}, at(2, 0))
+		a.Error(err, window)
+	}
+}
+
+func TestCheckBackupBlackout(t *testing.T) {
+	a := require.New(t)
+	now := time.Now().UTC()
+	window := fmt.Sprintf("%s-%s", now.Add(-time.Hour).Format("15:04"), now.Add(time.Hour).Format("15:04"))
+	exec := &DataUpdateExecutor{clock: realClock{}}
+
+	// The backup in a blackout window of the backup instance is deferred until the window ends.
+	err := exec.checkBackupBlackout([]string{window})
+	var deferredErr *backupDeferredError
+	a.ErrorAs(err, &deferredErr)
+	a.WithinDuration(now.Add(time.Hour), deferredErr.until, time.Minute)
+
+	// The backup instance without blackout windows doesn't defer the backup.
+	a.NoError(exec.checkBackupBlackout(nil))
+
+	// The disabled backup is not deferred.
+	ctx := context.Background()
+	payload := &storepb.TaskDatabaseUpdatePayload{
+		PreUpdateBackupDetail: &storepb.PreUpdateBackupDetail{Database: "instances/i/databases/bbdataarchive"},
+	}
+	_, _, err = exec.backupData(ctx, ctx, "UPDATE t SET a = 1;", payload, storepb.TaskDatabaseUpdatePayload_DISABLED, &store.TaskMessage{}, 0)
+	a.NoError(err)
+}
+
+// fakeClock is the clock stopped at the time.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestIsBackupStale(t *testing.T) {
+	a := require.New(t)
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	stale := &storepb.PriorBackupDetail{CompleteTime: timestamppb.New(now.Add(-2 * time.Hour))}
+
+	a.True(isBackupStale(stale, time.Hour, now))
+	a.False(isBackupStale(stale, 3*time.Hour, now))
+	// The check is disabled without the maximum gap, and skipped for the backups without the completion time.
+	a.False(isBackupStale(stale, 0, now))
+	a.False(isBackupStale(&storepb.PriorBackupDetail{}, time.Hour, now))
+}
+
+func TestDataUpdateExecutorClock(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2024, 1, 10, 21, 30, 0, 0, time.UTC)}
+	exec := &DataUpdateExecutor{
+		profile: &config.Profile{
+			PriorBackupPostSuccessDropAfter: 2 * time.Hour,
+		},
+		clock: clock,
+	}
+	windows := []string{"21:00-22:00"}
+
+	// The blackout deferral ends by the clock instead of the wall time.
+	err := exec.checkBackupBlackout(windows)
+	var deferredErr *backupDeferredError
+	a.ErrorAs(err, &deferredErr)
+	a.Equal(time.Date(2024, 1, 10, 22, 0, 0, 0, time.UTC), deferredErr.until)
+
+	// The drop time is scheduled by the clock.
+	detail := &storepb.PriorBackupDetail{PostSuccessPolicy: storepb.PriorBackupPostSuccessPolicy_DROP_AFTER}
+	exec.applyBackupPostSuccessPolicy(ctx, &store.TaskMessage{}, detail)
+	a.Equal(time.Date(2024, 1, 10, 23, 30, 0, 0, time.UTC), detail.GetDropTime().AsTime())
+
+	// The backup after the blackout window is not deferred.
+	clock.now = time.Date(2024, 1, 10, 22, 30, 0, 0, time.UTC)
+	a.NoError(exec.checkBackupBlackout(windows))
+
+	// The constructor sets the real clock.
+	exec, ok := NewDataUpdateExecutor(nil, nil, nil, nil, nil, &config.Profile{}, nil, nil).(*DataUpdateExecutor)
+	a.True(ok)
+	a.WithinDuration(time.Now(), exec.now(), time.Minute)
+}
+
+func TestRandomIDGenerator(t *testing.T) {
+	a := require.New(t)
+	id, err := randomIDGenerator{}.NewID()
+	a.NoError(err)
+	a.Regexp(`^[0-9a-f]{8}$`, id)
+	other, err := randomIDGenerator{}.NewID()
+	a.NoError(err)
+	a.NotEqual(id, other)
+}
+
+func TestGetAsyncBackup(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)}
+	exec := &DataUpdateExecutor{
+		stateCfg: &state.State{TaskRunTickleChan: make(chan int, 1)},
+		profile:  &config.Profile{PriorBackupMaxGap: time.Hour},
+		clock:    clock,
+	}
+	databaseID := 100
+	task := &store.TaskMessage{ID: 1, DatabaseID: &databaseID}
+	exec.stateCfg.RunningDatabaseMigration.Store(databaseID, task.ID)
+	release := make(chan struct{})
+	detail := &storepb.PriorBackupDetail{
+		Items:        []*storepb.PriorBackupDetail_Item{{TargetTable: &storepb.PriorBackupDetail_Item_Table{Table: "_0_0_t"}}},
+		CompleteTime: timestamppb.New(clock.now),
+	}
+	started := 0
+	backup := func(context.Context) (*storepb.PriorBackupDetail, []string, error) {
+		started++
+		<-release
+		return detail, []string{"SELECT 1"}, nil
+	}
+	var discarded []*storepb.PriorBackupDetail
+	discard := func(detail *storepb.PriorBackupDetail) {
+		discarded = append(discarded, detail)
+	}
+
+	// The backup runs in the background holding the database, and the data update is gated until it completes.
+	_, _, err := exec.getAsyncBackup(ctx, task, 1, backup, discard)
+	a.ErrorIs(err, errBackupInProgress)
+	_, _, err = exec.getAsyncBackup(ctx, task, 1, backup, discard)
+	a.ErrorIs(err, errBackupInProgress)
+	value, ok := exec.stateCfg.PriorBackupAsyncTaskRuns.Load(1)
+	a.True(ok)
+	a.True(value.(*asyncBackup).inProgress())
+	_, ok = exec.stateCfg.RunningDatabaseMigration.Load(databaseID)
+	a.True(ok)
+
+	// The completion releases the database and tickles the scheduler, and the result is returned once.
+	close(release)
+	<-exec.stateCfg.TaskRunTickleChan
+	a.False(value.(*asyncBackup).inProgress())
+	_, ok = exec.stateCfg.RunningDatabaseMigration.Load(databaseID)
+	a.False(ok)
+	gotDetail, statements, err := exec.getAsyncBackup(ctx, task, 1, backup, discard)
+	a.NoError(err)
+	a.Equal(detail, gotDetail)
+	a.Equal([]string{"SELECT 1"}, statements)
+	a.Equal(1, started)
+	a.Empty(discarded)
+	_, ok = exec.stateCfg.PriorBackupAsyncTaskRuns.Load(1)
+	a.False(ok)
+	_, ok = exec.stateCfg.PriorBackupAsyncCancelFunc.Load(1)
+	a.False(ok)
+
+	// The backup gone stale before the data update is discarded and backed up again in the background.
+	_, _, err = exec.getAsyncBackup(ctx, task, 4, backup, discard)
+	a.ErrorIs(err, errBackupInProgress)
+	<-exec.stateCfg.TaskRunTickleChan
+	clock.now = clock.now.Add(2 * time.Hour)
+	_, _, err = exec.getAsyncBackup(ctx, task, 4, backup, discard)
+	a.ErrorIs(err, errBackupInProgress)
+	<-exec.stateCfg.TaskRunTickleChan
+	a.Equal([]*storepb.PriorBackupDetail{detail}, discarded)
+	a.Equal(3, started)
+
+	// The failed backup fails the required data update.
+	failure := errors.New("backup database is unavailable")
+	_, _, err = exec.getAsyncBackup(ctx, task, 2, func(context.Context) (*storepb.PriorBackupDetail, []string, error) {
+		return nil, nil, failure
+	}, discard)
+	a.ErrorIs(err, errBackupInProgress)
+	<-exec.stateCfg.TaskRunTickleChan
+	_, _, err = exec.getAsyncBackup(ctx, task, 2, backup, discard)
+	a.ErrorIs(err, failure)
+	a.ErrorIs(checkBackupShortfall(storepb.TaskDatabaseUpdatePayload_REQUIRED, err), failure)
+
+	// Canceling the task run cancels the backup, forgets it and releases the database held by the task.
+	task = &store.TaskMessage{ID: 3, DatabaseID: &databaseID}
+	exec.stateCfg.RunningDatabaseMigration.Store(databaseID, task.ID)
+	_, _, err = exec.getAsyncBackup(ctx, task, 3, func(backupCtx context.Context) (*storepb.PriorBackupDetail, []string, error) {
+		<-backupCtx.Done()
+		return nil, nil, backupCtx.Err()
+	}, discard)
+	a.ErrorIs(err, errBackupInProgress)
+	cancelFunc, ok := exec.stateCfg.PriorBackupAsyncCancelFunc.Load(3)
+	a.True(ok)
+	cancelFunc.(context.CancelFunc)()
+	<-exec.stateCfg.TaskRunTickleChan
+	_, ok = exec.stateCfg.PriorBackupAsyncTaskRuns.Load(3)
+	a.False(ok)
+	_, ok = exec.stateCfg.PriorBackupAsyncCancelFunc.Load(3)
+	a.False(ok)
+	_, ok = exec.stateCfg.RunningDatabaseMigration.Load(databaseID)
+	a.False(ok)
+}
+
+func TestGetInterruptedBackupItems(t *testing.T) {
+	a := require.New(t)
+	item := func(table string) *storepb.PriorBackupDetail_Item {
+		return &storepb.PriorBackupDetail_Item{TargetTable: &storepb.PriorBackupDetail_Item_Table{Table: table}}
+	}
+	start := func(items ...*storepb.PriorBackupDetail_Item) *store.TaskRunLog {
+		return &store.TaskRunLog{Payload: &storepb.TaskRunLog{
+			Type:             storepb.TaskRunLog_PRIOR_BACKUP_START,
+			PriorBackupStart: &storepb.TaskRunLog_PriorBackupStart{Items: items},
+		}}
+	}
+	end := &store.TaskRunLog{Payload: &storepb.TaskRunLog{
+		Type:           storepb.TaskRunLog_PRIOR_BACKUP_END,
+		PriorBackupEnd: &storepb.TaskRunLog_PriorBackupEnd{},
+	}}
+	status := &store.TaskRunLog{Payload: &storepb.TaskRunLog{Type: storepb.TaskRunLog_TASK_RUN_STATUS_UPDATE}}
+
+	a.Empty(getInterruptedBackupItems(nil))
+	// The backup tables of the ended backup are tracked by its detail.
+	a.Empty(getInterruptedBackupItems([]*store.TaskRunLog{status, start(item("_0_t")), end}))
+	// The backup tables of the shards logged after the last end are those of the interrupted backup.
+	a.Equal(
+		[]*storepb.PriorBackupDetail_Item{item("_1_t"), item("_1_s")},
+		getInterruptedBackupItems([]*store.TaskRunLog{start(item("_0_t")), end, start(item("_1_t")), status, start(item("_1_s"))}),
+	)
+}
+
+// connectedDriver is a driver connected to a SQLite database reporting the database name as the current database.
+type connectedDriver struct {
+	db.Driver
+
+	sqlDB *sql.DB
+}
+
+func (d *connectedDriver) GetDB() *sql.DB {
+	return d.sqlDB
+}
+
+func newConnectedDriver(t *testing.T, database string) *connectedDriver {
+	driverName := "sqlite3_current_database_" + database
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("current_database", func() string { return database }, true)
+		},
+	})
+	sqlDB, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	return &connectedDriver{sqlDB: sqlDB}
+}
+
+func TestCheckDriverDatabase(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+
+	a.NoError(checkDriverDatabase(ctx, newConnectedDriver(t, "db"), storepb.Engine_POSTGRES, "db"))
+	// The backup would be written into the wrong database.
+	err := checkDriverDatabase(ctx, newConnectedDriver(t, "bbdataarchive"), storepb.Engine_POSTGRES, "db")
+	a.ErrorContains(err, `the driver is connected to database "bbdataarchive" instead of "db"`)
+
+	// The engines without the query of the current database are not checked.
+	a.NoError(checkDriverDatabase(ctx, &statementDriver{}, storepb.Engine_SNOWFLAKE, "db"))
+	a.Equal("SELECT DATABASE()", getCurrentDatabaseQuery(storepb.Engine_MYSQL))
+}
+
+func TestWaitForOnlineDDL(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	a.NoError(err)
+	defer sqlDB.Close()
+	// The in-memory databases are per connection.
+	sqlDB.SetMaxOpenConns(1)
+	_, err = sqlDB.Exec(`
+		ATTACH DATABASE ':memory:' AS information_schema;
+		CREATE TABLE information_schema.TABLES(TABLE_SCHEMA TEXT, TABLE_NAME TEXT);
+		CREATE TABLE information_schema.TRIGGERS(EVENT_OBJECT_SCHEMA TEXT, EVENT_OBJECT_TABLE TEXT, TRIGGER_NAME TEXT);
+		INSERT INTO information_schema.TABLES VALUES ('bbdataarchive', '_0_0_t'), ('bbdataarchive', '__0_0_t_gho');`)
+	a.NoError(err)
+
+	// The tag is skipped if the online DDL is still in progress after the checks.
+	tool, err := waitForOnlineDDL(ctx, sqlDB, storepb.Engine_MYSQL, "bbdataarchive", "_0_0_t", 2, time.Millisecond)
+	a.NoError(err)
+	a.Equal("gh-ost", tool)
+	tool, err = waitForOnlineDDL(ctx, sqlDB, storepb.Engine_MYSQL, "bbdataarchive", "_0_1_t", 2, time.Millisecond)
+	a.NoError(err)
+	a.Empty(tool)
+
+	_, err = sqlDB.Exec(`DELETE FROM information_schema.TABLES WHERE TABLE_NAME = '__0_0_t_gho'`)
+	a.NoError(err)
+	tool, err = waitForOnlineDDL(ctx, sqlDB, storepb.Engine_MYSQL, "bbdataarchive", "_0_0_t", 2, time.Millisecond)
+	a.NoError(err)
+	a.Empty(tool)
+
+	// The check is not supported on the other engines.
+	tool, err = waitForOnlineDDL(ctx, sqlDB, storepb.Engine_POSTGRES, "bbdataarchive", "_0_0_t", 2, time.Millisecond)
+	a.NoError(err)
+	a.Empty(tool)
+}
+
+func TestSetBackupRestoreStatements(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	getDatabaseMetadata := func(_ context.Context, _, database string) (string, *model.DatabaseMetadata, error) {
+		return database, model.NewDatabaseMetadata(&storepb.DatabaseSchemaMetadata{
+			Name: database,
+			Schemas: []*storepb.SchemaMetadata{
+				{
+					Name: "public",
+					Tables: []*storepb.TableMetadata{
+						{
+							Name:    "t",
+							Columns: []*storepb.ColumnMetadata{{Name: "id"}, {Name: "a"}},
+							Indexes: []*storepb.IndexMetadata{{Name: "t_pkey", Expressions: []string{"id"}, Primary: true, Unique: true}},
+						},
+					},
+				},
+			},
+		}), nil
+	}
+	statement := "UPDATE t SET a = 1 WHERE id = 1; DELETE FROM t WHERE id = 2;\nCALL bump(5);"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "db", "bbdataarchive", "_0")
+	a.NoError(err)
+	a.Len(backupStatements, 2)
+	var items []*storepb.PriorBackupDetail_Item
+	for _, backupStatement := range backupStatements {
+		items = append(items, &storepb.PriorBackupDetail_Item{
+			SourceTable:   &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: backupStatement.SourceTableName},
+			TargetTable:   &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: backupStatement.TargetTableName},
+			StartPosition: backupStatement.StartPosition,
+		})
+	}
+	// The item of the procedure call has no restore statement.
+	items = append(items, &storepb.PriorBackupDetail_Item{
+		SourceTable:   &storepb.PriorBackupDetail_Item_Table{Table: "t"},
+		TargetTable:   &storepb.PriorBackupDetail_Item_Table{Table: "_0_call_t"},
+		StartPosition: &storepb.Position{Line: 2},
+	})
+
+	setBackupRestoreStatements(ctx, storepb.Engine_POSTGRES, base.RestoreContext{GetDatabaseMetadataFunc: getDatabaseMetadata}, statement, "db", "bbdataarchive", items)
+	// Each item restores by the statement it backs up, even on the same line.
+	a.Equal(`/*
+Original SQL:
+UPDATE t SET a = 1 WHERE id = 1;
+*/
+INSERT INTO "public"."t" ("id", "a") SELECT "id", "a" FROM "bbdataarchive"."_0_0_t" ON CONFLICT ("id") DO UPDATE SET "a" = EXCLUDED."a";`, items[0].RestoreStatement)
+	a.Equal(`/*
+Original SQL:
+ DELETE FROM t WHERE id = 2;
+*/
+INSERT INTO "public"."t" ("id", "a") SELECT "id", "a" FROM "bbdataarchive"."_0_1_t";`, items[1].RestoreStatement)
+	a.Empty(items[2].RestoreStatement)
+
+	// The restore statements are not supported on the other engines.
+	item := &storepb.PriorBackupDetail_Item{SourceTable: &storepb.PriorBackupDetail_Item_Table{Table: "t"}, StartPosition: &storepb.Position{Line: 1}}
+	setBackupRestoreStatements(ctx, storepb.Engine_ORACLE, base.RestoreContext{GetDatabaseMetadataFunc: getDatabaseMetadata}, "DELETE FROM t;", "db", "bbdataarchive", []*storepb.PriorBackupDetail_Item{item})
+	a.Empty(item.RestoreStatement)
+}
+
+func TestGetBackupColumns(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	// The defaults at backup time.
+	columns := getBackupColumns(&storepb.TableMetadata{
+		Name: "t",
+		Columns: []*storepb.ColumnMetadata{
+			{Name: "id"},
+			{Name: "a", DefaultValue: &storepb.ColumnMetadata_DefaultExpression{DefaultExpression: "'x'::text"}},
+			{Name: "b", DefaultValue: &storepb.ColumnMetadata_Default{Default: wrapperspb.String("it's")}},
+			{Name: "c", DefaultValue: &storepb.ColumnMetadata_DefaultNull{DefaultNull: true}, Collation: "C"},
+		},
+	})
+	var defaults []string
+	for _, column := range columns {
+		defaults = append(defaults, column.GetName()+"="+column.GetDefault())
+	}
+	a.Equal([]string{"id=", "a='x'::text", "b='it''s'", "c=NULL"}, defaults)
+	// The explicit collation is captured, and the others use the default collation.
+	a.Equal("C", columns[3].GetCollation())
+	a.Empty(columns[0].GetCollation())
+
+	// The default of the excluded column has changed and column d has been added since the backup.
+	getDatabaseMetadata := func(_ context.Context, _, database string) (string, *model.DatabaseMetadata, error) {
+		return database, model.NewDatabaseMetadata(&storepb.DatabaseSchemaMetadata{
+			Name: database,
+			Schemas: []*storepb.SchemaMetadata{
+				{
+					Name: "public",
+					Tables: []*storepb.TableMetadata{
+						{
+							Name: "t",
+							Columns: []*storepb.ColumnMetadata{
+								{Name: "id"},
+								{Name: "a", DefaultValue: &storepb.ColumnMetadata_DefaultExpression{DefaultExpression: "'y'::text"}},
+								{Name: "b"},
+								{Name: "c", Collation: "C"},
+								{Name: "d"},
+							},
+							Indexes: []*storepb.IndexMetadata{{Name: "t_pkey", Expressions: []string{"id"}, Primary: true, Unique: true}},
+						},
+					},
+				},
+			},
+		}), nil
+	}
+	item := &storepb.PriorBackupDetail_Item{
+		SourceTable:     &storepb.PriorBackupDetail_Item_Table{Table: "t"},
+		TargetTable:     &storepb.PriorBackupDetail_Item_Table{Table: "_0_t"},
+		StartPosition:   &storepb.Position{Line: 1},
+		ExcludedColumns: []string{"a"},
+		Columns:         columns,
+	}
+	setBackupRestoreStatements(ctx, storepb.Engine_POSTGRES, base.RestoreContext{GetDatabaseMetadataFunc: getDatabaseMetadata}, "DELETE FROM t WHERE id = 1;", "db", "bbdataarchive", []*storepb.PriorBackupDetail_Item{item})
+	a.Equal(`/*
+Original SQL:
+DELETE FROM t WHERE id = 1;
+*/
+INSERT INTO "public"."t" ("id", "a", "b", "c") SELECT "id", 'x'::text, "b", "c" FROM "bbdataarchive"."_0_t";`, item.RestoreStatement)
+}
+
+func TestGetBackupCoverageQuery(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	statements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{AfterImage: true}, "UPDATE t SET a = a * 10 WHERE id > 7;", "db", "bbdataarchive", "_bbcoverage")
+	a.NoError(err)
+	a.Len(statements, 1)
+
+	// The rows selected by the predicate are matched with the rows of all the parts of the backup.
+	query, ok := GetBackupCoverageQuery(storepb.Engine_POSTGRES, statements[0], "bbdataarchive", []string{"_0_0_t_p1", "_0_0_t_p2"}, []string{"id", "k"})
+	a.True(ok)
+	a.Equal(`SELECT ROW(bb_modified."id", bb_modified."k")::text FROM (SELECT "t".* FROM t WHERE id > 7) AS bb_modified`+
+		` WHERE NOT EXISTS (SELECT 1 FROM "bbdataarchive"."_0_0_t_p1" AS bb_backup WHERE bb_backup."id" = bb_modified."id" AND bb_backup."k" = bb_modified."k")`+
+		` AND NOT EXISTS (SELECT 1 FROM "bbdataarchive"."_0_0_t_p2" AS bb_backup WHERE bb_backup."id" = bb_modified."id" AND bb_backup."k" = bb_modified."k") LIMIT 10`, query)
+
+	// The rows cannot be matched without the primary key.
+	_, ok = GetBackupCoverageQuery(storepb.Engine_POSTGRES, statements[0], "bbdataarchive", []string{"_0_0_t"}, nil)
+	a.False(ok)
+	_, ok = GetBackupCoverageQuery(storepb.Engine_MYSQL, statements[0], "bbdataarchive", []string{"_0_0_t"}, []string{"id"})
+	a.False(ok)
+}
+
+func TestTrackActiveBackup(t *testing.T) {
+	a := require.New(t)
+	gauge := activeBackupsGauge.WithLabelValues(storepb.Engine_POSTGRES.String(), "test-track-active-backup")
+
+	done := trackActiveBackup(storepb.Engine_POSTGRES, "test-track-active-backup")
+	a.Equal(float64(1), testutil.ToFloat64(gauge))
+	done()
+	a.Equal(float64(0), testutil.ToFloat64(gauge))
+
+	// The concurrent backups are counted, and the gauge returns to zero after they complete or panic.
+	var wg sync.WaitGroup
+	started := make(chan struct{})
+	release := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() {
+				_ = recover()
+			}()
+			defer trackActiveBackup(storepb.Engine_POSTGRES, "test-track-active-backup")()
+			started <- struct{}{}
+			<-release
+			if i%2 == 0 {
+				panic("backup failed")
+			}
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		<-started
+	}
+	a.Equal(float64(8), testutil.ToFloat64(gauge))
+	close(release)
+	wg.Wait()
+	a.Equal(float64(0), testutil.ToFloat64(gauge))
+}
+
+func TestGetBackupVersionWarnings(t *testing.T) {
+	a := require.New(t)
+	a.Equal([]int{8, 0, 33}, parseEngineVersion("8.0.33-log"))
+	a.Equal([]int{16, 2}, parseEngineVersion("16.2"))
+	a.Equal([]int{19, 0, 0, 0, 0}, parseEngineVersion("19.0.0.0.0"))
+	a.Empty(parseEngineVersion("unknown"))
+
+	// The same or a newer minor version of the backup database is compatible.
+	warnings, incompatible := getBackupVersionWarnings(storepb.Engine_MYSQL, "8.0.20", "8.0.33")
+	a.Empty(warnings)
+	a.False(incompatible)
+	warnings, incompatible = getBackupVersionWarnings(storepb.Engine_POSTGRES, "16.2", "16.2")
+	a.Empty(warnings)
+	a.False(incompatible)
+
+	// The older backup database lacks the features of the source database version.
+	warnings, incompatible = getBackupVersionWarnings(storepb.Engine_MYSQL, "8.0.33", "5.7.44")
+	a.True(incompatible)
+	a.Equal([]string{
+		"the backup database version 5.7.44 lacks the utf8mb4_0900 collations of the source database version 8.0.33 since 8.0",
+		"the backup database version 5.7.44 lacks the invisible columns of the source database version 8.0.33 since 8.0.23",
+	}, warnings)
+	warnings, incompatible = getBackupVersionWarnings(storepb.Engine_MYSQL, "8.0.33", "8.0.20")
+	a.True(incompatible)
+	a.Equal([]string{"the backup database version 8.0.20 lacks the invisible columns of the source database version 8.0.33 since 8.0.23"}, warnings)
+
+	// The other version mismatches only warn.
+	warnings, incompatible = getBackupVersionWarnings(storepb.Engine_POSTGRES, "13.4", "16.2")
+	a.False(incompatible)
+	a.Equal([]string{"the backup database version 16.2 differs from the source database version 13.4, the backup may fail or lose fidelity"}, warnings)
+	warnings, incompatible = getBackupVersionWarnings(storepb.Engine_POSTGRES, "", "16.2")
+	a.Empty(warnings)
+	a.False(incompatible)
+}
+
+func TestGetStagePriorBackupItems(t *testing.T) {
+	a := require.New(t)
+	stage := common.FormatStage("p", 2, 3)
+	a.Equal("projects/p/rollouts/2/stages/3", stage)
+	item := func(table string) *storepb.PriorBackupDetail_Item {
+		return &storepb.PriorBackupDetail_Item{TargetTable: &storepb.PriorBackupDetail_Item_Table{Table: table}}
+	}
+	// The backups of the tasks in the stage are aggregated in order, skipping those of the other stages.
+	details := []*storepb.PriorBackupDetail{
+		{Stage: stage, Items: []*storepb.PriorBackupDetail_Item{item("_0_0_t1"), item("_0_1_t2")}},
+		{Stage: common.FormatStage("p", 2, 4), Items: []*storepb.PriorBackupDetail_Item{item("_1_0_t1")}},
+		{Stage: stage, Items: []*storepb.PriorBackupDetail_Item{item("_2_0_t3")}},
+		{Items: []*storepb.PriorBackupDetail_Item{item("_3_0_t1")}},
+	}
+	var tables []string
+	for _, item := range getStagePriorBackupItems(details, stage) {
+		tables = append(tables, item.GetTargetTable().GetTable())
+	}
+	a.Equal([]string{"_0_0_t1", "_0_1_t2", "_2_0_t3"}, tables)
+	a.Empty(getStagePriorBackupItems(details, common.FormatStage("p", 2, 5)))
+}
+
+func TestBackupPostSuccessPolicy(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	// The policy of the task overrides the configured policy, and empty keeps the backup tables.
+	policy, err := getBackupPostSuccessPolicy("", storepb.PriorBackupPostSuccessPolicy_POST_SUCCESS_POLICY_UNSPECIFIED)
+	a.NoError(err)
+	a.Equal(storepb.PriorBackupPostSuccessPolicy_KEEP, policy)
+	policy, err = getBackupPostSuccessPolicy("drop_after", storepb.PriorBackupPostSuccessPolicy_POST_SUCCESS_POLICY_UNSPECIFIED)
+	a.NoError(err)
+	a.Equal(storepb.PriorBackupPostSuccessPolicy_DROP_AFTER, policy)
+	policy, err = getBackupPostSuccessPolicy("DROP_AFTER", storepb.PriorBackupPostSuccessPolicy_DROP_IMMEDIATELY)
+	a.NoError(err)
+	a.Equal(storepb.PriorBackupPostSuccessPolicy_DROP_IMMEDIATELY, policy)
+	_, err = getBackupPostSuccessPolicy("DROP", storepb.PriorBackupPostSuccessPolicy_POST_SUCCESS_POLICY_UNSPECIFIED)
+	a.ErrorContains(err, `unsupported backup post-success policy "DROP"`)
+
+	instance := &store.InstanceMessage{ResourceID: "i", Engine: storepb.Engine_MYSQL}
+	newDetail := func(policy storepb.PriorBackupPostSuccessPolicy) *storepb.PriorBackupDetail {
+		return &storepb.PriorBackupDetail{
+			Items: []*storepb.PriorBackupDetail_Item{
+				{
+					TargetTable:     &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: "_0_t"},
+					AfterImageTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/bbdataarchive", Table: "_0_t_after"},
+				},
+				// The backup tables of the shards on the other instances are left to the retention job.
+				{TargetTable: &storepb.PriorBackupDetail_Item_Table{Database: "instances/shard/databases/bbdataarchive", Table: "_0_t"}},
+				// The backups published to the sinks have no backup tables.
+				{Sink: &storepb.PriorBackupDetail_Item_Sink{Topic: "t"}},
+			},
+			PostSuccessPolicy: policy,
+		}
+	}
+
+	// KEEP leaves the backup tables to their retentions.
+	detail := newDetail(storepb.PriorBackupPostSuccessPolicy_KEEP)
+	a.False(setBackupDropTime(detail, time.Hour, now))
+	a.Nil(detail.DropTime)
+
+	// DROP_AFTER schedules the retention job to drop the backup tables after the delay.
+	detail = newDetail(storepb.PriorBackupPostSuccessPolicy_DROP_AFTER)
+	a.False(setBackupDropTime(detail, time.Hour, now))
+	a.Equal(now.Add(time.Hour), detail.DropTime.AsTime())
+	detail = newDetail(storepb.PriorBackupPostSuccessPolicy_DROP_AFTER)
+	a.False(setBackupDropTime(detail, 0, now))
+	a.Equal(now.Add(defaultBackupDropAfter), detail.DropTime.AsTime())
+
+	// DROP_IMMEDIATELY drops the backup tables on the instance right away.
+	detail = newDetail(storepb.PriorBackupPostSuccessPolicy_DROP_IMMEDIATELY)
+	a.True(setBackupDropTime(detail, time.Hour, now))
+	a.Equal(now, detail.DropTime.AsTime())
+	driver := &statementDriver{}
+	dropBackupTables(ctx, driver, instance, detail.GetItems(), now)
+	a.Equal([]string{
+		"DROP TABLE IF EXISTS `bbdataarchive`.`_0_t`;",
+		"DROP TABLE IF EXISTS `bbdataarchive`.`_0_t_after`;",
+	}, driver.statements)
+
+	// The immutable backup tables are scheduled to be dropped once they can be, instead of dropped right away.
+	immutableUntil := now.AddDate(0, 0, immutableBackupTableRetentionDays)
+	for _, policy := range []storepb.PriorBackupPostSuccessPolicy{storepb.PriorBackupPostSuccessPolicy_DROP_IMMEDIATELY, storepb.PriorBackupPostSuccessPolicy_DROP_AFTER} {
+		detail = newDetail(policy)
+		detail.Items[0].ImmutableUntil = timestamppb.New(immutableUntil)
+		a.False(setBackupDropTime(detail, time.Hour, now))
+		a.Equal(immutableUntil, detail.DropTime.AsTime())
+		a.Len(detail.Warnings, 1)
+		a.Contains(detail.Warnings[0], "the immutable backup tables are kept until")
+	}
+	// The cleanups skip the immutable backup tables, but not their after-image tables.
+	driver = &statementDriver{}
+	dropBackupTables(ctx, driver, instance, detail.GetItems(), now)
+	a.Equal([]string{"DROP TABLE IF EXISTS `bbdataarchive`.`_0_t_after`;"}, driver.statements)
+	driver = &statementDriver{}
+	dropBackupTables(ctx, driver, instance, detail.GetItems(), immutableUntil)
+	a.Len(driver.statements, 2)
+}
+
+func TestOptimisticRestore(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	table := &storepb.TableMetadata{
+		Name:    "t",
+		Columns: []*storepb.ColumnMetadata{{Name: "id"}, {Name: "a"}, {Name: "Version"}},
+		Indexes: []*storepb.IndexMetadata{{Name: "t_pkey", Expressions: []string{"id"}, Primary: true, Unique: true}},
+	}
+	// The first configured column the source table has is captured.
+	a.Equal("Version", getBackupVersionColumn(table, []string{"updated_at", "version"}))
+	a.Empty(getBackupVersionColumn(table, []string{"updated_at"}))
+	a.Empty(getBackupVersionColumn(table, nil))
+
+	table.Columns[2].Name = "version"
+	getDatabaseMetadata := func(_ context.Context, _, database string) (string, *model.DatabaseMetadata, error) {
+		return database, model.NewDatabaseMetadata(&storepb.DatabaseSchemaMetadata{
+			Name:    database,
+			Schemas: []*storepb.SchemaMetadata{{Name: "public", Tables: []*storepb.TableMetadata{table}}},
+		}), nil
+	}
+	statement := "UPDATE t SET a = 0;"
+	item := &storepb.PriorBackupDetail_Item{
+		SourceTable:   &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: "t"},
+		TargetTable:   &storepb.PriorBackupDetail_Item_Table{Database: "instances/i/databases/db", Table: "_0_t"},
+		StartPosition: &storepb.Position{Line: 1},
+		VersionColumn: "version",
+	}
+	setBackupRestoreStatements(ctx, storepb.Engine_POSTGRES, base.RestoreContext{GetDatabaseMetadataFunc: getDatabaseMetadata}, statement, "db", "bbdataarchive", []*storepb.PriorBackupDetail_Item{item})
+	a.Equal(`/*
+Original SQL:
+UPDATE t SET a = 0;
+*/
+INSERT INTO "public"."t" ("id", "a", "version") SELECT "id", "a", "version" FROM "bbdataarchive"."_0_t" ON CONFLICT ("id") DO UPDATE SET "a" = EXCLUDED."a" WHERE "public"."t"."version" <= EXCLUDED."version";`, item.RestoreStatement)
+
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	a.NoError(err)
+	defer sqlDB.Close()
+	// Each connection has its own in-memory databases.
+	sqlDB.SetMaxOpenConns(1)
+	for _, s := range []string{
+		`ATTACH ':memory:' AS "public"`,
+		`ATTACH ':memory:' AS "bbdataarchive"`,
+		`CREATE TABLE "public"."t" ("id" INT PRIMARY KEY, "a" INT, "version" INT)`,
+		`CREATE TABLE "bbdataarchive"."_0_t" ("id" INT, "a" INT, "version" INT)`,
+		`INSERT INTO "bbdataarchive"."_0_t" VALUES (1, 10, 1), (2, 20, 1)`,
+		// The data update zeroed the rows, and then row 1 was changed legitimately and advanced its version.
+		`INSERT INTO "public"."t" VALUES (1, 11, 2), (2, 0, 1)`,
+	} {
+		_, err := sqlDB.ExecContext(ctx, s)
+		a.NoError(err)
+	}
+	// SQLite requires a WHERE clause in the SELECT of INSERT ... SELECT with the upsert clause to parse it.
+	_, err = sqlDB.ExecContext(ctx, strings.Replace(item.RestoreStatement, " ON CONFLICT", " WHERE true ON CONFLICT", 1))
+	a.NoError(err)
+	rows, err := sqlDB.QueryContext(ctx, `SELECT "id", "a", "version" FROM "public"."t" ORDER BY "id"`)
+	a.NoError(err)
+	defer rows.Close()
+	var got [][3]int
+	for rows.Next() {
+		var row [3]int
+		a.NoError(rows.Scan(&row[0], &row[1], &row[2]))
+		got = append(got, row)
+	}
+	a.NoError(rows.Err())
+	// The row advanced after the backup is skipped, and the other row is restored.
+	a.Equal([][3]int{{1, 11, 2}, {2, 20, 1}}, got)
+}
@@ -189,6 +189,7 @@ func executeMigration(
 	statement string,
 	sheetID *int,
 	mi *db.MigrationInfo,
+	opts db.ExecuteOptions,
 ) (string, string, error) {
 	instance, err := stores.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &task.InstanceID})
 	if err != nil {
@@ -215,8 +216,6 @@ func executeMigration(
 	)
 
 	var migrationID string
-	opts := db.ExecuteOptions{}
-
 	opts.SetConnectionID = func(id string) {
 		stateCfg.TaskRunConnectionID.Store(taskRunUID, id)
 	}
@@ -328,12 +327,17 @@ func postMigration(ctx context.Context, stores *store.Store, task *store.TaskMes
 }
 
 func runMigration(ctx context.Context, driverCtx context.Context, store *store.Store, dbFactory *dbfactory.DBFactory, stateCfg *state.State, profile *config.Profile, task *store.TaskMessage, taskRunUID int, migrationType db.MigrationType, statement string, schemaVersion model.Version, sheetID *int) (terminated bool, result *storepb.TaskRunResult, err error) {
+	return runMigrationWithOptions(ctx, driverCtx, store, dbFactory, stateCfg, profile, task, taskRunUID, migrationType, statement, schemaVersion, sheetID, db.ExecuteOptions{})
+}
+
+// runMigrationWithOptions runs the migration with the base execute options, e.g. the statements executed in the same transaction before the migration.
+func runMigrationWithOptions(ctx context.Context, driverCtx context.Context, store *store.Store, dbFactory *dbfactory.DBFactory, stateCfg *state.State, profile *config.Profile, task *store.TaskMessage, taskRunUID int, migrationType db.MigrationType, statement string, schemaVersion model.Version, sheetID *int, opts db.ExecuteOptions) (terminated bool, result *storepb.TaskRunResult, err error) {
 	mi, err := getMigrationInfo(ctx, store, profile, task, migrationType, statement, schemaVersion, sheetID)
 	if err != nil {
 		return true, nil, err
 	}
 
-	migrationID, _, err := executeMigration(ctx, driverCtx, store, dbFactory, stateCfg, profile, task, taskRunUID, statement, sheetID, mi)
+	migrationID, _, err := executeMigration(ctx, driverCtx, store, dbFactory, stateCfg, profile, task, taskRunUID, statement, sheetID, mi, opts)
 	if err != nil {
 		return true, nil, err
 	}
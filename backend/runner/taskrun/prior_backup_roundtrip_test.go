@@ -0,0 +1,524 @@
+//go:build mysql
+
+package taskrun
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/bytebase/bytebase/backend/common"
+	"github.com/bytebase/bytebase/backend/component/config"
+	"github.com/bytebase/bytebase/backend/component/dbfactory"
+	"github.com/bytebase/bytebase/backend/component/state"
+	enterprisesvc "github.com/bytebase/bytebase/backend/enterprise/service"
+	api "github.com/bytebase/bytebase/backend/legacyapi"
+	"github.com/bytebase/bytebase/backend/migrator"
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	resourcemysql "github.com/bytebase/bytebase/backend/resources/mysql"
+	"github.com/bytebase/bytebase/backend/resources/postgres"
+	"github.com/bytebase/bytebase/backend/runner/schemasync"
+	"github.com/bytebase/bytebase/backend/store"
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func TestPriorBackupRoundTrip(t *testing.T) {
+	table := &roundTripTable{
+		name:       "t",
+		columns:    []string{"id", "a", "b"},
+		primaryKey: []string{"id"},
+		schema: `
+			CREATE TABLE t(id INT PRIMARY KEY, a INT, b VARCHAR(20));
+			INSERT INTO t VALUES (1, 1, 'x'), (2, 2, NULL), (3, 3, 'z');`,
+	}
+	tests := []struct {
+		name   string
+		engine *roundTripEngine
+		dml    string
+	}{
+		{name: "MySQLUpdate", engine: mysqlRoundTripEngine, dml: "UPDATE t SET b = 'changed' WHERE a >= 2;"},
+		{name: "MySQLDelete", engine: mysqlRoundTripEngine, dml: "DELETE FROM t WHERE a <= 2;"},
+		{name: "PostgresUpdate", engine: postgresRoundTripEngine, dml: "UPDATE t SET b = 'changed' WHERE a >= 2;"},
+		{name: "PostgresDelete", engine: postgresRoundTripEngine, dml: "DELETE FROM t WHERE a <= 2;"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testPriorBackupRoundTrip(t, test.engine, table, test.dml)
+		})
+	}
+}
+
+func TestPriorBackupAffectedRowsConformance(t *testing.T) {
+	table := &roundTripTable{
+		name:       "t",
+		columns:    []string{"id", "a", "b"},
+		primaryKey: []string{"id"},
+		schema: `
+			CREATE TABLE t(id INT PRIMARY KEY, a INT, b VARCHAR(20));
+			INSERT INTO t VALUES (1, 1, 'x'), (2, 2, NULL), (3, NULL, 'z'), (4, 4, NULL), (5, 5, 'y'), (6, NULL, NULL);`,
+	}
+	tests := []struct {
+		name   string
+		engine *roundTripEngine
+		dml    string
+	}{
+		{name: "MySQLUpdateNulls", engine: mysqlRoundTripEngine, dml: "UPDATE t SET b = 'changed' WHERE b IS NULL;"},
+		{name: "MySQLDeleteOrderByLimit", engine: mysqlRoundTripEngine, dml: "DELETE FROM t ORDER BY a DESC LIMIT 2;"},
+		{name: "MySQLUpdateSubquery", engine: mysqlRoundTripEngine, dml: "UPDATE t SET a = 0 WHERE id IN (SELECT id FROM (SELECT id FROM t WHERE b > 'x') s);"},
+		{name: "PostgresUpdateNulls", engine: postgresRoundTripEngine, dml: "UPDATE t SET b = 'changed' WHERE b IS NULL;"},
+		{name: "PostgresDeleteSubquery", engine: postgresRoundTripEngine, dml: "DELETE FROM t WHERE a IN (SELECT a FROM t WHERE a > 3);"},
+		{name: "PostgresUpdateAlias", engine: postgresRoundTripEngine, dml: "UPDATE t AS x SET a = x.id * 10 WHERE x.b IS NOT NULL;"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testAffectedRowsConformance(t, test.engine, table, test.dml)
+		})
+	}
+}
+
+func TestPriorBackupIsolationLevel(t *testing.T) {
+	table := &roundTripTable{
+		name:       "t",
+		columns:    []string{"id", "a"},
+		primaryKey: []string{"id"},
+		schema: `
+			CREATE TABLE t(id INT PRIMARY KEY, a INT);
+			INSERT INTO t VALUES (1, 1), (2, 2);`,
+	}
+	tests := []struct {
+		name           string
+		engine         *roundTripEngine
+		isolationLevel string
+		lockRows       bool
+		want           string
+	}{
+		// The backup statements run at REPEATABLE READ by default.
+		{name: "MySQL", engine: mysqlRoundTripEngine, want: "REPEATABLE READ"},
+		{name: "Postgres", engine: postgresRoundTripEngine, want: "REPEATABLE READ"},
+		{name: "MySQLReadCommitted", engine: mysqlRoundTripEngine, isolationLevel: "read committed", want: "READ COMMITTED"},
+		{name: "PostgresSerializable", engine: postgresRoundTripEngine, isolationLevel: "SERIALIZABLE", want: "SERIALIZABLE"},
+		// The backup statements deferred to the data update transaction run at the level of the transaction.
+		{name: "PostgresLockRows", engine: postgresRoundTripEngine, lockRows: true, want: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := require.New(t)
+			ctx := context.Background()
+			h := newRoundTripHarness(t, test.engine, table)
+			h.exec.profile.PriorBackupIsolationLevel = test.isolationLevel
+			h.exec.profile.PriorBackupLockRows = test.lockRows
+			payload := &storepb.TaskDatabaseUpdatePayload{
+				PreUpdateBackupDetail: &storepb.PreUpdateBackupDetail{Database: common.FormatDatabase(h.instance.ResourceID, test.engine.backupDatabaseName)},
+			}
+			detail, _, err := h.exec.backupData(ctx, ctx, "UPDATE t SET a = 0 WHERE id = 1;", payload, storepb.TaskDatabaseUpdatePayload_REQUIRED, h.task, 0)
+			a.NoError(err)
+			a.NotEmpty(detail.GetItems())
+			a.Equal(test.want, detail.GetIsolationLevel())
+		})
+	}
+}
+
+// roundTripTable is the table changed by the DML of a prior backup round-trip.
+type roundTripTable struct {
+	name       string
+	columns    []string
+	primaryKey []string
+	// schema is the statements creating the table and inserting the original rows.
+	schema string
+}
+
+func TestPriorBackupBulkCopyStrategy(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	table := &roundTripTable{
+		name:       "t",
+		columns:    []string{"id", "a", "b"},
+		primaryKey: []string{"id"},
+		schema: `
+			CREATE TABLE t(id INT PRIMARY KEY, a INT, b VARCHAR(20));
+			INSERT INTO t VALUES (1, 1, 'x'), (2, 2, NULL), (3, 3, 'z');`,
+	}
+	h := newRoundTripHarness(t, postgresRoundTripEngine, table)
+	h.exec.profile.PriorBackupBulkCopy = true
+
+	payload := &storepb.TaskDatabaseUpdatePayload{
+		PreUpdateBackupDetail: &storepb.PreUpdateBackupDetail{Database: common.FormatDatabase(h.instance.ResourceID, postgresRoundTripEngine.backupDatabaseName)},
+	}
+	detail, _, err := h.exec.backupData(ctx, ctx, "UPDATE t SET b = 'changed' WHERE a >= 2;", payload, storepb.TaskDatabaseUpdatePayload_REQUIRED, h.task, 0)
+	a.NoError(err)
+	a.Len(detail.GetItems(), 1)
+	item := detail.GetItems()[0]
+	a.Equal(storepb.PriorBackupDetail_Item_BULK_COPY, item.GetStrategy())
+	a.Equal("the bulk copy is configured", item.GetStrategyReason())
+	a.Equal(int64(2), item.GetRowCount())
+	var rows int64
+	a.NoError(h.sqlDB.QueryRow(fmt.Sprintf(`SELECT count(*) FROM "%s"."%s"`, postgresRoundTripEngine.backupDatabaseName, item.GetTargetTable().GetTable())).Scan(&rows))
+	a.Equal(int64(2), rows)
+}
+
+// TestPostgresBackupExportRoundTrip exports the rows to the object store and stages them back on Postgres, where the bytea values
+// aren't valid UTF-8 and the text values look like NULL or the bytea values.
+func TestPostgresBackupExportRoundTrip(t *testing.T) {
+	a := require.New(t)
+	ctx := context.Background()
+	table := &roundTripTable{
+		name:       "t",
+		columns:    []string{"id", "data", "name"},
+		primaryKey: []string{"id"},
+		schema: `
+			CREATE TABLE t(id INT PRIMARY KEY, data BYTEA, name TEXT);
+			INSERT INTO t VALUES (1, '\xff005c', '\N'), (2, '\x', '\x41'), (3, NULL, NULL);`,
+	}
+	h := newRoundTripHarness(t, postgresRoundTripEngine, table)
+	original, err := dumpRoundTripTable(h.sqlDB, table)
+	a.NoError(err)
+
+	rows, err := h.sqlDB.QueryContext(ctx, "SELECT * FROM t")
+	a.NoError(err)
+	binaryColumns, err := getBackupExportBinaryColumns(rows)
+	a.NoError(err)
+	a.Equal(map[int]bool{1: true}, binaryColumns)
+	objectStore := &fakeObjectStore{objects: map[string][]byte{}}
+	_, rowCount, err := uploadBackupExport(ctx, objectStore, "exports/0_t.csv.gz", rows, binaryColumns)
+	a.NoError(rows.Close())
+	a.NoError(err)
+	a.Equal(int64(3), rowCount)
+
+	detail := &storepb.PriorBackupDetail{
+		ExportManifestKey: "exports/manifest.json",
+		Items: []*storepb.PriorBackupDetail_Item{
+			{
+				SourceTable: &storepb.PriorBackupDetail_Item_Table{Database: common.FormatDatabase(h.instance.ResourceID, h.database.DatabaseName), Schema: "public", Table: "t"},
+				ObjectKey:   "exports/0_t.csv.gz",
+			},
+		},
+	}
+	staged, _, err := stageBackupExports(ctx, h.sqlDB, objectStore, storepb.Engine_POSTGRES, 1, "0a1b2c3d", detail)
+	a.NoError(err)
+	restored, err := dumpRoundTripTable(h.sqlDB, &roundTripTable{
+		name:       fmt.Sprintf("%s.%s", sinkBackupSchema, staged.Items[0].GetTargetTable().GetTable()),
+		columns:    table.columns,
+		primaryKey: table.primaryKey,
+	})
+	a.NoError(err)
+	a.Equal(original, restored)
+}
+
+// roundTripEngine is the per-engine hooks of the prior backup round-trip harness.
+type roundTripEngine struct {
+	engine storepb.Engine
+	// databaseName is the database of the table, and backupDatabaseName is the database or schema keeping the backup tables.
+	databaseName       string
+	backupDatabaseName string
+	// setup starts the database instance with the database and the backup database, and returns the admin data source of the instance.
+	setup func(t *testing.T, binDirs *roundTripBinDirs) *store.DataSourceMessage
+}
+
+// roundTripHarness is the metadata store, the data update executor and the task of a prior backup round-trip.
+type roundTripHarness struct {
+	store    *store.Store
+	syncer   *schemasync.Syncer
+	exec     *DataUpdateExecutor
+	instance *store.InstanceMessage
+	database *store.DatabaseMessage
+	task     *store.TaskMessage
+	// driver is the admin driver of the task database, and sqlDB is its connection.
+	driver db.Driver
+	sqlDB  *sql.DB
+}
+
+// testPriorBackupRoundTrip backs up the rows affected by the DML by backupData, applies the DML, restores the rows by the rollback statements
+// of the prior backup detail, and asserts the table returns to its original state.
+func testPriorBackupRoundTrip(t *testing.T, e *roundTripEngine, table *roundTripTable, dml string) {
+	a := require.New(t)
+	ctx := context.Background()
+
+	h := newRoundTripHarness(t, e, table)
+	original, err := dumpRoundTripTable(h.sqlDB, table)
+	a.NoError(err)
+
+	payload := &storepb.TaskDatabaseUpdatePayload{
+		PreUpdateBackupDetail: &storepb.PreUpdateBackupDetail{Database: common.FormatDatabase(h.instance.ResourceID, e.backupDatabaseName)},
+	}
+	detail, _, err := h.exec.backupData(ctx, ctx, dml, payload, storepb.TaskDatabaseUpdatePayload_REQUIRED, h.task, 0)
+	a.NoError(err)
+	a.NotEmpty(detail.GetItems())
+
+	_, err = h.sqlDB.Exec(dml)
+	a.NoError(err)
+	changed, err := dumpRoundTripTable(h.sqlDB, table)
+	a.NoError(err)
+	a.NotEqual(original, changed, "the DML must change the table")
+
+	rCtx := base.RestoreContext{
+		InstanceID:              h.instance.ResourceID,
+		GetDatabaseMetadataFunc: BuildGetDatabaseMetadataFunc(h.store),
+	}
+	statements, err := GetRollbackStatements(ctx, e.engine, rCtx, dml, common.FormatDatabase(h.database.InstanceID, h.database.DatabaseName), detail)
+	a.NoError(err)
+	a.NoError(executeRollbackStatements(ctx, h.driver, e.engine, statements, db.ExecuteOptions{}, newRollbackProgress(h.exec.stateCfg, realClock{}, h.task.ID, statements)))
+	restored, err := dumpRoundTripTable(h.sqlDB, table)
+	a.NoError(err)
+	a.Equal(original, restored)
+}
+
+// testAffectedRowsConformance backs up the rows affected by the DML, applies the DML, and asserts by the affected rows check
+// of the engine that the backup tables hold exactly the rows changed by the DML.
+func testAffectedRowsConformance(t *testing.T, e *roundTripEngine, table *roundTripTable, dml string) {
+	a := require.New(t)
+	ctx := context.Background()
+
+	h := newRoundTripHarness(t, e, table)
+	tc := base.TransformContext{
+		InstanceID:              h.instance.ResourceID,
+		GetDatabaseMetadataFunc: BuildGetDatabaseMetadataFunc(h.store),
+	}
+	backupStatements, err := base.TransformDMLToSelect(ctx, e.engine, tc, dml, e.databaseName, e.backupDatabaseName, "_conformance")
+	a.NoError(err)
+	a.NotEmpty(backupStatements)
+	var checks []*base.AffectedRowsCheck
+	for _, backupStatement := range backupStatements {
+		_, err = h.sqlDB.Exec(backupStatement.Statement)
+		a.NoError(err, backupStatement.Statement)
+		check, err := base.GenerateAffectedRowsCheck(e.engine, base.AffectedRowsCheckContext{
+			SourceDatabase: e.databaseName,
+			BackupDatabase: e.backupDatabaseName,
+			SnapshotTable:  "_snapshot" + backupStatement.TargetTableName,
+			Columns:        table.columns,
+		}, backupStatement)
+		a.NoError(err)
+		checks = append(checks, check)
+	}
+	for _, check := range checks {
+		_, err = h.sqlDB.Exec(check.Snapshot)
+		a.NoError(err, check.Snapshot)
+	}
+
+	_, err = h.sqlDB.Exec(dml)
+	a.NoError(err)
+	for _, check := range checks {
+		for _, query := range []string{check.Missing, check.Extra, check.Unchanged} {
+			var count int
+			a.NoError(h.sqlDB.QueryRow(query).Scan(&count), query)
+			a.Zero(count, query)
+		}
+		_, err = h.sqlDB.Exec(check.Cleanup)
+		a.NoError(err)
+	}
+}
+
+// newRoundTripHarness starts the metadata store and the database instance of the engine, creates the table in the task database,
+// and syncs its schema into the store so that backupData finds the table as it does in production.
+func newRoundTripHarness(t *testing.T, e *roundTripEngine, table *roundTripTable) *roundTripHarness {
+	a := require.New(t)
+	ctx := context.Background()
+	binDirs := installRoundTripBinDirs(t)
+
+	metaPort := getRoundTripPort(t)
+	t.Cleanup(postgres.SetupTestInstance(binDirs.pg, t.TempDir(), metaPort))
+	storeDB := store.NewDB(db.ConnectionConfig{
+		Username:             postgres.TestPgUser,
+		Host:                 common.GetPostgresSocketDir(),
+		Port:                 strconv.Itoa(metaPort),
+		Database:             "bbmeta",
+		MaximumSQLResultSize: common.DefaultMaximumSQLResultSize,
+	}, binDirs.pg, false /* readonly */, common.ReleaseModeDev)
+	a.NoError(storeDB.Open(ctx, true /* createDB */))
+	profile := &config.Profile{Mode: common.ReleaseModeDev}
+	storeInstance, err := store.New(storeDB, profile)
+	a.NoError(err)
+	_, err = migrator.MigrateSchema(ctx, storeDB, storeInstance, binDirs.pg, "server-version", common.ReleaseModeDev)
+	a.NoError(err)
+
+	licenseService, err := enterprisesvc.NewLicenseService(common.ReleaseModeDev, storeInstance)
+	a.NoError(err)
+	stateCfg, err := state.New()
+	a.NoError(err)
+	dbFactory := dbfactory.New(storeInstance, binDirs.mysql, "" /* mongoBinDir */, binDirs.pg, t.TempDir(), "" /* secret */)
+	syncer := schemasync.NewSyncer(storeInstance, dbFactory, stateCfg, profile, licenseService)
+	exec, ok := NewDataUpdateExecutor(storeInstance, dbFactory, licenseService, stateCfg, syncer, profile, nil, nil).(*DataUpdateExecutor)
+	a.True(ok)
+
+	dataSource := e.setup(t, binDirs)
+	instance, err := storeInstance.CreateInstanceV2(ctx, &store.InstanceMessage{
+		ResourceID:  "roundtrip",
+		Title:       "roundtrip",
+		Engine:      e.engine,
+		DataSources: []*store.DataSourceMessage{dataSource},
+	}, api.SystemBotID, 0 /* maximumActivation */)
+	a.NoError(err)
+	database, err := storeInstance.UpsertDatabase(ctx, &store.DatabaseMessage{
+		ProjectID:    api.DefaultProjectID,
+		InstanceID:   instance.ResourceID,
+		DatabaseName: e.databaseName,
+	})
+	a.NoError(err)
+	// The backup tables are in the backup schema of the task database on Postgres.
+	if e.engine != storepb.Engine_POSTGRES {
+		_, err = storeInstance.UpsertDatabase(ctx, &store.DatabaseMessage{
+			ProjectID:    api.DefaultProjectID,
+			InstanceID:   instance.ResourceID,
+			DatabaseName: e.backupDatabaseName,
+		})
+		a.NoError(err)
+	}
+
+	driver, err := dbFactory.GetAdminDatabaseDriver(ctx, instance, database, db.ConnectionContext{})
+	a.NoError(err)
+	t.Cleanup(func() {
+		driver.Close(ctx)
+	})
+	_, err = driver.GetDB().Exec(table.schema)
+	a.NoError(err)
+	a.NoError(syncer.SyncDatabaseSchema(ctx, database, true /* force */))
+
+	project, err := storeInstance.GetProjectV2(ctx, &store.FindProjectMessage{ResourceID: &database.ProjectID})
+	a.NoError(err)
+	pipeline, err := storeInstance.CreatePipelineV2(ctx, &store.PipelineMessage{ProjectID: project.ResourceID, Name: "roundtrip"}, api.SystemBotID)
+	a.NoError(err)
+	_, err = storeInstance.CreateIssueV2(ctx, &store.IssueMessage{
+		Project:     project,
+		Title:       "roundtrip",
+		Type:        api.IssueDatabaseGeneral,
+		Payload:     &storepb.IssuePayload{},
+		PipelineUID: &pipeline.ID,
+	}, api.SystemBotID)
+	a.NoError(err)
+
+	return &roundTripHarness{
+		store:    storeInstance,
+		syncer:   syncer,
+		exec:     exec,
+		instance: instance,
+		database: database,
+		task: &store.TaskMessage{
+			ID:         1,
+			PipelineID: pipeline.ID,
+			InstanceID: instance.UID,
+			DatabaseID: &database.UID,
+		},
+		driver: driver,
+		sqlDB:  driver.GetDB(),
+	}
+}
+
+// roundTripBinDirs are the binary directories of the database instances started by the harness.
+type roundTripBinDirs struct {
+	pg    string
+	mysql string
+}
+
+var (
+	roundTripInstallOnce sync.Once
+	roundTripInstalled   *roundTripBinDirs
+	roundTripInstallErr  error
+)
+
+// installRoundTripBinDirs installs the Postgres and MySQL binaries once for all the round-trip tests.
+func installRoundTripBinDirs(t *testing.T) *roundTripBinDirs {
+	roundTripInstallOnce.Do(func() {
+		resourceDir := filepath.Join(os.TempDir(), "bbtest-roundtrip")
+		pgBinDir, err := postgres.Install(resourceDir)
+		if err != nil {
+			roundTripInstallErr = err
+			return
+		}
+		mysqlBinDir, err := resourcemysql.Install(resourceDir)
+		if err != nil {
+			roundTripInstallErr = err
+			return
+		}
+		roundTripInstalled = &roundTripBinDirs{pg: pgBinDir, mysql: mysqlBinDir}
+	})
+	require.NoError(t, roundTripInstallErr)
+	return roundTripInstalled
+}
+
+// getRoundTripPort returns an unused local port.
+func getRoundTripPort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// dumpRoundTripTable returns the rows of the table ordered by the primary key.
+func dumpRoundTripTable(sqlDB *sql.DB, table *roundTripTable) ([][]string, error) {
+	rows, err := sqlDB.Query(fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", strings.Join(table.columns, ", "), table.name, strings.Join(table.primaryKey, ", ")))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result [][]string
+	for rows.Next() {
+		values := make([]sql.NullString, len(table.columns))
+		pointers := make([]any, len(table.columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		var row []string
+		for _, value := range values {
+			if !value.Valid {
+				row = append(row, "NULL")
+				continue
+			}
+			row = append(row, value.String)
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// mysqlRoundTripEngine backs up into the backup database on the MySQL instance.
+var mysqlRoundTripEngine = &roundTripEngine{
+	engine:             storepb.Engine_MYSQL,
+	databaseName:       "db",
+	backupDatabaseName: "bbdataarchive",
+	setup: func(t *testing.T, binDirs *roundTripBinDirs) *store.DataSourceMessage {
+		a := require.New(t)
+		port := getRoundTripPort(t)
+		t.Cleanup(resourcemysql.SetupTestInstance(t, port, binDirs.mysql))
+		sqlDB, err := sql.Open("mysql", fmt.Sprintf("root@tcp(127.0.0.1:%d)/mysql?multiStatements=true", port))
+		a.NoError(err)
+		defer sqlDB.Close()
+		_, err = sqlDB.Exec("CREATE DATABASE db; CREATE DATABASE bbdataarchive;")
+		a.NoError(err)
+		return &store.DataSourceMessage{ID: "admin", Type: api.Admin, Username: "root", Host: "127.0.0.1", Port: strconv.Itoa(port)}
+	},
+}
+
+// postgresRoundTripEngine backs up into the backup schema of the task database on the Postgres instance.
+var postgresRoundTripEngine = &roundTripEngine{
+	engine:             storepb.Engine_POSTGRES,
+	databaseName:       "db",
+	backupDatabaseName: "bbdataarchive",
+	setup: func(t *testing.T, binDirs *roundTripBinDirs) *store.DataSourceMessage {
+		a := require.New(t)
+		port := getRoundTripPort(t)
+		t.Cleanup(postgres.SetupTestInstance(binDirs.pg, t.TempDir(), port))
+		sqlDB, err := sql.Open("pgx", fmt.Sprintf("host=%s port=%d user=%s database=postgres", common.GetPostgresSocketDir(), port, postgres.TestPgUser))
+		a.NoError(err)
+		_, err = sqlDB.Exec("CREATE DATABASE db;")
+		a.NoError(err)
+		a.NoError(sqlDB.Close())
+		sqlDB, err = sql.Open("pgx", fmt.Sprintf("host=%s port=%d user=%s database=db", common.GetPostgresSocketDir(), port, postgres.TestPgUser))
+		a.NoError(err)
+		defer sqlDB.Close()
+		_, err = sqlDB.Exec("CREATE SCHEMA bbdataarchive;")
+		a.NoError(err)
+		return &store.DataSourceMessage{ID: "admin", Type: api.Admin, Username: postgres.TestPgUser, Host: common.GetPostgresSocketDir(), Port: strconv.Itoa(port)}
+	},
+}
@@ -35,6 +35,8 @@ type SchedulerV2 struct {
 	webhookManager *webhook.Manager
 	executorMap    map[api.TaskType]Executor
 	profile        *config.Profile
+	// clock tells the time of scheduling, which is compared with the deferrals of the executors.
+	clock Clock
 }
 
 // NewSchedulerV2 will create a new scheduler.
@@ -45,6 +47,7 @@ func NewSchedulerV2(store *store.Store, stateCfg *state.State, webhookManager *w
 		webhookManager: webhookManager,
 		profile:        profile,
 		executorMap:    map[api.TaskType]Executor{},
+		clock:          realClock{},
 	}
 }
 
@@ -208,7 +211,7 @@ func (s *SchedulerV2) scheduleAutoRolloutTask(ctx context.Context, taskUID int)
 		CreatorID: api.SystemBotID,
 		TaskUID:   task.ID,
 		SheetUID:  sheetUID,
-		Name:      fmt.Sprintf("%s %d", task.Name, time.Now().Unix()),
+		Name:      fmt.Sprintf("%s %d", task.Name, s.clock.Now().Unix()),
 	}
 
 	if err := s.store.CreatePendingTaskRuns(ctx, create); err != nil {
@@ -258,7 +261,7 @@ func (s *SchedulerV2) schedulePendingTaskRun(ctx context.Context, taskRun *store
 	if err != nil {
 		return errors.Wrapf(err, "failed to get task")
 	}
-	if task.EarliestAllowedTs != 0 && time.Now().Before(time.Unix(task.EarliestAllowedTs, 0)) {
+	if task.EarliestAllowedTs != 0 && s.clock.Now().Before(time.Unix(task.EarliestAllowedTs, 0)) {
 		return nil
 	}
 	for _, blockingTaskUID := range task.DependsOn {
@@ -289,7 +292,7 @@ func (s *SchedulerV2) schedulePendingTaskRun(ctx context.Context, taskRun *store
 	}); err != nil {
 		return errors.Wrapf(err, "failed to update task run status to running")
 	}
-	s.store.CreateTaskRunLogS(ctx, taskRun.ID, time.Now(), s.profile.DeployID, &storepb.TaskRunLog{
+	s.store.CreateTaskRunLogS(ctx, taskRun.ID, s.clock.Now(), s.profile.DeployID, &storepb.TaskRunLog{
 		Type: storepb.TaskRunLog_TASK_RUN_STATUS_UPDATE,
 		TaskRunStatusUpdate: &storepb.TaskRunLog_TaskRunStatusUpdate{
 			Status: storepb.TaskRunLog_TaskRunStatusUpdate_RUNNING_WAITING,
@@ -333,6 +336,35 @@ func (s *SchedulerV2) scheduleRunningTaskRuns(ctx context.Context) error {
 		if _, ok := s.stateCfg.RunningTaskRuns.Load(taskRun.ID); ok {
 			continue
 		}
+		// Skip the task run until its asynchronous prior backup completes.
+		if backupAny, ok := s.stateCfg.PriorBackupAsyncTaskRuns.Load(taskRun.ID); ok {
+			if backup, ok := backupAny.(*asyncBackup); ok && backup.inProgress() {
+				s.stateCfg.TaskRunSchedulerInfo.Store(taskRun.ID, &storepb.SchedulerInfo{
+					ReportTime: timestamppb.New(s.clock.Now()),
+					WaitingCause: &storepb.SchedulerInfo_WaitingCause{
+						Cause: &storepb.SchedulerInfo_WaitingCause_PriorBackupInProgress{
+							PriorBackupInProgress: true,
+						},
+					},
+				})
+				continue
+			}
+		}
+		// Skip the task run until its deferred prior backup is retried, e.g. after the blackout window ends.
+		if untilAny, ok := s.stateCfg.PriorBackupDeferredTaskRuns.Load(taskRun.ID); ok {
+			if until, ok := untilAny.(time.Time); ok && s.clock.Now().Before(until) {
+				s.stateCfg.TaskRunSchedulerInfo.Store(taskRun.ID, &storepb.SchedulerInfo{
+					ReportTime: timestamppb.New(s.clock.Now()),
+					WaitingCause: &storepb.SchedulerInfo_WaitingCause{
+						Cause: &storepb.SchedulerInfo_WaitingCause_PriorBackupBlackoutUntil{
+							PriorBackupBlackoutUntil: timestamppb.New(until),
+						},
+					},
+				})
+				continue
+			}
+			s.stateCfg.PriorBackupDeferredTaskRuns.Delete(taskRun.ID)
+		}
 		task, err := s.store.GetTaskV2ByID(ctx, taskRun.TaskUID)
 		if err != nil {
 			slog.Error("failed to get task", slog.Int("task id", taskRun.TaskUID), log.BBError(err))
@@ -340,10 +372,11 @@ func (s *SchedulerV2) scheduleRunningTaskRuns(ctx context.Context) error {
 		}
 		if task.DatabaseID != nil && task.Type.Sequential() {
 			// Skip the task run if there is an ongoing migration on the database.
-			if taskUIDAny, ok := s.stateCfg.RunningDatabaseMigration.Load(*task.DatabaseID); ok {
+			// The database held by the task itself during its asynchronous prior backup doesn't block it.
+			if taskUIDAny, ok := s.stateCfg.RunningDatabaseMigration.Load(*task.DatabaseID); ok && taskUIDAny != task.ID {
 				if taskUID, ok := taskUIDAny.(int); ok {
 					s.stateCfg.TaskRunSchedulerInfo.Store(taskRun.ID, &storepb.SchedulerInfo{
-						ReportTime: timestamppb.Now(),
+						ReportTime: timestamppb.New(s.clock.Now()),
 						WaitingCause: &storepb.SchedulerInfo_WaitingCause{
 							Cause: &storepb.SchedulerInfo_WaitingCause_TaskUid{
 								TaskUid: int32(taskUID),
@@ -355,7 +388,7 @@ func (s *SchedulerV2) scheduleRunningTaskRuns(ctx context.Context) error {
 			}
 			if taskUID := minTaskIDForDatabase[*task.DatabaseID]; taskUID != task.ID {
 				s.stateCfg.TaskRunSchedulerInfo.Store(taskRun.ID, &storepb.SchedulerInfo{
-					ReportTime: timestamppb.Now(),
+					ReportTime: timestamppb.New(s.clock.Now()),
 					WaitingCause: &storepb.SchedulerInfo_WaitingCause{
 						Cause: &storepb.SchedulerInfo_WaitingCause_TaskUid{
 							TaskUid: int32(taskUID),
@@ -385,7 +418,7 @@ func (s *SchedulerV2) scheduleRunningTaskRuns(ctx context.Context) error {
 		maximumConnections := int(instance.Options.GetMaximumConnections())
 		if s.stateCfg.InstanceOutstandingConnections.Increment(task.InstanceID, maximumConnections) {
 			s.stateCfg.TaskRunSchedulerInfo.Store(taskRun.ID, &storepb.SchedulerInfo{
-				ReportTime: timestamppb.Now(),
+				ReportTime: timestamppb.New(s.clock.Now()),
 				WaitingCause: &storepb.SchedulerInfo_WaitingCause{
 					Cause: &storepb.SchedulerInfo_WaitingCause_ConnectionLimit{
 						ConnectionLimit: true,
@@ -402,7 +435,7 @@ func (s *SchedulerV2) scheduleRunningTaskRuns(ctx context.Context) error {
 			s.stateCfg.RunningDatabaseMigration.Store(*task.DatabaseID, task.ID)
 		}
 
-		s.store.CreateTaskRunLogS(ctx, taskRun.ID, time.Now(), s.profile.DeployID, &storepb.TaskRunLog{
+		s.store.CreateTaskRunLogS(ctx, taskRun.ID, s.clock.Now(), s.profile.DeployID, &storepb.TaskRunLog{
 			Type: storepb.TaskRunLog_TASK_RUN_STATUS_UPDATE,
 			TaskRunStatusUpdate: &storepb.TaskRunLog_TaskRunStatusUpdate{
 				Status: storepb.TaskRunLog_TaskRunStatusUpdate_RUNNING_RUNNING,
@@ -415,13 +448,21 @@ func (s *SchedulerV2) scheduleRunningTaskRuns(ctx context.Context) error {
 }
 
 func (s *SchedulerV2) runTaskRunOnce(ctx context.Context, taskRun *store.TaskRunMessage, task *store.TaskMessage, executor Executor) {
+	// retry is whether the task run stays running while its prior backup is in progress or deferred, so it's scheduled again.
+	retry := false
+	// holdDatabase is whether the database stays held by the task run until its asynchronous prior backup completes.
+	holdDatabase := false
 	defer func() {
 		// We don't need to do s.stateCfg.RunningTaskRuns.Delete(taskRun.ID) to avoid race condition.
 		s.stateCfg.RunningTaskRunsCancelFunc.Delete(taskRun.ID)
-		if task.DatabaseID != nil {
+		if task.DatabaseID != nil && !holdDatabase {
 			s.stateCfg.RunningDatabaseMigration.Delete(*task.DatabaseID)
 		}
 		s.stateCfg.InstanceOutstandingConnections.Decrement(task.InstanceID)
+		// The task run is still running in the store, so it's only rescheduled after the cleanup above.
+		if retry {
+			s.stateCfg.RunningTaskRuns.Delete(taskRun.ID)
+		}
 	}()
 
 	driverCtx, cancel := context.WithCancel(ctx)
@@ -437,6 +478,9 @@ func (s *SchedulerV2) runTaskRunOnce(ctx context.Context, taskRun *store.TaskRun
 			slog.String("type", string(task.Type)),
 			log.BBError(err),
 		)
+		var backupDeferredErr *backupDeferredError
+		retry = errors.Is(err, errBackupInProgress) || errors.As(err, &backupDeferredErr)
+		holdDatabase = errors.Is(err, errBackupInProgress)
 		return
 	}
 
@@ -500,6 +544,11 @@ func (s *SchedulerV2) runTaskRunOnce(ctx context.Context, taskRun *store.TaskRun
 			taskRunResult.StartPosition = errWithPosition.Start
 			taskRunResult.EndPosition = errWithPosition.End
 		}
+		// Keep track of the backup tables created before the prior backup failed.
+		var backupFailedErr *backupFailedError
+		if errors.As(err, &backupFailedErr) {
+			taskRunResult.PriorBackupDetail = backupFailedErr.detail
+		}
 
 		resultBytes, marshalErr := protojson.Marshal(taskRunResult)
 		if marshalErr != nil {
@@ -25,6 +25,7 @@ import (
 	"github.com/bytebase/bytebase/backend/api/gitops"
 	"github.com/bytebase/bytebase/backend/api/lsp"
 	apiv1 "github.com/bytebase/bytebase/backend/api/v1"
+	"github.com/bytebase/bytebase/backend/common"
 	"github.com/bytebase/bytebase/backend/common/log"
 	"github.com/bytebase/bytebase/backend/common/stacktrace"
 	"github.com/bytebase/bytebase/backend/component/config"
@@ -38,7 +39,9 @@ import (
 	enterprisesvc "github.com/bytebase/bytebase/backend/enterprise/service"
 	api "github.com/bytebase/bytebase/backend/legacyapi"
 	"github.com/bytebase/bytebase/backend/migrator"
+	"github.com/bytebase/bytebase/backend/plugin/backupsink"
 	dbdriver "github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/objectstore"
 	"github.com/bytebase/bytebase/backend/resources/mongoutil"
 	"github.com/bytebase/bytebase/backend/resources/mysqlutil"
 	"github.com/bytebase/bytebase/backend/resources/postgres"
@@ -46,6 +49,7 @@ import (
 	"github.com/bytebase/bytebase/backend/runner/mail"
 	"github.com/bytebase/bytebase/backend/runner/metricreport"
 	"github.com/bytebase/bytebase/backend/runner/plancheck"
+	"github.com/bytebase/bytebase/backend/runner/priorbackup"
 	"github.com/bytebase/bytebase/backend/runner/relay"
 	"github.com/bytebase/bytebase/backend/runner/schemasync"
 	"github.com/bytebase/bytebase/backend/runner/slowquerysync"
@@ -73,6 +77,7 @@ type Server struct {
 	mailSender         *mail.SlowQueryWeeklyMailSender
 	approvalRunner     *approval.Runner
 	relayRunner        *relay.Runner
+	backupReconciler   *priorbackup.Reconciler
 	runnerWG           sync.WaitGroup
 
 	webhookManager *webhook.Manager
@@ -261,16 +266,47 @@ func NewServer(ctx context.Context, profile *config.Profile) (*Server, error) {
 		s.slowQuerySyncer = slowquerysync.NewSyncer(storeInstance, s.dbFactory, s.stateCfg, profile)
 		s.mailSender = mail.NewSender(s.store, s.stateCfg, s.iamManager)
 		s.relayRunner = relay.NewRunner(storeInstance, s.webhookManager, s.stateCfg)
+		s.backupReconciler = priorbackup.NewReconciler(storeInstance, s.dbFactory, profile)
 		s.approvalRunner = approval.NewRunner(storeInstance, s.sheetManager, s.dbFactory, s.stateCfg, s.webhookManager, s.relayRunner, s.licenseService)
 
+		// The prior backups are published to the sinks only if the sink brokers are configured.
+		var backupProducer taskrun.PriorBackupProducer
+		if len(profile.PriorBackupSinkBrokers) > 0 {
+			producer, err := backupsink.NewKafkaProducer(backupsink.KafkaConfig{
+				Brokers:  profile.PriorBackupSinkBrokers,
+				TLS:      profile.PriorBackupSinkTLS,
+				Username: profile.PriorBackupSinkUsername,
+				Password: profile.PriorBackupSinkPassword,
+			})
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to create prior backup sink producer")
+			}
+			backupProducer = producer
+		}
+		// The prior backups are kept in the object store only if the object store is configured.
+		var backupObjectStore common.PriorBackupObjectStore
+		if profile.PriorBackupObjectStoreURL != "" {
+			backupObjectStore, err = objectstore.New(ctx, objectstore.Config{
+				URL:              profile.PriorBackupObjectStoreURL,
+				Endpoint:         profile.PriorBackupObjectStoreEndpoint,
+				Region:           profile.PriorBackupObjectStoreRegion,
+				AccessKeyID:      profile.PriorBackupObjectStoreAccessKeyID,
+				SecretAccessKey:  profile.PriorBackupObjectStoreSecretAccessKey,
+				ConnectionString: profile.PriorBackupObjectStoreConnectionString,
+			})
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to create prior backup object store")
+			}
+		}
 		s.taskSchedulerV2 = taskrun.NewSchedulerV2(storeInstance, s.stateCfg, s.webhookManager, profile)
 		s.taskSchedulerV2.Register(api.TaskGeneral, taskrun.NewDefaultExecutor())
 		s.taskSchedulerV2.Register(api.TaskDatabaseCreate, taskrun.NewDatabaseCreateExecutor(storeInstance, s.dbFactory, s.schemaSyncer, s.stateCfg, profile))
 		s.taskSchedulerV2.Register(api.TaskDatabaseSchemaBaseline, taskrun.NewSchemaBaselineExecutor(storeInstance, s.dbFactory, s.licenseService, s.stateCfg, s.schemaSyncer, profile))
 		s.taskSchedulerV2.Register(api.TaskDatabaseSchemaUpdate, taskrun.NewSchemaUpdateExecutor(storeInstance, s.dbFactory, s.licenseService, s.stateCfg, s.schemaSyncer, profile))
 		s.taskSchedulerV2.Register(api.TaskDatabaseSchemaUpdateSDL, taskrun.NewSchemaUpdateSDLExecutor(storeInstance, s.dbFactory, s.licenseService, s.stateCfg, s.schemaSyncer, profile))
-		s.taskSchedulerV2.Register(api.TaskDatabaseDataUpdate, taskrun.NewDataUpdateExecutor(storeInstance, s.dbFactory, s.licenseService, s.stateCfg, s.schemaSyncer, profile))
+		s.taskSchedulerV2.Register(api.TaskDatabaseDataUpdate, taskrun.NewDataUpdateExecutor(storeInstance, s.dbFactory, s.licenseService, s.stateCfg, s.schemaSyncer, profile, backupProducer, backupObjectStore))
 		s.taskSchedulerV2.Register(api.TaskDatabaseDataExport, taskrun.NewDataExportExecutor(storeInstance, s.dbFactory, s.licenseService, s.stateCfg, s.schemaSyncer, profile))
+		s.taskSchedulerV2.Register(api.TaskDatabaseDataRollback, taskrun.NewDataRollbackExecutor(storeInstance, s.dbFactory, s.stateCfg, backupObjectStore))
 		s.taskSchedulerV2.Register(api.TaskDatabaseSchemaUpdateGhostSync, taskrun.NewSchemaUpdateGhostSyncExecutor(storeInstance, s.stateCfg, s.secret))
 		s.taskSchedulerV2.Register(api.TaskDatabaseSchemaUpdateGhostCutover, taskrun.NewSchemaUpdateGhostCutoverExecutor(storeInstance, s.dbFactory, s.licenseService, s.stateCfg, s.schemaSyncer, profile))
 
@@ -377,6 +413,8 @@ func (s *Server) Run(ctx context.Context, port int) error {
 		go s.approvalRunner.Run(ctx, &s.runnerWG)
 		s.runnerWG.Add(1)
 		go s.relayRunner.Run(ctx, &s.runnerWG)
+		s.runnerWG.Add(1)
+		go s.backupReconciler.Run(ctx, &s.runnerWG)
 
 		s.runnerWG.Add(1)
 		go s.metricReporter.Run(ctx, &s.runnerWG)
@@ -59,6 +59,8 @@ type DataSourceMessage struct {
 	MasterName               string
 	MasterUsername           string
 	MasterObfuscatedPassword string
+	// NodeTags are the roles of the node the data source connects to.
+	NodeTags []string
 }
 
 // FindDataSourceMessage is the message for finding a database.
@@ -106,6 +108,7 @@ func (m *DataSourceMessage) Copy() *DataSourceMessage {
 		MasterName:                         m.MasterName,
 		MasterUsername:                     m.MasterUsername,
 		MasterObfuscatedPassword:           m.MasterObfuscatedPassword,
+		NodeTags:                           slices.Clone(m.NodeTags),
 	}
 }
 
@@ -156,6 +159,7 @@ type UpdateDataSourceMessage struct {
 	MasterName               *string
 	MasterUsername           *string
 	MasterObfuscatedPassword *string
+	NodeTags                 *[]string
 }
 
 func (*Store) listInstanceDataSourceMap(ctx context.Context, tx *Tx, find *FindDataSourceMessage) (map[string][]*DataSourceMessage, error) {
@@ -246,6 +250,7 @@ func (*Store) listInstanceDataSourceMap(ctx context.Context, tx *Tx, find *FindD
 		dataSourceMessage.MasterName = dataSourceOptions.MasterName
 		dataSourceMessage.MasterObfuscatedPassword = dataSourceOptions.MasterObfuscatedPassword
 		dataSourceMessage.MasterUsername = dataSourceOptions.MasterUsername
+		dataSourceMessage.NodeTags = dataSourceOptions.NodeTags
 		instanceDataSourcesMap[instanceID] = append(instanceDataSourcesMap[instanceID], &dataSourceMessage)
 	}
 	if err := rows.Err(); err != nil {
@@ -463,6 +468,16 @@ func (s *Store) UpdateDataSourceV2(ctx context.Context, patch *UpdateDataSourceM
 	if v := patch.MasterObfuscatedPassword; v != nil {
 		optionSet, args = append(optionSet, fmt.Sprintf("jsonb_build_object('masterObfuscatedPassword', $%d::TEXT)", len(args)+1)), append(args, *v)
 	}
+	if v := patch.NodeTags; v != nil {
+		partialDataSourceOptions := &storepb.DataSourceOptions{
+			NodeTags: *v,
+		}
+		protoBytes, err := protojson.Marshal(partialDataSourceOptions)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal node tags")
+		}
+		optionSet, args = append(optionSet, fmt.Sprintf("jsonb_build_object('nodeTags', ($%d::JSONB)->'nodeTags')", len(args)+1)), append(args, protoBytes)
+	}
 	if len(optionSet) != 0 {
 		set = append(set, fmt.Sprintf(`options = options || %s`, strings.Join(optionSet, "||")))
 	}
@@ -525,6 +540,7 @@ func (*Store) addDataSourceToInstanceImplV2(ctx context.Context, tx *Tx, instanc
 		MasterName:                         dataSource.MasterName,
 		MasterUsername:                     dataSource.MasterName,
 		MasterObfuscatedPassword:           dataSource.MasterObfuscatedPassword,
+		NodeTags:                           dataSource.NodeTags,
 	}
 	protoBytes, err := protojson.Marshal(&dataSourceOptions)
 	if err != nil {
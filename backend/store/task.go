@@ -311,6 +311,16 @@ func (s *Store) UpdateTaskV2(ctx context.Context, patch *api.TaskPatch) (*TaskMe
 		}
 		payloadSet, args = append(payloadSet, fmt.Sprintf(`jsonb_build_object('preUpdateBackupDetail', $%d::JSONB)`, len(args)+1)), append(args, jsonb)
 	}
+	if v := patch.BackupRequirement; v != nil {
+		payloadSet, args = append(payloadSet, fmt.Sprintf(`jsonb_build_object('backupRequirement', $%d::TEXT)`, len(args)+1)), append(args, v.String())
+	}
+	if v := patch.BudgetDeadline; v != nil {
+		if v.IsZero() {
+			payloadSet = append(payloadSet, `jsonb_build_object('budgetDeadline', NULL)`)
+		} else {
+			payloadSet, args = append(payloadSet, fmt.Sprintf(`jsonb_build_object('budgetDeadline', $%d::TEXT)`, len(args)+1)), append(args, v.UTC().Format(time.RFC3339Nano))
+		}
+	}
 	if v := patch.Flags; v != nil {
 		jsonb, err := json.Marshal(v)
 		if err != nil {
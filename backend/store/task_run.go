@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
 
 	"github.com/bytebase/bytebase/backend/common"
 	api "github.com/bytebase/bytebase/backend/legacyapi"
@@ -179,6 +180,100 @@ func (s *Store) ListTaskRunsV2(ctx context.Context, find *FindTaskRunMessage) ([
 	return taskRuns, nil
 }
 
+// FindPriorBackupDetailMessage is the message for finding the prior backup details.
+type FindPriorBackupDetailMessage struct {
+	ProjectID  *string
+	PipelineID *int
+	StageID    *int
+}
+
+// ListPriorBackupDetails lists the prior backup details recorded in the task run results.
+func (s *Store) ListPriorBackupDetails(ctx context.Context, find *FindPriorBackupDetailMessage) ([]*storepb.PriorBackupDetail, error) {
+	where, args := []string{"task_run.result ? 'priorBackupDetail'"}, []any{}
+	if v := find.ProjectID; v != nil {
+		where, args = append(where, fmt.Sprintf("project.resource_id = $%d", len(args)+1)), append(args, *v)
+	}
+	if v := find.PipelineID; v != nil {
+		where, args = append(where, fmt.Sprintf("task.pipeline_id = $%d", len(args)+1)), append(args, *v)
+	}
+	if v := find.StageID; v != nil {
+		where, args = append(where, fmt.Sprintf("task.stage_id = $%d", len(args)+1)), append(args, *v)
+	}
+	rows, err := s.db.db.QueryContext(ctx, `
+		SELECT task_run.result->'priorBackupDetail'
+		FROM task_run
+		LEFT JOIN task ON task.id = task_run.task_id
+		LEFT JOIN stage ON stage.id = task.stage_id
+		LEFT JOIN pipeline ON pipeline.id = stage.pipeline_id
+		LEFT JOIN project ON project.id = pipeline.project_id
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY task_run.id ASC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var details []*storepb.PriorBackupDetail
+	for rows.Next() {
+		var detail string
+		if err := rows.Scan(&detail); err != nil {
+			return nil, err
+		}
+		var detailProto storepb.PriorBackupDetail
+		if err := common.ProtojsonUnmarshaler.Unmarshal([]byte(detail), &detailProto); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal prior backup detail: %s", detail)
+		}
+		details = append(details, &detailProto)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+// ListPriorBackupRowCounts returns the numbers of rows backed up from the source table by the most recent task runs, newest first.
+// The backups without the row count are skipped.
+func (s *Store) ListPriorBackupRowCounts(ctx context.Context, sourceTable *storepb.PriorBackupDetail_Item_Table, limit int) ([]int64, error) {
+	// The containment of the source table is looked up by the index on the prior backup details.
+	contained, err := protojson.Marshal(&storepb.PriorBackupDetail{
+		Items: []*storepb.PriorBackupDetail_Item{{SourceTable: sourceTable}},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal source table")
+	}
+	rows, err := s.db.db.QueryContext(ctx, `
+		SELECT item->>'rowCount'
+		FROM task_run, jsonb_array_elements(result->'priorBackupDetail'->'items') AS item
+		WHERE result->'priorBackupDetail' @> $1
+			AND item ? 'rowCount'
+			AND item->'sourceTable'->>'database' = $2
+			AND COALESCE(item->'sourceTable'->>'schema', '') = $3
+			AND item->'sourceTable'->>'table' = $4
+		ORDER BY task_run.id DESC
+		LIMIT $5`,
+		string(contained), sourceTable.GetDatabase(), sourceTable.GetSchema(), sourceTable.GetTable(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rowCounts []int64
+	for rows.Next() {
+		var rowCount int64
+		if err := rows.Scan(&rowCount); err != nil {
+			return nil, err
+		}
+		rowCounts = append(rowCounts, rowCount)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rowCounts, nil
+}
+
 // UpdateTaskRunStatus updates task run status.
 func (s *Store) UpdateTaskRunStatus(ctx context.Context, patch *TaskRunStatusPatch) (*TaskRunMessage, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
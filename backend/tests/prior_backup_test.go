@@ -0,0 +1,1138 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+
+	"github.com/bytebase/bytebase/backend/common"
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	pgdriver "github.com/bytebase/bytebase/backend/plugin/db/pg"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	resourcemysql "github.com/bytebase/bytebase/backend/resources/mysql"
+	"github.com/bytebase/bytebase/backend/resources/postgres"
+	"github.com/bytebase/bytebase/backend/runner/taskrun"
+	"github.com/bytebase/bytebase/backend/store/model"
+)
+
+func TestPriorBackupLockRows(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	_, err = pgDB.Exec("CREATE TABLE t(id INT PRIMARY KEY, a INT); INSERT INTO t VALUES (1, 1), (2, 2); CREATE SCHEMA bbdataarchive;")
+	a.NoError(err)
+
+	driver, err := db.Open(ctx, storepb.Engine_POSTGRES, db.DriverConfig{}, db.ConnectionConfig{
+		Username:             postgres.TestPgUser,
+		Host:                 common.GetPostgresSocketDir(),
+		Port:                 strconv.Itoa(pgPort),
+		Database:             "postgres",
+		MaximumSQLResultSize: common.DefaultMaximumSQLResultSize,
+	})
+	a.NoError(err)
+	defer driver.Close(ctx)
+
+	statement := "UPDATE t SET a = a + 10 WHERE id = 1;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{LockRows: true}, statement, "postgres", "bbdataarchive", "_lock")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+
+	// Widen the window between the backup and the data update to modify the locked row concurrently.
+	const window = 2 * time.Second
+	prelude := []string{backupStatements[0].Statement, fmt.Sprintf("SELECT pg_sleep(%d)", int(window.Seconds()))}
+	executeErr := make(chan error, 1)
+	go func() {
+		_, err := driver.Execute(ctx, statement, db.ExecuteOptions{PreludeStatements: prelude})
+		executeErr <- err
+	}()
+
+	time.Sleep(window / 4)
+	conn, err := pgDB.Conn(ctx)
+	a.NoError(err)
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, "SET lock_timeout = '100ms'")
+	a.NoError(err)
+	// The backed up row is locked until the data update commits.
+	_, err = conn.ExecContext(ctx, "UPDATE t SET a = 100 WHERE id = 1")
+	a.ErrorContains(err, "lock timeout")
+	// The rows not backed up are not locked.
+	_, err = conn.ExecContext(ctx, "UPDATE t SET a = 200 WHERE id = 2")
+	a.NoError(err)
+
+	a.NoError(<-executeErr)
+
+	var backup, current int
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT a FROM "bbdataarchive"."%s" WHERE id = 1`, backupStatements[0].TargetTableName)).Scan(&backup))
+	a.Equal(1, backup)
+	a.NoError(pgDB.QueryRow("SELECT a FROM t WHERE id = 1").Scan(&current))
+	a.Equal(11, current)
+}
+
+func TestPriorBackupBulkCopy(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	_, err = pgDB.Exec(`
+		CREATE TABLE t(id INT PRIMARY KEY, name TEXT, amount NUMERIC(10, 2), created_at TIMESTAMPTZ, tags TEXT[]);
+		INSERT INTO t SELECT i, 'name ' || i, i / 3.0, now() - i * interval '1 minute', ARRAY['a', NULL] FROM generate_series(1, 100000) AS i;
+		INSERT INTO t VALUES (0, NULL, NULL, NULL, NULL);
+		CREATE SCHEMA bbdataarchive;`)
+	a.NoError(err)
+
+	driver, err := db.Open(ctx, storepb.Engine_POSTGRES, db.DriverConfig{}, db.ConnectionConfig{
+		Username:             postgres.TestPgUser,
+		Host:                 common.GetPostgresSocketDir(),
+		Port:                 strconv.Itoa(pgPort),
+		Database:             "postgres",
+		MaximumSQLResultSize: common.DefaultMaximumSQLResultSize,
+	})
+	a.NoError(err)
+	defer driver.Close(ctx)
+	pgDriver, ok := driver.(*pgdriver.Driver)
+	a.True(ok)
+
+	query := `SELECT "t".* FROM t WHERE id % 2 = 0`
+	start := time.Now()
+	_, err = driver.Execute(ctx, fmt.Sprintf(`CREATE TABLE "bbdataarchive"."insert_t" AS %s;`, query), db.ExecuteOptions{})
+	a.NoError(err)
+	insertDuration := time.Since(start)
+
+	start = time.Now()
+	// The table name is quoted.
+	rowsAffected, err := pgDriver.CopyQueryToTable(ctx, query, "bbdataarchive", `copy"t`, db.ExecuteOptions{})
+	a.NoError(err)
+	copyDuration := time.Since(start)
+	t.Logf("INSERT ... SELECT took %v, COPY took %v", insertDuration, copyDuration)
+
+	// The copied rows are identical to the rows of the query and the rows inserted by INSERT ... SELECT.
+	var sourceRows, copiedRows int64
+	a.NoError(pgDB.QueryRow(fmt.Sprintf("SELECT count(*) FROM (%s) AS s", query)).Scan(&sourceRows))
+	a.NoError(pgDB.QueryRow(`SELECT count(*) FROM "bbdataarchive"."copy""t"`).Scan(&copiedRows))
+	a.Equal(int64(50001), sourceRows)
+	a.Equal(sourceRows, rowsAffected)
+	a.Equal(sourceRows, copiedRows)
+	var diff int
+	a.NoError(pgDB.QueryRow(`SELECT count(*) FROM (
+		(SELECT * FROM "bbdataarchive"."insert_t" EXCEPT ALL SELECT * FROM "bbdataarchive"."copy""t")
+		UNION ALL
+		(SELECT * FROM "bbdataarchive"."copy""t" EXCEPT ALL SELECT * FROM "bbdataarchive"."insert_t")
+	) AS d`).Scan(&diff))
+	a.Equal(0, diff)
+
+	// The table is dropped if the copy fails midway.
+	_, err = pgDriver.CopyQueryToTable(ctx, "SELECT id, 1 / (id - 50000) AS x FROM t", "bbdataarchive", "failed_t", db.ExecuteOptions{})
+	a.ErrorContains(err, "division by zero")
+	var failedTable sql.NullString
+	a.NoError(pgDB.QueryRow(`SELECT to_regclass('"bbdataarchive"."failed_t"')::text`).Scan(&failedTable))
+	a.False(failedTable.Valid)
+}
+
+func TestPriorBackupSample(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	_, err = pgDB.Exec(`
+		CREATE TABLE t(id INT PRIMARY KEY, a INT);
+		INSERT INTO t SELECT i, i FROM generate_series(1, 10000) AS i;
+		CREATE SCHEMA bbdataarchive;`)
+	a.NoError(err)
+
+	driver, err := db.Open(ctx, storepb.Engine_POSTGRES, db.DriverConfig{}, db.ConnectionConfig{
+		Username:             postgres.TestPgUser,
+		Host:                 common.GetPostgresSocketDir(),
+		Port:                 strconv.Itoa(pgPort),
+		Database:             "postgres",
+		MaximumSQLResultSize: common.DefaultMaximumSQLResultSize,
+	})
+	a.NoError(err)
+	defer driver.Close(ctx)
+
+	statement := "UPDATE t SET a = 0;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "postgres", "bbdataarchive", "_sample")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	sampled, err := taskrun.GetSampleBackupStatement(storepb.Engine_POSTGRES, "bbdataarchive", backupStatements[0], 10)
+	a.NoError(err)
+	_, err = driver.Execute(ctx, sampled, db.ExecuteOptions{})
+	a.NoError(err)
+	_, err = driver.Execute(ctx, statement, db.ExecuteOptions{})
+	a.NoError(err)
+
+	// The sample holds about a tenth of the affected rows, and the data update affects all the rows.
+	var sampleCount, updatedCount int
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT count(*) FROM "bbdataarchive"."%s"`, backupStatements[0].TargetTableName)).Scan(&sampleCount))
+	a.NoError(pgDB.QueryRow("SELECT count(*) FROM t WHERE a = 0").Scan(&updatedCount))
+	a.Greater(sampleCount, 0)
+	a.Less(sampleCount, 2000)
+	a.Equal(10000, updatedCount)
+}
+
+func TestPriorBackupStorageEngine(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	mysqlPort := getTestPort()
+	stopInstance := resourcemysql.SetupTestInstance(t, mysqlPort, mysqlBinDir)
+	defer stopInstance()
+
+	mysqlDB, err := sql.Open("mysql", fmt.Sprintf("root@tcp(127.0.0.1:%d)/mysql?multiStatements=true", mysqlPort))
+	a.NoError(err)
+	defer mysqlDB.Close()
+	_, err = mysqlDB.Exec(`
+		CREATE DATABASE db;
+		CREATE DATABASE bbdataarchive;
+		CREATE TABLE db.t(id INT PRIMARY KEY, a INT) ENGINE = MyISAM;
+		INSERT INTO db.t VALUES (1, 1), (2, 2);`)
+	a.NoError(err)
+
+	getDatabaseMetadata := func(context.Context, string, string) (string, *model.DatabaseMetadata, error) {
+		return "db", model.NewDatabaseMetadata(&storepb.DatabaseSchemaMetadata{
+			Name: "db",
+			Schemas: []*storepb.SchemaMetadata{
+				{
+					Tables: []*storepb.TableMetadata{
+						{
+							Name:    "t",
+							Columns: []*storepb.ColumnMetadata{{Name: "id"}, {Name: "a"}},
+						},
+					},
+				},
+			},
+		}), nil
+	}
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_MYSQL, base.TransformContext{GetDatabaseMetadataFunc: getDatabaseMetadata}, "UPDATE t SET a = 10 WHERE id = 1;", "db", "bbdataarchive", "_engine")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	_, err = mysqlDB.Exec(backupStatements[0].Statement)
+	a.NoError(err)
+
+	var sourceEngine, backupEngine string
+	a.NoError(mysqlDB.QueryRow("SELECT ENGINE FROM information_schema.TABLES WHERE TABLE_SCHEMA = 'db' AND TABLE_NAME = 't'").Scan(&sourceEngine))
+	a.NoError(mysqlDB.QueryRow("SELECT ENGINE FROM information_schema.TABLES WHERE TABLE_SCHEMA = 'bbdataarchive' AND TABLE_NAME = ?", backupStatements[0].TargetTableName).Scan(&backupEngine))
+	a.Equal("MyISAM", sourceEngine)
+	a.Equal(sourceEngine, backupEngine)
+}
+
+func TestPriorBackupInvisibleColumn(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	mysqlPort := getTestPort()
+	stopInstance := resourcemysql.SetupTestInstance(t, mysqlPort, mysqlBinDir)
+	defer stopInstance()
+
+	mysqlDB, err := sql.Open("mysql", fmt.Sprintf("root@tcp(127.0.0.1:%d)/mysql?multiStatements=true", mysqlPort))
+	a.NoError(err)
+	defer mysqlDB.Close()
+	_, err = mysqlDB.Exec(`
+		CREATE DATABASE db;
+		CREATE DATABASE bbdataarchive;
+		CREATE TABLE db.t(id INT PRIMARY KEY, a INT, b INT INVISIBLE);
+		INSERT INTO db.t(id, a, b) VALUES (1, 1, 10), (2, 2, 20);`)
+	a.NoError(err)
+
+	getDatabaseMetadata := func(context.Context, string, string) (string, *model.DatabaseMetadata, error) {
+		return "db", model.NewDatabaseMetadata(&storepb.DatabaseSchemaMetadata{
+			Name: "db",
+			Schemas: []*storepb.SchemaMetadata{
+				{
+					Tables: []*storepb.TableMetadata{
+						{
+							Name:    "t",
+							Columns: []*storepb.ColumnMetadata{{Name: "id"}, {Name: "a"}, {Name: "b", Hidden: true}},
+							Indexes: []*storepb.IndexMetadata{{Name: "PRIMARY", Expressions: []string{"id"}, Primary: true, Unique: true}},
+						},
+					},
+				},
+			},
+		}), nil
+	}
+	statement := "DELETE FROM t WHERE id = 1;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_MYSQL, base.TransformContext{GetDatabaseMetadataFunc: getDatabaseMetadata}, statement, "db", "bbdataarchive", "_invisible")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	_, err = mysqlDB.Exec(backupStatements[0].Statement)
+	a.NoError(err)
+
+	// The invisible column is backed up.
+	var backupB int
+	a.NoError(mysqlDB.QueryRow(fmt.Sprintf("SELECT b FROM bbdataarchive.`%s` WHERE id = 1", backupStatements[0].TargetTableName)).Scan(&backupB))
+	a.Equal(10, backupB)
+
+	_, err = mysqlDB.Exec("DELETE FROM db.t WHERE id = 1")
+	a.NoError(err)
+	restoreStatement, err := base.GenerateRestoreSQL(ctx, storepb.Engine_MYSQL, base.RestoreContext{GetDatabaseMetadataFunc: getDatabaseMetadata}, statement, "bbdataarchive", backupStatements[0].TargetTableName, "db", "t")
+	a.NoError(err)
+	_, err = mysqlDB.Exec(restoreStatement)
+	a.NoError(err)
+
+	// The row is matched and restored without writing the invisible column.
+	var id, restoredA int
+	var restoredB sql.NullInt64
+	a.NoError(mysqlDB.QueryRow("SELECT id, a, b FROM db.t WHERE id = 1").Scan(&id, &restoredA, &restoredB))
+	a.Equal(1, restoredA)
+	a.False(restoredB.Valid)
+}
+
+func TestPriorBackupPostGISGeometry(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	if _, err := pgDB.Exec("CREATE EXTENSION IF NOT EXISTS postgis"); err != nil {
+		t.Skipf("PostGIS is not available: %v", err)
+	}
+	_, err = pgDB.Exec(`
+		CREATE TABLE t(id INT PRIMARY KEY, g geometry(Point, 4326));
+		INSERT INTO t VALUES (1, ST_GeomFromText('POINT(121.4737 31.2304)', 4326));
+		CREATE SCHEMA bbdataarchive;`)
+	a.NoError(err)
+
+	statement := "UPDATE t SET g = ST_SetSRID(ST_MakePoint(0, 0), 4326) WHERE id = 1;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "postgres", "bbdataarchive", "_geometry")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	_, err = pgDB.Exec(backupStatements[0].Statement)
+	a.NoError(err)
+	_, err = pgDB.Exec(statement)
+	a.NoError(err)
+
+	// The backup column keeps the geometry type and the SRID.
+	var columnType string
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT format_type(atttypid, atttypmod) FROM pg_attribute WHERE attrelid = '"bbdataarchive"."%s"'::regclass AND attname = 'g'`, backupStatements[0].TargetTableName)).Scan(&columnType))
+	a.Equal("geometry(Point,4326)", columnType)
+
+	// Restore the geometry from the backup table and compare the extended WKB exactly.
+	_, err = pgDB.Exec(fmt.Sprintf(`UPDATE t SET g = b.g FROM "bbdataarchive"."%s" AS b WHERE t.id = b.id`, backupStatements[0].TargetTableName))
+	a.NoError(err)
+	var restored string
+	a.NoError(pgDB.QueryRow("SELECT encode(ST_AsEWKB(g), 'hex') FROM t WHERE id = 1").Scan(&restored))
+	var expected string
+	a.NoError(pgDB.QueryRow("SELECT encode(ST_AsEWKB(ST_GeomFromText('POINT(121.4737 31.2304)', 4326)), 'hex')").Scan(&expected))
+	a.Equal(expected, restored)
+}
+
+func TestPriorBackupIdentityColumn(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	_, err = pgDB.Exec(`
+		CREATE TABLE t(id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY, n SERIAL, a INT);
+		INSERT INTO t(a) VALUES (1), (2), (3);
+		CREATE SCHEMA bbdataarchive;`)
+	a.NoError(err)
+
+	statement := "DELETE FROM t WHERE a >= 2;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "postgres", "bbdataarchive", "_identity")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	_, err = pgDB.Exec(backupStatements[0].Statement)
+	a.NoError(err)
+
+	ownedSequences, err := taskrun.GetOwnedSequences(ctx, pgDB, backupStatements[0])
+	a.NoError(err)
+	a.Len(ownedSequences, 2)
+	a.Equal("id", ownedSequences[0].Column)
+	a.Equal("public", ownedSequences[0].Schema)
+	a.Equal("t_id_seq", ownedSequences[0].Sequence)
+	a.Equal("ALWAYS", ownedSequences[0].IdentityGeneration)
+	a.Equal("n", ownedSequences[1].Column)
+	a.Equal("t_n_seq", ownedSequences[1].Sequence)
+	a.Equal("", ownedSequences[1].IdentityGeneration)
+
+	_, err = pgDB.Exec(statement)
+	a.NoError(err)
+	// Reset the sequences to make the next values collide with the restored rows.
+	_, err = pgDB.Exec("ALTER TABLE t ALTER COLUMN id RESTART WITH 2; SELECT setval('t_n_seq', 1);")
+	a.NoError(err)
+
+	// Restore the rows with the identity values of the backup.
+	_, err = pgDB.Exec(fmt.Sprintf(`INSERT INTO t OVERRIDING SYSTEM VALUE SELECT * FROM "bbdataarchive"."%s"`, backupStatements[0].TargetTableName))
+	a.NoError(err)
+	item := &storepb.PriorBackupDetail_Item{
+		SourceTable:    &storepb.PriorBackupDetail_Item_Table{Table: "t"},
+		OwnedSequences: ownedSequences,
+	}
+	restoreStatements, err := taskrun.GetOwnedSequenceRestoreStatements(item)
+	a.NoError(err)
+	for _, restoreStatement := range restoreStatements {
+		_, err = pgDB.Exec(restoreStatement)
+		a.NoError(err)
+	}
+
+	// The sequences are still owned by the columns, and the new rows don't collide with the restored ones.
+	var idSequence, nSequence string
+	a.NoError(pgDB.QueryRow("SELECT pg_get_serial_sequence('t', 'id'), pg_get_serial_sequence('t', 'n')").Scan(&idSequence, &nSequence))
+	a.Equal("public.t_id_seq", idSequence)
+	a.Equal("public.t_n_seq", nSequence)
+	var id, n int
+	a.NoError(pgDB.QueryRow("INSERT INTO t(a) VALUES (4) RETURNING id, n").Scan(&id, &n))
+	a.Equal(4, id)
+	a.Equal(4, n)
+}
+
+func TestPriorBackupSurrogateKey(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	// The table has no primary key and duplicate rows.
+	_, err = pgDB.Exec(`
+		CREATE TABLE t(a INT, b TEXT);
+		INSERT INTO t VALUES (1, 'x'), (1, 'x'), (2, 'y');
+		CREATE SCHEMA bbdataarchive;`)
+	a.NoError(err)
+
+	statement := "UPDATE t SET b = 'z' WHERE a = 1;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "postgres", "bbdataarchive", "_surrogate")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	keyed, key, ok := taskrun.GetSurrogateKeyBackupStatement(storepb.Engine_POSTGRES, backupStatements[0])
+	a.True(ok)
+	a.Equal(storepb.PriorBackupDetail_Item_UUID, key)
+	_, err = pgDB.Exec(keyed)
+	a.NoError(err)
+
+	// Every backup row has its own surrogate key, even the duplicate ones.
+	var count, keys int
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT COUNT(*), COUNT(DISTINCT bb_surrogate_key) FROM "bbdataarchive"."%s"`, backupStatements[0].TargetTableName)).Scan(&count, &keys))
+	a.Equal(2, count)
+	a.Equal(2, keys)
+
+	// The surrogate key matches exactly one backup row.
+	var surrogateKey string
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT bb_surrogate_key FROM "bbdataarchive"."%s" LIMIT 1`, backupStatements[0].TargetTableName)).Scan(&surrogateKey))
+	var matched int
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "bbdataarchive"."%s" WHERE bb_surrogate_key = $1`, backupStatements[0].TargetTableName), surrogateKey).Scan(&matched))
+	a.Equal(1, matched)
+}
+
+func TestPriorBackupMySQLGeometry(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	mysqlPort := getTestPort()
+	stopInstance := resourcemysql.SetupTestInstance(t, mysqlPort, mysqlBinDir)
+	defer stopInstance()
+
+	mysqlDB, err := sql.Open("mysql", fmt.Sprintf("root@tcp(127.0.0.1:%d)/mysql?multiStatements=true", mysqlPort))
+	a.NoError(err)
+	defer mysqlDB.Close()
+	_, err = mysqlDB.Exec(`
+		CREATE DATABASE db;
+		CREATE DATABASE bbdataarchive;
+		CREATE TABLE db.t(id INT PRIMARY KEY, g POINT NOT NULL SRID 4326);
+		INSERT INTO db.t VALUES (1, ST_GeomFromText('POINT(31.2304 121.4737)', 4326));`)
+	a.NoError(err)
+
+	getDatabaseMetadata := func(context.Context, string, string) (string, *model.DatabaseMetadata, error) {
+		return "db", model.NewDatabaseMetadata(&storepb.DatabaseSchemaMetadata{
+			Name: "db",
+			Schemas: []*storepb.SchemaMetadata{
+				{
+					Tables: []*storepb.TableMetadata{
+						{
+							Name:    "t",
+							Columns: []*storepb.ColumnMetadata{{Name: "id"}, {Name: "g"}},
+							Indexes: []*storepb.IndexMetadata{{Name: "PRIMARY", Expressions: []string{"id"}, Primary: true, Unique: true}},
+						},
+					},
+				},
+			},
+		}), nil
+	}
+	statement := "DELETE FROM t WHERE id = 1;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_MYSQL, base.TransformContext{GetDatabaseMetadataFunc: getDatabaseMetadata}, statement, "db", "bbdataarchive", "_geometry")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	_, err = mysqlDB.Exec(backupStatements[0].Statement)
+	a.NoError(err)
+
+	// The backup column keeps the SRID attribute.
+	var srid int
+	a.NoError(mysqlDB.QueryRow("SELECT SRS_ID FROM information_schema.ST_GEOMETRY_COLUMNS WHERE TABLE_SCHEMA = 'bbdataarchive' AND TABLE_NAME = ? AND COLUMN_NAME = 'g'", backupStatements[0].TargetTableName).Scan(&srid))
+	a.Equal(4326, srid)
+
+	_, err = mysqlDB.Exec("DELETE FROM db.t WHERE id = 1")
+	a.NoError(err)
+	restoreStatement, err := base.GenerateRestoreSQL(ctx, storepb.Engine_MYSQL, base.RestoreContext{GetDatabaseMetadataFunc: getDatabaseMetadata}, statement, "bbdataarchive", backupStatements[0].TargetTableName, "db", "t")
+	a.NoError(err)
+	_, err = mysqlDB.Exec(restoreStatement)
+	a.NoError(err)
+
+	// The restored geometry is identical to the original one, including the SRID.
+	var equal bool
+	a.NoError(mysqlDB.QueryRow("SELECT ST_AsBinary(g) = ST_AsBinary(ST_GeomFromText('POINT(31.2304 121.4737)', 4326)) AND ST_SRID(g) = 4326 FROM db.t WHERE id = 1").Scan(&equal))
+	a.True(equal)
+}
+
+func TestPriorBackupSessionSettings(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	mysqlPort := getTestPort()
+	stopInstance := resourcemysql.SetupTestInstance(t, mysqlPort, mysqlBinDir)
+	defer stopInstance()
+
+	// The sessions of the task database run in a non-UTC time zone.
+	mysqlDB, err := sql.Open("mysql", fmt.Sprintf("root@tcp(127.0.0.1:%d)/mysql?multiStatements=true&time_zone=%%27%%2B08%%3A00%%27", mysqlPort))
+	a.NoError(err)
+	defer mysqlDB.Close()
+	_, err = mysqlDB.Exec(`
+		CREATE DATABASE db;
+		CREATE DATABASE bbdataarchive;
+		CREATE TABLE db.t(id INT PRIMARY KEY, ts TIMESTAMP NULL);
+		INSERT INTO db.t VALUES (1, '2024-01-01 08:00:00');`)
+	a.NoError(err)
+
+	settings, err := taskrun.GetBackupSessionSettings(ctx, mysqlDB, storepb.Engine_MYSQL)
+	a.NoError(err)
+	a.Equal("+08:00", settings["time_zone"])
+	a.Contains(settings, "sql_mode")
+
+	getDatabaseMetadata := func(context.Context, string, string) (string, *model.DatabaseMetadata, error) {
+		return "db", model.NewDatabaseMetadata(&storepb.DatabaseSchemaMetadata{
+			Name: "db",
+			Schemas: []*storepb.SchemaMetadata{
+				{
+					Tables: []*storepb.TableMetadata{
+						{
+							Name:    "t",
+							Columns: []*storepb.ColumnMetadata{{Name: "id"}, {Name: "ts"}},
+							Indexes: []*storepb.IndexMetadata{{Name: "PRIMARY", Expressions: []string{"id"}, Primary: true, Unique: true}},
+						},
+					},
+				},
+			},
+		}), nil
+	}
+	statement := "UPDATE t SET ts = '2024-06-01 00:00:00' WHERE id = 1;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_MYSQL, base.TransformContext{GetDatabaseMetadataFunc: getDatabaseMetadata}, statement, "db", "bbdataarchive", "_session")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	_, err = mysqlDB.Exec(backupStatements[0].Statement)
+	a.NoError(err)
+	_, err = mysqlDB.Exec("UPDATE db.t SET ts = '2024-06-01 00:00:00' WHERE id = 1")
+	a.NoError(err)
+
+	// The restore runs in a UTC session, which applies the session settings at backup time first.
+	utcDB, err := sql.Open("mysql", fmt.Sprintf("root@tcp(127.0.0.1:%d)/db?multiStatements=true&time_zone=%%27%%2B00%%3A00%%27", mysqlPort))
+	a.NoError(err)
+	defer utcDB.Close()
+	conn, err := utcDB.Conn(ctx)
+	a.NoError(err)
+	defer conn.Close()
+	restoreStatement, err := base.GenerateRestoreSQL(ctx, storepb.Engine_MYSQL, base.RestoreContext{GetDatabaseMetadataFunc: getDatabaseMetadata}, statement, "bbdataarchive", backupStatements[0].TargetTableName, "db", "t")
+	a.NoError(err)
+	_, err = conn.ExecContext(ctx, taskrun.GetSessionSettingStatement(storepb.Engine_MYSQL, settings)+"\n"+restoreStatement)
+	a.NoError(err)
+
+	// The restored timestamp reads the same as the original one in both the restore session and the sessions of the task database.
+	var timeZone, restored string
+	a.NoError(conn.QueryRowContext(ctx, "SELECT @@SESSION.time_zone, DATE_FORMAT(ts, '%Y-%m-%d %H:%i:%s') FROM db.t WHERE id = 1").Scan(&timeZone, &restored))
+	a.Equal("+08:00", timeZone)
+	a.Equal("2024-01-01 08:00:00", restored)
+	a.NoError(mysqlDB.QueryRow("SELECT DATE_FORMAT(ts, '%Y-%m-%d %H:%i:%s') FROM db.t WHERE id = 1").Scan(&restored))
+	a.Equal("2024-01-01 08:00:00", restored)
+}
+
+func TestPriorBackupOrderByLimit(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	mysqlPort := getTestPort()
+	stopInstance := resourcemysql.SetupTestInstance(t, mysqlPort, mysqlBinDir)
+	defer stopInstance()
+
+	adminDB, err := sql.Open("mysql", fmt.Sprintf("root@tcp(127.0.0.1:%d)/mysql?multiStatements=true", mysqlPort))
+	a.NoError(err)
+	_, err = adminDB.Exec("CREATE DATABASE db; CREATE DATABASE bbdataarchive; CREATE TABLE db.t(id INT PRIMARY KEY, a INT);")
+	a.NoError(err)
+	a.NoError(adminDB.Close())
+	// The DML and the backup statements reference the tables of the task database without the database name.
+	mysqlDB, err := sql.Open("mysql", fmt.Sprintf("root@tcp(127.0.0.1:%d)/db?multiStatements=true", mysqlPort))
+	a.NoError(err)
+	defer mysqlDB.Close()
+	// The rows are inserted out of the order of the primary key.
+	for _, id := range []int{15, 3, 20, 8, 1, 12, 6, 18, 10, 4, 17, 2, 9, 14, 7, 19, 5, 11, 16, 13} {
+		_, err = mysqlDB.Exec("INSERT INTO db.t VALUES (?, ?)", id, id%3)
+		a.NoError(err)
+	}
+
+	statement := "DELETE FROM t ORDER BY id LIMIT 10;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_MYSQL, base.TransformContext{}, statement, "db", "bbdataarchive", "_limit")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	a.Zero(backupStatements[0].Uncertainty)
+	_, err = mysqlDB.Exec(backupStatements[0].Statement)
+	a.NoError(err)
+	_, err = mysqlDB.Exec(statement)
+	a.NoError(err)
+
+	// Exactly the deleted rows are backed up.
+	getIDs := func(table string) []int {
+		rows, err := mysqlDB.Query(fmt.Sprintf("SELECT id FROM %s ORDER BY id", table))
+		a.NoError(err)
+		defer rows.Close()
+		var ids []int
+		for rows.Next() {
+			var id int
+			a.NoError(rows.Scan(&id))
+			ids = append(ids, id)
+		}
+		a.NoError(rows.Err())
+		return ids
+	}
+	a.Equal([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, getIDs(fmt.Sprintf("bbdataarchive.%s", backupStatements[0].TargetTableName)))
+	a.Equal([]int{11, 12, 13, 14, 15, 16, 17, 18, 19, 20}, getIDs("db.t"))
+}
+
+func TestPriorBackupConsistentSnapshot(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	// The balances of the accounts always sum up to 100.
+	_, err = pgDB.Exec(`
+		CREATE TABLE a(id INT PRIMARY KEY, balance INT);
+		CREATE TABLE b(id INT PRIMARY KEY, balance INT);
+		INSERT INTO a VALUES (1, 50);
+		INSERT INTO b VALUES (1, 50);
+		CREATE SCHEMA bbdataarchive;`)
+	a.NoError(err)
+
+	driver, err := db.Open(ctx, storepb.Engine_POSTGRES, db.DriverConfig{}, db.ConnectionConfig{
+		Username:             postgres.TestPgUser,
+		Host:                 common.GetPostgresSocketDir(),
+		Port:                 strconv.Itoa(pgPort),
+		Database:             "postgres",
+		MaximumSQLResultSize: common.DefaultMaximumSQLResultSize,
+	})
+	a.NoError(err)
+	defer driver.Close(ctx)
+
+	statement := "UPDATE a SET balance = 0 WHERE id = 1; UPDATE b SET balance = 0 WHERE id = 1;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "postgres", "bbdataarchive", "_snapshot")
+	a.NoError(err)
+	a.Len(backupStatements, 2)
+
+	snapshot, err := taskrun.BeginBackupSnapshot(ctx, driver, storepb.Engine_POSTGRES)
+	a.NoError(err)
+	defer snapshot.Rollback()
+	_, err = snapshot.Execute(ctx, backupStatements[0].Statement, db.ExecuteOptions{})
+	a.NoError(err)
+	// A concurrent transfer between the accounts commits between the backup statements.
+	_, err = pgDB.Exec("BEGIN; UPDATE a SET balance = balance - 10; UPDATE b SET balance = balance + 10; COMMIT;")
+	a.NoError(err)
+	_, err = snapshot.Execute(ctx, backupStatements[1].Statement, db.ExecuteOptions{})
+	a.NoError(err)
+	a.NoError(snapshot.Commit())
+
+	// Both backup tables reflect the snapshot before the transfer.
+	var balanceA, balanceB int
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT balance FROM "bbdataarchive"."%s"`, backupStatements[0].TargetTableName)).Scan(&balanceA))
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT balance FROM "bbdataarchive"."%s"`, backupStatements[1].TargetTableName)).Scan(&balanceB))
+	a.Equal(50, balanceA)
+	a.Equal(50, balanceB)
+}
+
+func TestPriorBackupExportedSnapshot(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	// The balances of the accounts always sum up to 100.
+	_, err = pgDB.Exec(`
+		CREATE TABLE a(id INT PRIMARY KEY, balance INT);
+		CREATE TABLE b(id INT PRIMARY KEY, balance INT);
+		INSERT INTO a VALUES (1, 50);
+		INSERT INTO b VALUES (1, 50);
+		CREATE SCHEMA bbdataarchive;`)
+	a.NoError(err)
+
+	driver, err := db.Open(ctx, storepb.Engine_POSTGRES, db.DriverConfig{}, db.ConnectionConfig{
+		Username:             postgres.TestPgUser,
+		Host:                 common.GetPostgresSocketDir(),
+		Port:                 strconv.Itoa(pgPort),
+		Database:             "postgres",
+		MaximumSQLResultSize: common.DefaultMaximumSQLResultSize,
+	})
+	a.NoError(err)
+	defer driver.Close(ctx)
+
+	statement := "UPDATE a SET balance = 0 WHERE id = 1; UPDATE b SET balance = 0 WHERE id = 1;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "postgres", "bbdataarchive", "_exported")
+	a.NoError(err)
+	a.Len(backupStatements, 2)
+
+	snapshot, err := taskrun.ExportBackupSnapshot(ctx, driver, storepb.Engine_POSTGRES)
+	a.NoError(err)
+	defer snapshot.Release()
+	a.NotEmpty(snapshot.ID)
+	_, err = snapshot.Execute(ctx, backupStatements[0].Statement, db.ExecuteOptions{})
+	a.NoError(err)
+	// The lock on the source table is released after the backup statement, so that the DDL is not blocked by the backup.
+	_, err = pgDB.Exec("BEGIN; SET LOCAL lock_timeout = '1s'; LOCK TABLE a IN ACCESS EXCLUSIVE MODE; COMMIT;")
+	a.NoError(err)
+	// A concurrent transfer between the accounts commits between the backup statements.
+	_, err = pgDB.Exec("BEGIN; UPDATE a SET balance = balance - 10; UPDATE b SET balance = balance + 10; COMMIT;")
+	a.NoError(err)
+	_, err = snapshot.Execute(ctx, backupStatements[1].Statement, db.ExecuteOptions{})
+	a.NoError(err)
+	snapshot.Release()
+
+	// Both backup tables reflect the exported snapshot before the transfer.
+	var balanceA, balanceB int
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT balance FROM "bbdataarchive"."%s"`, backupStatements[0].TargetTableName)).Scan(&balanceA))
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT balance FROM "bbdataarchive"."%s"`, backupStatements[1].TargetTableName)).Scan(&balanceB))
+	a.Equal(50, balanceA)
+	a.Equal(50, balanceB)
+
+	// The snapshot cannot be used after it's released.
+	_, err = snapshot.Execute(ctx, backupStatements[0].Statement, db.ExecuteOptions{})
+	a.Error(err)
+}
+
+func TestPriorBackupSessionRole(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	_, err = pgDB.Exec(`
+		CREATE TABLE t(id INT PRIMARY KEY, a INT);
+		INSERT INTO t VALUES (1, 1);
+		CREATE SCHEMA bbdataarchive;
+		CREATE ROLE backup_role;
+		GRANT SELECT ON t TO backup_role;
+		GRANT USAGE, CREATE ON SCHEMA bbdataarchive TO backup_role;`)
+	a.NoError(err)
+
+	driver, err := db.Open(ctx, storepb.Engine_POSTGRES, db.DriverConfig{}, db.ConnectionConfig{
+		Username:             postgres.TestPgUser,
+		Host:                 common.GetPostgresSocketDir(),
+		Port:                 strconv.Itoa(pgPort),
+		Database:             "postgres",
+		MaximumSQLResultSize: common.DefaultMaximumSQLResultSize,
+	})
+	a.NoError(err)
+	defer driver.Close(ctx)
+
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, "UPDATE t SET a = 2 WHERE id = 1;", "postgres", "bbdataarchive", "_role")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	_, err = driver.Execute(ctx, backupStatements[0].Statement, db.ExecuteOptions{PreludeStatements: []string{`SET LOCAL ROLE "backup_role"`}})
+	a.NoError(err)
+
+	// The backup table is created under the role, and the role is reset after the backup statement.
+	var owner string
+	a.NoError(pgDB.QueryRow(`SELECT tableowner FROM pg_tables WHERE schemaname = 'bbdataarchive' AND tablename = $1`, backupStatements[0].TargetTableName).Scan(&owner))
+	a.Equal("backup_role", owner)
+	_, err = driver.Execute(ctx, `CREATE TABLE "bbdataarchive"."after_backup"(id INT);`, db.ExecuteOptions{})
+	a.NoError(err)
+	a.NoError(pgDB.QueryRow(`SELECT tableowner FROM pg_tables WHERE schemaname = 'bbdataarchive' AND tablename = 'after_backup'`).Scan(&owner))
+	a.NotEqual("backup_role", owner)
+}
+
+func TestPriorBackupPartition(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	_, err = pgDB.Exec(`
+		CREATE TABLE t(id INT PRIMARY KEY, b TEXT);
+		INSERT INTO t SELECT i, 'x' FROM generate_series(1, 1000) AS i;
+		CREATE SCHEMA bbdataarchive;`)
+	a.NoError(err)
+
+	statement := "UPDATE t SET b = 'y' WHERE id > 100;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "postgres", "bbdataarchive", "_partition")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+
+	// The 900 affected rows split into 4 parts of at most 250 rows.
+	bounds, err := taskrun.GetBackupPartitionBounds(ctx, pgDB, storepb.Engine_POSTGRES, "bbdataarchive", backupStatements[0], "id", 250)
+	a.NoError(err)
+	a.Len(bounds, 3)
+	parts, ranges, err := taskrun.GetPartitionedBackupStatements(storepb.Engine_POSTGRES, "bbdataarchive", backupStatements[0], "id", false, bounds)
+	a.NoError(err)
+	a.Len(parts, 4)
+	a.Len(ranges, 4)
+
+	total := 0
+	for _, part := range parts {
+		_, err = pgDB.Exec(part.Statement)
+		a.NoError(err)
+		var count int
+		a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "bbdataarchive"."%s"`, part.TargetTableName)).Scan(&count))
+		a.InDelta(225, count, 1)
+		total += count
+	}
+	a.Equal(900, total)
+
+	// The parts cover all the affected rows without overlap.
+	var union []string
+	for _, part := range parts {
+		union = append(union, fmt.Sprintf(`SELECT id FROM "bbdataarchive"."%s"`, part.TargetTableName))
+	}
+	var distinct, minimum, maximum int
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT COUNT(DISTINCT id), MIN(id), MAX(id) FROM (%s) AS parts`, strings.Join(union, " UNION ALL "))).Scan(&distinct, &minimum, &maximum))
+	a.Equal(900, distinct)
+	a.Equal(101, minimum)
+	a.Equal(1000, maximum)
+
+	// The affected rows fitting in one part are not split.
+	bounds, err = taskrun.GetBackupPartitionBounds(ctx, pgDB, storepb.Engine_POSTGRES, "bbdataarchive", backupStatements[0], "id", 1000)
+	a.NoError(err)
+	a.Empty(bounds)
+}
+
+func TestPriorBackupAnalyzeTable(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	_, err = pgDB.Exec(`
+		CREATE TABLE t(id INT PRIMARY KEY, b TEXT);
+		INSERT INTO t SELECT i, 'x' FROM generate_series(1, 100) AS i;
+		CREATE SCHEMA bbdataarchive;`)
+	a.NoError(err)
+
+	statement := "UPDATE t SET b = 'y' WHERE id > 10;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "postgres", "bbdataarchive", "_analyze")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	_, err = pgDB.Exec(backupStatements[0].Statement)
+	a.NoError(err)
+
+	// The column statistics of the backup table are only collected by ANALYZE.
+	countStats := func() int {
+		var count int
+		a.NoError(pgDB.QueryRow(`SELECT COUNT(*) FROM pg_stats WHERE schemaname = 'bbdataarchive' AND tablename = $1`, backupStatements[0].TargetTableName).Scan(&count))
+		return count
+	}
+	a.Equal(0, countStats())
+	analyzeStatement, err := taskrun.GetBackupAnalyzeStatement(storepb.Engine_POSTGRES, "bbdataarchive", "", backupStatements[0].TargetTableName)
+	a.NoError(err)
+	_, err = pgDB.Exec(analyzeStatement)
+	a.NoError(err)
+	a.Equal(2, countStats())
+	var tuples float64
+	a.NoError(pgDB.QueryRow(`SELECT reltuples FROM pg_class WHERE oid = $1::regclass`, fmt.Sprintf(`"bbdataarchive"."%s"`, backupStatements[0].TargetTableName)).Scan(&tuples))
+	a.Equal(float64(90), tuples)
+}
+
+func TestPriorBackupAfterImage(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	_, err = pgDB.Exec(`
+		CREATE TABLE t(id INT PRIMARY KEY, a INT, b TEXT);
+		INSERT INTO t SELECT i, i, 'x' || i FROM generate_series(1, 10) AS i;
+		CREATE SCHEMA bbdataarchive;`)
+	a.NoError(err)
+
+	statement := "UPDATE t AS x SET a = a * 10, b = upper(x.b) || (SELECT MAX(a) FROM t) WHERE id > 7;"
+	backupStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{AfterImage: true}, statement, "postgres", "bbdataarchive", "_after")
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	_, err = pgDB.Exec(backupStatements[0].Statement)
+	a.NoError(err)
+	afterImageTable, afterImageStatement, err := taskrun.GetAfterImageStatement(storepb.Engine_POSTGRES, "bbdataarchive", backupStatements[0])
+	a.NoError(err)
+	a.NotEmpty(afterImageStatement)
+	_, err = pgDB.Exec(afterImageStatement)
+	a.NoError(err)
+
+	// The after-image table has the new values of the backed up rows computed by the SET expressions before the data update.
+	rows, err := pgDB.Query(fmt.Sprintf(`SELECT id, a, b FROM "bbdataarchive"."%s" ORDER BY id`, afterImageTable))
+	a.NoError(err)
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var id, value int
+		var text string
+		a.NoError(rows.Scan(&id, &value, &text))
+		got = append(got, fmt.Sprintf("%d,%d,%s", id, value, text))
+	}
+	a.NoError(rows.Err())
+	a.Equal([]string{"8,80,X810", "9,90,X910", "10,100,X1010"}, got)
+
+	// The after-image matches the data update, and the backup table keeps the old values.
+	_, err = pgDB.Exec(statement)
+	a.NoError(err)
+	var diff int
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM (SELECT id, a, b FROM t WHERE id > 7 EXCEPT SELECT id, a, b FROM "bbdataarchive"."%s") AS diff`, afterImageTable)).Scan(&diff))
+	a.Equal(0, diff)
+	var old int
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT SUM(a) FROM "bbdataarchive"."%s"`, backupStatements[0].TargetTableName)).Scan(&old))
+	a.Equal(27, old)
+}
+
+func TestPriorBackupProcedure(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	_, err = pgDB.Exec(`
+		CREATE TABLE t(id INT PRIMARY KEY, a INT);
+		INSERT INTO t SELECT i, i FROM generate_series(1, 10) AS i;
+		CREATE PROCEDURE bump(threshold INT) LANGUAGE SQL AS $$ UPDATE t SET a = a * 10 WHERE id > threshold $$;
+		CREATE SCHEMA bbdataarchive;`)
+	a.NoError(err)
+
+	// The procedure calls cannot be backed up without the declared tables.
+	statement := "CALL bump(5);"
+	_, err = taskrun.GetProcedureBackupStatements(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "postgres", "bbdataarchive", "_proc", nil)
+	a.Error(err)
+
+	backupStatements, err := taskrun.GetProcedureBackupStatements(ctx, storepb.Engine_POSTGRES, base.TransformContext{}, statement, "postgres", "bbdataarchive", "_proc", []string{"public.t"})
+	a.NoError(err)
+	a.Len(backupStatements, 1)
+	_, err = pgDB.Exec(backupStatements[0].Statement)
+	a.NoError(err)
+	_, err = pgDB.Exec(statement)
+	a.NoError(err)
+
+	// The backup table keeps the rows of the declared table before the procedure updates them.
+	var updated, old int
+	a.NoError(pgDB.QueryRow(`SELECT SUM(a) FROM t`).Scan(&updated))
+	a.Equal(15+400, updated)
+	a.NoError(pgDB.QueryRow(fmt.Sprintf(`SELECT SUM(a) FROM "bbdataarchive"."%s"`, backupStatements[0].TargetTableName)).Scan(&old))
+	a.Equal(55, old)
+}
+
+func TestPriorBackupOnlineDDL(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	mysqlPort := getTestPort()
+	stopInstance := resourcemysql.SetupTestInstance(t, mysqlPort, mysqlBinDir)
+	defer stopInstance()
+
+	mysqlDB, err := sql.Open("mysql", fmt.Sprintf("root@tcp(127.0.0.1:%d)/mysql?multiStatements=true", mysqlPort))
+	a.NoError(err)
+	defer mysqlDB.Close()
+	_, err = mysqlDB.Exec(`
+		CREATE DATABASE bbdataarchive;
+		CREATE TABLE bbdataarchive._0_0_t(id INT PRIMARY KEY, a INT);`)
+	a.NoError(err)
+
+	tool, err := taskrun.GetOnlineDDLInProgress(ctx, mysqlDB, storepb.Engine_MYSQL, "bbdataarchive", "_0_0_t")
+	a.NoError(err)
+	a.Empty(tool)
+
+	// gh-ost copies the rows into its ghost table and tracks its progress in the changelog table.
+	_, err = mysqlDB.Exec(`
+		CREATE TABLE bbdataarchive.__0_0_t_gho LIKE bbdataarchive._0_0_t;
+		CREATE TABLE bbdataarchive.__0_0_t_ghc(id BIGINT PRIMARY KEY, hint VARCHAR(64), value VARCHAR(4096));`)
+	a.NoError(err)
+	tool, err = taskrun.GetOnlineDDLInProgress(ctx, mysqlDB, storepb.Engine_MYSQL, "bbdataarchive", "_0_0_t")
+	a.NoError(err)
+	a.Equal("gh-ost", tool)
+	_, err = mysqlDB.Exec(`DROP TABLE bbdataarchive.__0_0_t_gho, bbdataarchive.__0_0_t_ghc;`)
+	a.NoError(err)
+
+	// pt-online-schema-change syncs the changes into its new table by the triggers on the original table.
+	_, err = mysqlDB.Exec(`CREATE TRIGGER bbdataarchive.pt_osc_bbdataarchive__0_0_t_ins AFTER INSERT ON bbdataarchive._0_0_t FOR EACH ROW SET @pt_osc = NEW.id;`)
+	a.NoError(err)
+	tool, err = taskrun.GetOnlineDDLInProgress(ctx, mysqlDB, storepb.Engine_MYSQL, "bbdataarchive", "_0_0_t")
+	a.NoError(err)
+	a.Equal("pt-online-schema-change", tool)
+	_, err = mysqlDB.Exec(`DROP TRIGGER bbdataarchive.pt_osc_bbdataarchive__0_0_t_ins;`)
+	a.NoError(err)
+	tool, err = taskrun.GetOnlineDDLInProgress(ctx, mysqlDB, storepb.Engine_MYSQL, "bbdataarchive", "_0_0_t")
+	a.NoError(err)
+	a.Empty(tool)
+}
+
+func TestPriorBackupCoverage(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+	_, err = pgDB.Exec(`
+		CREATE TABLE t(id INT PRIMARY KEY, a INT);
+		INSERT INTO t SELECT i, i FROM generate_series(1, 10) AS i;
+		CREATE SCHEMA bbdataarchive;`)
+	a.NoError(err)
+
+	// The backup predicate deliberately diverges from the DML, missing the row 8.
+	_, err = pgDB.Exec(`CREATE TABLE bbdataarchive._narrow_0_t AS SELECT * FROM t WHERE id > 8;`)
+	a.NoError(err)
+	statement := "UPDATE t SET a = a * 10 WHERE id > 7;"
+	_, err = pgDB.Exec(statement)
+	a.NoError(err)
+
+	coverageStatements, err := base.TransformDMLToSelect(ctx, storepb.Engine_POSTGRES, base.TransformContext{AfterImage: true}, statement, "postgres", "bbdataarchive", "_bbcoverage")
+	a.NoError(err)
+	a.Len(coverageStatements, 1)
+	query, ok := taskrun.GetBackupCoverageQuery(storepb.Engine_POSTGRES, coverageStatements[0], "bbdataarchive", []string{"_narrow_0_t"}, []string{"id"})
+	a.True(ok)
+	rows, err := pgDB.Query(query)
+	a.NoError(err)
+	defer rows.Close()
+	var uncovered []string
+	for rows.Next() {
+		var key string
+		a.NoError(rows.Scan(&key))
+		uncovered = append(uncovered, key)
+	}
+	a.NoError(rows.Err())
+	a.Equal([]string{"(8)"}, uncovered)
+
+	// The complete backup covers all the modified rows.
+	_, err = pgDB.Exec(`CREATE TABLE bbdataarchive._full_0_t AS SELECT * FROM t WHERE id > 7;`)
+	a.NoError(err)
+	query, ok = taskrun.GetBackupCoverageQuery(storepb.Engine_POSTGRES, coverageStatements[0], "bbdataarchive", []string{"_full_0_t"}, []string{"id"})
+	a.True(ok)
+	var count int
+	a.NoError(pgDB.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS uncovered", query)).Scan(&count))
+	a.Zero(count)
+}
+
+func TestPriorBackupLogPosition(t *testing.T) {
+	t.Parallel()
+	a := require.New(t)
+	ctx := context.Background()
+
+	pgPort := getTestPort()
+	stopInstance := postgres.SetupTestInstance(pgBinDir, t.TempDir(), pgPort)
+	defer stopInstance()
+
+	pgDB, err := sql.Open("pgx", fmt.Sprintf("host=/tmp port=%d user=root database=postgres", pgPort))
+	a.NoError(err)
+	defer pgDB.Close()
+
+	lsnRegexp := regexp.MustCompile(`^[0-9A-F]{1,8}/[0-9A-F]{1,8}$`)
+	before, err := taskrun.GetBackupLogPosition(ctx, pgDB, storepb.Engine_POSTGRES)
+	a.NoError(err)
+	a.Regexp(lsnRegexp, before)
+
+	// The LSN moves forward with the writes.
+	_, err = pgDB.Exec(`CREATE TABLE t(id INT PRIMARY KEY); INSERT INTO t SELECT generate_series(1, 100);`)
+	a.NoError(err)
+	after, err := taskrun.GetBackupLogPosition(ctx, pgDB, storepb.Engine_POSTGRES)
+	a.NoError(err)
+	a.Regexp(lsnRegexp, after)
+	var advanced bool
+	a.NoError(pgDB.QueryRow(`SELECT $1::pg_lsn > $2::pg_lsn`, after, before).Scan(&advanced))
+	a.True(advanced)
+}
@@ -10,6 +10,7 @@ import (
 	"log/slog"
 	"reflect"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -47,6 +48,24 @@ func DataSourceFromInstanceWithType(instance *store.InstanceMessage, dataSourceT
 	return nil
 }
 
+// DataSourceFromInstanceWithNodeTag gets the data source connecting to a node tagged with the role from an instance,
+// preferring the admin data source among the tagged ones.
+func DataSourceFromInstanceWithNodeTag(instance *store.InstanceMessage, tag string) *store.DataSourceMessage {
+	var result *store.DataSourceMessage
+	for _, dataSource := range instance.DataSources {
+		if !slices.Contains(dataSource.NodeTags, tag) {
+			continue
+		}
+		if dataSource.Type == api.Admin {
+			return dataSource
+		}
+		if result == nil {
+			result = dataSource
+		}
+	}
+	return result
+}
+
 // isMatchExpression checks whether a databases matches the query.
 // labels is a mapping from database label key to value.
 func isMatchExpression(labels map[string]string, expression *store.LabelSelectorRequirement) bool {
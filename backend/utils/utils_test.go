@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	api "github.com/bytebase/bytebase/backend/legacyapi"
 	"github.com/bytebase/bytebase/backend/store"
 	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
 )
@@ -512,3 +513,19 @@ func TestCheckDatabaseGroupMatch(t *testing.T) {
 		assert.Equal(t, test.match, match)
 	}
 }
+
+func TestDataSourceFromInstanceWithNodeTag(t *testing.T) {
+	a := require.New(t)
+	instance := &store.InstanceMessage{
+		DataSources: []*store.DataSourceMessage{
+			{ID: "admin", Type: api.Admin, Host: "primary"},
+			{ID: "analytics", Type: api.RO, Host: "replica-1", NodeTags: []string{"analytics"}},
+			{ID: "backup-ro", Type: api.RO, Host: "replica-2", NodeTags: []string{"analytics", "backup"}},
+			{ID: "backup", Type: api.Admin, Host: "replica-3", NodeTags: []string{"backup"}},
+		},
+	}
+	// The admin data source is preferred among the tagged ones.
+	a.Equal("replica-3", DataSourceFromInstanceWithNodeTag(instance, "backup").Host)
+	a.Equal("replica-1", DataSourceFromInstanceWithNodeTag(instance, "analytics").Host)
+	a.Nil(DataSourceFromInstanceWithNodeTag(instance, "reporting"))
+}
@@ -33,6 +33,8 @@ export type FeatureType =
   | "bb.feature.encrypted-secrets"
   | "bb.feature.database-grouping"
   | "bb.feature.schema-template"
+  | "bb.feature.encrypted-backup"
+  | "bb.feature.backup-sink"
   // Database management
   | "bb.feature.read-replica-connection"
   | "bb.feature.custom-instance-scan-interval"
@@ -62,6 +64,8 @@ export const instanceLimitFeature = new Set<FeatureType>([
   "bb.feature.encrypted-secrets",
   "bb.feature.task-schedule-time",
   "bb.feature.online-migration",
+  "bb.feature.encrypted-backup",
+  "bb.feature.backup-sink",
   // Database Management
   "bb.feature.read-replica-connection",
   "bb.feature.instance-ssh-connection",
@@ -301,6 +301,62 @@ func (ExportFormat) EnumDescriptor() ([]byte, []int) {
 	return file_store_common_proto_rawDescGZIP(), []int{3}
 }
 
+// PriorBackupPostSuccessPolicy is what happens to the prior backup tables after the data update succeeds.
+type PriorBackupPostSuccessPolicy int32
+
+const (
+	PriorBackupPostSuccessPolicy_POST_SUCCESS_POLICY_UNSPECIFIED PriorBackupPostSuccessPolicy = 0
+	// The backup tables are kept for audits until their retentions expire.
+	PriorBackupPostSuccessPolicy_KEEP PriorBackupPostSuccessPolicy = 1
+	// The backup tables are dropped by the retention job after a delay, even if their retentions keep them.
+	PriorBackupPostSuccessPolicy_DROP_AFTER PriorBackupPostSuccessPolicy = 2
+	// The backup tables are dropped right after the data update succeeds to save space.
+	PriorBackupPostSuccessPolicy_DROP_IMMEDIATELY PriorBackupPostSuccessPolicy = 3
+)
+
+// Enum value maps for PriorBackupPostSuccessPolicy.
+var (
+	PriorBackupPostSuccessPolicy_name = map[int32]string{
+		0: "POST_SUCCESS_POLICY_UNSPECIFIED",
+		1: "KEEP",
+		2: "DROP_AFTER",
+		3: "DROP_IMMEDIATELY",
+	}
+	PriorBackupPostSuccessPolicy_value = map[string]int32{
+		"POST_SUCCESS_POLICY_UNSPECIFIED": 0,
+		"KEEP":                            1,
+		"DROP_AFTER":                      2,
+		"DROP_IMMEDIATELY":                3,
+	}
+)
+
+func (x PriorBackupPostSuccessPolicy) Enum() *PriorBackupPostSuccessPolicy {
+	p := new(PriorBackupPostSuccessPolicy)
+	*p = x
+	return p
+}
+
+func (x PriorBackupPostSuccessPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PriorBackupPostSuccessPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_store_common_proto_enumTypes[4].Descriptor()
+}
+
+func (PriorBackupPostSuccessPolicy) Type() protoreflect.EnumType {
+	return &file_store_common_proto_enumTypes[4]
+}
+
+func (x PriorBackupPostSuccessPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PriorBackupPostSuccessPolicy.Descriptor instead.
+func (PriorBackupPostSuccessPolicy) EnumDescriptor() ([]byte, []int) {
+	return file_store_common_proto_rawDescGZIP(), []int{4}
+}
+
 // Used internally for obfuscating the page token.
 type PageToken struct {
 	state         protoimpl.MessageState
@@ -579,9 +635,16 @@ var file_store_common_proto_rawDesc = []byte{
 	0x54, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12,
 	0x07, 0x0a, 0x03, 0x43, 0x53, 0x56, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x4a, 0x53, 0x4f, 0x4e,
 	0x10, 0x02, 0x12, 0x07, 0x0a, 0x03, 0x53, 0x51, 0x4c, 0x10, 0x03, 0x12, 0x08, 0x0a, 0x04, 0x58,
-	0x4c, 0x53, 0x58, 0x10, 0x04, 0x42, 0x14, 0x5a, 0x12, 0x67, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74,
-	0x65, 0x64, 0x2d, 0x67, 0x6f, 0x2f, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x33,
+	0x4c, 0x53, 0x58, 0x10, 0x04, 0x2a, 0x73, 0x0a, 0x1c, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61,
+	0x63, 0x6b, 0x75, 0x70, 0x50, 0x6f, 0x73, 0x74, 0x53, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x50,
+	0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x23, 0x0a, 0x1f, 0x50, 0x4f, 0x53, 0x54, 0x5f, 0x53, 0x55,
+	0x43, 0x43, 0x45, 0x53, 0x53, 0x5f, 0x50, 0x4f, 0x4c, 0x49, 0x43, 0x59, 0x5f, 0x55, 0x4e, 0x53,
+	0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x4b, 0x45,
+	0x45, 0x50, 0x10, 0x01, 0x12, 0x0e, 0x0a, 0x0a, 0x44, 0x52, 0x4f, 0x50, 0x5f, 0x41, 0x46, 0x54,
+	0x45, 0x52, 0x10, 0x02, 0x12, 0x14, 0x0a, 0x10, 0x44, 0x52, 0x4f, 0x50, 0x5f, 0x49, 0x4d, 0x4d,
+	0x45, 0x44, 0x49, 0x41, 0x54, 0x45, 0x4c, 0x59, 0x10, 0x03, 0x42, 0x14, 0x5a, 0x12, 0x67, 0x65,
+	0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x64, 0x2d, 0x67, 0x6f, 0x2f, 0x73, 0x74, 0x6f, 0x72, 0x65,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -596,17 +659,18 @@ func file_store_common_proto_rawDescGZIP() []byte {
 	return file_store_common_proto_rawDescData
 }
 
-var file_store_common_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_store_common_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
 var file_store_common_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_store_common_proto_goTypes = []any{
-	(Engine)(0),           // 0: bytebase.store.Engine
-	(VCSType)(0),          // 1: bytebase.store.VCSType
-	(MaskingLevel)(0),     // 2: bytebase.store.MaskingLevel
-	(ExportFormat)(0),     // 3: bytebase.store.ExportFormat
-	(*PageToken)(nil),     // 4: bytebase.store.PageToken
-	(*Position)(nil),      // 5: bytebase.store.Position
-	(*Range)(nil),         // 6: bytebase.store.Range
-	(*DatabaseLabel)(nil), // 7: bytebase.store.DatabaseLabel
+	(Engine)(0),                       // 0: bytebase.store.Engine
+	(VCSType)(0),                      // 1: bytebase.store.VCSType
+	(MaskingLevel)(0),                 // 2: bytebase.store.MaskingLevel
+	(ExportFormat)(0),                 // 3: bytebase.store.ExportFormat
+	(PriorBackupPostSuccessPolicy)(0), // 4: bytebase.store.PriorBackupPostSuccessPolicy
+	(*PageToken)(nil),                 // 5: bytebase.store.PageToken
+	(*Position)(nil),                  // 6: bytebase.store.Position
+	(*Range)(nil),                     // 7: bytebase.store.Range
+	(*DatabaseLabel)(nil),             // 8: bytebase.store.DatabaseLabel
 }
 var file_store_common_proto_depIdxs = []int32{
 	0, // [0:0] is the sub-list for method output_type
@@ -676,7 +740,7 @@ func file_store_common_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_store_common_proto_rawDesc,
-			NumEnums:      4,
+			NumEnums:      5,
 			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   0,
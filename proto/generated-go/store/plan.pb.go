@@ -41,6 +41,8 @@ const (
 	PlanConfig_ChangeDatabaseConfig_BRANCH PlanConfig_ChangeDatabaseConfig_Type = 5
 	// Used for DML change.
 	PlanConfig_ChangeDatabaseConfig_DATA PlanConfig_ChangeDatabaseConfig_Type = 6
+	// Used for rolling back a DML change from its prior backup.
+	PlanConfig_ChangeDatabaseConfig_DATA_ROLLBACK PlanConfig_ChangeDatabaseConfig_Type = 7
 )
 
 // Enum value maps for PlanConfig_ChangeDatabaseConfig_Type.
@@ -53,6 +55,7 @@ var (
 		4: "MIGRATE_GHOST",
 		5: "BRANCH",
 		6: "DATA",
+		7: "DATA_ROLLBACK",
 	}
 	PlanConfig_ChangeDatabaseConfig_Type_value = map[string]int32{
 		"TYPE_UNSPECIFIED": 0,
@@ -62,6 +65,7 @@ var (
 		"MIGRATE_GHOST":    4,
 		"BRANCH":           5,
 		"DATA":             6,
+		"DATA_ROLLBACK":    7,
 	}
 )
 
@@ -92,6 +96,64 @@ func (PlanConfig_ChangeDatabaseConfig_Type) EnumDescriptor() ([]byte, []int) {
 	return file_store_plan_proto_rawDescGZIP(), []int{0, 3, 0}
 }
 
+// BackupRequirement controls how the DML change handles a shortfall of the prior backup,
+// such as the missing backup database and the statements which cannot be backed up.
+type PlanConfig_ChangeDatabaseConfig_BackupRequirement int32
+
+const (
+	// REQUIRED if the prior backup is set, and DISABLED otherwise.
+	PlanConfig_ChangeDatabaseConfig_BACKUP_REQUIREMENT_UNSPECIFIED PlanConfig_ChangeDatabaseConfig_BackupRequirement = 0
+	// Any backup shortfall fails the task.
+	PlanConfig_ChangeDatabaseConfig_REQUIRED PlanConfig_ChangeDatabaseConfig_BackupRequirement = 1
+	// Any backup shortfall is logged as a warning, and the data is updated without the backup.
+	PlanConfig_ChangeDatabaseConfig_BEST_EFFORT PlanConfig_ChangeDatabaseConfig_BackupRequirement = 2
+	// The data is updated without the backup.
+	PlanConfig_ChangeDatabaseConfig_DISABLED PlanConfig_ChangeDatabaseConfig_BackupRequirement = 3
+)
+
+// Enum value maps for PlanConfig_ChangeDatabaseConfig_BackupRequirement.
+var (
+	PlanConfig_ChangeDatabaseConfig_BackupRequirement_name = map[int32]string{
+		0: "BACKUP_REQUIREMENT_UNSPECIFIED",
+		1: "REQUIRED",
+		2: "BEST_EFFORT",
+		3: "DISABLED",
+	}
+	PlanConfig_ChangeDatabaseConfig_BackupRequirement_value = map[string]int32{
+		"BACKUP_REQUIREMENT_UNSPECIFIED": 0,
+		"REQUIRED":                       1,
+		"BEST_EFFORT":                    2,
+		"DISABLED":                       3,
+	}
+)
+
+func (x PlanConfig_ChangeDatabaseConfig_BackupRequirement) Enum() *PlanConfig_ChangeDatabaseConfig_BackupRequirement {
+	p := new(PlanConfig_ChangeDatabaseConfig_BackupRequirement)
+	*p = x
+	return p
+}
+
+func (x PlanConfig_ChangeDatabaseConfig_BackupRequirement) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PlanConfig_ChangeDatabaseConfig_BackupRequirement) Descriptor() protoreflect.EnumDescriptor {
+	return file_store_plan_proto_enumTypes[1].Descriptor()
+}
+
+func (PlanConfig_ChangeDatabaseConfig_BackupRequirement) Type() protoreflect.EnumType {
+	return &file_store_plan_proto_enumTypes[1]
+}
+
+func (x PlanConfig_ChangeDatabaseConfig_BackupRequirement) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PlanConfig_ChangeDatabaseConfig_BackupRequirement.Descriptor instead.
+func (PlanConfig_ChangeDatabaseConfig_BackupRequirement) EnumDescriptor() ([]byte, []int) {
+	return file_store_plan_proto_rawDescGZIP(), []int{0, 3, 1}
+}
+
 type PlanConfig struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -479,6 +541,12 @@ type PlanConfig_ChangeDatabaseConfig struct {
 	GhostFlags    map[string]string `protobuf:"bytes,7,rep,name=ghost_flags,json=ghostFlags,proto3" json:"ghost_flags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 	// If set, a backup of the modified data will be created automatically before any changes are applied.
 	PreUpdateBackupDetail *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail `protobuf:"bytes,8,opt,name=pre_update_backup_detail,json=preUpdateBackupDetail,proto3,oneof" json:"pre_update_backup_detail,omitempty"`
+	// The DML change rolled back by the DATA_ROLLBACK change.
+	RollbackDetail    *PlanConfig_ChangeDatabaseConfig_RollbackDetail   `protobuf:"bytes,9,opt,name=rollback_detail,json=rollbackDetail,proto3,oneof" json:"rollback_detail,omitempty"`
+	BackupRequirement PlanConfig_ChangeDatabaseConfig_BackupRequirement `protobuf:"varint,10,opt,name=backup_requirement,json=backupRequirement,proto3,enum=bytebase.store.PlanConfig_ChangeDatabaseConfig_BackupRequirement" json:"backup_requirement,omitempty"`
+	// The deadline of the overall time budget of the DML change, e.g. the end of the maintenance window the rollout must finish in.
+	// The prior backup estimated to run past the deadline is warned about or deferred.
+	BudgetDeadline *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=budget_deadline,json=budgetDeadline,proto3" json:"budget_deadline,omitempty"`
 }
 
 func (x *PlanConfig_ChangeDatabaseConfig) Reset() {
@@ -555,6 +623,27 @@ func (x *PlanConfig_ChangeDatabaseConfig) GetPreUpdateBackupDetail() *PlanConfig
 	return nil
 }
 
+func (x *PlanConfig_ChangeDatabaseConfig) GetRollbackDetail() *PlanConfig_ChangeDatabaseConfig_RollbackDetail {
+	if x != nil {
+		return x.RollbackDetail
+	}
+	return nil
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig) GetBackupRequirement() PlanConfig_ChangeDatabaseConfig_BackupRequirement {
+	if x != nil {
+		return x.BackupRequirement
+	}
+	return PlanConfig_ChangeDatabaseConfig_BACKUP_REQUIREMENT_UNSPECIFIED
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig) GetBudgetDeadline() *timestamppb.Timestamp {
+	if x != nil {
+		return x.BudgetDeadline
+	}
+	return nil
+}
+
 type PlanConfig_ExportDataConfig struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -707,6 +796,41 @@ type PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail struct {
 	// The database for keeping the backup data.
 	// Format: instances/{instance}/databases/{database}
 	Database string `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	// The shard databases behind the target database, such as the shards of a sharding proxy or the workers of a distributed database,
+	// which the change on the target database is routed to. The affected rows in each shard are backed up into the database
+	// with the same name as the backup database on the shard instance. They must belong to the same project as the target database.
+	// Format: instances/{instance}/databases/{database}
+	ShardDatabases []string `protobuf:"bytes,2,rep,name=shard_databases,json=shardDatabases,proto3" json:"shard_databases,omitempty"`
+	// The reference to the KMS key encrypting the backup tables, e.g. its id, alias or ARN.
+	// Empty means the backup tables are not encrypted.
+	EncryptionKey string `protobuf:"bytes,3,opt,name=encryption_key,json=encryptionKey,proto3" json:"encryption_key,omitempty"`
+	// The TiDB resource control group of the backup sessions, so that the backups are rate-limited by the database.
+	// It overrides the resource group configured for the server.
+	ResourceGroup string `protobuf:"bytes,4,opt,name=resource_group,json=resourceGroup,proto3" json:"resource_group,omitempty"`
+	// Back up one in sample_rate of the affected rows on average to test the backup pipeline cheaply.
+	// The sample backups cannot be restored. Zero backs up all the affected rows.
+	SampleRate int32 `protobuf:"varint,5,opt,name=sample_rate,json=sampleRate,proto3" json:"sample_rate,omitempty"`
+	// The topic of the logical replication or CDC sink such as Kafka receiving the before-images of the affected rows.
+	// The before-images are published to the sink instead of the backup tables, and the backup database is not used.
+	SinkTopic string `protobuf:"bytes,6,opt,name=sink_topic,json=sinkTopic,proto3" json:"sink_topic,omitempty"`
+	// The database role the backup sessions run under for least privilege, e.g. SET ROLE on Postgres and EXECUTE AS USER on MSSQL.
+	// It overrides the session role configured for the server.
+	SessionRole string `protobuf:"bytes,7,opt,name=session_role,json=sessionRole,proto3" json:"session_role,omitempty"`
+	// The tables modified by the stored procedures called in the sheet, e.g. "schema.table" or "table".
+	// The rows changed by the procedures cannot be derived from the sheet, so the declared tables are backed up as a whole.
+	ProcedureTables []string `protobuf:"bytes,8,rep,name=procedure_tables,json=procedureTables,proto3" json:"procedure_tables,omitempty"`
+	// The statement the backup is derived from instead of the sheet, e.g. with a broader predicate to also back up the soft-deleted rows.
+	// It must modify the same tables as the sheet. Empty derives the backup from the sheet.
+	BackupStatement string `protobuf:"bytes,9,opt,name=backup_statement,json=backupStatement,proto3" json:"backup_statement,omitempty"`
+	// Back up only the first sample_limit affected rows ordered by the primary key as a representative sample for audits,
+	// or the last ones if sample_from_last. The sample backups cannot be restored. Zero backs up all the affected rows.
+	SampleLimit    int32 `protobuf:"varint,10,opt,name=sample_limit,json=sampleLimit,proto3" json:"sample_limit,omitempty"`
+	SampleFromLast bool  `protobuf:"varint,11,opt,name=sample_from_last,json=sampleFromLast,proto3" json:"sample_from_last,omitempty"`
+	// What happens to the backup tables after the data update succeeds. It overrides the policy configured for the server.
+	PostSuccessPolicy PriorBackupPostSuccessPolicy `protobuf:"varint,12,opt,name=post_success_policy,json=postSuccessPolicy,proto3,enum=bytebase.store.PriorBackupPostSuccessPolicy" json:"post_success_policy,omitempty"`
+	// Export the affected rows to the object store configured for the server, e.g. an S3, GCS or Azure Blob bucket,
+	// as the gzip-compressed CSV files with a manifest instead of the backup tables. The backup database is not used.
+	ObjectStorage bool `protobuf:"varint,13,opt,name=object_storage,json=objectStorage,proto3" json:"object_storage,omitempty"`
 }
 
 func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) Reset() {
@@ -748,6 +872,159 @@ func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetDatabase() st
 	return ""
 }
 
+func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetShardDatabases() []string {
+	if x != nil {
+		return x.ShardDatabases
+	}
+	return nil
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetEncryptionKey() string {
+	if x != nil {
+		return x.EncryptionKey
+	}
+	return ""
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetResourceGroup() string {
+	if x != nil {
+		return x.ResourceGroup
+	}
+	return ""
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetSampleRate() int32 {
+	if x != nil {
+		return x.SampleRate
+	}
+	return 0
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetSinkTopic() string {
+	if x != nil {
+		return x.SinkTopic
+	}
+	return ""
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetSessionRole() string {
+	if x != nil {
+		return x.SessionRole
+	}
+	return ""
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetProcedureTables() []string {
+	if x != nil {
+		return x.ProcedureTables
+	}
+	return nil
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetBackupStatement() string {
+	if x != nil {
+		return x.BackupStatement
+	}
+	return ""
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetSampleLimit() int32 {
+	if x != nil {
+		return x.SampleLimit
+	}
+	return 0
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetSampleFromLast() bool {
+	if x != nil {
+		return x.SampleFromLast
+	}
+	return false
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetPostSuccessPolicy() PriorBackupPostSuccessPolicy {
+	if x != nil {
+		return x.PostSuccessPolicy
+	}
+	return PriorBackupPostSuccessPolicy_POST_SUCCESS_POLICY_UNSPECIFIED
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail) GetObjectStorage() bool {
+	if x != nil {
+		return x.ObjectStorage
+	}
+	return false
+}
+
+type PlanConfig_ChangeDatabaseConfig_RollbackDetail struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The task run of the DML change rolled back, whose prior backup the rows are restored from.
+	// Format: projects/{project}/rollouts/{rollout}/stages/{stage}/tasks/{task}/taskRuns/{taskRun}
+	TaskRun string `protobuf:"bytes,1,opt,name=task_run,json=taskRun,proto3" json:"task_run,omitempty"`
+	// How the backed up rows conflicting with the current rows are restored, i.e. OVERWRITE, SKIP or ERROR.
+	// Empty overwrites the updated columns of the rows changed by UPDATE, and fails on conflict for the rows deleted by DELETE.
+	ConflictStrategy string `protobuf:"bytes,2,opt,name=conflict_strategy,json=conflictStrategy,proto3" json:"conflict_strategy,omitempty"`
+	// The maximum number of tables restored concurrently, each in its own transaction, after the tables referenced by its foreign keys.
+	// The tables restored before a failure stay restored. Zero or one restores all the tables in one transaction.
+	Parallelism int32 `protobuf:"varint,3,opt,name=parallelism,proto3" json:"parallelism,omitempty"`
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_RollbackDetail) Reset() {
+	*x = PlanConfig_ChangeDatabaseConfig_RollbackDetail{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_store_plan_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_RollbackDetail) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlanConfig_ChangeDatabaseConfig_RollbackDetail) ProtoMessage() {}
+
+func (x *PlanConfig_ChangeDatabaseConfig_RollbackDetail) ProtoReflect() protoreflect.Message {
+	mi := &file_store_plan_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlanConfig_ChangeDatabaseConfig_RollbackDetail.ProtoReflect.Descriptor instead.
+func (*PlanConfig_ChangeDatabaseConfig_RollbackDetail) Descriptor() ([]byte, []int) {
+	return file_store_plan_proto_rawDescGZIP(), []int{0, 3, 2}
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_RollbackDetail) GetTaskRun() string {
+	if x != nil {
+		return x.TaskRun
+	}
+	return ""
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_RollbackDetail) GetConflictStrategy() string {
+	if x != nil {
+		return x.ConflictStrategy
+	}
+	return ""
+}
+
+func (x *PlanConfig_ChangeDatabaseConfig_RollbackDetail) GetParallelism() int32 {
+	if x != nil {
+		return x.Parallelism
+	}
+	return 0
+}
+
 var File_store_plan_proto protoreflect.FileDescriptor
 
 var file_store_plan_proto_rawDesc = []byte{
@@ -758,7 +1035,7 @@ var file_store_plan_proto_rawDesc = []byte{
 	0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74,
 	0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70,
 	0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x12, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2f, 0x63, 0x6f, 0x6d, 0x6d,
-	0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xed, 0x10, 0x0a, 0x0a, 0x50, 0x6c, 0x61,
+	0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x9f, 0x19, 0x0a, 0x0a, 0x50, 0x6c, 0x61,
 	0x6e, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x35, 0x0a, 0x05, 0x73, 0x74, 0x65, 0x70, 0x73,
 	0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73,
 	0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x6c, 0x61, 0x6e, 0x43, 0x6f, 0x6e, 0x66,
@@ -831,7 +1108,7 @@ var file_store_plan_proto_rawDesc = []byte{
 	0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
 	0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
 	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01,
-	0x1a, 0xad, 0x05, 0x0a, 0x14, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x44, 0x61, 0x74, 0x61, 0x62,
+	0x1a, 0xdf, 0x0d, 0x0a, 0x14, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x44, 0x61, 0x74, 0x61, 0x62,
 	0x61, 0x73, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x61, 0x72,
 	0x67, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65,
 	0x74, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x68, 0x65, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
@@ -857,45 +1134,112 @@ var file_store_plan_proto_rawDesc = []byte{
 	0x72, 0x65, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65,
 	0x74, 0x61, 0x69, 0x6c, 0x48, 0x00, 0x52, 0x15, 0x70, 0x72, 0x65, 0x55, 0x70, 0x64, 0x61, 0x74,
 	0x65, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x88, 0x01, 0x01,
-	0x1a, 0x3d, 0x0a, 0x0f, 0x47, 0x68, 0x6f, 0x73, 0x74, 0x46, 0x6c, 0x61, 0x67, 0x73, 0x45, 0x6e,
-	0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a,
-	0x33, 0x0a, 0x15, 0x50, 0x72, 0x65, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x42, 0x61, 0x63, 0x6b,
-	0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x61, 0x74, 0x61,
-	0x62, 0x61, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x64, 0x61, 0x74, 0x61,
-	0x62, 0x61, 0x73, 0x65, 0x22, 0x71, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x14, 0x0a, 0x10,
-	0x54, 0x59, 0x50, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44,
-	0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x42, 0x41, 0x53, 0x45, 0x4c, 0x49, 0x4e, 0x45, 0x10, 0x01,
-	0x12, 0x0b, 0x0a, 0x07, 0x4d, 0x49, 0x47, 0x52, 0x41, 0x54, 0x45, 0x10, 0x02, 0x12, 0x0f, 0x0a,
-	0x0b, 0x4d, 0x49, 0x47, 0x52, 0x41, 0x54, 0x45, 0x5f, 0x53, 0x44, 0x4c, 0x10, 0x03, 0x12, 0x11,
-	0x0a, 0x0d, 0x4d, 0x49, 0x47, 0x52, 0x41, 0x54, 0x45, 0x5f, 0x47, 0x48, 0x4f, 0x53, 0x54, 0x10,
-	0x04, 0x12, 0x0a, 0x0a, 0x06, 0x42, 0x52, 0x41, 0x4e, 0x43, 0x48, 0x10, 0x05, 0x12, 0x08, 0x0a,
-	0x04, 0x44, 0x41, 0x54, 0x41, 0x10, 0x06, 0x42, 0x1b, 0x0a, 0x19, 0x5f, 0x70, 0x72, 0x65, 0x5f,
-	0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x5f, 0x62, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x5f, 0x64, 0x65,
-	0x74, 0x61, 0x69, 0x6c, 0x4a, 0x04, 0x08, 0x05, 0x10, 0x06, 0x4a, 0x04, 0x08, 0x06, 0x10, 0x07,
-	0x1a, 0xa4, 0x01, 0x0a, 0x10, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x44, 0x61, 0x74, 0x61, 0x43,
-	0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x12, 0x14, 0x0a,
-	0x05, 0x73, 0x68, 0x65, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x73, 0x68,
-	0x65, 0x65, 0x74, 0x12, 0x34, 0x0a, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x18, 0x03, 0x20,
-	0x01, 0x28, 0x0e, 0x32, 0x1c, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73,
-	0x74, 0x6f, 0x72, 0x65, 0x2e, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x46, 0x6f, 0x72, 0x6d, 0x61,
-	0x74, 0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x1f, 0x0a, 0x08, 0x70, 0x61, 0x73,
-	0x73, 0x77, 0x6f, 0x72, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x08, 0x70,
-	0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x88, 0x01, 0x01, 0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x70,
-	0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x1a, 0x8e, 0x01, 0x0a, 0x09, 0x56, 0x43, 0x53, 0x53,
-	0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x32, 0x0a, 0x08, 0x76, 0x63, 0x73, 0x5f, 0x74, 0x79, 0x70,
-	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61,
-	0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x56, 0x43, 0x53, 0x54, 0x79, 0x70, 0x65,
-	0x52, 0x07, 0x76, 0x63, 0x73, 0x54, 0x79, 0x70, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x76, 0x63, 0x73,
-	0x5f, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x0c, 0x76, 0x63, 0x73, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x12, 0x28,
-	0x0a, 0x10, 0x70, 0x75, 0x6c, 0x6c, 0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x75,
-	0x72, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x70, 0x75, 0x6c, 0x6c, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x55, 0x72, 0x6c, 0x42, 0x14, 0x5a, 0x12, 0x67, 0x65, 0x6e, 0x65,
-	0x72, 0x61, 0x74, 0x65, 0x64, 0x2d, 0x67, 0x6f, 0x2f, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x12, 0x6c, 0x0a, 0x0f, 0x72, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x5f, 0x64, 0x65, 0x74,
+	0x61, 0x69, 0x6c, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x3e, 0x2e, 0x62, 0x79, 0x74, 0x65,
+	0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x6c, 0x61, 0x6e, 0x43,
+	0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x44, 0x61, 0x74, 0x61,
+	0x62, 0x61, 0x73, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x52, 0x6f, 0x6c, 0x6c, 0x62,
+	0x61, 0x63, 0x6b, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x48, 0x01, 0x52, 0x0e, 0x72, 0x6f, 0x6c,
+	0x6c, 0x62, 0x61, 0x63, 0x6b, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x88, 0x01, 0x01, 0x12, 0x70,
+	0x0a, 0x12, 0x62, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x5f, 0x72, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65,
+	0x6d, 0x65, 0x6e, 0x74, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x41, 0x2e, 0x62, 0x79, 0x74,
+	0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x6c, 0x61, 0x6e,
+	0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x44, 0x61, 0x74,
+	0x61, 0x62, 0x61, 0x73, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x42, 0x61, 0x63, 0x6b,
+	0x75, 0x70, 0x52, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x11, 0x62,
+	0x61, 0x63, 0x6b, 0x75, 0x70, 0x52, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74,
+	0x12, 0x43, 0x0a, 0x0f, 0x62, 0x75, 0x64, 0x67, 0x65, 0x74, 0x5f, 0x64, 0x65, 0x61, 0x64, 0x6c,
+	0x69, 0x6e, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0e, 0x62, 0x75, 0x64, 0x67, 0x65, 0x74, 0x44, 0x65, 0x61,
+	0x64, 0x6c, 0x69, 0x6e, 0x65, 0x1a, 0x3d, 0x0a, 0x0f, 0x47, 0x68, 0x6f, 0x73, 0x74, 0x46, 0x6c,
+	0x61, 0x67, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x3a, 0x02, 0x38, 0x01, 0x1a, 0xb5, 0x04, 0x0a, 0x15, 0x50, 0x72, 0x65, 0x55, 0x70, 0x64, 0x61,
+	0x74, 0x65, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x12, 0x1a,
+	0x0a, 0x08, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x08, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x73, 0x68,
+	0x61, 0x72, 0x64, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x73, 0x18, 0x02, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x0e, 0x73, 0x68, 0x61, 0x72, 0x64, 0x44, 0x61, 0x74, 0x61, 0x62, 0x61,
+	0x73, 0x65, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x69, 0x6f,
+	0x6e, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x65, 0x6e, 0x63,
+	0x72, 0x79, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x4b, 0x65, 0x79, 0x12, 0x25, 0x0a, 0x0e, 0x72, 0x65,
+	0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x5f, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0d, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x47, 0x72, 0x6f, 0x75,
+	0x70, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x72, 0x61, 0x74, 0x65,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x52, 0x61,
+	0x74, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x69, 0x6e, 0x6b, 0x5f, 0x74, 0x6f, 0x70, 0x69, 0x63,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x69, 0x6e, 0x6b, 0x54, 0x6f, 0x70, 0x69,
+	0x63, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x72, 0x6f, 0x6c,
+	0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x52, 0x6f, 0x6c, 0x65, 0x12, 0x29, 0x0a, 0x10, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x64, 0x75, 0x72,
+	0x65, 0x5f, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0f,
+	0x70, 0x72, 0x6f, 0x63, 0x65, 0x64, 0x75, 0x72, 0x65, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x73, 0x12,
+	0x29, 0x0a, 0x10, 0x62, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x6d,
+	0x65, 0x6e, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x62, 0x61, 0x63, 0x6b, 0x75,
+	0x70, 0x53, 0x74, 0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x0b, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x28, 0x0a,
+	0x10, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x66, 0x72, 0x6f, 0x6d, 0x5f, 0x6c, 0x61, 0x73,
+	0x74, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0e, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x46,
+	0x72, 0x6f, 0x6d, 0x4c, 0x61, 0x73, 0x74, 0x12, 0x5c, 0x0a, 0x13, 0x70, 0x6f, 0x73, 0x74, 0x5f,
+	0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x5f, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x0c,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x2c, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e,
+	0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75,
+	0x70, 0x50, 0x6f, 0x73, 0x74, 0x53, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x50, 0x6f, 0x6c, 0x69,
+	0x63, 0x79, 0x52, 0x11, 0x70, 0x6f, 0x73, 0x74, 0x53, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x50,
+	0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x25, 0x0a, 0x0e, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x5f,
+	0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0d, 0x6f,
+	0x62, 0x6a, 0x65, 0x63, 0x74, 0x53, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x1a, 0x7a, 0x0a, 0x0e,
+	0x52, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x12, 0x19,
+	0x0a, 0x08, 0x74, 0x61, 0x73, 0x6b, 0x5f, 0x72, 0x75, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x74, 0x61, 0x73, 0x6b, 0x52, 0x75, 0x6e, 0x12, 0x2b, 0x0a, 0x11, 0x63, 0x6f, 0x6e,
+	0x66, 0x6c, 0x69, 0x63, 0x74, 0x5f, 0x73, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x79, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x10, 0x63, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x53, 0x74,
+	0x72, 0x61, 0x74, 0x65, 0x67, 0x79, 0x12, 0x20, 0x0a, 0x0b, 0x70, 0x61, 0x72, 0x61, 0x6c, 0x6c,
+	0x65, 0x6c, 0x69, 0x73, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x70, 0x61, 0x72,
+	0x61, 0x6c, 0x6c, 0x65, 0x6c, 0x69, 0x73, 0x6d, 0x22, 0x84, 0x01, 0x0a, 0x04, 0x54, 0x79, 0x70,
+	0x65, 0x12, 0x14, 0x0a, 0x10, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43,
+	0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x42, 0x41, 0x53, 0x45, 0x4c,
+	0x49, 0x4e, 0x45, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x4d, 0x49, 0x47, 0x52, 0x41, 0x54, 0x45,
+	0x10, 0x02, 0x12, 0x0f, 0x0a, 0x0b, 0x4d, 0x49, 0x47, 0x52, 0x41, 0x54, 0x45, 0x5f, 0x53, 0x44,
+	0x4c, 0x10, 0x03, 0x12, 0x11, 0x0a, 0x0d, 0x4d, 0x49, 0x47, 0x52, 0x41, 0x54, 0x45, 0x5f, 0x47,
+	0x48, 0x4f, 0x53, 0x54, 0x10, 0x04, 0x12, 0x0a, 0x0a, 0x06, 0x42, 0x52, 0x41, 0x4e, 0x43, 0x48,
+	0x10, 0x05, 0x12, 0x08, 0x0a, 0x04, 0x44, 0x41, 0x54, 0x41, 0x10, 0x06, 0x12, 0x11, 0x0a, 0x0d,
+	0x44, 0x41, 0x54, 0x41, 0x5f, 0x52, 0x4f, 0x4c, 0x4c, 0x42, 0x41, 0x43, 0x4b, 0x10, 0x07, 0x22,
+	0x64, 0x0a, 0x11, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x52, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65,
+	0x6d, 0x65, 0x6e, 0x74, 0x12, 0x22, 0x0a, 0x1e, 0x42, 0x41, 0x43, 0x4b, 0x55, 0x50, 0x5f, 0x52,
+	0x45, 0x51, 0x55, 0x49, 0x52, 0x45, 0x4d, 0x45, 0x4e, 0x54, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45,
+	0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x52, 0x45, 0x51, 0x55,
+	0x49, 0x52, 0x45, 0x44, 0x10, 0x01, 0x12, 0x0f, 0x0a, 0x0b, 0x42, 0x45, 0x53, 0x54, 0x5f, 0x45,
+	0x46, 0x46, 0x4f, 0x52, 0x54, 0x10, 0x02, 0x12, 0x0c, 0x0a, 0x08, 0x44, 0x49, 0x53, 0x41, 0x42,
+	0x4c, 0x45, 0x44, 0x10, 0x03, 0x42, 0x1b, 0x0a, 0x19, 0x5f, 0x70, 0x72, 0x65, 0x5f, 0x75, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x5f, 0x62, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x5f, 0x64, 0x65, 0x74, 0x61,
+	0x69, 0x6c, 0x42, 0x12, 0x0a, 0x10, 0x5f, 0x72, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x5f,
+	0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x4a, 0x04, 0x08, 0x05, 0x10, 0x06, 0x4a, 0x04, 0x08, 0x06,
+	0x10, 0x07, 0x1a, 0xa4, 0x01, 0x0a, 0x10, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x44, 0x61, 0x74,
+	0x61, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x12,
+	0x14, 0x0a, 0x05, 0x73, 0x68, 0x65, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x73, 0x68, 0x65, 0x65, 0x74, 0x12, 0x34, 0x0a, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1c, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65,
+	0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x46, 0x6f, 0x72,
+	0x6d, 0x61, 0x74, 0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x1f, 0x0a, 0x08, 0x70,
+	0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52,
+	0x08, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x88, 0x01, 0x01, 0x42, 0x0b, 0x0a, 0x09,
+	0x5f, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x1a, 0x8e, 0x01, 0x0a, 0x09, 0x56, 0x43,
+	0x53, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x32, 0x0a, 0x08, 0x76, 0x63, 0x73, 0x5f, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x62, 0x79, 0x74, 0x65,
+	0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x56, 0x43, 0x53, 0x54, 0x79,
+	0x70, 0x65, 0x52, 0x07, 0x76, 0x63, 0x73, 0x54, 0x79, 0x70, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x76,
+	0x63, 0x73, 0x5f, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0c, 0x76, 0x63, 0x73, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x6f, 0x72,
+	0x12, 0x28, 0x0a, 0x10, 0x70, 0x75, 0x6c, 0x6c, 0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x5f, 0x75, 0x72, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x70, 0x75, 0x6c, 0x6c,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x55, 0x72, 0x6c, 0x42, 0x14, 0x5a, 0x12, 0x67, 0x65,
+	0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x64, 0x2d, 0x67, 0x6f, 0x2f, 0x73, 0x74, 0x6f, 0x72, 0x65,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -910,43 +1254,50 @@ func file_store_plan_proto_rawDescGZIP() []byte {
 	return file_store_plan_proto_rawDescData
 }
 
-var file_store_plan_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_store_plan_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_store_plan_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_store_plan_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 var file_store_plan_proto_goTypes = []any{
-	(PlanConfig_ChangeDatabaseConfig_Type)(0), // 0: bytebase.store.PlanConfig.ChangeDatabaseConfig.Type
-	(*PlanConfig)(nil),                        // 1: bytebase.store.PlanConfig
-	(*PlanConfig_Step)(nil),                   // 2: bytebase.store.PlanConfig.Step
-	(*PlanConfig_Spec)(nil),                   // 3: bytebase.store.PlanConfig.Spec
-	(*PlanConfig_CreateDatabaseConfig)(nil),   // 4: bytebase.store.PlanConfig.CreateDatabaseConfig
-	(*PlanConfig_ChangeDatabaseConfig)(nil),   // 5: bytebase.store.PlanConfig.ChangeDatabaseConfig
-	(*PlanConfig_ExportDataConfig)(nil),       // 6: bytebase.store.PlanConfig.ExportDataConfig
-	(*PlanConfig_VCSSource)(nil),              // 7: bytebase.store.PlanConfig.VCSSource
-	nil,                                       // 8: bytebase.store.PlanConfig.CreateDatabaseConfig.LabelsEntry
-	nil,                                       // 9: bytebase.store.PlanConfig.ChangeDatabaseConfig.GhostFlagsEntry
-	(*PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail)(nil), // 10: bytebase.store.PlanConfig.ChangeDatabaseConfig.PreUpdateBackupDetail
-	(*timestamppb.Timestamp)(nil),                                 // 11: google.protobuf.Timestamp
-	(ExportFormat)(0),                                             // 12: bytebase.store.ExportFormat
-	(VCSType)(0),                                                  // 13: bytebase.store.VCSType
+	(PlanConfig_ChangeDatabaseConfig_Type)(0),              // 0: bytebase.store.PlanConfig.ChangeDatabaseConfig.Type
+	(PlanConfig_ChangeDatabaseConfig_BackupRequirement)(0), // 1: bytebase.store.PlanConfig.ChangeDatabaseConfig.BackupRequirement
+	(*PlanConfig)(nil),                      // 2: bytebase.store.PlanConfig
+	(*PlanConfig_Step)(nil),                 // 3: bytebase.store.PlanConfig.Step
+	(*PlanConfig_Spec)(nil),                 // 4: bytebase.store.PlanConfig.Spec
+	(*PlanConfig_CreateDatabaseConfig)(nil), // 5: bytebase.store.PlanConfig.CreateDatabaseConfig
+	(*PlanConfig_ChangeDatabaseConfig)(nil), // 6: bytebase.store.PlanConfig.ChangeDatabaseConfig
+	(*PlanConfig_ExportDataConfig)(nil),     // 7: bytebase.store.PlanConfig.ExportDataConfig
+	(*PlanConfig_VCSSource)(nil),            // 8: bytebase.store.PlanConfig.VCSSource
+	nil,                                     // 9: bytebase.store.PlanConfig.CreateDatabaseConfig.LabelsEntry
+	nil,                                     // 10: bytebase.store.PlanConfig.ChangeDatabaseConfig.GhostFlagsEntry
+	(*PlanConfig_ChangeDatabaseConfig_PreUpdateBackupDetail)(nil), // 11: bytebase.store.PlanConfig.ChangeDatabaseConfig.PreUpdateBackupDetail
+	(*PlanConfig_ChangeDatabaseConfig_RollbackDetail)(nil),        // 12: bytebase.store.PlanConfig.ChangeDatabaseConfig.RollbackDetail
+	(*timestamppb.Timestamp)(nil),                                 // 13: google.protobuf.Timestamp
+	(ExportFormat)(0),                                             // 14: bytebase.store.ExportFormat
+	(VCSType)(0),                                                  // 15: bytebase.store.VCSType
+	(PriorBackupPostSuccessPolicy)(0),                             // 16: bytebase.store.PriorBackupPostSuccessPolicy
 }
 var file_store_plan_proto_depIdxs = []int32{
-	2,  // 0: bytebase.store.PlanConfig.steps:type_name -> bytebase.store.PlanConfig.Step
-	7,  // 1: bytebase.store.PlanConfig.vcs_source:type_name -> bytebase.store.PlanConfig.VCSSource
-	3,  // 2: bytebase.store.PlanConfig.Step.specs:type_name -> bytebase.store.PlanConfig.Spec
-	11, // 3: bytebase.store.PlanConfig.Spec.earliest_allowed_time:type_name -> google.protobuf.Timestamp
-	4,  // 4: bytebase.store.PlanConfig.Spec.create_database_config:type_name -> bytebase.store.PlanConfig.CreateDatabaseConfig
-	5,  // 5: bytebase.store.PlanConfig.Spec.change_database_config:type_name -> bytebase.store.PlanConfig.ChangeDatabaseConfig
-	6,  // 6: bytebase.store.PlanConfig.Spec.export_data_config:type_name -> bytebase.store.PlanConfig.ExportDataConfig
-	8,  // 7: bytebase.store.PlanConfig.CreateDatabaseConfig.labels:type_name -> bytebase.store.PlanConfig.CreateDatabaseConfig.LabelsEntry
+	3,  // 0: bytebase.store.PlanConfig.steps:type_name -> bytebase.store.PlanConfig.Step
+	8,  // 1: bytebase.store.PlanConfig.vcs_source:type_name -> bytebase.store.PlanConfig.VCSSource
+	4,  // 2: bytebase.store.PlanConfig.Step.specs:type_name -> bytebase.store.PlanConfig.Spec
+	13, // 3: bytebase.store.PlanConfig.Spec.earliest_allowed_time:type_name -> google.protobuf.Timestamp
+	5,  // 4: bytebase.store.PlanConfig.Spec.create_database_config:type_name -> bytebase.store.PlanConfig.CreateDatabaseConfig
+	6,  // 5: bytebase.store.PlanConfig.Spec.change_database_config:type_name -> bytebase.store.PlanConfig.ChangeDatabaseConfig
+	7,  // 6: bytebase.store.PlanConfig.Spec.export_data_config:type_name -> bytebase.store.PlanConfig.ExportDataConfig
+	9,  // 7: bytebase.store.PlanConfig.CreateDatabaseConfig.labels:type_name -> bytebase.store.PlanConfig.CreateDatabaseConfig.LabelsEntry
 	0,  // 8: bytebase.store.PlanConfig.ChangeDatabaseConfig.type:type_name -> bytebase.store.PlanConfig.ChangeDatabaseConfig.Type
-	9,  // 9: bytebase.store.PlanConfig.ChangeDatabaseConfig.ghost_flags:type_name -> bytebase.store.PlanConfig.ChangeDatabaseConfig.GhostFlagsEntry
-	10, // 10: bytebase.store.PlanConfig.ChangeDatabaseConfig.pre_update_backup_detail:type_name -> bytebase.store.PlanConfig.ChangeDatabaseConfig.PreUpdateBackupDetail
-	12, // 11: bytebase.store.PlanConfig.ExportDataConfig.format:type_name -> bytebase.store.ExportFormat
-	13, // 12: bytebase.store.PlanConfig.VCSSource.vcs_type:type_name -> bytebase.store.VCSType
-	13, // [13:13] is the sub-list for method output_type
-	13, // [13:13] is the sub-list for method input_type
-	13, // [13:13] is the sub-list for extension type_name
-	13, // [13:13] is the sub-list for extension extendee
-	0,  // [0:13] is the sub-list for field type_name
+	10, // 9: bytebase.store.PlanConfig.ChangeDatabaseConfig.ghost_flags:type_name -> bytebase.store.PlanConfig.ChangeDatabaseConfig.GhostFlagsEntry
+	11, // 10: bytebase.store.PlanConfig.ChangeDatabaseConfig.pre_update_backup_detail:type_name -> bytebase.store.PlanConfig.ChangeDatabaseConfig.PreUpdateBackupDetail
+	12, // 11: bytebase.store.PlanConfig.ChangeDatabaseConfig.rollback_detail:type_name -> bytebase.store.PlanConfig.ChangeDatabaseConfig.RollbackDetail
+	1,  // 12: bytebase.store.PlanConfig.ChangeDatabaseConfig.backup_requirement:type_name -> bytebase.store.PlanConfig.ChangeDatabaseConfig.BackupRequirement
+	13, // 13: bytebase.store.PlanConfig.ChangeDatabaseConfig.budget_deadline:type_name -> google.protobuf.Timestamp
+	14, // 14: bytebase.store.PlanConfig.ExportDataConfig.format:type_name -> bytebase.store.ExportFormat
+	15, // 15: bytebase.store.PlanConfig.VCSSource.vcs_type:type_name -> bytebase.store.VCSType
+	16, // 16: bytebase.store.PlanConfig.ChangeDatabaseConfig.PreUpdateBackupDetail.post_success_policy:type_name -> bytebase.store.PriorBackupPostSuccessPolicy
+	17, // [17:17] is the sub-list for method output_type
+	17, // [17:17] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
 }
 
 func init() { file_store_plan_proto_init() }
@@ -1052,6 +1403,18 @@ func file_store_plan_proto_init() {
 				return nil
 			}
 		}
+		file_store_plan_proto_msgTypes[10].Exporter = func(v any, i int) any {
+			switch v := v.(*PlanConfig_ChangeDatabaseConfig_RollbackDetail); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	file_store_plan_proto_msgTypes[2].OneofWrappers = []any{
 		(*PlanConfig_Spec_CreateDatabaseConfig)(nil),
@@ -1065,8 +1428,8 @@ func file_store_plan_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_store_plan_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   10,
+			NumEnums:      2,
+			NumMessages:   11,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -21,6 +21,113 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type PriorBackupDetail_Item_Strategy int32
+
+const (
+	PriorBackupDetail_Item_STRATEGY_UNSPECIFIED PriorBackupDetail_Item_Strategy = 0
+	// The backup statement such as INSERT ... SELECT was executed.
+	PriorBackupDetail_Item_STATEMENT PriorBackupDetail_Item_Strategy = 1
+	// The rows were streamed into the backup table by the bulk copy of the engine such as Postgres COPY.
+	PriorBackupDetail_Item_BULK_COPY PriorBackupDetail_Item_Strategy = 2
+	// The backup statement was deferred to the data update transaction to lock the backed up rows.
+	PriorBackupDetail_Item_DEFERRED PriorBackupDetail_Item_Strategy = 3
+)
+
+// Enum value maps for PriorBackupDetail_Item_Strategy.
+var (
+	PriorBackupDetail_Item_Strategy_name = map[int32]string{
+		0: "STRATEGY_UNSPECIFIED",
+		1: "STATEMENT",
+		2: "BULK_COPY",
+		3: "DEFERRED",
+	}
+	PriorBackupDetail_Item_Strategy_value = map[string]int32{
+		"STRATEGY_UNSPECIFIED": 0,
+		"STATEMENT":            1,
+		"BULK_COPY":            2,
+		"DEFERRED":             3,
+	}
+)
+
+func (x PriorBackupDetail_Item_Strategy) Enum() *PriorBackupDetail_Item_Strategy {
+	p := new(PriorBackupDetail_Item_Strategy)
+	*p = x
+	return p
+}
+
+func (x PriorBackupDetail_Item_Strategy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PriorBackupDetail_Item_Strategy) Descriptor() protoreflect.EnumDescriptor {
+	return file_store_task_run_proto_enumTypes[0].Descriptor()
+}
+
+func (PriorBackupDetail_Item_Strategy) Type() protoreflect.EnumType {
+	return &file_store_task_run_proto_enumTypes[0]
+}
+
+func (x PriorBackupDetail_Item_Strategy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PriorBackupDetail_Item_Strategy.Descriptor instead.
+func (PriorBackupDetail_Item_Strategy) EnumDescriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{1, 0, 0}
+}
+
+type PriorBackupDetail_Item_SurrogateKey int32
+
+const (
+	PriorBackupDetail_Item_SURROGATE_KEY_UNSPECIFIED PriorBackupDetail_Item_SurrogateKey = 0
+	// The ROWID of the source row, which is stable across updates. Only for Oracle.
+	PriorBackupDetail_Item_ROWID PriorBackupDetail_Item_SurrogateKey = 1
+	// A random UUID identifying the backup row, which tells apart the duplicate rows of the tables without primary keys.
+	// Only for Postgres, where the physical row locations change on updates.
+	PriorBackupDetail_Item_UUID PriorBackupDetail_Item_SurrogateKey = 2
+)
+
+// Enum value maps for PriorBackupDetail_Item_SurrogateKey.
+var (
+	PriorBackupDetail_Item_SurrogateKey_name = map[int32]string{
+		0: "SURROGATE_KEY_UNSPECIFIED",
+		1: "ROWID",
+		2: "UUID",
+	}
+	PriorBackupDetail_Item_SurrogateKey_value = map[string]int32{
+		"SURROGATE_KEY_UNSPECIFIED": 0,
+		"ROWID":                     1,
+		"UUID":                      2,
+	}
+)
+
+func (x PriorBackupDetail_Item_SurrogateKey) Enum() *PriorBackupDetail_Item_SurrogateKey {
+	p := new(PriorBackupDetail_Item_SurrogateKey)
+	*p = x
+	return p
+}
+
+func (x PriorBackupDetail_Item_SurrogateKey) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PriorBackupDetail_Item_SurrogateKey) Descriptor() protoreflect.EnumDescriptor {
+	return file_store_task_run_proto_enumTypes[1].Descriptor()
+}
+
+func (PriorBackupDetail_Item_SurrogateKey) Type() protoreflect.EnumType {
+	return &file_store_task_run_proto_enumTypes[1]
+}
+
+func (x PriorBackupDetail_Item_SurrogateKey) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PriorBackupDetail_Item_SurrogateKey.Descriptor instead.
+func (PriorBackupDetail_Item_SurrogateKey) EnumDescriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{1, 0, 1}
+}
+
 type TaskRunResult struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -36,6 +143,13 @@ type TaskRunResult struct {
 	ExportArchiveUid int32 `protobuf:"varint,6,opt,name=export_archive_uid,json=exportArchiveUid,proto3" json:"export_archive_uid,omitempty"`
 	// The prior backup detail that will be used to rollback the task run.
 	PriorBackupDetail *PriorBackupDetail `protobuf:"bytes,7,opt,name=prior_backup_detail,json=priorBackupDetail,proto3" json:"prior_backup_detail,omitempty"`
+	// The EXPLAIN plans of the data update statements captured before execution.
+	// Empty if capturing the plan is disabled or unsupported by the engine.
+	ExplainPlans []string `protobuf:"bytes,8,rep,name=explain_plans,json=explainPlans,proto3" json:"explain_plans,omitempty"`
+	// The warnings that don't fail the task run, e.g. the prior backups much larger or smaller than the history of the source tables.
+	Warnings []string `protobuf:"bytes,9,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	// The error of the prior backup the data was updated without by the BEST_EFFORT backup requirement, e.g. the backup timeout.
+	PriorBackupError string `protobuf:"bytes,10,opt,name=prior_backup_error,json=priorBackupError,proto3" json:"prior_backup_error,omitempty"`
 }
 
 func (x *TaskRunResult) Reset() {
@@ -105,45 +219,866 @@ func (x *TaskRunResult) GetEndPosition() *TaskRunResult_Position {
 	return nil
 }
 
-func (x *TaskRunResult) GetExportArchiveUid() int32 {
+func (x *TaskRunResult) GetExportArchiveUid() int32 {
+	if x != nil {
+		return x.ExportArchiveUid
+	}
+	return 0
+}
+
+func (x *TaskRunResult) GetPriorBackupDetail() *PriorBackupDetail {
+	if x != nil {
+		return x.PriorBackupDetail
+	}
+	return nil
+}
+
+func (x *TaskRunResult) GetExplainPlans() []string {
+	if x != nil {
+		return x.ExplainPlans
+	}
+	return nil
+}
+
+func (x *TaskRunResult) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *TaskRunResult) GetPriorBackupError() string {
+	if x != nil {
+		return x.PriorBackupError
+	}
+	return ""
+}
+
+type PriorBackupDetail struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []*PriorBackupDetail_Item `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	// The isolation level under which the backup statements were executed, e.g. REPEATABLE READ.
+	// Empty means the driver default, e.g. the backup statements deferred to the data update transaction.
+	IsolationLevel string `protobuf:"bytes,2,opt,name=isolation_level,json=isolationLevel,proto3" json:"isolation_level,omitempty"`
+	// The reference to the KMS key encrypting the backup tables and their exports.
+	// It's only supported on MySQL with the keyring_aws plugin, whose master key must be the referenced key.
+	EncryptionKey string `protobuf:"bytes,3,opt,name=encryption_key,json=encryptionKey,proto3" json:"encryption_key,omitempty"`
+	// The items of each backup database are ordered by the foreign key dependencies of the source tables,
+	// the referenced tables first, so that restores can replay the items in order.
+	DependencyOrdered bool `protobuf:"varint,4,opt,name=dependency_ordered,json=dependencyOrdered,proto3" json:"dependency_ordered,omitempty"`
+	// Non-zero means the backup tables only hold a sample of one in sample_rate of the affected rows on average.
+	// The sample backups are for testing the backup pipeline and must not be restored.
+	SampleRate int32 `protobuf:"varint,5,opt,name=sample_rate,json=sampleRate,proto3" json:"sample_rate,omitempty"`
+	// The principal who initiated the data change. It's the system bot for the unattended changes.
+	// Format: users/{email}
+	Principal string `protobuf:"bytes,6,opt,name=principal,proto3" json:"principal,omitempty"`
+	// The backup exceeded the overall backup timeout and the remaining backup statements were aborted.
+	// The items only cover the backup tables created before the timeout.
+	TimedOut bool `protobuf:"varint,7,opt,name=timed_out,json=timedOut,proto3" json:"timed_out,omitempty"`
+	// The schema version of the task at backup time, so that the restores can detect the schema drift since the backup.
+	SchemaVersion string `protobuf:"bytes,8,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	// The position of the transaction log of the task database at backup time, for correlating the backup with the DML commit in the logs.
+	// It's the WAL LSN on Postgres, e.g. 0/16B3748, and the executed GTID set on MySQL. Empty if it's not captured.
+	LogPosition string `protobuf:"bytes,10,opt,name=log_position,json=logPosition,proto3" json:"log_position,omitempty"`
+	// The key of the versioned object in the object store holding the before-images published to the sink, one JSON message per row.
+	// Format: prior-backups/issues/{issue}/tasks/{task}/{time}.jsonl
+	ObjectKey string `protobuf:"bytes,9,opt,name=object_key,json=objectKey,proto3" json:"object_key,omitempty"`
+	// The tenant namespace prefixing the backup table names, so that the tenants sharing a backup database don't clash.
+	// It's the value of the namespace database label, or the project ID if the database doesn't have the label. Empty if not namespaced.
+	Namespace string `protobuf:"bytes,11,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// The warnings about the fidelity of the backup found before backing up, e.g. the backup database version lacks features of the source database version.
+	Warnings []string `protobuf:"bytes,12,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	// The stage of the task, so that the backups created in a stage can be collected and restored together.
+	// Format: projects/{project}/rollouts/{rollout}/stages/{stage}
+	Stage string `protobuf:"bytes,13,opt,name=stage,proto3" json:"stage,omitempty"`
+	// Non-zero means the backup tables only hold the first sample_limit affected rows ordered by the primary key,
+	// or the last ones if sample_from_last. The sample backups are for audits and must not be restored.
+	SampleLimit    int32 `protobuf:"varint,14,opt,name=sample_limit,json=sampleLimit,proto3" json:"sample_limit,omitempty"`
+	SampleFromLast bool  `protobuf:"varint,15,opt,name=sample_from_last,json=sampleFromLast,proto3" json:"sample_from_last,omitempty"`
+	// What happens to the backup tables after the data update succeeds.
+	PostSuccessPolicy PriorBackupPostSuccessPolicy `protobuf:"varint,16,opt,name=post_success_policy,json=postSuccessPolicy,proto3,enum=bytebase.store.PriorBackupPostSuccessPolicy" json:"post_success_policy,omitempty"`
+	// The time after which the retention job drops the backup tables by the post-success policy.
+	// Only set after the data update succeeds with the DROP_AFTER or DROP_IMMEDIATELY policy.
+	DropTime *timestamppb.Timestamp `protobuf:"bytes,17,opt,name=drop_time,json=dropTime,proto3" json:"drop_time,omitempty"`
+	// The session settings of the task database at backup time affecting the temporal values and the DML, keyed by the setting names,
+	// e.g. sql_mode and time_zone on MySQL, and the NLS parameters and TIME_ZONE on Oracle. The restore statements apply them first,
+	// so that the backed up rows are restored as they were backed up. Empty if they're not captured.
+	SessionSettings map[string]string `protobuf:"bytes,18,rep,name=session_settings,json=sessionSettings,proto3" json:"session_settings,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Set if the task database was backed up by the native snapshot of the whole database instead of copying the rows
+	// into the backup tables, in which case there are no items for the task database.
+	DatabaseSnapshot *PriorBackupDetail_DatabaseSnapshot `protobuf:"bytes,19,opt,name=database_snapshot,json=databaseSnapshot,proto3" json:"database_snapshot,omitempty"`
+	// The time the backup completed. The backup is refreshed before the data update if more than the configured maximum gap
+	// has elapsed since then, e.g. the asynchronous backup waiting for the data update, since the concurrent changes meanwhile
+	// make the backup inconsistent with the rows the data update changes. Unset for the backups published to the sinks.
+	CompleteTime *timestamppb.Timestamp `protobuf:"bytes,20,opt,name=complete_time,json=completeTime,proto3" json:"complete_time,omitempty"`
+	// The key of the manifest in the object store listing the files of the rows exported instead of the backup tables.
+	ExportManifestKey string `protobuf:"bytes,21,opt,name=export_manifest_key,json=exportManifestKey,proto3" json:"export_manifest_key,omitempty"`
+	// The backup failed or timed out, and the data was updated without it by the BEST_EFFORT backup requirement.
+	// The items only cover the backup tables created before the failure, which must not be rolled back from.
+	Incomplete bool `protobuf:"varint,22,opt,name=incomplete,proto3" json:"incomplete,omitempty"`
+}
+
+func (x *PriorBackupDetail) Reset() {
+	*x = PriorBackupDetail{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_store_task_run_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PriorBackupDetail) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PriorBackupDetail) ProtoMessage() {}
+
+func (x *PriorBackupDetail) ProtoReflect() protoreflect.Message {
+	mi := &file_store_task_run_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PriorBackupDetail.ProtoReflect.Descriptor instead.
+func (*PriorBackupDetail) Descriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PriorBackupDetail) GetItems() []*PriorBackupDetail_Item {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail) GetIsolationLevel() string {
+	if x != nil {
+		return x.IsolationLevel
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail) GetEncryptionKey() string {
+	if x != nil {
+		return x.EncryptionKey
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail) GetDependencyOrdered() bool {
+	if x != nil {
+		return x.DependencyOrdered
+	}
+	return false
+}
+
+func (x *PriorBackupDetail) GetSampleRate() int32 {
+	if x != nil {
+		return x.SampleRate
+	}
+	return 0
+}
+
+func (x *PriorBackupDetail) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail) GetTimedOut() bool {
+	if x != nil {
+		return x.TimedOut
+	}
+	return false
+}
+
+func (x *PriorBackupDetail) GetSchemaVersion() string {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail) GetLogPosition() string {
+	if x != nil {
+		return x.LogPosition
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail) GetObjectKey() string {
+	if x != nil {
+		return x.ObjectKey
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail) GetSampleLimit() int32 {
+	if x != nil {
+		return x.SampleLimit
+	}
+	return 0
+}
+
+func (x *PriorBackupDetail) GetSampleFromLast() bool {
+	if x != nil {
+		return x.SampleFromLast
+	}
+	return false
+}
+
+func (x *PriorBackupDetail) GetPostSuccessPolicy() PriorBackupPostSuccessPolicy {
+	if x != nil {
+		return x.PostSuccessPolicy
+	}
+	return PriorBackupPostSuccessPolicy_POST_SUCCESS_POLICY_UNSPECIFIED
+}
+
+func (x *PriorBackupDetail) GetDropTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DropTime
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail) GetSessionSettings() map[string]string {
+	if x != nil {
+		return x.SessionSettings
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail) GetDatabaseSnapshot() *PriorBackupDetail_DatabaseSnapshot {
+	if x != nil {
+		return x.DatabaseSnapshot
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail) GetCompleteTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CompleteTime
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail) GetExportManifestKey() string {
+	if x != nil {
+		return x.ExportManifestKey
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail) GetIncomplete() bool {
+	if x != nil {
+		return x.Incomplete
+	}
+	return false
+}
+
+type SchedulerInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ReportTime   *timestamppb.Timestamp      `protobuf:"bytes,1,opt,name=report_time,json=reportTime,proto3" json:"report_time,omitempty"`
+	WaitingCause *SchedulerInfo_WaitingCause `protobuf:"bytes,2,opt,name=waiting_cause,json=waitingCause,proto3" json:"waiting_cause,omitempty"`
+}
+
+func (x *SchedulerInfo) Reset() {
+	*x = SchedulerInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_store_task_run_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SchedulerInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SchedulerInfo) ProtoMessage() {}
+
+func (x *SchedulerInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_store_task_run_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SchedulerInfo.ProtoReflect.Descriptor instead.
+func (*SchedulerInfo) Descriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SchedulerInfo) GetReportTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ReportTime
+	}
+	return nil
+}
+
+func (x *SchedulerInfo) GetWaitingCause() *SchedulerInfo_WaitingCause {
+	if x != nil {
+		return x.WaitingCause
+	}
+	return nil
+}
+
+// The following fields are used for error reporting.
+type TaskRunResult_Position struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Line   int32 `protobuf:"varint,1,opt,name=line,proto3" json:"line,omitempty"`
+	Column int32 `protobuf:"varint,2,opt,name=column,proto3" json:"column,omitempty"`
+}
+
+func (x *TaskRunResult_Position) Reset() {
+	*x = TaskRunResult_Position{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_store_task_run_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TaskRunResult_Position) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TaskRunResult_Position) ProtoMessage() {}
+
+func (x *TaskRunResult_Position) ProtoReflect() protoreflect.Message {
+	mi := &file_store_task_run_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TaskRunResult_Position.ProtoReflect.Descriptor instead.
+func (*TaskRunResult_Position) Descriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{0, 0}
+}
+
+func (x *TaskRunResult_Position) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *TaskRunResult_Position) GetColumn() int32 {
+	if x != nil {
+		return x.Column
+	}
+	return 0
+}
+
+type PriorBackupDetail_Item struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The original table information.
+	SourceTable *PriorBackupDetail_Item_Table `protobuf:"bytes,1,opt,name=source_table,json=sourceTable,proto3" json:"source_table,omitempty"`
+	// The target backup table information.
+	TargetTable   *PriorBackupDetail_Item_Table `protobuf:"bytes,2,opt,name=target_table,json=targetTable,proto3" json:"target_table,omitempty"`
+	StartPosition *Position                     `protobuf:"bytes,3,opt,name=start_position,json=startPosition,proto3" json:"start_position,omitempty"`
+	EndPosition   *Position                     `protobuf:"bytes,4,opt,name=end_position,json=endPosition,proto3" json:"end_position,omitempty"`
+	// The storage engine of the source table, e.g. InnoDB. Only set for MySQL and TiDB.
+	StorageEngine string `protobuf:"bytes,5,opt,name=storage_engine,json=storageEngine,proto3" json:"storage_engine,omitempty"`
+	// The tablespace of the source table. Only set for Oracle and Postgres, and empty for the default tablespace on Postgres.
+	Tablespace string `protobuf:"bytes,6,opt,name=tablespace,proto3" json:"tablespace,omitempty"`
+	// The source table was small enough that the backup table was created by the lightweight path,
+	// without the table comment and the issue comment.
+	Lightweight bool `protobuf:"varint,7,opt,name=lightweight,proto3" json:"lightweight,omitempty"`
+	// The sequences owned by the columns of the source table, including the identity sequences. Only set for Postgres.
+	// The restores keep the ownership and move the sequences past the restored values instead of recreating them.
+	OwnedSequences []*PriorBackupDetail_Item_OwnedSequence `protobuf:"bytes,8,rep,name=owned_sequences,json=ownedSequences,proto3" json:"owned_sequences,omitempty"`
+	// The strategy copying the rows into the backup table.
+	Strategy PriorBackupDetail_Item_Strategy `protobuf:"varint,9,opt,name=strategy,proto3,enum=bytebase.store.PriorBackupDetail_Item_Strategy" json:"strategy,omitempty"`
+	// The reason the strategy was selected, e.g. the estimated size of the source table.
+	StrategyReason string `protobuf:"bytes,22,opt,name=strategy_reason,json=strategyReason,proto3" json:"strategy_reason,omitempty"`
+	// The surrogate key in the bb_surrogate_key column of the backup table, which matches the rows unambiguously on restore.
+	// Unspecified means the backup table has no surrogate key column.
+	SurrogateKey PriorBackupDetail_Item_SurrogateKey `protobuf:"varint,10,opt,name=surrogate_key,json=surrogateKey,proto3,enum=bytebase.store.PriorBackupDetail_Item_SurrogateKey" json:"surrogate_key,omitempty"`
+	// The sink receiving the before-images of the source table. The target table is not set if the backup is published to a sink.
+	Sink *PriorBackupDetail_Item_Sink `protobuf:"bytes,11,opt,name=sink,proto3" json:"sink,omitempty"`
+	// The columns of the source table labeled as no-backup, e.g. the columns holding secrets.
+	// They are NULL in the backup table and must not be restored.
+	ExcludedColumns []string `protobuf:"bytes,12,rep,name=excluded_columns,json=excludedColumns,proto3" json:"excluded_columns,omitempty"`
+	// The range of the primary key of the rows in the backup table if the backup of the source table was split into multiple backup tables.
+	// The parts are consecutive items ordered by their ranges, which cover all the rows backed up by the statement.
+	Range *PriorBackupDetail_Item_Range `protobuf:"bytes,13,opt,name=range,proto3" json:"range,omitempty"`
+	// Non-empty means the whole source table was backed up instead of the affected rows,
+	// because the affected rows could not be determined with confidence for the reason.
+	FullTableReason string `protobuf:"bytes,14,opt,name=full_table_reason,json=fullTableReason,proto3" json:"full_table_reason,omitempty"`
+	// The identifier of the exported snapshot the backup statement read the source table in, e.g. 00000003-0000001B-1.
+	// Only set for Postgres if the backup statements of the database imported one snapshot exported by pg_export_snapshot.
+	Snapshot string `protobuf:"bytes,15,opt,name=snapshot,proto3" json:"snapshot,omitempty"`
+	// The number of rows copied into the backup table. Unset if it's not counted, e.g. the backup was deferred to the data update transaction.
+	RowCount *int64 `protobuf:"varint,16,opt,name=row_count,json=rowCount,proto3,oneof" json:"row_count,omitempty"`
+	// The partitions of the source table by the synced schema at backup time, so that the restores can route the rows to the right partitions.
+	// The backup table itself is not partitioned. Empty if the source table is not partitioned.
+	Partitions []*TablePartitionMetadata `protobuf:"bytes,17,rep,name=partitions,proto3" json:"partitions,omitempty"`
+	// The companion table with the new values of the backed up rows computed by the SET clause of the UPDATE,
+	// so that the reviewers can diff the backup table with it. Only set if the after-image is enabled and supported.
+	AfterImageTable *PriorBackupDetail_Item_Table `protobuf:"bytes,18,opt,name=after_image_table,json=afterImageTable,proto3" json:"after_image_table,omitempty"`
+	// The check constraints of the source table by the synced schema at backup time, so that the restores can warn about
+	// the constraints added or changed since the backup, which may reject the backed up rows.
+	CheckConstraints []*CheckConstraintMetadata `protobuf:"bytes,19,rep,name=check_constraints,json=checkConstraints,proto3" json:"check_constraints,omitempty"`
+	// Non-empty means the backup table was not tagged by the table comment for the reason,
+	// e.g. an online DDL tool such as gh-ost was operating on the backup table.
+	TableCommentSkipReason string `protobuf:"bytes,20,opt,name=table_comment_skip_reason,json=tableCommentSkipReason,proto3" json:"table_comment_skip_reason,omitempty"`
+	// The statement restoring the backed up rows into the source table, so that the restore plan can be reviewed without running it.
+	// Empty if the restore statement is not supported for the engine or the statement, e.g. the sample backups or the procedure calls.
+	RestoreStatement string `protobuf:"bytes,21,opt,name=restore_statement,json=restoreStatement,proto3" json:"restore_statement,omitempty"`
+	// The columns of the source table with their defaults and collations at backup time, so that the restores supply the defaults
+	// at backup time explicitly for the columns without backed up values instead of relying on the current defaults,
+	// and refuse to restore into the columns whose collations have changed since the backup.
+	Columns []*PriorBackupDetail_Item_Column `protobuf:"bytes,23,rep,name=columns,proto3" json:"columns,omitempty"`
+	// The version or timestamp column of the source table, e.g. version or updated_at, so that the restores only overwrite
+	// the rows not changed since the backup. Empty if the source table has none of the configured version columns.
+	VersionColumn string `protobuf:"bytes,24,opt,name=version_column,json=versionColumn,proto3" json:"version_column,omitempty"`
+	// The partition of the archive table the backed up rows were appended to for the long-term retention,
+	// so that the retention job drops the whole partition once all its rows are past the retention. Unset if the rows were not archived.
+	ArchivePartition *PriorBackupDetail_Item_ArchivePartition `protobuf:"bytes,25,opt,name=archive_partition,json=archivePartition,proto3" json:"archive_partition,omitempty"`
+	// The key of the gzip-compressed CSV file in the object store holding the rows exported instead of the backup table.
+	ObjectKey string `protobuf:"bytes,26,opt,name=object_key,json=objectKey,proto3" json:"object_key,omitempty"`
+	// Set if the backup table was created as an Oracle immutable table, which cannot be dropped until it has been idle
+	// for the retention days. The post-success policy, the cleanups and the retention job keep the backup table until then.
+	ImmutableUntil *timestamppb.Timestamp `protobuf:"bytes,27,opt,name=immutable_until,json=immutableUntil,proto3" json:"immutable_until,omitempty"`
+}
+
+func (x *PriorBackupDetail_Item) Reset() {
+	*x = PriorBackupDetail_Item{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_store_task_run_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PriorBackupDetail_Item) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PriorBackupDetail_Item) ProtoMessage() {}
+
+func (x *PriorBackupDetail_Item) ProtoReflect() protoreflect.Message {
+	mi := &file_store_task_run_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PriorBackupDetail_Item.ProtoReflect.Descriptor instead.
+func (*PriorBackupDetail_Item) Descriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{1, 0}
+}
+
+func (x *PriorBackupDetail_Item) GetSourceTable() *PriorBackupDetail_Item_Table {
+	if x != nil {
+		return x.SourceTable
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetTargetTable() *PriorBackupDetail_Item_Table {
+	if x != nil {
+		return x.TargetTable
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetStartPosition() *Position {
+	if x != nil {
+		return x.StartPosition
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetEndPosition() *Position {
+	if x != nil {
+		return x.EndPosition
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetStorageEngine() string {
+	if x != nil {
+		return x.StorageEngine
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item) GetTablespace() string {
+	if x != nil {
+		return x.Tablespace
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item) GetLightweight() bool {
+	if x != nil {
+		return x.Lightweight
+	}
+	return false
+}
+
+func (x *PriorBackupDetail_Item) GetOwnedSequences() []*PriorBackupDetail_Item_OwnedSequence {
+	if x != nil {
+		return x.OwnedSequences
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetStrategy() PriorBackupDetail_Item_Strategy {
+	if x != nil {
+		return x.Strategy
+	}
+	return PriorBackupDetail_Item_STRATEGY_UNSPECIFIED
+}
+
+func (x *PriorBackupDetail_Item) GetStrategyReason() string {
+	if x != nil {
+		return x.StrategyReason
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item) GetSurrogateKey() PriorBackupDetail_Item_SurrogateKey {
+	if x != nil {
+		return x.SurrogateKey
+	}
+	return PriorBackupDetail_Item_SURROGATE_KEY_UNSPECIFIED
+}
+
+func (x *PriorBackupDetail_Item) GetSink() *PriorBackupDetail_Item_Sink {
+	if x != nil {
+		return x.Sink
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetExcludedColumns() []string {
+	if x != nil {
+		return x.ExcludedColumns
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetRange() *PriorBackupDetail_Item_Range {
+	if x != nil {
+		return x.Range
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetFullTableReason() string {
+	if x != nil {
+		return x.FullTableReason
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item) GetSnapshot() string {
+	if x != nil {
+		return x.Snapshot
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item) GetRowCount() int64 {
+	if x != nil && x.RowCount != nil {
+		return *x.RowCount
+	}
+	return 0
+}
+
+func (x *PriorBackupDetail_Item) GetPartitions() []*TablePartitionMetadata {
+	if x != nil {
+		return x.Partitions
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetAfterImageTable() *PriorBackupDetail_Item_Table {
+	if x != nil {
+		return x.AfterImageTable
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetCheckConstraints() []*CheckConstraintMetadata {
+	if x != nil {
+		return x.CheckConstraints
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetTableCommentSkipReason() string {
+	if x != nil {
+		return x.TableCommentSkipReason
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item) GetRestoreStatement() string {
+	if x != nil {
+		return x.RestoreStatement
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item) GetColumns() []*PriorBackupDetail_Item_Column {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetVersionColumn() string {
+	if x != nil {
+		return x.VersionColumn
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item) GetArchivePartition() *PriorBackupDetail_Item_ArchivePartition {
+	if x != nil {
+		return x.ArchivePartition
+	}
+	return nil
+}
+
+func (x *PriorBackupDetail_Item) GetObjectKey() string {
+	if x != nil {
+		return x.ObjectKey
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item) GetImmutableUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ImmutableUntil
+	}
+	return nil
+}
+
+type PriorBackupDetail_DatabaseSnapshot struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The database cloned by the snapshot.
+	// Format: instances/{instance}/databases/{database}
+	Database string `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	// The identifier of the snapshot returned by the instance or its storage, e.g. the name of the Aurora clone cluster.
+	Snapshot string `protobuf:"bytes,2,opt,name=snapshot,proto3" json:"snapshot,omitempty"`
+	// The reason the snapshot was selected, e.g. the estimated number of the affected rows.
+	Reason string `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+}
+
+func (x *PriorBackupDetail_DatabaseSnapshot) Reset() {
+	*x = PriorBackupDetail_DatabaseSnapshot{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_store_task_run_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PriorBackupDetail_DatabaseSnapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PriorBackupDetail_DatabaseSnapshot) ProtoMessage() {}
+
+func (x *PriorBackupDetail_DatabaseSnapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_store_task_run_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PriorBackupDetail_DatabaseSnapshot.ProtoReflect.Descriptor instead.
+func (*PriorBackupDetail_DatabaseSnapshot) Descriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{1, 2}
+}
+
+func (x *PriorBackupDetail_DatabaseSnapshot) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_DatabaseSnapshot) GetSnapshot() string {
+	if x != nil {
+		return x.Snapshot
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_DatabaseSnapshot) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type PriorBackupDetail_Item_Table struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The database information.
+	// Format: instances/{instance}/databases/{database}
+	Database string `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Schema   string `protobuf:"bytes,2,opt,name=schema,proto3" json:"schema,omitempty"`
+	Table    string `protobuf:"bytes,3,opt,name=table,proto3" json:"table,omitempty"`
+}
+
+func (x *PriorBackupDetail_Item_Table) Reset() {
+	*x = PriorBackupDetail_Item_Table{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_store_task_run_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PriorBackupDetail_Item_Table) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PriorBackupDetail_Item_Table) ProtoMessage() {}
+
+func (x *PriorBackupDetail_Item_Table) ProtoReflect() protoreflect.Message {
+	mi := &file_store_task_run_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PriorBackupDetail_Item_Table.ProtoReflect.Descriptor instead.
+func (*PriorBackupDetail_Item_Table) Descriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{1, 0, 0}
+}
+
+func (x *PriorBackupDetail_Item_Table) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item_Table) GetSchema() string {
 	if x != nil {
-		return x.ExportArchiveUid
+		return x.Schema
 	}
-	return 0
+	return ""
 }
 
-func (x *TaskRunResult) GetPriorBackupDetail() *PriorBackupDetail {
+func (x *PriorBackupDetail_Item_Table) GetTable() string {
 	if x != nil {
-		return x.PriorBackupDetail
+		return x.Table
 	}
-	return nil
+	return ""
 }
 
-type PriorBackupDetail struct {
+type PriorBackupDetail_Item_OwnedSequence struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Items []*PriorBackupDetail_Item `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	// The column owning the sequence.
+	Column string `protobuf:"bytes,1,opt,name=column,proto3" json:"column,omitempty"`
+	// The schema of the sequence.
+	Schema string `protobuf:"bytes,2,opt,name=schema,proto3" json:"schema,omitempty"`
+	// The name of the sequence.
+	Sequence string `protobuf:"bytes,3,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// The identity generation of the column, ALWAYS or BY DEFAULT. Empty for the serial columns.
+	IdentityGeneration string `protobuf:"bytes,4,opt,name=identity_generation,json=identityGeneration,proto3" json:"identity_generation,omitempty"`
 }
 
-func (x *PriorBackupDetail) Reset() {
-	*x = PriorBackupDetail{}
+func (x *PriorBackupDetail_Item_OwnedSequence) Reset() {
+	*x = PriorBackupDetail_Item_OwnedSequence{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_store_task_run_proto_msgTypes[1]
+		mi := &file_store_task_run_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *PriorBackupDetail) String() string {
+func (x *PriorBackupDetail_Item_OwnedSequence) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PriorBackupDetail) ProtoMessage() {}
+func (*PriorBackupDetail_Item_OwnedSequence) ProtoMessage() {}
 
-func (x *PriorBackupDetail) ProtoReflect() protoreflect.Message {
-	mi := &file_store_task_run_proto_msgTypes[1]
+func (x *PriorBackupDetail_Item_OwnedSequence) ProtoReflect() protoreflect.Message {
+	mi := &file_store_task_run_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -154,44 +1089,68 @@ func (x *PriorBackupDetail) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PriorBackupDetail.ProtoReflect.Descriptor instead.
-func (*PriorBackupDetail) Descriptor() ([]byte, []int) {
-	return file_store_task_run_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use PriorBackupDetail_Item_OwnedSequence.ProtoReflect.Descriptor instead.
+func (*PriorBackupDetail_Item_OwnedSequence) Descriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{1, 0, 1}
 }
 
-func (x *PriorBackupDetail) GetItems() []*PriorBackupDetail_Item {
+func (x *PriorBackupDetail_Item_OwnedSequence) GetColumn() string {
 	if x != nil {
-		return x.Items
+		return x.Column
 	}
-	return nil
+	return ""
 }
 
-type SchedulerInfo struct {
+func (x *PriorBackupDetail_Item_OwnedSequence) GetSchema() string {
+	if x != nil {
+		return x.Schema
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item_OwnedSequence) GetSequence() string {
+	if x != nil {
+		return x.Sequence
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item_OwnedSequence) GetIdentityGeneration() string {
+	if x != nil {
+		return x.IdentityGeneration
+	}
+	return ""
+}
+
+type PriorBackupDetail_Item_Sink struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	ReportTime   *timestamppb.Timestamp      `protobuf:"bytes,1,opt,name=report_time,json=reportTime,proto3" json:"report_time,omitempty"`
-	WaitingCause *SchedulerInfo_WaitingCause `protobuf:"bytes,2,opt,name=waiting_cause,json=waitingCause,proto3" json:"waiting_cause,omitempty"`
+	// The topic receiving the before-images.
+	Topic string `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	// The offsets of the first and the last before-images of the source table. Both are -1 if no rows were affected.
+	StartOffset int64 `protobuf:"varint,2,opt,name=start_offset,json=startOffset,proto3" json:"start_offset,omitempty"`
+	EndOffset   int64 `protobuf:"varint,3,opt,name=end_offset,json=endOffset,proto3" json:"end_offset,omitempty"`
 }
 
-func (x *SchedulerInfo) Reset() {
-	*x = SchedulerInfo{}
+func (x *PriorBackupDetail_Item_Sink) Reset() {
+	*x = PriorBackupDetail_Item_Sink{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_store_task_run_proto_msgTypes[2]
+		mi := &file_store_task_run_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *SchedulerInfo) String() string {
+func (x *PriorBackupDetail_Item_Sink) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SchedulerInfo) ProtoMessage() {}
+func (*PriorBackupDetail_Item_Sink) ProtoMessage() {}
 
-func (x *SchedulerInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_store_task_run_proto_msgTypes[2]
+func (x *PriorBackupDetail_Item_Sink) ProtoReflect() protoreflect.Message {
+	mi := &file_store_task_run_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -202,52 +1161,65 @@ func (x *SchedulerInfo) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SchedulerInfo.ProtoReflect.Descriptor instead.
-func (*SchedulerInfo) Descriptor() ([]byte, []int) {
-	return file_store_task_run_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use PriorBackupDetail_Item_Sink.ProtoReflect.Descriptor instead.
+func (*PriorBackupDetail_Item_Sink) Descriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{1, 0, 2}
 }
 
-func (x *SchedulerInfo) GetReportTime() *timestamppb.Timestamp {
+func (x *PriorBackupDetail_Item_Sink) GetTopic() string {
 	if x != nil {
-		return x.ReportTime
+		return x.Topic
 	}
-	return nil
+	return ""
 }
 
-func (x *SchedulerInfo) GetWaitingCause() *SchedulerInfo_WaitingCause {
+func (x *PriorBackupDetail_Item_Sink) GetStartOffset() int64 {
 	if x != nil {
-		return x.WaitingCause
+		return x.StartOffset
 	}
-	return nil
+	return 0
 }
 
-// The following fields are used for error reporting.
-type TaskRunResult_Position struct {
+func (x *PriorBackupDetail_Item_Sink) GetEndOffset() int64 {
+	if x != nil {
+		return x.EndOffset
+	}
+	return 0
+}
+
+type PriorBackupDetail_Item_Range struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Line   int32 `protobuf:"varint,1,opt,name=line,proto3" json:"line,omitempty"`
-	Column int32 `protobuf:"varint,2,opt,name=column,proto3" json:"column,omitempty"`
+	// The single-column primary key or unique key of the source table splitting the backup.
+	Column string `protobuf:"bytes,1,opt,name=column,proto3" json:"column,omitempty"`
+	// The inclusive lower bound of the primary key in text. Unset means unbounded.
+	Start *string `protobuf:"bytes,2,opt,name=start,proto3,oneof" json:"start,omitempty"`
+	// The exclusive upper bound of the primary key in text. Unset means unbounded.
+	End *string `protobuf:"bytes,3,opt,name=end,proto3,oneof" json:"end,omitempty"`
+	// The part also has the rows whose column is NULL, which is the first part on the engines sorting NULLs first
+	// and the last part on the others, so that the parts follow the order of the column.
+	Nulls bool `protobuf:"varint,4,opt,name=nulls,proto3" json:"nulls,omitempty"`
 }
 
-func (x *TaskRunResult_Position) Reset() {
-	*x = TaskRunResult_Position{}
+func (x *PriorBackupDetail_Item_Range) Reset() {
+	*x = PriorBackupDetail_Item_Range{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_store_task_run_proto_msgTypes[3]
+		mi := &file_store_task_run_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *TaskRunResult_Position) String() string {
+func (x *PriorBackupDetail_Item_Range) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TaskRunResult_Position) ProtoMessage() {}
+func (*PriorBackupDetail_Item_Range) ProtoMessage() {}
 
-func (x *TaskRunResult_Position) ProtoReflect() protoreflect.Message {
-	mi := &file_store_task_run_proto_msgTypes[3]
+func (x *PriorBackupDetail_Item_Range) ProtoReflect() protoreflect.Message {
+	mi := &file_store_task_run_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -258,55 +1230,69 @@ func (x *TaskRunResult_Position) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TaskRunResult_Position.ProtoReflect.Descriptor instead.
-func (*TaskRunResult_Position) Descriptor() ([]byte, []int) {
-	return file_store_task_run_proto_rawDescGZIP(), []int{0, 0}
+// Deprecated: Use PriorBackupDetail_Item_Range.ProtoReflect.Descriptor instead.
+func (*PriorBackupDetail_Item_Range) Descriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{1, 0, 3}
 }
 
-func (x *TaskRunResult_Position) GetLine() int32 {
+func (x *PriorBackupDetail_Item_Range) GetColumn() string {
 	if x != nil {
-		return x.Line
+		return x.Column
 	}
-	return 0
+	return ""
 }
 
-func (x *TaskRunResult_Position) GetColumn() int32 {
+func (x *PriorBackupDetail_Item_Range) GetStart() string {
+	if x != nil && x.Start != nil {
+		return *x.Start
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item_Range) GetEnd() string {
+	if x != nil && x.End != nil {
+		return *x.End
+	}
+	return ""
+}
+
+func (x *PriorBackupDetail_Item_Range) GetNulls() bool {
 	if x != nil {
-		return x.Column
+		return x.Nulls
 	}
-	return 0
+	return false
 }
 
-type PriorBackupDetail_Item struct {
+type PriorBackupDetail_Item_Column struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	// The original table information.
-	SourceTable *PriorBackupDetail_Item_Table `protobuf:"bytes,1,opt,name=source_table,json=sourceTable,proto3" json:"source_table,omitempty"`
-	// The target backup table information.
-	TargetTable   *PriorBackupDetail_Item_Table `protobuf:"bytes,2,opt,name=target_table,json=targetTable,proto3" json:"target_table,omitempty"`
-	StartPosition *Position                     `protobuf:"bytes,3,opt,name=start_position,json=startPosition,proto3" json:"start_position,omitempty"`
-	EndPosition   *Position                     `protobuf:"bytes,4,opt,name=end_position,json=endPosition,proto3" json:"end_position,omitempty"`
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The default expression of the column, e.g. now() or 'x'::text. Empty means no default.
+	Default string `protobuf:"bytes,2,opt,name=default,proto3" json:"default,omitempty"`
+	// The explicit collation of the column, e.g. C. Empty means the default collation of the database.
+	// The backup table columns inherit the collations of the source columns.
+	Collation string `protobuf:"bytes,3,opt,name=collation,proto3" json:"collation,omitempty"`
 }
 
-func (x *PriorBackupDetail_Item) Reset() {
-	*x = PriorBackupDetail_Item{}
+func (x *PriorBackupDetail_Item_Column) Reset() {
+	*x = PriorBackupDetail_Item_Column{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_store_task_run_proto_msgTypes[4]
+		mi := &file_store_task_run_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *PriorBackupDetail_Item) String() string {
+func (x *PriorBackupDetail_Item_Column) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PriorBackupDetail_Item) ProtoMessage() {}
+func (*PriorBackupDetail_Item_Column) ProtoMessage() {}
 
-func (x *PriorBackupDetail_Item) ProtoReflect() protoreflect.Message {
-	mi := &file_store_task_run_proto_msgTypes[4]
+func (x *PriorBackupDetail_Item_Column) ProtoReflect() protoreflect.Message {
+	mi := &file_store_task_run_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -317,68 +1303,63 @@ func (x *PriorBackupDetail_Item) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PriorBackupDetail_Item.ProtoReflect.Descriptor instead.
-func (*PriorBackupDetail_Item) Descriptor() ([]byte, []int) {
-	return file_store_task_run_proto_rawDescGZIP(), []int{1, 0}
-}
-
-func (x *PriorBackupDetail_Item) GetSourceTable() *PriorBackupDetail_Item_Table {
-	if x != nil {
-		return x.SourceTable
-	}
-	return nil
+// Deprecated: Use PriorBackupDetail_Item_Column.ProtoReflect.Descriptor instead.
+func (*PriorBackupDetail_Item_Column) Descriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{1, 0, 4}
 }
 
-func (x *PriorBackupDetail_Item) GetTargetTable() *PriorBackupDetail_Item_Table {
+func (x *PriorBackupDetail_Item_Column) GetName() string {
 	if x != nil {
-		return x.TargetTable
+		return x.Name
 	}
-	return nil
+	return ""
 }
 
-func (x *PriorBackupDetail_Item) GetStartPosition() *Position {
+func (x *PriorBackupDetail_Item_Column) GetDefault() string {
 	if x != nil {
-		return x.StartPosition
+		return x.Default
 	}
-	return nil
+	return ""
 }
 
-func (x *PriorBackupDetail_Item) GetEndPosition() *Position {
+func (x *PriorBackupDetail_Item_Column) GetCollation() string {
 	if x != nil {
-		return x.EndPosition
+		return x.Collation
 	}
-	return nil
+	return ""
 }
 
-type PriorBackupDetail_Item_Table struct {
+type PriorBackupDetail_Item_ArchivePartition struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	// The database information.
-	// Format: instances/{instance}/databases/{database}
-	Database string `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
-	Schema   string `protobuf:"bytes,2,opt,name=schema,proto3" json:"schema,omitempty"`
-	Table    string `protobuf:"bytes,3,opt,name=table,proto3" json:"table,omitempty"`
+	// The monthly-partitioned archive table in the backup database, which the backed up rows of the source table are appended to.
+	Table *PriorBackupDetail_Item_Table `protobuf:"bytes,1,opt,name=table,proto3" json:"table,omitempty"`
+	// The partition of the archive table, e.g. p202401 on MySQL, or the partition table archive_public_t_p202401 on Postgres.
+	Partition string `protobuf:"bytes,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	// The range of the backup times in the partition, inclusive of the start time and exclusive of the end time.
+	StartTime *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime   *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
 }
 
-func (x *PriorBackupDetail_Item_Table) Reset() {
-	*x = PriorBackupDetail_Item_Table{}
+func (x *PriorBackupDetail_Item_ArchivePartition) Reset() {
+	*x = PriorBackupDetail_Item_ArchivePartition{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_store_task_run_proto_msgTypes[5]
+		mi := &file_store_task_run_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *PriorBackupDetail_Item_Table) String() string {
+func (x *PriorBackupDetail_Item_ArchivePartition) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PriorBackupDetail_Item_Table) ProtoMessage() {}
+func (*PriorBackupDetail_Item_ArchivePartition) ProtoMessage() {}
 
-func (x *PriorBackupDetail_Item_Table) ProtoReflect() protoreflect.Message {
-	mi := &file_store_task_run_proto_msgTypes[5]
+func (x *PriorBackupDetail_Item_ArchivePartition) ProtoReflect() protoreflect.Message {
+	mi := &file_store_task_run_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -389,30 +1370,37 @@ func (x *PriorBackupDetail_Item_Table) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PriorBackupDetail_Item_Table.ProtoReflect.Descriptor instead.
-func (*PriorBackupDetail_Item_Table) Descriptor() ([]byte, []int) {
-	return file_store_task_run_proto_rawDescGZIP(), []int{1, 0, 0}
+// Deprecated: Use PriorBackupDetail_Item_ArchivePartition.ProtoReflect.Descriptor instead.
+func (*PriorBackupDetail_Item_ArchivePartition) Descriptor() ([]byte, []int) {
+	return file_store_task_run_proto_rawDescGZIP(), []int{1, 0, 5}
 }
 
-func (x *PriorBackupDetail_Item_Table) GetDatabase() string {
+func (x *PriorBackupDetail_Item_ArchivePartition) GetTable() *PriorBackupDetail_Item_Table {
 	if x != nil {
-		return x.Database
+		return x.Table
 	}
-	return ""
+	return nil
 }
 
-func (x *PriorBackupDetail_Item_Table) GetSchema() string {
+func (x *PriorBackupDetail_Item_ArchivePartition) GetPartition() string {
 	if x != nil {
-		return x.Schema
+		return x.Partition
 	}
 	return ""
 }
 
-func (x *PriorBackupDetail_Item_Table) GetTable() string {
+func (x *PriorBackupDetail_Item_ArchivePartition) GetStartTime() *timestamppb.Timestamp {
 	if x != nil {
-		return x.Table
+		return x.StartTime
 	}
-	return ""
+	return nil
+}
+
+func (x *PriorBackupDetail_Item_ArchivePartition) GetEndTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndTime
+	}
+	return nil
 }
 
 type SchedulerInfo_WaitingCause struct {
@@ -424,13 +1412,15 @@ type SchedulerInfo_WaitingCause struct {
 	//
 	//	*SchedulerInfo_WaitingCause_ConnectionLimit
 	//	*SchedulerInfo_WaitingCause_TaskUid
+	//	*SchedulerInfo_WaitingCause_PriorBackupBlackoutUntil
+	//	*SchedulerInfo_WaitingCause_PriorBackupInProgress
 	Cause isSchedulerInfo_WaitingCause_Cause `protobuf_oneof:"cause"`
 }
 
 func (x *SchedulerInfo_WaitingCause) Reset() {
 	*x = SchedulerInfo_WaitingCause{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_store_task_run_proto_msgTypes[6]
+		mi := &file_store_task_run_proto_msgTypes[13]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -443,7 +1433,7 @@ func (x *SchedulerInfo_WaitingCause) String() string {
 func (*SchedulerInfo_WaitingCause) ProtoMessage() {}
 
 func (x *SchedulerInfo_WaitingCause) ProtoReflect() protoreflect.Message {
-	mi := &file_store_task_run_proto_msgTypes[6]
+	mi := &file_store_task_run_proto_msgTypes[13]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -480,6 +1470,20 @@ func (x *SchedulerInfo_WaitingCause) GetTaskUid() int32 {
 	return 0
 }
 
+func (x *SchedulerInfo_WaitingCause) GetPriorBackupBlackoutUntil() *timestamppb.Timestamp {
+	if x, ok := x.GetCause().(*SchedulerInfo_WaitingCause_PriorBackupBlackoutUntil); ok {
+		return x.PriorBackupBlackoutUntil
+	}
+	return nil
+}
+
+func (x *SchedulerInfo_WaitingCause) GetPriorBackupInProgress() bool {
+	if x, ok := x.GetCause().(*SchedulerInfo_WaitingCause_PriorBackupInProgress); ok {
+		return x.PriorBackupInProgress
+	}
+	return false
+}
+
 type isSchedulerInfo_WaitingCause_Cause interface {
 	isSchedulerInfo_WaitingCause_Cause()
 }
@@ -492,10 +1496,24 @@ type SchedulerInfo_WaitingCause_TaskUid struct {
 	TaskUid int32 `protobuf:"varint,2,opt,name=task_uid,json=taskUid,proto3,oneof"`
 }
 
+type SchedulerInfo_WaitingCause_PriorBackupBlackoutUntil struct {
+	// The task run is deferred until the end of the blackout window of the prior backup.
+	PriorBackupBlackoutUntil *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=prior_backup_blackout_until,json=priorBackupBlackoutUntil,proto3,oneof"`
+}
+
+type SchedulerInfo_WaitingCause_PriorBackupInProgress struct {
+	// The task run is waiting for its asynchronous prior backup to complete.
+	PriorBackupInProgress bool `protobuf:"varint,4,opt,name=prior_backup_in_progress,json=priorBackupInProgress,proto3,oneof"`
+}
+
 func (*SchedulerInfo_WaitingCause_ConnectionLimit) isSchedulerInfo_WaitingCause_Cause() {}
 
 func (*SchedulerInfo_WaitingCause_TaskUid) isSchedulerInfo_WaitingCause_Cause() {}
 
+func (*SchedulerInfo_WaitingCause_PriorBackupBlackoutUntil) isSchedulerInfo_WaitingCause_Cause() {}
+
+func (*SchedulerInfo_WaitingCause_PriorBackupInProgress) isSchedulerInfo_WaitingCause_Cause() {}
+
 var File_store_task_run_proto protoreflect.FileDescriptor
 
 var file_store_task_run_proto_rawDesc = []byte{
@@ -504,82 +1522,309 @@ var file_store_task_run_proto_rawDesc = []byte{
 	0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70,
 	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
 	0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x12, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2f, 0x63,
-	0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xbb, 0x03, 0x0a, 0x0d,
-	0x54, 0x61, 0x73, 0x6b, 0x52, 0x75, 0x6e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x16, 0x0a,
-	0x06, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64,
-	0x65, 0x74, 0x61, 0x69, 0x6c, 0x12, 0x25, 0x0a, 0x0e, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x5f,
-	0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x63,
-	0x68, 0x61, 0x6e, 0x67, 0x65, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x18, 0x0a, 0x07,
-	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76,
-	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x4d, 0x0a, 0x0e, 0x73, 0x74, 0x61, 0x72, 0x74, 0x5f,
-	0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x26,
+	0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x14, 0x73, 0x74, 0x6f,
+	0x72, 0x65, 0x2f, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x22, 0xaa, 0x04, 0x0a, 0x0d, 0x54, 0x61, 0x73, 0x6b, 0x52, 0x75, 0x6e, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x12, 0x25, 0x0a, 0x0e, 0x63,
+	0x68, 0x61, 0x6e, 0x67, 0x65, 0x5f, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0d, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x48, 0x69, 0x73, 0x74, 0x6f,
+	0x72, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x4d, 0x0a, 0x0e,
+	0x73, 0x74, 0x61, 0x72, 0x74, 0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x26, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e,
+	0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x54, 0x61, 0x73, 0x6b, 0x52, 0x75, 0x6e, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x2e, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0d, 0x73, 0x74,
+	0x61, 0x72, 0x74, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x49, 0x0a, 0x0c, 0x65,
+	0x6e, 0x64, 0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x26, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f,
+	0x72, 0x65, 0x2e, 0x54, 0x61, 0x73, 0x6b, 0x52, 0x75, 0x6e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x2e, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x65, 0x6e, 0x64, 0x50, 0x6f,
+	0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x2c, 0x0a, 0x12, 0x65, 0x78, 0x70, 0x6f, 0x72, 0x74,
+	0x5f, 0x61, 0x72, 0x63, 0x68, 0x69, 0x76, 0x65, 0x5f, 0x75, 0x69, 0x64, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x10, 0x65, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x41, 0x72, 0x63, 0x68, 0x69, 0x76,
+	0x65, 0x55, 0x69, 0x64, 0x12, 0x51, 0x0a, 0x13, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x5f, 0x62, 0x61,
+	0x63, 0x6b, 0x75, 0x70, 0x5f, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x21, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f,
+	0x72, 0x65, 0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65,
+	0x74, 0x61, 0x69, 0x6c, 0x52, 0x11, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75,
+	0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x12, 0x23, 0x0a, 0x0d, 0x65, 0x78, 0x70, 0x6c, 0x61,
+	0x69, 0x6e, 0x5f, 0x70, 0x6c, 0x61, 0x6e, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0c,
+	0x65, 0x78, 0x70, 0x6c, 0x61, 0x69, 0x6e, 0x50, 0x6c, 0x61, 0x6e, 0x73, 0x12, 0x1a, 0x0a, 0x08,
+	0x77, 0x61, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08,
+	0x77, 0x61, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x2c, 0x0a, 0x12, 0x70, 0x72, 0x69, 0x6f,
+	0x72, 0x5f, 0x62, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x5f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x0a,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x10, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75,
+	0x70, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x1a, 0x36, 0x0a, 0x08, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x22, 0xdb,
+	0x1d, 0x0a, 0x11, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65,
+	0x74, 0x61, 0x69, 0x6c, 0x12, 0x3c, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x26, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73,
+	0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70,
+	0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x2e, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x05, 0x69, 0x74, 0x65,
+	0x6d, 0x73, 0x12, 0x27, 0x0a, 0x0f, 0x69, 0x73, 0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f,
+	0x6c, 0x65, 0x76, 0x65, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x69, 0x73, 0x6f,
+	0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x12, 0x25, 0x0a, 0x0e, 0x65,
+	0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0d, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x4b,
+	0x65, 0x79, 0x12, 0x2d, 0x0a, 0x12, 0x64, 0x65, 0x70, 0x65, 0x6e, 0x64, 0x65, 0x6e, 0x63, 0x79,
+	0x5f, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x11,
+	0x64, 0x65, 0x70, 0x65, 0x6e, 0x64, 0x65, 0x6e, 0x63, 0x79, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x65,
+	0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x72, 0x61, 0x74, 0x65,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x52, 0x61,
+	0x74, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x72, 0x69, 0x6e, 0x63, 0x69, 0x70, 0x61, 0x6c, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x72, 0x69, 0x6e, 0x63, 0x69, 0x70, 0x61, 0x6c,
+	0x12, 0x1b, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x64, 0x5f, 0x6f, 0x75, 0x74, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x08, 0x74, 0x69, 0x6d, 0x65, 0x64, 0x4f, 0x75, 0x74, 0x12, 0x25, 0x0a,
+	0x0e, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x56, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x21, 0x0a, 0x0c, 0x6c, 0x6f, 0x67, 0x5f, 0x70, 0x6f, 0x73, 0x69,
+	0x74, 0x69, 0x6f, 0x6e, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x6c, 0x6f, 0x67, 0x50,
+	0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x6f, 0x62, 0x6a, 0x65, 0x63,
+	0x74, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6f, 0x62, 0x6a,
+	0x65, 0x63, 0x74, 0x4b, 0x65, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70,
+	0x61, 0x63, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73,
+	0x70, 0x61, 0x63, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x77, 0x61, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x73,
+	0x18, 0x0c, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x77, 0x61, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x73,
+	0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x67, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x73, 0x74, 0x61, 0x67, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65,
+	0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x73, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x28, 0x0a, 0x10, 0x73, 0x61, 0x6d,
+	0x70, 0x6c, 0x65, 0x5f, 0x66, 0x72, 0x6f, 0x6d, 0x5f, 0x6c, 0x61, 0x73, 0x74, 0x18, 0x0f, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0e, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x46, 0x72, 0x6f, 0x6d, 0x4c,
+	0x61, 0x73, 0x74, 0x12, 0x5c, 0x0a, 0x13, 0x70, 0x6f, 0x73, 0x74, 0x5f, 0x73, 0x75, 0x63, 0x63,
+	0x65, 0x73, 0x73, 0x5f, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x2c, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72,
+	0x65, 0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x50, 0x6f, 0x73,
+	0x74, 0x53, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x11,
+	0x70, 0x6f, 0x73, 0x74, 0x53, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x50, 0x6f, 0x6c, 0x69, 0x63,
+	0x79, 0x12, 0x37, 0x0a, 0x09, 0x64, 0x72, 0x6f, 0x70, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x11,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x52, 0x08, 0x64, 0x72, 0x6f, 0x70, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x61, 0x0a, 0x10, 0x73, 0x65,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x12,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x36, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e,
+	0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75,
+	0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x2e, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x53,
+	0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0f, 0x73, 0x65,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x5f, 0x0a,
+	0x11, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x5f, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68,
+	0x6f, 0x74, 0x18, 0x13, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x32, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62,
+	0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x42,
+	0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x2e, 0x44, 0x61, 0x74, 0x61,
+	0x62, 0x61, 0x73, 0x65, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x52, 0x10, 0x64, 0x61,
+	0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x3f,
+	0x0a, 0x0d, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18,
+	0x14, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x52, 0x0c, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x12,
+	0x2e, 0x0a, 0x13, 0x65, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x5f, 0x6d, 0x61, 0x6e, 0x69, 0x66, 0x65,
+	0x73, 0x74, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x15, 0x20, 0x01, 0x28, 0x09, 0x52, 0x11, 0x65, 0x78,
+	0x70, 0x6f, 0x72, 0x74, 0x4d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74, 0x4b, 0x65, 0x79, 0x12,
+	0x1e, 0x0a, 0x0a, 0x69, 0x6e, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x18, 0x16, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0a, 0x69, 0x6e, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x1a,
+	0x92, 0x14, 0x0a, 0x04, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x4f, 0x0a, 0x0c, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x5f, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2c,
 	0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e,
-	0x54, 0x61, 0x73, 0x6b, 0x52, 0x75, 0x6e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x2e, 0x50, 0x6f,
-	0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0d, 0x73, 0x74, 0x61, 0x72, 0x74, 0x50, 0x6f, 0x73,
-	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x49, 0x0a, 0x0c, 0x65, 0x6e, 0x64, 0x5f, 0x70, 0x6f, 0x73,
-	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x26, 0x2e, 0x62, 0x79,
-	0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x54, 0x61, 0x73,
-	0x6b, 0x52, 0x75, 0x6e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x2e, 0x50, 0x6f, 0x73, 0x69, 0x74,
-	0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x65, 0x6e, 0x64, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e,
-	0x12, 0x2c, 0x0a, 0x12, 0x65, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x5f, 0x61, 0x72, 0x63, 0x68, 0x69,
-	0x76, 0x65, 0x5f, 0x75, 0x69, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x10, 0x65, 0x78,
-	0x70, 0x6f, 0x72, 0x74, 0x41, 0x72, 0x63, 0x68, 0x69, 0x76, 0x65, 0x55, 0x69, 0x64, 0x12, 0x51,
-	0x0a, 0x13, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x5f, 0x62, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x5f, 0x64,
-	0x65, 0x74, 0x61, 0x69, 0x6c, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x62, 0x79,
-	0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72, 0x69,
-	0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x52, 0x11,
-	0x70, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69,
-	0x6c, 0x1a, 0x36, 0x0a, 0x08, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a,
-	0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x6c, 0x69, 0x6e,
-	0x65, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x05, 0x52, 0x06, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x22, 0xcd, 0x03, 0x0a, 0x11, 0x50, 0x72,
-	0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x12,
-	0x3c, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x26,
+	0x50, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69,
+	0x6c, 0x2e, 0x49, 0x74, 0x65, 0x6d, 0x2e, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x52, 0x0b, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x4f, 0x0a, 0x0c, 0x74, 0x61, 0x72,
+	0x67, 0x65, 0x74, 0x5f, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x2c, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65,
+	0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61,
+	0x69, 0x6c, 0x2e, 0x49, 0x74, 0x65, 0x6d, 0x2e, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x52, 0x0b, 0x74,
+	0x61, 0x72, 0x67, 0x65, 0x74, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x3f, 0x0a, 0x0e, 0x73, 0x74,
+	0x61, 0x72, 0x74, 0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x18, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74,
+	0x6f, 0x72, 0x65, 0x2e, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0d, 0x73, 0x74,
+	0x61, 0x72, 0x74, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3b, 0x0a, 0x0c, 0x65,
+	0x6e, 0x64, 0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x18, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f,
+	0x72, 0x65, 0x2e, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x65, 0x6e, 0x64,
+	0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x25, 0x0a, 0x0e, 0x73, 0x74, 0x6f, 0x72,
+	0x61, 0x67, 0x65, 0x5f, 0x65, 0x6e, 0x67, 0x69, 0x6e, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0d, 0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x45, 0x6e, 0x67, 0x69, 0x6e, 0x65, 0x12,
+	0x1e, 0x0a, 0x0a, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0a, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12,
+	0x20, 0x0a, 0x0b, 0x6c, 0x69, 0x67, 0x68, 0x74, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x07,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x6c, 0x69, 0x67, 0x68, 0x74, 0x77, 0x65, 0x69, 0x67, 0x68,
+	0x74, 0x12, 0x5d, 0x0a, 0x0f, 0x6f, 0x77, 0x6e, 0x65, 0x64, 0x5f, 0x73, 0x65, 0x71, 0x75, 0x65,
+	0x6e, 0x63, 0x65, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x34, 0x2e, 0x62, 0x79, 0x74,
+	0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72, 0x69, 0x6f,
+	0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x2e, 0x49, 0x74,
+	0x65, 0x6d, 0x2e, 0x4f, 0x77, 0x6e, 0x65, 0x64, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65,
+	0x52, 0x0e, 0x6f, 0x77, 0x6e, 0x65, 0x64, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x73,
+	0x12, 0x4b, 0x0a, 0x08, 0x73, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x79, 0x18, 0x09, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x2f, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74,
+	0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44,
+	0x65, 0x74, 0x61, 0x69, 0x6c, 0x2e, 0x49, 0x74, 0x65, 0x6d, 0x2e, 0x53, 0x74, 0x72, 0x61, 0x74,
+	0x65, 0x67, 0x79, 0x52, 0x08, 0x73, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x79, 0x12, 0x27, 0x0a,
+	0x0f, 0x73, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x79, 0x5f, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e,
+	0x18, 0x16, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x73, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x79,
+	0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x58, 0x0a, 0x0d, 0x73, 0x75, 0x72, 0x72, 0x6f, 0x67,
+	0x61, 0x74, 0x65, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x33, 0x2e,
+	0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50,
+	0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c,
+	0x2e, 0x49, 0x74, 0x65, 0x6d, 0x2e, 0x53, 0x75, 0x72, 0x72, 0x6f, 0x67, 0x61, 0x74, 0x65, 0x4b,
+	0x65, 0x79, 0x52, 0x0c, 0x73, 0x75, 0x72, 0x72, 0x6f, 0x67, 0x61, 0x74, 0x65, 0x4b, 0x65, 0x79,
+	0x12, 0x3f, 0x0a, 0x04, 0x73, 0x69, 0x6e, 0x6b, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2b,
 	0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e,
 	0x50, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69,
-	0x6c, 0x2e, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x1a, 0xf9, 0x02,
-	0x0a, 0x04, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x4f, 0x0a, 0x0c, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65,
-	0x5f, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x62,
-	0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72,
-	0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x2e,
-	0x49, 0x74, 0x65, 0x6d, 0x2e, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x52, 0x0b, 0x73, 0x6f, 0x75, 0x72,
-	0x63, 0x65, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x4f, 0x0a, 0x0c, 0x74, 0x61, 0x72, 0x67, 0x65,
-	0x74, 0x5f, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2c, 0x2e,
+	0x6c, 0x2e, 0x49, 0x74, 0x65, 0x6d, 0x2e, 0x53, 0x69, 0x6e, 0x6b, 0x52, 0x04, 0x73, 0x69, 0x6e,
+	0x6b, 0x12, 0x29, 0x0a, 0x10, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x64, 0x5f, 0x63, 0x6f,
+	0x6c, 0x75, 0x6d, 0x6e, 0x73, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0f, 0x65, 0x78, 0x63,
+	0x6c, 0x75, 0x64, 0x65, 0x64, 0x43, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x73, 0x12, 0x42, 0x0a, 0x05,
+	0x72, 0x61, 0x6e, 0x67, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x62, 0x79,
+	0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72, 0x69,
+	0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x2e, 0x49,
+	0x74, 0x65, 0x6d, 0x2e, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x05, 0x72, 0x61, 0x6e, 0x67, 0x65,
+	0x12, 0x2a, 0x0a, 0x11, 0x66, 0x75, 0x6c, 0x6c, 0x5f, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x72,
+	0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x66, 0x75, 0x6c,
+	0x6c, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08,
+	0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x20, 0x0a, 0x09, 0x72, 0x6f, 0x77, 0x5f,
+	0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x10, 0x20, 0x01, 0x28, 0x03, 0x48, 0x00, 0x52, 0x08, 0x72,
+	0x6f, 0x77, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x88, 0x01, 0x01, 0x12, 0x46, 0x0a, 0x0a, 0x70, 0x61,
+	0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x11, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x26,
+	0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e,
+	0x54, 0x61, 0x62, 0x6c, 0x65, 0x50, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x4d, 0x65,
+	0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x0a, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x12, 0x58, 0x0a, 0x11, 0x61, 0x66, 0x74, 0x65, 0x72, 0x5f, 0x69, 0x6d, 0x61, 0x67,
+	0x65, 0x5f, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x12, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2c, 0x2e,
 	0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50,
 	0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c,
-	0x2e, 0x49, 0x74, 0x65, 0x6d, 0x2e, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x52, 0x0b, 0x74, 0x61, 0x72,
-	0x67, 0x65, 0x74, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x3f, 0x0a, 0x0e, 0x73, 0x74, 0x61, 0x72,
-	0x74, 0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x18, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72,
-	0x65, 0x2e, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0d, 0x73, 0x74, 0x61, 0x72,
-	0x74, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3b, 0x0a, 0x0c, 0x65, 0x6e, 0x64,
-	0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x18, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65,
-	0x2e, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x65, 0x6e, 0x64, 0x50, 0x6f,
-	0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x1a, 0x51, 0x0a, 0x05, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x12,
-	0x1a, 0x0a, 0x08, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x08, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73,
-	0x63, 0x68, 0x65, 0x6d, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x63, 0x68,
-	0x65, 0x6d, 0x61, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x05, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x22, 0x80, 0x02, 0x0a, 0x0d, 0x53, 0x63,
-	0x68, 0x65, 0x64, 0x75, 0x6c, 0x65, 0x72, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x3b, 0x0a, 0x0b, 0x72,
-	0x65, 0x70, 0x6f, 0x72, 0x74, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
-	0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x72, 0x65,
-	0x70, 0x6f, 0x72, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x4f, 0x0a, 0x0d, 0x77, 0x61, 0x69, 0x74,
-	0x69, 0x6e, 0x67, 0x5f, 0x63, 0x61, 0x75, 0x73, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x2a, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65,
-	0x2e, 0x53, 0x63, 0x68, 0x65, 0x64, 0x75, 0x6c, 0x65, 0x72, 0x49, 0x6e, 0x66, 0x6f, 0x2e, 0x57,
-	0x61, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x43, 0x61, 0x75, 0x73, 0x65, 0x52, 0x0c, 0x77, 0x61, 0x69,
-	0x74, 0x69, 0x6e, 0x67, 0x43, 0x61, 0x75, 0x73, 0x65, 0x1a, 0x61, 0x0a, 0x0c, 0x57, 0x61, 0x69,
-	0x74, 0x69, 0x6e, 0x67, 0x43, 0x61, 0x75, 0x73, 0x65, 0x12, 0x2b, 0x0a, 0x10, 0x63, 0x6f, 0x6e,
-	0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x08, 0x48, 0x00, 0x52, 0x0f, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f,
-	0x6e, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x1b, 0x0a, 0x08, 0x74, 0x61, 0x73, 0x6b, 0x5f, 0x75,
-	0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x07, 0x74, 0x61, 0x73, 0x6b,
-	0x55, 0x69, 0x64, 0x42, 0x07, 0x0a, 0x05, 0x63, 0x61, 0x75, 0x73, 0x65, 0x42, 0x14, 0x5a, 0x12,
-	0x67, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x64, 0x2d, 0x67, 0x6f, 0x2f, 0x73, 0x74, 0x6f,
-	0x72, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x2e, 0x49, 0x74, 0x65, 0x6d, 0x2e, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x52, 0x0f, 0x61, 0x66, 0x74,
+	0x65, 0x72, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x54, 0x0a, 0x11,
+	0x63, 0x68, 0x65, 0x63, 0x6b, 0x5f, 0x63, 0x6f, 0x6e, 0x73, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x74,
+	0x73, 0x18, 0x13, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x27, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61,
+	0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x43, 0x6f,
+	0x6e, 0x73, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x74, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
+	0x52, 0x10, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x43, 0x6f, 0x6e, 0x73, 0x74, 0x72, 0x61, 0x69, 0x6e,
+	0x74, 0x73, 0x12, 0x39, 0x0a, 0x19, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x63, 0x6f, 0x6d, 0x6d,
+	0x65, 0x6e, 0x74, 0x5f, 0x73, 0x6b, 0x69, 0x70, 0x5f, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18,
+	0x14, 0x20, 0x01, 0x28, 0x09, 0x52, 0x16, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x43, 0x6f, 0x6d, 0x6d,
+	0x65, 0x6e, 0x74, 0x53, 0x6b, 0x69, 0x70, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x2b, 0x0a,
+	0x11, 0x72, 0x65, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x6d, 0x65,
+	0x6e, 0x74, 0x18, 0x15, 0x20, 0x01, 0x28, 0x09, 0x52, 0x10, 0x72, 0x65, 0x73, 0x74, 0x6f, 0x72,
+	0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x47, 0x0a, 0x07, 0x63, 0x6f,
+	0x6c, 0x75, 0x6d, 0x6e, 0x73, 0x18, 0x17, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2d, 0x2e, 0x62, 0x79,
+	0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72, 0x69,
+	0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x2e, 0x49,
+	0x74, 0x65, 0x6d, 0x2e, 0x43, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x52, 0x07, 0x63, 0x6f, 0x6c, 0x75,
+	0x6d, 0x6e, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x63,
+	0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x18, 0x18, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x76, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x12, 0x64, 0x0a, 0x11, 0x61, 0x72,
+	0x63, 0x68, 0x69, 0x76, 0x65, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x19, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x37, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65,
+	0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b,
+	0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x2e, 0x49, 0x74, 0x65, 0x6d, 0x2e, 0x41, 0x72,
+	0x63, 0x68, 0x69, 0x76, 0x65, 0x50, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x10,
+	0x61, 0x72, 0x63, 0x68, 0x69, 0x76, 0x65, 0x50, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x1d, 0x0a, 0x0a, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x1a,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x4b, 0x65, 0x79, 0x12,
+	0x43, 0x0a, 0x0f, 0x69, 0x6d, 0x6d, 0x75, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x75, 0x6e, 0x74,
+	0x69, 0x6c, 0x18, 0x1b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73,
+	0x74, 0x61, 0x6d, 0x70, 0x52, 0x0e, 0x69, 0x6d, 0x6d, 0x75, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x55,
+	0x6e, 0x74, 0x69, 0x6c, 0x1a, 0x51, 0x0a, 0x05, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x1a, 0x0a,
+	0x08, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x63, 0x68,
+	0x65, 0x6d, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x63, 0x68, 0x65, 0x6d,
+	0x61, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x1a, 0x8c, 0x01, 0x0a, 0x0d, 0x4f, 0x77, 0x6e, 0x65,
+	0x64, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x6f, 0x6c,
+	0x75, 0x6d, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x6f, 0x6c, 0x75, 0x6d,
+	0x6e, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65, 0x71,
+	0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x65, 0x71,
+	0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x2f, 0x0a, 0x13, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74,
+	0x79, 0x5f, 0x67, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x12, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x47, 0x65, 0x6e, 0x65,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x1a, 0x5e, 0x0a, 0x04, 0x53, 0x69, 0x6e, 0x6b, 0x12, 0x14,
+	0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74,
+	0x6f, 0x70, 0x69, 0x63, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x74, 0x61, 0x72, 0x74, 0x5f, 0x6f, 0x66,
+	0x66, 0x73, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x73, 0x74, 0x61, 0x72,
+	0x74, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x65, 0x6e, 0x64, 0x5f, 0x6f,
+	0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x65, 0x6e, 0x64,
+	0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x1a, 0x79, 0x0a, 0x05, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x12,
+	0x16, 0x0a, 0x06, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x12, 0x19, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x88,
+	0x01, 0x01, 0x12, 0x15, 0x0a, 0x03, 0x65, 0x6e, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x48,
+	0x01, 0x52, 0x03, 0x65, 0x6e, 0x64, 0x88, 0x01, 0x01, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x75, 0x6c,
+	0x6c, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x6e, 0x75, 0x6c, 0x6c, 0x73, 0x42,
+	0x08, 0x0a, 0x06, 0x5f, 0x73, 0x74, 0x61, 0x72, 0x74, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x65, 0x6e,
+	0x64, 0x1a, 0x54, 0x0a, 0x06, 0x43, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
+	0x18, 0x0a, 0x07, 0x64, 0x65, 0x66, 0x61, 0x75, 0x6c, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x64, 0x65, 0x66, 0x61, 0x75, 0x6c, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x63, 0x6f, 0x6c,
+	0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x63, 0x6f,
+	0x6c, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x1a, 0xe6, 0x01, 0x0a, 0x10, 0x41, 0x72, 0x63, 0x68,
+	0x69, 0x76, 0x65, 0x50, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x42, 0x0a, 0x05,
+	0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x62, 0x79,
+	0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72, 0x69,
+	0x6f, 0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x2e, 0x49,
+	0x74, 0x65, 0x6d, 0x2e, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x52, 0x05, 0x74, 0x61, 0x62, 0x6c, 0x65,
+	0x12, 0x1c, 0x0a, 0x09, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x39,
+	0x0a, 0x0a, 0x73, 0x74, 0x61, 0x72, 0x74, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09,
+	0x73, 0x74, 0x61, 0x72, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x35, 0x0a, 0x08, 0x65, 0x6e, 0x64,
+	0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x07, 0x65, 0x6e, 0x64, 0x54, 0x69, 0x6d, 0x65,
+	0x22, 0x50, 0x0a, 0x08, 0x53, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x79, 0x12, 0x18, 0x0a, 0x14,
+	0x53, 0x54, 0x52, 0x41, 0x54, 0x45, 0x47, 0x59, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49,
+	0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x53, 0x54, 0x41, 0x54, 0x45, 0x4d,
+	0x45, 0x4e, 0x54, 0x10, 0x01, 0x12, 0x0d, 0x0a, 0x09, 0x42, 0x55, 0x4c, 0x4b, 0x5f, 0x43, 0x4f,
+	0x50, 0x59, 0x10, 0x02, 0x12, 0x0c, 0x0a, 0x08, 0x44, 0x45, 0x46, 0x45, 0x52, 0x52, 0x45, 0x44,
+	0x10, 0x03, 0x22, 0x42, 0x0a, 0x0c, 0x53, 0x75, 0x72, 0x72, 0x6f, 0x67, 0x61, 0x74, 0x65, 0x4b,
+	0x65, 0x79, 0x12, 0x1d, 0x0a, 0x19, 0x53, 0x55, 0x52, 0x52, 0x4f, 0x47, 0x41, 0x54, 0x45, 0x5f,
+	0x4b, 0x45, 0x59, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10,
+	0x00, 0x12, 0x09, 0x0a, 0x05, 0x52, 0x4f, 0x57, 0x49, 0x44, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04,
+	0x55, 0x55, 0x49, 0x44, 0x10, 0x02, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x72, 0x6f, 0x77, 0x5f, 0x63,
+	0x6f, 0x75, 0x6e, 0x74, 0x1a, 0x42, 0x0a, 0x14, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x53,
+	0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x62, 0x0a, 0x10, 0x44, 0x61, 0x74, 0x61,
+	0x62, 0x61, 0x73, 0x65, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x1a, 0x0a, 0x08,
+	0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x6e, 0x61, 0x70,
+	0x73, 0x68, 0x6f, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x6e, 0x61, 0x70,
+	0x73, 0x68, 0x6f, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x22, 0x99, 0x03, 0x0a,
+	0x0d, 0x53, 0x63, 0x68, 0x65, 0x64, 0x75, 0x6c, 0x65, 0x72, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x3b,
+	0x0a, 0x0b, 0x72, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52,
+	0x0a, 0x72, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x4f, 0x0a, 0x0d, 0x77,
+	0x61, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x5f, 0x63, 0x61, 0x75, 0x73, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x2a, 0x2e, 0x62, 0x79, 0x74, 0x65, 0x62, 0x61, 0x73, 0x65, 0x2e, 0x73, 0x74,
+	0x6f, 0x72, 0x65, 0x2e, 0x53, 0x63, 0x68, 0x65, 0x64, 0x75, 0x6c, 0x65, 0x72, 0x49, 0x6e, 0x66,
+	0x6f, 0x2e, 0x57, 0x61, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x43, 0x61, 0x75, 0x73, 0x65, 0x52, 0x0c,
+	0x77, 0x61, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x43, 0x61, 0x75, 0x73, 0x65, 0x1a, 0xf9, 0x01, 0x0a,
+	0x0c, 0x57, 0x61, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x43, 0x61, 0x75, 0x73, 0x65, 0x12, 0x2b, 0x0a,
+	0x10, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6c, 0x69, 0x6d, 0x69,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x48, 0x00, 0x52, 0x0f, 0x63, 0x6f, 0x6e, 0x6e, 0x65,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x1b, 0x0a, 0x08, 0x74, 0x61,
+	0x73, 0x6b, 0x5f, 0x75, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x07,
+	0x74, 0x61, 0x73, 0x6b, 0x55, 0x69, 0x64, 0x12, 0x5b, 0x0a, 0x1b, 0x70, 0x72, 0x69, 0x6f, 0x72,
+	0x5f, 0x62, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x5f, 0x62, 0x6c, 0x61, 0x63, 0x6b, 0x6f, 0x75, 0x74,
+	0x5f, 0x75, 0x6e, 0x74, 0x69, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x48, 0x00, 0x52, 0x18, 0x70, 0x72, 0x69, 0x6f,
+	0x72, 0x42, 0x61, 0x63, 0x6b, 0x75, 0x70, 0x42, 0x6c, 0x61, 0x63, 0x6b, 0x6f, 0x75, 0x74, 0x55,
+	0x6e, 0x74, 0x69, 0x6c, 0x12, 0x39, 0x0a, 0x18, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x5f, 0x62, 0x61,
+	0x63, 0x6b, 0x75, 0x70, 0x5f, 0x69, 0x6e, 0x5f, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x48, 0x00, 0x52, 0x15, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x42,
+	0x61, 0x63, 0x6b, 0x75, 0x70, 0x49, 0x6e, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x42,
+	0x07, 0x0a, 0x05, 0x63, 0x61, 0x75, 0x73, 0x65, 0x42, 0x14, 0x5a, 0x12, 0x67, 0x65, 0x6e, 0x65,
+	0x72, 0x61, 0x74, 0x65, 0x64, 0x2d, 0x67, 0x6f, 0x2f, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -594,34 +1839,67 @@ func file_store_task_run_proto_rawDescGZIP() []byte {
 	return file_store_task_run_proto_rawDescData
 }
 
-var file_store_task_run_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_store_task_run_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_store_task_run_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_store_task_run_proto_goTypes = []any{
-	(*TaskRunResult)(nil),                // 0: bytebase.store.TaskRunResult
-	(*PriorBackupDetail)(nil),            // 1: bytebase.store.PriorBackupDetail
-	(*SchedulerInfo)(nil),                // 2: bytebase.store.SchedulerInfo
-	(*TaskRunResult_Position)(nil),       // 3: bytebase.store.TaskRunResult.Position
-	(*PriorBackupDetail_Item)(nil),       // 4: bytebase.store.PriorBackupDetail.Item
-	(*PriorBackupDetail_Item_Table)(nil), // 5: bytebase.store.PriorBackupDetail.Item.Table
-	(*SchedulerInfo_WaitingCause)(nil),   // 6: bytebase.store.SchedulerInfo.WaitingCause
-	(*timestamppb.Timestamp)(nil),        // 7: google.protobuf.Timestamp
-	(*Position)(nil),                     // 8: bytebase.store.Position
+	(PriorBackupDetail_Item_Strategy)(0),            // 0: bytebase.store.PriorBackupDetail.Item.Strategy
+	(PriorBackupDetail_Item_SurrogateKey)(0),        // 1: bytebase.store.PriorBackupDetail.Item.SurrogateKey
+	(*TaskRunResult)(nil),                           // 2: bytebase.store.TaskRunResult
+	(*PriorBackupDetail)(nil),                       // 3: bytebase.store.PriorBackupDetail
+	(*SchedulerInfo)(nil),                           // 4: bytebase.store.SchedulerInfo
+	(*TaskRunResult_Position)(nil),                  // 5: bytebase.store.TaskRunResult.Position
+	(*PriorBackupDetail_Item)(nil),                  // 6: bytebase.store.PriorBackupDetail.Item
+	nil,                                             // 7: bytebase.store.PriorBackupDetail.SessionSettingsEntry
+	(*PriorBackupDetail_DatabaseSnapshot)(nil),      // 8: bytebase.store.PriorBackupDetail.DatabaseSnapshot
+	(*PriorBackupDetail_Item_Table)(nil),            // 9: bytebase.store.PriorBackupDetail.Item.Table
+	(*PriorBackupDetail_Item_OwnedSequence)(nil),    // 10: bytebase.store.PriorBackupDetail.Item.OwnedSequence
+	(*PriorBackupDetail_Item_Sink)(nil),             // 11: bytebase.store.PriorBackupDetail.Item.Sink
+	(*PriorBackupDetail_Item_Range)(nil),            // 12: bytebase.store.PriorBackupDetail.Item.Range
+	(*PriorBackupDetail_Item_Column)(nil),           // 13: bytebase.store.PriorBackupDetail.Item.Column
+	(*PriorBackupDetail_Item_ArchivePartition)(nil), // 14: bytebase.store.PriorBackupDetail.Item.ArchivePartition
+	(*SchedulerInfo_WaitingCause)(nil),              // 15: bytebase.store.SchedulerInfo.WaitingCause
+	(PriorBackupPostSuccessPolicy)(0),               // 16: bytebase.store.PriorBackupPostSuccessPolicy
+	(*timestamppb.Timestamp)(nil),                   // 17: google.protobuf.Timestamp
+	(*Position)(nil),                                // 18: bytebase.store.Position
+	(*TablePartitionMetadata)(nil),                  // 19: bytebase.store.TablePartitionMetadata
+	(*CheckConstraintMetadata)(nil),                 // 20: bytebase.store.CheckConstraintMetadata
 }
 var file_store_task_run_proto_depIdxs = []int32{
-	3,  // 0: bytebase.store.TaskRunResult.start_position:type_name -> bytebase.store.TaskRunResult.Position
-	3,  // 1: bytebase.store.TaskRunResult.end_position:type_name -> bytebase.store.TaskRunResult.Position
-	1,  // 2: bytebase.store.TaskRunResult.prior_backup_detail:type_name -> bytebase.store.PriorBackupDetail
-	4,  // 3: bytebase.store.PriorBackupDetail.items:type_name -> bytebase.store.PriorBackupDetail.Item
-	7,  // 4: bytebase.store.SchedulerInfo.report_time:type_name -> google.protobuf.Timestamp
-	6,  // 5: bytebase.store.SchedulerInfo.waiting_cause:type_name -> bytebase.store.SchedulerInfo.WaitingCause
-	5,  // 6: bytebase.store.PriorBackupDetail.Item.source_table:type_name -> bytebase.store.PriorBackupDetail.Item.Table
-	5,  // 7: bytebase.store.PriorBackupDetail.Item.target_table:type_name -> bytebase.store.PriorBackupDetail.Item.Table
-	8,  // 8: bytebase.store.PriorBackupDetail.Item.start_position:type_name -> bytebase.store.Position
-	8,  // 9: bytebase.store.PriorBackupDetail.Item.end_position:type_name -> bytebase.store.Position
-	10, // [10:10] is the sub-list for method output_type
-	10, // [10:10] is the sub-list for method input_type
-	10, // [10:10] is the sub-list for extension type_name
-	10, // [10:10] is the sub-list for extension extendee
-	0,  // [0:10] is the sub-list for field type_name
+	5,  // 0: bytebase.store.TaskRunResult.start_position:type_name -> bytebase.store.TaskRunResult.Position
+	5,  // 1: bytebase.store.TaskRunResult.end_position:type_name -> bytebase.store.TaskRunResult.Position
+	3,  // 2: bytebase.store.TaskRunResult.prior_backup_detail:type_name -> bytebase.store.PriorBackupDetail
+	6,  // 3: bytebase.store.PriorBackupDetail.items:type_name -> bytebase.store.PriorBackupDetail.Item
+	16, // 4: bytebase.store.PriorBackupDetail.post_success_policy:type_name -> bytebase.store.PriorBackupPostSuccessPolicy
+	17, // 5: bytebase.store.PriorBackupDetail.drop_time:type_name -> google.protobuf.Timestamp
+	7,  // 6: bytebase.store.PriorBackupDetail.session_settings:type_name -> bytebase.store.PriorBackupDetail.SessionSettingsEntry
+	8,  // 7: bytebase.store.PriorBackupDetail.database_snapshot:type_name -> bytebase.store.PriorBackupDetail.DatabaseSnapshot
+	17, // 8: bytebase.store.PriorBackupDetail.complete_time:type_name -> google.protobuf.Timestamp
+	17, // 9: bytebase.store.SchedulerInfo.report_time:type_name -> google.protobuf.Timestamp
+	15, // 10: bytebase.store.SchedulerInfo.waiting_cause:type_name -> bytebase.store.SchedulerInfo.WaitingCause
+	9,  // 11: bytebase.store.PriorBackupDetail.Item.source_table:type_name -> bytebase.store.PriorBackupDetail.Item.Table
+	9,  // 12: bytebase.store.PriorBackupDetail.Item.target_table:type_name -> bytebase.store.PriorBackupDetail.Item.Table
+	18, // 13: bytebase.store.PriorBackupDetail.Item.start_position:type_name -> bytebase.store.Position
+	18, // 14: bytebase.store.PriorBackupDetail.Item.end_position:type_name -> bytebase.store.Position
+	10, // 15: bytebase.store.PriorBackupDetail.Item.owned_sequences:type_name -> bytebase.store.PriorBackupDetail.Item.OwnedSequence
+	0,  // 16: bytebase.store.PriorBackupDetail.Item.strategy:type_name -> bytebase.store.PriorBackupDetail.Item.Strategy
+	1,  // 17: bytebase.store.PriorBackupDetail.Item.surrogate_key:type_name -> bytebase.store.PriorBackupDetail.Item.SurrogateKey
+	11, // 18: bytebase.store.PriorBackupDetail.Item.sink:type_name -> bytebase.store.PriorBackupDetail.Item.Sink
+	12, // 19: bytebase.store.PriorBackupDetail.Item.range:type_name -> bytebase.store.PriorBackupDetail.Item.Range
+	19, // 20: bytebase.store.PriorBackupDetail.Item.partitions:type_name -> bytebase.store.TablePartitionMetadata
+	9,  // 21: bytebase.store.PriorBackupDetail.Item.after_image_table:type_name -> bytebase.store.PriorBackupDetail.Item.Table
+	20, // 22: bytebase.store.PriorBackupDetail.Item.check_constraints:type_name -> bytebase.store.CheckConstraintMetadata
+	13, // 23: bytebase.store.PriorBackupDetail.Item.columns:type_name -> bytebase.store.PriorBackupDetail.Item.Column
+	14, // 24: bytebase.store.PriorBackupDetail.Item.archive_partition:type_name -> bytebase.store.PriorBackupDetail.Item.ArchivePartition
+	17, // 25: bytebase.store.PriorBackupDetail.Item.immutable_until:type_name -> google.protobuf.Timestamp
+	9,  // 26: bytebase.store.PriorBackupDetail.Item.ArchivePartition.table:type_name -> bytebase.store.PriorBackupDetail.Item.Table
+	17, // 27: bytebase.store.PriorBackupDetail.Item.ArchivePartition.start_time:type_name -> google.protobuf.Timestamp
+	17, // 28: bytebase.store.PriorBackupDetail.Item.ArchivePartition.end_time:type_name -> google.protobuf.Timestamp
+	17, // 29: bytebase.store.SchedulerInfo.WaitingCause.prior_backup_blackout_until:type_name -> google.protobuf.Timestamp
+	30, // [30:30] is the sub-list for method output_type
+	30, // [30:30] is the sub-list for method input_type
+	30, // [30:30] is the sub-list for extension type_name
+	30, // [30:30] is the sub-list for extension extendee
+	0,  // [0:30] is the sub-list for field type_name
 }
 
 func init() { file_store_task_run_proto_init() }
@@ -630,6 +1908,7 @@ func file_store_task_run_proto_init() {
 		return
 	}
 	file_store_common_proto_init()
+	file_store_database_proto_init()
 	if !protoimpl.UnsafeEnabled {
 		file_store_task_run_proto_msgTypes[0].Exporter = func(v any, i int) any {
 			switch v := v.(*TaskRunResult); i {
@@ -691,7 +1970,19 @@ func file_store_task_run_proto_init() {
 				return nil
 			}
 		}
-		file_store_task_run_proto_msgTypes[5].Exporter = func(v any, i int) any {
+		file_store_task_run_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*PriorBackupDetail_DatabaseSnapshot); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_store_task_run_proto_msgTypes[7].Exporter = func(v any, i int) any {
 			switch v := v.(*PriorBackupDetail_Item_Table); i {
 			case 0:
 				return &v.state
@@ -703,7 +1994,67 @@ func file_store_task_run_proto_init() {
 				return nil
 			}
 		}
-		file_store_task_run_proto_msgTypes[6].Exporter = func(v any, i int) any {
+		file_store_task_run_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*PriorBackupDetail_Item_OwnedSequence); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_store_task_run_proto_msgTypes[9].Exporter = func(v any, i int) any {
+			switch v := v.(*PriorBackupDetail_Item_Sink); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_store_task_run_proto_msgTypes[10].Exporter = func(v any, i int) any {
+			switch v := v.(*PriorBackupDetail_Item_Range); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_store_task_run_proto_msgTypes[11].Exporter = func(v any, i int) any {
+			switch v := v.(*PriorBackupDetail_Item_Column); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_store_task_run_proto_msgTypes[12].Exporter = func(v any, i int) any {
+			switch v := v.(*PriorBackupDetail_Item_ArchivePartition); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_store_task_run_proto_msgTypes[13].Exporter = func(v any, i int) any {
 			switch v := v.(*SchedulerInfo_WaitingCause); i {
 			case 0:
 				return &v.state
@@ -716,22 +2067,27 @@ func file_store_task_run_proto_init() {
 			}
 		}
 	}
-	file_store_task_run_proto_msgTypes[6].OneofWrappers = []any{
+	file_store_task_run_proto_msgTypes[4].OneofWrappers = []any{}
+	file_store_task_run_proto_msgTypes[10].OneofWrappers = []any{}
+	file_store_task_run_proto_msgTypes[13].OneofWrappers = []any{
 		(*SchedulerInfo_WaitingCause_ConnectionLimit)(nil),
 		(*SchedulerInfo_WaitingCause_TaskUid)(nil),
+		(*SchedulerInfo_WaitingCause_PriorBackupBlackoutUntil)(nil),
+		(*SchedulerInfo_WaitingCause_PriorBackupInProgress)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_store_task_run_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   7,
+			NumEnums:      2,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_store_task_run_proto_goTypes,
 		DependencyIndexes: file_store_task_run_proto_depIdxs,
+		EnumInfos:         file_store_task_run_proto_enumTypes,
 		MessageInfos:      file_store_task_run_proto_msgTypes,
 	}.Build()
 	File_store_task_run_proto = out.File